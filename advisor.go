@@ -0,0 +1,119 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Generating a code block costs one index selection plus one XOR of a symbol
+// for each index picked. Small symbols (and so large K) give fountain codes
+// lower reception overhead, but the per-block index selection cost is then
+// spread over fewer bytes. Where the balance lies depends on the hardware, so
+// the advisor below measures both costs on the running machine and picks the
+// smallest symbol size which still meets a target encode throughput.
+// The one-time cost of generating the intermediate blocks is not included.
+
+// MeasureXORRate times XORs of 64KB blocks for about the given duration and
+// returns the XOR rate in bytes per second.
+func MeasureXORRate(d time.Duration) float64 {
+	a := block{data: make([]byte, 64*1024)}
+	b := block{data: make([]byte, 64*1024)}
+	n := 0
+	start := time.Now()
+	for time.Since(start) < d || n == 0 {
+		for i := 0; i < 16; i++ {
+			b.xor(a)
+		}
+		n += 16
+	}
+	return float64(n*len(a.data)) / time.Since(start).Seconds()
+}
+
+// MeasurePickRate times index selection for the codec for about the given
+// duration. It returns the number of selections per second and the mean number
+// of indices selected, which is the number of XORs needed per code block.
+// The IDs picked for wrap around after MaxDistinctRaptorESI, so that a long
+// measurement stays within the raptor codec's ESIs.
+func MeasurePickRate(c Codec, d time.Duration) (perSecond, meanDegree float64) {
+	n, indices := 0, 0
+	start := time.Now()
+	for time.Since(start) < d || n == 0 {
+		for i := 0; i < 16; i++ {
+			indices += len(c.PickIndices(int64(n % (MaxDistinctRaptorESI + 1))))
+			n++
+		}
+	}
+	return float64(n) / time.Since(start).Seconds(), float64(indices) / float64(n)
+}
+
+// EncodeAdvice is a recommended encoding configuration.
+type EncodeAdvice struct {
+	// SymbolSize is the size in bytes of each source and code block.
+	SymbolSize int
+
+	// SourceBlocks is the number of source blocks (K) for the message.
+	SourceBlocks int
+
+	// Throughput is the predicted rate of code block generation, in bytes of
+	// code blocks per second.
+	Throughput float64
+}
+
+// AdviseSymbolSize measures the cost of XOR and index selection on this
+// machine and recommends the smallest symbol size (and so the largest number
+// of source blocks) for which encoding a message of the given length is
+// predicted to reach targetThroughput bytes per second.
+// Symbol sizes tried are powers of two from 16 bytes to 64KB which are
+// multiples of alignment. newCodec creates the codec to be used for a given
+// number of source blocks; it may return nil for unsupported values. Each
+// measurement runs for about probe, so the call takes roughly 14*probe.
+// If no symbol size meets the target, returns the fastest configuration found
+// along with an error.
+func AdviseSymbolSize(newCodec func(sourceBlocks int) Codec, messageLength int,
+	targetThroughput float64, alignment int, probe time.Duration) (EncodeAdvice, error) {
+	xorRate := MeasureXORRate(probe)
+
+	var best EncodeAdvice
+	for size := 16; size <= 64*1024; size *= 2 {
+		if alignment > 0 && size%alignment != 0 {
+			continue
+		}
+		k := (messageLength + size - 1) / size
+		if k < 1 {
+			k = 1
+		}
+		c := newCodec(k)
+		if c == nil {
+			continue
+		}
+		pickRate, degree := MeasurePickRate(c, probe)
+		perBlock := 1/pickRate + degree*float64(size)/xorRate
+		advice := EncodeAdvice{SymbolSize: size, SourceBlocks: k, Throughput: float64(size) / perBlock}
+		if advice.Throughput >= targetThroughput {
+			return advice, nil
+		}
+		if advice.Throughput > best.Throughput {
+			best = advice
+		}
+		if k == 1 {
+			// Larger symbols only add padding.
+			break
+		}
+	}
+	return best, fmt.Errorf("fountain: no symbol size reaches %.0f bytes/s; best is %.0f bytes/s",
+		targetThroughput, best.Throughput)
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMeasureRates(t *testing.T) {
+	if rate := MeasureXORRate(time.Millisecond); rate <= 0 {
+		t.Errorf("MeasureXORRate = %f, want positive", rate)
+	}
+	perSecond, degree := MeasurePickRate(NewRaptorCodec(100, 4), time.Millisecond)
+	if perSecond <= 0 || degree < 1 || degree > 40 {
+		t.Errorf("MeasurePickRate = (%f, %f), want positive rate and degree in [1, 40]",
+			perSecond, degree)
+	}
+}
+
+func TestMeasurePickRateRaptorESIs(t *testing.T) {
+	// Measure for long enough to pick for past the last raptor ESI, which the
+	// raptor codec rejects.
+	c := NewRaptorCodec(100, 4)
+	perSecond, _ := MeasurePickRate(c, 10*time.Millisecond)
+	d := min(time.Duration(2*(MaxRaptorESI+1)/perSecond*float64(time.Second)), 5*time.Second)
+	if perSecond, degree := MeasurePickRate(c, d); perSecond <= 0 || degree < 1 || degree > 40 {
+		t.Errorf("MeasurePickRate = (%f, %f), want positive rate and degree in [1, 40]",
+			perSecond, degree)
+	}
+	newCodec := func(k int) Codec {
+		if k < 4 || k > 8192 {
+			return nil
+		}
+		return NewRaptorCodec(k, 4)
+	}
+	if _, err := AdviseSymbolSize(newCodec, 1<<16, 0, 4, d); err != nil {
+		t.Errorf("AdviseSymbolSize failed: %v", err)
+	}
+}
+
+func TestAdviseSymbolSize(t *testing.T) {
+	newCodec := func(k int) Codec {
+		if k < 4 || k > 8192 {
+			return nil
+		}
+		return NewRaptorCodec(k, 4)
+	}
+
+	advice, err := AdviseSymbolSize(newCodec, 1<<20, 0, 4, time.Millisecond)
+	if err != nil {
+		t.Fatalf("AdviseSymbolSize with no target failed: %v", err)
+	}
+	// The smallest symbol size giving K <= 8192 for 1MB is 128 bytes.
+	if advice.SymbolSize != 128 || advice.SourceBlocks != 8192 {
+		t.Errorf("Got %+v, want 128-byte symbols and K=8192", advice)
+	}
+
+	advice, err = AdviseSymbolSize(newCodec, 1<<20, math.Inf(1), 4, time.Millisecond)
+	if err == nil {
+		t.Errorf("AdviseSymbolSize met an infinite target: %+v", advice)
+	}
+	if advice.Throughput <= 0 {
+		t.Errorf("Got %+v with unreachable target, want the fastest configuration", advice)
+	}
+}
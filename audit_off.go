@@ -0,0 +1,37 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !fountain_audit
+
+package fountain
+
+// auditLog records buffers handed to a decoder so that later modification by
+// the caller can be detected. Without the fountain_audit build tag it does
+// nothing, and buffers are used in place.
+type auditLog struct{}
+
+// handoff returns the buffer the decoder should use in place of data.
+func (a *auditLog) handoff(data []byte) []byte {
+	return data
+}
+
+// check panics if any handed-off buffer has been modified since.
+func (a *auditLog) check() {}
+
+// release forgets the handed-off buffers once the decoder no longer uses
+// them.
+func (a *auditLog) release() {}
+
+// auditPoison overwrites a buffer the package has finished borrowing.
+func auditPoison(b []byte) {}
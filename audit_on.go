@@ -0,0 +1,79 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fountain_audit
+
+package fountain
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// The package borrows the caller's buffers in places: decoders keep the Data
+// of the code blocks they are given, and EncodeLTBlocks overwrites its message.
+// Building with the fountain_audit tag makes these handoffs checked. Decoders
+// work on private copies of code block data and panic when solving their
+// equations if the caller has since modified the originals, and buffers the
+// package has finished borrowing are filled with a poison pattern so that
+// callers relying on their contents fail visibly.
+
+// auditPoisonByte is the value borrowed buffers are filled with.
+const auditPoisonByte = 0xa5
+
+type auditEntry struct {
+	data []byte
+	sum  uint64
+}
+
+// auditLog records buffers handed to a decoder so that later modification by
+// the caller can be detected.
+type auditLog struct {
+	entries []auditEntry
+}
+
+func auditSum(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// handoff records data and returns a private copy for the decoder to use.
+func (a *auditLog) handoff(data []byte) []byte {
+	a.entries = append(a.entries, auditEntry{data: data, sum: auditSum(data)})
+	return append([]byte(nil), data...)
+}
+
+// check panics if any handed-off buffer has been modified since.
+func (a *auditLog) check() {
+	for i, e := range a.entries {
+		if auditSum(e.data) != e.sum {
+			panic(fmt.Sprintf("fountain: code block data handed to the decoder (block %d of %d) was modified by the caller",
+				i, len(a.entries)))
+		}
+	}
+}
+
+// release forgets the handed-off buffers once the decoder no longer uses
+// them.
+func (a *auditLog) release() {
+	a.entries = nil
+}
+
+// auditPoison overwrites a buffer the package has finished borrowing.
+func auditPoison(b []byte) {
+	for i := range b {
+		b[i] = auditPoisonByte
+	}
+}
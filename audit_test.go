@@ -0,0 +1,75 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fountain_audit
+
+package fountain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditPoisonsMessage(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	EncodeLTBlocks(message, []int64{0, 1}, NewRaptorCodec(13, 2))
+	for i, b := range message {
+		if b != auditPoisonByte {
+			t.Fatalf("Message byte %d is %x after encoding, want poison", i, b)
+		}
+	}
+}
+
+func TestAuditDetectsMutation(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	var ids []int64
+	for i := int64(0); i < 16; i++ {
+		ids = append(ids, i)
+	}
+	blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, codec)
+	d := codec.NewDecoder(len(message))
+	d.AddBlocks(blocks[:8])
+
+	// The caller reuses a receive buffer after handing it to the decoder, which
+	// is caught when the decoder becomes determined and solves its equations.
+	blocks[4].Data[0] ^= 0xff
+	defer func() {
+		r := recover()
+		if s, ok := r.(string); !ok || !strings.Contains(s, "modified by the caller") {
+			t.Errorf("AddBlocks after mutation recovered %v, want an audit panic", r)
+		}
+	}()
+	d.AddBlocks(blocks[8:])
+}
+
+func TestAuditReleasesOnDetermination(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	var ids []int64
+	for i := int64(0); i < 16; i++ {
+		ids = append(ids, i)
+	}
+	blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, codec)
+	d := codec.NewDecoder(len(message))
+	if !d.AddBlocks(blocks) {
+		t.Fatalf("decoder not determined")
+	}
+
+	// Once determined, the decoder is done with the buffers.
+	blocks[4].Data[0] ^= 0xff
+	if decoded := d.Decode(); string(decoded) != string(message) {
+		t.Errorf("decoded %q, want %q", decoded, message)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+// A multicast receiver takes code blocks from anyone who can reach the group,
+// and a single bogus block which enters its decode matrix corrupts the decoded
+// message: the pollution attack. An authenticated codec seals every code block
+// its encoders generate, and its decoders drop the blocks which don't open
+// before they reach the matrix, so an attacker without the key can only cost
+// a receiver the work of checking its blocks. A SymbolSealer encrypts the
+// blocks as well, while a SymbolMAC only appends a tag, for content which is
+// public but must not be forged.
+//
+// Each transfer should have its own key, so that blocks can't be replayed
+// from one transfer into another even if the object IDs repeat;
+// DeriveTransferKey derives one from a long-term secret.
+
+// SymbolAuthenticator seals code blocks so that forged or modified ones are
+// detected. SymbolSealer and SymbolMAC implement it.
+type SymbolAuthenticator interface {
+	// Seal returns the block with its data protected.
+	Seal(b LTBlock) LTBlock
+
+	// Open returns the block a sealed block was made from, or ErrSymbolAuth
+	// if it doesn't authenticate.
+	Open(b LTBlock) (LTBlock, error)
+}
+
+// Tag sizes of a SymbolMAC, in bytes.
+const (
+	MinSymbolMACTagSize = 12
+	MaxSymbolMACTagSize = sha256.Size
+)
+
+// MinSymbolMACKeySize is the least key size of a SymbolMAC, in bytes.
+const MinSymbolMACKeySize = 16
+
+// SymbolMAC authenticates individual code blocks of one object with a
+// truncated HMAC-SHA256 tag, leaving their data in the clear.
+type SymbolMAC struct {
+	key      []byte
+	objectID uint32
+	tagSize  int
+}
+
+// NewSymbolMAC creates an authenticator for the code blocks of the object with
+// the given ID, appending tags of tagSize bytes. The tag covers the object ID,
+// the block ID and the data.
+func NewSymbolMAC(key []byte, objectID uint32, tagSize int) (*SymbolMAC, error) {
+	if len(key) < MinSymbolMACKeySize {
+		return nil, fmt.Errorf("fountain: MAC key of %d bytes is shorter than %d", len(key), MinSymbolMACKeySize)
+	}
+	if tagSize < MinSymbolMACTagSize || tagSize > MaxSymbolMACTagSize {
+		return nil, fmt.Errorf("fountain: MAC tag size %d not in [%d, %d]", tagSize, MinSymbolMACTagSize, MaxSymbolMACTagSize)
+	}
+	return &SymbolMAC{key: append([]byte(nil), key...), objectID: objectID, tagSize: tagSize}, nil
+}
+
+// tag returns the tag of a code block.
+func (m *SymbolMAC) tag(id int64, data []byte) []byte {
+	h := hmac.New(sha256.New, m.key)
+	var prefix [12]byte
+	ByteOrder.PutUint32(prefix[:], m.objectID)
+	ByteOrder.PutUint64(prefix[4:], uint64(id))
+	h.Write(prefix[:])
+	h.Write(data)
+	return h.Sum(nil)[:m.tagSize]
+}
+
+// Seal returns the block with the tag appended to its data.
+func (m *SymbolMAC) Seal(b LTBlock) LTBlock {
+	data := make([]byte, len(b.Data), len(b.Data)+m.tagSize)
+	copy(data, b.Data)
+	return LTBlock{BlockCode: b.BlockCode, Data: append(data, m.tag(b.BlockCode, b.Data)...)}
+}
+
+// Open checks the tag of a sealed block, and returns the block without it,
+// whose data aliases the sealed block's. Returns ErrSymbolAuth if the block
+// was modified, or sealed for another ID or object.
+func (m *SymbolMAC) Open(b LTBlock) (LTBlock, error) {
+	n := len(b.Data) - m.tagSize
+	if n < 0 || !hmac.Equal(b.Data[n:], m.tag(b.BlockCode, b.Data[:n])) {
+		return LTBlock{}, ErrSymbolAuth
+	}
+	return LTBlock{BlockCode: b.BlockCode, Data: b.Data[:n:n]}, nil
+}
+
+// DeriveTransferKey derives a key of size bytes for the transfer of the object
+// with the given ID from a long-term secret, with HKDF-SHA256.
+func DeriveTransferKey(secret []byte, objectID uint32, size int) ([]byte, error) {
+	info := ByteOrder.AppendUint32([]byte("gofountain transfer key "), objectID)
+	return hkdf.Key(sha256.New, secret, nil, string(info), size)
+}
+
+// authCodec is a Codec whose code blocks are sealed.
+type authCodec struct {
+	Codec
+	auth SymbolAuthenticator
+}
+
+// NewAuthenticatedCodec returns a codec which behaves like c, but whose
+// encoders seal each code block with a, and whose decoders drop the code
+// blocks which don't open, as if they had been lost (see RejectedBlocks).
+// Sealed code blocks are larger than c's, by the authenticator's overhead.
+func NewAuthenticatedCodec(c Codec, a SymbolAuthenticator) Codec {
+	return &authCodec{Codec: c, auth: a}
+}
+
+// NewEncoder creates an encoder of sealed code blocks.
+func (c *authCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
+// NewDecoder creates a decoder which opens the code blocks it is given.
+func (c *authCodec) NewDecoder(messageLength int) Decoder {
+	return &authDecoder{Decoder: c.Codec.NewDecoder(messageLength), auth: c.auth}
+}
+
+// unwrap returns the wrapped codec, which composes the code blocks the
+// encoder seals.
+func (c *authCodec) unwrap() Codec {
+	return c.Codec
+}
+
+// encodesInPlace reports whether the wrapped codec encodes in place.
+func (c *authCodec) encodesInPlace() bool {
+	e, ok := c.Codec.(inPlaceEncoder)
+	return ok && e.encodesInPlace()
+}
+
+// authDecoder opens code blocks before passing them to the underlying
+// codec's decoder.
+type authDecoder struct {
+	Decoder
+	auth SymbolAuthenticator
+
+	// rejected counts the blocks which didn't open.
+	rejected int
+}
+
+// AddBlocks opens the code blocks, and adds those which authenticate to the
+// decoder. Returns true if the message can be fully decoded.
+func (d *authDecoder) AddBlocks(blocks []LTBlock) bool {
+	opened := make([]LTBlock, 0, len(blocks))
+	for _, b := range blocks {
+		o, err := d.auth.Open(b)
+		if err != nil {
+			d.rejected++
+			continue
+		}
+		opened = append(opened, o)
+	}
+	return d.Decoder.AddBlocks(opened)
+}
+
+// decode decodes the message like the underlying decoder.
+func (d *authDecoder) decode(strict bool) ([]byte, error) {
+	s, ok := d.Decoder.(strictDecoder)
+	if !ok {
+		return nil, fmt.Errorf("fountain: %T doesn't support strict decoding", d.Decoder)
+	}
+	return s.decode(strict)
+}
+
+func (d *authDecoder) recoverSource() ([]block, []bool) {
+	return d.Decoder.(sourceRecoverer).recoverSource()
+}
+
+func (d *authDecoder) preloadSource(i int, data []byte) {
+	d.Decoder.(sourcePreloader).preloadSource(i, data)
+}
+
+func (d *authDecoder) gaps() MatrixGaps {
+	r, ok := d.Decoder.(gapReporter)
+	if !ok {
+		return MatrixGaps{}
+	}
+	return r.gaps()
+}
+
+// RejectedBlocks returns the number of code blocks a decoder created by an
+// authenticated codec has dropped because they didn't authenticate. Returns
+// false if d wasn't created by an authenticated codec.
+func RejectedBlocks(d Decoder) (int, bool) {
+	ad, ok := d.(*authDecoder)
+	if !ok {
+		return 0, false
+	}
+	return ad.rejected, true
+}
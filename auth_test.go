@@ -0,0 +1,176 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"math/rand"
+	"testing"
+)
+
+var (
+	_ SymbolAuthenticator = &SymbolSealer{}
+	_ SymbolAuthenticator = &SymbolMAC{}
+)
+
+func TestSymbolMAC(t *testing.T) {
+	key, err := DeriveTransferKey([]byte("long-term secret"), 7, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewSymbolMAC(key, 7, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := LTBlock{BlockCode: 42, Data: []byte("some data")}
+	sealed := m.Seal(b)
+	if len(sealed.Data) != len(b.Data)+16 || !bytes.HasPrefix(sealed.Data, b.Data) {
+		t.Errorf("Seal = %x, want the data followed by a 16-byte tag", sealed.Data)
+	}
+	if opened, err := m.Open(sealed); err != nil || !bytes.Equal(opened.Data, b.Data) || opened.BlockCode != 42 {
+		t.Errorf("Open = %v, %v; want %v", opened, err, b)
+	}
+
+	otherKey, _ := DeriveTransferKey([]byte("long-term secret"), 8, 32)
+	otherTransfer, _ := NewSymbolMAC(otherKey, 7, 16)
+	otherObject, _ := NewSymbolMAC(key, 8, 16)
+	for name, forged := range map[string]LTBlock{
+		"modified data":    {BlockCode: 42, Data: append([]byte("Some data"), sealed.Data[9:]...)},
+		"changed ID":       {BlockCode: 43, Data: sealed.Data},
+		"other transfer":   otherTransfer.Seal(b),
+		"other object":     otherObject.Seal(b),
+		"shorter than tag": {BlockCode: 42, Data: sealed.Data[:10]},
+	} {
+		if _, err := m.Open(forged); err != ErrSymbolAuth {
+			t.Errorf("Open(%s) = %v, want ErrSymbolAuth", name, err)
+		}
+	}
+
+	if _, err := NewSymbolMAC(key[:8], 7, 16); err == nil {
+		t.Error("NewSymbolMAC accepted an 8-byte key")
+	}
+	if _, err := NewSymbolMAC(key, 7, 8); err == nil {
+		t.Error("NewSymbolMAC accepted an 8-byte tag")
+	}
+}
+
+func TestAuthenticatedCodec(t *testing.T) {
+	key, _ := DeriveTransferKey([]byte("secret"), 1, 16)
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	sealer, _ := NewSymbolSealer(aead, 1)
+	mac, _ := NewSymbolMAC(key, 1, 12)
+
+	random := rand.New(rand.NewSource(3))
+	message := make([]byte, 30*16)
+	random.Read(message)
+	for _, test := range []struct {
+		name string
+		c    Codec
+		a    SymbolAuthenticator
+	}{
+		{"raptor/AEAD", NewRaptorCodec(30, 4), sealer},
+		{"raptor/MAC", NewRaptorCodec(30, 4), mac},
+		{"online/MAC", NewOnlineCodec(30, 0.2, 7, 5), mac},
+		{"reed-solomon/MAC", NewReedSolomonCodec(30, 40), mac},
+	} {
+		c := NewAuthenticatedCodec(test.c, test.a)
+		e := c.NewEncoder(message)
+		d := c.NewDecoder(len(message))
+
+		// An attacker injects a bogus block for every genuine one, some with
+		// the IDs of genuine blocks.
+		ids := make([]int64, 40)
+		for i := range ids {
+			ids[i] = int64(i)
+		}
+		sealed := EncodeLTBlocksCopy(message, ids, c)
+		var forged int
+		var determined bool
+		for i := range ids {
+			b := e.Generate(ids[i])
+			if !bytes.Equal(b.Data, sealed[i].Data) {
+				t.Fatalf("%s: Generate and EncodeLTBlocksCopy disagree on block %d", test.name, i)
+			}
+			bogus := LTBlock{BlockCode: ids[i] % 3, Data: make([]byte, len(b.Data))}
+			random.Read(bogus.Data)
+			forged++
+			if determined = d.AddBlocks([]LTBlock{bogus, b}); determined {
+				break
+			}
+		}
+		if !determined {
+			t.Fatalf("%s: decoder not determined", test.name)
+		}
+		if n, ok := RejectedBlocks(d); !ok || n != forged {
+			t.Errorf("%s: RejectedBlocks = %d, %v; want %d", test.name, n, ok, forged)
+		}
+		if got := d.Decode(); !bytes.Equal(got, message) {
+			t.Errorf("%s: decoded message differs", test.name)
+		}
+	}
+	if _, ok := RejectedBlocks(NewRaptorCodec(30, 4).NewDecoder(480)); ok {
+		t.Error("RejectedBlocks of a plain decoder reported a count")
+	}
+}
+
+func TestAuthenticatedDecoderHelpers(t *testing.T) {
+	key, _ := DeriveTransferKey([]byte("secret"), 2, 16)
+	mac, _ := NewSymbolMAC(key, 2, 16)
+	c := NewAuthenticatedCodec(NewRaptorCodec(20, 4), mac)
+	message := make([]byte, 20*8)
+	rand.New(rand.NewSource(5)).Read(message)
+	ids := make([]int64, 30)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	blocks := EncodeLTBlocksCopy(message, ids, c)
+
+	d := c.NewDecoder(len(message))
+	if _, err := TryDecode(d); err == nil {
+		t.Error("TryDecode of an empty decoder succeeded")
+	} else if _, ok := err.(*DecodeError); !ok {
+		t.Errorf("TryDecode of an empty decoder = %v, want a *DecodeError", err)
+	}
+	if !d.AddBlocks(blocks) {
+		t.Fatal("decoder not determined")
+	}
+	for name, decode := range map[string]func(Decoder) ([]byte, error){
+		"TryDecode":    TryDecode,
+		"DecodeStrict": DecodeStrict,
+		"DecodeContext": func(d Decoder) ([]byte, error) {
+			return DecodeContext(context.Background(), d)
+		},
+		"DecodeWriter": func(d Decoder) ([]byte, error) {
+			var buf bytes.Buffer
+			dw, err := NewDecodeWriter(d, &buf)
+			if err != nil {
+				return nil, err
+			}
+			_, err = dw.Flush()
+			return buf.Bytes(), err
+		},
+	} {
+		if got, err := decode(d); err != nil || !bytes.Equal(got, message) {
+			t.Errorf("%s = %d bytes, %v; want the message", name, len(got), err)
+		}
+	}
+	if diag, ok := Diagnose(d); !ok || diag.SourceBlocks != 20 || diag.MessageLength != len(message) {
+		t.Errorf("Diagnose = %+v, %v; want 20 source blocks of a %d-byte message", diag, ok, len(message))
+	}
+}
@@ -43,8 +43,11 @@ func (c *binaryCodec) SourceBlocks() int {
 // PickIndices finds the source indices for a code block given an ID and
 // a random seed. Uses the Mersenne Twister internally.
 func (c *binaryCodec) PickIndices(codeBlockIndex int64) []int {
-	random := rand.New(NewMersenneTwister(codeBlockIndex))
+	return c.pickIndices(rand.New(NewMersenneTwister(codeBlockIndex)))
+}
 
+// pickIndices picks the source indices using the given PRNG.
+func (c *binaryCodec) pickIndices(random *rand.Rand) []int {
 	var indices []int
 	for b := 0; b < c.SourceBlocks(); b++ {
 		if random.Intn(2) == 1 {
@@ -55,6 +58,13 @@ func (c *binaryCodec) PickIndices(codeBlockIndex int64) []int {
 	return indices
 }
 
+// tracePRNG records the PRNG draws of PickIndices.
+func (c *binaryCodec) tracePRNG(codeBlockIndex int64) (PRNGTrace, bool) {
+	src := &countingSource{Source: NewMersenneTwister(codeBlockIndex)}
+	c.pickIndices(rand.New(src))
+	return PRNGTrace{BlockCode: codeBlockIndex, Generator: "mt19937", Seed: []uint64{uint64(codeBlockIndex)}, Draws: src.draws}, true
+}
+
 // GenerateIntermediateBlocks simply returns the partition of the input message
 // into source blocks. It does not perform any additional precoding.
 func (c *binaryCodec) GenerateIntermediateBlocks(message []byte, numBlocks int) []block {
@@ -69,6 +79,11 @@ func (c *binaryCodec) NewDecoder(messageLength int) Decoder {
 	return newBinaryDecoder(c, messageLength)
 }
 
+// NewEncoder creates a new binary fountain code encoder
+func (c *binaryCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
 // binaryDecoder is the state required to decode a combinatoric fountain
 // code message.
 type binaryDecoder struct {
@@ -95,22 +110,41 @@ func newBinaryDecoder(c *binaryCodec, length int) *binaryDecoder {
 // AddBlocks adds a set of encoded blocks to the decoder. Returns true if the
 // message can be fully decoded. False if there is insufficient information.
 func (d *binaryDecoder) AddBlocks(blocks []LTBlock) bool {
+	if d.matrix.lateBlocks(blocks) {
+		return true
+	}
 	for i := range blocks {
-		d.matrix.addEquation(d.codec.PickIndices(blocks[i].BlockCode),
+		d.matrix.addCodeBlock(blocks[i].BlockCode, d.codec.PickIndices(blocks[i].BlockCode),
 			block{data: blocks[i].Data})
 	}
-	return d.matrix.determined()
+	return d.matrix.setComplete(d.matrix.determined())
 }
 
 // Decode extracts the decoded message from the decoder. If the decoder does
 // not have sufficient information to produce an output, returns a nil slice.
 func (d *binaryDecoder) Decode() []byte {
+	out, _ := d.decode(false)
+	return out
+}
+
+// decode reduces the decode matrix and reconstructs the message. See
+// reconstructBlocks for the meaning of strict.
+func (d *binaryDecoder) decode(strict bool) ([]byte, error) {
 	if !d.matrix.determined() {
-		return nil
+		return nil, errNotDetermined
 	}
 
 	d.matrix.reduce()
-
 	lenLong, lenShort, numLong, numShort := partition(d.messageLength, d.codec.numSourceBlocks)
-	return d.matrix.reconstruct(d.messageLength, lenLong, lenShort, numLong, numShort)
+	return reconstructBlocks(d.matrix.v, d.messageLength, lenLong, lenShort, numLong, numShort, strict)
+}
+
+// recoverSource reduces the solvable rows of the decode matrix and returns
+// the source blocks, along with which of them have been recovered. It may be
+// called before the decoder is determined.
+func (d *binaryDecoder) recoverSource() ([]block, []bool) {
+	n := d.codec.numSourceBlocks
+	ok := d.matrix.solvable()
+	d.matrix.reduce()
+	return d.matrix.v[:n], ok[:n]
 }
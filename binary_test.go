@@ -31,7 +31,7 @@ func TestBinaryDecoder(t *testing.T) {
 		ids[i] = int64(random.Intn(100000))
 	}
 
-	blocks := EncodeLTBlocks(message, ids, c)
+	blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, c)
 	t.Log("blocks =", blocks)
 
 	d := newBinaryDecoder(c.(*binaryCodec), len(message))
@@ -70,7 +70,7 @@ func TestBinaryDecoderBlockTable(t *testing.T) {
 		for i := range ids {
 			ids[i] = int64(r.Intn(100000))
 		}
-		blocks := EncodeLTBlocks(message, ids, c)
+		blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, c)
 
 		d := newBinaryDecoder(c.(*binaryCodec), len(message))
 		d.AddBlocks(blocks[0:30])
@@ -106,7 +106,7 @@ func TestBinaryDecodeMessageTable(t *testing.T) {
 		for i := range ids {
 			ids[i] = int64(r.Intn(100000))
 		}
-		blocks := EncodeLTBlocks(message, ids, c)
+		blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, c)
 
 		d := newBinaryDecoder(c.(*binaryCodec), len(message))
 		d.AddBlocks(blocks[0:25])
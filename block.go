@@ -14,6 +14,12 @@
 
 package fountain
 
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
 // A block represents a contiguous range of data being encoded or decoded,
 // or a block of coded data. Details of how the source text is split into blocks
 // is governed by the particular fountain code used.
@@ -23,6 +29,10 @@ type block struct {
 
 	// How many padding bytes this block has at the end.
 	padding int
+
+	// shared is set if data belongs to a SharedSymbol, and must be copied
+	// before it is modified.
+	shared bool
 }
 
 // newBlock creates a new block with a given length. The block will initially be
@@ -41,11 +51,25 @@ func (b *block) empty() bool {
 	return b.length() == 0
 }
 
+// zero reports whether all the bytes of the block are zero.
+func (b *block) zero() bool {
+	for _, v := range b.data {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // A common operation is to XOR entire code blocks together with other blocks.
 // When this is done, padding bytes count as 0 (that is XOR identity), and the
 // destination block will be modified so that its data is large enough to
 // contain the result of the XOR.
 func (b *block) xor(a block) {
+	if b.shared {
+		b.data = append([]byte(nil), b.data...)
+		b.shared = false
+	}
 	if len(b.data) < len(a.data) {
 		var inc = len(a.data) - len(b.data)
 		b.data = append(b.data, make([]byte, inc)...)
@@ -56,9 +80,7 @@ func (b *block) xor(a block) {
 		}
 	}
 
-	for i := 0; i < len(a.data); i++ {
-		b.data[i] ^= a.data[i]
-	}
+	xorBytes(b.data, a.data)
 }
 
 // partitionBytes partitions an input text into a sequence of p blocks. The
@@ -71,7 +93,9 @@ func partitionBytes(in []byte, p int) ([]block, []block) {
 		blocks := make([]block, num)
 		for i := range blocks {
 			if len(in) > length {
-				blocks[i].data, in = in[:length], in[length:]
+				// Cap the slice so that growing a block (as the raptor encoder does
+				// when XORing into a short block) can't overwrite its neighbour.
+				blocks[i].data, in = in[:length:length], in[length:]
 			} else {
 				blocks[i].data, in = in, []byte{}
 			}
@@ -136,6 +160,51 @@ func equalizeBlockLengths(longBlocks, shortBlocks []block) []block {
 type sparseMatrix struct {
 	coeff [][]int
 	v     []block
+
+	// bits holds the bitsets of the dense rows of coeff (see setRow), and nil
+	// for the others.
+	bits []bitRow
+
+	// cost accumulates the work done on the matrix during decoding.
+	cost DecodeCost
+
+	// stats describes the code blocks added with addCodeBlock.
+	stats receptionStats
+
+	// strategy performs reduce(). If nil, reduceCore and PushStrategy do.
+	strategy Strategy
+
+	// audit checks code block data for modification after handoff in
+	// fountain_audit builds.
+	audit auditLog
+
+	// complete is set once the decoder using the matrix is determined, and
+	// overfeed says what to do with code blocks which arrive after that.
+	complete bool
+	overfeed OverfeedPolicy
+
+	// verify checks a late code block against the decoded message when the
+	// policy is OverfeedVerify.
+	verify func(LTBlock) bool
+
+	// borrowing is set while code blocks from SharedSymbols are being added,
+	// and shared holds the references to those symbols until the matrix is
+	// compacted.
+	borrowing bool
+	shared    []*SharedSymbol
+
+	// symbolSize, if positive, is the largest code block addCodeBlock
+	// accepts.
+	symbolSize int
+
+	// collector, if set, is told about the work done on the matrix (see
+	// SetDecoderStats).
+	collector Stats
+
+	// ctx is the context of a reduceContext call in progress, and ctxErr its
+	// error if the reduction was abandoned.
+	ctx    context.Context
+	ctxErr error
 }
 
 // xorRow performs a reduction of the given candidate equation (indices, b)
@@ -145,6 +214,8 @@ type sparseMatrix struct {
 // coefficient slices are sorted.
 func (m *sparseMatrix) xorRow(s int, indices []int, b block) ([]int, block) {
 	b.xor(m.v[s])
+	m.cost.RowOps++
+	m.cost.XORBytes += int64(len(m.v[s].data))
 
 	var newIndices []int
 	coeffs := m.coeff[s]
@@ -175,29 +246,50 @@ func (m *sparseMatrix) xorRow(s int, indices []int, b block) ([]int, block) {
 // adding an equation to the matrix, it ensures that the decode matrix remains
 // triangular.
 func (m *sparseMatrix) addEquation(components []int, b block) {
+	m.cost.Equations++
+	xorBefore := m.cost.XORBytes
+	if m.collector != nil {
+		m.collector.EquationAdded()
+	}
 	// This loop reduces the incoming equation by XOR until it either fits into
 	// an empty row in the decode matrix or is discarded as redundant.
 	for len(components) > 0 && len(m.coeff[components[0]]) > 0 {
+		if m.dense(len(components)) {
+			components, b = m.addDenseEquation(components, b)
+			break
+		}
 		s := components[0]
 		if len(components) >= len(m.coeff[s]) {
 			components, b = m.xorRow(s, components, b)
 		} else {
 			// Swap the existing row for the new one, reduce the existing one and
 			// see if it fits elsewhere.
-			components, m.coeff[s] = m.coeff[s], components
-			b, m.v[s] = m.v[s], b
+			row, rowv := m.coeff[s], m.v[s]
+			m.setRow(s, components, b)
+			components, b = row, rowv
 		}
 	}
 
+	m.reportXOR(xorBefore)
 	if len(components) > 0 {
-		m.coeff[components[0]] = components
-		m.v[components[0]] = b
+		m.setRow(components[0], components, b)
+		return
+	}
+	// The equation was a combination of ones already in the matrix, so its
+	// value should have cancelled out too.
+	m.stats.redundant++
+	if m.collector != nil {
+		m.collector.RedundantSymbol()
+	}
+	if !b.zero() {
+		m.stats.inconsistent++
 	}
 }
 
 // Check to see if the decode matrix is fully specified. This is true when
 // all rows have non-empty coefficient slices.
-// TODO(gbillock): is there a weakness here if an auxiliary block is unpopulated?
+// Codecs whose precode adds blocks that needn't be recovered (the Online code's
+// auxiliary blocks) should use solvedThrough instead.
 func (m *sparseMatrix) determined() bool {
 	for _, r := range m.coeff {
 		if len(r) == 0 {
@@ -207,42 +299,108 @@ func (m *sparseMatrix) determined() bool {
 	return true
 }
 
+// solvable returns, for each row, whether the block that row leads with can be
+// solved for: the row is populated, and so are the rows of all the other
+// blocks in its equation (recursively). Since the matrix is triangular, a
+// single pass from the bottom row up settles this.
+func (m *sparseMatrix) solvable() []bool {
+	ok := make([]bool, len(m.coeff))
+	for i := len(m.coeff) - 1; i >= 0; i-- {
+		if len(m.coeff[i]) == 0 {
+			continue
+		}
+		ok[i] = true
+		for _, c := range m.coeff[i][1:] {
+			if !ok[c] {
+				ok[i] = false
+				break
+			}
+		}
+	}
+	return ok
+}
+
+// solvedThrough reports whether the first n blocks can all be solved for, even
+// if some later rows are empty.
+func (m *sparseMatrix) solvedThrough(n int) bool {
+	ok := m.solvable()
+	for i := 0; i < n; i++ {
+		if !ok[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // reduce performs Gaussian Elimination over the whole matrix. Presumes
 // the matrix is triangular, and that the method is not called unless there is
-// enough data for a solution.
+// enough data for a solution. Rows which can't be solved (see solvable) are
+// left as they are. The work is done by the matrix's Strategy; if none was
+// selected, the unsolved rows are reduced as a dense system where they are few
+// or dense enough (see reduceCore), and PushStrategy does the rest.
 // TODO(gbillock): Could profitably do this online as well?
 func (m *sparseMatrix) reduce() {
-	for i := len(m.coeff) - 1; i >= 0; i-- {
-		for j := 0; j < i; j++ {
-			ci, cj := m.coeff[i], m.coeff[j]
-			for k := 1; k < len(cj); k++ {
-				if cj[k] == ci[0] {
-					m.v[j].xor(m.v[i])
-					continue
-				}
-			}
+	m.audit.check()
+	if m.collector != nil {
+		start, xorBefore := time.Now(), m.cost.XORBytes
+		defer func() {
+			m.reportXOR(xorBefore)
+			m.collector.Reduced(time.Since(start))
+		}()
+	}
+	ok := m.solvable()
+	r := &Reduction{m: m, ok: ok}
+	if m.strategy != nil {
+		m.strategy.Reduce(r)
+		if m.ctxErr == nil {
+			// A strategy from outside the package may leave rows unsolved.
+			PullStrategy.Reduce(r)
 		}
-		// All but the leading coefficient in the rows have been reduced out.
-		m.coeff[i] = m.coeff[i][0:1]
+		return
 	}
+	if m.reduceCore(ok) {
+		return
+	}
+	PushStrategy.Reduce(r)
 }
 
-// reconstruct pastes the fully reduced values in the sparse matrix result column
-// into a new byte array and returns it. The length/number parameters are typically
-// those given by partition().
-// lenLong is how many long blocks there are.
-// lenShort is how many short blocks there are (following the long blocks).
-// numLong is how many bytes are in the long blocks.
-// numShort is how many bytes the short blocks are.
-func (m *sparseMatrix) reconstruct(totalLength, lenLong, lenShort, numLong, numShort int) []byte {
-	out := make([]byte, totalLength)
-	out = out[0:0]
-	for i := 0; i < numLong; i++ {
-		out = append(out, m.v[i].data[0:lenLong]...)
+// reconstructBlocks pastes the values of fully reduced blocks (typically the
+// sparse matrix result column) into a new byte array and returns it. The
+// length/number parameters are typically those given by partition(): it takes
+// lenLong bytes from each of the first numLong blocks and lenShort bytes from
+// each of the following numShort blocks, which must add up to totalLength.
+// A block with less data than that is zero-filled if the missing bytes are
+// accounted for as padding. Otherwise the block is short: if strict is set,
+// that is an error; if not, the block is zero-filled anyway.
+// Returns an error, and no output, if the parameters don't describe a
+// partition of totalLength bytes into the available blocks.
+func reconstructBlocks(blocks []block, totalLength, lenLong, lenShort, numLong, numShort int, strict bool) ([]byte, error) {
+	if numLong < 0 || numShort < 0 || numLong+numShort > len(blocks) {
+		return nil, fmt.Errorf("fountain: partition into %d+%d blocks, but there are %d",
+			numLong, numShort, len(blocks))
 	}
-	for i := numLong; i < numLong+numShort; i++ {
-		out = append(out, m.v[i].data[0:lenShort]...)
+	if lenLong*numLong+lenShort*numShort != totalLength {
+		return nil, fmt.Errorf("fountain: partition %d*%d+%d*%d doesn't cover %d bytes",
+			numLong, lenLong, numShort, lenShort, totalLength)
 	}
 
-	return out
+	out := make([]byte, 0, totalLength)
+	for i := 0; i < numLong+numShort; i++ {
+		want := lenShort
+		if i < numLong {
+			want = lenLong
+		}
+		data := blocks[i].data
+		if len(data) >= want {
+			out = append(out, data[:want]...)
+			continue
+		}
+		if strict && len(data)+blocks[i].padding < want {
+			return nil, fmt.Errorf("fountain: source block %d has %d bytes, want %d",
+				i, blocks[i].length(), want)
+		}
+		out = append(out, data...)
+		out = append(out, make([]byte, want-len(data))...)
+	}
+	return out, nil
 }
@@ -26,8 +26,8 @@ func TestBlockLength(t *testing.T) {
 		len int
 	}{
 		{block{}, 0},
-		{block{[]byte{1, 0, 1}, 0}, 3},
-		{block{[]byte{1, 0, 1}, 1}, 4},
+		{block{data: []byte{1, 0, 1}, padding: 0}, 3},
+		{block{data: []byte{1, 0, 1}, padding: 1}, 4},
 	}
 
 	for _, i := range lengthTests {
@@ -46,13 +46,13 @@ func TestBlockXor(t *testing.T) {
 		b   block
 		out block
 	}{
-		{block{[]byte{1, 0, 1}, 0}, block{[]byte{1, 1, 1}, 0}, block{[]byte{0, 1, 0}, 0}},
-		{block{[]byte{1}, 0}, block{[]byte{0, 14, 6}, 0}, block{[]byte{1, 14, 6}, 0}},
-		{block{}, block{[]byte{100, 200}, 0}, block{[]byte{100, 200}, 0}},
-		{block{[]byte{}, 5}, block{[]byte{0, 1, 0}, 0}, block{[]byte{0, 1, 0}, 2}},
-		{block{[]byte{}, 5}, block{[]byte{0, 1, 0, 2, 3}, 0}, block{[]byte{0, 1, 0, 2, 3}, 0}},
-		{block{[]byte{}, 5}, block{[]byte{0, 1, 0, 2, 3, 7}, 0}, block{[]byte{0, 1, 0, 2, 3, 7}, 0}},
-		{block{[]byte{1}, 4}, block{[]byte{0, 1, 0, 2, 3, 7}, 0}, block{[]byte{1, 1, 0, 2, 3, 7}, 0}},
+		{block{data: []byte{1, 0, 1}, padding: 0}, block{data: []byte{1, 1, 1}, padding: 0}, block{data: []byte{0, 1, 0}, padding: 0}},
+		{block{data: []byte{1}, padding: 0}, block{data: []byte{0, 14, 6}, padding: 0}, block{data: []byte{1, 14, 6}, padding: 0}},
+		{block{}, block{data: []byte{100, 200}, padding: 0}, block{data: []byte{100, 200}, padding: 0}},
+		{block{data: []byte{}, padding: 5}, block{data: []byte{0, 1, 0}, padding: 0}, block{data: []byte{0, 1, 0}, padding: 2}},
+		{block{data: []byte{}, padding: 5}, block{data: []byte{0, 1, 0, 2, 3}, padding: 0}, block{data: []byte{0, 1, 0, 2, 3}, padding: 0}},
+		{block{data: []byte{}, padding: 5}, block{data: []byte{0, 1, 0, 2, 3, 7}, padding: 0}, block{data: []byte{0, 1, 0, 2, 3, 7}, padding: 0}},
+		{block{data: []byte{1}, padding: 4}, block{data: []byte{0, 1, 0, 2, 3, 7}, padding: 0}, block{data: []byte{1, 1, 0, 2, 3, 7}, padding: 0}},
 	}
 
 	for _, i := range xorTests {
@@ -172,9 +172,9 @@ func TestMatrixXorRow(t *testing.T) {
 	}
 
 	for _, test := range xorRowTests {
-		m := sparseMatrix{coeff: [][]int{test.arow}, v: []block{block{[]byte{1}, 0}}}
+		m := sparseMatrix{coeff: [][]int{test.arow}, v: []block{block{data: []byte{1}, padding: 0}}}
 
-		testb := block{[]byte{2}, 0}
+		testb := block{data: []byte{2}, padding: 0}
 		test.r, testb = m.xorRow(0, test.r, testb)
 
 		// Needed since under DeepEqual the nil and the empty slice are not equal.
@@ -184,8 +184,8 @@ func TestMatrixXorRow(t *testing.T) {
 		if !reflect.DeepEqual(test.r, test.result) {
 			t.Errorf("XOR row result got %v, should be %v", test.r, test.result)
 		}
-		if !reflect.DeepEqual(testb, block{[]byte{3}, 0}) {
-			t.Errorf("XOR row block got %v, should be %v", testb, block{[]byte{3}, 0})
+		if !reflect.DeepEqual(testb, block{data: []byte{3}, padding: 0}) {
+			t.Errorf("XOR row block got %v, should be %v", testb, block{data: []byte{3}, padding: 0})
 		}
 	}
 }
@@ -278,3 +278,87 @@ func TestMatrixLarge(t *testing.T) {
 		t.Errorf("Got %v for coeff[1], expect [1, 3]", m.coeff[1])
 	}
 }
+
+func TestMatrixSolvable(t *testing.T) {
+	m := sparseMatrix{
+		coeff: [][]int{{0, 2}, {1}, {}, {3}},
+		v:     []block{{data: []byte{1}}, {data: []byte{2}}, {}, {data: []byte{4}}},
+	}
+	if got := m.solvable(); !reflect.DeepEqual(got, []bool{false, true, false, true}) {
+		t.Errorf("solvable() = %v, want [false true false true]", got)
+	}
+	if m.solvedThrough(2) {
+		t.Errorf("Row 0 depends on empty row 2, but solvedThrough(2) is true")
+	}
+
+	// With row 0 depending only on row 3, the first two rows are solvable even
+	// though row 2 stays empty, and reduce must leave row 2 alone.
+	m.coeff[0] = []int{0, 3}
+	if !m.solvedThrough(2) {
+		t.Errorf("solvedThrough(2) is false, want true")
+		printMatrix(m, t)
+	}
+	m.reduce()
+	if !reflect.DeepEqual(m.coeff[0], []int{0}) || m.v[0].data[0] != 5 {
+		t.Errorf("Equation 0 got (%v = %v), want ([0] = [5])", m.coeff[0], m.v[0].data)
+	}
+	if len(m.coeff[2]) != 0 {
+		t.Errorf("Empty row 2 became %v after reduce", m.coeff[2])
+	}
+}
+
+func TestReconstructBlocks(t *testing.T) {
+	blocks := []block{
+		{data: []byte{1, 2}},
+		{data: []byte{3, 4}},
+		{data: []byte{5}, padding: 1},
+		{data: []byte{}},
+	}
+
+	out, err := reconstructBlocks(blocks, 6, 2, 1, 2, 2, true)
+	if err == nil {
+		t.Errorf("Strict reconstruction of a block with no data succeeded: %v", out)
+	}
+	out, err = reconstructBlocks(blocks, 6, 2, 1, 2, 2, false)
+	if err != nil || !reflect.DeepEqual(out, []byte{1, 2, 3, 4, 5, 0}) {
+		t.Errorf("Got (%v, %v), want ([1 2 3 4 5 0], nil)", out, err)
+	}
+
+	// The padded block counts as having the bytes it is short.
+	out, err = reconstructBlocks(blocks, 6, 2, 2, 2, 1, true)
+	if err != nil || !reflect.DeepEqual(out, []byte{1, 2, 3, 4, 5, 0}) {
+		t.Errorf("Got (%v, %v), want ([1 2 3 4 5 0], nil)", out, err)
+	}
+
+	if _, err = reconstructBlocks(blocks, 8, 2, 1, 2, 2, false); err == nil {
+		t.Errorf("Reconstruction of a partition not covering the length succeeded")
+	}
+	if _, err = reconstructBlocks(blocks, 10, 2, 2, 3, 2, false); err == nil {
+		t.Errorf("Reconstruction of more blocks than are available succeeded")
+	}
+}
+
+func FuzzPartitionReconstruct(f *testing.F) {
+	f.Add([]byte("abcdefghijklmnopqrstuvwxyz"), 4)
+	f.Add([]byte("abcdefghijklmnopqrstuvwxyz"), 13)
+	f.Add([]byte("abc"), 7)
+	f.Add([]byte{}, 1)
+	f.Fuzz(func(t *testing.T, message []byte, p int) {
+		if p <= 0 || p > 10000 {
+			t.Skip()
+		}
+		original := make([]byte, len(message))
+		copy(original, message)
+
+		long, short := partitionBytes(message, p)
+		blocks := equalizeBlockLengths(long, short)
+		lenLong, lenShort, numLong, numShort := partition(len(message), p)
+		out, err := reconstructBlocks(blocks, len(message), lenLong, lenShort, numLong, numShort, true)
+		if err != nil {
+			t.Fatalf("Reconstructing %d bytes in %d blocks: %v", len(message), p, err)
+		}
+		if !reflect.DeepEqual(out, original) && len(original) > 0 {
+			t.Errorf("Reconstructed %v from %d blocks, want %v", out, p, original)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"sort"
+)
+
+// A sender serving many objects to many receivers over a shared uplink has a
+// fixed number of repair symbols it can send per round. Spreading that budget
+// evenly wastes symbols on objects whose receivers are nearly done while
+// starving the ones that are furthest behind. The RepairBalancer collects the
+// rank deficits receivers report (how many more independent equations their
+// decoders need) and divides the budget in proportion to them.
+//
+// Because every repair symbol of an object is useful to all of its receivers,
+// the deficit of an object is the largest deficit reported by any of its
+// receivers.
+
+// RepairAllocation is the share of a repair budget assigned to one object.
+type RepairAllocation struct {
+	// Object is the ID of the object the symbols should be generated for.
+	Object int64
+
+	// Symbols is the number of repair symbols to send for the object.
+	Symbols int
+}
+
+// RepairBalancer apportions a shared repair symbol budget among concurrently
+// served objects according to the rank deficits reported by their receivers.
+// It is not safe for concurrent use.
+type RepairBalancer struct {
+	// deficits maps object ID to the latest deficit reported by each receiver.
+	deficits map[int64]map[int64]int
+}
+
+// NewRepairBalancer creates an empty RepairBalancer.
+func NewRepairBalancer() *RepairBalancer {
+	return &RepairBalancer{deficits: make(map[int64]map[int64]int)}
+}
+
+// Report records the remaining rank deficit a receiver has for an object. A
+// deficit of zero or less means the receiver has finished with the object.
+func (b *RepairBalancer) Report(object, receiver int64, deficit int) {
+	receivers, ok := b.deficits[object]
+	if !ok {
+		receivers = make(map[int64]int)
+		b.deficits[object] = receivers
+	}
+	if deficit <= 0 {
+		delete(receivers, receiver)
+		return
+	}
+	receivers[receiver] = deficit
+}
+
+// Remove forgets all reports for the given object, for instance when the
+// sender stops serving it.
+func (b *RepairBalancer) Remove(object int64) {
+	delete(b.deficits, object)
+}
+
+// Deficit returns the deficit of an object: the largest deficit reported by
+// any receiver which has not yet finished with it.
+func (b *RepairBalancer) Deficit(object int64) int {
+	max := 0
+	for _, d := range b.deficits[object] {
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// Allocate divides budget repair symbols among the objects with outstanding
+// deficits. Each object receives a share proportional to its deficit, but
+// never more than its deficit while others still need symbols. Symbols left
+// over after every deficit is covered are handed out one at a time starting
+// with the largest deficit, since receivers usually need a few symbols beyond
+// their rank deficit to cover loss.
+// The result is ordered by decreasing deficit (ties broken by object ID) and
+// omits objects allocated no symbols.
+func (b *RepairBalancer) Allocate(budget int) []RepairAllocation {
+	type need struct {
+		object  int64
+		deficit int
+	}
+	var needs []need
+	total := 0
+	for object := range b.deficits {
+		if d := b.Deficit(object); d > 0 {
+			needs = append(needs, need{object, d})
+			total += d
+		}
+	}
+	if len(needs) == 0 || budget <= 0 {
+		return nil
+	}
+	sort.Slice(needs, func(i, j int) bool {
+		if needs[i].deficit != needs[j].deficit {
+			return needs[i].deficit > needs[j].deficit
+		}
+		return needs[i].object < needs[j].object
+	})
+
+	shares := make([]int, len(needs))
+	remaining := budget
+	if budget >= total {
+		for i := range needs {
+			shares[i] = needs[i].deficit
+		}
+		remaining -= total
+	} else {
+		for i := range needs {
+			shares[i] = budget * needs[i].deficit / total
+			remaining -= shares[i]
+		}
+	}
+
+	// Hand out what rounding (or surplus) left, largest deficit first. While
+	// under budget, skip objects whose deficit is already covered.
+	for remaining > 0 {
+		for i := range needs {
+			if remaining == 0 {
+				break
+			}
+			if budget < total && shares[i] >= needs[i].deficit {
+				continue
+			}
+			shares[i]++
+			remaining--
+		}
+	}
+
+	var allocations []RepairAllocation
+	for i := range needs {
+		if shares[i] > 0 {
+			allocations = append(allocations, RepairAllocation{Object: needs[i].object, Symbols: shares[i]})
+		}
+	}
+	return allocations
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepairBalancerDeficit(t *testing.T) {
+	b := NewRepairBalancer()
+	b.Report(1, 100, 5)
+	b.Report(1, 101, 9)
+	if b.Deficit(1) != 9 {
+		t.Errorf("Deficit(1) = %d, want 9", b.Deficit(1))
+	}
+	b.Report(1, 101, 0)
+	if b.Deficit(1) != 5 {
+		t.Errorf("Deficit(1) after receiver finished = %d, want 5", b.Deficit(1))
+	}
+	b.Remove(1)
+	if b.Deficit(1) != 0 {
+		t.Errorf("Deficit(1) after Remove = %d, want 0", b.Deficit(1))
+	}
+}
+
+func TestRepairBalancerAllocate(t *testing.T) {
+	var allocateTests = []struct {
+		deficits map[int64]int
+		budget   int
+		want     []RepairAllocation
+	}{
+		{map[int64]int{}, 10, nil},
+		{map[int64]int{1: 5}, 0, nil},
+		{map[int64]int{1: 30, 2: 10}, 20, []RepairAllocation{{1, 15}, {2, 5}}},
+		{map[int64]int{1: 10, 2: 10, 3: 10}, 10, []RepairAllocation{{1, 4}, {2, 3}, {3, 3}}},
+		{map[int64]int{1: 2, 2: 6}, 11, []RepairAllocation{{2, 8}, {1, 3}}},
+		{map[int64]int{1: 100, 2: 1}, 3, []RepairAllocation{{1, 3}}},
+	}
+
+	for _, test := range allocateTests {
+		b := NewRepairBalancer()
+		for object, deficit := range test.deficits {
+			b.Report(object, 0, deficit)
+		}
+		got := b.Allocate(test.budget)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Allocate(%d) with deficits %v = %v, want %v",
+				test.budget, test.deficits, got, test.want)
+		}
+	}
+}
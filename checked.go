@@ -0,0 +1,154 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The plain codec constructors don't check their parameters: a codec built
+// from bad ones panics or silently produces undecodable blocks when it is
+// first used. The constructors here check the parameters up front, and are
+// the ones to use when they come from configuration or from the network.
+
+// ErrInvalidCodec is returned when codec parameters are out of range.
+var ErrInvalidCodec = errors.New("fountain: invalid codec parameters")
+
+// NewRaptorCodecChecked is NewRaptorCodec, but returns an error wrapping
+// ErrInvalidCodec if sourceBlocks is outside
+// [MinRaptorSourceSymbols, MaxRaptorCodecSourceSymbols] or alignmentSize
+// isn't positive.
+func NewRaptorCodecChecked(sourceBlocks int, alignmentSize int) (Codec, error) {
+	if sourceBlocks < MinRaptorSourceSymbols || sourceBlocks > MaxRaptorCodecSourceSymbols {
+		return nil, fmt.Errorf("%w: raptor source symbols %d not in [%d, %d]",
+			ErrInvalidCodec, sourceBlocks, MinRaptorSourceSymbols, MaxRaptorCodecSourceSymbols)
+	}
+	if err := checkAlignment(alignmentSize); err != nil {
+		return nil, err
+	}
+	if _, ok := raptorParamsFor(sourceBlocks).systematicIndex(); !ok {
+		return nil, fmt.Errorf("%w: no raptor systematic index for %d source symbols",
+			ErrInvalidCodec, sourceBlocks)
+	}
+	return NewRaptorCodec(sourceBlocks, alignmentSize), nil
+}
+
+// NewRU10CodecChecked is NewRU10Codec, but returns an error wrapping
+// ErrInvalidCodec if numSourceSymbols or symbolAlignmentSize isn't positive.
+func NewRU10CodecChecked(numSourceSymbols int, symbolAlignmentSize int) (Codec, error) {
+	if err := checkSourceBlocks(numSourceSymbols); err != nil {
+		return nil, err
+	}
+	if err := checkAlignment(symbolAlignmentSize); err != nil {
+		return nil, err
+	}
+	return NewRU10Codec(numSourceSymbols, symbolAlignmentSize), nil
+}
+
+// NewOnlineCodecChecked is NewOnlineCodec, but returns an error wrapping
+// ErrInvalidCodec unless sourceBlocks and quality are positive, epsilon is in
+// (0, 1), and the parameters give the outer code at least quality auxiliary
+// blocks, that is, 0.55*quality*epsilon*sourceBlocks >= quality. Each source
+// block is attached to quality auxiliary blocks, so with fewer there are
+// duplicates, and the outer code protects less than the quality promises.
+func NewOnlineCodecChecked(sourceBlocks int, epsilon float64, quality int, seed int64) (Codec, error) {
+	if err := checkSourceBlocks(sourceBlocks); err != nil {
+		return nil, err
+	}
+	if !(epsilon > 0 && epsilon < 1) {
+		return nil, fmt.Errorf("%w: online code epsilon %v not in (0, 1)", ErrInvalidCodec, epsilon)
+	}
+	if quality < 1 {
+		return nil, fmt.Errorf("%w: online code quality %d is not positive", ErrInvalidCodec, quality)
+	}
+	c := NewOnlineCodec(sourceBlocks, epsilon, quality, seed).(*onlineCodec)
+	if aux := c.numAuxBlocks(); aux < quality {
+		return nil, fmt.Errorf("%w: online code has %d auxiliary blocks, fewer than quality %d (need 0.55*q*e*N >= q)",
+			ErrInvalidCodec, aux, quality)
+	}
+	return c, nil
+}
+
+// NewBinaryCodecChecked is NewBinaryCodec, but returns an error wrapping
+// ErrInvalidCodec if numSourceBlocks isn't positive.
+func NewBinaryCodecChecked(numSourceBlocks int) (Codec, error) {
+	if err := checkSourceBlocks(numSourceBlocks); err != nil {
+		return nil, err
+	}
+	return NewBinaryCodec(numSourceBlocks), nil
+}
+
+// NewSeededLubyCodecChecked is NewSeededLubyCodec, but returns an error
+// wrapping ErrInvalidCodec if sourceBlocks isn't positive or degreeCDF isn't a
+// degree CDF: one-based as SolitonDistribution returns, nondecreasing, and
+// ending at 1.
+func NewSeededLubyCodecChecked(sourceBlocks int, seed int64, degreeCDF []float64) (Codec, error) {
+	if err := checkSourceBlocks(sourceBlocks); err != nil {
+		return nil, err
+	}
+	if err := checkDegreeCDF(degreeCDF); err != nil {
+		return nil, err
+	}
+	return NewSeededLubyCodec(sourceBlocks, seed, degreeCDF), nil
+}
+
+// NewReedSolomonCodecChecked is NewReedSolomonCodec, but returns an error
+// wrapping ErrInvalidCodec if sourceBlocks isn't positive or shares isn't in
+// [sourceBlocks, MaxReedSolomonShares].
+func NewReedSolomonCodecChecked(sourceBlocks, shares int) (Codec, error) {
+	if err := checkSourceBlocks(sourceBlocks); err != nil {
+		return nil, err
+	}
+	if shares < sourceBlocks || shares > MaxReedSolomonShares {
+		return nil, fmt.Errorf("%w: Reed-Solomon shares %d not in [%d, %d]",
+			ErrInvalidCodec, shares, sourceBlocks, MaxReedSolomonShares)
+	}
+	return NewReedSolomonCodec(sourceBlocks, shares), nil
+}
+
+func checkSourceBlocks(n int) error {
+	if n < 1 {
+		return fmt.Errorf("%w: %d source blocks", ErrInvalidCodec, n)
+	}
+	return nil
+}
+
+func checkAlignment(al int) error {
+	if al < 1 {
+		return fmt.Errorf("%w: alignment size %d is not positive", ErrInvalidCodec, al)
+	}
+	return nil
+}
+
+// cdfTolerance is how far from 1 the last entry of a degree CDF may be, to
+// allow for rounding in summing the distribution.
+const cdfTolerance = 1e-9
+
+func checkDegreeCDF(cdf []float64) error {
+	if len(cdf) < 2 {
+		return fmt.Errorf("%w: degree CDF has no degrees", ErrInvalidCodec)
+	}
+	for i := 1; i < len(cdf); i++ {
+		if math.IsNaN(cdf[i]) || cdf[i] < cdf[i-1] || cdf[i] < 0 {
+			return fmt.Errorf("%w: degree CDF isn't nondecreasing at degree %d", ErrInvalidCodec, i)
+		}
+	}
+	if last := cdf[len(cdf)-1]; math.Abs(last-1) > cdfTolerance {
+		return fmt.Errorf("%w: degree CDF ends at %v, not 1", ErrInvalidCodec, last)
+	}
+	return nil
+}
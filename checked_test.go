@@ -0,0 +1,75 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckedConstructors(t *testing.T) {
+	valid := []struct {
+		name string
+		new  func() (Codec, error)
+	}{
+		{"raptor min", func() (Codec, error) { return NewRaptorCodecChecked(4, 1) }},
+		{"raptor max", func() (Codec, error) { return NewRaptorCodecChecked(8192, 4) }},
+		{"raptor beyond RFC", func() (Codec, error) { return NewRaptorCodecChecked(8193, 4) }},
+		{"ru10", func() (Codec, error) { return NewRU10CodecChecked(10, 1) }},
+		{"online", func() (Codec, error) { return NewOnlineCodecChecked(10, 0.3, 10, 200) }},
+		{"binary", func() (Codec, error) { return NewBinaryCodecChecked(10) }},
+		{"luby soliton", func() (Codec, error) { return NewSeededLubyCodecChecked(10, 99, SolitonDistribution(10)) }},
+		{"luby robust", func() (Codec, error) {
+			return NewSeededLubyCodecChecked(100, 99, RobustSolitonDistribution(100, 10, 0.5))
+		}},
+		{"reed-solomon", func() (Codec, error) { return NewReedSolomonCodecChecked(10, 14) }},
+		{"reed-solomon max", func() (Codec, error) { return NewReedSolomonCodecChecked(200, MaxReedSolomonShares) }},
+	}
+	for _, v := range valid {
+		c, err := v.new()
+		if err != nil || c == nil {
+			t.Errorf("%s: got (%v, %v), want a codec", v.name, c, err)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		new  func() (Codec, error)
+	}{
+		{"raptor K too small", func() (Codec, error) { return NewRaptorCodecChecked(3, 4) }},
+		{"raptor K too large", func() (Codec, error) { return NewRaptorCodecChecked(MaxRaptorCodecSourceSymbols+1, 4) }},
+		{"raptor alignment", func() (Codec, error) { return NewRaptorCodecChecked(10, 0) }},
+		{"ru10 K", func() (Codec, error) { return NewRU10CodecChecked(0, 1) }},
+		{"ru10 alignment", func() (Codec, error) { return NewRU10CodecChecked(10, -1) }},
+		{"online N", func() (Codec, error) { return NewOnlineCodecChecked(0, 0.3, 10, 200) }},
+		{"online epsilon", func() (Codec, error) { return NewOnlineCodecChecked(10, 0, 10, 200) }},
+		{"online epsilon large", func() (Codec, error) { return NewOnlineCodecChecked(10, 1.5, 10, 200) }},
+		{"online quality", func() (Codec, error) { return NewOnlineCodecChecked(10, 0.3, 0, 200) }},
+		{"online aux blocks", func() (Codec, error) { return NewOnlineCodecChecked(5, 0.1, 10, 200) }},
+		{"binary", func() (Codec, error) { return NewBinaryCodecChecked(-1) }},
+		{"luby empty cdf", func() (Codec, error) { return NewSeededLubyCodecChecked(10, 99, []float64{0}) }},
+		{"luby decreasing cdf", func() (Codec, error) { return NewSeededLubyCodecChecked(10, 99, []float64{0, 0.6, 0.5, 1}) }},
+		{"luby short cdf", func() (Codec, error) { return NewSeededLubyCodecChecked(10, 99, []float64{0, 0.5, 0.9}) }},
+		{"reed-solomon K", func() (Codec, error) { return NewReedSolomonCodecChecked(0, 4) }},
+		{"reed-solomon too few shares", func() (Codec, error) { return NewReedSolomonCodecChecked(10, 9) }},
+		{"reed-solomon too many shares", func() (Codec, error) { return NewReedSolomonCodecChecked(10, MaxReedSolomonShares+1) }},
+	}
+	for _, v := range invalid {
+		c, err := v.new()
+		if !errors.Is(err, ErrInvalidCodec) || c != nil {
+			t.Errorf("%s: got (%v, %v), want ErrInvalidCodec", v.name, c, err)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+// A receiver which restarts in the middle of a transfer would otherwise have
+// to receive every code block again. Everything a decoder knows is held in the
+// rows of its equation matrix, so those can be saved to a checkpoint and later
+// restored into a new decoder for the same message.
+//
+// A checkpoint starts with a WireHeader, then the kind and parameters of the
+// codec (see appendCodecParams), length-prefixed, then the message length and
+// the number of rows of the matrix as uvarints. Each row follows as the number of
+// its coefficients, and if there are any, the coefficients as uvarint
+// differences from the previous one (the first from the row's own index, and
+// so zero), then the length of its data, the data itself and its padding.
+
+// checkpointVersion is the wire format version of a decoder checkpoint.
+// Checkpoints of version 1 didn't identify the codec, and aren't restored.
+const checkpointVersion = 2
+
+// ErrCheckpointFormat is returned for malformed decoder checkpoints.
+var ErrCheckpointFormat = errors.New("fountain: malformed decoder checkpoint")
+
+// ErrCheckpointMismatch is returned when restoring a checkpoint into a decoder
+// for a different message or codec than the one it was taken from.
+var ErrCheckpointMismatch = errors.New("fountain: checkpoint doesn't match the decoder")
+
+// Checkpoint returns the state of a decoder created by one of the package's
+// codecs, for Restore to load into a new decoder after a restart. The decode
+// cost and reception statistics aren't saved.
+func Checkpoint(d Decoder) ([]byte, error) {
+	length, ok := decoderLength(d)
+	m := decoderMatrix(d)
+	params, known := appendCodecParams(nil, d)
+	if !ok || m == nil || !known {
+		return nil, fmt.Errorf("fountain: Checkpoint doesn't support %T", d)
+	}
+	b := WireHeader{Version: checkpointVersion}.AppendTo(nil)
+	b = binary.AppendUvarint(b, uint64(len(params)))
+	b = append(b, params...)
+	b = binary.AppendUvarint(b, uint64(length))
+	b = binary.AppendUvarint(b, uint64(len(m.coeff)))
+	for i, row := range m.coeff {
+		b = binary.AppendUvarint(b, uint64(len(row)))
+		if len(row) == 0 {
+			continue
+		}
+		prev := i
+		for _, c := range row {
+			b = binary.AppendUvarint(b, uint64(c-prev))
+			prev = c
+		}
+		b = binary.AppendUvarint(b, uint64(len(m.v[i].data)))
+		b = append(b, m.v[i].data...)
+		b = binary.AppendUvarint(b, uint64(m.v[i].padding))
+	}
+	return b, nil
+}
+
+// Restore loads a checkpoint taken by Checkpoint into d, which must be a new
+// decoder created by the same codec for the same message length as the one
+// checkpointed. The state d holds is replaced. Returns whether the message can
+// now be decoded, like d.AddBlocks.
+// Returns ErrCheckpointMismatch if d doesn't match the checkpoint, and leaves
+// d unchanged if the checkpoint can't be restored.
+func Restore(d Decoder, checkpoint []byte) (bool, error) {
+	length, ok := decoderLength(d)
+	m := decoderMatrix(d)
+	params, known := appendCodecParams(nil, d)
+	if !ok || m == nil || !known {
+		return false, fmt.Errorf("fountain: Restore doesn't support %T", d)
+	}
+	h, b, err := ParseWireHeader(checkpoint, checkpointVersion)
+	if err != nil {
+		return false, err
+	}
+	if h.Version != checkpointVersion {
+		return false, fmt.Errorf("%w: version %d doesn't identify the codec", ErrCheckpointFormat, h.Version)
+	}
+	next := func() (uint64, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, ErrShortWireData
+		}
+		b = b[n:]
+		return v, nil
+	}
+	size, err := next()
+	if err != nil {
+		return false, err
+	}
+	if size > uint64(len(b)) {
+		return false, ErrShortWireData
+	}
+	if !bytes.Equal(b[:size], params) {
+		return false, fmt.Errorf("%w: checkpoint of another codec or codec parameters", ErrCheckpointMismatch)
+	}
+	b = b[size:]
+	messageLength, err := next()
+	if err != nil {
+		return false, err
+	}
+	rows, err := next()
+	if err != nil {
+		return false, err
+	}
+	if messageLength != uint64(length) || rows != uint64(len(m.coeff)) {
+		return false, fmt.Errorf("%w: checkpoint of a %d-byte message with %d rows, decoder of a %d-byte message with %d rows",
+			ErrCheckpointMismatch, messageLength, rows, length, len(m.coeff))
+	}
+
+	coeff := make([][]int, len(m.coeff))
+	v := make([]block, len(m.v))
+	for i := range coeff {
+		n, err := next()
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			continue
+		}
+		if n > uint64(len(coeff)-i) {
+			return false, fmt.Errorf("%w: row %d has %d coefficients", ErrCheckpointFormat, i, n)
+		}
+		row := make([]int, n)
+		prev := uint64(i)
+		for j := range row {
+			delta, err := next()
+			if err != nil {
+				return false, err
+			}
+			if (j == 0) != (delta == 0) || delta >= uint64(len(coeff))-prev {
+				return false, fmt.Errorf("%w: bad coefficients in row %d", ErrCheckpointFormat, i)
+			}
+			prev += delta
+			row[j] = int(prev)
+		}
+		size, err := next()
+		if err != nil {
+			return false, err
+		}
+		if size > uint64(len(b)) {
+			return false, ErrShortWireData
+		}
+		data := bytes.Clone(b[:size])
+		b = b[size:]
+		padding, err := next()
+		if err != nil {
+			return false, err
+		}
+		if padding > uint64(length) {
+			return false, fmt.Errorf("%w: row %d has %d bytes of padding", ErrCheckpointFormat, i, padding)
+		}
+		coeff[i], v[i] = row, block{data: data, padding: int(padding)}
+	}
+	if len(b) != 0 {
+		return false, fmt.Errorf("%w: %d bytes of trailing data", ErrCheckpointFormat, len(b))
+	}
+
+	m.releaseShared()
+	m.coeff, m.v, m.bits, m.complete = coeff, v, nil, false
+	for i := range coeff {
+		if len(coeff[i]) > 0 {
+			m.setRow(i, coeff[i], v[i])
+		}
+	}
+	return d.AddBlocks(nil), nil
+}
+
+// Codec kinds of a checkpoint.
+const (
+	checkpointLuby = iota + 1
+	checkpointBinary
+	checkpointOnline
+	checkpointRaptor
+	checkpointRU10
+	checkpointROI
+)
+
+// appendCodecParams appends the kind and parameters of the codec which
+// created d to b, for Restore to check that a checkpoint was taken from a
+// decoder of the same codec. Parameters which don't fit in a few bytes, such
+// as the degree distribution of a Luby codec, are represented by their
+// CRC-32C. The PRNG a Luby codec was created with can't be identified.
+// Returns false if d wasn't created by one of the package's codecs which
+// Checkpoint supports.
+func appendCodecParams(b []byte, d Decoder) ([]byte, bool) {
+	put := func(vs ...uint64) {
+		for _, v := range vs {
+			b = binary.AppendUvarint(b, v)
+		}
+	}
+	switch d := d.(type) {
+	case *lubyDecoder:
+		c := d.codec
+		sum := crc32.New(crc32c)
+		for _, p := range c.degreeCDF {
+			binary.Write(sum, binary.LittleEndian, math.Float64bits(p))
+		}
+		for _, id := range c.schedule {
+			binary.Write(sum, binary.LittleEndian, id)
+		}
+		put(checkpointLuby, uint64(c.sourceBlocks), uint64(c.seed), uint64(c.sampling), uint64(sum.Sum32()))
+	case *binaryDecoder:
+		put(checkpointBinary, uint64(d.codec.numSourceBlocks))
+	case *onlineDecoder:
+		c := d.codec
+		put(checkpointOnline, uint64(c.numSourceBlocks), math.Float64bits(c.epsilon), uint64(c.quality),
+			uint64(c.randomSeed), uint64(c.sampling))
+	case *raptorDecoder:
+		put(checkpointRaptor, uint64(d.codec.NumSourceSymbols), uint64(d.codec.SymbolAlignmentSize))
+	case *ru10Decoder:
+		c := d.codec
+		sum := crc32.New(crc32c)
+		for _, composition := range c.compositions {
+			binary.Write(sum, binary.LittleEndian, uint32(len(composition)))
+			for _, i := range composition {
+				binary.Write(sum, binary.LittleEndian, uint32(i))
+			}
+		}
+		put(checkpointRU10, uint64(c.numSourceSymbols), uint64(c.symbolAlignmentSize), uint64(sum.Sum32()))
+	case *roiDecoder:
+		put(checkpointROI, uint64(d.codec.messageLength), uint64(len(d.codec.regions)))
+		for _, r := range d.codec.regions {
+			put(uint64(r.Offset), uint64(r.Length))
+		}
+		return appendCodecParams(b, d.Decoder)
+	case *authDecoder:
+		// Authentication doesn't change what the decoder holds.
+		return appendCodecParams(b, d.Decoder)
+	default:
+		return b, false
+	}
+	return b, true
+}
@@ -0,0 +1,122 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCheckpointRestore(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	codecs := testCodecs()
+	for _, c := range codecs {
+		enc := c.NewEncoder(message)
+		d := c.NewDecoder(len(message))
+		for id := int64(0); id < 5; id++ {
+			d.AddBlocks([]LTBlock{enc.Generate(id)})
+		}
+		checkpoint, err := Checkpoint(d)
+		if err != nil {
+			t.Fatalf("%T: Checkpoint failed: %v", c, err)
+		}
+
+		restored := c.NewDecoder(len(message))
+		if done, err := Restore(restored, checkpoint); err != nil || done {
+			t.Fatalf("%T: Restore = %v, %v, want false, nil", c, done, err)
+		}
+		for id := int64(5); ; id++ {
+			if id > 500 {
+				t.Fatalf("%T: no decode after 500 blocks", c)
+			}
+			want := d.AddBlocks([]LTBlock{enc.Generate(id)})
+			if got := restored.AddBlocks([]LTBlock{enc.Generate(id)}); got != want {
+				t.Fatalf("%T: after %d blocks, restored decoder determined = %v, want %v", c, id+1, got, want)
+			}
+			if want {
+				break
+			}
+		}
+		if out := restored.Decode(); !bytes.Equal(out, message) {
+			t.Errorf("%T: restored decoder decoded %q, want %q", c, out, message)
+		}
+
+		// A determined decoder's checkpoint restores a determined decoder.
+		checkpoint, err = Checkpoint(d)
+		if err != nil {
+			t.Fatalf("%T: Checkpoint failed: %v", c, err)
+		}
+		restored = c.NewDecoder(len(message))
+		if done, err := Restore(restored, checkpoint); err != nil || !done {
+			t.Fatalf("%T: Restore of a determined decoder = %v, %v, want true, nil", c, done, err)
+		}
+		if out := restored.Decode(); !bytes.Equal(out, message) {
+			t.Errorf("%T: restored decoder decoded %q, want %q", c, out, message)
+		}
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewRaptorCodec(10, 1)
+	enc := c.NewEncoder(message)
+	d := c.NewDecoder(len(message))
+	d.AddBlocks([]LTBlock{enc.Generate(0), enc.Generate(11), enc.Generate(12)})
+	checkpoint, err := Checkpoint(d)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	if _, err := Restore(c.NewDecoder(len(message)+1), checkpoint); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Restore into a decoder of another length = %v, want ErrCheckpointMismatch", err)
+	}
+	if _, err := Restore(NewRaptorCodec(12, 1).NewDecoder(len(message)), checkpoint); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Restore into a decoder of another codec = %v, want ErrCheckpointMismatch", err)
+	}
+
+	// Decoders of other codecs, or other parameters, may have as many rows.
+	for _, test := range []struct{ from, to Codec }{
+		{NewRaptorCodec(10, 1), NewRU10Codec(10, 1)},
+		{NewRaptorCodec(10, 1), NewRaptorCodec(10, 2)},
+		{NewBinaryCodec(10), NewSeededLubyCodec(10, 99, SolitonDistribution(10))},
+		{NewSeededLubyCodec(10, 99, SolitonDistribution(10)), NewSeededLubyCodec(10, 98, SolitonDistribution(10))},
+		{NewOnlineCodec(10, 0.3, 10, 200), NewOnlineCodec(10, 0.3, 10, 201)},
+	} {
+		from := test.from.NewDecoder(len(message))
+		from.AddBlocks([]LTBlock{test.from.NewEncoder(message).Generate(0)})
+		cp, err := Checkpoint(from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Restore(test.to.NewDecoder(len(message)), cp); !errors.Is(err, ErrCheckpointMismatch) {
+			t.Errorf("Restore of a %T checkpoint into %T = %v, want ErrCheckpointMismatch", test.from, test.to, err)
+		}
+	}
+
+	fresh := c.NewDecoder(len(message))
+	before, _ := Checkpoint(fresh)
+	for n := 0; n < len(checkpoint); n++ {
+		if _, err := Restore(fresh, checkpoint[:n]); err == nil {
+			t.Errorf("Restore of a checkpoint truncated to %d bytes succeeded", n)
+		}
+	}
+	if _, err := Restore(fresh, append(checkpoint, 0)); !errors.Is(err, ErrCheckpointFormat) {
+		t.Errorf("Restore with trailing data = %v, want ErrCheckpointFormat", err)
+	}
+	if after, _ := Checkpoint(fresh); !bytes.Equal(after, before) {
+		t.Errorf("failed Restore modified the decoder")
+	}
+}
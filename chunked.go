@@ -0,0 +1,238 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Compressing a whole object before fountain encoding it makes the object
+// useless until every source block is recovered. Compressing it in fixed-size
+// chunks instead lets a receiver decompress each chunk as soon as the source
+// blocks holding it are recovered, at some cost in compression ratio.
+
+// Chunk describes one independently compressed chunk of an object.
+type Chunk struct {
+	// Offset and Length locate the compressed chunk in the encoded message.
+	Offset, Length int
+
+	// RawLength is the length of the chunk before compression.
+	RawLength int
+}
+
+// chunkIndexVersion is the wire format version of a chunk index.
+const chunkIndexVersion = 1
+
+// maxDeflateRatio bounds the ratio of the raw to the compressed length of a
+// chunk: DEFLATE encodes a match of 258 bytes in no less than 2 bits.
+const maxDeflateRatio = 1032
+
+// CompressChunks splits the object into chunks of chunkSize bytes (the last
+// may be shorter), compresses each one with DEFLATE, and returns their
+// concatenation, which is the message to encode, along with the chunk index
+// the receiver needs to decompress it.
+func CompressChunks(object []byte, chunkSize, level int) ([]byte, []Chunk, error) {
+	if chunkSize <= 0 {
+		return nil, nil, fmt.Errorf("fountain: chunk size %d", chunkSize)
+	}
+	var message bytes.Buffer
+	w, err := flate.NewWriter(&message, level)
+	if err != nil {
+		return nil, nil, err
+	}
+	var chunks []Chunk
+	for start := 0; start < len(object); start += chunkSize {
+		end := start + chunkSize
+		if end > len(object) {
+			end = len(object)
+		}
+		offset := message.Len()
+		w.Reset(&message)
+		w.Write(object[start:end])
+		if err := w.Close(); err != nil {
+			return nil, nil, err
+		}
+		chunks = append(chunks, Chunk{Offset: offset, Length: message.Len() - offset, RawLength: end - start})
+	}
+	return message.Bytes(), chunks, nil
+}
+
+// AppendChunkIndex appends the wire encoding of a chunk index to b: a
+// WireHeader followed by the number of chunks and each chunk's compressed and
+// raw lengths, all as uvarints. Offsets are implied by the lengths.
+func AppendChunkIndex(b []byte, chunks []Chunk) []byte {
+	b = WireHeader{Version: chunkIndexVersion}.AppendTo(b)
+	b = binary.AppendUvarint(b, uint64(len(chunks)))
+	for _, c := range chunks {
+		b = binary.AppendUvarint(b, uint64(c.Length))
+		b = binary.AppendUvarint(b, uint64(c.RawLength))
+	}
+	return b
+}
+
+// ParseChunkIndex decodes a chunk index written by AppendChunkIndex. As
+// CompressChunks makes them, every chunk but the last must have the raw
+// length of the first, and the last no more, so that the index describes an
+// object of at most the number of chunks times the chunk size; and no chunk
+// may be longer than DEFLATE can expand its compressed length to.
+func ParseChunkIndex(b []byte) ([]Chunk, error) {
+	_, b, err := ParseWireHeader(b, chunkIndexVersion)
+	if err != nil {
+		return nil, err
+	}
+	next := func() (int, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > math.MaxInt32 {
+			return 0, ErrShortWireData
+		}
+		b = b[n:]
+		return int(v), nil
+	}
+	count, err := next()
+	if err != nil || count > len(b) {
+		return nil, ErrShortWireData
+	}
+	chunks := make([]Chunk, count)
+	offset := 0
+	for i := range chunks {
+		if chunks[i].Length, err = next(); err != nil {
+			return nil, err
+		}
+		if chunks[i].RawLength, err = next(); err != nil {
+			return nil, err
+		}
+		chunks[i].Offset = offset
+		offset += chunks[i].Length
+	}
+	for i, c := range chunks {
+		if c.RawLength > chunks[0].RawLength || i < len(chunks)-1 && c.RawLength != chunks[0].RawLength ||
+			int64(c.RawLength) > maxDeflateRatio*int64(c.Length) {
+			return nil, fmt.Errorf("fountain: chunk %d of %d raw bytes from %d in an index of %d-byte chunks",
+				i, c.RawLength, c.Length, chunks[0].RawLength)
+		}
+	}
+	return chunks, nil
+}
+
+// sourceRecoverer is implemented by the package's decoders.
+type sourceRecoverer interface {
+	recoverSource() ([]block, []bool)
+}
+
+// recoveredRange returns bytes [from, to) of a message of messageLength bytes
+// being decoded by d, if all the source blocks holding them are recovered.
+func recoveredRange(d Decoder, messageLength, from, to int) ([]byte, bool) {
+	r, ok := d.(sourceRecoverer)
+	if !ok {
+		return nil, false
+	}
+	source, recovered := r.recoverSource()
+	return sourceRange(source, recovered, messageLength, from, to)
+}
+
+// sourceRange returns bytes [from, to) of a message of messageLength bytes
+// split into the source blocks, if all those holding them are recovered.
+func sourceRange(source []block, recovered []bool, messageLength, from, to int) ([]byte, bool) {
+	p := sourcePartition(messageLength, len(source))
+	out := make([]byte, to-from)
+	for i := 0; i < p.Pieces(); i++ {
+		start, end := p.Offset(i), p.Offset(i+1)
+		if end <= from || start >= to {
+			continue
+		}
+		if !recovered[i] {
+			return nil, false
+		}
+		lo, hi := max(start, from), min(end, to)
+		data := source[i].data
+		if len(data) > hi-start {
+			data = data[:hi-start]
+		}
+		if len(data) > lo-start {
+			copy(out[lo-from:], data[lo-start:])
+		}
+	}
+	return out, true
+}
+
+// ChunkedDecoder decodes a message produced by CompressChunks, and
+// decompresses each chunk as soon as the source blocks holding it are
+// recovered.
+type ChunkedDecoder struct {
+	decoder       Decoder
+	messageLength int
+	chunks        []Chunk
+	done          []bool
+}
+
+// NewChunkedDecoder creates a decoder for a message encoded with the codec c,
+// whose chunk index is chunks.
+func NewChunkedDecoder(c Codec, chunks []Chunk) *ChunkedDecoder {
+	length := 0
+	for _, ch := range chunks {
+		length += ch.Length
+	}
+	return &ChunkedDecoder{
+		decoder:       c.NewDecoder(length),
+		messageLength: length,
+		chunks:        chunks,
+		done:          make([]bool, len(chunks)),
+	}
+}
+
+// AddBlocks adds code blocks to the decoder, and returns the indices of the
+// chunks which have become available because of them.
+func (d *ChunkedDecoder) AddBlocks(blocks []LTBlock) []int {
+	d.decoder.AddBlocks(blocks)
+	r, ok := d.decoder.(sourceRecoverer)
+	if !ok {
+		return nil
+	}
+	// Recover the source blocks once for all the chunks.
+	source, recovered := r.recoverSource()
+	var ready []int
+	for i, c := range d.chunks {
+		if d.done[i] {
+			continue
+		}
+		if _, ok := sourceRange(source, recovered, d.messageLength, c.Offset, c.Offset+c.Length); ok {
+			d.done[i] = true
+			ready = append(ready, i)
+		}
+	}
+	return ready
+}
+
+// Chunk decompresses and returns the i'th chunk of the object. Returns an
+// error if the chunk's source blocks are not yet recovered.
+func (d *ChunkedDecoder) Chunk(i int) ([]byte, error) {
+	c := d.chunks[i]
+	compressed, ok := recoveredRange(d.decoder, d.messageLength, c.Offset, c.Offset+c.Length)
+	if !ok {
+		return nil, errNotDetermined
+	}
+	raw := make([]byte, c.RawLength)
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close()
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("fountain: decompressing chunk %d: %v", i, err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"compress/flate"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkIndex(t *testing.T) {
+	chunks := []Chunk{{0, 10, 1000}, {10, 300, 1000}, {310, 1, 0}}
+	got, err := ParseChunkIndex(AppendChunkIndex(nil, chunks))
+	if err != nil {
+		t.Fatalf("ParseChunkIndex failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, chunks) {
+		t.Errorf("Round trip of chunk index gave %v, want %v", got, chunks)
+	}
+	if _, err := ParseChunkIndex(AppendChunkIndex(nil, chunks)[:5]); err == nil {
+		t.Errorf("ParseChunkIndex accepted a truncated index")
+	}
+	for _, bad := range [][]Chunk{
+		{{0, 10, 100}, {10, 300, 1000}},
+		{{0, 10, 1000}, {10, 300, 999}, {310, 1, 0}},
+		{{0, 1, 1 << 30}},
+	} {
+		if _, err := ParseChunkIndex(AppendChunkIndex(nil, bad)); err == nil {
+			t.Errorf("ParseChunkIndex accepted %v", bad)
+		}
+	}
+}
+
+func TestChunkedDecoder(t *testing.T) {
+	object := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 100))
+	message, chunks, err := CompressChunks(object, 500, flate.BestCompression)
+	if err != nil {
+		t.Fatalf("CompressChunks failed: %v", err)
+	}
+	if len(chunks) != 9 {
+		t.Fatalf("Got %d chunks, want 9", len(chunks))
+	}
+
+	// Degree-one code blocks recover source blocks one at a time.
+	codec := NewLubyCodec(30, rand.New(NewMersenneTwister(200)), []float64{0, 1})
+	decoder := NewChunkedDecoder(codec, chunks)
+	var ready []int
+	for id := int64(0); len(ready) < len(chunks) && id < 1000; id++ {
+		block := EncodeLTBlocks(append([]byte(nil), message...), []int64{id}, codec)
+		newly := decoder.AddBlocks(block)
+		if len(newly) > 0 && len(ready) == 0 && decoder.decoder.AddBlocks(nil) {
+			t.Errorf("No chunk was available before the message was determined")
+		}
+		ready = append(ready, newly...)
+	}
+	if len(ready) != len(chunks) {
+		t.Fatalf("Only chunks %v became available", ready)
+	}
+
+	var got []byte
+	for i := range chunks {
+		chunk, err := decoder.Chunk(i)
+		if err != nil {
+			t.Fatalf("Chunk(%d) failed: %v", i, err)
+		}
+		got = append(got, chunk...)
+	}
+	if !bytes.Equal(got, object) {
+		t.Errorf("Decompressed chunks don't match the object")
+	}
+}
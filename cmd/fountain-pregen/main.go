@@ -0,0 +1,71 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command fountain-pregen pre-generates repair symbols for a content library,
+// writing a container file for each file in it, so that edge servers can
+// serve loss-protected downloads without encoding on the fly.
+//
+// Usage:
+//
+//	fountain-pregen [flags] library
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	fountain "github.com/google/gofountain"
+)
+
+func main() {
+	opts := fountain.DefaultPregenOptions()
+	flag.StringVar(&opts.OutDir, "out", "", "directory to write containers to (default: next to each file)")
+	flag.Float64Var(&opts.RepairPercent, "repair", opts.RepairPercent, "repair symbols per source block, as a percentage of its source symbols")
+	flag.BoolVar(&opts.Systematic, "systematic", opts.Systematic, "include the source symbols in the containers")
+	flag.IntVar(&opts.PayloadSize, "payload", opts.PayloadSize, "largest packet payload in bytes (P)")
+	flag.IntVar(&opts.Alignment, "align", opts.Alignment, "symbol alignment in bytes (Al)")
+	flag.IntVar(&opts.MaxSubBlock, "subblock", opts.MaxSubBlock, "largest sub-block a receiver decodes in memory, in bytes (W)")
+	flag.IntVar(&opts.MinSymbols, "kmin", opts.MinSymbols, "least number of symbols per source block targeted (Kmin)")
+	flag.IntVar(&opts.MaxSymbolsPerPacket, "gmax", opts.MaxSymbolsPerPacket, "most symbols per packet (Gmax)")
+	flag.IntVar(&opts.Workers, "workers", 0, "files encoded concurrently (default: GOMAXPROCS)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] library\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	results, err := fountain.PregenerateRepair(flag.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.Path, r.Err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: Z=%d K=%d T=%d, %d repair symbols per block\n",
+			r.Container, r.Params.SourceBlocks, r.Params.SourceBlockSymbols(0), r.Params.SymbolSize, r.Repair)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+// Until a decoder's message is read, its matrix holds the full coefficient
+// slice of every equation, and the data of every code block it was given,
+// including the buffers those grew into during reduction. Receivers holding
+// many completed-but-unread decoders pay for all of it. So once a decoder is
+// determined, its matrix is compacted: the rows are solved right away, which
+// leaves each with a single coefficient, and the solved values are packed into
+// one exactly-sized buffer. Decode then has nothing left to do but copy out the
+// message.
+
+// setComplete records whether the decoder using the matrix is determined,
+// compacting the matrix when it first becomes so. Returns determined.
+func (m *sparseMatrix) setComplete(determined bool) bool {
+	if determined && !m.complete {
+		m.compact()
+	}
+	m.complete = determined
+	return determined
+}
+
+// compact solves the solvable rows of the matrix and repacks its storage.
+// Rows which can't be solved (the auxiliary rows some codecs needn't recover)
+// are repacked as they are.
+func (m *sparseMatrix) compact() {
+	m.reduce()
+	m.audit.release()
+	defer m.releaseShared()
+
+	numCoeffs, numBytes := 0, 0
+	for i := range m.coeff {
+		numCoeffs += len(m.coeff[i])
+		numBytes += len(m.v[i].data)
+	}
+	coeffs := make([]int, 0, numCoeffs)
+	data := make([]byte, 0, numBytes)
+	for i := range m.coeff {
+		if len(m.coeff[i]) > 0 {
+			start := len(coeffs)
+			coeffs = append(coeffs, m.coeff[i]...)
+			m.coeff[i] = coeffs[start:len(coeffs):len(coeffs)]
+		}
+		if len(m.v[i].data) > 0 {
+			start := len(data)
+			data = append(data, m.v[i].data...)
+			m.v[i].data = data[start:len(data):len(data)]
+			m.v[i].shared = false
+		}
+	}
+}
+
+// Compact solves and repacks the matrix of a decoder created by one of the
+// package's codecs, freeing the storage of the code blocks it was given (but
+// not, of course, the caller's own copies) and releasing the SharedSymbols it
+// holds. Decoders do this themselves once
+// they are determined; Compact can also be called on an undetermined decoder,
+// to repack what it has solved so far. Returns false if the decoder is of an
+// unknown type.
+func Compact(d Decoder) bool {
+	m := decoderMatrix(d)
+	if m == nil {
+		return false
+	}
+	m.compact()
+	return true
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompactOnDetermination(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	codecs := testCodecs()
+	for _, c := range codecs {
+		d := c.NewDecoder(len(message))
+		var given []LTBlock
+		for id := int64(0); id < 500; id++ {
+			b := EncodeLTBlocksCopy(message, []int64{id}, c)
+			given = append(given, b...)
+			if d.AddBlocks(b) {
+				break
+			}
+		}
+
+		m := decoderMatrix(d)
+		ok := m.solvable()
+		for i, row := range m.coeff {
+			if ok[i] && len(row) != 1 {
+				t.Errorf("%T: row %d has coefficients %v after determination", c, i, row)
+			}
+		}
+
+		// The decoder no longer refers to the code blocks it was given.
+		for _, b := range given {
+			for i := range b.Data {
+				b.Data[i] = 0xff
+			}
+		}
+		if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+			t.Errorf("%T: decoded %q, want %q", c, decoded, message)
+		}
+	}
+
+	if Compact(NewQuarantineDecoder(NewBinaryCodec(10).NewDecoder(10), nil, 1)) {
+		t.Errorf("Compact succeeded on an unknown decoder")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	c := NewBinaryCodec(10)
+	d := c.NewDecoder(len(message))
+	d.AddBlocks(EncodeLTBlocksCopy(message, []int64{1, 2, 3, 4, 5}, c))
+	if !Compact(d) {
+		t.Fatalf("Compact failed")
+	}
+	for id := int64(6); !d.AddBlocks(EncodeLTBlocksCopy(message, []int64{id}, c)); id++ {
+	}
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("decoded %q, want %q", decoded, message)
+	}
+}
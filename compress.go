@@ -0,0 +1,171 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The overhead of a fountain transfer, in symbols sent and in decoding work,
+// grows with the size of what is transferred, so compressible objects are
+// better compressed before they are partitioned into source blocks. An
+// ObjectEncoder created with WithCompression does so, and records the
+// compression in the object's parameters, which an ObjectDecoder undoes after
+// decoding. Compressions are identified by a byte on the wire, and
+// applications can register their own alongside the ones built in, in the way
+// of archive/zip.
+
+// Compression identifies how an object was compressed before encoding.
+// Values from 128 up are left for applications to register.
+type Compression uint8
+
+// Built-in compressions.
+const (
+	// CompressionNone leaves the object as it is.
+	CompressionNone Compression = 0
+
+	// CompressionDeflate compresses the object with DEFLATE (RFC 1951), at
+	// the default level.
+	CompressionDeflate Compression = 1
+)
+
+// Compressor returns a writer which compresses what is written to it into w.
+// Closing it flushes the compressed data, without closing w.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// Decompressor returns a reader which decompresses the data read from r.
+type Decompressor func(r io.Reader) io.ReadCloser
+
+// compression is a registered compression.
+type compression struct {
+	compress   Compressor
+	decompress Decompressor
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[Compression]compression{
+		CompressionDeflate: {
+			compress: func(w io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(w, flate.DefaultCompression)
+			},
+			decompress: flate.NewReader,
+		},
+	}
+)
+
+// RegisterCompression registers a compression under the given identifier,
+// which senders and receivers must agree on. It panics if the identifier is
+// CompressionNone or already registered.
+func RegisterCompression(c Compression, comp Compressor, decomp Decompressor) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	if _, ok := compressions[c]; ok || c == CompressionNone {
+		panic(fmt.Sprintf("fountain: compression %d already registered", c))
+	}
+	compressions[c] = compression{compress: comp, decompress: decomp}
+}
+
+// lookupCompression returns the registered compression c.
+func lookupCompression(c Compression) (compression, bool) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	comp, ok := compressions[c]
+	return comp, ok
+}
+
+// compressObject compresses object with c.
+func compressObject(object []byte, c Compression) ([]byte, error) {
+	comp, ok := lookupCompression(c)
+	if !ok {
+		return nil, fmt.Errorf("fountain: unknown compression %d", c)
+	}
+	var buf bytes.Buffer
+	w, err := comp.compress(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(object); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressObject decompresses an object of length bytes compressed with c.
+// It reads no more than a byte past length, so that a small compressed object
+// can't expand to exhaust the receiver's memory, and returns an error if the
+// object doesn't have that length.
+func decompressObject(compressed []byte, c Compression, length int64) ([]byte, error) {
+	comp, ok := lookupCompression(c)
+	if !ok {
+		return nil, fmt.Errorf("fountain: unknown compression %d", c)
+	}
+	r := comp.decompress(bytes.NewReader(compressed))
+	defer r.Close()
+	object, err := io.ReadAll(io.LimitReader(r, length+1))
+	if err != nil {
+		return nil, fmt.Errorf("fountain: decompressing object: %v", err)
+	}
+	if int64(len(object)) != length {
+		return nil, fmt.Errorf("fountain: object decompressed to %d bytes or more, want %d", len(object), length)
+	}
+	return object, nil
+}
+
+// ObjectEncoderOption configures an ObjectEncoder.
+type ObjectEncoderOption func(*objectEncoderConfig)
+
+// objectEncoderConfig is the description an ObjectEncoder is built from.
+type objectEncoderConfig struct {
+	compression Compression
+}
+
+// WithCompression compresses the object with c before it is partitioned. The
+// parameters the encoder is given then describe the object as it is, and the
+// encoder's Params describe the compressed object: its transfer length, the
+// compression, the length of the object as it is, and if the compressed
+// object has too few symbols for them, fewer source blocks. The object is sent
+// as it is if compressing it doesn't save at least a symbol, or leaves too few
+// symbols to encode.
+func WithCompression(c Compression) ObjectEncoderOption {
+	return func(config *objectEncoderConfig) { config.compression = c }
+}
+
+// compressedParams returns the parameters of an object of compressedLength
+// bytes, compressed with c, which was to be partitioned with p. Returns false
+// if compression doesn't pay off.
+func (p ObjectParams) compressedParams(compressedLength int64, c Compression) (ObjectParams, bool) {
+	q := p
+	q.TransferLength = compressedLength
+	q.Compression = c
+	q.ContentLength = p.TransferLength
+	if q.symbols() >= p.symbols() {
+		return p, false
+	}
+	// Keep the source blocks, unless some would have fewer symbols than the
+	// raptor code allows.
+	q.SourceBlocks = min(p.SourceBlocks, q.symbols()/MinRaptorSourceSymbols)
+	if q.SourceBlocks == 0 || q.Validate() != nil {
+		return p, false
+	}
+	return q, true
+}
@@ -0,0 +1,165 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// decodeObject decodes an object from all the source symbols of an encoder.
+func decodeObject(t *testing.T, e *ObjectEncoder) ([]byte, error) {
+	t.Helper()
+	p := e.Params()
+	d, err := NewObjectDecoder(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sbn := 0; sbn < p.SourceBlocks; sbn++ {
+		for esi := int64(0); esi < int64(p.SourceBlockSymbols(sbn)); esi++ {
+			s, _ := e.Symbol(sbn, esi)
+			d.AddSymbols([]ObjectSymbol{s})
+		}
+	}
+	return d.Object()
+}
+
+func TestCompressedObject(t *testing.T) {
+	// Text of four letters compresses to about a quarter of its size.
+	object := make([]byte, 100000)
+	random := rand.New(rand.NewSource(1))
+	for i := range object {
+		object[i] = 'a' + byte(random.Intn(4))
+	}
+	p, err := DeriveObjectParams(int64(len(object)), 4, 256, 1024, 32, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SourceBlocks = 4
+	e, err := NewObjectEncoder(object, p, WithCompression(CompressionDeflate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := e.Params()
+	if q.Compression != CompressionDeflate || q.TransferLength >= p.TransferLength || q.SymbolSize != p.SymbolSize ||
+		q.ContentLength != p.TransferLength {
+		t.Errorf("Params = %+v, want a smaller deflated object of %d-byte symbols", q, p.SymbolSize)
+	}
+	if q.SourceBlocks > p.SourceBlocks || q.Validate() != nil {
+		t.Errorf("Params have %d source blocks, want at most %d and valid", q.SourceBlocks, p.SourceBlocks)
+	}
+	got, err := decodeObject(t, e)
+	if err != nil || !bytes.Equal(got, object) {
+		t.Errorf("Object = %d bytes, %v; want the object of %d bytes", len(got), err, len(object))
+	}
+
+	// Random data doesn't compress, so it is sent as it is.
+	random.Read(object)
+	e, err = NewObjectEncoder(object, p, WithCompression(CompressionDeflate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Params() != p {
+		t.Errorf("Params of an incompressible object = %+v, want %+v", e.Params(), p)
+	}
+	if got, err := decodeObject(t, e); err != nil || !bytes.Equal(got, object) {
+		t.Errorf("Object = %d bytes, %v; want the object", len(got), err)
+	}
+
+	if _, err := NewObjectEncoder(object, p, WithCompression(200)); err == nil {
+		t.Error("NewObjectEncoder accepted an unknown compression")
+	}
+	p.MerkleRoot[0] = 1
+	if _, err := NewObjectEncoder(object, p, WithCompression(CompressionDeflate)); err == nil {
+		t.Error("NewObjectEncoder accepted a Merkle root with compression")
+	}
+	p.MerkleRoot[0] = 0
+	p.Compression = 201
+	if _, err := NewObjectDecoder(p); err == nil {
+		t.Error("NewObjectDecoder accepted an unknown compression")
+	}
+	p.Compression = CompressionDeflate
+	if _, err := NewObjectDecoder(p); err == nil {
+		t.Error("NewObjectDecoder accepted a compression without a content length")
+	}
+}
+
+func TestDecompressObjectLength(t *testing.T) {
+	// A megabyte of zeros deflates to about a kilobyte.
+	compressed, err := compressObject(make([]byte, 1<<20), CompressionDeflate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decompressObject(compressed, CompressionDeflate, 1<<20); err != nil || len(got) != 1<<20 {
+		t.Errorf("decompressObject = %d bytes, %v; want a megabyte", len(got), err)
+	}
+	for _, length := range []int64{1000, 1<<20 + 1} {
+		if got, err := decompressObject(compressed, CompressionDeflate, length); err == nil {
+			t.Errorf("decompressObject to %d bytes = %d bytes, want an error", length, len(got))
+		}
+	}
+}
+
+// trimmer is the writer of a toy compression, which drops the first four
+// bytes of an object, taken to be zeros.
+type trimmer struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (tr *trimmer) Write(b []byte) (int, error) {
+	tr.buf = append(tr.buf, b...)
+	return len(b), nil
+}
+
+func (tr *trimmer) Close() error {
+	_, err := tr.w.Write(tr.buf[4:])
+	return err
+}
+
+func TestRegisterCompression(t *testing.T) {
+	const trim Compression = 250
+	RegisterCompression(trim,
+		func(w io.Writer) (io.WriteCloser, error) { return &trimmer{w: w}, nil },
+		func(r io.Reader) io.ReadCloser {
+			return io.NopCloser(io.MultiReader(bytes.NewReader(make([]byte, 4)), r))
+		})
+	object := make([]byte, 400)
+	rand.New(rand.NewSource(2)).Read(object[4:])
+	p := ObjectParams{TransferLength: 400, SymbolSize: 4, Alignment: 4, SourceBlocks: 1, SubBlocks: 1, SymbolsPerPacket: 1}
+	e, err := NewObjectEncoder(object, p, WithCompression(trim))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := e.Params(); q.Compression != trim || q.TransferLength != 396 {
+		t.Errorf("Params = %+v, want 396 bytes compressed with %d", q, trim)
+	}
+	if got, err := decodeObject(t, e); err != nil || !bytes.Equal(got, object) {
+		t.Errorf("Object = %x, %v; want %x", got, err, object)
+	}
+
+	for _, c := range []Compression{CompressionNone, CompressionDeflate, trim} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterCompression(%d) didn't panic", c)
+				}
+			}()
+			RegisterCompression(c, nil, nil)
+		}()
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A container file (conventionally named with a .fount extension) holds
+// encoding symbols of an object generated ahead of time, along with what a
+// receiver needs to decode them: the object's FEC Object Transmission
+// Information, its digest and free-form metadata. A server can then serve
+// source and repair symbols from the file without keeping the original object
+// online.
+//
+// The file starts with an 8-byte magic string and a WireHeader. A
+// length-prefixed header follows: the ObjectParams as uvarints, in field
+// order up to SymbolsPerPacket, then from version 2 the compression and the
+// content length, then in version 3 the Merkle root, then the SHA-256 digest of the object, and the metadata as a count of
+// entries followed by each key and value, length-prefixed and sorted by key.
+// The rest of the file is a sequence of symbol records, each a uvarint length
+// followed by the symbol's SBN as a uvarint and its LTBlock wire encoding.
+
+// containerMagic starts every container file. Like PNG's, it includes a high
+// byte and line endings so that mangled transfers are detected.
+const containerMagic = "\x89fount\r\n"
+
+// containerVersion is the format version of a container file. Containers are
+// written with the oldest version which can describe the object: version 1
+// lacks the compression and the content length, and version 2 the Merkle root.
+const containerVersion = 3
+
+// version returns the format version of a container with the header.
+func (h ContainerHeader) version() uint8 {
+	switch {
+	case h.Params.MerkleRoot != [sha256.Size]byte{}:
+		return 3
+	case h.Params.Compression != CompressionNone:
+		return 2
+	}
+	return 1
+}
+
+// ErrContainerFormat is returned for malformed container files.
+var ErrContainerFormat = errors.New("fountain: malformed container file")
+
+// ContainerHeader describes the object whose symbols a container holds.
+type ContainerHeader struct {
+	// Params are the object's encoding parameters.
+	Params ObjectParams
+
+	// Digest is the SHA-256 digest of the object, before any compression.
+	Digest [sha256.Size]byte
+
+	// Metadata holds free-form information about the object, such as its name
+	// or content type.
+	Metadata map[string]string
+}
+
+// Verify checks a decoded object against the header's digest. Returns
+// ErrIntegrity if it doesn't match.
+func (h ContainerHeader) Verify(object []byte) error {
+	length := h.Params.TransferLength
+	if h.Params.Compression != CompressionNone {
+		length = h.Params.ContentLength
+	}
+	if int64(len(object)) != length || sha256.Sum256(object) != h.Digest {
+		return ErrIntegrity
+	}
+	return nil
+}
+
+// appendTo appends the encoded header, without its length prefix, to b.
+func (h ContainerHeader) appendTo(b []byte) []byte {
+	p := h.Params
+	for _, v := range []int64{p.TransferLength, int64(p.SymbolSize), int64(p.Alignment),
+		int64(p.SourceBlocks), int64(p.SubBlocks), int64(p.SymbolsPerPacket)} {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	version := h.version()
+	if version >= 2 {
+		b = binary.AppendUvarint(b, uint64(p.Compression))
+		b = binary.AppendUvarint(b, uint64(p.ContentLength))
+	}
+	if version >= 3 {
+		b = append(b, p.MerkleRoot[:]...)
+	}
+	b = append(b, h.Digest[:]...)
+	keys := make([]string, 0, len(h.Metadata))
+	for k := range h.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b = binary.AppendUvarint(b, uint64(len(keys)))
+	for _, k := range keys {
+		b = binary.AppendUvarint(b, uint64(len(k)))
+		b = append(b, k...)
+		b = binary.AppendUvarint(b, uint64(len(h.Metadata[k])))
+		b = append(b, h.Metadata[k]...)
+	}
+	return b
+}
+
+// parseContainerHeader decodes a header written by appendTo, of a container of
+// the given version.
+func parseContainerHeader(b []byte, version uint8) (ContainerHeader, error) {
+	next := func() (uint64, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > 1<<62 {
+			return 0, ErrContainerFormat
+		}
+		b = b[n:]
+		return v, nil
+	}
+	var fields [6]int64
+	for i := range fields {
+		v, err := next()
+		if err != nil {
+			return ContainerHeader{}, err
+		}
+		fields[i] = int64(v)
+	}
+	h := ContainerHeader{Params: ObjectParams{
+		TransferLength:   fields[0],
+		SymbolSize:       int(fields[1]),
+		Alignment:        int(fields[2]),
+		SourceBlocks:     int(fields[3]),
+		SubBlocks:        int(fields[4]),
+		SymbolsPerPacket: int(fields[5]),
+	}}
+	if version >= 2 {
+		c, err := next()
+		if err != nil || c > 0xff {
+			return ContainerHeader{}, ErrContainerFormat
+		}
+		h.Params.Compression = Compression(c)
+		length, err := next()
+		if err != nil {
+			return ContainerHeader{}, err
+		}
+		h.Params.ContentLength = int64(length)
+	}
+	if version >= 3 {
+		if len(b) < sha256.Size {
+			return ContainerHeader{}, ErrContainerFormat
+		}
+		b = b[copy(h.Params.MerkleRoot[:], b):]
+	}
+	// Containers are written with the oldest version which fits.
+	if h.version() != version {
+		return ContainerHeader{}, ErrContainerFormat
+	}
+	if err := h.Params.Validate(); err != nil {
+		return ContainerHeader{}, fmt.Errorf("%w: %v", ErrContainerFormat, err)
+	}
+	if len(b) < sha256.Size {
+		return ContainerHeader{}, ErrContainerFormat
+	}
+	b = b[copy(h.Digest[:], b):]
+	count, err := next()
+	if err != nil || count > uint64(len(b)) {
+		return ContainerHeader{}, ErrContainerFormat
+	}
+	h.Metadata = make(map[string]string, count)
+	str := func() (string, error) {
+		n, err := next()
+		if err != nil || n > uint64(len(b)) {
+			return "", ErrContainerFormat
+		}
+		s := string(b[:n])
+		b = b[n:]
+		return s, nil
+	}
+	for i := uint64(0); i < count; i++ {
+		k, err := str()
+		if err != nil {
+			return ContainerHeader{}, err
+		}
+		if h.Metadata[k], err = str(); err != nil {
+			return ContainerHeader{}, err
+		}
+	}
+	if len(b) != 0 {
+		return ContainerHeader{}, ErrContainerFormat
+	}
+	return h, nil
+}
+
+// ContainerWriter writes a container file.
+type ContainerWriter struct {
+	w      io.Writer
+	header ContainerHeader
+}
+
+// NewContainerWriter writes the magic string and header of a container to w.
+// Returns an error if the header's parameters are invalid.
+func NewContainerWriter(w io.Writer, h ContainerHeader) (*ContainerWriter, error) {
+	if err := h.Params.Validate(); err != nil {
+		return nil, err
+	}
+	header := h.appendTo(nil)
+	b := WireHeader{Version: h.version()}.AppendTo([]byte(containerMagic))
+	b = binary.AppendUvarint(b, uint64(len(header)))
+	if _, err := w.Write(append(b, header...)); err != nil {
+		return nil, err
+	}
+	return &ContainerWriter{w: w, header: h}, nil
+}
+
+// WriteSymbol appends an encoding symbol to the container. Returns an error if
+// it isn't a whole symbol of one of the object's source blocks.
+func (cw *ContainerWriter) WriteSymbol(s ObjectSymbol) error {
+	p := cw.header.Params
+	if s.SBN < 0 || s.SBN >= p.SourceBlocks || s.BlockCode < 0 || len(s.Data) != p.SymbolSize {
+		return fmt.Errorf("fountain: symbol %d of source block %d with %d bytes doesn't fit %d source blocks of %d-byte symbols",
+			s.BlockCode, s.SBN, len(s.Data), p.SourceBlocks, p.SymbolSize)
+	}
+	payload := binary.AppendUvarint(nil, uint64(s.SBN))
+	payload, _ = s.LTBlock.AppendBinary(payload)
+	b := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(payload)), uint64(len(payload)))
+	_, err := cw.w.Write(append(b, payload...))
+	return err
+}
+
+// ContainerReader reads a container file.
+type ContainerReader struct {
+	r      *bufio.Reader
+	header ContainerHeader
+}
+
+// NewContainerReader reads the magic string and header of a container from r.
+func NewContainerReader(r io.Reader) (*ContainerReader, error) {
+	br := bufio.NewReader(r)
+	var start [len(containerMagic) + WireHeaderLen]byte
+	if _, err := io.ReadFull(br, start[:]); err != nil || string(start[:len(containerMagic)]) != containerMagic {
+		return nil, ErrContainerFormat
+	}
+	wh, _, err := ParseWireHeader(start[len(containerMagic):], containerVersion)
+	if err != nil {
+		return nil, err
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil || n > 1<<20 {
+		return nil, ErrContainerFormat
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return nil, ErrContainerFormat
+	}
+	h, err := parseContainerHeader(b, wh.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerReader{r: br, header: h}, nil
+}
+
+// Header returns the container's header.
+func (cr *ContainerReader) Header() ContainerHeader {
+	return cr.header
+}
+
+// Next returns the next symbol in the container. Returns io.EOF at the end of
+// the file, and ErrContainerFormat if the file is truncated or malformed.
+func (cr *ContainerReader) Next() (ObjectSymbol, error) {
+	n, err := binary.ReadUvarint(cr.r)
+	if err == io.EOF {
+		return ObjectSymbol{}, io.EOF
+	}
+	// Leave room for the SBN and the LTBlock's header, code and length.
+	if err != nil || n > uint64(cr.header.Params.SymbolSize+WireHeaderLen+3*binary.MaxVarintLen64) {
+		return ObjectSymbol{}, ErrContainerFormat
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(cr.r, b); err != nil {
+		return ObjectSymbol{}, ErrContainerFormat
+	}
+	sbn, m := binary.Uvarint(b)
+	if m <= 0 || sbn >= uint64(cr.header.Params.SourceBlocks) {
+		return ObjectSymbol{}, ErrContainerFormat
+	}
+	block, rest, err := ParseLTBlock(b[m:])
+	if err != nil || len(rest) != 0 || len(block.Data) != cr.header.Params.SymbolSize {
+		return ObjectSymbol{}, ErrContainerFormat
+	}
+	return ObjectSymbol{SBN: int(sbn), LTBlock: block}, nil
+}
+
+// WriteObjectContainer encodes an object with the given parameters and writes
+// a container holding, for each source block, its source symbols if
+// systematic is set, followed by repair symbols (those with ESIs from the
+// block's number of source symbols up) to the given count. The options
+// configure the encoder as in NewObjectEncoder.
+func WriteObjectContainer(w io.Writer, object []byte, p ObjectParams, metadata map[string]string, systematic bool, repair int, opts ...ObjectEncoderOption) error {
+	e, err := NewObjectEncoder(object, p, opts...)
+	if err != nil {
+		return err
+	}
+	p = e.Params()
+	cw, err := NewContainerWriter(w, ContainerHeader{Params: p, Digest: sha256.Sum256(object), Metadata: metadata})
+	if err != nil {
+		return err
+	}
+	for sbn := 0; sbn < p.SourceBlocks; sbn++ {
+		k := int64(p.sourceSymbols(sbn))
+		from := k
+		if systematic {
+			from = 0
+		}
+		for esi := from; esi < k+int64(repair); esi++ {
+			s, err := e.Symbol(sbn, esi)
+			if err != nil {
+				return err
+			}
+			if err := cw.WriteSymbol(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadObjectContainer decodes the object from the symbols of a container,
+// and verifies it against the container's digest. Returns the object and the
+// container's header. Objects longer than DefaultMaxObjectLength aren't
+// decoded (see ReadObjectContainerLimit).
+func ReadObjectContainer(r io.Reader) ([]byte, ContainerHeader, error) {
+	return ReadObjectContainerLimit(r, DefaultMaxObjectLength)
+}
+
+// ReadObjectContainerLimit is like ReadObjectContainer, but returns
+// ErrObjectTooLarge (wrapped), along with the header, if decoding the object
+// takes more than maxLength bytes (see ObjectParams.CheckLength).
+func ReadObjectContainerLimit(r io.Reader, maxLength int64) ([]byte, ContainerHeader, error) {
+	cr, err := NewContainerReader(r)
+	if err != nil {
+		return nil, ContainerHeader{}, err
+	}
+	h := cr.Header()
+	if err := h.Params.CheckLength(maxLength); err != nil {
+		return nil, h, err
+	}
+	d, err := NewObjectDecoder(h.Params)
+	if err != nil {
+		return nil, h, err
+	}
+	for !d.Complete() {
+		s, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, h, err
+		}
+		d.AddSymbols([]ObjectSymbol{s})
+	}
+	object, err := d.Object()
+	if err != nil {
+		return nil, h, err
+	}
+	if err := h.Verify(object); err != nil {
+		return nil, h, err
+	}
+	return object, h, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestObjectContainer(t *testing.T) {
+	random := rand.New(rand.NewSource(4))
+	object := make([]byte, 1000)
+	random.Read(object)
+	p := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 3, SubBlocks: 4, SymbolsPerPacket: 1}
+	metadata := map[string]string{"name": "object.bin", "type": "application/octet-stream"}
+
+	for _, test := range []struct {
+		systematic bool
+		repair     int
+		symbols    int
+	}{
+		{true, 0, 42},
+		{true, 5, 57},
+		{false, 24, 72},
+	} {
+		var file bytes.Buffer
+		if err := WriteObjectContainer(&file, object, p, metadata, test.systematic, test.repair); err != nil {
+			t.Fatalf("WriteObjectContainer(%v, %d) failed: %v", test.systematic, test.repair, err)
+		}
+
+		cr, err := NewContainerReader(bytes.NewReader(file.Bytes()))
+		if err != nil {
+			t.Fatalf("NewContainerReader failed: %v", err)
+		}
+		if file.Bytes()[len(containerMagic)] != 1 {
+			t.Errorf("container of an uncompressed object isn't version 1")
+		}
+		if h := cr.Header(); h.Params != p || !reflect.DeepEqual(h.Metadata, metadata) || h.Verify(object) != nil {
+			t.Errorf("Header() = %+v, want params %+v and metadata %v", h, p, metadata)
+		}
+		n := 0
+		for ; ; n++ {
+			if _, err := cr.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Next failed after %d symbols: %v", n, err)
+			}
+		}
+		if n != test.symbols {
+			t.Errorf("container holds %d symbols, want %d", n, test.symbols)
+		}
+
+		got, _, err := ReadObjectContainer(bytes.NewReader(file.Bytes()))
+		if err != nil {
+			t.Fatalf("ReadObjectContainer(%v, %d) failed: %v", test.systematic, test.repair, err)
+		}
+		if !bytes.Equal(got, object) {
+			t.Errorf("ReadObjectContainer(%v, %d) decoded the wrong object", test.systematic, test.repair)
+		}
+	}
+}
+
+func TestCompressedObjectContainer(t *testing.T) {
+	object := make([]byte, 2000)
+	random := rand.New(rand.NewSource(5))
+	for i := range object {
+		object[i] = 'a' + byte(random.Intn(4))
+	}
+	p := ObjectParams{TransferLength: int64(len(object)), SymbolSize: 16, Alignment: 4, SourceBlocks: 2, SubBlocks: 1, SymbolsPerPacket: 1}
+	var file bytes.Buffer
+	if err := WriteObjectContainer(&file, object, p, nil, true, 2, WithCompression(CompressionDeflate)); err != nil {
+		t.Fatal(err)
+	}
+	if v := file.Bytes()[len(containerMagic)]; v != 2 {
+		t.Errorf("container version %d, want 2", v)
+	}
+	got, h, err := ReadObjectContainer(bytes.NewReader(file.Bytes()))
+	if err != nil || !bytes.Equal(got, object) {
+		t.Fatalf("ReadObjectContainer = %d bytes, %v; want the object", len(got), err)
+	}
+	if h.Params.Compression != CompressionDeflate || h.Params.TransferLength >= p.TransferLength ||
+		h.Params.ContentLength != p.TransferLength {
+		t.Errorf("header params = %+v, want a deflated object", h.Params)
+	}
+}
+
+func TestMerkleObjectContainer(t *testing.T) {
+	object, p, _ := merkleObject(t)
+	var file bytes.Buffer
+	if err := WriteObjectContainer(&file, object, p, nil, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if v := file.Bytes()[len(containerMagic)]; v != 3 {
+		t.Errorf("container version %d, want 3", v)
+	}
+	got, h, err := ReadObjectContainer(bytes.NewReader(file.Bytes()))
+	if err != nil || !bytes.Equal(got, object) || h.Params != p {
+		t.Errorf("ReadObjectContainer = %d bytes, %+v, %v; want the object and %+v", len(got), h.Params, err, p)
+	}
+
+	// A container of the wrong version for its object is malformed.
+	data := bytes.Clone(file.Bytes())
+	data[len(containerMagic)] = 2
+	if _, err := NewContainerReader(bytes.NewReader(data)); err != ErrContainerFormat {
+		t.Errorf("NewContainerReader of a version 2 container with a Merkle root = %v, want ErrContainerFormat", err)
+	}
+}
+
+func TestObjectContainerErrors(t *testing.T) {
+	object := []byte("abcdefghijklmnopqrstuvwxyz0123456789")
+	p := ObjectParams{TransferLength: int64(len(object)), SymbolSize: 4, Alignment: 4, SourceBlocks: 1, SubBlocks: 1, SymbolsPerPacket: 1}
+	var file bytes.Buffer
+	if err := WriteObjectContainer(&file, object, p, nil, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	data := file.Bytes()
+
+	if _, err := NewContainerReader(bytes.NewReader(data[1:])); err != ErrContainerFormat {
+		t.Errorf("NewContainerReader without magic = %v, want ErrContainerFormat", err)
+	}
+	cr, _ := NewContainerReader(bytes.NewReader(data[:len(data)-1]))
+	var err error
+	for err == nil {
+		_, err = cr.Next()
+	}
+	if err != ErrContainerFormat {
+		t.Errorf("Next at the end of a truncated container = %v, want ErrContainerFormat", err)
+	}
+
+	// Corrupt the data of the last symbol: the object decodes, but doesn't
+	// match the digest.
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 1
+	if _, _, err := ReadObjectContainer(bytes.NewReader(corrupt)); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("ReadObjectContainer of a corrupt container = %v, want ErrIntegrity", err)
+	}
+
+	// Too few symbols.
+	if _, _, err := ReadObjectContainer(bytes.NewReader(data[:len(data)-10])); err == nil {
+		t.Errorf("ReadObjectContainer succeeded with symbols missing")
+	}
+
+	// An object larger than the reader accepts isn't decoded.
+	if _, h, err := ReadObjectContainerLimit(bytes.NewReader(data), 35); !errors.Is(err, ErrObjectTooLarge) || h.Params != p {
+		t.Errorf("ReadObjectContainerLimit(35) = %+v, %v; want the header and ErrObjectTooLarge", h, err)
+	}
+
+	cw, _ := NewContainerWriter(io.Discard, ContainerHeader{Params: p})
+	if err := cw.WriteSymbol(ObjectSymbol{SBN: 1, LTBlock: LTBlock{Data: make([]byte, 4)}}); err == nil {
+		t.Errorf("WriteSymbol accepted a symbol of a missing source block")
+	}
+	if err := cw.WriteSymbol(ObjectSymbol{LTBlock: LTBlock{Data: make([]byte, 3)}}); err == nil {
+		t.Errorf("WriteSymbol accepted a short symbol")
+	}
+	if _, err := NewContainerWriter(io.Discard, ContainerHeader{}); err == nil {
+		t.Errorf("NewContainerWriter accepted invalid params")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"context"
+	"fmt"
+)
+
+// For K in the thousands, solving for the raptor codec's intermediate blocks
+// and reducing a decode matrix each take long enough that callers may want to
+// give up on them. The Context variants here check their context every
+// contextCheckInterval rows or code blocks, and return its error if it is
+// done. A decoder reduces its matrix when the code blocks it is given first
+// determine it, and again when it is decoded. The matrix is left consistent
+// when a reduction is abandoned, so the decoder can still be given more code
+// blocks, and decoded later.
+
+// contextCheckInterval is the number of rows or code blocks processed between
+// checks of a context.
+const contextCheckInterval = 64
+
+// contextIntermediateGenerator is implemented by codecs whose intermediate
+// block generation is slow enough to be worth cancelling.
+type contextIntermediateGenerator interface {
+	generateIntermediateBlocksContext(ctx context.Context, message []byte, numBlocks int) ([]block, error)
+}
+
+// EncodeLTBlocksContext is like EncodeLTBlocksCopy, but gives up, returning
+// ctx.Err(), if ctx is done before the code blocks have been generated.
+func EncodeLTBlocksContext(ctx context.Context, message []byte, encodedBlockIDs []int64, c Codec) ([]LTBlock, error) {
+	e, err := newLTEncoderContext(ctx, c, message)
+	if err != nil {
+		return nil, err
+	}
+	ltBlocks := make([]LTBlock, len(encodedBlockIDs))
+	for i := range encodedBlockIDs {
+		if i%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		ltBlocks[i] = e.Generate(encodedBlockIDs[i])
+	}
+	return ltBlocks, nil
+}
+
+// NewEncoderContext is like c.NewEncoder(message), but gives up, returning
+// ctx.Err(), if ctx is done before the codec has generated the message's
+// intermediate blocks. The message is left untouched.
+func NewEncoderContext(ctx context.Context, c Codec, message []byte) (Encoder, error) {
+	return newLTEncoderContext(ctx, c, message)
+}
+
+// newLTEncoderContext creates an encoder for the message, which is left
+// untouched, unless ctx is done first.
+func newLTEncoderContext(ctx context.Context, c Codec, message []byte) (*ltEncoder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	g, ok := c.(contextIntermediateGenerator)
+	if !ok {
+		return newLTEncoder(c, message), nil
+	}
+	if e, ok := c.(inPlaceEncoder); ok && e.encodesInPlace() {
+		message = append([]byte(nil), message...)
+	}
+	source, err := g.generateIntermediateBlocksContext(ctx, message, c.SourceBlocks())
+	if err != nil {
+		return nil, err
+	}
+	compactZeroBlocks(source)
+	return &ltEncoder{codec: c, source: source}, nil
+}
+
+// AddBlocksContext is like d.AddBlocks(blocks), but gives up if ctx is done
+// first, returning its error along with whether the blocks added so far
+// determine the decoder. The blocks not yet added are dropped, as if they had
+// been lost. d must be a decoder created by one of the package's codecs. A
+// determined decoder whose reduction was abandoned can still be decoded,
+// which finishes the reduction.
+func AddBlocksContext(ctx context.Context, d Decoder, blocks []LTBlock) (bool, error) {
+	m := decoderMatrix(d)
+	if m == nil {
+		return false, fmt.Errorf("fountain: AddBlocksContext doesn't support %T", d)
+	}
+	determined := false
+	for len(blocks) > 0 {
+		if err := ctx.Err(); err != nil {
+			return determined, err
+		}
+		n := min(len(blocks), contextCheckInterval)
+		if err := m.withContext(ctx, func() { determined = d.AddBlocks(blocks[:n]) }); err != nil {
+			return determined, err
+		}
+		blocks = blocks[n:]
+	}
+	return determined, nil
+}
+
+// DecodeContext is like TryDecode(d), but gives up, returning ctx.Err(), if ctx
+// is done before the decode matrix has been reduced. d must be a decoder
+// created by one of the package's codecs.
+func DecodeContext(ctx context.Context, d Decoder) ([]byte, error) {
+	m := decoderMatrix(d)
+	if m == nil {
+		return nil, fmt.Errorf("fountain: DecodeContext doesn't support %T", d)
+	}
+	if err := m.reduceContext(ctx); err != nil {
+		return nil, err
+	}
+	// The matrix is reduced, so decoding only reconstructs the message.
+	return TryDecode(d)
+}
+
+// reduceContext is like reduce, but abandons the reduction if ctx is done,
+// returning its error.
+func (m *sparseMatrix) reduceContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.withContext(ctx, m.reduce)
+}
+
+// withContext calls f, abandoning any reduction of the matrix it makes if
+// ctx is done, and returns ctx's error if so.
+func (m *sparseMatrix) withContext(ctx context.Context, f func()) error {
+	m.ctx, m.ctxErr = ctx, nil
+	defer func() { m.ctx = nil }()
+	f()
+	return m.ctxErr
+}
+
+// cancelled reports, every contextCheckInterval calls with successive step
+// numbers, whether the context of a reduceContext call is done, recording its
+// error if so.
+func (m *sparseMatrix) cancelled(step int) bool {
+	if m.ctx == nil || step%contextCheckInterval != 0 {
+		return false
+	}
+	m.ctxErr = m.ctx.Err()
+	return m.ctxErr != nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// countdownContext is a context which is cancelled once Err has been called
+// n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestEncodeLTBlocksContext(t *testing.T) {
+	message := make([]byte, 8000)
+	for i := range message {
+		message[i] = byte(i * 11)
+	}
+	c := NewRaptorCodec(1000, 4)
+	ids := []int64{0, 1, 1000, 5000}
+
+	want := EncodeLTBlocksCopy(message, ids, c)
+	got, err := EncodeLTBlocksContext(context.Background(), message, ids, c)
+	if err != nil {
+		t.Fatalf("EncodeLTBlocksContext failed: %v", err)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Errorf("Block %d differs from EncodeLTBlocksCopy's", ids[i])
+		}
+	}
+
+	// Cancel partway through the intermediate block generation.
+	ctx := &countdownContext{Context: context.Background(), n: 5}
+	if _, err := EncodeLTBlocksContext(ctx, message, ids, c); !errors.Is(err, context.Canceled) {
+		t.Errorf("EncodeLTBlocksContext with a cancelled context returned %v, want context.Canceled", err)
+	}
+	ctx = &countdownContext{Context: context.Background(), n: 20}
+	if _, err := NewEncoderContext(ctx, c, message); !errors.Is(err, context.Canceled) {
+		t.Errorf("NewEncoderContext with a cancelled context returned %v, want context.Canceled", err)
+	}
+	if message[0] != 0 || message[1] != 11 {
+		t.Errorf("EncodeLTBlocksContext modified the message")
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	message := make([]byte, 8000)
+	for i := range message {
+		message[i] = byte(i * 7)
+	}
+	c := NewRaptorCodec(1000, 4)
+	ids := make([]int64, 1100)
+	for i := range ids {
+		ids[i] = int64(2000 + i)
+	}
+	// A nil Strategy is the default, which reduces the dense tail of the
+	// matrix first (see reduceCore).
+	for _, s := range []Strategy{PushStrategy, PullStrategy, WeightStrategy, InactivationStrategy, nil} {
+		// Abandon adding the blocks, and then decoding, at various points.
+		for n := 0; ; n += 5 {
+			// Decoders take ownership of the blocks they're given.
+			blocks := EncodeLTBlocksCopy(message, ids, c)
+			d := c.NewDecoder(len(message))
+			SetStrategy(d, s)
+			ctx := &countdownContext{Context: context.Background(), n: n}
+			determined, err := AddBlocksContext(ctx, d, blocks)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("%T: AddBlocksContext returned %v, want context.Canceled", s, err)
+			}
+			if !determined {
+				continue
+			}
+			for m := 1; m <= 3; m++ {
+				ctx = &countdownContext{Context: context.Background(), n: m}
+				if _, err := DecodeContext(ctx, d); err != nil && !errors.Is(err, context.Canceled) {
+					t.Errorf("%T: DecodeContext with a cancelled context returned %v", s, err)
+				}
+			}
+			out, err := DecodeContext(context.Background(), d)
+			if err != nil || !bytes.Equal(out, message) {
+				t.Errorf("%T, countdown %d: DecodeContext after abandoning = %v, want the message", s, n, err)
+			}
+		}
+	}
+
+	d := NewBinaryCodec(10).NewDecoder(100)
+	var de *DecodeError
+	if _, err := DecodeContext(context.Background(), d); !errors.As(err, &de) {
+		t.Errorf("DecodeContext of an undetermined decoder returned %v, want a *DecodeError", err)
+	}
+}
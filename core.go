@@ -0,0 +1,206 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+// Strategies walk the whole matrix: PushStrategy visits every pair of rows.
+// Two kinds of matrix are better reduced as a dense GF(2) system instead, with
+// each row's coefficients a bitset over the columns being eliminated.
+//
+// Receivers which read the message progressively (DecodeWriter,
+// DecodePartial, DecodeRegions) reduce the matrix again as each few code
+// blocks arrive, and by then all but a small core of the rows are solved
+// already. When few solvable rows are left unsolved, all of them are
+// eliminated densely, without visiting the rest of the matrix.
+//
+// And when a matrix is first reduced, triangularization (see addEquation) has
+// filled in its last rows: each of those refers to a good fraction of the
+// rows below it, which is exactly the structure raptor decoding produces. The
+// longest run of trailing unsolved rows whose coefficients among themselves
+// are dense is eliminated densely, and PushStrategy then substitutes their
+// values into the rows above.
+//
+// Dense elimination is Gauss-Jordan elimination: the values of the solved
+// rows the system refers to are XORed in first, then its columns, from the
+// last to the first, are each pivoted on the lightest row which holds them
+// and eliminated from all the others. The system is triangular, so the
+// lightest row is the one leading with the column, and the XORs are those of
+// back-substitution; pivoting keeps the elimination correct for any system of
+// full rank all the same.
+//
+// Decoders for which a Strategy has been selected (see SetStrategy) always
+// leave the whole reduction to it.
+
+// denseCoreDivisor sets the size of the unsolved core reduced as a dense
+// system: at most one in denseCoreDivisor of the matrix's rows.
+const denseCoreDivisor = 8
+
+// denseTailDivisor sets the density of a run of trailing rows reduced as a
+// dense system: at least one in denseTailDivisor of its coefficients among
+// its own columns are set.
+const denseTailDivisor = 12
+
+// denseTailMinRows is the fewest trailing rows reduced as a dense system.
+// The bitsets of fewer don't pay for their allocation.
+const denseTailMinRows = 64
+
+// reduceCore reduces the solvable rows which aren't yet solved as a dense
+// system, if there are few enough of them, or else their dense tail if they
+// have one. Returns true if nothing is left for the matrix's Strategy to do:
+// the whole core was reduced, or the reduction was abandoned (see cancelled).
+func (m *sparseMatrix) reduceCore(ok []bool) bool {
+	var core []int
+	for i, row := range m.coeff {
+		if ok[i] && len(row) > 1 {
+			core = append(core, i)
+		}
+	}
+	if len(core)*denseCoreDivisor <= len(m.coeff) {
+		m.reduceDense(core)
+		return true
+	}
+	if tail := m.denseTail(core); tail != nil {
+		m.reduceDense(tail)
+	}
+	return m.ctxErr != nil
+}
+
+// denseTail returns the longest run of at least denseTailMinRows rows at the
+// end of core which is dense, or nil if there is none. core is the unsolved
+// solvable rows, so any run at its end refers only to its own rows and to
+// solved ones.
+func (m *sparseMatrix) denseTail(core []int) []int {
+	pos := make([]int, len(m.coeff))
+	for i := range pos {
+		pos[i] = -1
+	}
+	for p, i := range core {
+		pos[i] = p
+	}
+	// The rows of a triangular matrix refer only to rows below them, so the
+	// coefficients within the run from position s are those of its rows in
+	// core columns.
+	ones := 0
+	start := -1
+	for s := len(core) - 1; s >= 0; s-- {
+		for _, c := range m.coeff[core[s]][1:] {
+			if pos[c] >= 0 {
+				ones++
+			}
+		}
+		if n := len(core) - s; n >= denseTailMinRows && ones*denseTailDivisor >= n*n {
+			start = s
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	return core[start:]
+}
+
+// reduceDense solves the given rows, in increasing order, as a dense system.
+// They must be solvable, and refer only to each other and to solved rows.
+func (m *sparseMatrix) reduceDense(rows []int) {
+	n := len(rows)
+	pos := make([]int, len(m.coeff))
+	for i := range pos {
+		pos[i] = -1
+	}
+	for p, i := range rows {
+		pos[i] = p
+	}
+	sys := make([]bitRow, n)
+	weight := make([]int, n)
+	for p, i := range rows {
+		sys[p] = make(bitRow, (n+63)/64)
+		for _, c := range m.coeff[i] {
+			if q := pos[c]; q >= 0 {
+				sys[p][q/64] |= 1 << (q % 64)
+				weight[p]++
+				continue
+			}
+			m.xorDense(i, c)
+		}
+	}
+
+	pivoted := make([]bool, n)
+	for q := n - 1; q >= 0; q-- {
+		if m.cancelled(n - 1 - q) {
+			break
+		}
+		// The pivot search and the elimination each test column q of every
+		// equation.
+		m.cost.Scans += 2 * int64(n)
+		pivot := -1
+		for p := range sys {
+			if !pivoted[p] && sys[p].has(q) && (pivot < 0 || weight[p] < weight[pivot]) {
+				pivot = p
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+		pivoted[pivot] = true
+		for p := range sys {
+			if p != pivot && sys[p].has(q) {
+				weight[p] += sys[p].xor(sys[pivot])
+				m.xorDense(rows[p], rows[pivot])
+			}
+		}
+	}
+	m.placeDense(rows, sys, weight)
+}
+
+// placeDense stores the equations of a dense system back in the matrix, each
+// in the row of its leading column, once reduceDense has finished or
+// abandoned the elimination. Equations which lead with the same column are
+// reduced by one another first, so that the matrix stays triangular.
+func (m *sparseMatrix) placeDense(rows []int, sys []bitRow, weight []int) {
+	lead := make([]int, len(rows))
+	for q := range lead {
+		lead[q] = -1
+	}
+	for p := range sys {
+		for {
+			q := sys[p].next(0)
+			if q < 0 {
+				break
+			}
+			if lead[q] < 0 {
+				lead[q] = p
+				break
+			}
+			weight[p] += sys[p].xor(sys[lead[q]])
+			m.xorDense(rows[p], rows[lead[q]])
+		}
+	}
+	v := make([]block, len(rows))
+	for p, i := range rows {
+		v[p] = m.v[i]
+	}
+	for q, p := range lead {
+		coeffs := make([]int, 0, weight[p])
+		for _, r := range sys[p].coeffs(weight[p]) {
+			coeffs = append(coeffs, rows[r])
+		}
+		m.setRow(rows[q], coeffs, v[p])
+	}
+}
+
+// xorDense XORs the value of row j into that of row i.
+func (m *sparseMatrix) xorDense(i, j int) {
+	m.v[i].xor(m.v[j])
+	m.cost.RowOps++
+	m.cost.XORBytes += int64(len(m.v[j].data))
+}
@@ -0,0 +1,175 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestReduceCore(t *testing.T) {
+	message := make([]byte, 64*8)
+	rand.New(rand.NewSource(3)).Read(message)
+	c := NewSeededLubyCodec(64, 5, RobustSolitonDistribution(64, 16, 0.1))
+	enc := c.NewEncoder(message)
+	core := c.NewDecoder(len(message)).(*lubyDecoder)
+	push := c.NewDecoder(len(message)).(*lubyDecoder)
+
+	// Reduce after every code block, as a progressive reader would.
+	used := 0
+	for id := int64(0); !core.matrix.determined(); id++ {
+		if id > 1000 {
+			t.Fatalf("No decode after 1000 blocks")
+		}
+		b := enc.Generate(id)
+		indices := c.PickIndices(id)
+		core.matrix.addEquation(indices, block{data: bytes.Clone(b.Data)})
+		push.matrix.addEquation(indices, block{data: b.Data})
+
+		if ok := core.matrix.solvable(); core.matrix.reduceCore(ok) {
+			used++
+		} else {
+			PushStrategy.Reduce(&Reduction{m: &core.matrix, ok: ok})
+		}
+		PushStrategy.Reduce(&Reduction{m: &push.matrix, ok: push.matrix.solvable()})
+		if !reflect.DeepEqual(core.matrix.coeff, push.matrix.coeff) {
+			t.Fatalf("After block %d, coefficients differ from PushStrategy's", id)
+		}
+		for i := range core.matrix.v {
+			if !bytes.Equal(core.matrix.v[i].data, push.matrix.v[i].data) {
+				t.Fatalf("After block %d, row %d = %v, want %v", id, i, core.matrix.v[i].data, push.matrix.v[i].data)
+			}
+		}
+	}
+	if used == 0 {
+		t.Errorf("The unsolved core was never small enough to reduce directly")
+	}
+	if out, _ := core.decode(true); !bytes.Equal(out, message) {
+		t.Errorf("Decoded %q, want %q", out, message)
+	}
+}
+
+func TestReduceCoreTooLarge(t *testing.T) {
+	m := sparseMatrix{
+		coeff: [][]int{{0, 1}, {1, 2}, {2, 3}, {3}},
+		v:     []block{{data: []byte{1}}, {data: []byte{2}}, {data: []byte{4}}, {data: []byte{8}}},
+	}
+	if m.reduceCore(m.solvable()) {
+		t.Errorf("reduceCore reduced a core of 3 rows in a matrix of 4")
+	}
+	if len(m.coeff[0]) != 2 || m.v[0].data[0] != 1 {
+		t.Errorf("reduceCore changed the matrix: row 0 = (%v = %v)", m.coeff[0], m.v[0].data)
+	}
+}
+
+func TestReduceDenseTail(t *testing.T) {
+	message := make([]byte, 1000*4)
+	rand.New(rand.NewSource(4)).Read(message)
+	c := NewRaptorCodec(1000, 4)
+	ids := make([]int64, 1200)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	blocks := EncodeLTBlocksCopy(message, ids, c)
+	dense := c.NewDecoder(len(message))
+	push := c.NewDecoder(len(message))
+	SetStrategy(push, PushStrategy)
+	// Add the blocks to the dense decoder's matrix directly, as AddBlocks
+	// reduces it as soon as it is determined.
+	m := decoderMatrix(dense)
+	for _, b := range blocks {
+		push.AddBlocks([]LTBlock{{BlockCode: b.BlockCode, Data: bytes.Clone(b.Data)}})
+		m.addCodeBlock(b.BlockCode, findLTIndices(1000, uint16(b.BlockCode)), block{data: b.Data})
+		if m.determined() {
+			break
+		}
+	}
+
+	// On the first reduction, the core is most of the matrix, but its last
+	// rows are dense.
+	ok := m.solvable()
+	var core []int
+	for i, row := range m.coeff {
+		if ok[i] && len(row) > 1 {
+			core = append(core, i)
+		}
+	}
+	if len(core)*denseCoreDivisor <= len(m.coeff) {
+		t.Fatalf("Core of %d rows in a matrix of %d, want most of it", len(core), len(m.coeff))
+	}
+	if tail := m.denseTail(core); len(tail) < denseTailMinRows {
+		t.Errorf("Dense tail of %d rows, want at least %d", len(tail), denseTailMinRows)
+	}
+	if !dense.AddBlocks(nil) {
+		t.Fatalf("Decoder not determined")
+	}
+	if out := dense.Decode(); !bytes.Equal(out, message) {
+		t.Errorf("Decoded message differs")
+	}
+	if out := push.Decode(); !bytes.Equal(out, message) {
+		t.Errorf("Decoded message differs with PushStrategy")
+	}
+	// Eliminating the tail densely makes the XORs that back-substituting it
+	// would, though not the same comparisons.
+	got, want := m.cost, decoderMatrix(push).cost
+	got.Scans, want.Scans = 0, 0
+	if got != want {
+		t.Errorf("Cost %+v, want PushStrategy's %+v", got, want)
+	}
+}
+
+func TestReduceDensePivoting(t *testing.T) {
+	// A system of full rank which isn't triangular: x0+x1 = 3, x1 = 2 and
+	// x0+x1+x2 = 7 are written to rows 0, 2 and 1, over a solved row 3 = 8
+	// which row 2 refers to.
+	m := sparseMatrix{
+		coeff: [][]int{{0, 1}, {0, 1, 2}, {1, 3}, {3}},
+		v:     []block{{data: []byte{3}}, {data: []byte{7}}, {data: []byte{2 ^ 8}}, {data: []byte{8}}},
+	}
+	m.reduceDense([]int{0, 1, 2})
+	for i, want := range []byte{1, 2, 4, 8} {
+		if !reflect.DeepEqual(m.coeff[i], []int{i}) || m.v[i].data[0] != want {
+			t.Errorf("Row %d = (%v = %v), want ([%d] = %d)", i, m.coeff[i], m.v[i].data, i, want)
+		}
+	}
+}
+
+func BenchmarkDecodeWriter(b *testing.B) {
+	message := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(message)
+	c := NewSeededLubyCodec(1024, 1, RobustSolitonDistribution(1024, 64, 0.05))
+	ids := make([]int64, 2000)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	blocks := EncodeLTBlocksCopy(message, ids, c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := c.NewDecoder(len(message))
+		dw, _ := NewDecodeWriter(d, io.Discard)
+		for j := 0; !dw.Done(); j++ {
+			// Drop a few blocks so that the writer has to wait on repairs.
+			if j%100 != 7 {
+				d.AddBlocks([]LTBlock{{BlockCode: blocks[j].BlockCode, Data: bytes.Clone(blocks[j].Data)}})
+			}
+			if j%16 == 0 || j >= len(message)/64 {
+				dw.Flush()
+			}
+		}
+	}
+}
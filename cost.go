@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Choosing a codec and its parameters trades reception overhead against the
+// CPU the receiver spends decoding. The reception overhead is easy to observe;
+// the decode cost is tracked here in machine-independent units (row operations
+// and bytes XORed) so codecs and parameter sets can be compared directly.
+
+// DecodeCost is an account of the work a decoder performed.
+type DecodeCost struct {
+	// Blocks is the number of code blocks given to the decoder.
+	Blocks int
+
+	// Equations is the number of equations added to the decode matrix. This
+	// includes any constraint equations the codec adds for its precode.
+	Equations int
+
+	// RowOps is the number of elimination steps: one for each time a row of the
+	// decode matrix was XORed into another equation.
+	RowOps int
+
+	// XORBytes is the total number of bytes XORed while decoding.
+	XORBytes int64
+
+	// Scans is the number of coefficient comparisons made during
+	// back-substitution, which depends on the Strategy (see SetStrategy).
+	Scans int64
+}
+
+// decoderMatrix returns the decode matrix of one of the package's decoders, or
+// nil if the decoder is of an unknown type.
+func decoderMatrix(d Decoder) *sparseMatrix {
+	switch d := d.(type) {
+	case *lubyDecoder:
+		return &d.matrix
+	case *binaryDecoder:
+		return &d.matrix
+	case *onlineDecoder:
+		return &d.matrix
+	case *raptorDecoder:
+		return &d.matrix
+	case *ru10Decoder:
+		return &d.decoder.matrix
+	case *roiDecoder:
+		return decoderMatrix(d.Decoder)
+	case *authDecoder:
+		return decoderMatrix(d.Decoder)
+	}
+	return nil
+}
+
+// MeasureDecodeCost encodes a random message of the given length with the codec
+// and feeds code blocks with random IDs to a decoder one at a time until the
+// message can be decoded, then decodes it. It returns the cost of the whole
+// decode. If the decoder cannot decode after maxBlocks blocks, the returned
+// cost has the work done so far and ok is false.
+// The random source governs both the message contents and the block IDs, so a
+// seeded source gives reproducible measurements.
+func MeasureDecodeCost(c Codec, messageLength, maxBlocks int, random *rand.Rand) (cost DecodeCost, ok bool) {
+	message := make([]byte, messageLength)
+	for i := range message {
+		message[i] = byte(random.Intn(256))
+	}
+	ids := make([]int64, maxBlocks)
+	for i := range ids {
+		// Stay in the uint16 range so the raptor codec ESIs don't alias.
+		ids[i] = int64(random.Intn(65536))
+	}
+	blocks := EncodeLTBlocks(message, ids, c)
+
+	d := c.NewDecoder(messageLength)
+	m := decoderMatrix(d)
+	if m == nil {
+		return cost, false
+	}
+	for i := range blocks {
+		cost.Blocks++
+		if d.AddBlocks(blocks[i : i+1]) {
+			ok = true
+			break
+		}
+	}
+	if ok {
+		d.Decode()
+	}
+	cost.Equations, cost.RowOps, cost.XORBytes, cost.Scans = m.cost.Equations, m.cost.RowOps, m.cost.XORBytes, m.cost.Scans
+	return cost, ok
+}
+
+// DecodeBound is a worst-case bound on the work and memory of a decode, for
+// capacity planning. It holds for every set of code blocks, so it is far
+// above the typical cost MeasureDecodeCost reports; the ratio between the two
+// shows how much headroom the bound leaves.
+type DecodeBound struct {
+	// RowOps and XORBytes bound the DecodeCost fields of the same names.
+	RowOps, XORBytes int64
+
+	// Scans bounds the DecodeCost field of the same name, which depends on
+	// the Strategy.
+	Scans int64
+
+	// MemoryBytes bounds the memory held by the decode matrix: one symbol and
+	// up to one coefficient per block for each row.
+	MemoryBytes int64
+}
+
+// maxLTDegree is the largest number of intermediate blocks the raptor codecs
+// combine into one code block.
+const maxLTDegree = 40
+
+// DecodeUpperBound bounds the cost of decoding with the codec when up to
+// maxBlocks code blocks of symbolSize bytes are received, using the given
+// back-substitution strategy (nil for the default).
+//
+// Each equation added to the decode matrix is reduced at most once against
+// each of the L rows, and back-substitution XORs at most L(L-1)/2 rows; the R10
+// raptor codec then re-encodes K source blocks from at most 40 intermediate
+// blocks each. Returns an error if the codec's decoder is of an unknown type.
+func DecodeUpperBound(c Codec, symbolSize, maxBlocks int, s Strategy) (DecodeBound, error) {
+	d := c.NewDecoder(symbolSize * c.SourceBlocks())
+	m := decoderMatrix(d)
+	if m == nil {
+		return DecodeBound{}, fmt.Errorf("fountain: DecodeUpperBound doesn't support %T", d)
+	}
+	l := int64(len(m.coeff))
+	k := int64(c.SourceBlocks())
+	equations := int64(m.cost.Equations + maxBlocks)
+
+	var b DecodeBound
+	b.RowOps = equations*l + l*(l-1)/2
+	if _, ok := d.(*raptorDecoder); ok {
+		b.RowOps += k * min(maxLTDegree, l)
+	}
+	b.XORBytes = b.RowOps * int64(symbolSize)
+	if s == PullStrategy || s == WeightStrategy {
+		b.Scans = l * (l - 1) / 2
+	} else {
+		// Each row is compared with every row above it, coefficient by
+		// coefficient, and a dense reduction tests each of its columns in
+		// every equation twice. For strategies from outside the package no
+		// better is known.
+		b.Scans = l*(l-1)/2*l + 2*l*l
+	}
+	b.MemoryBytes = l * (int64(symbolSize) + 8*l)
+	return b, nil
+}
+
+// Time converts the bound into a decode time on a machine which XORs xorRate
+// bytes per second (see MeasureXORRate) and makes scanRate coefficient
+// comparisons per second.
+func (b DecodeBound) Time(xorRate, scanRate float64) time.Duration {
+	seconds := float64(b.XORBytes)/xorRate + float64(b.Scans)/scanRate
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestMatrixCost(t *testing.T) {
+	m := sparseMatrix{coeff: make([][]int, 2), v: make([]block, 2)}
+	m.addEquation([]int{0}, block{data: []byte{1, 2}})
+	m.addEquation([]int{0, 1}, block{data: []byte{3, 4}})
+	if m.cost.Equations != 2 || m.cost.RowOps != 1 || m.cost.XORBytes != 2 {
+		t.Errorf("Cost after insertion is %+v, want 2 equations, 1 row op, 2 bytes", m.cost)
+	}
+	m.reduce()
+	if m.cost.RowOps != 1 {
+		t.Errorf("Reducing a diagonal matrix should not cost row ops, got %d", m.cost.RowOps)
+	}
+}
+
+func TestMeasureDecodeCost(t *testing.T) {
+	codecs := []Codec{
+		NewBinaryCodec(10),
+		NewOnlineCodec(10, 0.2, 7, 0),
+		NewRaptorCodec(10, 4),
+		NewRU10Codec(10, 4),
+	}
+
+	for _, c := range codecs {
+		cost, ok := MeasureDecodeCost(c, 1000, 100, rand.New(rand.NewSource(8234982)))
+		if !ok {
+			t.Errorf("%T did not decode after %d blocks", c, cost.Blocks)
+			continue
+		}
+		if cost.Blocks < c.SourceBlocks() {
+			t.Errorf("%T decoded with %d blocks, fewer than %d source blocks",
+				c, cost.Blocks, c.SourceBlocks())
+		}
+		if cost.RowOps == 0 || cost.XORBytes == 0 {
+			t.Errorf("%T reported no decode work: %+v", c, cost)
+		}
+		t.Logf("%T: %+v", c, cost)
+	}
+}
+
+func TestDecodeUpperBound(t *testing.T) {
+	message := make([]byte, 1000)
+	rand.New(rand.NewSource(8234982)).Read(message)
+	codecs := []Codec{
+		NewBinaryCodec(10),
+		NewOnlineCodec(10, 0.2, 7, 0),
+		NewRaptorCodec(10, 4),
+		NewRU10Codec(10, 4),
+	}
+
+	for _, c := range codecs {
+		scans := make(map[Strategy]int64)
+		for _, s := range []Strategy{PushStrategy, PullStrategy, WeightStrategy, InactivationStrategy, substituteStrategy{}} {
+			bound, err := DecodeUpperBound(c, 100, 100, s)
+			if err != nil {
+				t.Fatalf("%T: %v", c, err)
+			}
+			d := c.NewDecoder(len(message))
+			SetStrategy(d, s)
+			var cost DecodeCost
+			for id := int64(0); id < 100; id++ {
+				cost.Blocks++
+				if d.AddBlocks(EncodeLTBlocks(append([]byte(nil), message...), []int64{id}, c)) {
+					break
+				}
+			}
+			if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+				t.Errorf("%T with %T did not decode after %d blocks", c, s, cost.Blocks)
+				continue
+			}
+			m := decoderMatrix(d).cost
+			cost.Equations, cost.RowOps, cost.XORBytes, cost.Scans = m.Equations, m.RowOps, m.XORBytes, m.Scans
+			if int64(cost.RowOps) > bound.RowOps || cost.XORBytes > bound.XORBytes || cost.Scans > bound.Scans {
+				t.Errorf("%T with %T: measured cost %+v exceeds bound %+v", c, s, cost, bound)
+			}
+			if cost.Scans == 0 {
+				t.Errorf("%T with %T made no comparisons: %+v", c, s, cost)
+			}
+			if bound.MemoryBytes < 1000 || bound.Time(1e9, 1e9) <= 0 {
+				t.Errorf("%T: implausible bound %+v", c, bound)
+			}
+			scans[s] = cost.Scans
+		}
+		// Pulling reads each row's coefficients once; pushing compares every
+		// solved row with all the rows above it.
+		if scans[PullStrategy] >= scans[PushStrategy] {
+			t.Errorf("%T: pull strategy made %d comparisons, push strategy %d; want fewer",
+				c, scans[PullStrategy], scans[PushStrategy])
+		}
+	}
+
+	push, _ := DecodeUpperBound(NewRaptorCodec(10, 4), 100, 100, PushStrategy)
+	pull, _ := DecodeUpperBound(NewRaptorCodec(10, 4), 100, 100, PullStrategy)
+	if pull.Scans >= push.Scans || pull.RowOps != push.RowOps {
+		t.Errorf("Pull strategy bound %+v, push strategy bound %+v; want fewer scans, same row ops", pull, push)
+	}
+	if _, err := DecodeUpperBound(NewRaptorCodec(10, 4), 100, 100, nil); err != nil {
+		t.Errorf("DecodeUpperBound with the default strategy failed: %v", err)
+	}
+}
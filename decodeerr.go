@@ -0,0 +1,66 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+)
+
+// DecodeError explains why a decoder couldn't produce the message.
+type DecodeError struct {
+	// Diagnostics is the state of the decoder when decoding was attempted.
+	Diagnostics Diagnostics
+
+	// Gaps lists the rows of the decode matrix which are holding it back.
+	Gaps MatrixGaps
+
+	// Deficit estimates how many more independent code blocks the decoder
+	// needs: the number of empty rows in its decode matrix. More will need to
+	// be received to cover duplicates, linearly dependent blocks and loss.
+	Deficit int
+}
+
+// Error summarizes the decoder's state.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("fountain: decoder is not yet determined: rank %d/%d, ~%d more code blocks needed, %d inconsistent equations",
+		e.Diagnostics.Rank, e.Diagnostics.Rows, e.Deficit, e.Diagnostics.Inconsistent)
+}
+
+// newDecodeError describes why d, one of the package's decoders, isn't
+// determined.
+func newDecodeError(d Decoder) *DecodeError {
+	diag, _ := Diagnose(d)
+	gaps, _ := DecoderGaps(d)
+	return &DecodeError{
+		Diagnostics: diag,
+		Gaps:        gaps,
+		Deficit:     gaps.Missing(),
+	}
+}
+
+// TryDecode is like d.Decode(), but returns a *DecodeError explaining why
+// instead of a nil message if the decoder isn't determined. d must be a
+// decoder created by one of the package's codecs.
+func TryDecode(d Decoder) ([]byte, error) {
+	s, ok := d.(strictDecoder)
+	if !ok {
+		return nil, fmt.Errorf("fountain: TryDecode doesn't support %T", d)
+	}
+	out, err := s.decode(false)
+	if err == errNotDetermined {
+		return nil, newDecodeError(d)
+	}
+	return out, err
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTryDecode(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	codecs := testCodecs()
+	for _, c := range codecs {
+		d := c.NewDecoder(len(message))
+		d.AddBlocks(EncodeLTBlocksCopy(message, []int64{0, 1, 2, 3}, c))
+
+		_, err := TryDecode(d)
+		e, ok := err.(*DecodeError)
+		if !ok {
+			t.Fatalf("%T: TryDecode error = %v, want a *DecodeError", c, err)
+		}
+		if e.Diagnostics.Received != 4 {
+			t.Errorf("%T: error reports %d received blocks, want 4", c, e.Diagnostics.Received)
+		}
+		if e.Deficit != e.Diagnostics.Rows-e.Diagnostics.Rank || e.Deficit == 0 {
+			t.Errorf("%T: deficit %d after 4 of 10 blocks", c, e.Deficit)
+		}
+		if _, err := DecodeStrict(d); err == nil {
+			t.Errorf("%T: DecodeStrict succeeded", c)
+		}
+		t.Logf("%T: %v", c, err)
+
+		for id := int64(4); !d.AddBlocks(EncodeLTBlocksCopy(message, []int64{id}, c)); id++ {
+		}
+		decoded, err := TryDecode(d)
+		if err != nil || !bytes.Equal(decoded, message) {
+			t.Errorf("%T: TryDecode = %q, %v, want %q", c, decoded, err, message)
+		}
+	}
+}
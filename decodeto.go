@@ -0,0 +1,143 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"io"
+)
+
+// Decode() assembles the whole message in a new buffer, on top of the decode
+// matrix already holding the source blocks. A receiver which only streams the
+// message to a file or socket can instead write the source blocks straight
+// from the matrix, and need not even wait for the whole message: the blocks at
+// the front of the message are often recovered well before the last ones.
+
+// decoderLength returns the length of the message d decodes, if d is one of
+// the package's decoders.
+func decoderLength(d Decoder) (int, bool) {
+	switch d := d.(type) {
+	case *lubyDecoder:
+		return d.messageLength, true
+	case *binaryDecoder:
+		return d.messageLength, true
+	case *onlineDecoder:
+		return d.messageLength, true
+	case *raptorDecoder:
+		return d.messageLength, true
+	case *ru10Decoder:
+		return d.decoder.messageLength, true
+	case *reedSolomonDecoder:
+		return d.messageLength, true
+	case *roiDecoder:
+		return decoderLength(d.Decoder)
+	case *authDecoder:
+		return decoderLength(d.Decoder)
+	}
+	return 0, false
+}
+
+// DecodeWriter writes a message to an io.Writer progressively, as the source
+// blocks at its front are recovered.
+type DecodeWriter struct {
+	decoder       Decoder
+	messageLength int
+	w             io.Writer
+
+	// next is the index of the next source block to write, and pieces the
+	// number of source blocks.
+	next, pieces int
+
+	// err is the first error the writer failed with.
+	err error
+}
+
+// NewDecodeWriter creates a writer of the message d decodes to w. d must be
+// a decoder created by one of the package's codecs.
+func NewDecodeWriter(d Decoder, w io.Writer) (*DecodeWriter, error) {
+	length, ok := decoderLength(d)
+	if !ok {
+		return nil, fmt.Errorf("fountain: NewDecodeWriter doesn't support %T", d)
+	}
+	return &DecodeWriter{decoder: d, messageLength: length, w: w}, nil
+}
+
+// Flush writes the source blocks which follow those already written and have
+// since been recovered, stopping at the first one which hasn't. Returns the
+// number of blocks written. Like Decode(), blocks holding less data than
+// their share of the message are zero-filled.
+// Once writing fails, Flush returns the same error without writing anything.
+func (dw *DecodeWriter) Flush() (int, error) {
+	if dw.err != nil {
+		return 0, dw.err
+	}
+	source, recovered := dw.decoder.(sourceRecoverer).recoverSource()
+	p := sourcePartition(dw.messageLength, len(source))
+	dw.pieces = p.Pieces()
+	n := 0
+	for ; dw.next < p.Pieces() && recovered[dw.next]; dw.next++ {
+		want := p.Offset(dw.next+1) - p.Offset(dw.next)
+		if dw.err = writeBlock(dw.w, source[dw.next].data, want); dw.err != nil {
+			return n, dw.err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Done reports whether the whole message has been written.
+func (dw *DecodeWriter) Done() bool {
+	return dw.err == nil && dw.pieces > 0 && dw.next == dw.pieces
+}
+
+// zeros is written in place of the missing tail of short source blocks.
+var zeros [512]byte
+
+// writeBlock writes the first want bytes of a source block holding data,
+// zero-filling them past its end.
+func writeBlock(w io.Writer, data []byte, want int) error {
+	if len(data) > want {
+		data = data[:want]
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	for want -= len(data); want > 0; want -= min(want, len(zeros)) {
+		if _, err := w.Write(zeros[:min(want, len(zeros))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeTo writes the message d decodes to w, one source block at a time,
+// instead of assembling it in memory. If the decoder can't recover the whole
+// message, it writes nothing and returns a *DecodeError. d must be a decoder
+// created by one of the package's codecs. Use a DecodeWriter to write the
+// message progressively instead.
+func DecodeTo(d Decoder, w io.Writer) error {
+	dw, err := NewDecodeWriter(d, w)
+	if err != nil {
+		return err
+	}
+	_, recovered := d.(sourceRecoverer).recoverSource()
+	for _, ok := range recovered {
+		if !ok {
+			return newDecodeError(d)
+		}
+	}
+	_, err = dw.Flush()
+	return err
+}
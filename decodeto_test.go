@@ -0,0 +1,109 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecodeTo(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	codecs := testCodecs()
+	for _, c := range codecs {
+		d := c.NewDecoder(len(message))
+		var out bytes.Buffer
+		var de *DecodeError
+		if err := DecodeTo(d, &out); !errors.As(err, &de) {
+			t.Errorf("%T: DecodeTo of an empty decoder = %v, want a *DecodeError", c, err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%T: DecodeTo wrote %d bytes of an undecoded message", c, out.Len())
+		}
+
+		enc := c.NewEncoder(message)
+		for id := int64(0); !d.AddBlocks([]LTBlock{enc.Generate(id)}); id++ {
+			if id > 500 {
+				t.Fatalf("%T: no decode after 500 blocks", c)
+			}
+		}
+		if err := DecodeTo(d, &out); err != nil {
+			t.Fatalf("%T: DecodeTo failed: %v", c, err)
+		}
+		if !bytes.Equal(out.Bytes(), message) {
+			t.Errorf("%T: DecodeTo wrote %q, want %q", c, out.Bytes(), message)
+		}
+	}
+}
+
+func TestDecodeWriter(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewBinaryCodec(10)
+	d := c.NewDecoder(len(message))
+	var out bytes.Buffer
+	dw, err := NewDecodeWriter(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := dw.Flush(); n != 0 || err != nil {
+		t.Errorf("Flush of an empty decoder = (%d, %v), want (0, nil)", n, err)
+	}
+
+	// Load the source blocks back to front: nothing can be written until the
+	// first block arrives, and then everything can.
+	p := sourcePartition(len(message), 10)
+	for i := 9; i > 0; i-- {
+		d.(sourcePreloader).preloadSource(i, message[p.Offset(i):p.Offset(i+1)])
+		if n, err := dw.Flush(); n != 0 || err != nil {
+			t.Fatalf("Flush without block 0 = (%d, %v), want (0, nil)", n, err)
+		}
+	}
+	d.(sourcePreloader).preloadSource(0, message[:p.Offset(1)])
+	if n, err := dw.Flush(); n != 10 || err != nil {
+		t.Errorf("Flush = (%d, %v), want (10, nil)", n, err)
+	}
+	if !dw.Done() {
+		t.Errorf("Done = false after writing every block")
+	}
+	if !bytes.Equal(out.Bytes(), message) {
+		t.Errorf("wrote %q, want %q", out.Bytes(), message)
+	}
+
+	if _, err := NewDecodeWriter(NewQuarantineDecoder(d, nil, 1), &out); err == nil {
+		t.Errorf("NewDecodeWriter accepted a foreign decoder")
+	}
+}
+
+func TestDecodeWriterPrefix(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewBinaryCodec(10)
+	d := c.NewDecoder(len(message))
+	var out bytes.Buffer
+	dw, _ := NewDecodeWriter(d, &out)
+	p := sourcePartition(len(message), 10)
+	for _, i := range []int{0, 1, 3} {
+		d.(sourcePreloader).preloadSource(i, message[p.Offset(i):p.Offset(i+1)])
+	}
+	if n, err := dw.Flush(); n != 2 || err != nil {
+		t.Errorf("Flush = (%d, %v), want (2, nil)", n, err)
+	}
+	if want := message[:14]; !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("wrote %q, want %q", out.Bytes(), want)
+	}
+	if dw.Done() {
+		t.Errorf("Done = true with blocks missing")
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// receptionStats describes the code blocks a decode matrix has been given.
+type receptionStats struct {
+	// tracker records the code block IDs seen.
+	tracker *ReceptionTracker
+
+	received, duplicates int
+
+	// rejected counts the code blocks dropped because they were larger than
+	// the matrix's symbol size, or their IDs were out of range.
+	rejected int
+
+	// late counts the code blocks which arrived after the decoder was
+	// determined and were only counted (see OverfeedCount), and mismatches
+	// those of them which didn't match the decoded message (see
+	// OverfeedVerify).
+	late, mismatches int
+
+	// redundant counts equations which reduced to nothing when added, and
+	// inconsistent those of them whose value didn't reduce to zero.
+	redundant, inconsistent int
+
+	// degrees counts the code blocks by the number of blocks they combine.
+	degrees map[int]int
+}
+
+// init allocates the statistics' tables if that hasn't been done yet.
+func (s *receptionStats) init() {
+	if s.tracker == nil {
+		s.tracker = NewReceptionTracker()
+		s.degrees = make(map[int]int)
+	}
+}
+
+// addCodeBlock adds the equation for a received code block to the matrix, and
+// records it in the matrix's reception statistics.
+func (m *sparseMatrix) addCodeBlock(id int64, components []int, b block) {
+	if !m.admitBlock(b) {
+		return
+	}
+	if !m.stats.tracker.Receive(id) {
+		m.stats.duplicates++
+	}
+	m.addReceived(components, b)
+}
+
+// addRegionBlock adds the equation for a received block which isn't one of
+// the sequence of code blocks, such as a region repair block, to the matrix.
+// It is counted in the reception statistics like a code block, but its ID
+// isn't recorded.
+func (m *sparseMatrix) addRegionBlock(components []int, b block) {
+	if m.admitBlock(b) {
+		m.addReceived(components, b)
+	}
+}
+
+// admitBlock counts a received block, and reports whether it fits the
+// matrix's symbol size. A block which doesn't is counted as rejected.
+func (m *sparseMatrix) admitBlock(b block) bool {
+	if m.symbolSize > 0 && len(b.data) > m.symbolSize {
+		m.rejectCodeBlock()
+		return false
+	}
+	m.stats.init()
+	m.stats.received++
+	return true
+}
+
+// addReceived adds the equation for an admitted block to the matrix.
+func (m *sparseMatrix) addReceived(components []int, b block) {
+	m.stats.degrees[len(components)]++
+	b.data = m.audit.handoff(b.data)
+	b.shared = m.borrowing
+	m.addEquation(components, b)
+}
+
+// rejectCodeBlock counts a code block the decoder dropped without adding it.
+func (m *sparseMatrix) rejectCodeBlock() {
+	m.stats.init()
+	m.stats.received++
+	m.stats.rejected++
+}
+
+// Diagnostics is a summary of a decoder's state, meant to be attached to bug
+// reports and telemetry when an application abandons a transfer.
+type Diagnostics struct {
+	// Decoder is the decoder's type.
+	Decoder string
+
+	// SourceBlocks and MessageLength are the parameters of the decode.
+	SourceBlocks, MessageLength int
+
+	// Received is the number of code blocks given to the decoder, and
+	// Duplicates the number of those whose ID had already been seen.
+	Received, Duplicates int
+
+	// Rejected is the number of received code blocks dropped because they
+	// were larger than the decoder's symbol size (see SetSymbolSize), or, for
+	// the raptor codec, their IDs weren't ESIs.
+	Rejected int
+
+	// Late is the number of received code blocks which were only counted
+	// because they arrived after the decoder was determined. Mismatched is the
+	// number of those which were checked against the decoded message and
+	// differed from it.
+	Late, Mismatched int
+
+	// Rank is the number of populated rows of the decode matrix, out of Rows.
+	Rank, Rows int
+
+	// Redundant is the number of equations (including any precode constraint
+	// equations) which added no information. Inconsistent is the number of
+	// those which contradicted the equations already in the matrix, which
+	// indicates corrupt code blocks or mismatched codec parameters.
+	Redundant, Inconsistent int
+
+	// Degrees maps the number of blocks combined in a code block to the number
+	// of code blocks received with that degree.
+	Degrees map[int]int
+}
+
+// Diagnose summarizes the state of a decoder created by one of the package's
+// codecs. The second return value is false if the decoder is of an unknown
+// type.
+func Diagnose(d Decoder) (Diagnostics, bool) {
+	m := decoderMatrix(d)
+	if m == nil {
+		return Diagnostics{}, false
+	}
+	diag := Diagnostics{
+		Decoder:      fmt.Sprintf("%T", d),
+		Received:     m.stats.received,
+		Duplicates:   m.stats.duplicates,
+		Rejected:     m.stats.rejected,
+		Late:         m.stats.late,
+		Mismatched:   m.stats.mismatches,
+		Rows:         len(m.coeff),
+		Redundant:    m.stats.redundant,
+		Inconsistent: m.stats.inconsistent,
+		Degrees:      make(map[int]int),
+	}
+	for _, row := range m.coeff {
+		if len(row) > 0 {
+			diag.Rank++
+		}
+	}
+	for degree, n := range m.stats.degrees {
+		diag.Degrees[degree] = n
+	}
+
+	switch d := d.(type) {
+	case *lubyDecoder:
+		diag.SourceBlocks, diag.MessageLength = d.codec.sourceBlocks, d.messageLength
+	case *binaryDecoder:
+		diag.SourceBlocks, diag.MessageLength = d.codec.numSourceBlocks, d.messageLength
+	case *onlineDecoder:
+		diag.SourceBlocks, diag.MessageLength = d.codec.numSourceBlocks, d.messageLength
+	case *raptorDecoder:
+		diag.SourceBlocks, diag.MessageLength = d.codec.NumSourceSymbols, d.messageLength
+	case *ru10Decoder:
+		diag.SourceBlocks, diag.MessageLength = d.codec.numSourceSymbols, d.decoder.messageLength
+	case *roiDecoder:
+		diag.SourceBlocks, diag.MessageLength = d.codec.SourceBlocks(), d.codec.messageLength
+	case *authDecoder:
+		inner, _ := Diagnose(d.Decoder)
+		diag.SourceBlocks, diag.MessageLength = inner.SourceBlocks, inner.MessageLength
+	}
+	return diag, true
+}
+
+// String formats the diagnostics compactly on one line, with the degree
+// histogram in ascending order of degree.
+func (d Diagnostics) String() string {
+	degrees := make([]int, 0, len(d.Degrees))
+	for degree := range d.Degrees {
+		degrees = append(degrees, degree)
+	}
+	sort.Ints(degrees)
+	hist := make([]string, len(degrees))
+	for i, degree := range degrees {
+		hist[i] = fmt.Sprintf("%d:%d", degree, d.Degrees[degree])
+	}
+	return fmt.Sprintf("%s k=%d len=%d recv=%d dup=%d rejected=%d late=%d mismatched=%d rank=%d/%d redundant=%d inconsistent=%d degrees=[%s]",
+		d.Decoder, d.SourceBlocks, d.MessageLength, d.Received, d.Duplicates, d.Rejected, d.Late, d.Mismatched, d.Rank, d.Rows,
+		d.Redundant, d.Inconsistent, strings.Join(hist, " "))
+}
+
+// DecoderReception returns the ReceptionTracker recording the code block IDs
+// given to a decoder created by one of the package's codecs, or false if the
+// decoder is of an unknown type. When code blocks are sent with sequential
+// IDs, its LossRate estimates the channel's loss rate without a separate
+// sequence number layer. The sender's declarations of IDs it skipped can be
+// recorded on it with NotSent.
+func DecoderReception(d Decoder) (*ReceptionTracker, bool) {
+	m := decoderMatrix(d)
+	if m == nil {
+		return nil, false
+	}
+	m.stats.init()
+	return m.stats.tracker, true
+}
+
+// EstimateLossRate estimates the loss rate of the channel feeding a decoder
+// created by one of the package's codecs, from the gaps in the code block IDs
+// it has received. It assumes that the IDs were sent sequentially; the raptor
+// codecs' IDs must not have wrapped around. Returns 0 if the decoder is of an
+// unknown type or has received nothing.
+func EstimateLossRate(d Decoder) float64 {
+	t, ok := DecoderReception(d)
+	if !ok {
+		return 0
+	}
+	return t.LossRate()
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"testing"
+)
+
+func TestReceptionStats(t *testing.T) {
+	m := sparseMatrix{coeff: make([][]int, 3), v: make([]block, 3)}
+	m.addCodeBlock(1, []int{0}, block{data: []byte{1}})
+	m.addCodeBlock(2, []int{0, 1}, block{data: []byte{3}})
+	m.addCodeBlock(3, []int{1}, block{data: []byte{2}})
+	m.addCodeBlock(3, []int{1}, block{data: []byte{2}})
+	m.addCodeBlock(4, []int{1}, block{data: []byte{7}})
+
+	s := m.stats
+	if s.received != 5 || s.duplicates != 1 {
+		t.Errorf("Got %d received, %d duplicates; want 5, 1", s.received, s.duplicates)
+	}
+	if s.redundant != 3 || s.inconsistent != 1 {
+		t.Errorf("Got %d redundant, %d inconsistent; want 3, 1", s.redundant, s.inconsistent)
+	}
+	if s.degrees[1] != 4 || s.degrees[2] != 1 {
+		t.Errorf("Degree histogram is %v", s.degrees)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	blocks := EncodeLTBlocks(append([]byte(nil), message...), []int64{0, 1, 2, 1}, codec)
+
+	d := codec.NewDecoder(len(message))
+	d.AddBlocks(blocks)
+	diag, ok := Diagnose(d)
+	if !ok {
+		t.Fatalf("Diagnose doesn't support %T", d)
+	}
+	l, s, h := intermediateSymbols(13)
+	if diag.SourceBlocks != 13 || diag.MessageLength != 26 || diag.Rows != l {
+		t.Errorf("Parameters are %+v", diag)
+	}
+	if diag.Received != 4 || diag.Duplicates != 1 || diag.Rank != s+h+3 {
+		t.Errorf("Got %d received, %d duplicates, rank %d; want 4, 1, %d",
+			diag.Received, diag.Duplicates, diag.Rank, s+h+3)
+	}
+	if diag.Redundant != 1 || diag.Inconsistent != 0 {
+		t.Errorf("Got %d redundant, %d inconsistent; want 1, 0", diag.Redundant, diag.Inconsistent)
+	}
+
+	if _, ok := Diagnose(nil); ok {
+		t.Errorf("Diagnose supports a nil decoder")
+	}
+	m := Diagnostics{Decoder: "d", Degrees: map[int]int{3: 1, 1: 2}}
+	want := "d k=0 len=0 recv=0 dup=0 rejected=0 late=0 mismatched=0 rank=0/0 redundant=0 inconsistent=0 degrees=[1:2 3:1]"
+	if m.String() != want {
+		t.Errorf("String() = %q, want %q", m.String(), want)
+	}
+}
+
+func TestEstimateLossRate(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	var ids []int64
+	for i := int64(0); i < 40; i++ {
+		if i%4 != 3 {
+			ids = append(ids, i)
+		}
+	}
+	d := codec.NewDecoder(len(message))
+	if EstimateLossRate(d) != 0 {
+		t.Errorf("Empty decoder estimates loss rate %f", EstimateLossRate(d))
+	}
+	d.AddBlocks(EncodeLTBlocks(append([]byte(nil), message...), ids, codec))
+
+	// IDs 0..38 span 39 sent, of which 9 were lost.
+	if rate := EstimateLossRate(d); rate < 0.230 || rate > 0.231 {
+		t.Errorf("EstimateLossRate = %f, want 9/39", rate)
+	}
+	tracker, ok := DecoderReception(d)
+	if !ok {
+		t.Fatalf("DecoderReception doesn't support %T", d)
+	}
+	tracker.NotSent(3, 3)
+	if rate := EstimateLossRate(d); rate < 0.210 || rate > 0.211 {
+		t.Errorf("After declaring ID 3 not sent, EstimateLossRate = %f, want 8/38", rate)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// MaxRaptorESI is the largest encoding symbol ID of the R10 raptor codec.
+// RFC 5053 ESIs are 16 bits. The raptor codec panics when asked to compose a
+// code block with an ID outside [0, MaxRaptorESI], and its decoders drop such
+// blocks (see Diagnostics.Rejected), rather than truncate the ID and alias
+// another ESI. Note that the RFC's triple generator works modulo 65521, so
+// ESIs 65521 and up compose the same code blocks as ESIs 0 through 14 (see
+// MaxDistinctRaptorESI).
+const MaxRaptorESI = 65535
+
+// MaxDistinctRaptorESI is the largest ESI of the R10 raptor codec whose code
+// block no smaller ESI also composes. Senders which mean to send distinct code
+// blocks draw their ESIs from [0, MaxDistinctRaptorESI].
+const MaxDistinctRaptorESI = 65520
+
+// LastBlockCode returns the largest ID of a distinct code block of codec c:
+// MaxDistinctRaptorESI for the raptor codec, and codecs wrapping it, and
+// math.MaxInt64 for codecs whose IDs are unbounded.
+func LastBlockCode(c Codec) int64 {
+	if distinctBlockCode(c, MaxDistinctRaptorESI+1) {
+		return math.MaxInt64
+	}
+	return MaxDistinctRaptorESI
+}
+
+// ErrESIExhausted is returned when every repair ESI has been allocated.
+var ErrESIExhausted = errors.New("fountain: repair ESI space exhausted")
+
+// RepairESIAllocator hands out R10 repair symbol IDs drawn uniformly without
+// replacement from [K, MaxDistinctRaptorESI]. Unlike counting upwards, it
+// never wraps around into the systematic IDs below K, repeats an ID through
+// 16-bit truncation or hands out an ID aliasing a smaller one; it fails
+// cleanly once the space is used up.
+type RepairESIAllocator struct {
+	first  int
+	random *rand.Rand
+
+	// The allocator runs a Fisher-Yates shuffle of the ESI space lazily:
+	// position i of the shuffled sequence holds swapped[i] if present, or
+	// first+i. next is the number of ESIs allocated so far.
+	swapped map[int]int
+	next    int
+}
+
+// NewRepairESIAllocator creates an allocator of repair ESIs for a raptor codec
+// with k source symbols, drawing from random.
+func NewRepairESIAllocator(k int, random *rand.Rand) *RepairESIAllocator {
+	return &RepairESIAllocator{first: k, random: random, swapped: make(map[int]int)}
+}
+
+// size returns the number of repair ESIs.
+func (a *RepairESIAllocator) size() int {
+	if a.first > MaxDistinctRaptorESI {
+		return 0
+	}
+	return MaxDistinctRaptorESI - a.first + 1
+}
+
+// at returns the ESI at position i of the shuffled sequence.
+func (a *RepairESIAllocator) at(i int) int {
+	if v, ok := a.swapped[i]; ok {
+		return v
+	}
+	return a.first + i
+}
+
+// Next returns an unallocated repair ESI, or ErrESIExhausted.
+func (a *RepairESIAllocator) Next() (int64, error) {
+	n := a.size()
+	if a.next >= n {
+		return 0, ErrESIExhausted
+	}
+	j := a.next + a.random.Intn(n-a.next)
+	esi := a.at(j)
+	a.swapped[j] = a.at(a.next)
+	delete(a.swapped, a.next)
+	a.next++
+	return int64(esi), nil
+}
+
+// NextN returns n unallocated repair ESIs. If fewer than n remain, it returns
+// none and ErrESIExhausted.
+func (a *RepairESIAllocator) NextN(n int) ([]int64, error) {
+	if n > a.Remaining() {
+		return nil, ErrESIExhausted
+	}
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i], _ = a.Next()
+	}
+	return ids, nil
+}
+
+// Remaining returns the number of repair ESIs not yet allocated.
+func (a *RepairESIAllocator) Remaining() int {
+	return a.size() - a.next
+}
+
+// ShuffledSystematicOrder returns the systematic ESIs 0..k-1 in a
+// pseudo-random order determined by seed. Transmitting the source symbols in
+// this order rather than sequentially spreads a burst of consecutive packet
+// losses across the object; GroupPacketizer.Order sends packets in such an
+// order. Decoders are unaffected, since each code block
+// carries its ESI; a receiver can regenerate the order from the seed if it
+// needs to know what to expect next.
+// The shuffle is an explicit Fisher-Yates over a 64-bit Mersenne Twister, so
+// other implementations can reproduce it.
+func ShuffledSystematicOrder(k int, seed int64) []int64 {
+	order := make([]int64, k)
+	for i := range order {
+		order[i] = int64(i)
+	}
+	random := rand.New(NewMersenneTwister64(seed))
+	for i := k - 1; i > 0; i-- {
+		j := random.Int63n(int64(i + 1))
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// validESI reports whether id is an ESI of the R10 raptor codec.
+func validESI(id int64) bool {
+	return id >= 0 && id <= MaxRaptorESI
+}
+
+// validBlockCode reports whether codec c can compose the code block with the
+// given ID. Only the raptor codec's IDs are bounded, to its ESIs; the IDs of
+// region repair blocks wrapping it are negative.
+func validBlockCode(c Codec, id int64) bool {
+	return blockCodeWithin(c, id, MaxRaptorESI)
+}
+
+// distinctBlockCode reports whether the code block of codec c with the given
+// ID is one no smaller ID composes: for the raptor codec, whether the ID is
+// an ESI up to MaxDistinctRaptorESI.
+func distinctBlockCode(c Codec, id int64) bool {
+	return blockCodeWithin(c, id, MaxDistinctRaptorESI)
+}
+
+// blockCodeWithin reports whether codec c can compose the code block with the
+// given ID, bounding the raptor codec's IDs to [0, maxESI].
+func blockCodeWithin(c Codec, id, maxESI int64) bool {
+	for {
+		switch w := c.(type) {
+		case *raptorCodec:
+			return id >= 0 && id <= maxESI
+		case *roiCodec:
+			if id < 0 {
+				return true
+			}
+			c = w.Codec
+			continue
+		}
+		w, ok := c.(codecWrapper)
+		if !ok {
+			return true
+		}
+		c = w.unwrap()
+	}
+}
+
+// checkBlockCodes returns an error if codec c can't compose the code block
+// with one of the IDs.
+func checkBlockCodes(c Codec, ids []int64) error {
+	for _, id := range ids {
+		if !validBlockCode(c, id) {
+			return fmt.Errorf("%w: code block ID %d is outside [0, %d]", ErrNonCompliant, id, MaxRaptorESI)
+		}
+	}
+	return nil
+}
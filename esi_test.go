@@ -0,0 +1,128 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRepairESIAllocator(t *testing.T) {
+	k := MaxDistinctRaptorESI - 999
+	a := NewRepairESIAllocator(k, rand.New(rand.NewSource(3)))
+	if a.Remaining() != 1000 {
+		t.Fatalf("Remaining() = %d, want 1000", a.Remaining())
+	}
+
+	seen := make(map[int64]bool)
+	ids, err := a.NextN(600)
+	if err != nil {
+		t.Fatalf("NextN(600) failed: %v", err)
+	}
+	if _, err := a.NextN(401); err != ErrESIExhausted {
+		t.Errorf("NextN(401) with 400 left gave %v, want ErrESIExhausted", err)
+	}
+	for a.Remaining() > 0 {
+		id, err := a.Next()
+		if err != nil {
+			t.Fatalf("Next failed with %d remaining: %v", a.Remaining(), err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		if id < int64(k) || id > MaxDistinctRaptorESI || seen[id] {
+			t.Errorf("Allocated ESI %d out of range or twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 1000 {
+		t.Errorf("Allocated %d distinct ESIs, want 1000", len(seen))
+	}
+	if _, err := a.Next(); err != ErrESIExhausted {
+		t.Errorf("Next on exhausted allocator gave %v, want ErrESIExhausted", err)
+	}
+
+	// The order is shuffled, not sequential.
+	sequential := 0
+	for i := 1; i < 600; i++ {
+		if ids[i] == ids[i-1]+1 {
+			sequential++
+		}
+	}
+	if sequential > 10 {
+		t.Errorf("%d of 600 allocated ESIs followed their predecessor", sequential)
+	}
+}
+
+func TestMaxDistinctRaptorESI(t *testing.T) {
+	c := NewRaptorCodec(10, 4)
+	for esi := int64(MaxDistinctRaptorESI + 1); esi <= MaxRaptorESI; esi++ {
+		alias := esi - (MaxDistinctRaptorESI + 1)
+		if got, want := c.PickIndices(esi), c.PickIndices(alias); !reflect.DeepEqual(got, want) {
+			t.Errorf("PickIndices(%d) = %v, want those of ESI %d, %v", esi, got, alias, want)
+		}
+	}
+}
+
+func TestLastBlockCode(t *testing.T) {
+	raptor := NewRaptorCodec(10, 4)
+	for _, test := range []struct {
+		c    Codec
+		want int64
+	}{
+		{raptor, MaxDistinctRaptorESI},
+		{NewAuthenticatedCodec(raptor, &SymbolMAC{}), MaxDistinctRaptorESI},
+		{NewRU10Codec(10, 4), math.MaxInt64},
+		{NewBinaryCodec(10), math.MaxInt64},
+	} {
+		if got := LastBlockCode(test.c); got != test.want {
+			t.Errorf("LastBlockCode(%T) = %d, want %d", test.c, got, test.want)
+		}
+	}
+}
+
+func TestShuffledSystematicOrder(t *testing.T) {
+	order := ShuffledSystematicOrder(1000, 42)
+	seen := make(map[int64]bool)
+	for _, id := range order {
+		if id < 0 || id >= 1000 || seen[id] {
+			t.Fatalf("Order has out of range or repeated ID %d", id)
+		}
+		seen[id] = true
+	}
+	if again := ShuffledSystematicOrder(1000, 42); !reflect.DeepEqual(order, again) {
+		t.Errorf("Order isn't deterministic for a fixed seed")
+	}
+	if other := ShuffledSystematicOrder(1000, 43); reflect.DeepEqual(order, other) {
+		t.Errorf("Orders for different seeds are identical")
+	}
+
+	// A burst of 20 lost packets rarely takes out neighbouring symbols.
+	lost := make(map[int64]bool)
+	for _, id := range order[500:520] {
+		lost[id] = true
+	}
+	adjacent := 0
+	for id := range lost {
+		if lost[id+1] {
+			adjacent++
+		}
+	}
+	if adjacent > 2 {
+		t.Errorf("Burst lost %d pairs of adjacent symbols", adjacent)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountainnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+
+	fountain "github.com/google/gofountain"
+)
+
+// A data carousel broadcasts a set of objects over and over, to receivers
+// which tune in at any time and have no way to ask for what they missed. Each
+// cycle, a Carousel sends every object's source symbols, which a receiver with
+// no losses decodes without any work, followed by a batch of repair symbols it
+// hasn't sent before, so that a receiver which keeps listening over several
+// cycles collects distinct symbols to make up for its losses.
+
+// CarouselConfig configures a Carousel.
+type CarouselConfig struct {
+	// SymbolsPerPacket is the number of symbols packed in each packet (G). If
+	// zero, each packet holds one symbol.
+	SymbolsPerPacket int
+
+	// BytesPerSecond limits the rate packets are written at, until changed
+	// with SetRate. If zero, packets are written as fast as the connection
+	// accepts them.
+	BytesPerSecond float64
+
+	// RepairRatio is the number of repair symbols sent in each cycle for each
+	// source symbol of an object.
+	RepairRatio float64
+
+	// OnCycle, if set, is called by Run after each cycle through the objects,
+	// with the number of cycles completed. It may add and remove objects, to
+	// rotate them through the carousel, and change the rate.
+	OnCycle func(cycle int)
+}
+
+// errRemoved is returned by Carousel.send when the object is removed while
+// it is being sent.
+var errRemoved = errors.New("fountainnet: object removed from carousel")
+
+// carouselObject is an object on a carousel.
+type carouselObject struct {
+	sbn     uint16
+	encoder *fountain.SystematicEncoder
+
+	// source holds the source symbols, padded to the symbol size.
+	source []fountain.LTBlock
+
+	// repair is the ESI of the next repair symbol to send.
+	repair int64
+
+	// removed is set once the object has been removed.
+	removed bool
+}
+
+// Carousel endlessly broadcasts the source and repair symbols of a set of
+// objects encoded with the raptor codec. Its methods may be called from any
+// goroutine, including while Run is running.
+type Carousel struct {
+	conn   net.PacketConn
+	addr   net.Addr
+	config CarouselConfig
+	pacer  pacer
+
+	mu      sync.Mutex
+	objects []*carouselObject
+	rate    float64
+
+	// added is signalled when an object is added.
+	added chan struct{}
+}
+
+// NewCarousel creates a carousel writing to addr over conn. It starts with
+// no objects.
+func NewCarousel(conn net.PacketConn, addr net.Addr, config CarouselConfig) (*Carousel, error) {
+	if config.SymbolsPerPacket == 0 {
+		config.SymbolsPerPacket = 1
+	}
+	if config.SymbolsPerPacket < 0 || config.BytesPerSecond < 0 || config.RepairRatio < 0 {
+		return nil, fmt.Errorf("fountainnet: invalid carousel config %+v", config)
+	}
+	return &Carousel{conn: conn, addr: addr, config: config, rate: config.BytesPerSecond,
+		added: make(chan struct{}, 1)}, nil
+}
+
+// Add puts an object on the carousel as source block sbn, from the next cycle
+// on. Returns an error if the carousel already has an object with that
+// source block number.
+func (c *Carousel) Add(sbn uint16, e *fountain.SystematicEncoder) error {
+	source := e.SourceSymbols()
+	// The shorter source symbols come without their padding.
+	size := len(source[0].Data)
+	for i, b := range source {
+		if len(b.Data) < size {
+			data := make([]byte, size)
+			copy(data, b.Data)
+			source[i].Data = data
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, o := range c.objects {
+		if o.sbn == sbn {
+			return fmt.Errorf("fountainnet: carousel already has source block %d", sbn)
+		}
+	}
+	c.objects = append(c.objects, &carouselObject{sbn: sbn, encoder: e, source: source,
+		repair: int64(len(source))})
+	select {
+	case c.added <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Remove takes source block sbn off the carousel. It stops being sent by the
+// next packet. Returns false if the carousel has no such object.
+func (c *Carousel) Remove(sbn uint16) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, o := range c.objects {
+		if o.sbn == sbn {
+			o.removed = true
+			c.objects = append(c.objects[:i:i], c.objects[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetRate changes the rate packets are written at, in bytes per second. A
+// rate of zero writes them as fast as the connection accepts them.
+func (c *Carousel) SetRate(bytesPerSecond float64) error {
+	if bytesPerSecond < 0 {
+		return fmt.Errorf("fountainnet: carousel rate %v is negative", bytesPerSecond)
+	}
+	c.mu.Lock()
+	c.rate = bytesPerSecond
+	c.mu.Unlock()
+	return nil
+}
+
+// Run broadcasts the objects, cycle after cycle, until ctx is done or writing
+// fails, and returns the error. While the carousel has no objects, it waits
+// for one to be added. Run may only be called from one goroutine at a time.
+func (c *Carousel) Run(ctx context.Context) error {
+	for cycle := 1; ; cycle++ {
+		c.mu.Lock()
+		objects := c.objects
+		c.mu.Unlock()
+		if len(objects) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-c.added:
+			}
+			cycle--
+			continue
+		}
+		for _, o := range objects {
+			if err := c.send(ctx, o); err != nil && err != errRemoved {
+				return err
+			}
+		}
+		if c.config.OnCycle != nil {
+			c.config.OnCycle(cycle)
+		}
+	}
+}
+
+// send writes one cycle's packets of an object.
+func (c *Carousel) send(ctx context.Context, o *carouselObject) error {
+	g := c.config.SymbolsPerPacket
+	for i := 0; i < len(o.source); i += g {
+		if err := c.write(ctx, o, o.source[i:min(i+g, len(o.source))]); err != nil {
+			return err
+		}
+	}
+
+	k := int64(len(o.source))
+	repair := int(math.Ceil(c.config.RepairRatio * float64(k)))
+	blocks := make([]fountain.LTBlock, 0, g)
+	for n := 0; n < repair; n += len(blocks) {
+		// A packet's ESIs must be consecutive, so once the ESIs of distinct
+		// code blocks run out, start again from the first repair symbol.
+		if o.repair+int64(g)-1 > fountain.MaxDistinctRaptorESI {
+			o.repair = k
+		}
+		blocks = blocks[:0]
+		for len(blocks) < min(g, repair-n) {
+			b, err := o.encoder.Repair(o.repair)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, b)
+			o.repair++
+		}
+		if err := c.write(ctx, o, blocks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write paces and writes a packet of an object's symbols. Returns errRemoved
+// if the object has been removed.
+func (c *Carousel) write(ctx context.Context, o *carouselObject, blocks []fountain.LTBlock) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	removed, rate := o.removed, c.rate
+	c.mu.Unlock()
+	if removed {
+		return errRemoved
+	}
+	packet, err := fountain.AppendPacket(nil, o.sbn, blocks)
+	if err != nil {
+		return err
+	}
+	if err := c.pacer.wait(ctx, len(packet), rate); err != nil {
+		return err
+	}
+	_, err = c.conn.WriteTo(packet, c.addr)
+	return err
+}
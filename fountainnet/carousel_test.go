@@ -0,0 +1,109 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountainnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+func TestCarousel(t *testing.T) {
+	c := fountain.NewRaptorCodec(50, 4)
+	messages := [][]byte{make([]byte, 1000), make([]byte, 990)}
+	for i, m := range messages {
+		for j := range m {
+			m[j] = byte(i + j*3)
+		}
+	}
+
+	out, in := listen(t), listen(t)
+	cycles := make(chan int, 100)
+	carousel, err := NewCarousel(out, in.LocalAddr(), CarouselConfig{
+		SymbolsPerPacket: 4,
+		RepairRatio:      0.2,
+		BytesPerSecond:   1e6,
+		OnCycle:          func(cycle int) { cycles <- cycle },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range messages {
+		e, err := fountain.NewSystematicEncoder(c, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := carousel.Add(uint16(i), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, _ := fountain.NewSystematicEncoder(c, messages[0])
+	if err := carousel.Add(0, e); err == nil {
+		t.Errorf("Added source block 0 twice")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- carousel.Run(ctx) }()
+
+	// Receive the second object, whose symbols are 20 bytes long.
+	d := c.NewDecoder(len(messages[1]))
+	r := NewReceiver(in, d, 1, 20)
+	if err := r.Receive(ctx); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if got := d.Decode(); !bytes.Equal(got, messages[1]) {
+		t.Errorf("Decoded the wrong message")
+	}
+	if r.Packets == 0 || r.Rejected == 0 {
+		t.Errorf("Packets, Rejected = %d, %d; want packets of both objects", r.Packets, r.Rejected)
+	}
+
+	// Keep cycling with only the first object.
+	if !carousel.Remove(1) || carousel.Remove(1) {
+		t.Errorf("Remove(1) didn't remove the object exactly once")
+	}
+	if err := carousel.SetRate(-1); err == nil {
+		t.Errorf("SetRate accepted a negative rate")
+	}
+	if n, next := <-cycles, <-cycles; next != n+1 {
+		t.Errorf("Cycle %d followed cycle %d", next, n)
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+}
+
+func TestCarouselWaitsForObjects(t *testing.T) {
+	out, in := listen(t), listen(t)
+	carousel, err := NewCarousel(out, in.LocalAddr(), CarouselConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := carousel.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run with no objects = %v, want context.DeadlineExceeded", err)
+	}
+	if _, err := NewCarousel(out, in.LocalAddr(), CarouselConfig{RepairRatio: -1}); err == nil {
+		t.Errorf("NewCarousel accepted a negative repair ratio")
+	}
+}
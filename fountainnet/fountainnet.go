@@ -0,0 +1,217 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fountainnet carries fountain-coded objects over datagram networks.
+//
+// A Sender generates code blocks of one source block, packs runs of
+// consecutive ESIs into RFC 5053 packets (a FEC Payload ID followed by the
+// symbols) and writes them to a net.PacketConn, optionally paced to a given
+// rate. A Receiver reads packets from a net.PacketConn, feeds their symbols to
+// a decoder and signals when the object can be decoded. A Carousel broadcasts
+// a changing set of objects over and over, for receivers which may tune in at
+// any time.
+package fountainnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+// maxDatagram is the largest datagram a Receiver reads.
+const maxDatagram = 65535
+
+// SenderConfig configures a Sender.
+type SenderConfig struct {
+	// SBN is the source block number written in the packets' Payload IDs.
+	SBN uint16
+
+	// SymbolsPerPacket is the number of symbols packed in each packet (G). If
+	// zero, each packet holds one symbol.
+	SymbolsPerPacket int
+
+	// BytesPerSecond limits the rate packets are written at. If zero, packets
+	// are written as fast as the connection accepts them.
+	BytesPerSecond float64
+
+	// FirstESI is the ESI of the first symbol sent. The last is
+	// fountain.MaxDistinctRaptorESI, past which the raptor codec's code blocks
+	// repeat earlier ones.
+	FirstESI int64
+}
+
+// Sender writes the code blocks of an encoder to a network address.
+type Sender struct {
+	conn    net.PacketConn
+	addr    net.Addr
+	encoder fountain.Encoder
+	config  SenderConfig
+
+	// esi is the ESI of the next symbol to send.
+	esi int64
+
+	pacer pacer
+
+	// Packets counts the packets written.
+	Packets int
+}
+
+// NewSender creates a sender of the code blocks of e to addr over conn.
+func NewSender(conn net.PacketConn, addr net.Addr, e fountain.Encoder, config SenderConfig) (*Sender, error) {
+	if config.SymbolsPerPacket == 0 {
+		config.SymbolsPerPacket = 1
+	}
+	if config.SymbolsPerPacket < 0 || config.BytesPerSecond < 0 ||
+		config.FirstESI < 0 || config.FirstESI > fountain.MaxDistinctRaptorESI {
+		return nil, fmt.Errorf("fountainnet: invalid sender config %+v", config)
+	}
+	return &Sender{conn: conn, addr: addr, encoder: e, config: config, esi: config.FirstESI}, nil
+}
+
+// Send writes the next n packets, or until the ESIs are exhausted (see
+// SenderConfig.FirstESI) or ctx is done. If n is negative, it writes packets
+// until then. Returns fountain.ErrNonCompliant (wrapped) once the ESIs are
+// exhausted.
+func (s *Sender) Send(ctx context.Context, n int) error {
+	for i := 0; n < 0 || i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if s.esi > fountain.MaxDistinctRaptorESI {
+			return fmt.Errorf("%w: ESIs exhausted", fountain.ErrNonCompliant)
+		}
+		blocks := make([]fountain.LTBlock, 0, s.config.SymbolsPerPacket)
+		for ; len(blocks) < cap(blocks) && s.esi <= fountain.MaxDistinctRaptorESI; s.esi++ {
+			blocks = append(blocks, s.encoder.Generate(s.esi))
+		}
+		packet, err := fountain.AppendPacket(nil, s.config.SBN, blocks)
+		if err != nil {
+			return err
+		}
+		if err := s.pacer.wait(ctx, len(packet), s.config.BytesPerSecond); err != nil {
+			return err
+		}
+		if _, err := s.conn.WriteTo(packet, s.addr); err != nil {
+			return err
+		}
+		s.Packets++
+	}
+	return nil
+}
+
+// pacer spaces out packets to keep within a rate.
+type pacer struct {
+	// next is the earliest time the next packet may be written.
+	next time.Time
+}
+
+// wait waits until a packet of the given size may be written at
+// bytesPerSecond, or returns ctx's error if it is done first. A rate of zero
+// doesn't wait.
+func (p *pacer) wait(ctx context.Context, size int, bytesPerSecond float64) error {
+	if bytesPerSecond == 0 {
+		return nil
+	}
+	now := time.Now()
+	if wait := p.next.Sub(now); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	} else {
+		p.next = now
+	}
+	p.next = p.next.Add(time.Duration(float64(size) / bytesPerSecond * float64(time.Second)))
+	return nil
+}
+
+// Receiver feeds the packets of one source block arriving on a connection to
+// a decoder.
+type Receiver struct {
+	conn       net.PacketConn
+	decoder    fountain.Decoder
+	sbn        uint16
+	symbolSize int
+	done       chan struct{}
+
+	// Packets counts the packets read, and Rejected those of them which
+	// weren't packets of the source block.
+	Packets, Rejected int
+}
+
+// NewReceiver creates a receiver feeding symbols of symbolSize bytes of
+// source block sbn, read from conn, to d.
+func NewReceiver(conn net.PacketConn, d fountain.Decoder, sbn uint16, symbolSize int) *Receiver {
+	return &Receiver{conn: conn, decoder: d, sbn: sbn, symbolSize: symbolSize, done: make(chan struct{})}
+}
+
+// Done returns a channel which is closed once the object can be decoded.
+func (r *Receiver) Done() <-chan struct{} {
+	return r.done
+}
+
+// Decoder returns the receiver's decoder.
+func (r *Receiver) Decoder() fountain.Decoder {
+	return r.decoder
+}
+
+// Receive reads packets until the object can be decoded, and then returns
+// nil. Returns ctx's error if it is done first, or the connection's error if
+// reading fails. Receive may only be called from one goroutine at a time.
+func (r *Receiver) Receive(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return nil
+	default:
+	}
+	// Unblock the pending read when ctx is done.
+	stop := context.AfterFunc(ctx, func() { r.conn.SetReadDeadline(time.Now()) })
+	defer func() {
+		if !stop() {
+			r.conn.SetReadDeadline(time.Time{})
+		}
+	}()
+	buf := make([]byte, maxDatagram)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					return ctx.Err()
+				}
+			}
+			return err
+		}
+		r.Packets++
+		// The decoder takes ownership of the symbols' data, so it mustn't
+		// refer to the buffer.
+		sbn, blocks, err := fountain.ParsePacket(append([]byte(nil), buf[:n]...), r.symbolSize)
+		if err != nil || sbn != r.sbn {
+			r.Rejected++
+			continue
+		}
+		if r.decoder.AddBlocks(blocks) {
+			close(r.done)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountainnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+func listen(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSendReceive(t *testing.T) {
+	message := make([]byte, 4000)
+	for i := range message {
+		message[i] = byte(i * 7)
+	}
+	c := fountain.NewRU10Codec(100, 4)
+	for _, g := range []int{1, 4} {
+		out, in := listen(t), listen(t)
+		s, err := NewSender(out, in.LocalAddr(), c.NewEncoder(message), SenderConfig{SBN: 3, SymbolsPerPacket: g})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := c.NewDecoder(len(message))
+		r := NewReceiver(in, d, 3, len(message)/100)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		errc := make(chan error, 1)
+		go func() { errc <- r.Receive(ctx) }()
+		// Loopback datagrams may still be dropped when the socket buffer
+		// fills up, so keep sending until the receiver is done.
+		for done := false; !done; {
+			if err := s.Send(ctx, 20); err != nil {
+				t.Fatalf("G=%d: Send failed: %v", g, err)
+			}
+			select {
+			case <-r.Done():
+				done = true
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("G=%d: Receive failed: %v", g, err)
+		}
+		cancel()
+		if got := d.Decode(); !bytes.Equal(got, message) {
+			t.Errorf("G=%d: decoded the wrong message", g)
+		}
+		if r.Rejected != 0 {
+			t.Errorf("G=%d: %d packets rejected", g, r.Rejected)
+		}
+	}
+}
+
+func TestReceiverRejects(t *testing.T) {
+	out, in := listen(t), listen(t)
+	c := fountain.NewRU10Codec(10, 4)
+	r := NewReceiver(in, c.NewDecoder(40), 1, 4)
+	s, _ := NewSender(out, in.LocalAddr(), c.NewEncoder(make([]byte, 40)), SenderConfig{SBN: 2})
+	if err := s.Send(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	out.WriteTo([]byte{1}, in.LocalAddr())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := r.Receive(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Receive = %v, want context.DeadlineExceeded", err)
+	}
+	if r.Packets != 2 || r.Rejected != 2 {
+		t.Errorf("Packets, Rejected = %d, %d, want 2, 2", r.Packets, r.Rejected)
+	}
+}
+
+func TestSenderPacing(t *testing.T) {
+	out, in := listen(t), listen(t)
+	c := fountain.NewRU10Codec(10, 4)
+	// Packets of 4+4 bytes at 800 bytes/s: 10ms each.
+	s, _ := NewSender(out, in.LocalAddr(), c.NewEncoder(make([]byte, 40)), SenderConfig{BytesPerSecond: 800})
+	start := time.Now()
+	if err := s.Send(context.Background(), 11); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("sent 11 paced packets in %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestSenderExhaustsESIs(t *testing.T) {
+	out, in := listen(t), listen(t)
+	c := fountain.NewRU10Codec(10, 4)
+	s, _ := NewSender(out, in.LocalAddr(), c.NewEncoder(make([]byte, 40)),
+		SenderConfig{SymbolsPerPacket: 2, FirstESI: fountain.MaxDistinctRaptorESI - 2})
+	if err := s.Send(context.Background(), 3); !errors.Is(err, fountain.ErrNonCompliant) {
+		t.Errorf("Send past the last distinct ESI = %v, want ErrNonCompliant", err)
+	}
+	if s.Packets != 2 {
+		t.Errorf("sent %d packets, want 2", s.Packets)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+// MatrixGaps describes which rows of a decoder's equation matrix are still
+// empty, grouped by the kind of (precode) block each row solves for.
+// Missing source rows just need more code blocks. Missing auxiliary rows are
+// harmless by themselves: Online and RU10 codes only need the source blocks
+// recovered, and an auxiliary block is only needed if a source block's
+// equation refers to it (see Blocked).
+//
+// A row holds the equation whose leading (lowest) block it is, not
+// necessarily an equation of the block of its own kind. The R10 raptor
+// codec's decoder starts with an equation for each LDPC symbol and
+// half-symbol, each of which holds a parity block no other one does, so none
+// of them is ever missing; the empty rows of its matrix are the intermediate
+// symbols which no equation leads with yet, and all need code blocks.
+type MatrixGaps struct {
+	// Source lists the empty rows among the first SourceBlocks() rows. For
+	// the R10 raptor codec, whose code blocks refer to all L intermediate
+	// symbols, it lists the empty rows among all of them.
+	Source []int
+
+	// Blocked lists the populated source rows which still can't be solved
+	// because their equation refers to a block whose row is empty.
+	Blocked []int
+
+	// Auxiliary lists the empty rows of Online code auxiliary blocks, or of the
+	// RU10 codec's precode parity blocks.
+	Auxiliary []int
+
+	// LDPC is always empty: the R10 raptor codec's empty rows are all listed
+	// in Source.
+	//
+	// Deprecated: the rows of a decode matrix aren't those of the block of
+	// their index, so no row is an LDPC row.
+	LDPC []int
+
+	// HalfSymbol is always empty, like LDPC.
+	//
+	// Deprecated: the rows of a decode matrix aren't those of the block of
+	// their index, so no row is a half-symbol row.
+	HalfSymbol []int
+}
+
+// Determined reports whether the gaps leave the source message recoverable.
+// Only auxiliary gaps are tolerated.
+func (g MatrixGaps) Determined() bool {
+	return len(g.Source) == 0 && len(g.Blocked) == 0
+}
+
+// Missing returns the total number of empty rows. The rank of the decode
+// matrix is its number of rows less this number.
+func (g MatrixGaps) Missing() int {
+	return len(g.Source) + len(g.Auxiliary)
+}
+
+// gapReporter is implemented by the package's decoders.
+type gapReporter interface {
+	gaps() MatrixGaps
+}
+
+// DecoderGaps reports the empty rows in the equation matrix of a decoder
+// created by one of the package's codecs. The second return value is false if
+// the decoder is of an unknown type.
+func DecoderGaps(d Decoder) (MatrixGaps, bool) {
+	if r, ok := d.(gapReporter); ok {
+		return r.gaps(), true
+	}
+	return MatrixGaps{}, false
+}
+
+// missing returns the indices of the empty rows in the matrix, in ascending
+// order, that are at or above "from" and below "to".
+func (m *sparseMatrix) missing(from, to int) []int {
+	var rows []int
+	for i := from; i < to; i++ {
+		if len(m.coeff[i]) == 0 {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+// blocked returns the indices of the populated rows below n which can't be
+// solved because they depend on an empty row.
+func (m *sparseMatrix) blocked(n int) []int {
+	ok := m.solvable()
+	var rows []int
+	for i := 0; i < n; i++ {
+		if len(m.coeff[i]) > 0 && !ok[i] {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+func (d *lubyDecoder) gaps() MatrixGaps {
+	n := len(d.matrix.coeff)
+	return MatrixGaps{Source: d.matrix.missing(0, n), Blocked: d.matrix.blocked(n)}
+}
+
+func (d *binaryDecoder) gaps() MatrixGaps {
+	n := len(d.matrix.coeff)
+	return MatrixGaps{Source: d.matrix.missing(0, n), Blocked: d.matrix.blocked(n)}
+}
+
+func (d *onlineDecoder) gaps() MatrixGaps {
+	n := d.codec.numSourceBlocks
+	return MatrixGaps{
+		Source:    d.matrix.missing(0, n),
+		Blocked:   d.matrix.blocked(n),
+		Auxiliary: d.matrix.missing(n, len(d.matrix.coeff)),
+	}
+}
+
+func (d *raptorDecoder) gaps() MatrixGaps {
+	n := len(d.matrix.coeff)
+	return MatrixGaps{Source: d.matrix.missing(0, n), Blocked: d.matrix.blocked(n)}
+}
+
+func (d *ru10Decoder) gaps() MatrixGaps {
+	// The RU10 source blocks are the first K intermediate blocks, so its parity
+	// blocks play the same role as Online code auxiliary blocks.
+	k := d.codec.numSourceSymbols
+	return MatrixGaps{
+		Source:    d.decoder.matrix.missing(0, k),
+		Blocked:   d.decoder.matrix.blocked(k),
+		Auxiliary: d.decoder.matrix.missing(k, len(d.decoder.matrix.coeff)),
+	}
+}
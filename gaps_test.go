@@ -0,0 +1,77 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatrixMissing(t *testing.T) {
+	m := sparseMatrix{coeff: make([][]int, 4), v: make([]block, 4)}
+	m.addEquation([]int{1, 3}, block{data: []byte{1}})
+	if got := m.missing(0, 4); !reflect.DeepEqual(got, []int{0, 2, 3}) {
+		t.Errorf("missing(0, 4) = %v, want [0 2 3]", got)
+	}
+	if got := m.missing(1, 3); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("missing(1, 3) = %v, want [2]", got)
+	}
+}
+
+func TestDecoderGaps(t *testing.T) {
+	c := NewRaptorCodec(10, 4)
+	d := c.NewDecoder(40)
+	gaps, ok := DecoderGaps(d)
+	if !ok {
+		t.Fatalf("DecoderGaps didn't recognize the raptor decoder")
+	}
+	// A fresh raptor decoder has its S+H constraint equations, so it needs
+	// K equations of code blocks, and no precode constraint is missing.
+	if gaps.Missing() != 10 || len(gaps.Source) != 10 {
+		t.Errorf("Fresh decoder is missing %d rows, want K=10 source rows: %+v", gaps.Missing(), gaps)
+	}
+	if len(gaps.LDPC) != 0 || len(gaps.HalfSymbol) != 0 {
+		t.Errorf("Fresh decoder reports precode gaps %+v", gaps)
+	}
+	for _, k := range []int{4, 20, 100, 1000} {
+		if gaps, _ := DecoderGaps(NewRaptorCodec(k, 4).NewDecoder(4 * k)); len(gaps.LDPC)+len(gaps.HalfSymbol) != 0 {
+			t.Errorf("Fresh K=%d decoder reports precode gaps LDPC %v, half-symbols %v", k, gaps.LDPC, gaps.HalfSymbol)
+		}
+	}
+	if gaps.Determined() {
+		t.Errorf("Fresh decoder reports no gaps")
+	}
+
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789abcd")
+	ids := make([]int64, 30)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	blocks := EncodeLTBlocksCopy(message, ids, c)
+	for i := range blocks {
+		if d.AddBlocks(blocks[i : i+1]) {
+			break
+		}
+	}
+	if gaps, _ = DecoderGaps(d); !gaps.Determined() {
+		t.Errorf("Determined decoder reports gaps %+v", gaps)
+	}
+
+	online := NewOnlineCodec(13, 0.3, 10, 0).NewDecoder(26)
+	gaps, _ = DecoderGaps(online)
+	if len(gaps.Source)+len(gaps.Auxiliary) != 13 || len(gaps.LDPC) != 0 {
+		t.Errorf("Fresh online decoder gaps = %+v, want 13 missing source/aux rows", gaps)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import "math"
+
+// Growth codes are LT codes for collecting data from sensor networks, where
+// what matters is recovering as much of the data as early as possible, rather
+// than needing few code blocks for all of it. Code blocks of degree one give a
+// receiver which knows nothing a new source block most often, but once it
+// knows much of the message, code blocks of higher degree are more likely to
+// decode one, since the blocks they combine are mostly known. So the degree
+// grows with the code block ID: degree i is used while the receiver is
+// expected to have recovered no more than R_i = (iN-1)/(i+1) of the N source
+// blocks, the point beyond which degree i+1 does better. See "Growth Codes:
+// Maximizing Sensor Network Data Persistence" -- A. Kamra et al. (2006)
+
+// NewGrowthCodec creates a seeded LT codec (see NewSeededLubyCodec) whose
+// code blocks follow the growth code degree schedule for sourceBlocks source
+// blocks: code block IDs count up from 0 in the order the code blocks are sent,
+// the first ones have degree one, and the degree goes up as the receiver is
+// expected to have recovered more. Until all of it can be decoded, a receiver
+// recovers much more of the message (see DecodePartial) than one receiving
+// code blocks of the robust soliton distribution, at the cost of needing
+// more code blocks for all of it. The degree stops growing at sourceBlocks/2,
+// so that code blocks stay diverse.
+func NewGrowthCodec(sourceBlocks int, seed int64) Codec {
+	return &lubyCodec{
+		sourceBlocks: sourceBlocks,
+		seed:         seed,
+		schedule:     growthSchedule(sourceBlocks),
+	}
+}
+
+// growthSchedule returns the code block IDs at which the degree of a growth
+// code over n source blocks goes up: entry i-1 is K_i, the expected number of
+// code blocks the receiver needs to recover R_i source blocks when the first
+// K_1 have degree one, the next K_2-K_1 degree two, and so on. With r source
+// blocks recovered, a code block of degree i recovers another if i-1 of its
+// blocks are among the r and the last isn't, so it takes on average
+// C(n, i) / (C(r, i-1) * (n-r)) code blocks to get from r to r+1.
+func growthSchedule(n int) []int64 {
+	maxDegree := max(1, n/2)
+	schedule := make([]int64, 0, maxDegree-1)
+	k, r := 0.0, 0
+	for i := 1; i < maxDegree; i++ {
+		ri := (i*n - 1) / (i + 1)
+		for ; r < ri; r++ {
+			if r < i-1 {
+				continue
+			}
+			k += math.Exp(logChoose(n, i) - logChoose(r, i-1) - math.Log(float64(n-r)))
+		}
+		schedule = append(schedule, int64(math.Ceil(k)))
+	}
+	return schedule
+}
+
+// logChoose returns the natural logarithm of the binomial coefficient
+// C(n, k).
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestGrowthSchedule(t *testing.T) {
+	const n = 100
+	schedule := growthSchedule(n)
+	if len(schedule) != n/2-1 {
+		t.Fatalf("len(schedule) = %d, want %d", len(schedule), n/2-1)
+	}
+	for i := 1; i < len(schedule); i++ {
+		if schedule[i] < schedule[i-1] {
+			t.Errorf("schedule[%d] = %d < schedule[%d] = %d", i, schedule[i], i-1, schedule[i-1])
+		}
+	}
+	// Recovering R_1 = 49 source blocks from degree one code blocks is coupon
+	// collecting: the sum of n/(n-r) for r < 49.
+	want := 0.0
+	for r := 0; r < 49; r++ {
+		want += float64(n) / float64(n-r)
+	}
+	if got := schedule[0]; got != int64(math.Ceil(want)) {
+		t.Errorf("K_1 = %d, want %v", got, math.Ceil(want))
+	}
+
+	c := NewGrowthCodec(n, 1)
+	for _, id := range []int64{0, schedule[0] - 1, schedule[0], schedule[1], schedule[len(schedule)-1] + 1000} {
+		want := 1
+		for _, k := range schedule {
+			if id >= k {
+				want++
+			}
+		}
+		if got := len(c.PickIndices(id)); got != want {
+			t.Errorf("code block %d has degree %d, want %d", id, got, want)
+		}
+	}
+}
+
+func TestGrowthCodecRecoversEarly(t *testing.T) {
+	const n = 100
+	message := make([]byte, 10*n)
+	for i := range message {
+		message[i] = byte(i * 7)
+	}
+	growth := NewGrowthCodec(n, 3)
+	lt := NewSeededLubyCodec(n, 3, RobustSolitonDistribution(n, 10, 0.5))
+
+	recovered := func(c Codec, blocks int) int {
+		d := c.NewDecoder(len(message))
+		e := c.NewEncoder(message)
+		for id := int64(0); id < int64(blocks); id++ {
+			d.AddBlocks([]LTBlock{e.Generate(id)})
+		}
+		_, ok := DecodePartial(d)
+		count := 0
+		for _, r := range ok {
+			if r {
+				count++
+			}
+		}
+		return count
+	}
+	for _, blocks := range []int{n / 4, n / 2} {
+		g, l := recovered(growth, blocks), recovered(lt, blocks)
+		t.Logf("after %d code blocks: growth code recovered %d, robust soliton %d", blocks, g, l)
+		if g <= l {
+			t.Errorf("after %d code blocks the growth code recovered %d source blocks, no more than %d", blocks, g, l)
+		}
+	}
+
+	// It still decodes the whole message.
+	d := growth.NewDecoder(len(message))
+	e := growth.NewEncoder(message)
+	for id := int64(0); !d.AddBlocks([]LTBlock{e.Generate(id)}); id++ {
+		if id > 20*n {
+			t.Fatalf("not decoded after %d code blocks", id)
+		}
+	}
+	if !bytes.Equal(d.Decode(), message) {
+		t.Error("decoded message differs")
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// LossModel decides which transmitted code blocks are lost in a simulated
+// channel. Implementations carry their own (seeded) random state so that runs
+// are reproducible.
+type LossModel interface {
+	// Lost reports whether the next transmitted block is dropped.
+	Lost() bool
+}
+
+// bernoulliLoss drops each block independently with a fixed probability.
+type bernoulliLoss struct {
+	p      float64
+	random *rand.Rand
+}
+
+// NewBernoulliLoss returns a LossModel which drops each block independently
+// with probability p, drawing from the given random source.
+func NewBernoulliLoss(p float64, random *rand.Rand) LossModel {
+	return &bernoulliLoss{p: p, random: random}
+}
+
+// Lost reports whether the next block is dropped.
+func (l *bernoulliLoss) Lost() bool {
+	return l.random.Float64() < l.p
+}
+
+// PropertyTest runs many encode/transmit/decode rounds of a codec over a
+// simulated lossy channel, checking that every decoded message is correct and
+// that decoding rarely needs more than a given reception overhead. It is meant
+// both for the package's own tests and for users validating their own degree
+// distributions or wrappers around the package's codecs.
+type PropertyTest struct {
+	// Codec is the codec under test.
+	Codec Codec
+
+	// Loss is the channel model applied to transmitted blocks. If nil, no
+	// blocks are lost.
+	Loss LossModel
+
+	// Rounds is the number of messages to send.
+	Rounds int
+
+	// MessageLength is the length in bytes of each random message.
+	MessageLength int
+
+	// MaxOverhead bounds the number of blocks a decoder may receive before it
+	// is counted as a failure: ceil((1+MaxOverhead)*SourceBlocks).
+	MaxOverhead float64
+
+	// MaxFailureRate is the fraction of rounds permitted to exceed the overhead
+	// bound before Run reports an error.
+	MaxFailureRate float64
+
+	// MaxSent bounds the number of blocks sent in a round, lost ones
+	// included, so that a round over a channel which loses (nearly) every
+	// block ends. Run reports an error if a round reaches it. If zero, it is
+	// 100 times the overhead bound.
+	MaxSent int
+}
+
+// PropertyTestResult summarizes the rounds run by a PropertyTest.
+type PropertyTestResult struct {
+	// Rounds is the number of rounds run.
+	Rounds int
+
+	// Failures is the number of rounds in which the decoder was not determined
+	// within the overhead bound.
+	Failures int
+
+	// Sent is the total number of blocks transmitted, including lost ones.
+	Sent int
+
+	// Received is the total number of blocks received by decoders which
+	// succeeded.
+	Received int
+
+	// MaxReceived is the largest number of blocks any successful decoder needed.
+	MaxReceived int
+}
+
+// MeanOverhead returns the average reception overhead of the successful rounds,
+// as a fraction of the number of source blocks.
+func (r PropertyTestResult) MeanOverhead(sourceBlocks int) float64 {
+	ok := r.Rounds - r.Failures
+	if ok == 0 || sourceBlocks == 0 {
+		return 0
+	}
+	return float64(r.Received)/float64(ok*sourceBlocks) - 1
+}
+
+// Run runs the rounds, drawing message contents and block IDs from random.
+// Given identically seeded random sources and loss model, a run is exactly
+// reproducible. Returns an error if any message decodes incorrectly, if a
+// round reaches MaxSent, or if the failure rate exceeds MaxFailureRate.
+func (p *PropertyTest) Run(random *rand.Rand) (PropertyTestResult, error) {
+	var result PropertyTestResult
+	k := p.Codec.SourceBlocks()
+	maxReceived := int(math.Ceil((1 + p.MaxOverhead) * float64(k)))
+	maxSent := p.MaxSent
+	if maxSent == 0 {
+		maxSent = 100 * maxReceived
+	}
+
+	for round := 0; round < p.Rounds; round++ {
+		result.Rounds++
+		message := make([]byte, p.MessageLength)
+		for i := range message {
+			message[i] = byte(random.Intn(256))
+		}
+
+		// Consecutive IDs from a random start stay distinct (modulo the raptor
+		// codec's 16-bit ESI space) for any plausible number of transmissions.
+		start := int64(random.Intn(65536))
+		e := p.Codec.NewEncoder(message)
+		d := p.Codec.NewDecoder(len(message))
+		received, sent := 0, 0
+		determined := false
+		for !determined && received < maxReceived && sent < maxSent {
+			b := e.Generate((start + int64(sent)) % 65536)
+			sent++
+			if p.Loss != nil && p.Loss.Lost() {
+				continue
+			}
+			received++
+			determined = d.AddBlocks([]LTBlock{b})
+		}
+		result.Sent += sent
+		if !determined && received < maxReceived {
+			return result, fmt.Errorf("round %d: %d blocks sent, of which only %d were received", round, sent, received)
+		}
+
+		if !determined {
+			result.Failures++
+			continue
+		}
+		result.Received += received
+		if received > result.MaxReceived {
+			result.MaxReceived = received
+		}
+		if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+			return result, fmt.Errorf("round %d: decoded message differs from the original", round)
+		}
+	}
+
+	if float64(result.Failures) > p.MaxFailureRate*float64(result.Rounds) {
+		return result, fmt.Errorf("%d of %d rounds needed more than %d blocks",
+			result.Failures, result.Rounds, maxReceived)
+	}
+	return result, nil
+}
+
+// patternLoss drops blocks according to a repeating pattern.
+type patternLoss struct {
+	pattern []bool
+	next    int
+}
+
+// NewPatternLoss returns a LossModel which drops the n'th transmitted block if
+// pattern[n%len(pattern)] is true. It is useful for reproducing specific
+// burst losses.
+func NewPatternLoss(pattern []bool) LossModel {
+	return &patternLoss{pattern: pattern}
+}
+
+// Lost reports whether the next block is dropped.
+func (l *patternLoss) Lost() bool {
+	if len(l.pattern) == 0 {
+		return false
+	}
+	lost := l.pattern[l.next%len(l.pattern)]
+	l.next++
+	return lost
+}
+
+// ConformanceMessages returns the messages every codec with k source blocks
+// must round-trip exactly: the boundary lengths 0, 1, k-1, k and k+1 bytes,
+// and a few longer lengths which don't divide evenly into k blocks. The
+// contents are a fixed non-repeating byte pattern, so misplaced blocks show up.
+func ConformanceMessages(k int) [][]byte {
+	lengths := []int{0, 1, k - 1, k, k + 1, 2*k + 1, 7*k - 3, 100*k + k/2}
+	var messages [][]byte
+	seen := make(map[int]bool)
+	for _, n := range lengths {
+		if n < 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		message := make([]byte, n)
+		for i := range message {
+			message[i] = byte(i*7 + i/251)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// RoundTrip encodes the message with the codec, transmits code blocks with IDs
+// 0, 1, 2, ... over the lossy channel (nil for none) until the decoder is
+// determined or maxBlocks blocks have been sent, and checks that the decoded
+// message is byte-for-byte identical to the original. Code which wraps the
+// package's codecs can use it, together with ConformanceMessages, to check
+// that the wrapping preserves messages exactly.
+func RoundTrip(c Codec, message []byte, loss LossModel, maxBlocks int) error {
+	ids := make([]int64, maxBlocks)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	messageCopy := make([]byte, len(message))
+	copy(messageCopy, message)
+	blocks := EncodeLTBlocks(messageCopy, ids, c)
+
+	d := c.NewDecoder(len(message))
+	determined := false
+	for i := 0; i < len(blocks) && !determined; i++ {
+		if loss != nil && loss.Lost() {
+			continue
+		}
+		determined = d.AddBlocks(blocks[i : i+1])
+	}
+	if !determined {
+		return fmt.Errorf("%d-byte message not determined after %d blocks", len(message), maxBlocks)
+	}
+	decoded := d.Decode()
+	if len(decoded) != len(message) {
+		return fmt.Errorf("%d-byte message decoded to %d bytes", len(message), len(decoded))
+	}
+	if !bytes.Equal(decoded, message) {
+		return fmt.Errorf("%d-byte message decoded incorrectly", len(message))
+	}
+	return nil
+}
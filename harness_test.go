@@ -0,0 +1,115 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBernoulliLoss(t *testing.T) {
+	l := NewBernoulliLoss(0.25, rand.New(rand.NewSource(1)))
+	lost := 0
+	for i := 0; i < 10000; i++ {
+		if l.Lost() {
+			lost++
+		}
+	}
+	if lost < 2300 || lost > 2700 {
+		t.Errorf("Lost %d of 10000 blocks at p=0.25", lost)
+	}
+}
+
+func TestPropertyTest(t *testing.T) {
+	var propertyTests = []struct {
+		codec       Codec
+		maxOverhead float64
+	}{
+		{NewBinaryCodec(20), 0.5},
+		{NewOnlineCodec(20, 0.3, 10, 200), 2},
+		{NewRaptorCodec(20, 4), 0.5},
+		{NewRU10Codec(20, 4), 0.5},
+	}
+
+	for _, test := range propertyTests {
+		p := PropertyTest{
+			Codec:          test.codec,
+			Loss:           NewBernoulliLoss(0.2, rand.New(rand.NewSource(5))),
+			Rounds:         50,
+			MessageLength:  997,
+			MaxOverhead:    test.maxOverhead,
+			MaxFailureRate: 0.05,
+		}
+		result, err := p.Run(rand.New(rand.NewSource(8234982)))
+		if err != nil {
+			t.Errorf("%T: %v", test.codec, err)
+		}
+		if result.Rounds != 50 {
+			t.Errorf("%T: ran %d rounds, want 50", test.codec, result.Rounds)
+		}
+		t.Logf("%T: %+v, mean overhead %f", test.codec, result,
+			result.MeanOverhead(test.codec.SourceBlocks()))
+	}
+}
+
+func TestPropertyTestMaxSent(t *testing.T) {
+	for _, maxSent := range []int{0, 50} {
+		p := PropertyTest{
+			Codec:         NewRaptorCodec(20, 4),
+			Loss:          NewBernoulliLoss(1, rand.New(rand.NewSource(5))),
+			Rounds:        3,
+			MessageLength: 100,
+			MaxOverhead:   0.5,
+			MaxSent:       maxSent,
+		}
+		result, err := p.Run(rand.New(rand.NewSource(1)))
+		want := maxSent
+		if want == 0 {
+			want = 100 * 30
+		}
+		if err == nil || result.Sent != want {
+			t.Errorf("Run over a channel losing every block (MaxSent %d) = %+v, %v; want an error after %d blocks",
+				maxSent, result, err, want)
+		}
+	}
+}
+
+func TestPatternLoss(t *testing.T) {
+	l := NewPatternLoss([]bool{false, true, true})
+	want := []bool{false, true, true, false, true, true, false}
+	for i, w := range want {
+		if got := l.Lost(); got != w {
+			t.Errorf("Block %d lost = %t, want %t", i, got, w)
+		}
+	}
+}
+
+func TestRoundTripConformance(t *testing.T) {
+	codecs := []Codec{
+		NewLubyCodec(10, rand.New(NewMersenneTwister(200)), SolitonDistribution(10)),
+		NewBinaryCodec(10),
+		NewOnlineCodec(10, 0.3, 10, 200),
+		NewRaptorCodec(10, 1),
+		NewRU10Codec(10, 1),
+	}
+	for _, c := range codecs {
+		for _, message := range ConformanceMessages(c.SourceBlocks()) {
+			loss := NewPatternLoss([]bool{false, false, true, true, false})
+			if err := RoundTrip(c, message, loss, 500); err != nil {
+				t.Errorf("%T: %v", c, err)
+			}
+		}
+	}
+}
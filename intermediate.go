@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+)
+
+// IntermediateSymbols returns the intermediate symbols recovered by a
+// determined raptor or RU10 decoder: the precode expansion of the K source
+// symbols, from which all code blocks are composed. The code block with ID x is
+// the XOR of the symbols whose indices the codec's PickIndices(x) returns, so
+// a receiver can generate repair symbols, acting as a secondary seeder,
+// without re-running the precode on the decoded message. The symbols are
+// copies, all of the symbol length, and may be modified.
+func IntermediateSymbols(d Decoder) ([][]byte, error) {
+	var r *raptorDecoder
+	switch d := d.(type) {
+	case *raptorDecoder:
+		r = d
+	case *ru10Decoder:
+		r = d.decoder
+	default:
+		return nil, fmt.Errorf("fountain: IntermediateSymbols doesn't support %T", d)
+	}
+	if !r.matrix.complete {
+		return nil, newDecodeError(d)
+	}
+	r.matrix.reduce()
+	size := 0
+	for _, b := range r.matrix.v {
+		if b.length() > size {
+			size = b.length()
+		}
+	}
+	symbols := make([][]byte, len(r.matrix.v))
+	for i, b := range r.matrix.v {
+		symbols[i] = make([]byte, size)
+		copy(symbols[i], b.data)
+	}
+	return symbols, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodedIntermediateSymbols(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	for _, c := range []Codec{NewRaptorCodec(13, 2), NewRU10Codec(13, 2)} {
+		d := c.NewDecoder(len(message))
+		if _, err := IntermediateSymbols(d); err == nil {
+			t.Errorf("%T: IntermediateSymbols of an undetermined decoder succeeded", c)
+		}
+		for id := int64(0); !d.AddBlocks(EncodeLTBlocksCopy(message, []int64{id}, c)); id++ {
+		}
+
+		symbols, err := IntermediateSymbols(d)
+		if err != nil {
+			t.Fatalf("%T: IntermediateSymbols failed: %v", c, err)
+		}
+		for id := int64(100); id < 120; id++ {
+			repair := make([]byte, len(symbols[0]))
+			for _, i := range c.PickIndices(id) {
+				for j := range repair {
+					repair[j] ^= symbols[i][j]
+				}
+			}
+			want := EncodeLTBlocksCopy(message, []int64{id}, c)[0].Data
+			if !bytes.Equal(repair, want) {
+				t.Errorf("%T: code block %d from intermediate symbols is %x, want %x", c, id, repair, want)
+			}
+		}
+
+		// Modifying the copies doesn't affect the decoder.
+		symbols[0][0] ^= 0xff
+		if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+			t.Errorf("%T: decoded %q, want %q", c, decoded, message)
+		}
+	}
+
+	if _, err := IntermediateSymbols(NewBinaryCodec(4).NewDecoder(10)); err == nil {
+		t.Errorf("IntermediateSymbols accepted a binary decoder")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gf256 implements arithmetic in GF(2^8), with the reducing
+// polynomial x^8+x^4+x^3+x^2+1, for the Reed-Solomon codec of package
+// fountain and the BATS codec of package x/bats. Addition is XOR.
+package gf256
+
+import "crypto/subtle"
+
+// exp holds the powers of the generator 2, twice over so that the sum of two
+// logarithms can index it, and log their logarithms.
+var exp, log = tables()
+
+func tables() (exp [510]byte, log [256]byte) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	return exp, log
+}
+
+// Mul returns the product of a and b.
+func Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return exp[int(log[a])+int(log[b])]
+}
+
+// Inverse returns the multiplicative inverse of a, which must not be zero.
+func Inverse(a byte) byte {
+	return exp[255-int(log[a])]
+}
+
+// MulAdd adds f times src to dst, which must be at least as long.
+func MulAdd(dst, src []byte, f byte) {
+	switch f {
+	case 0:
+		return
+	case 1:
+		subtle.XORBytes(dst, dst[:len(src)], src)
+		return
+	}
+	lf := int(log[f])
+	for i, s := range src {
+		if s != 0 {
+			dst[i] ^= exp[int(log[s])+lf]
+		}
+	}
+}
+
+// Scale multiplies b by f.
+func Scale(b []byte, f byte) {
+	for i := range b {
+		b[i] = Mul(b[i], f)
+	}
+}
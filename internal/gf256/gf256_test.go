@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gf256
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestArithmetic(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if p := Mul(byte(a), Inverse(byte(a))); p != 1 {
+			t.Errorf("%d * inverse(%d) = %d, want 1", a, a, p)
+		}
+	}
+	// Check Mul against carry-less multiplication reduced by the polynomial.
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b += 7 {
+			var p int
+			for i := 0; i < 8; i++ {
+				if b&(1<<i) != 0 {
+					p ^= a << i
+				}
+			}
+			for i := 14; i >= 8; i-- {
+				if p&(1<<i) != 0 {
+					p ^= 0x11d << (i - 8)
+				}
+			}
+			if got := Mul(byte(a), byte(b)); got != byte(p) {
+				t.Fatalf("%d * %d = %d, want %d", a, b, got, p)
+			}
+		}
+	}
+	// Multiplication distributes over addition, which is XOR.
+	for a := 0; a < 256; a += 3 {
+		for b := 0; b < 256; b += 5 {
+			for c := 0; c < 256; c += 7 {
+				if Mul(byte(a), byte(b^c)) != Mul(byte(a), byte(b))^Mul(byte(a), byte(c)) {
+					t.Fatalf("%d * (%d + %d) isn't distributive", a, b, c)
+				}
+			}
+		}
+	}
+}
+
+func TestMulAdd(t *testing.T) {
+	for _, f := range []byte{0, 1, 7} {
+		dst := []byte{1, 2, 3, 9}
+		MulAdd(dst, []byte{4, 0, 6}, f)
+		want := []byte{1 ^ Mul(4, f), 2, 3 ^ Mul(6, f), 9}
+		if !bytes.Equal(dst, want) {
+			t.Errorf("MulAdd(%d) = %v, want %v", f, dst, want)
+		}
+	}
+	b := []byte{0, 1, 2}
+	Scale(b, 3)
+	if want := []byte{0, 3, Mul(2, 3)}; !bytes.Equal(b, want) {
+		t.Errorf("Scale = %v, want %v", b, want)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+// Progressive delivery sends an asset as a base layer (say, a thumbnail)
+// plus enhancement layers which refine it, each encoded as its own fountain
+// object, over a single stream. Every layer is decoded independently, so a
+// receiver can present the base layer as soon as it has enough of its
+// symbols, without waiting for the rest. Giving the base layer a larger share
+// of the stream gets it there sooner.
+
+// Layer is one layer of a layered object, for the sender.
+type Layer struct {
+	// Codec encodes the layer. Receivers need the same codec, and the length
+	// of Data.
+	Codec Codec
+
+	// Data is the layer's content. It is not modified.
+	Data []byte
+
+	// Weight is the layer's share of the stream, relative to the other
+	// layers' weights. Layers with weight zero or less are given weight one.
+	Weight int
+}
+
+// LayeredBlock is a code block of one layer of a layered object.
+type LayeredBlock struct {
+	// Layer is the index of the layer: 0 for the base layer.
+	Layer int
+
+	LTBlock
+}
+
+// LayeredEncoder generates the stream of code blocks of a layered object.
+type LayeredEncoder struct {
+	codecs   []Codec
+	encoders []Encoder
+	weights  []int
+	credit   []int
+	nextID   []int64
+}
+
+// NewLayeredEncoder creates an encoder for the given layers, the first of
+// which is the base layer.
+func NewLayeredEncoder(layers []Layer) *LayeredEncoder {
+	e := &LayeredEncoder{
+		codecs:   make([]Codec, len(layers)),
+		encoders: make([]Encoder, len(layers)),
+		weights:  make([]int, len(layers)),
+		credit:   make([]int, len(layers)),
+		nextID:   make([]int64, len(layers)),
+	}
+	for i, l := range layers {
+		e.codecs[i] = l.Codec
+		e.encoders[i] = l.Codec.NewEncoder(l.Data)
+		e.weights[i] = l.Weight
+		if e.weights[i] <= 0 {
+			e.weights[i] = 1
+		}
+	}
+	return e
+}
+
+// Next returns the next code block of the stream. Layers take turns in
+// proportion to their weights, interleaved as evenly as possible (smooth
+// weighted round robin, ties going to the lower layer), and each layer's code
+// blocks have consecutive IDs starting at zero. A layer whose codec has run
+// out of IDs of distinct code blocks, as the raptor codec does past
+// MaxDistinctRaptorESI, drops out of the rotation. Returns ErrESIExhausted
+// once every layer has.
+func (e *LayeredEncoder) Next() (LayeredBlock, error) {
+	total, best := 0, -1
+	for i, w := range e.weights {
+		if !distinctBlockCode(e.codecs[i], e.nextID[i]) {
+			continue
+		}
+		e.credit[i] += w
+		total += w
+		if best < 0 || e.credit[i] > e.credit[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return LayeredBlock{}, ErrESIExhausted
+	}
+	e.credit[best] -= total
+	id := e.nextID[best]
+	e.nextID[best]++
+	return LayeredBlock{Layer: best, LTBlock: e.encoders[best].Generate(id)}, nil
+}
+
+// LayeredDecoder decodes the layers of a layered object independently.
+type LayeredDecoder struct {
+	decoders []Decoder
+	done     []bool
+}
+
+// NewLayeredDecoder creates a decoder for a layered object whose layers were
+// encoded with the given codecs and have the given lengths.
+func NewLayeredDecoder(codecs []Codec, lengths []int) *LayeredDecoder {
+	d := &LayeredDecoder{
+		decoders: make([]Decoder, len(codecs)),
+		done:     make([]bool, len(codecs)),
+	}
+	for i, c := range codecs {
+		d.decoders[i] = c.NewDecoder(lengths[i])
+	}
+	return d
+}
+
+// AddBlocks adds code blocks to the decoders of their layers, and returns the
+// indices of the layers which became decodable as a result. Blocks of layers
+// the decoder doesn't have are ignored.
+func (d *LayeredDecoder) AddBlocks(blocks []LayeredBlock) []int {
+	var ready []int
+	for _, b := range blocks {
+		if b.Layer < 0 || b.Layer >= len(d.decoders) || d.done[b.Layer] {
+			continue
+		}
+		if d.decoders[b.Layer].AddBlocks([]LTBlock{b.LTBlock}) {
+			d.done[b.Layer] = true
+			ready = append(ready, b.Layer)
+		}
+	}
+	return ready
+}
+
+// Layer returns the decoded content of layer i, or nil if it can't be decoded
+// yet.
+func (d *LayeredDecoder) Layer(i int) []byte {
+	if !d.done[i] {
+		return nil
+	}
+	return d.decoders[i].Decode()
+}
+
+// Quality returns the number of layers, starting from the base layer, which
+// can all be decoded: the level of detail the receiver can present.
+func (d *LayeredDecoder) Quality() int {
+	n := 0
+	for n < len(d.done) && d.done[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestLayeredEncoderSchedule(t *testing.T) {
+	e := NewLayeredEncoder([]Layer{
+		{Codec: NewBinaryCodec(4), Data: []byte("base"), Weight: 3},
+		{Codec: NewBinaryCodec(4), Data: []byte("enhancement")},
+	})
+	var layers []int
+	var ids []int64
+	for i := 0; i < 8; i++ {
+		b, err := e.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers = append(layers, b.Layer)
+		ids = append(ids, b.BlockCode)
+	}
+	if want := []int{0, 0, 1, 0, 0, 0, 1, 0}; !reflect.DeepEqual(layers, want) {
+		t.Errorf("layers = %v, want %v", layers, want)
+	}
+	if want := []int64{0, 1, 0, 2, 3, 4, 1, 5}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestLayeredDelivery(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	base := make([]byte, 100)
+	enhancement := make([]byte, 2000)
+	random.Read(base)
+	random.Read(enhancement)
+	codecs := []Codec{NewRU10Codec(10, 1), NewRU10Codec(40, 1)}
+
+	e := NewLayeredEncoder([]Layer{
+		{Codec: codecs[0], Data: base, Weight: 2},
+		{Codec: codecs[1], Data: enhancement, Weight: 1},
+	})
+	d := NewLayeredDecoder(codecs, []int{len(base), len(enhancement)})
+	loss := NewBernoulliLoss(0.2, random)
+
+	var order []int
+	for sent := 0; d.Quality() < 2 && sent < 1000; sent++ {
+		b, err := e.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loss.Lost() {
+			continue
+		}
+		order = append(order, d.AddBlocks([]LayeredBlock{b})...)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(order, want) {
+		t.Errorf("layers became ready in order %v, want %v", order, want)
+	}
+	if !bytes.Equal(d.Layer(0), base) || !bytes.Equal(d.Layer(1), enhancement) {
+		t.Errorf("decoded layers differ from the originals")
+	}
+}
+
+func TestLayeredEncoderExhausted(t *testing.T) {
+	// The raptor layer runs out of ESIs of distinct code blocks, and the RU10
+	// layer carries on.
+	e := NewLayeredEncoder([]Layer{
+		{Codec: NewRaptorCodec(4, 1), Data: []byte("base")},
+		{Codec: NewRU10Codec(4, 1), Data: []byte("more")},
+	})
+	e.nextID[0] = MaxDistinctRaptorESI
+	e.nextID[1] = 1 << 40
+	var layers []int
+	for i := 0; i < 4; i++ {
+		b, err := e.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers = append(layers, b.Layer)
+	}
+	if want := []int{0, 1, 1, 1}; !reflect.DeepEqual(layers, want) {
+		t.Errorf("layers = %v, want %v", layers, want)
+	}
+
+	e = NewLayeredEncoder([]Layer{{Codec: NewRaptorCodec(4, 1), Data: []byte("base")}})
+	e.nextID[0] = MaxDistinctRaptorESI + 1
+	if _, err := e.Next(); err != ErrESIExhausted {
+		t.Errorf("Next past the last distinct ESI = %v, want ErrESIExhausted", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"slices"
+)
+
+// A code block is the XOR of a set of intermediate blocks. When a large batch
+// of repair blocks is generated from a message bigger than the CPU caches,
+// generating them in ID order touches intermediate blocks all over the
+// message for each code block. Generating consecutively code blocks whose sets
+// share many intermediate blocks keeps those in cache instead, and generating
+// the whole batch source block by source block reads each intermediate block
+// only once. A BatchOrder is an order to generate a batch of code blocks in;
+// every order produces the same code blocks. BenchmarkGenerateBatch compares
+// them on 256 repair blocks of an 8MB message. On a Xeon with a 2MB L2 cache,
+// whose L3 cache holds the whole message, SourceOrder took 53-60ms against
+// IDOrder's 69-73ms with the high-degree binary code, about 20% less, and the
+// same 2.2ms within noise with the low-degree RU10 code; LocalityOrder took
+// 5-15% longer than IDOrder with both. So IDOrder remains the default of
+// GenerateBatch, and the bulk paths, EncodeLTBlocks and the EncoderPool,
+// generate in SourceOrder.
+
+// BatchOrder is an order in which GenerateBatch generates code blocks.
+type BatchOrder interface {
+	// generate returns the code blocks of e with the given IDs, whose
+	// intermediate block indices are given, in the order of the IDs.
+	generate(e *ltEncoder, ids []int64, indices [][]int) []LTBlock
+}
+
+// idOrder generates code blocks in the order of their IDs in the batch.
+type idOrder struct{}
+
+// localityOrder chains code blocks greedily by the overlap of their sets.
+type localityOrder struct{}
+
+// sourceOrder iterates over the intermediate blocks in the outer loop.
+type sourceOrder struct{}
+
+// IDOrder generates code blocks in the order the batch lists them.
+var IDOrder BatchOrder = idOrder{}
+
+// LocalityOrder sorts the batch's index sets lexicographically, which clusters
+// sets sharing their lowest indices, then chains them greedily: the next code
+// block generated is, among a pool of the next few in sorted order, the one
+// sharing the most intermediate blocks with the current one.
+var LocalityOrder BatchOrder = localityOrder{}
+
+// SourceOrder generates the whole batch at once, reading each intermediate
+// block once and XORing it into every code block of the batch which refers to
+// it, instead of reading it again for each of them.
+var SourceOrder BatchOrder = sourceOrder{}
+
+// localityWindow is the number of candidates LocalityOrder considers for the
+// next code block.
+const localityWindow = 16
+
+func (idOrder) generate(e *ltEncoder, ids []int64, indices [][]int) []LTBlock {
+	order := make([]int, len(ids))
+	for i := range order {
+		order[i] = i
+	}
+	return generateInOrder(e, ids, indices, order)
+}
+
+func (o localityOrder) generate(e *ltEncoder, ids []int64, indices [][]int) []LTBlock {
+	return generateInOrder(e, ids, indices, o.schedule(indices))
+}
+
+// generateInOrder generates the code blocks at the given positions of ids
+// one at a time, in that order.
+func generateInOrder(e *ltEncoder, ids []int64, indices [][]int, order []int) []LTBlock {
+	blocks := make([]LTBlock, len(ids))
+	for _, i := range order {
+		blocks[i] = e.generate(ids[i], indices[i])
+	}
+	return blocks
+}
+
+// schedule returns the order in which to generate code blocks with the given
+// intermediate block indices, as positions in indices.
+func (localityOrder) schedule(indices [][]int) []int {
+	sorted := make([][]int, len(indices))
+	for i, s := range indices {
+		sorted[i] = slices.Clone(s)
+		slices.Sort(sorted[i])
+	}
+	pending := make([]int, len(indices))
+	for i := range pending {
+		pending[i] = i
+	}
+	slices.SortStableFunc(pending, func(a, b int) int {
+		return slices.Compare(sorted[a], sorted[b])
+	})
+
+	// Pick each code block from a pool of the next candidates in sorted
+	// order, refilling the pool from the sorted list.
+	order := make([]int, 0, len(indices))
+	n := min(localityWindow, len(pending))
+	pool, rest := pending[:n:n], pending[n:]
+	for len(pool) > 0 {
+		best := 0
+		if len(order) > 0 {
+			current := sorted[order[len(order)-1]]
+			bestOverlap := -1
+			for i, p := range pool {
+				if o := overlap(current, sorted[p]); o > bestOverlap {
+					best, bestOverlap = i, o
+				}
+			}
+		}
+		order = append(order, pool[best])
+		if len(rest) > 0 {
+			pool[best], rest = rest[0], rest[1:]
+		} else {
+			pool = append(pool[:best], pool[best+1:]...)
+		}
+	}
+	return order
+}
+
+func (sourceOrder) generate(e *ltEncoder, ids []int64, indices [][]int) []LTBlock {
+	symbols := generateLubyTransformBatch(e.source, indices)
+	blocks := make([]LTBlock, len(ids))
+	for i, b := range symbols {
+		e.countGenerated(indices[i])
+		blocks[i] = e.block(ids[i], b)
+	}
+	return blocks
+}
+
+// overlap returns the number of elements two sorted sets have in common.
+func overlap(a, b []int) int {
+	n := 0
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			n++
+			a, b = a[1:], b[1:]
+		}
+	}
+	return n
+}
+
+// GenerateBatch returns the code blocks of e with the given IDs, in the same
+// order as the IDs, generating them in the order o chooses, or in IDOrder if
+// o is nil. Encoders which aren't created by the package's LT codecs generate
+// them in ID order. Returns an error wrapping ErrNonCompliant, without
+// generating anything, if the codec can't compose a block with one of the
+// IDs.
+func GenerateBatch(e Encoder, ids []int64, o BatchOrder) ([]LTBlock, error) {
+	if o == nil {
+		o = IDOrder
+	}
+	lt, ok := e.(*ltEncoder)
+	if !ok {
+		blocks := make([]LTBlock, len(ids))
+		for i, id := range ids {
+			blocks[i] = e.Generate(id)
+		}
+		return blocks, nil
+	}
+	if err := checkBlockCodes(lt.codec, ids); err != nil {
+		return nil, err
+	}
+	return lt.generateBatch(ids, o), nil
+}
+
+// generateBatch returns the code blocks with the given IDs, generated in the
+// order o chooses if the codec composes them by XOR.
+func (e *ltEncoder) generateBatch(ids []int64, o BatchOrder) []LTBlock {
+	if _, custom := symbolGeneratorOf(e.codec); custom {
+		blocks := make([]LTBlock, len(ids))
+		for i, id := range ids {
+			blocks[i] = e.Generate(id)
+		}
+		return blocks
+	}
+	indices := make([][]int, len(ids))
+	for i, id := range ids {
+		indices[i] = e.codec.PickIndices(id)
+	}
+	return o.generate(e, ids, indices)
+}
@@ -0,0 +1,170 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGenerateBatch(t *testing.T) {
+	// The second message has all-zero source blocks, which the encoders turn
+	// into padding (see compactZeroBlocks).
+	messages := [][]byte{
+		[]byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"),
+		append(append(make([]byte, 30), "abcdefghijklmnopqrstuvwxyz"...), make([]byte, 6)...),
+	}
+	codecs := testCodecs()
+	ids := make([]int64, 100)
+	for i := range ids {
+		ids[i] = int64(i*7 + 3)
+	}
+	for _, message := range messages {
+		for _, c := range codecs {
+			want := EncodeLTBlocksCopy(message, ids, c)
+			for _, o := range []BatchOrder{IDOrder, LocalityOrder, SourceOrder} {
+				if got, err := GenerateBatch(c.NewEncoder(message), ids, o); err != nil || !reflect.DeepEqual(got, want) {
+					t.Errorf("%T: GenerateBatch(%T) of %q differs from EncodeLTBlocksCopy, %v", c, o, message, err)
+				}
+			}
+		}
+	}
+
+	// A nil order is IDOrder, and IDs the codec can't compose are rejected.
+	c := NewRaptorCodec(20, 1)
+	want := EncodeLTBlocksCopy(messages[0], ids, c)
+	if got, err := GenerateBatch(c.NewEncoder(messages[0]), ids, nil); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("GenerateBatch(nil) differs from EncodeLTBlocksCopy, %v", err)
+	}
+	for _, id := range []int64{-1, MaxRaptorESI + 1} {
+		if _, err := GenerateBatch(c.NewEncoder(messages[0]), []int64{0, id}, SourceOrder); !errors.Is(err, ErrNonCompliant) {
+			t.Errorf("GenerateBatch of raptor ID %d = %v, want ErrNonCompliant", id, err)
+		}
+	}
+}
+
+func TestGenerateBatchStats(t *testing.T) {
+	message := make([]byte, 4000)
+	for i := range message {
+		message[i] = byte(i * 13)
+	}
+	ids := make([]int64, 50)
+	for i := range ids {
+		ids[i] = int64(i * 3)
+	}
+	c := NewRaptorCodec(40, 4)
+	var want StatsCounter
+	e := c.NewEncoder(message)
+	SetEncoderStats(e, &want)
+	for _, id := range ids {
+		e.Generate(id)
+	}
+	for _, o := range []BatchOrder{IDOrder, LocalityOrder, SourceOrder} {
+		var got StatsCounter
+		e := c.NewEncoder(message)
+		SetEncoderStats(e, &got)
+		if _, err := GenerateBatch(e, ids, o); err != nil {
+			t.Fatal(err)
+		}
+		if got.Snapshot() != want.Snapshot() {
+			t.Errorf("GenerateBatch(%T) reported %+v, want %+v as by Generate", o, got.Snapshot(), want.Snapshot())
+		}
+	}
+}
+
+func TestGenerateLubyTransformBatchTiles(t *testing.T) {
+	// Source blocks of 100kB put two code blocks in a tile.
+	random := rand.New(rand.NewSource(4))
+	source := make([]block, 10)
+	for i := range source {
+		source[i].data = make([]byte, 100<<10)
+		random.Read(source[i].data)
+	}
+	indices := make([][]int, 7)
+	for i := range indices {
+		indices[i] = random.Perm(len(source))[:i%4+1]
+	}
+	for i, b := range generateLubyTransformBatch(source, indices) {
+		if want := generateLubyTransformBlock(source, indices[i]); !reflect.DeepEqual(b, want) {
+			t.Errorf("code block %d of %v differs from generateLubyTransformBlock", i, indices[i])
+		}
+	}
+}
+
+func TestLocalityOrder(t *testing.T) {
+	indices := [][]int{{5, 9}, {1, 2, 3}, {9, 5, 7}, {1, 2}, {4}}
+	order := localityOrder{}.schedule(indices)
+	// Sorted: {1, 2}, {1, 2, 3}, {4}, {5, 7, 9}, {5, 9}. Ties keep the sorted
+	// order, so {5, 9} follows {5, 7, 9}, with which it shares two blocks.
+	if want := []int{3, 1, 4, 2, 0}; !reflect.DeepEqual(order, want) {
+		t.Errorf("schedule = %v, want %v", order, want)
+	}
+
+	random := rand.New(rand.NewSource(3))
+	indices = make([][]int, 100)
+	for i := range indices {
+		indices[i] = random.Perm(20)[:random.Intn(5)+1]
+	}
+	order = localityOrder{}.schedule(indices)
+	sorted := append([]int(nil), order...)
+	sort.Ints(sorted)
+	for i, p := range sorted {
+		if p != i {
+			t.Fatalf("schedule of 100 sets isn't a permutation: %v", order)
+		}
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	for _, test := range []struct {
+		a, b []int
+		want int
+	}{
+		{nil, []int{1}, 0},
+		{[]int{1, 3, 5}, []int{2, 3, 4, 5}, 2},
+		{[]int{1, 2}, []int{1, 2}, 2},
+	} {
+		if got := overlap(test.a, test.b); got != test.want {
+			t.Errorf("overlap(%v, %v) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// BenchmarkGenerateBatch generates repair blocks of an 8MB message, larger
+// than typical last-level caches, with a low-degree code (RU10) and a
+// high-degree one (binary).
+func BenchmarkGenerateBatch(b *testing.B) {
+	message := make([]byte, 8<<20)
+	rand.New(rand.NewSource(1)).Read(message)
+	for _, c := range []Codec{NewRU10Codec(2048, 4), NewBinaryCodec(256)} {
+		e := c.NewEncoder(message)
+		ids := make([]int64, 256)
+		for i := range ids {
+			ids[i] = int64(c.SourceBlocks() + i)
+		}
+		for _, o := range []BatchOrder{IDOrder, LocalityOrder, SourceOrder} {
+			b.Run(fmt.Sprintf("%T/%T", c, o), func(b *testing.B) {
+				b.SetBytes(int64(len(ids)) * int64(len(message)/c.SourceBlocks()))
+				for i := 0; i < b.N; i++ {
+					GenerateBatch(e, ids, o)
+				}
+			})
+		}
+	}
+}
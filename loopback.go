@@ -0,0 +1,151 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"io"
+	"sync"
+)
+
+// NewLoopback connects a sender and a receiver in memory through a simulated
+// lossy channel, much as io.Pipe connects a writer and a reader. Programs built
+// on the package can integration-test their application logic with it, without
+// sockets. Unlike io.Pipe, the channel is buffered: Send never waits for the
+// receiver, so both ends may be driven from a single goroutine.
+
+// loopback is the channel shared by a LoopbackSender and LoopbackReceiver.
+type loopback struct {
+	codec Codec
+	loss  LossModel
+
+	mu             sync.Mutex
+	ready          *sync.Cond
+	queue          []LTBlock
+	senderClosed   bool
+	receiverClosed bool
+}
+
+// LoopbackSender is the sending half of a loopback channel.
+type LoopbackSender struct {
+	l *loopback
+}
+
+// LoopbackReceiver is the receiving half of a loopback channel.
+type LoopbackReceiver struct {
+	l *loopback
+}
+
+// NewLoopback creates a connected sender and receiver which exchange code
+// blocks of the given codec. Each block sent is dropped if loss (which may be
+// nil for a lossless channel) says so. The pair is safe for concurrent use.
+func NewLoopback(c Codec, loss LossModel) (*LoopbackSender, *LoopbackReceiver) {
+	l := &loopback{codec: c, loss: loss}
+	l.ready = sync.NewCond(&l.mu)
+	return &LoopbackSender{l}, &LoopbackReceiver{l}
+}
+
+// Send encodes the code blocks with the given IDs from message, which is left
+// untouched, and transmits them through the channel. Returns the number of
+// blocks which weren't lost, or io.ErrClosedPipe if either end has been
+// closed.
+func (s *LoopbackSender) Send(message []byte, ids []int64) (int, error) {
+	blocks := EncodeLTBlocksCopy(message, ids, s.l.codec)
+	return s.SendBlocks(blocks)
+}
+
+// SendBlocks transmits already encoded blocks through the channel. The
+// receiver gets the blocks themselves, not copies.
+func (s *LoopbackSender) SendBlocks(blocks []LTBlock) (int, error) {
+	l := s.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.senderClosed || l.receiverClosed {
+		return 0, io.ErrClosedPipe
+	}
+	delivered := 0
+	for _, b := range blocks {
+		if l.loss != nil && l.loss.Lost() {
+			continue
+		}
+		l.queue = append(l.queue, b)
+		delivered++
+	}
+	l.ready.Broadcast()
+	return delivered, nil
+}
+
+// Close closes the sending end. The receiver gets the blocks already sent,
+// then io.EOF.
+func (s *LoopbackSender) Close() error {
+	l := s.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.senderClosed = true
+	l.ready.Broadcast()
+	return nil
+}
+
+// Next returns the next block to arrive, waiting for one to be sent if
+// necessary. Returns io.EOF once the sender is closed and every block it sent
+// has been received, or io.ErrClosedPipe if the receiver has been closed.
+func (r *LoopbackReceiver) Next() (LTBlock, error) {
+	l := r.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.queue) == 0 && !l.senderClosed && !l.receiverClosed {
+		l.ready.Wait()
+	}
+	if l.receiverClosed {
+		return LTBlock{}, io.ErrClosedPipe
+	}
+	if len(l.queue) == 0 {
+		return LTBlock{}, io.EOF
+	}
+	b := l.queue[0]
+	l.queue = l.queue[1:]
+	return b, nil
+}
+
+// Receive decodes a message of messageLength bytes from the blocks arriving
+// on the channel. It returns as soon as the message can be decoded, leaving
+// any further blocks queued. Returns io.ErrUnexpectedEOF if the sender is
+// closed first.
+func (r *LoopbackReceiver) Receive(messageLength int) ([]byte, error) {
+	d := r.l.codec.NewDecoder(messageLength)
+	for {
+		b, err := r.Next()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if d.AddBlocks([]LTBlock{b}) {
+			return d.Decode(), nil
+		}
+	}
+}
+
+// Close closes the receiving end. Blocks still queued are discarded, and
+// further sends fail.
+func (r *LoopbackReceiver) Close() error {
+	l := r.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.receiverClosed = true
+	l.queue = nil
+	l.ready.Broadcast()
+	return nil
+}
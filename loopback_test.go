@@ -0,0 +1,74 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestLoopback(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewRU10Codec(10, 1)
+	sender, receiver := NewLoopback(c, NewBernoulliLoss(0.3, rand.New(rand.NewSource(5))))
+
+	go func() {
+		for id := int64(0); id < 1000; id += 10 {
+			ids := make([]int64, 10)
+			for i := range ids {
+				ids[i] = id + int64(i)
+			}
+			if _, err := sender.Send(message, ids); err != nil {
+				break
+			}
+		}
+		sender.Close()
+	}()
+
+	decoded, err := receiver.Receive(len(message))
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if !bytes.Equal(decoded, message) {
+		t.Errorf("decoded %q, want %q", decoded, message)
+	}
+	receiver.Close()
+	if _, err := receiver.Next(); err != io.ErrClosedPipe {
+		t.Errorf("Next after Close returned %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestLoopbackEOF(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	sender, receiver := NewLoopback(NewBinaryCodec(10), NewPatternLoss([]bool{false, true}))
+
+	delivered, err := sender.Send(message, []int64{1, 2, 3, 4, 5, 6})
+	if err != nil || delivered != 3 {
+		t.Errorf("Send = %d, %v, want 3, nil", delivered, err)
+	}
+	sender.Close()
+	if _, err := sender.Send(message, []int64{7}); err != io.ErrClosedPipe {
+		t.Errorf("Send after Close returned %v, want %v", err, io.ErrClosedPipe)
+	}
+
+	if _, err := receiver.Receive(len(message)); err != io.ErrUnexpectedEOF {
+		t.Errorf("Receive returned %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := receiver.Next(); err != io.EOF {
+		t.Errorf("Next returned %v, want %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Wire format versions of a code block. Checksummed blocks have a version of
+// their own, so that readers which predate checksums reject them rather than
+// misparse the checksum as the start of the next block.
+const (
+	ltBlockVersion         = 1
+	ltBlockChecksumVersion = 2
+)
+
+// AppendBinary appends the wire encoding of the code block to b: a WireHeader,
+// then the block code as a zigzag varint (see binary.AppendVarint), so that
+// negative codes such as those of region repair blocks stay short, and the
+// length of the data as a uvarint, then the data. Being length-prefixed,
+// encoded blocks can be concatenated in a stream.
+// Implements encoding.BinaryAppender.
+func (b LTBlock) AppendBinary(buf []byte) ([]byte, error) {
+	buf = WireHeader{Version: ltBlockVersion}.AppendTo(buf)
+	buf = binary.AppendVarint(buf, b.BlockCode)
+	buf = binary.AppendUvarint(buf, uint64(len(b.Data)))
+	return append(buf, b.Data...), nil
+}
+
+// A code block which is corrupted on the way but still delivered, as by a
+// link without checksums of its own or a faulty middlebox, would otherwise
+// enter the decode matrix and silently corrupt the decoded message. A block
+// encoded with AppendBinaryChecksum carries a CRC-32C of its encoding, which
+// ParseLTBlock checks, so a corrupted block is dropped like a lost one.
+
+// ErrBlockChecksum is returned when a code block doesn't match its checksum.
+var ErrBlockChecksum = errors.New("fountain: code block doesn't match its checksum")
+
+// crc32c is the CRC-32C (Castagnoli) table.
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// AppendBinaryChecksum appends the wire encoding of the code block to b like
+// AppendBinary, but as version 2 of the format, with WireFlagChecksum set and
+// followed by the CRC-32C of the encoding, covering the block code and the
+// data.
+func (b LTBlock) AppendBinaryChecksum(buf []byte) []byte {
+	start := len(buf)
+	buf = WireHeader{Version: ltBlockChecksumVersion, Flags: WireFlagChecksum}.AppendTo(buf)
+	buf = binary.AppendVarint(buf, b.BlockCode)
+	buf = binary.AppendUvarint(buf, uint64(len(b.Data)))
+	buf = append(buf, b.Data...)
+	return ByteOrder.AppendUint32(buf, crc32.Checksum(buf[start:], crc32c))
+}
+
+// MarshalBinary returns the wire encoding of the code block (see
+// AppendBinary). Implements encoding.BinaryMarshaler.
+func (b LTBlock) MarshalBinary() ([]byte, error) {
+	return b.AppendBinary(nil)
+}
+
+// UnmarshalBinary decodes a code block written by MarshalBinary, or by
+// AppendBinaryChecksum, into b. The data is copied. Returns an error if data
+// holds anything past the block. Implements encoding.BinaryUnmarshaler.
+func (b *LTBlock) UnmarshalBinary(data []byte) error {
+	block, rest, err := ParseLTBlock(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("fountain: %d bytes of trailing data after code block", len(rest))
+	}
+	*b = LTBlock{BlockCode: block.BlockCode, Data: bytes.Clone(block.Data)}
+	return nil
+}
+
+// ParseLTBlock decodes the code block at the start of b, written by
+// AppendBinary or AppendBinaryChecksum, and returns it along with the
+// remainder of b. The block's data aliases b. If the block doesn't match its
+// checksum, the error wraps ErrBlockChecksum, and the remainder of b after
+// the block is still returned, so that a stream of blocks can go on past it.
+func ParseLTBlock(b []byte) (LTBlock, []byte, error) {
+	encoded := b
+	h, b, err := ParseWireHeader(b, ltBlockChecksumVersion)
+	if err != nil {
+		return LTBlock{}, nil, err
+	}
+	// Version 1 blocks have no checksum, and version 2 blocks always do.
+	checksum := h.Version == ltBlockChecksumVersion
+	known := uint8(WireFlagLittleEndian)
+	if checksum {
+		known |= WireFlagChecksum
+	}
+	if h.Flags&^known != 0 || checksum && h.Flags&WireFlagChecksum == 0 {
+		return LTBlock{}, nil, fmt.Errorf("fountain: unsupported code block flags %#x in version %d", h.Flags, h.Version)
+	}
+	code, n := binary.Varint(b)
+	if n <= 0 {
+		return LTBlock{}, nil, ErrShortWireData
+	}
+	b = b[n:]
+	length, n := binary.Uvarint(b)
+	if n <= 0 || length > uint64(len(b)-n) {
+		return LTBlock{}, nil, ErrShortWireData
+	}
+	b = b[n:]
+	block, rest := LTBlock{BlockCode: code, Data: b[:length:length]}, b[length:]
+	if !checksum {
+		return block, rest, nil
+	}
+	if len(rest) < 4 {
+		return LTBlock{}, nil, ErrShortWireData
+	}
+	sum := crc32.Checksum(encoded[:len(encoded)-len(rest)], crc32c)
+	if h.ByteOrder().Uint32(rest) != sum {
+		return LTBlock{}, rest[4:], fmt.Errorf("%w: block code %d", ErrBlockChecksum, block.BlockCode)
+	}
+	return block, rest[4:], nil
+}
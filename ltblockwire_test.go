@@ -0,0 +1,157 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = LTBlock{}
+	_ encoding.BinaryAppender    = LTBlock{}
+	_ encoding.BinaryUnmarshaler = &LTBlock{}
+)
+
+func TestLTBlockBinary(t *testing.T) {
+	blocks := []LTBlock{
+		{BlockCode: 0, Data: []byte{}},
+		{BlockCode: 300, Data: []byte("abc")},
+		{BlockCode: -1, Data: []byte{0, 1, 2, 3}},
+		{BlockCode: 7<<32 + 5, Data: make([]byte, 200)},
+	}
+	for _, b := range blocks {
+		data, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(%v) failed: %v", b, err)
+		}
+		var got LTBlock
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, b) {
+			t.Errorf("round trip of %v = %v", b, got)
+		}
+		data[len(data)-1] ^= 1
+		if len(b.Data) > 0 && got.Data[len(got.Data)-1] != b.Data[len(b.Data)-1] {
+			t.Errorf("UnmarshalBinary didn't copy the data")
+		}
+	}
+
+	// A negative block code, as of a region repair block, takes one byte.
+	if data, _ := (LTBlock{BlockCode: -1, Data: []byte{1}}).MarshalBinary(); len(data) != WireHeaderLen+3 {
+		t.Errorf("block code -1 encoded in %d bytes, want %d", len(data), WireHeaderLen+3)
+	}
+
+	// Encoded blocks can be concatenated.
+	var stream []byte
+	for _, b := range blocks {
+		stream, _ = b.AppendBinary(stream)
+	}
+	for i := 0; len(stream) > 0; i++ {
+		b, rest, err := ParseLTBlock(stream)
+		if err != nil {
+			t.Fatalf("ParseLTBlock of block %d failed: %v", i, err)
+		}
+		if !reflect.DeepEqual(b, blocks[i]) {
+			t.Errorf("block %d = %v, want %v", i, b, blocks[i])
+		}
+		stream = rest
+	}
+}
+
+func TestLTBlockBinaryErrors(t *testing.T) {
+	data, _ := LTBlock{BlockCode: 300, Data: []byte("abc")}.MarshalBinary()
+	var b LTBlock
+	for n := 0; n < len(data); n++ {
+		if err := b.UnmarshalBinary(data[:n]); !errors.Is(err, ErrShortWireData) {
+			t.Errorf("UnmarshalBinary of %d bytes = %v, want ErrShortWireData", n, err)
+		}
+	}
+	if err := b.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Errorf("UnmarshalBinary accepted trailing data")
+	}
+	data[0] = ltBlockVersion + 1
+	if err := b.UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary accepted an unknown version")
+	}
+}
+
+func TestLTBlockChecksum(t *testing.T) {
+	blocks := []LTBlock{
+		{BlockCode: 5, Data: []byte("hello")},
+		{BlockCode: 1 << 20, Data: []byte{}},
+		{BlockCode: 6, Data: []byte("world")},
+	}
+	var stream []byte
+	for _, b := range blocks {
+		stream = b.AppendBinaryChecksum(stream)
+	}
+	var got LTBlock
+	if err := got.UnmarshalBinary(blocks[0].AppendBinaryChecksum(nil)); err != nil || !reflect.DeepEqual(got, blocks[0]) {
+		t.Errorf("UnmarshalBinary = %v, %v; want %v", got, err, blocks[0])
+	}
+
+	// Every single-bit error in the first block's code or data is caught,
+	// and the stream goes on past it.
+	first := len(blocks[0].AppendBinaryChecksum(nil))
+	for bit := WireHeaderLen * 8; bit < (first-4)*8; bit++ {
+		corrupt := append([]byte(nil), stream...)
+		corrupt[bit/8] ^= 1 << (bit % 8)
+		_, rest, err := ParseLTBlock(corrupt)
+		if errors.Is(err, ErrShortWireData) {
+			// The length was corrupted past the end of the data.
+			continue
+		}
+		if !errors.Is(err, ErrBlockChecksum) {
+			t.Fatalf("ParseLTBlock with bit %d flipped = %v, want ErrBlockChecksum", bit, err)
+		}
+		if bit/8 >= WireHeaderLen+2 && len(rest) != len(stream)-first {
+			t.Errorf("bit %d flipped: %d bytes left, want %d", bit, len(rest), len(stream)-first)
+		}
+	}
+
+	for i := 0; len(stream) > 0; i++ {
+		b, rest, err := ParseLTBlock(stream)
+		if err != nil || !reflect.DeepEqual(b, blocks[i]) {
+			t.Fatalf("block %d = %v, %v; want %v", i, b, err, blocks[i])
+		}
+		stream = rest
+	}
+	truncated := blocks[0].AppendBinaryChecksum(nil)
+	if _, _, err := ParseLTBlock(truncated[:len(truncated)-1]); !errors.Is(err, ErrShortWireData) {
+		t.Errorf("ParseLTBlock without the whole checksum = %v, want ErrShortWireData", err)
+	}
+
+	// A checksummed block is a version 1 reader's unsupported version, and
+	// the checksum flag is only meaningful in version 2.
+	if _, _, err := ParseWireHeader(truncated, ltBlockVersion); err == nil {
+		t.Error("a version 1 reader accepted a checksummed block")
+	}
+	for _, h := range []WireHeader{
+		{Version: ltBlockVersion, Flags: WireFlagChecksum},
+		{Version: ltBlockVersion, Flags: 0x80},
+		{Version: ltBlockChecksumVersion},
+		{Version: ltBlockChecksumVersion, Flags: WireFlagChecksum | 0x80},
+	} {
+		b := h.AppendTo(nil)
+		b = append(b, truncated[WireHeaderLen:]...)
+		if _, _, err := ParseLTBlock(b); err == nil {
+			t.Errorf("ParseLTBlock accepted header %+v", h)
+		}
+	}
+}
@@ -34,7 +34,10 @@ an LT (Luby Transform) process to produce the code blocks.
 package fountain
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
+	"sort"
 )
 
 // Codec is an interface for fountain codes which follow the general
@@ -65,6 +68,11 @@ type Codec interface {
 	// codec for a known message size (in bytes). The decoder will be initialized
 	// and ready to receive incoming blocks for decoding.
 	NewDecoder(messageLength int) Decoder
+
+	// NewEncoder creates an encoder which generates code blocks for the given
+	// message on demand. The intermediate blocks are generated once, up front.
+	// The message must not be modified while the encoder is in use.
+	NewEncoder(message []byte) Encoder
 }
 
 // LTBlock is an encoded block structure representing a block created using
@@ -79,6 +87,13 @@ type LTBlock struct {
 	Data []byte
 }
 
+// Encoder is an interface allowing code blocks for a message to be generated
+// incrementally, for instance as repair symbols are requested.
+type Encoder interface {
+	// Generate returns the code block with the given ID.
+	Generate(id int64) LTBlock
+}
+
 // Decoder is an interface allowing decoding of fountain-code-encoded messages
 // as the blocks are received.
 type Decoder interface {
@@ -91,6 +106,34 @@ type Decoder interface {
 	Decode() []byte
 }
 
+// errNotDetermined is returned when decoding is attempted before the decoder
+// has enough information.
+var errNotDetermined = errors.New("fountain: decoder is not yet determined")
+
+// strictDecoder is implemented by the package's decoders.
+type strictDecoder interface {
+	decode(strict bool) ([]byte, error)
+}
+
+// DecodeStrict is like d.Decode(), but validates the reconstruction of the
+// message instead of trusting the partitioning arithmetic: it returns an error
+// if the decoder isn't determined (a *DecodeError), or if any recovered source
+// block holds less data than its share of the message (for instance because
+// code blocks shorter than the symbol size were received). Decode()
+// zero-fills such blocks. d must be a decoder created by one of the package's
+// codecs.
+func DecodeStrict(d Decoder) ([]byte, error) {
+	s, ok := d.(strictDecoder)
+	if !ok {
+		return nil, fmt.Errorf("fountain: DecodeStrict doesn't support %T", d)
+	}
+	out, err := s.decode(true)
+	if err == errNotDetermined {
+		err = newDecodeError(d)
+	}
+	return out, err
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Implementation of Luby Transform codes.
 // The Luby Transform (LT) converts a source text split into a number of source
@@ -106,12 +149,24 @@ type lubyCodec struct {
 	sourceBlocks int
 
 	// random is a source of randomness for sampling the degree distribution
-	// and the source blocks when composing a code block.
+	// and the source blocks when composing a code block. If nil, a fresh PRNG
+	// derived from seed and the code block ID is used for each code block.
 	random *rand.Rand
 
+	// seed is mixed with the code block ID to pick indices when random is nil.
+	seed int64
+
 	// degreeCDF is the degree distribution function from which encoding block
 	// compositions are chosen.
 	degreeCDF []float64
+
+	// schedule, if set, replaces degreeCDF with a degree which depends on the
+	// code block ID: 1 plus the number of entries no greater than it. See
+	// NewGrowthCodec.
+	schedule []int64
+
+	// sampling is how the source indices are sampled (see WithIndexSampling).
+	sampling IndexSampling
 }
 
 // NewLubyCodec creates a new Codec using the provided number of source blocks,
@@ -120,6 +175,9 @@ type lubyCodec struct {
 // message padded so that all blocks have equal size. The indices will be picked
 // using the provided PRNG seeded with the BlockCode ID of the LTBlock
 // to be created, according to the degree CDF provided.
+// The PRNG is re-seeded and shared by every PickIndices call, so the codec (and
+// decoders created from it) must not be used concurrently. NewSeededLubyCodec
+// doesn't have this limitation.
 func NewLubyCodec(sourceBlocks int, random *rand.Rand, degreeCDF []float64) Codec {
 	return &lubyCodec{
 		sourceBlocks: sourceBlocks,
@@ -127,19 +185,64 @@ func NewLubyCodec(sourceBlocks int, random *rand.Rand, degreeCDF []float64) Code
 		degreeCDF:    degreeCDF}
 }
 
+// NewSeededLubyCodec creates a new Codec using the provided number of source
+// blocks, seed, and degree distribution function. The indices for each LTBlock
+// are picked using a Mersenne Twister seeded from both the seed and the
+// BlockCode ID, so they depend on nothing else: a decoder can be constructed
+// from a separately created codec with the same parameters, and encoders and
+// decoders may run concurrently.
+func NewSeededLubyCodec(sourceBlocks int, seed int64, degreeCDF []float64) Codec {
+	return &lubyCodec{
+		sourceBlocks: sourceBlocks,
+		seed:         seed,
+		degreeCDF:    degreeCDF}
+}
+
 // SourceBlocks retrieves the number of source blocks the codec is configured to use.
 func (c *lubyCodec) SourceBlocks() int {
 	return c.sourceBlocks
 }
 
-// PickIndices uses the provided PRNG to select a random number of source
-// blocks with degree d, given by a random selection in the degreeCDF parameter.
+// PickIndices uses the provided PRNG (or one derived from the codec seed) to
+// select a random number of source blocks with degree d, given by a random
+// selection in the degreeCDF parameter.
 // The degree distribution is how likely the encoder is to pick code blocks composed
 // of d source blocks.
 func (c *lubyCodec) PickIndices(codeBlockIndex int64) []int {
-	c.random.Seed(codeBlockIndex)
-	d := pickDegree(c.random, c.degreeCDF)
-	return sampleUniform(c.random, d, c.sourceBlocks)
+	random := c.random
+	if random == nil {
+		t := &MersenneTwister64{}
+		t.SeedSlice([]uint64{uint64(c.seed), uint64(codeBlockIndex)})
+		random = rand.New(t)
+	} else {
+		random.Seed(codeBlockIndex)
+	}
+	return c.pickIndices(random, codeBlockIndex)
+}
+
+// pickIndices picks the source indices for a code block using the given PRNG.
+func (c *lubyCodec) pickIndices(random *rand.Rand, codeBlockIndex int64) []int {
+	var d int
+	if c.schedule != nil {
+		d = 1 + sort.Search(len(c.schedule), func(i int) bool { return c.schedule[i] > codeBlockIndex })
+	} else {
+		d = pickDegree(random, c.degreeCDF)
+	}
+	return c.sampling.sample(random, d, c.sourceBlocks)
+}
+
+// tracePRNG records the PRNG draws of PickIndices. The draws can't be traced
+// for codecs using a caller-supplied PRNG.
+func (c *lubyCodec) tracePRNG(codeBlockIndex int64) (PRNGTrace, bool) {
+	if c.random != nil {
+		return PRNGTrace{}, false
+	}
+	seed := []uint64{uint64(c.seed), uint64(codeBlockIndex)}
+	t := &MersenneTwister64{}
+	t.SeedSlice(seed)
+	src := &countingSource{Source: t}
+	c.pickIndices(rand.New(src), codeBlockIndex)
+	return PRNGTrace{BlockCode: codeBlockIndex, Generator: "mt19937-64", Seed: seed, Draws: src.draws}, true
 }
 
 // GenerateIntermediateEncoding for the LubyCodec simply splits the source message
@@ -152,34 +255,198 @@ func (c *lubyCodec) GenerateIntermediateBlocks(message []byte, numBlocks int) []
 
 // generateLubyTransformBlock generates a single code block from the set of
 // source blocks, given the composition indices, by XORing the source blocks
-// together.
+// together. Source blocks which are all padding (see compactZeroBlocks) cost
+// nothing to XOR, but still count towards the length of the result.
 func generateLubyTransformBlock(source []block, indices []int) block {
 	var symbol block
 
 	for _, i := range indices {
 		if i < len(source) {
-			symbol.xor(source[i])
+			symbol.xorSource(source[i])
 		}
 	}
 
 	return symbol
 }
 
+// generateLubyTransformBatch generates a batch of code blocks, given the
+// composition indices of each. It iterates over the source blocks in the outer
+// loop, XORing each into all the code blocks referring to it, so each source
+// block is read once for a whole tile of the batch: as many code blocks as fit
+// in batchTileBytes, which stay in cache while the source blocks stream past.
+// The results are those of generateLubyTransformBlock.
+func generateLubyTransformBatch(source []block, indices [][]int) []block {
+	symbols := make([]block, len(indices))
+	if len(source) == 0 {
+		return symbols
+	}
+	tile := max(1, batchTileBytes/max(1, source[0].length()))
+	refs := make([][]int, len(source))
+	for first := 0; first < len(indices); first += tile {
+		last := min(first+tile, len(indices))
+		for i := range refs {
+			refs[i] = refs[i][:0]
+		}
+		for s := first; s < last; s++ {
+			for _, i := range indices[s] {
+				if i < len(source) {
+					refs[i] = append(refs[i], s)
+				}
+			}
+		}
+		for i, r := range refs {
+			for _, s := range r {
+				symbols[s].xorSource(source[i])
+			}
+		}
+	}
+	return symbols
+}
+
+// batchTileBytes bounds the size of the code blocks generateLubyTransformBatch
+// generates together, to keep them within a typical L2 cache.
+const batchTileBytes = 256 << 10
+
+// xorSource XORs a source block into a code block being generated. A source
+// block which is all padding still counts towards the length of the result.
+func (b *block) xorSource(source block) {
+	b.xor(source)
+	if len(source.data) == 0 && b.length() < source.padding {
+		b.padding = source.padding - len(b.data)
+	}
+}
+
+// symbolGenerator is implemented by codecs whose code blocks aren't the XOR of
+// the intermediate blocks PickIndices names, such as the Reed-Solomon codec.
+type symbolGenerator interface {
+	// generateSymbol returns the code block with the given ID.
+	generateSymbol(source []block, codeBlockIndex int64) block
+}
+
+// codecWrapper is implemented by codecs which wrap another codec and compose
+// its code blocks the same way, such as the authenticated codec.
+type codecWrapper interface {
+	// unwrap returns the wrapped codec.
+	unwrap() Codec
+}
+
+// symbolGeneratorOf returns the generator of c's code blocks, if they aren't
+// the XOR of the intermediate blocks PickIndices names. The codecs c wraps
+// are searched, so that a wrapper which doesn't override generateSymbol
+// doesn't turn Reed-Solomon shares into XOR parity.
+func symbolGeneratorOf(c Codec) (symbolGenerator, bool) {
+	for {
+		if g, ok := c.(symbolGenerator); ok {
+			return g, true
+		}
+		w, ok := c.(codecWrapper)
+		if !ok {
+			return nil, false
+		}
+		c = w.unwrap()
+	}
+}
+
+// encodeCodeBlock generates the code block of codec c with the given ID from
+// the intermediate blocks.
+func encodeCodeBlock(c Codec, source []block, id int64) block {
+	if g, ok := symbolGeneratorOf(c); ok {
+		return g.generateSymbol(source, id)
+	}
+	return generateLubyTransformBlock(source, c.PickIndices(id))
+}
+
 // EncodeLTBlocks encodes a sequence of LT-encoded code blocks from the given message
 // and the block IDs. Suitable for use with any fountain.Codec.
 // Note: This method is destructive to the message array.
 func EncodeLTBlocks(message []byte, encodedBlockIDs []int64, c Codec) []LTBlock {
+	ltBlocks := encodeLTBlocks(intermediateEncoder(c, message), encodedBlockIDs)
+	auditPoison(message)
+	return ltBlocks
+}
+
+// EncodeLTBlocksCopy is like EncodeLTBlocks, but leaves the message untouched.
+// The message is only copied for codecs whose intermediate block generation
+// works in place (the Raptor codec); the others read it directly.
+func EncodeLTBlocksCopy(message []byte, encodedBlockIDs []int64, c Codec) []LTBlock {
+	return encodeLTBlocks(newLTEncoder(c, message), encodedBlockIDs)
+}
+
+// inPlaceEncoder is implemented by codecs whose GenerateIntermediateBlocks
+// overwrites the message it is given.
+type inPlaceEncoder interface {
+	encodesInPlace() bool
+}
+
+// encodeLTBlocks generates the code blocks with the given IDs, as a batch in
+// SourceOrder.
+func encodeLTBlocks(e *ltEncoder, encodedBlockIDs []int64) []LTBlock {
+	return e.generateBatch(encodedBlockIDs, SourceOrder)
+}
+
+// ltEncoder implements Encoder for all of the package's codecs by holding on to
+// the intermediate blocks of the message.
+type ltEncoder struct {
+	codec  Codec
+	source []block
+
+	// stats, if set, collects metrics of the generated code blocks.
+	stats Stats
+
+	// auth, if set, seals the generated code blocks (see
+	// NewAuthenticatedCodec).
+	auth SymbolAuthenticator
+}
+
+// newLTEncoder creates an encoder for the message, which is left untouched.
+func newLTEncoder(c Codec, message []byte) *ltEncoder {
+	if e, ok := c.(inPlaceEncoder); ok && e.encodesInPlace() {
+		message = append([]byte(nil), message...)
+	}
+	return intermediateEncoder(c, message)
+}
+
+// intermediateEncoder creates an encoder for the message, which the codec may
+// overwrite.
+func intermediateEncoder(c Codec, message []byte) *ltEncoder {
 	source := c.GenerateIntermediateBlocks(message, c.SourceBlocks())
+	compactZeroBlocks(source)
+	e := &ltEncoder{codec: c, source: source}
+	if a, ok := c.(*authCodec); ok {
+		e.auth = a.auth
+	}
+	return e
+}
 
-	ltBlocks := make([]LTBlock, len(encodedBlockIDs))
-	for i := range encodedBlockIDs {
-		indices := c.PickIndices(encodedBlockIDs[i])
-		ltBlocks[i].BlockCode = encodedBlockIDs[i]
-		b := generateLubyTransformBlock(source, indices)
-		ltBlocks[i].Data = make([]byte, b.length())
-		copy(ltBlocks[i].Data, b.data)
+// Generate returns the code block with the given ID.
+func (e *ltEncoder) Generate(id int64) LTBlock {
+	if e.stats != nil {
+		return e.generateCounted(id)
 	}
-	return ltBlocks
+	return e.block(id, encodeCodeBlock(e.codec, e.source, id))
+}
+
+// generate returns the code block with the given ID and intermediate block
+// indices, reporting it to the encoder's collector.
+func (e *ltEncoder) generate(id int64, indices []int) LTBlock {
+	e.countGenerated(indices)
+	return e.block(id, generateLubyTransformBlock(e.source, indices))
+}
+
+// block returns the code block with the given ID and value, its padding
+// filled in with zeros, and sealed if the encoder has an authenticator.
+func (e *ltEncoder) block(id int64, b block) LTBlock {
+	data := make([]byte, b.length())
+	copy(data, b.data)
+	if e.auth != nil {
+		return e.auth.Seal(LTBlock{BlockCode: id, Data: data})
+	}
+	return LTBlock{BlockCode: id, Data: data}
+}
+
+// NewEncoder creates a Luby Transform encoder.
+func (c *lubyCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
 }
 
 // NewDecoder creates a luby transform decoder
@@ -210,22 +477,41 @@ func newLubyDecoder(c *lubyCodec, length int) *lubyDecoder {
 // AddBlocks adds a set of encoded blocks to the decoder. Returns true if the
 // message can be fully decoded. False if there is insufficient information.
 func (d *lubyDecoder) AddBlocks(blocks []LTBlock) bool {
+	if d.matrix.lateBlocks(blocks) {
+		return true
+	}
 	for i := range blocks {
 		indices := d.codec.PickIndices(blocks[i].BlockCode)
-		d.matrix.addEquation(indices, block{data: blocks[i].Data})
+		d.matrix.addCodeBlock(blocks[i].BlockCode, indices, block{data: blocks[i].Data})
 	}
-	return d.matrix.determined()
+	return d.matrix.setComplete(d.matrix.determined())
 }
 
 // Decode extracts the decoded message from the decoder. If the decoder does
 // not have sufficient information to produce an output, returns a nil slice.
 func (d *lubyDecoder) Decode() []byte {
+	out, _ := d.decode(false)
+	return out
+}
+
+// decode reduces the decode matrix and reconstructs the message. See
+// reconstructBlocks for the meaning of strict.
+func (d *lubyDecoder) decode(strict bool) ([]byte, error) {
 	if !d.matrix.determined() {
-		return nil
+		return nil, errNotDetermined
 	}
 
 	d.matrix.reduce()
-
 	lenLong, lenShort, numLong, numShort := partition(d.messageLength, d.codec.SourceBlocks())
-	return d.matrix.reconstruct(d.messageLength, lenLong, lenShort, numLong, numShort)
+	return reconstructBlocks(d.matrix.v, d.messageLength, lenLong, lenShort, numLong, numShort, strict)
+}
+
+// recoverSource reduces the solvable rows of the decode matrix and returns
+// the source blocks, along with which of them have been recovered. It may be
+// called before the decoder is determined.
+func (d *lubyDecoder) recoverSource() ([]block, []bool) {
+	n := d.codec.sourceBlocks
+	ok := d.matrix.solvable()
+	d.matrix.reduce()
+	return d.matrix.v[:n], ok[:n]
 }
@@ -22,7 +22,7 @@ import (
 
 func TestLubyTransformBlockGenerator(t *testing.T) {
 	message := []byte("abcdefghijklmnopqrstuvwxyz")
-	codec := NewLubyCodec(4, rand.New(NewMersenneTwister(200)), solitonDistribution(4))
+	codec := NewLubyCodec(4, rand.New(NewMersenneTwister(200)), SolitonDistribution(4))
 
 	wantIndices := [][]int{
 		{0},
@@ -77,10 +77,10 @@ func TestLubyTransformBlockGenerator(t *testing.T) {
 
 func TestLubyDecoder(t *testing.T) {
 	message := []byte("abcdefghijklmnopqrstuvwxyz")
-	codec := NewLubyCodec(4, rand.New(NewMersenneTwister(200)), solitonDistribution(4))
+	codec := NewLubyCodec(4, rand.New(NewMersenneTwister(200)), SolitonDistribution(4))
 
 	encodeBlocks := []int64{7, 34, 5, 31, 25}
-	lubyBlocks := EncodeLTBlocks(message, encodeBlocks, codec)
+	lubyBlocks := EncodeLTBlocks(append([]byte(nil), message...), encodeBlocks, codec)
 
 	decoder := codec.NewDecoder(len(message))
 	determined := decoder.AddBlocks(lubyBlocks)
@@ -93,3 +93,142 @@ func TestLubyDecoder(t *testing.T) {
 		t.Logf("String value = %v", string(decoded))
 	}
 }
+
+func TestSeededLubyCodecIndependence(t *testing.T) {
+	encoder := NewSeededLubyCodec(20, 1234, SolitonDistribution(20))
+	decoderCodec := NewSeededLubyCodec(20, 1234, SolitonDistribution(20))
+
+	// Pick indices in different orders on the two codecs; each ID must map to
+	// the same composition regardless.
+	ids := []int64{3, 99, 7, 12345, 0}
+	want := make([][]int, len(ids))
+	for i := range ids {
+		want[i] = encoder.PickIndices(ids[i])
+	}
+	for i := len(ids) - 1; i >= 0; i-- {
+		if got := decoderCodec.PickIndices(ids[i]); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("PickIndices(%d) = %v on second codec, want %v", ids[i], got, want[i])
+		}
+	}
+
+	other := NewSeededLubyCodec(20, 4321, SolitonDistribution(20))
+	same := 0
+	for i := range ids {
+		if reflect.DeepEqual(other.PickIndices(ids[i]), want[i]) {
+			same++
+		}
+	}
+	if same == len(ids) {
+		t.Errorf("Codecs with different seeds picked identical indices for %v", ids)
+	}
+}
+
+func TestSeededLubyDecoder(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	ids := make([]int64, 100)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	messageCopy := make([]byte, len(message))
+	copy(messageCopy, message)
+	blocks := EncodeLTBlocks(messageCopy, ids, NewSeededLubyCodec(4, 77, SolitonDistribution(4)))
+
+	decoder := NewSeededLubyCodec(4, 77, SolitonDistribution(4)).NewDecoder(len(message))
+	for i := range blocks {
+		if decoder.AddBlocks(blocks[i : i+1]) {
+			break
+		}
+	}
+	if decoded := decoder.Decode(); !reflect.DeepEqual(decoded, message) {
+		t.Errorf("Decoded luby transform message is %v, expected %v", decoded, message)
+	}
+}
+
+// testCodecs returns new codecs of each kind whose decoders share the sparse
+// decode matrix, each over 10 source blocks, for the tests which check a
+// feature across all of them.
+func testCodecs() []Codec {
+	return []Codec{
+		NewSeededLubyCodec(10, 99, SolitonDistribution(10)),
+		NewBinaryCodec(10),
+		NewOnlineCodec(10, 0.3, 10, 200),
+		NewRaptorCodec(10, 1),
+		NewRU10Codec(10, 1),
+	}
+}
+
+func TestEncodeLTBlocksCopy(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	original := append([]byte(nil), message...)
+	ids := []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+	codecs := testCodecs()
+	for _, c := range codecs {
+		got := EncodeLTBlocksCopy(message, ids, c)
+		if !reflect.DeepEqual(message, original) {
+			t.Fatalf("%T: EncodeLTBlocksCopy modified the message", c)
+		}
+		want := EncodeLTBlocks(append([]byte(nil), message...), ids, c)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: EncodeLTBlocksCopy = %v, want %v", c, got, want)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	original := append([]byte(nil), message...)
+
+	codecs := testCodecs()
+	for _, c := range codecs {
+		e := c.NewEncoder(message)
+		d := c.NewDecoder(len(message))
+		for id := int64(0); id < 100; id++ {
+			b := e.Generate(id)
+			want := EncodeLTBlocks(append([]byte(nil), message...), []int64{id}, c)
+			if !reflect.DeepEqual(b, want[0]) {
+				t.Fatalf("%T: Generate(%d) = %v, want %v", c, id, b, want[0])
+			}
+			if d.AddBlocks([]LTBlock{b}) {
+				break
+			}
+		}
+		if decoded := d.Decode(); !reflect.DeepEqual(decoded, message) {
+			t.Errorf("%T: decoded %q, want %q", c, decoded, message)
+		}
+		if !reflect.DeepEqual(message, original) {
+			t.Fatalf("%T: NewEncoder modified the message", c)
+		}
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewLubyCodec(4, rand.New(NewMersenneTwister(200)), SolitonDistribution(4))
+	encodeBlocks := []int64{7, 34, 5, 31, 25}
+
+	decoder := codec.NewDecoder(len(message))
+	if _, err := DecodeStrict(decoder); err == nil {
+		t.Errorf("DecodeStrict succeeded on an empty decoder")
+	}
+
+	lubyBlocks := EncodeLTBlocks(message, encodeBlocks, codec)
+	decoder.AddBlocks(lubyBlocks)
+	decoded, err := DecodeStrict(decoder)
+	if err != nil {
+		t.Fatalf("DecodeStrict failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, []byte("abcdefghijklmnopqrstuvwxyz")) {
+		t.Errorf("Decoded luby transform message is %v", string(decoded))
+	}
+
+	// Truncated code blocks leave the recovered source blocks short.
+	for i := range lubyBlocks {
+		lubyBlocks[i].Data = lubyBlocks[i].Data[:3]
+	}
+	decoder = codec.NewDecoder(len(message))
+	decoder.AddBlocks(lubyBlocks)
+	if _, err := DecodeStrict(decoder); err == nil {
+		t.Errorf("DecodeStrict succeeded with truncated code blocks")
+	}
+}
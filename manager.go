@@ -0,0 +1,132 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A receiver of many objects keeps a decoder for each transfer in progress,
+// and a transfer whose sender has gone away would otherwise hold on to its
+// decoder, and all the code blocks in it, forever. The DecoderManager gives
+// every decoder a maximum lifetime. It never consults the clock itself: the
+// caller supplies the time to Add and Expire, so expiry happens at points of
+// its choosing and is reproducible in tests.
+
+// DecoderManager tracks the decoders of concurrently received objects by
+// object ID, and discards those which outlive their time to live.
+// It is not safe for concurrent use.
+type DecoderManager struct {
+	ttl      time.Duration
+	onExpire func(object int64, diag Diagnostics)
+	decoders map[int64]*managedDecoder
+}
+
+// managedDecoder is a decoder along with the time it expires.
+type managedDecoder struct {
+	decoder Decoder
+	expires time.Time
+}
+
+// NewDecoderManager creates a DecoderManager whose decoders live for ttl
+// unless given their own lifetime. If onExpire is not nil, it is called with
+// the diagnostics of each decoder as it expires, for telemetry on abandoned
+// transfers. Diagnostics of decoders Diagnose doesn't support only have the
+// Decoder field set.
+func NewDecoderManager(ttl time.Duration, onExpire func(object int64, diag Diagnostics)) *DecoderManager {
+	return &DecoderManager{
+		ttl:      ttl,
+		onExpire: onExpire,
+		decoders: make(map[int64]*managedDecoder),
+	}
+}
+
+// Add starts tracking the decoder for an object, created at time now. It
+// expires ttl later, or after the manager's default lifetime if ttl is zero.
+// Adding a decoder for an object already tracked replaces it without calling
+// the expiry callback.
+func (m *DecoderManager) Add(object int64, d Decoder, now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = m.ttl
+	}
+	m.decoders[object] = &managedDecoder{decoder: d, expires: now.Add(ttl)}
+}
+
+// Decoder returns the decoder for an object, and false if none is tracked.
+func (m *DecoderManager) Decoder(object int64) (Decoder, bool) {
+	e, ok := m.decoders[object]
+	if !ok {
+		return nil, false
+	}
+	return e.decoder, true
+}
+
+// Expires returns the time the decoder for an object expires, and false if
+// none is tracked.
+func (m *DecoderManager) Expires(object int64) (time.Time, bool) {
+	e, ok := m.decoders[object]
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.expires, true
+}
+
+// Remove stops tracking the decoder for an object, for instance once it has
+// been decoded, and returns it. The expiry callback isn't called.
+func (m *DecoderManager) Remove(object int64) (Decoder, bool) {
+	d, ok := m.Decoder(object)
+	delete(m.decoders, object)
+	return d, ok
+}
+
+// Len returns the number of decoders tracked.
+func (m *DecoderManager) Len() int {
+	return len(m.decoders)
+}
+
+// Expire discards every decoder whose expiry time is not after now, calling
+// the expiry callback for each in order of expiry time (ties broken by object
+// ID). Returns the IDs of the expired objects in the same order.
+func (m *DecoderManager) Expire(now time.Time) []int64 {
+	var expired []int64
+	for object, e := range m.decoders {
+		if !e.expires.After(now) {
+			expired = append(expired, object)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		a, b := m.decoders[expired[i]].expires, m.decoders[expired[j]].expires
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return expired[i] < expired[j]
+	})
+
+	for _, object := range expired {
+		d := m.decoders[object].decoder
+		delete(m.decoders, object)
+		if m.onExpire == nil {
+			continue
+		}
+		diag, ok := Diagnose(d)
+		if !ok {
+			diag = Diagnostics{Decoder: fmt.Sprintf("%T", d)}
+		}
+		m.onExpire(object, diag)
+	}
+	return expired
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecoderManagerExpire(t *testing.T) {
+	var expired []int64
+	var received []int
+	m := NewDecoderManager(time.Minute, func(object int64, diag Diagnostics) {
+		expired = append(expired, object)
+		received = append(received, diag.Received)
+	})
+
+	start := time.Unix(1000, 0)
+	c := NewBinaryCodec(10)
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	for object := int64(1); object <= 4; object++ {
+		d := c.NewDecoder(len(message))
+		d.AddBlocks(EncodeLTBlocksCopy(message, []int64{1, 2, 3}[:object-1], c))
+		m.Add(object, d, start, 0)
+	}
+	m.Add(5, c.NewDecoder(len(message)), start, 10*time.Second)
+	m.Add(6, c.NewDecoder(len(message)), start.Add(30*time.Second), 0)
+
+	if got := m.Expire(start.Add(5 * time.Second)); got != nil {
+		t.Errorf("Expire before any deadline = %v, want nil", got)
+	}
+	if got, want := m.Expire(start.Add(10*time.Second)), []int64{5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expire = %v, want %v", got, want)
+	}
+	if _, ok := m.Remove(2); !ok {
+		t.Errorf("Remove(2) found no decoder")
+	}
+	if got, want := m.Expire(start.Add(time.Minute)), []int64{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expire = %v, want %v", got, want)
+	}
+
+	if want := []int64{5, 1, 3, 4}; !reflect.DeepEqual(expired, want) {
+		t.Errorf("expiry callbacks for %v, want %v", expired, want)
+	}
+	if want := []int{0, 0, 2, 3}; !reflect.DeepEqual(received, want) {
+		t.Errorf("expired decoders received %v blocks, want %v", received, want)
+	}
+	if m.Len() != 1 {
+		t.Errorf("%d decoders left, want 1", m.Len())
+	}
+	if expires, ok := m.Expires(6); !ok || !expires.Equal(start.Add(90*time.Second)) {
+		t.Errorf("Expires(6) = %v, %v, want %v", expires, ok, start.Add(90*time.Second))
+	}
+}
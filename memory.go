@@ -0,0 +1,91 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// An embedded receiver has a fixed amount of RAM for decoding, and a raptor
+// decoder's memory grows with the number of source symbols in the block it
+// decodes: it holds a value row of one symbol for each of the L intermediate
+// symbols, and coefficient lists which, as elimination fills them in, can
+// reach L(L+1)/2 indices in all. Splitting an object into more source blocks
+// of fewer symbols bounds that, provided the receiver decodes the source
+// blocks one at a time (writing each out as it completes, see DecodeTo). An
+// ObjectDecoder, which decodes all of an object's source blocks at once, needs
+// the memory of every block together.
+
+// ErrMemoryBudget is returned when an object can't be decoded within a memory
+// budget.
+var ErrMemoryBudget = errors.New("fountain: object can't be decoded within the memory budget")
+
+// rowOverhead is the memory in bytes a decoder needs per intermediate symbol
+// besides its value and coefficients: the slice headers and fields of the
+// row.
+const rowOverhead = 64
+
+// maxSourceBlocks is the most source blocks an RFC 5053 Payload ID can
+// number.
+const maxSourceBlocks = 1 << 16
+
+// RaptorDecoderMemory returns the worst-case memory in bytes of a raptor
+// decoder for a source block of k symbols of symbolSize bytes.
+func RaptorDecoderMemory(k, symbolSize int) int64 {
+	n, _, _ := intermediateSymbols(k)
+	l := int64(n)
+	return l*(int64(symbolSize)+rowOverhead) + l*(l+1)/2*bits.UintSize/8
+}
+
+// ChooseSourceBlocks picks the partitioning of an object of objectLength bytes
+// into source blocks of symbolSize-byte symbols, aligned to alignment bytes,
+// so that the worst-case memory of the decoder of any one source block (see
+// RaptorDecoderMemory) is at most memoryBudget bytes. It uses the fewest
+// source blocks that do so, since larger blocks protect against loss more
+// efficiently. The result has no sub-blocks and one symbol per packet.
+// Returns ErrMemoryBudget if even the smallest source blocks the codec allows
+// don't fit in the budget.
+func ChooseSourceBlocks(objectLength int64, symbolSize, alignment int, memoryBudget int64) (ObjectParams, error) {
+	if objectLength <= 0 || symbolSize <= 0 || alignment <= 0 {
+		return ObjectParams{}, fmt.Errorf("fountain: invalid object parameter inputs")
+	}
+	// The memory grows with K, so find the largest K within the budget.
+	n := sort.Search(MaxRaptorSourceSymbols-MinRaptorSourceSymbols+1, func(i int) bool {
+		return RaptorDecoderMemory(MinRaptorSourceSymbols+i, symbolSize) > memoryBudget
+	})
+	if n == 0 {
+		return ObjectParams{}, fmt.Errorf("%w: a block of %d %d-byte symbols needs %d bytes, over the budget of %d",
+			ErrMemoryBudget, MinRaptorSourceSymbols, symbolSize,
+			RaptorDecoderMemory(MinRaptorSourceSymbols, symbolSize), memoryBudget)
+	}
+	maxK := int64(MinRaptorSourceSymbols + n - 1)
+	z := ceilDiv(ceilDiv(objectLength, int64(symbolSize)), maxK)
+	if z > maxSourceBlocks {
+		return ObjectParams{}, fmt.Errorf("%w: %d source blocks of at most %d symbols are needed, over the limit of %d",
+			ErrMemoryBudget, z, maxK, maxSourceBlocks)
+	}
+	p := ObjectParams{
+		TransferLength:   objectLength,
+		SymbolSize:       symbolSize,
+		Alignment:        alignment,
+		SourceBlocks:     int(z),
+		SubBlocks:        1,
+		SymbolsPerPacket: 1,
+	}
+	return p, p.Validate()
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRaptorDecoderMemory(t *testing.T) {
+	prev := int64(0)
+	for k := MinRaptorSourceSymbols; k <= MaxRaptorSourceSymbols; k += 97 {
+		m := RaptorDecoderMemory(k, 1024)
+		if m <= prev {
+			t.Fatalf("RaptorDecoderMemory(%d) = %d, not above %d for fewer symbols", k, m, prev)
+		}
+		if m < int64(k)*1024 {
+			t.Fatalf("RaptorDecoderMemory(%d) = %d, less than the source block", k, m)
+		}
+		prev = m
+	}
+}
+
+func TestChooseSourceBlocks(t *testing.T) {
+	for _, test := range []struct {
+		length int64
+		t      int
+		budget int64
+		z      int
+	}{
+		{1 << 20, 1024, 1 << 30, 1},
+		{1 << 20, 1024, 2 << 20, 2},
+		{100 << 20, 1024, 4 << 20, 0},
+		{50000, 64, 256 << 10, 0},
+	} {
+		p, err := ChooseSourceBlocks(test.length, test.t, 4, test.budget)
+		if err != nil {
+			t.Errorf("ChooseSourceBlocks(%d, %d, %d) failed: %v", test.length, test.t, test.budget, err)
+			continue
+		}
+		if test.z != 0 && p.SourceBlocks != test.z {
+			t.Errorf("ChooseSourceBlocks(%d, %d, %d) chose Z=%d, want %d", test.length, test.t, test.budget, p.SourceBlocks, test.z)
+		}
+		if m := RaptorDecoderMemory(p.SourceBlockSymbols(0), p.SymbolSize); m > test.budget {
+			t.Errorf("ChooseSourceBlocks(%d, %d, %d): K=%d needs %d bytes", test.length, test.t, test.budget, p.SourceBlockSymbols(0), m)
+		}
+		// One block fewer would overflow the budget.
+		if p.SourceBlocks > 1 {
+			fewer := p
+			fewer.SourceBlocks--
+			if m := RaptorDecoderMemory(fewer.SourceBlockSymbols(0), p.SymbolSize); m <= test.budget {
+				t.Errorf("ChooseSourceBlocks(%d, %d, %d) chose Z=%d, but %d blocks fit", test.length, test.t, test.budget, p.SourceBlocks, fewer.SourceBlocks)
+			}
+		}
+	}
+
+	if _, err := ChooseSourceBlocks(1<<20, 1024, 4, 1000); !errors.Is(err, ErrMemoryBudget) {
+		t.Errorf("ChooseSourceBlocks with a tiny budget = %v, want ErrMemoryBudget", err)
+	}
+	if _, err := ChooseSourceBlocks(1<<40, 16, 4, 64<<10); !errors.Is(err, ErrMemoryBudget) {
+		t.Errorf("ChooseSourceBlocks of a huge object = %v, want ErrMemoryBudget", err)
+	}
+	if _, err := ChooseSourceBlocks(8, 4, 4, 1<<20); !errors.Is(err, ErrNonCompliant) {
+		t.Errorf("ChooseSourceBlocks of a 2-symbol object = %v, want ErrNonCompliant", err)
+	}
+}
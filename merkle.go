@@ -0,0 +1,229 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A Merkle tree over the source symbols of an object lets a receiver check
+// the object against the single digest at its root, carried in the object's
+// parameters, when it reassembles it, and lets it check a source symbol
+// it receives on its own against the root with a proof of the symbol's path
+// through the tree. Since the raptor code is systematic, source symbols are
+// the encoding symbols with ESIs below K, so a receiver can reject a corrupted
+// or forged source symbol before it enters a decoder. Container files carry
+// the root in their header, and a proof can be sent along with its symbol in
+// the encoding of AppendMerkleProof.
+//
+// The leaves are the source symbols of all the source blocks in order, each
+// zero padded to the symbol size, and hashed as SHA-256(0x00 || symbol). An
+// interior node is SHA-256(0x01 || left || right), and a node without a
+// sibling, the last of a level with an odd number of nodes, is carried up to
+// the next level unchanged.
+
+// ErrMerkleProof is returned when a source symbol or object doesn't match the
+// Merkle root of its parameters.
+var ErrMerkleProof = errors.New("fountain: data doesn't match its Merkle root")
+
+// Prefixes separating the hashes of leaves and interior nodes, so that a
+// leaf can't pass for an interior node.
+const (
+	merkleLeaf     = 0
+	merkleInterior = 1
+)
+
+// MerkleTree is a Merkle tree over the source symbols of an object.
+type MerkleTree struct {
+	params ObjectParams
+
+	// levels holds the nodes of each level of the tree, from the leaves up to
+	// the root.
+	levels [][][sha256.Size]byte
+}
+
+// NewMerkleTree computes the Merkle tree over the source symbols of an object
+// partitioned with parameters p. Set p.MerkleRoot to its root before passing p
+// to the encoder and the receivers.
+func NewMerkleTree(object []byte, p ObjectParams) (*MerkleTree, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if int64(len(object)) != p.TransferLength {
+		return nil, fmt.Errorf("fountain: object of %d bytes, but the transfer length is %d", len(object), p.TransferLength)
+	}
+	leaves := make([][sha256.Size]byte, p.symbols())
+	symbol := make([]byte, p.SymbolSize)
+	for i := range leaves {
+		n := copy(symbol, object[min(i*p.SymbolSize, len(object)):])
+		clear(symbol[n:])
+		leaves[i] = merkleLeafHash(symbol)
+	}
+	t := &MerkleTree{params: p, levels: [][][sha256.Size]byte{leaves}}
+	for level := leaves; len(level) > 1; {
+		next := make([][sha256.Size]byte, (len(level)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(level) {
+				next[i] = merkleInteriorHash(level[2*i], level[2*i+1])
+			} else {
+				next[i] = level[2*i]
+			}
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t, nil
+}
+
+// merkleLeafHash returns the hash of a leaf holding symbol.
+func merkleLeafHash(symbol []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeaf})
+	h.Write(symbol)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// merkleInteriorHash returns the hash of an interior node with the given
+// children.
+func merkleInteriorHash(left, right [sha256.Size]byte) [sha256.Size]byte {
+	var b [1 + 2*sha256.Size]byte
+	b[0] = merkleInterior
+	copy(b[1:], left[:])
+	copy(b[1+sha256.Size:], right[:])
+	return sha256.Sum256(b[:])
+}
+
+// Root returns the root of the tree.
+func (t *MerkleTree) Root() [sha256.Size]byte {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// leafIndex returns the index of the leaf of source symbol esi of source
+// block sbn, checking that there is such a source symbol.
+func (p ObjectParams) leafIndex(sbn int, esi int64) (int, error) {
+	if sbn < 0 || sbn >= p.SourceBlocks || esi < 0 || esi >= int64(p.sourceSymbols(sbn)) {
+		return 0, fmt.Errorf("fountain: no source symbol %d in source block %d", esi, sbn)
+	}
+	return p.sourceOffset(sbn) + int(esi), nil
+}
+
+// Proof returns the proof of source symbol esi of source block sbn: the
+// siblings of the nodes on its path to the root, from the leaf up, skipping
+// the levels where the node has no sibling.
+func (t *MerkleTree) Proof(sbn int, esi int64) ([][sha256.Size]byte, error) {
+	i, err := t.params.leafIndex(sbn, esi)
+	if err != nil {
+		return nil, err
+	}
+	var proof [][sha256.Size]byte
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if sibling := i ^ 1; sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		i /= 2
+	}
+	return proof, nil
+}
+
+// maxMerkleProofLen is the most hashes a proof can hold: one per level of a
+// tree with a leaf per possible source symbol.
+const maxMerkleProofLen = 64
+
+// AppendMerkleProof appends the wire encoding of a proof to b: the number of
+// hashes as a uvarint, then the hashes.
+func AppendMerkleProof(b []byte, proof [][sha256.Size]byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(proof)))
+	for _, h := range proof {
+		b = append(b, h[:]...)
+	}
+	return b
+}
+
+// ParseMerkleProof decodes the proof at the start of b, written by
+// AppendMerkleProof, and returns it along with the remainder of b.
+func ParseMerkleProof(b []byte) ([][sha256.Size]byte, []byte, error) {
+	n, m := binary.Uvarint(b)
+	if m <= 0 {
+		return nil, nil, ErrShortWireData
+	}
+	b = b[m:]
+	if n > maxMerkleProofLen {
+		return nil, nil, fmt.Errorf("fountain: Merkle proof of %d hashes", n)
+	}
+	if uint64(len(b)) < n*sha256.Size {
+		return nil, nil, ErrShortWireData
+	}
+	proof := make([][sha256.Size]byte, n)
+	for i := range proof {
+		b = b[copy(proof[i][:], b):]
+	}
+	return proof, b, nil
+}
+
+// VerifySourceSymbol checks a source symbol against p.MerkleRoot with its
+// proof. Returns an error wrapping ErrMerkleProof if it doesn't match.
+func VerifySourceSymbol(p ObjectParams, s ObjectSymbol, proof [][sha256.Size]byte) error {
+	if p.MerkleRoot == ([sha256.Size]byte{}) {
+		return errors.New("fountain: parameters have no Merkle root")
+	}
+	i, err := p.leafIndex(s.SBN, s.BlockCode)
+	if err != nil {
+		return err
+	}
+	if len(s.Data) != p.SymbolSize {
+		return fmt.Errorf("fountain: symbol of %d bytes, want %d", len(s.Data), p.SymbolSize)
+	}
+	node := merkleLeafHash(s.Data)
+	for n := p.symbols(); n > 1; n = (n + 1) / 2 {
+		sibling := i ^ 1
+		if sibling < n {
+			if len(proof) == 0 {
+				return fmt.Errorf("%w: proof too short", ErrMerkleProof)
+			}
+			if i%2 == 0 {
+				node = merkleInteriorHash(node, proof[0])
+			} else {
+				node = merkleInteriorHash(proof[0], node)
+			}
+			proof = proof[1:]
+		}
+		i /= 2
+	}
+	if len(proof) != 0 || node != p.MerkleRoot {
+		return fmt.Errorf("%w: source symbol %d of source block %d", ErrMerkleProof, s.BlockCode, s.SBN)
+	}
+	return nil
+}
+
+// verifyMerkleRoot checks an object reassembled from its source symbols,
+// padded to whole symbols, against p.MerkleRoot, if it is set.
+func (p ObjectParams) verifyMerkleRoot(padded []byte) error {
+	if p.MerkleRoot == ([sha256.Size]byte{}) {
+		return nil
+	}
+	t, err := NewMerkleTree(padded[:p.TransferLength], p)
+	if err != nil {
+		return err
+	}
+	if t.Root() != p.MerkleRoot {
+		return fmt.Errorf("%w: decoded object", ErrMerkleProof)
+	}
+	return nil
+}
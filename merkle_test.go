@@ -0,0 +1,152 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// merkleObject returns an object, its parameters with the Merkle root set,
+// and its Merkle tree.
+func merkleObject(t *testing.T) ([]byte, ObjectParams, *MerkleTree) {
+	t.Helper()
+	object := make([]byte, 1000)
+	rand.New(rand.NewSource(4)).Read(object)
+	// 42 source symbols in 3 source blocks, the last one padded.
+	p := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 3, SubBlocks: 2, SymbolsPerPacket: 1}
+	tree, err := NewMerkleTree(object, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.MerkleRoot = tree.Root()
+	return object, p, tree
+}
+
+func TestMerkleSourceSymbols(t *testing.T) {
+	object, p, tree := merkleObject(t)
+	e, err := NewObjectEncoder(object, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sbn := 0; sbn < p.SourceBlocks; sbn++ {
+		for esi := int64(0); esi < int64(p.SourceBlockSymbols(sbn)); esi++ {
+			s, _ := e.Symbol(sbn, esi)
+			proof, err := tree.Proof(sbn, esi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Proofs are sent along with their symbols.
+			proof, rest, err := ParseMerkleProof(AppendMerkleProof(nil, proof))
+			if err != nil || len(rest) != 0 {
+				t.Fatalf("ParseMerkleProof = %v, %x, %v", proof, rest, err)
+			}
+			if err := VerifySourceSymbol(p, s, proof); err != nil {
+				t.Errorf("VerifySourceSymbol(%d/%d) = %v", sbn, esi, err)
+			}
+			s.Data[0] ^= 1
+			if err := VerifySourceSymbol(p, s, proof); !errors.Is(err, ErrMerkleProof) {
+				t.Errorf("VerifySourceSymbol(corrupt %d/%d) = %v, want ErrMerkleProof", sbn, esi, err)
+			}
+		}
+	}
+
+	// A proof is only good for its own symbol.
+	s, _ := e.Symbol(1, 3)
+	proof, _ := tree.Proof(1, 4)
+	if err := VerifySourceSymbol(p, s, proof); !errors.Is(err, ErrMerkleProof) {
+		t.Errorf("VerifySourceSymbol with another symbol's proof = %v, want ErrMerkleProof", err)
+	}
+	proof, _ = tree.Proof(1, 3)
+	if err := VerifySourceSymbol(p, s, proof[1:]); !errors.Is(err, ErrMerkleProof) {
+		t.Errorf("VerifySourceSymbol with a short proof = %v, want ErrMerkleProof", err)
+	}
+	if _, err := tree.Proof(0, int64(p.SourceBlockSymbols(0))); err == nil {
+		t.Error("Proof of a repair symbol succeeded")
+	}
+	p.MerkleRoot = [sha256.Size]byte{}
+	if err := VerifySourceSymbol(p, s, proof); err == nil {
+		t.Error("VerifySourceSymbol without a Merkle root succeeded")
+	}
+}
+
+func TestParseMerkleProofErrors(t *testing.T) {
+	_, _, tree := merkleObject(t)
+	proof, _ := tree.Proof(0, 0)
+	b := AppendMerkleProof(nil, proof)
+	for n := 0; n < len(b); n++ {
+		if _, _, err := ParseMerkleProof(b[:n]); !errors.Is(err, ErrShortWireData) {
+			t.Errorf("ParseMerkleProof of %d bytes = %v, want ErrShortWireData", n, err)
+		}
+	}
+	if _, _, err := ParseMerkleProof([]byte{65}); err == nil {
+		t.Error("ParseMerkleProof of 65 hashes succeeded")
+	}
+}
+
+func TestMerkleObjectDecoder(t *testing.T) {
+	object, p, _ := merkleObject(t)
+	e, err := NewObjectEncoder(object, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(corrupt bool) ([]byte, error) {
+		d, err := NewObjectDecoder(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for sbn := 0; sbn < p.SourceBlocks; sbn++ {
+			for esi := int64(0); esi < int64(p.SourceBlockSymbols(sbn)); esi++ {
+				s, _ := e.Symbol(sbn, esi)
+				if corrupt && sbn == 2 && esi == 5 {
+					s.Data[3] ^= 0x80
+				}
+				d.AddSymbols([]ObjectSymbol{s})
+			}
+		}
+		return d.Object()
+	}
+	got, err := decode(false)
+	if err != nil || !bytes.Equal(got, object) {
+		t.Errorf("Object = %d bytes, %v; want the object", len(got), err)
+	}
+	if _, err := decode(true); !errors.Is(err, ErrMerkleProof) {
+		t.Errorf("Object with a corrupt symbol = %v, want ErrMerkleProof", err)
+	}
+}
+
+func TestMerkleTreeShapes(t *testing.T) {
+	// Trees of every size up to a few levels verify every leaf.
+	for k := 4; k <= 19; k++ {
+		object := bytes.Repeat([]byte{byte(k)}, k*4)
+		object[0] = 0
+		p := ObjectParams{TransferLength: int64(len(object)), SymbolSize: 4, Alignment: 4, SourceBlocks: 1, SubBlocks: 1, SymbolsPerPacket: 1}
+		tree, err := NewMerkleTree(object, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.MerkleRoot = tree.Root()
+		for esi := 0; esi < k; esi++ {
+			proof, _ := tree.Proof(0, int64(esi))
+			s := ObjectSymbol{LTBlock: LTBlock{BlockCode: int64(esi), Data: object[esi*4 : esi*4+4]}}
+			if err := VerifySourceSymbol(p, s, proof); err != nil {
+				t.Errorf("K=%d: VerifySourceSymbol(%d) = %v", k, esi, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// The raptor codec encodes a single source block, of at most 8192 symbols in
+// RFC 5053. RFC 5053 §4.2 describes how to carry larger objects: the object is
+// partitioned into Z source blocks, each encoded separately, and to bound the
+// working memory of receivers each source block may be further split into N
+// sub-blocks. A sub-block holds one sub-symbol (a slice of Al-aligned bytes)
+// of each of the source block's symbols, and is encoded separately too; the
+// encoding symbol with a given ESI is the concatenation of the sub-blocks'
+// encoding symbols with that ESI.
+
+// ObjectParams describe how an object is partitioned and encoded, per RFC 5053
+// §4.2. They include the FEC Object Transmission Information receivers need.
+type ObjectParams struct {
+	// TransferLength is the length of the object in bytes (F).
+	TransferLength int64
+
+	// SymbolSize is the size of an encoding symbol in bytes (T).
+	SymbolSize int
+
+	// Alignment is the symbol alignment in bytes (Al).
+	Alignment int
+
+	// SourceBlocks is the number of source blocks (Z).
+	SourceBlocks int
+
+	// SubBlocks is the number of sub-blocks in each source block (N).
+	SubBlocks int
+
+	// SymbolsPerPacket is the number of symbols sent in each packet (G).
+	SymbolsPerPacket int
+
+	// MerkleRoot, if not zero, is the root of the Merkle tree over the
+	// object's source symbols (see NewMerkleTree), which an ObjectDecoder
+	// checks the object against.
+	MerkleRoot [sha256.Size]byte
+
+	// Compression is how the object was compressed before it was partitioned
+	// (see WithCompression). TransferLength is then the compressed length.
+	Compression Compression
+
+	// ContentLength is the length of a compressed object before compression,
+	// which an ObjectDecoder decompresses no more than. It is zero for an
+	// object which isn't compressed.
+	ContentLength int64
+}
+
+// DeriveObjectParams computes the parameters of an object of transferLength
+// bytes following the example derivation of RFC 5053 §4.2.2: alignment is Al,
+// payloadSize the largest packet payload (P), maxSubBlock the largest
+// sub-block a receiver can decode in working memory (W), minSymbols the least
+// number of symbols per source block targeted (Kmin), and maxSymbolsPerPacket
+// the most symbols per packet (Gmax).
+func DeriveObjectParams(transferLength int64, alignment, payloadSize, maxSubBlock, minSymbols, maxSymbolsPerPacket int) (ObjectParams, error) {
+	if transferLength <= 0 || alignment <= 0 || payloadSize < alignment || maxSubBlock <= 0 ||
+		minSymbols <= 0 || maxSymbolsPerPacket <= 0 {
+		return ObjectParams{}, fmt.Errorf("fountain: invalid object parameter inputs")
+	}
+	f := transferLength
+	g := int(ceilDiv(int64(payloadSize)*int64(minSymbols), f))
+	if g > payloadSize/alignment {
+		g = payloadSize / alignment
+	}
+	if g > maxSymbolsPerPacket {
+		g = maxSymbolsPerPacket
+	}
+	t := payloadSize / (alignment * g) * alignment
+	kt := ceilDiv(f, int64(t))
+	z := int(ceilDiv(kt, MaxRaptorSourceSymbols))
+	n := int(ceilDiv(ceilDiv(kt, int64(z))*int64(t), int64(maxSubBlock)))
+	if n > t/alignment {
+		n = t / alignment
+	}
+	p := ObjectParams{
+		TransferLength:   transferLength,
+		SymbolSize:       t,
+		Alignment:        alignment,
+		SourceBlocks:     z,
+		SubBlocks:        n,
+		SymbolsPerPacket: g,
+	}
+	return p, p.Validate()
+}
+
+// ceilDiv returns ceil(a/b) for positive a and b.
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
+
+// Validate checks that the parameters describe a partitioning the raptor
+// codec can encode: T a multiple of Al, between 1 and T/Al sub-blocks, and
+// between 4 and 8192 symbols in every source block.
+func (p ObjectParams) Validate() error {
+	if p.TransferLength <= 0 || p.Alignment <= 0 || p.SymbolSize <= 0 || p.SymbolSize%p.Alignment != 0 {
+		return fmt.Errorf("%w: F=%d, T=%d, Al=%d", ErrNonCompliant, p.TransferLength, p.SymbolSize, p.Alignment)
+	}
+	if p.SourceBlocks <= 0 || p.SubBlocks <= 0 || p.SubBlocks > p.SymbolSize/p.Alignment || p.SymbolsPerPacket <= 0 {
+		return fmt.Errorf("%w: Z=%d, N=%d, G=%d", ErrNonCompliant, p.SourceBlocks, p.SubBlocks, p.SymbolsPerPacket)
+	}
+	for _, k := range []int{p.sourceSymbols(0), p.sourceSymbols(p.SourceBlocks - 1)} {
+		if k < MinRaptorSourceSymbols || k > MaxRaptorSourceSymbols {
+			return fmt.Errorf("%w: K=%d is outside [%d, %d]", ErrNonCompliant, k,
+				MinRaptorSourceSymbols, MaxRaptorSourceSymbols)
+		}
+	}
+	if _, ok := lookupCompression(p.Compression); !ok && p.Compression != CompressionNone {
+		return fmt.Errorf("fountain: unknown compression %d", p.Compression)
+	}
+	if (p.Compression == CompressionNone) != (p.ContentLength == 0) || p.ContentLength < 0 {
+		return fmt.Errorf("fountain: content length %d with compression %d", p.ContentLength, p.Compression)
+	}
+	return nil
+}
+
+// ErrObjectTooLarge is returned (wrapped) when parameters received from a
+// peer describe an object larger than the receiver accepts.
+var ErrObjectTooLarge = errors.New("fountain: object too large")
+
+// DefaultMaxObjectLength is the length of the largest object decoded from
+// parameters read from a container or the network, unless the caller says
+// otherwise. The parameters allow objects of up to about a terabyte.
+const DefaultMaxObjectLength = 1 << 32
+
+// CheckLength returns ErrObjectTooLarge (wrapped) if decoding an object with
+// the parameters takes a buffer of more than maxLength bytes: for its source
+// symbols, or once decompressed, for the object. Receivers should check
+// parameters they are sent before decoding with them.
+func (p ObjectParams) CheckLength(maxLength int64) error {
+	if p.paddedLength() > maxLength || p.ContentLength > maxLength {
+		return fmt.Errorf("%w: %d bytes in %d-byte symbols, or %d bytes decompressed, more than %d",
+			ErrObjectTooLarge, p.TransferLength, p.SymbolSize, p.ContentLength, maxLength)
+	}
+	return nil
+}
+
+// paddedLength returns the length of the object's source symbols, Kt*T.
+func (p ObjectParams) paddedLength() int64 {
+	return ceilDiv(p.TransferLength, int64(p.SymbolSize)) * int64(p.SymbolSize)
+}
+
+// PaddingBytes returns the number of zero bytes an ObjectEncoder adds to the
+// object to fill its last source symbol, the counterpart for objects of the
+// package-level PaddingBytes. Every symbol sent carries SymbolSize bytes, of
+// which TransferLength/Kt are object data on average.
+func (p ObjectParams) PaddingBytes() int64 {
+	return p.paddedLength() - p.TransferLength
+}
+
+// symbols returns the total number of source symbols in the object (Kt).
+func (p ObjectParams) symbols() int {
+	return int(ceilDiv(p.TransferLength, int64(p.SymbolSize)))
+}
+
+// sourceBlocks returns the partition of the object's symbols into source
+// blocks, (KL, KS, ZL, ZS), as RFC 5053 specifies it.
+func (p ObjectParams) sourceBlocks() Partition {
+	return RFC5053Partitioner.Partition(p.symbols(), p.SourceBlocks)
+}
+
+// sourceSymbols returns the number of source symbols (K) in source block sbn.
+// The first ZL source blocks have KL symbols, and the rest KS.
+func (p ObjectParams) sourceSymbols(sbn int) int {
+	return p.sourceBlocks().Size(sbn)
+}
+
+// sourceOffset returns the offset in the object of source block sbn, in
+// symbols.
+func (p ObjectParams) sourceOffset(sbn int) int {
+	return p.sourceBlocks().Offset(sbn)
+}
+
+// subSymbol returns the offset and size in bytes of sub-block j's sub-symbol
+// within each symbol. The first NL sub-blocks have sub-symbols of TL*Al bytes,
+// and the rest TS*Al.
+func (p ObjectParams) subSymbol(j int) (offset, size int) {
+	sub := RFC5053Partitioner.SubBlocks(p.SymbolSize, p.Alignment, p.SubBlocks)
+	return sub.Offset(j), sub.Size(j)
+}
+
+// SourceBlockSymbols returns the number of source symbols in source block sbn.
+func (p ObjectParams) SourceBlockSymbols(sbn int) int {
+	return p.sourceSymbols(sbn)
+}
+
+// ObjectSymbol is an encoding symbol of one source block of an object.
+type ObjectSymbol struct {
+	// SBN is the source block number.
+	SBN int
+
+	// LTBlock holds the symbol's ESI and data.
+	LTBlock
+}
+
+// ObjectEncoder encodes an object partitioned into source blocks and
+// sub-blocks.
+type ObjectEncoder struct {
+	params ObjectParams
+
+	// encoders holds the encoder of each sub-block of each source block.
+	encoders [][]Encoder
+}
+
+// NewObjectEncoder creates an encoder for an object of p.TransferLength
+// bytes. The object is left untouched. Receivers must decode with the
+// encoder's Params, which differ from p if the object is compressed.
+func NewObjectEncoder(object []byte, p ObjectParams, opts ...ObjectEncoderOption) (*ObjectEncoder, error) {
+	object, p, err := prepareObject(object, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	e := &ObjectEncoder{params: p, encoders: make([][]Encoder, p.SourceBlocks)}
+	for sbn := range e.encoders {
+		e.encoders[sbn] = p.sourceBlockEncoders(object, sbn, NewRaptorCodec)
+	}
+	return e, nil
+}
+
+// prepareObject checks an object to encode with parameters p, and compresses
+// it if the options ask to. Returns the object to partition and the
+// parameters to encode it with.
+func prepareObject(object []byte, p ObjectParams, opts []ObjectEncoderOption) ([]byte, ObjectParams, error) {
+	if err := p.Validate(); err != nil {
+		return nil, ObjectParams{}, err
+	}
+	if int64(len(object)) != p.TransferLength {
+		return nil, ObjectParams{}, fmt.Errorf("fountain: object of %d bytes, but the transfer length is %d", len(object), p.TransferLength)
+	}
+	var config objectEncoderConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.compression != CompressionNone {
+		if p.Compression != CompressionNone {
+			return nil, ObjectParams{}, fmt.Errorf("fountain: object to compress is already compressed with %d", p.Compression)
+		}
+		if p.MerkleRoot != ([sha256.Size]byte{}) {
+			return nil, ObjectParams{}, fmt.Errorf("fountain: a Merkle root can't cover an object the encoder compresses")
+		}
+		compressed, err := compressObject(object, config.compression)
+		if err != nil {
+			return nil, ObjectParams{}, err
+		}
+		if q, ok := p.compressedParams(int64(len(compressed)), config.compression); ok {
+			object, p = compressed, q
+		}
+	}
+	return object, p, nil
+}
+
+// sourceBlockEncoders creates the encoders of the sub-blocks of source block
+// sbn of an object partitioned with p, with the codecs newCodec creates for
+// the source block's number of symbols and p's alignment.
+func (p ObjectParams) sourceBlockEncoders(object []byte, sbn int, newCodec func(sourceSymbols, alignment int) Codec) []Encoder {
+	k := p.sourceSymbols(sbn)
+	start := p.sourceOffset(sbn) * p.SymbolSize
+	encoders := make([]Encoder, p.SubBlocks)
+	for j := range encoders {
+		offset, size := p.subSymbol(j)
+		// Gather sub-block j, zero padding the end of the object.
+		sub := make([]byte, k*size)
+		for s := 0; s < k; s++ {
+			from := start + s*p.SymbolSize + offset
+			if from < len(object) {
+				to := from + size
+				if to > len(object) {
+					to = len(object)
+				}
+				copy(sub[s*size:], object[from:to])
+			}
+		}
+		encoders[j] = intermediateEncoder(newCodec(k, p.Alignment), sub)
+	}
+	return encoders
+}
+
+// Params returns the parameters of the encoding.
+func (e *ObjectEncoder) Params() ObjectParams {
+	return e.params
+}
+
+// Symbol returns the encoding symbol with the given ESI of source block sbn.
+// ESIs below the source block's number of source symbols are the source
+// symbols themselves.
+func (e *ObjectEncoder) Symbol(sbn int, esi int64) (ObjectSymbol, error) {
+	if sbn < 0 || sbn >= len(e.encoders) {
+		return ObjectSymbol{}, fmt.Errorf("fountain: source block %d of %d", sbn, len(e.encoders))
+	}
+	if esi < 0 || esi > MaxRaptorESI {
+		return ObjectSymbol{}, fmt.Errorf("%w: ESI %d is outside [0, %d]", ErrNonCompliant, esi, MaxRaptorESI)
+	}
+	return sourceBlockSymbol(e.encoders[sbn], sbn, esi, e.params.SymbolSize), nil
+}
+
+// sourceBlockSymbol returns the encoding symbol with the given ESI of source
+// block sbn, the concatenation of that of each of its sub-blocks' encoders.
+func sourceBlockSymbol(encoders []Encoder, sbn int, esi int64, symbolSize int) ObjectSymbol {
+	data := make([]byte, 0, symbolSize)
+	for _, sub := range encoders {
+		data = append(data, sub.Generate(esi).Data...)
+	}
+	return ObjectSymbol{SBN: sbn, LTBlock: LTBlock{BlockCode: esi, Data: data}}
+}
+
+// SourceBlockProgress reports how far decoding of a source block has got.
+type SourceBlockProgress struct {
+	// SourceSymbols is the number of source symbols in the block (K), the
+	// least number of symbols which can decode it.
+	SourceSymbols int
+
+	// Received is the number of symbols of the block received.
+	Received int
+
+	// Complete is set once the block can be decoded.
+	Complete bool
+}
+
+// ObjectDecoder decodes an object encoded by an ObjectEncoder, demultiplexing
+// the symbols it receives by source block number.
+type ObjectDecoder struct {
+	params ObjectParams
+
+	// decoders holds the decoder of each sub-block of each source block.
+	decoders [][]Decoder
+	progress []SourceBlockProgress
+}
+
+// NewObjectDecoder creates a decoder for an object encoded with the given
+// parameters.
+func NewObjectDecoder(p ObjectParams) (*ObjectDecoder, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	d := &ObjectDecoder{
+		params:   p,
+		decoders: make([][]Decoder, p.SourceBlocks),
+		progress: make([]SourceBlockProgress, p.SourceBlocks),
+	}
+	for sbn := range d.decoders {
+		k := p.sourceSymbols(sbn)
+		d.progress[sbn].SourceSymbols = k
+		d.decoders[sbn] = make([]Decoder, p.SubBlocks)
+		for j := range d.decoders[sbn] {
+			_, size := p.subSymbol(j)
+			d.decoders[sbn][j] = NewRaptorCodec(k, p.Alignment).NewDecoder(k * size)
+		}
+	}
+	return d, nil
+}
+
+// AddSymbols adds received symbols to the decoders of their source blocks,
+// and returns the numbers of the source blocks which became decodable as a
+// result. Symbols of unknown source blocks, or whose size isn't the symbol
+// size, are ignored. Like code blocks given to a Decoder, the symbols' data
+// is used in place.
+func (d *ObjectDecoder) AddSymbols(symbols []ObjectSymbol) []int {
+	var complete []int
+	for _, s := range symbols {
+		if s.SBN < 0 || s.SBN >= len(d.decoders) || len(s.Data) != d.params.SymbolSize {
+			continue
+		}
+		progress := &d.progress[s.SBN]
+		progress.Received++
+		if progress.Complete {
+			continue
+		}
+		determined := true
+		for j, sub := range d.decoders[s.SBN] {
+			offset, size := d.params.subSymbol(j)
+			b := LTBlock{BlockCode: s.BlockCode, Data: s.Data[offset : offset+size : offset+size]}
+			if !sub.AddBlocks([]LTBlock{b}) {
+				determined = false
+			}
+		}
+		if determined {
+			progress.Complete = true
+			complete = append(complete, s.SBN)
+		}
+	}
+	return complete
+}
+
+// Progress returns the progress of each source block.
+func (d *ObjectDecoder) Progress() []SourceBlockProgress {
+	return append([]SourceBlockProgress(nil), d.progress...)
+}
+
+// Complete reports whether every source block can be decoded.
+func (d *ObjectDecoder) Complete() bool {
+	for _, p := range d.progress {
+		if !p.Complete {
+			return false
+		}
+	}
+	return true
+}
+
+// Object reassembles the decoded object, decompressing it if it was
+// compressed. Returns an error if any source block can't be decoded yet, or
+// one wrapping ErrMerkleProof if the parameters have a Merkle root which the
+// object doesn't match.
+func (d *ObjectDecoder) Object() ([]byte, error) {
+	p := d.params
+	for sbn := range d.decoders {
+		if !d.progress[sbn].Complete {
+			return nil, fmt.Errorf("fountain: source block %d is not yet decoded", sbn)
+		}
+	}
+	object := make([]byte, p.symbols()*p.SymbolSize)
+	for sbn, subs := range d.decoders {
+		start := p.sourceOffset(sbn) * p.SymbolSize
+		for j, sub := range subs {
+			data, err := DecodeStrict(sub)
+			if err != nil {
+				return nil, fmt.Errorf("fountain: source block %d sub-block %d: %v", sbn, j, err)
+			}
+			// Scatter sub-block j's sub-symbols back into the symbols.
+			offset, size := p.subSymbol(j)
+			for s := 0; s < len(data)/size; s++ {
+				copy(object[start+s*p.SymbolSize+offset:], data[s*size:(s+1)*size])
+			}
+		}
+	}
+	if err := p.verifyMerkleRoot(object); err != nil {
+		return nil, err
+	}
+	if p.Compression != CompressionNone {
+		return decompressObject(object[:p.TransferLength], p.Compression, p.ContentLength)
+	}
+	return object[:p.TransferLength], nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+var deriveObjectParamsTests = []struct {
+	length                            int64
+	alignment, payload, w, kmin, gmax int
+	want                              ObjectParams
+}{
+	// A small object gets small symbols, several to a packet.
+	{10000, 4, 1024, 1 << 18, 1024, 10, ObjectParams{TransferLength: 10000, SymbolSize: 100, Alignment: 4, SourceBlocks: 1, SubBlocks: 1, SymbolsPerPacket: 10}},
+	// A large one needs several source blocks, and sub-blocks to fit in W.
+	{50 << 20, 4, 1024, 1 << 20, 1024, 10, ObjectParams{TransferLength: 50 << 20, SymbolSize: 1024, Alignment: 4, SourceBlocks: 7, SubBlocks: 8, SymbolsPerPacket: 1}},
+}
+
+func TestDeriveObjectParams(t *testing.T) {
+	for _, test := range deriveObjectParamsTests {
+		p, err := DeriveObjectParams(test.length, test.alignment, test.payload, test.w, test.kmin, test.gmax)
+		if err != nil {
+			t.Errorf("DeriveObjectParams(%d) failed: %v", test.length, err)
+			continue
+		}
+		if p != test.want {
+			t.Errorf("DeriveObjectParams(%d) = %+v, want %+v", test.length, p, test.want)
+		}
+	}
+	if _, err := DeriveObjectParams(3, 1, 1, 100, 1, 1); err == nil {
+		t.Errorf("DeriveObjectParams accepted a 3 symbol object")
+	}
+}
+
+func TestObjectEncoder(t *testing.T) {
+	random := rand.New(rand.NewSource(9))
+	object := make([]byte, 1000)
+	random.Read(object)
+	p := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 3, SubBlocks: 4, SymbolsPerPacket: 1}
+
+	e, err := NewObjectEncoder(object, p)
+	if err != nil {
+		t.Fatalf("NewObjectEncoder failed: %v", err)
+	}
+
+	// Kt = 42 symbols, as source blocks of 14 symbols. The source symbols are
+	// the object itself, zero padded.
+	padded := append(append([]byte(nil), object...), make([]byte, 42*24-1000)...)
+	if n := e.Params().PaddingBytes(); n != 42*24-1000 {
+		t.Errorf("PaddingBytes() = %d, want %d", n, 42*24-1000)
+	}
+	for sbn := 0; sbn < 3; sbn++ {
+		if k := p.SourceBlockSymbols(sbn); k != 14 {
+			t.Errorf("source block %d has %d symbols, want 14", sbn, k)
+		}
+		for esi := int64(0); esi < 14; esi++ {
+			s, err := e.Symbol(sbn, esi)
+			if err != nil {
+				t.Fatalf("Symbol(%d, %d) failed: %v", sbn, esi, err)
+			}
+			start := (sbn*14 + int(esi)) * 24
+			if !bytes.Equal(s.Data, padded[start:start+24]) {
+				t.Errorf("source symbol %d of block %d is %x, want %x", esi, sbn, s.Data, padded[start:start+24])
+			}
+		}
+	}
+
+	if _, err := e.Symbol(3, 0); err == nil {
+		t.Errorf("Symbol accepted source block 3")
+	}
+	if _, err := e.Symbol(0, MaxRaptorESI+1); err == nil {
+		t.Errorf("Symbol accepted ESI %d", MaxRaptorESI+1)
+	}
+}
+
+func TestObjectDecoder(t *testing.T) {
+	random := rand.New(rand.NewSource(9))
+	object := make([]byte, 1000)
+	random.Read(object)
+	p := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 3, SubBlocks: 4, SymbolsPerPacket: 1}
+
+	e, err := NewObjectEncoder(object, p)
+	if err != nil {
+		t.Fatalf("NewObjectEncoder failed: %v", err)
+	}
+	d, err := NewObjectDecoder(p)
+	if err != nil {
+		t.Fatalf("NewObjectDecoder failed: %v", err)
+	}
+
+	// Send the source blocks' repair symbols interleaved, losing a third.
+	loss := NewBernoulliLoss(0.3, random)
+	var order []int
+	for esi := int64(20); !d.Complete() && esi < 200; esi++ {
+		for sbn := 0; sbn < 3; sbn++ {
+			s, err := e.Symbol(sbn, esi)
+			if err != nil {
+				t.Fatalf("Symbol failed: %v", err)
+			}
+			if loss.Lost() {
+				continue
+			}
+			order = append(order, d.AddSymbols([]ObjectSymbol{s})...)
+		}
+		if _, err := d.Object(); (err == nil) != d.Complete() {
+			t.Errorf("Object error %v with Complete() = %v", err, d.Complete())
+		}
+	}
+	if len(order) != 3 {
+		t.Errorf("source blocks completed in order %v, want all 3 once", order)
+	}
+	for sbn, progress := range d.Progress() {
+		if !progress.Complete || progress.SourceSymbols != 14 || progress.Received < 14 {
+			t.Errorf("source block %d progress %+v", sbn, progress)
+		}
+	}
+	decoded, err := d.Object()
+	if err != nil {
+		t.Fatalf("Object failed: %v", err)
+	}
+	if !bytes.Equal(decoded, object) {
+		t.Errorf("decoded object differs from the original")
+	}
+}
+
+func TestObjectParamsCheckLength(t *testing.T) {
+	p := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 3, SubBlocks: 4, SymbolsPerPacket: 1}
+	// The source symbols pad the object to 1008 bytes.
+	if err := p.CheckLength(1008); err != nil {
+		t.Errorf("CheckLength(1008) = %v, want nil", err)
+	}
+	if err := p.CheckLength(1007); !errors.Is(err, ErrObjectTooLarge) {
+		t.Errorf("CheckLength(1007) = %v, want ErrObjectTooLarge", err)
+	}
+	p.Compression, p.ContentLength = CompressionDeflate, 5000
+	if err := p.CheckLength(4999); !errors.Is(err, ErrObjectTooLarge) {
+		t.Errorf("CheckLength(4999) of a compressed object = %v, want ErrObjectTooLarge", err)
+	}
+}
+
+func TestObjectParamsPartitioner(t *testing.T) {
+	// Kt = 42 symbols of 24 bytes, as 4 source blocks of 11, 11, 10 and 10,
+	// and 6 aligned units of 4 bytes, as 4 sub-blocks of 2, 2, 1 and 1 units.
+	p := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 4, SubBlocks: 4, SymbolsPerPacket: 1}
+	blocks := RFC5053Partitioner.SourceBlocks(1000, 24, 4)
+	subs := RFC5053Partitioner.SubBlocks(24, 4, 4)
+	for i, want := range []int{11, 11, 10, 10} {
+		if k, offset := p.sourceSymbols(i), p.sourceOffset(i); k != want || k != blocks.Size(i) || offset != blocks.Offset(i) {
+			t.Errorf("source block %d: %d symbols at %d, want %d at %d", i, k, offset, want, blocks.Offset(i))
+		}
+	}
+	for j, want := range []int{8, 8, 4, 4} {
+		if offset, size := p.subSymbol(j); size != want || size != subs.Size(j) || offset != subs.Offset(j) {
+			t.Errorf("sub-block %d: %d bytes at %d, want %d at %d", j, size, offset, want, subs.Offset(j))
+		}
+	}
+}
@@ -75,6 +75,10 @@ type onlineCodec struct {
 
 	// cdf is the cumulative distribution function of the degree distribution.
 	cdf []float64
+
+	// sampling is how the indices of code blocks and auxiliary blocks are
+	// sampled (see WithIndexSampling).
+	sampling IndexSampling
 }
 
 // NewOnlineCodec creates a new encoder for an Online code.
@@ -92,7 +96,7 @@ func NewOnlineCodec(sourceBlocks int, epsilon float64, quality int, seed int64)
 		quality:         quality,
 		numSourceBlocks: sourceBlocks,
 		randomSeed:      seed,
-		cdf:             onlineSolitonDistribution(epsilon)}
+		cdf:             OnlineSolitonDistribution(epsilon)}
 }
 
 // SourceBlocks returns the number of source blocks into which the codec will
@@ -146,7 +150,7 @@ func generateOuterEncoding(message []byte, codec onlineCodec) ([]block, []block)
 
 	random := rand.New(NewMersenneTwister(codec.randomSeed))
 	for i := 0; i < codec.numSourceBlocks; i++ {
-		touchAuxBlocks := sampleUniform(random, codec.quality, numAuxBlocks)
+		touchAuxBlocks := codec.sampling.sample(random, codec.quality, numAuxBlocks)
 		for _, j := range touchAuxBlocks {
 			aux[j].xor(source[i])
 		}
@@ -175,15 +179,25 @@ func generateCodeBlock(source []block, aux []block, indices []int) block {
 // PickIndices finds the source indices for a code block given an ID using
 // the CDF for the online degree distribution.
 func (c *onlineCodec) PickIndices(codeBlockIndex int64) []int {
-	random := rand.New(NewMersenneTwister(codeBlockIndex))
+	return c.pickIndices(rand.New(NewMersenneTwister(codeBlockIndex)))
+}
 
+// pickIndices picks the source indices using the given PRNG.
+func (c *onlineCodec) pickIndices(random *rand.Rand) []int {
 	degree := pickDegree(random, c.cdf)
 	// Pick blocks from the augmented set of original+aux blocks produced
 	// by GenerateIntermediateBlocks.
-	s := sampleUniform(random, degree, c.SourceBlocks()+c.numAuxBlocks())
+	s := c.sampling.sample(random, degree, c.SourceBlocks()+c.numAuxBlocks())
 	return s
 }
 
+// tracePRNG records the PRNG draws of PickIndices.
+func (c *onlineCodec) tracePRNG(codeBlockIndex int64) (PRNGTrace, bool) {
+	src := &countingSource{Source: NewMersenneTwister(codeBlockIndex)}
+	c.pickIndices(rand.New(src))
+	return PRNGTrace{BlockCode: codeBlockIndex, Generator: "mt19937", Seed: []uint64{uint64(codeBlockIndex)}, Draws: src.draws}, true
+}
+
 // encodeOnlineBlocks creates a set of online code blocks given the ids provided.
 // An easy way to generate the ids is to pick a pseudo-random sequence and then
 // just grab the first M members of the sequence.
@@ -222,6 +236,11 @@ func (c *onlineCodec) NewDecoder(messageLength int) Decoder {
 	return newOnlineDecoder(c, messageLength)
 }
 
+// NewEncoder creates a new online code encoder
+func (c *onlineCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
 // newOnlineDecoder creates a new decoder for a particular message. The codec
 // parameters as well as the original message length must be provided. The
 // decoder is only valid for decoding blocks for a particular source message.
@@ -238,7 +257,7 @@ func newOnlineDecoder(c *onlineCodec, length int) *onlineDecoder {
 	auxBlockComposition := make([][]int, numAuxBlocks)
 	random := rand.New(NewMersenneTwister(c.randomSeed))
 	for i := 0; i < c.numSourceBlocks; i++ {
-		touchAuxBlocks := sampleUniform(random, c.quality, numAuxBlocks)
+		touchAuxBlocks := c.sampling.sample(random, c.quality, numAuxBlocks)
 		for _, j := range touchAuxBlocks {
 			auxBlockComposition[j] = append(auxBlockComposition[j], i)
 		}
@@ -260,22 +279,49 @@ func newOnlineDecoder(c *onlineCodec, length int) *onlineDecoder {
 // AddBlocks adds a set of encoded blocks to the decoder. Returns true if the
 // message can be fully decoded. False if there is insufficient information.
 func (d *onlineDecoder) AddBlocks(blocks []LTBlock) bool {
+	if d.matrix.lateBlocks(blocks) {
+		return true
+	}
 	for i := range blocks {
 		indices := d.codec.PickIndices(blocks[i].BlockCode)
-		d.matrix.addEquation(indices, block{data: blocks[i].Data})
+		d.matrix.addCodeBlock(blocks[i].BlockCode, indices, block{data: blocks[i].Data})
 	}
-	return d.matrix.determined()
+	return d.matrix.setComplete(d.determined())
+}
+
+// determined reports whether all the source blocks can be recovered. As in the
+// paper, the auxiliary blocks only help recover the source blocks; they need
+// not be recovered themselves, so empty auxiliary rows don't prevent decoding
+// unless a source block's equation depends on them.
+func (d *onlineDecoder) determined() bool {
+	return d.matrix.solvedThrough(d.codec.numSourceBlocks)
 }
 
 // Decode extracts the decoded message from the decoder. If the decoder does
 // not have sufficient information to produce an output, returns a nil slice.
 func (d *onlineDecoder) Decode() []byte {
-	if !d.matrix.determined() {
-		return nil
+	out, _ := d.decode(false)
+	return out
+}
+
+// decode reduces the decode matrix and reconstructs the message. See
+// reconstructBlocks for the meaning of strict.
+func (d *onlineDecoder) decode(strict bool) ([]byte, error) {
+	if !d.determined() {
+		return nil, errNotDetermined
 	}
 
 	d.matrix.reduce()
-
 	lenLong, lenShort, numLong, numShort := partition(d.messageLength, d.codec.numSourceBlocks)
-	return d.matrix.reconstruct(d.messageLength, lenLong, lenShort, numLong, numShort)
+	return reconstructBlocks(d.matrix.v, d.messageLength, lenLong, lenShort, numLong, numShort, strict)
+}
+
+// recoverSource reduces the solvable rows of the decode matrix and returns
+// the source blocks, along with which of them have been recovered. It may be
+// called before the decoder is determined.
+func (d *onlineDecoder) recoverSource() ([]block, []bool) {
+	n := d.codec.numSourceBlocks
+	ok := d.matrix.solvable()
+	d.matrix.reduce()
+	return d.matrix.v[:n], ok[:n]
 }
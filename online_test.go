@@ -59,7 +59,7 @@ func TestOnlineBlocks(t *testing.T) {
 	t.Log("block =", block)
 
 	codec := NewOnlineCodec(6, 0.01, 5, 200)
-	ltblocks := EncodeLTBlocks(message, []int64{252}, codec)
+	ltblocks := EncodeLTBlocks(append([]byte(nil), message...), []int64{252}, codec)
 	indices := codec.PickIndices(252)
 	if !reflect.DeepEqual(indices, []int{4}) {
 		t.Errorf("Indices for 252 are %v, should be [4]", indices)
@@ -173,3 +173,31 @@ func TestDecodeMessageTable(t *testing.T) {
 		}
 	}
 }
+
+// TestDecoderAuxiliaryGap checks that an Online decoder which can solve every
+// source block decodes even if an auxiliary block's row stays empty.
+func TestDecoderAuxiliaryGap(t *testing.T) {
+	c := NewOnlineCodec(4, 0.5, 1, 7).(*onlineCodec)
+	message := []byte("abcdefghijklmnop")
+	source, _ := generateOuterEncoding(message, *c)
+
+	d := newOnlineDecoder(c, len(message))
+	// Wipe the auxiliary constraints and give the decoder the source blocks
+	// directly, so the auxiliary rows are all empty.
+	for i := range d.matrix.coeff {
+		d.matrix.coeff[i] = nil
+		d.matrix.v[i] = block{}
+	}
+	for i := range source {
+		d.matrix.addEquation([]int{i}, source[i])
+	}
+
+	gaps, _ := DecoderGaps(d)
+	if len(gaps.Auxiliary) != c.numAuxBlocks() || !gaps.Determined() {
+		t.Errorf("Gaps = %+v, want all %d auxiliary rows missing and determined",
+			gaps, c.numAuxBlocks())
+	}
+	if decoded := d.Decode(); !reflect.DeepEqual(decoded, message) {
+		t.Errorf("Got %v, want %v", decoded, message)
+	}
+}
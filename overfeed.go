@@ -0,0 +1,130 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+)
+
+// Once a decoder is determined, further code blocks add nothing, yet adding
+// them still costs a reduction against the decode matrix. Receivers sharing a
+// socket with other transfers, or whose sender keeps sending for a while after
+// completion, can choose to stop paying for such late blocks.
+
+// OverfeedPolicy says what a decoder does with code blocks it is given after
+// it is determined.
+type OverfeedPolicy int
+
+const (
+	// OverfeedReduce adds late blocks to the decode matrix like any other.
+	// This is the default.
+	OverfeedReduce OverfeedPolicy = iota
+
+	// OverfeedIgnore drops late blocks without looking at them.
+	OverfeedIgnore
+
+	// OverfeedCount drops late blocks, but records them in the decoder's
+	// reception statistics (see Diagnose and DecoderReception), so loss
+	// estimates keep tracking the channel.
+	OverfeedCount
+
+	// OverfeedVerify counts late blocks like OverfeedCount, and also checks
+	// each against the decoded message by encoding a block with its ID and
+	// comparing. Mismatches, which point to corruption in the channel or the
+	// decoder, are reported by Diagnose.
+	OverfeedVerify
+)
+
+// SetOverfeedPolicy sets the overfeeding policy of a decoder created by one
+// of the package's codecs. Returns false if the decoder is of an unknown type.
+func SetOverfeedPolicy(d Decoder, p OverfeedPolicy) bool {
+	m := decoderMatrix(d)
+	if m == nil {
+		return false
+	}
+	m.overfeed = p
+	m.verify = nil
+	if p == OverfeedVerify {
+		m.verify = solutionVerifier(d)
+	}
+	return true
+}
+
+// decoderCodec returns the codec of one of the package's decoders.
+func decoderCodec(d Decoder) Codec {
+	switch d := d.(type) {
+	case *lubyDecoder:
+		return d.codec
+	case *binaryDecoder:
+		return &d.codec
+	case *onlineDecoder:
+		return d.codec
+	case *raptorDecoder:
+		return &d.codec
+	case *ru10Decoder:
+		return d.codec
+	}
+	return nil
+}
+
+// solutionVerifier returns a function which checks code blocks against the
+// message decoded by d. The message is decoded, and its intermediate blocks
+// generated, on the first call.
+func solutionVerifier(d Decoder) func(LTBlock) bool {
+	c := decoderCodec(d)
+	var source []block
+	return func(b LTBlock) bool {
+		if source == nil {
+			message := d.Decode()
+			if message == nil {
+				return false
+			}
+			source = c.GenerateIntermediateBlocks(message, c.SourceBlocks())
+		}
+		want := encodeCodeBlock(c, source, b.BlockCode)
+		if len(b.Data) != want.length() {
+			return false
+		}
+		return bytes.Equal(b.Data[:len(want.data)], want.data) &&
+			(&block{data: b.Data[len(want.data):]}).zero()
+	}
+}
+
+// lateBlocks applies the overfeeding policy to blocks given to a determined
+// decoder. It returns true if the blocks have been dealt with, and false if
+// the decoder should add them to the matrix.
+func (m *sparseMatrix) lateBlocks(blocks []LTBlock) bool {
+	if !m.complete {
+		return false
+	}
+	switch m.overfeed {
+	case OverfeedIgnore:
+	case OverfeedCount, OverfeedVerify:
+		m.stats.init()
+		for i := range blocks {
+			m.stats.received++
+			m.stats.late++
+			if !m.stats.tracker.Receive(blocks[i].BlockCode) {
+				m.stats.duplicates++
+			}
+			if m.verify != nil && !m.verify(blocks[i]) {
+				m.stats.mismatches++
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
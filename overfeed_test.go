@@ -0,0 +1,114 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOverfeedPolicy(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	var ids []int64
+	for i := int64(0); i < 40; i++ {
+		ids = append(ids, i)
+	}
+
+	for _, policy := range []OverfeedPolicy{OverfeedReduce, OverfeedIgnore, OverfeedCount} {
+		// Decoders work on the blocks' data in place, so each needs its own.
+		blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, codec)
+		d := codec.NewDecoder(len(message))
+		if !SetOverfeedPolicy(d, policy) {
+			t.Fatalf("SetOverfeedPolicy doesn't support %T", d)
+		}
+		n := 0
+		for !d.AddBlocks(blocks[n : n+1]) {
+			n++
+		}
+		n++
+		equations := decoderMatrix(d).cost.Equations
+
+		d.AddBlocks(blocks[n:])
+		diag, _ := Diagnose(d)
+		late := len(blocks) - n
+		m := decoderMatrix(d)
+		switch policy {
+		case OverfeedReduce:
+			if m.cost.Equations != equations+late || diag.Late != 0 {
+				t.Errorf("Reduce: %d equations after %d late blocks, %d counted late",
+					m.cost.Equations-equations, late, diag.Late)
+			}
+		case OverfeedIgnore:
+			if m.cost.Equations != equations || diag.Received != n {
+				t.Errorf("Ignore: %d equations and %d received after %d late blocks",
+					m.cost.Equations-equations, diag.Received-n, late)
+			}
+		case OverfeedCount:
+			if m.cost.Equations != equations || diag.Received != len(blocks) || diag.Late != late {
+				t.Errorf("Count: %d equations, %d received, %d late; want 0, %d, %d",
+					m.cost.Equations-equations, diag.Received, diag.Late, len(blocks), late)
+			}
+		}
+		if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+			t.Errorf("Policy %d: decoded %q, want %q", policy, decoded, message)
+		}
+	}
+}
+
+func TestOverfeedVerify(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codecs := []Codec{
+		NewSeededLubyCodec(13, 5, SolitonDistribution(13)),
+		NewBinaryCodec(13),
+		NewOnlineCodec(13, 0.3, 10, 200),
+		NewRaptorCodec(13, 2),
+		NewRU10Codec(13, 2),
+	}
+	for _, c := range codecs {
+		var ids []int64
+		for i := int64(0); i < 200; i++ {
+			ids = append(ids, i)
+		}
+		blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, c)
+		d := c.NewDecoder(len(message))
+		SetOverfeedPolicy(d, OverfeedVerify)
+		n := 0
+		for !d.AddBlocks(blocks[n : n+1]) {
+			n++
+		}
+		late := EncodeLTBlocks(append([]byte(nil), message...), []int64{300, 301, 302, 303}, c)
+		late[2].Data[0] ^= 0x40
+		d.AddBlocks(late)
+
+		diag, _ := Diagnose(d)
+		if diag.Late != 4 || diag.Mismatched != 1 {
+			t.Errorf("%T: %d late, %d mismatched; want 4, 1", c, diag.Late, diag.Mismatched)
+		}
+	}
+
+	// A late block whose ID isn't a raptor ESI is rejected without being
+	// composed for comparison.
+	c := NewRaptorCodec(13, 2)
+	d := c.NewDecoder(len(message))
+	SetOverfeedPolicy(d, OverfeedVerify)
+	if !d.AddBlocks(EncodeLTBlocksCopy(message, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, c)) {
+		t.Fatal("raptor decoder not determined by the source blocks")
+	}
+	d.AddBlocks([]LTBlock{{BlockCode: 70000, Data: []byte{1, 2}}})
+	if diag, _ := Diagnose(d); diag.Rejected != 1 || diag.Late != 0 {
+		t.Errorf("late block 70000: %d rejected, %d late; want 1, 0", diag.Rejected, diag.Late)
+	}
+}
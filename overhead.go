@@ -0,0 +1,154 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// OverheadProfile describes how many code blocks a decoder for a codec needs:
+// the probability that it can't decode the message from a given number of
+// received code blocks, and the expected number it needs beyond K.
+type OverheadProfile struct {
+	// SourceBlocks is the number of source blocks (K) of the codec.
+	SourceBlocks int
+
+	// ExpectedOverhead is the expected number of code blocks beyond K needed
+	// to decode.
+	ExpectedOverhead float64
+
+	// Analytic is true if the profile comes from a formula for the code, and
+	// false if it was measured.
+	Analytic bool
+
+	// failure returns the probability of failing to decode from a number of
+	// received code blocks.
+	failure func(received int) float64
+}
+
+// FailureProb returns the probability that a decoder can't decode the message
+// from received distinct code blocks.
+func (p OverheadProfile) FailureProb(received int) float64 {
+	if received < p.SourceBlocks {
+		return 1
+	}
+	return p.failure(received)
+}
+
+// EstimateOverhead returns the overhead profile of a codec. The profiles of
+// the Reed-Solomon, raptor, binary and online codecs come from the formulas
+// for those codes. For any other codec, EstimateOverhead decodes trials random
+// messages of 4*K bytes from consecutive code block IDs starting at random
+// places, drawn from random, and reports the distribution of the number of
+// code blocks each needed; trials that don't decode from 2*K+64 code blocks
+// count as needing more than any number of them.
+func EstimateOverhead(c Codec, trials int, random *rand.Rand) OverheadProfile {
+	k := c.SourceBlocks()
+	switch c := c.(type) {
+	case *reedSolomonCodec:
+		return OverheadProfile{SourceBlocks: k, Analytic: true,
+			failure: func(int) float64 { return 0 }}
+
+	case *raptorCodec:
+		// RFC 5053 §1: failure from K+A symbols is about 0.85*0.567^A, so the
+		// expected overhead is the sum of that geometric series.
+		return OverheadProfile{SourceBlocks: k, ExpectedOverhead: 0.85 / (1 - 0.567), Analytic: true,
+			failure: func(received int) float64 { return raptorFailureProb(received - k) }}
+
+	case *binaryCodec:
+		p := OverheadProfile{SourceBlocks: k, Analytic: true,
+			failure: func(received int) float64 { return binaryFailureProb(k, received) }}
+		for a := 0; ; a++ {
+			f := binaryFailureProb(k, k+a)
+			if f < 1e-12 {
+				break
+			}
+			p.ExpectedOverhead += f
+		}
+		return p
+
+	case *onlineCodec:
+		// The outer code fails with probability (epsilon/2)^(quality+1) once
+		// (1+epsilon) times the composite blocks have arrived.
+		needed := c.estimateDecodeBlocksNeeded()
+		fail := math.Pow(c.epsilon/2, float64(c.quality+1))
+		return OverheadProfile{SourceBlocks: k, ExpectedOverhead: float64(needed - k), Analytic: true,
+			failure: func(received int) float64 {
+				if received < needed {
+					return 1
+				}
+				return fail
+			}}
+	}
+	return measureOverhead(c, trials, random)
+}
+
+// binaryFailureProb returns the probability that a k by received random binary
+// matrix doesn't have rank k.
+func binaryFailureProb(k, received int) float64 {
+	// The rank is full unless, for some i, the i'th column falls in the span
+	// of the previous ones.
+	logFull := 0.0
+	for i := 0; i < k; i++ {
+		logFull += math.Log1p(-math.Exp2(float64(i - received)))
+	}
+	return -math.Expm1(logFull)
+}
+
+// measureOverhead builds an overhead profile for c from trials decodes.
+func measureOverhead(c Codec, trials int, random *rand.Rand) OverheadProfile {
+	k := c.SourceBlocks()
+	maxBlocks := 2*k + 64
+	// needed holds the number of code blocks each trial needed, or
+	// maxBlocks+1 if it didn't decode.
+	needed := make([]int, trials)
+	sum := 0
+	for t := range needed {
+		message := make([]byte, 4*k)
+		random.Read(message)
+		start := int64(random.Intn(65536))
+		ids := make([]int64, maxBlocks)
+		for i := range ids {
+			ids[i] = (start + int64(i)) % 65536
+		}
+		blocks := EncodeLTBlocks(message, ids, c)
+		d := c.NewDecoder(len(message))
+		needed[t] = maxBlocks + 1
+		for i := range blocks {
+			if d.AddBlocks(blocks[i : i+1]) {
+				needed[t] = i + 1
+				break
+			}
+		}
+		sum += needed[t]
+	}
+	sort.Ints(needed)
+
+	p := OverheadProfile{SourceBlocks: k,
+		failure: func(received int) float64 {
+			if trials == 0 {
+				return 1
+			}
+			// The number of trials which needed more than received blocks.
+			more := trials - sort.SearchInts(needed, received+1)
+			return float64(more) / float64(trials)
+		}}
+	if trials > 0 {
+		p.ExpectedOverhead = float64(sum)/float64(trials) - float64(k)
+	}
+	return p
+}
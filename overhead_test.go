@@ -0,0 +1,67 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestEstimateOverheadAnalytic(t *testing.T) {
+	var tests = []struct {
+		c        Codec
+		overhead float64
+		received int
+		fail     float64
+	}{
+		{NewReedSolomonCodec(10, 20), 0, 10, 0},
+		{NewRaptorCodec(100, 4), 1.963, 102, 0.85 * 0.567 * 0.567},
+		{NewBinaryCodec(50), 1.607, 51, 0.4224},
+		{NewOnlineCodec(1000, 0.01, 3, 1), 28, 1028, 1.0 / 200 / 200 / 200 / 200},
+	}
+	for _, test := range tests {
+		p := EstimateOverhead(test.c, 0, nil)
+		if !p.Analytic || math.Abs(p.ExpectedOverhead-test.overhead) > 0.001 {
+			t.Errorf("EstimateOverhead(%T) = %+v, want analytic overhead %v", test.c, p, test.overhead)
+		}
+		if got := p.FailureProb(test.received); math.Abs(got-test.fail) > 1e-4*math.Max(test.fail, 1e-9) {
+			t.Errorf("%T: FailureProb(%d) = %v, want %v", test.c, test.received, got, test.fail)
+		}
+		if got := p.FailureProb(test.c.SourceBlocks() - 1); got != 1 {
+			t.Errorf("%T: FailureProb(K-1) = %v, want 1", test.c, got)
+		}
+	}
+}
+
+func TestEstimateOverheadMeasured(t *testing.T) {
+	c := NewSeededLubyCodec(50, 7, SolitonDistribution(50))
+	p := EstimateOverhead(c, 40, rand.New(rand.NewSource(8)))
+	if p.Analytic {
+		t.Errorf("Luby codec profile is analytic, want measured")
+	}
+	if p.ExpectedOverhead <= 0 || p.ExpectedOverhead > 100 {
+		t.Errorf("ExpectedOverhead = %v, want in (0, 100]", p.ExpectedOverhead)
+	}
+	if p.FailureProb(49) != 1 || p.FailureProb(1000) != 0 {
+		t.Errorf("FailureProb(49), FailureProb(1000) = %v, %v; want 1, 0",
+			p.FailureProb(49), p.FailureProb(1000))
+	}
+	for n := 50; n < 200; n++ {
+		if p.FailureProb(n+1) > p.FailureProb(n) {
+			t.Errorf("FailureProb(%d) = %v > FailureProb(%d) = %v", n+1, p.FailureProb(n+1), n, p.FailureProb(n))
+		}
+	}
+}
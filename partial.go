@@ -0,0 +1,72 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+// A decoder which hasn't received enough code blocks to recover the whole
+// message has often recovered much of it anyway: peeling solves the source
+// blocks whose code blocks happen to have arrived, so that progressive media
+// players, for instance, can start on the front of a stream long before the
+// decoder is determined.
+
+// DecodePartial returns the message d decodes, as far as it can be recovered
+// yet, along with which of its source blocks have been. The bytes of the
+// source blocks which haven't been recovered are zero. Like Decode(), blocks
+// holding less data than their share of the message are zero-filled. Returns
+// nil slices if d wasn't created by one of the package's codecs.
+func DecodePartial(d Decoder) (data []byte, recovered []bool) {
+	length, ok := decoderLength(d)
+	if !ok {
+		return nil, nil
+	}
+	source, recovered := d.(sourceRecoverer).recoverSource()
+	p := sourcePartition(length, len(source))
+	data = make([]byte, length)
+	for i := 0; i < p.Pieces(); i++ {
+		if !recovered[i] {
+			continue
+		}
+		start, end := p.Offset(i), p.Offset(i+1)
+		block := source[i].data
+		if len(block) > end-start {
+			block = block[:end-start]
+		}
+		copy(data[start:], block)
+	}
+	return data, recovered[:p.Pieces()]
+}
+
+// RecoveredRanges returns the ranges of bytes of the message d decodes which
+// have been recovered so far, in order, merging those of adjacent source
+// blocks. Returns nil if d wasn't created by one of the package's codecs.
+func RecoveredRanges(d Decoder) []ByteRange {
+	length, ok := decoderLength(d)
+	if !ok {
+		return nil
+	}
+	source, recovered := d.(sourceRecoverer).recoverSource()
+	p := sourcePartition(length, len(source))
+	var ranges []ByteRange
+	for i := 0; i < p.Pieces(); i++ {
+		if !recovered[i] || p.Size(i) == 0 {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].Offset+ranges[n-1].Length == p.Offset(i) {
+			ranges[n-1].Length += p.Size(i)
+			continue
+		}
+		ranges = append(ranges, ByteRange{Offset: p.Offset(i), Length: p.Size(i)})
+	}
+	return ranges
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodePartial(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	codecs := testCodecs()
+	for _, c := range codecs {
+		d := c.NewDecoder(len(message))
+		enc := c.NewEncoder(message)
+		p := sourcePartition(len(message), c.SourceBlocks())
+		partial := false
+		for id := int64(0); ; id++ {
+			if id > 500 {
+				t.Fatalf("%T: no decode after 500 blocks", c)
+			}
+			done := d.AddBlocks([]LTBlock{enc.Generate(id)})
+			data, recovered := DecodePartial(d)
+			if len(data) != len(message) || len(recovered) != p.Pieces() {
+				t.Fatalf("%T: DecodePartial returned %d bytes and %d blocks, want %d and %d",
+					c, len(data), len(recovered), len(message), p.Pieces())
+			}
+			n, size := 0, 0
+			for i, ok := range recovered {
+				want := message[p.Offset(i):p.Offset(i+1)]
+				if !ok {
+					want = make([]byte, len(want))
+				} else {
+					n, size = n+1, size+p.Size(i)
+				}
+				if got := data[p.Offset(i):p.Offset(i+1)]; !bytes.Equal(got, want) {
+					t.Errorf("%T: after %d blocks, source block %d = %q, want %q", c, id+1, i, got, want)
+				}
+			}
+			covered := 0
+			for _, r := range RecoveredRanges(d) {
+				if !bytes.Equal(data[r.Offset:r.Offset+r.Length], message[r.Offset:r.Offset+r.Length]) {
+					t.Errorf("%T: recovered range %+v doesn't match the message", c, r)
+				}
+				covered += r.Length
+			}
+			if covered != size {
+				t.Errorf("%T: recovered ranges cover %d bytes, want %d", c, covered, size)
+			}
+			if done {
+				if n != p.Pieces() {
+					t.Errorf("%T: decoder is determined, but only %d of %d blocks are recovered", c, n, p.Pieces())
+				}
+				break
+			}
+			partial = partial || n > 0
+		}
+		if _, ok := c.(*lubyCodec); ok && !partial {
+			t.Errorf("%T: no source blocks recovered before the whole message", c)
+		}
+	}
+}
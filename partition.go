@@ -0,0 +1,160 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+// Transports carrying an object split into several source blocks (and symbols
+// split into sub-blocks) must make the identical splitting choices at both
+// ends. The RFCs specify exactly how to do this with the Partition[I, J]
+// function; a Partitioner packages that function with the limits of the FEC
+// scheme it belongs to, so both ends can share one value.
+
+// Partition is the result of splitting a size I into J nearly equal pieces
+// with the function Partition[I, J] of RFC 5053 section 5.3.1.2 (which RFC 6330
+// section 4.4.1.2 repeats unchanged). The NumLong longer pieces come first.
+type Partition struct {
+	// LongSize is the size of each of the longer pieces: ceil(I/J). (IL)
+	LongSize int
+
+	// ShortSize is the size of each of the shorter pieces: floor(I/J). (IS)
+	ShortSize int
+
+	// NumLong is the number of longer pieces. (JL)
+	NumLong int
+
+	// NumShort is the number of shorter pieces. (JS)
+	NumShort int
+}
+
+// Pieces returns the total number of pieces, J.
+func (p Partition) Pieces() int {
+	return p.NumLong + p.NumShort
+}
+
+// Size returns the size of the n'th piece.
+func (p Partition) Size(n int) int {
+	if n < p.NumLong {
+		return p.LongSize
+	}
+	return p.ShortSize
+}
+
+// Offset returns the sum of the sizes of the pieces before the n'th one.
+func (p Partition) Offset(n int) int {
+	if n <= p.NumLong {
+		return n * p.LongSize
+	}
+	return p.NumLong*p.LongSize + (n-p.NumLong)*p.ShortSize
+}
+
+// Total returns the size that was partitioned, I.
+func (p Partition) Total() int {
+	return p.Offset(p.Pieces())
+}
+
+// Partitioner splits an object into source blocks, and symbols into
+// sub-blocks, the way a particular FEC scheme specifies.
+type Partitioner interface {
+	// Partition splits i into j nearly equal pieces.
+	Partition(i, j int) Partition
+
+	// MaxSourceSymbols returns the largest number of source symbols the scheme
+	// allows in one source block (KMAX).
+	MaxSourceSymbols() int
+
+	// SourceBlocks splits an object of transferLength bytes with the given
+	// symbol size into z source blocks. The sizes in the result are numbers of
+	// symbols.
+	SourceBlocks(transferLength, symbolSize, z int) Partition
+
+	// SubBlocks splits each symbol of the given size into n sub-symbols whose
+	// sizes are multiples of the symbol alignment. The sizes in the result are
+	// in bytes.
+	SubBlocks(symbolSize, alignment, n int) Partition
+}
+
+// rfcPartitioner implements the partitioning common to RFC 5053 and RFC 6330,
+// which differ only in the largest source block they allow.
+type rfcPartitioner struct {
+	maxSourceSymbols int
+}
+
+// RFC5053Partitioner partitions objects as the Raptor (R10) FEC scheme of
+// RFC 5053 does.
+var RFC5053Partitioner Partitioner = rfcPartitioner{maxSourceSymbols: 8192}
+
+// RFC6330Partitioner partitions objects as the RaptorQ FEC scheme of RFC 6330
+// does.
+var RFC6330Partitioner Partitioner = rfcPartitioner{maxSourceSymbols: 56403}
+
+// Partition implements Partition[I, J] exactly as specified, using integer
+// arithmetic. Unlike the package-internal partition(), LongSize is ceil(i/j)
+// even when there are no long pieces.
+func (p rfcPartitioner) Partition(i, j int) Partition {
+	if j <= 0 {
+		return Partition{}
+	}
+	return Partition{
+		LongSize:  (i + j - 1) / j,
+		ShortSize: i / j,
+		NumLong:   i - (i/j)*j,
+		NumShort:  j - (i - (i/j)*j),
+	}
+}
+
+// MaxSourceSymbols returns KMAX for the scheme.
+func (p rfcPartitioner) MaxSourceSymbols() int {
+	return p.maxSourceSymbols
+}
+
+// SourceBlocks computes (KL, KS, ZL, ZS) = Partition[Kt, Z], where
+// Kt = ceil(F/T) is the number of symbols in the object.
+func (p rfcPartitioner) SourceBlocks(transferLength, symbolSize, z int) Partition {
+	if symbolSize <= 0 {
+		return Partition{}
+	}
+	kt := (transferLength + symbolSize - 1) / symbolSize
+	return p.Partition(kt, z)
+}
+
+// SubBlocks computes (TL, TS, NL, NS) = Partition[T/Al, N], and scales the
+// sizes from units of Al back to bytes.
+func (p rfcPartitioner) SubBlocks(symbolSize, alignment, n int) Partition {
+	if alignment <= 0 {
+		return Partition{}
+	}
+	sub := p.Partition(symbolSize/alignment, n)
+	sub.LongSize *= alignment
+	sub.ShortSize *= alignment
+	return sub
+}
+
+// SymbolSize returns the length of the source blocks (and so of the code
+// blocks) the package's codecs use for a message of messageLength bytes split
+// into sourceBlocks blocks. All blocks are padded to the length of the longest.
+func SymbolSize(messageLength, sourceBlocks int) int {
+	if sourceBlocks <= 0 {
+		return 0
+	}
+	return (messageLength + sourceBlocks - 1) / sourceBlocks
+}
+
+// PaddingBytes returns the number of zero bytes the package's codecs add to a
+// message of messageLength bytes to split it into sourceBlocks blocks of equal
+// length. Transports can use it to account for the effective overhead of a
+// transfer: every code block carries SymbolSize bytes, but only
+// messageLength/sourceBlocks of them on average are message data.
+func PaddingBytes(messageLength, sourceBlocks int) int {
+	return SymbolSize(messageLength, sourceBlocks)*sourceBlocks - messageLength
+}
@@ -0,0 +1,138 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"testing"
+)
+
+func TestPartitioner(t *testing.T) {
+	var partitionTests = []struct {
+		i, j int
+		p    Partition
+	}{
+		{26, 4, Partition{7, 6, 2, 2}},
+		{10, 5, Partition{2, 2, 0, 5}},
+		{3, 5, Partition{1, 0, 3, 2}},
+		{0, 3, Partition{0, 0, 0, 3}},
+		{10000, 7, Partition{1429, 1428, 4, 3}},
+	}
+
+	for _, test := range partitionTests {
+		for _, p := range []Partitioner{RFC5053Partitioner, RFC6330Partitioner} {
+			got := p.Partition(test.i, test.j)
+			if got != test.p {
+				t.Errorf("Partition[%d, %d] = %+v, want %+v", test.i, test.j, got, test.p)
+			}
+			if got.Total() != test.i || got.Pieces() != test.j {
+				t.Errorf("Partition[%d, %d] covers %d in %d pieces", test.i, test.j,
+					got.Total(), got.Pieces())
+			}
+		}
+	}
+}
+
+// TestPartitionerCrossCheck checks the exported integer implementation against
+// the RFC's formula as the package implements it internally.
+func TestPartitionerCrossCheck(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		for j := 1; j < 60; j++ {
+			il, is, jl, js := partition(i, j)
+			p := RFC5053Partitioner.Partition(i, j)
+			if jl == 0 {
+				// partition() reports no size for the absent long pieces.
+				il = p.LongSize
+			}
+			if p != (Partition{il, is, jl, js}) {
+				t.Fatalf("Partition[%d, %d] = %+v, partition() gives (%d, %d, %d, %d)",
+					i, j, p, il, is, jl, js)
+			}
+		}
+	}
+}
+
+func TestPartitionOffsets(t *testing.T) {
+	p := Partition{7, 6, 2, 2}
+	offsets := []int{0, 7, 14, 20, 26}
+	for n, want := range offsets {
+		if p.Offset(n) != want {
+			t.Errorf("Offset(%d) = %d, want %d", n, p.Offset(n), want)
+		}
+	}
+	if p.Size(1) != 7 || p.Size(2) != 6 {
+		t.Errorf("Sizes of pieces 1 and 2 are %d and %d, want 7 and 6", p.Size(1), p.Size(2))
+	}
+}
+
+func TestPartitionerBlocks(t *testing.T) {
+	// A 1MB object in 1024-byte symbols: Kt = 1024 symbols in 3 source blocks.
+	blocks := RFC5053Partitioner.SourceBlocks(1<<20, 1024, 3)
+	if blocks != (Partition{342, 341, 1, 2}) {
+		t.Errorf("SourceBlocks = %+v, want {342 341 1 2}", blocks)
+	}
+	// Partial symbols count as a whole symbol.
+	blocks = RFC5053Partitioner.SourceBlocks(1025, 1024, 1)
+	if blocks.Total() != 2 {
+		t.Errorf("SourceBlocks(1025, 1024, 1) has %d symbols, want 2", blocks.Total())
+	}
+
+	sub := RFC5053Partitioner.SubBlocks(1024, 4, 3)
+	if sub != (Partition{344, 340, 1, 2}) || sub.Total() != 1024 {
+		t.Errorf("SubBlocks = %+v, want {344 340 1 2}", sub)
+	}
+
+	if RFC5053Partitioner.MaxSourceSymbols() != 8192 || RFC6330Partitioner.MaxSourceSymbols() != 56403 {
+		t.Errorf("Unexpected KMAX values %d, %d", RFC5053Partitioner.MaxSourceSymbols(),
+			RFC6330Partitioner.MaxSourceSymbols())
+	}
+}
+
+func TestPaddingBytes(t *testing.T) {
+	var paddingTests = []struct {
+		length, k     int
+		size, padding int
+	}{
+		{26, 4, 7, 2},
+		{28, 4, 7, 0},
+		{3, 5, 1, 2},
+		{0, 4, 0, 0},
+		{1000, 13, 77, 1},
+	}
+
+	for _, test := range paddingTests {
+		if got := SymbolSize(test.length, test.k); got != test.size {
+			t.Errorf("SymbolSize(%d, %d) = %d, want %d", test.length, test.k, got, test.size)
+		}
+		if got := PaddingBytes(test.length, test.k); got != test.padding {
+			t.Errorf("PaddingBytes(%d, %d) = %d, want %d", test.length, test.k, got, test.padding)
+		}
+
+		// Cross-check against the blocks the codecs actually produce.
+		message := make([]byte, test.length)
+		long, short := partitionBytes(message, test.k)
+		padding := 0
+		for _, b := range equalizeBlockLengths(long, short) {
+			padding += b.padding
+			if test.length > 0 && b.length() != test.size {
+				t.Errorf("Block of length %d for (%d, %d), want %d", b.length(),
+					test.length, test.k, test.size)
+			}
+		}
+		if test.length > 0 && padding != test.padding {
+			t.Errorf("Blocks for (%d, %d) have %d padding bytes, want %d",
+				test.length, test.k, padding, test.padding)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+)
+
+// RFC 5053 §3.2 packets start with a 4-byte FEC Payload ID: a 16-bit source
+// block number (SBN) followed by the 16-bit encoding symbol ID (ESI) of the
+// first symbol in the packet, both in network byte order. The rest of the
+// packet is one or more symbols with consecutive ESIs.
+
+// PayloadIDLen is the length in bytes of an encoded PayloadID.
+const PayloadIDLen = 4
+
+// PayloadID is the RFC 5053 FEC Payload ID.
+type PayloadID struct {
+	// SBN is the source block number.
+	SBN uint16
+
+	// ESI is the encoding symbol ID.
+	ESI uint16
+}
+
+// NewPayloadID returns the Payload ID of a code block of the given source
+// block. Returns an error if the block's ID doesn't fit in an ESI.
+func NewPayloadID(sbn uint16, b LTBlock) (PayloadID, error) {
+	if b.BlockCode < 0 || b.BlockCode > MaxRaptorESI {
+		return PayloadID{}, fmt.Errorf("%w: ESI %d is outside [0, %d]", ErrNonCompliant, b.BlockCode, MaxRaptorESI)
+	}
+	return PayloadID{SBN: sbn, ESI: uint16(b.BlockCode)}, nil
+}
+
+// AppendTo appends the encoded Payload ID to b and returns the extended slice.
+func (p PayloadID) AppendTo(b []byte) []byte {
+	b = ByteOrder.AppendUint16(b, p.SBN)
+	return ByteOrder.AppendUint16(b, p.ESI)
+}
+
+// ParsePayloadID decodes the Payload ID at the start of b, and returns it
+// along with the remainder of b.
+func ParsePayloadID(b []byte) (PayloadID, []byte, error) {
+	if len(b) < PayloadIDLen {
+		return PayloadID{}, nil, ErrShortWireData
+	}
+	p := PayloadID{SBN: ByteOrder.Uint16(b), ESI: ByteOrder.Uint16(b[2:])}
+	return p, b[PayloadIDLen:], nil
+}
+
+// AppendPacket appends an RFC 5053 packet holding the given code blocks of
+// source block sbn to b, and returns the extended slice. The blocks must make
+// a compliant packet (see CheckRaptorPacket).
+func AppendPacket(b []byte, sbn uint16, blocks []LTBlock) ([]byte, error) {
+	if len(blocks) == 0 {
+		return b, fmt.Errorf("%w: empty packet", ErrNonCompliant)
+	}
+	if err := CheckRaptorPacket(blocks, len(blocks[0].Data)); err != nil {
+		return b, err
+	}
+	id, _ := NewPayloadID(sbn, blocks[0])
+	b = id.AppendTo(b)
+	for _, block := range blocks {
+		b = append(b, block.Data...)
+	}
+	return b, nil
+}
+
+// ParsePacket splits an RFC 5053 packet into its source block number and
+// code blocks of symbolSize bytes. The blocks' data refers to the packet.
+func ParsePacket(packet []byte, symbolSize int) (uint16, []LTBlock, error) {
+	id, rest, err := ParsePayloadID(packet)
+	if err != nil {
+		return 0, nil, err
+	}
+	if symbolSize <= 0 || len(rest) == 0 || len(rest)%symbolSize != 0 {
+		return 0, nil, fmt.Errorf("%w: %d bytes of symbols of size %d", ErrNonCompliant, len(rest), symbolSize)
+	}
+	n := len(rest) / symbolSize
+	if int(id.ESI)+n-1 > MaxRaptorESI {
+		return 0, nil, fmt.Errorf("%w: %d symbols starting at ESI %d", ErrNonCompliant, n, id.ESI)
+	}
+	blocks := make([]LTBlock, n)
+	for i := range blocks {
+		blocks[i] = LTBlock{
+			BlockCode: int64(id.ESI) + int64(i),
+			Data:      rest[i*symbolSize : (i+1)*symbolSize : (i+1)*symbolSize],
+		}
+	}
+	return id.SBN, blocks, nil
+}
+
+// GroupPacketizer packs G symbols with consecutive ESIs into each RFC 5053
+// packet, so that a small symbol size (and so a large K) doesn't mean tiny
+// packets. Packet n carries the symbols with ESIs n*G to n*G+G-1, unless
+// Order says otherwise.
+type GroupPacketizer struct {
+	// SBN is the source block number of the packets.
+	SBN uint16
+
+	// G is the number of symbols per packet.
+	G int
+
+	// Order, if set, is the order the first packets are sent in: packet n
+	// carries the symbols of group Order[n], the ESIs Order[n]*G to
+	// Order[n]*G+G-1, for n below len(Order), and of group n after that. To
+	// send the source symbols of a block of K in a shuffled order, set it to
+	// ShuffledSystematicOrder((K+G-1)/G, seed).
+	Order []int64
+}
+
+// Packet returns packet n, holding the code blocks e generates for its ESIs,
+// which must all have the same size.
+func (p GroupPacketizer) Packet(e Encoder, n int64) ([]byte, error) {
+	if p.G < 1 {
+		return nil, fmt.Errorf("%w: packet of %d symbols", ErrNonCompliant, p.G)
+	}
+	if n < 0 || n > (MaxRaptorESI+1)/int64(p.G)-1 {
+		return nil, fmt.Errorf("%w: packet %d of %d symbols has ESIs beyond %d", ErrNonCompliant, n, p.G, MaxRaptorESI)
+	}
+	group := n
+	if n < int64(len(p.Order)) {
+		group = p.Order[n]
+		if group < 0 || group > (MaxRaptorESI+1)/int64(p.G)-1 {
+			return nil, fmt.Errorf("%w: packet %d is group %d of %d symbols, beyond ESI %d", ErrNonCompliant, n, group, p.G, MaxRaptorESI)
+		}
+	}
+	blocks := make([]LTBlock, p.G)
+	for i := range blocks {
+		blocks[i] = e.Generate(group*int64(p.G) + int64(i))
+	}
+	return AppendPacket(nil, p.SBN, blocks)
+}
+
+// Depacketize splits a packet into its G code blocks. Returns an error if the
+// packet is for another source block, or doesn't hold G symbols of the same
+// size. The blocks' data refers to the packet.
+func (p GroupPacketizer) Depacketize(packet []byte) ([]LTBlock, error) {
+	if p.G < 1 || len(packet) <= PayloadIDLen || (len(packet)-PayloadIDLen)%p.G != 0 {
+		return nil, fmt.Errorf("%w: %d-byte packet doesn't hold %d equal symbols", ErrNonCompliant, len(packet), p.G)
+	}
+	sbn, blocks, err := ParsePacket(packet, (len(packet)-PayloadIDLen)/p.G)
+	if err != nil {
+		return nil, err
+	}
+	if sbn != p.SBN {
+		return nil, fmt.Errorf("fountain: packet for source block %d, want %d", sbn, p.SBN)
+	}
+	return blocks, nil
+}
+
+// AddPacket splits a packet into its code blocks and adds them to d, and
+// reports whether the message can be decoded. The decoder keeps references to
+// the packet.
+func (p GroupPacketizer) AddPacket(d Decoder, packet []byte) (bool, error) {
+	blocks, err := p.Depacketize(packet)
+	if err != nil {
+		return false, err
+	}
+	return d.AddBlocks(blocks), nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadID(t *testing.T) {
+	id, err := NewPayloadID(0x0102, LTBlock{BlockCode: 0x0304})
+	if err != nil {
+		t.Fatalf("NewPayloadID failed: %v", err)
+	}
+	b := id.AppendTo([]byte{0xff})
+	if want := []byte{0xff, 0x01, 0x02, 0x03, 0x04}; !bytes.Equal(b, want) {
+		t.Errorf("AppendTo = %x, want %x", b, want)
+	}
+	parsed, rest, err := ParsePayloadID(b[1:])
+	if err != nil || parsed != id || len(rest) != 0 {
+		t.Errorf("ParsePayloadID = %v, %x, %v, want %v", parsed, rest, err, id)
+	}
+
+	if _, err := NewPayloadID(0, LTBlock{BlockCode: MaxRaptorESI + 1}); err == nil {
+		t.Errorf("NewPayloadID accepted ESI %d", MaxRaptorESI+1)
+	}
+	if _, _, err := ParsePayloadID([]byte{1, 2, 3}); err != ErrShortWireData {
+		t.Errorf("ParsePayloadID of 3 bytes returned %v, want %v", err, ErrShortWireData)
+	}
+}
+
+func TestPacket(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewRaptorCodec(10, 4)
+	e, err := NewRaptorStrictEncoder(c, message, 8)
+	if err != nil {
+		t.Fatalf("NewRaptorStrictEncoder failed: %v", err)
+	}
+	blocks, err := e.Packet(40, 3)
+	if err != nil {
+		t.Fatalf("Packet failed: %v", err)
+	}
+
+	packet, err := AppendPacket(nil, 7, blocks)
+	if err != nil {
+		t.Fatalf("AppendPacket failed: %v", err)
+	}
+	if len(packet) != PayloadIDLen+3*8 {
+		t.Errorf("packet has %d bytes, want %d", len(packet), PayloadIDLen+3*8)
+	}
+	sbn, parsed, err := ParsePacket(packet, 8)
+	if err != nil {
+		t.Fatalf("ParsePacket failed: %v", err)
+	}
+	if sbn != 7 || !reflect.DeepEqual(parsed, blocks) {
+		t.Errorf("ParsePacket = %d, %v, want 7, %v", sbn, parsed, blocks)
+	}
+
+	if _, _, err := ParsePacket(packet[:len(packet)-1], 8); err == nil {
+		t.Errorf("ParsePacket accepted a truncated symbol")
+	}
+	if _, err := AppendPacket(nil, 7, []LTBlock{blocks[0], blocks[2]}); err == nil {
+		t.Errorf("AppendPacket accepted non-consecutive ESIs")
+	}
+}
+
+func TestGroupPacketizer(t *testing.T) {
+	message := make([]byte, 4000)
+	for i := range message {
+		message[i] = byte(i * 11)
+	}
+	c := NewRaptorCodec(100, 4)
+	e := c.NewEncoder(message)
+	p := GroupPacketizer{SBN: 3, G: 4}
+
+	packet, err := p.Packet(e, 5)
+	if err != nil {
+		t.Fatalf("Packet failed: %v", err)
+	}
+	if len(packet) != PayloadIDLen+4*40 {
+		t.Fatalf("packet has %d bytes, want %d", len(packet), PayloadIDLen+4*40)
+	}
+	blocks, err := p.Depacketize(packet)
+	if err != nil {
+		t.Fatalf("Depacketize failed: %v", err)
+	}
+	for i, b := range blocks {
+		if want := e.Generate(20 + int64(i)); !reflect.DeepEqual(b, want) {
+			t.Errorf("symbol %d = %v, want %v", i, b, want)
+		}
+	}
+
+	// Every other packet is lost; the rest decode the message, 4 symbols at a
+	// time.
+	d := c.NewDecoder(len(message))
+	done := false
+	for n := int64(0); !done; n += 2 {
+		if n > 200 {
+			t.Fatal("not decoded after 100 packets")
+		}
+		packet, err := p.Packet(e, n)
+		if err != nil {
+			t.Fatalf("Packet %d failed: %v", n, err)
+		}
+		if done, err = p.AddPacket(d, packet); err != nil {
+			t.Fatalf("AddPacket %d failed: %v", n, err)
+		}
+	}
+	if !bytes.Equal(d.Decode(), message) {
+		t.Error("decoded message differs")
+	}
+
+	if _, err := (GroupPacketizer{SBN: 4, G: 4}).Depacketize(packet); err == nil {
+		t.Error("Depacketize accepted a packet for another source block")
+	}
+	if _, err := (GroupPacketizer{SBN: 3, G: 3}).Depacketize(packet); err == nil {
+		t.Error("Depacketize accepted 160 bytes as 3 symbols")
+	}
+	if _, err := p.Packet(e, (MaxRaptorESI+1)/4); err == nil {
+		t.Error("Packet accepted ESIs beyond the maximum")
+	}
+	if _, err := p.Packet(e, (MaxRaptorESI+1)/4-1); err != nil {
+		t.Errorf("last packet failed: %v", err)
+	}
+}
+
+func TestGroupPacketizerOrder(t *testing.T) {
+	message := make([]byte, 4000)
+	for i := range message {
+		message[i] = byte(i * 13)
+	}
+	c := NewRaptorCodec(100, 4)
+	e := c.NewEncoder(message)
+	order := ShuffledSystematicOrder(50, 7)
+	p := GroupPacketizer{SBN: 1, G: 2, Order: order}
+
+	// A burst of 20 lost packets takes out 40 source symbols scattered over
+	// the block; the repair packets after the shuffled ones make up for them.
+	d := c.NewDecoder(len(message))
+	done := false
+	for n := int64(0); !done; n++ {
+		if n > 200 {
+			t.Fatal("not decoded after 200 packets")
+		}
+		packet, err := p.Packet(e, n)
+		if err != nil {
+			t.Fatalf("Packet %d failed: %v", n, err)
+		}
+		blocks, err := p.Depacketize(packet)
+		if err != nil {
+			t.Fatalf("Depacketize %d failed: %v", n, err)
+		}
+		want := n
+		if n < int64(len(order)) {
+			want = order[n]
+		}
+		if blocks[0].BlockCode != 2*want {
+			t.Fatalf("packet %d starts at ESI %d, want %d", n, blocks[0].BlockCode, 2*want)
+		}
+		if n >= 10 && n < 30 {
+			continue
+		}
+		if done, err = p.AddPacket(d, packet); err != nil {
+			t.Fatalf("AddPacket %d failed: %v", n, err)
+		}
+	}
+	if !bytes.Equal(d.Decode(), message) {
+		t.Error("decoded message differs")
+	}
+
+	bad := GroupPacketizer{SBN: 1, G: 2, Order: []int64{(MaxRaptorESI + 1) / 2}}
+	if _, err := bad.Packet(e, 0); err == nil {
+		t.Error("Packet accepted an ordered group beyond the maximum ESI")
+	}
+}
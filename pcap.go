@@ -0,0 +1,232 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Field captures of a transfer record the exact loss and reordering a real
+// network produced. The reader below extracts UDP payloads from a capture in
+// the classic libpcap file format, so a capture can be replayed into a decoder
+// offline, both for postmortem analysis and to test receivers against real
+// loss patterns. Only the link types commonly used for UDP captures are
+// supported: Ethernet (with optional VLAN tags), raw IP and Linux cooked
+// captures. Fragmented IP datagrams are skipped.
+
+// Link types from the pcap file header.
+const (
+	pcapLinkEthernet = 1
+	pcapLinkRaw      = 101
+	pcapLinkLinuxSLL = 113
+	pcapLinkIPv4     = 228
+	pcapLinkIPv6     = 229
+)
+
+// ErrPcapFormat is returned for malformed capture files.
+var ErrPcapFormat = errors.New("fountain: malformed pcap file")
+
+// UDPPacket is a UDP datagram read from a capture.
+type UDPPacket struct {
+	Timestamp        time.Time
+	SrcPort, DstPort uint16
+	Payload          []byte
+}
+
+// PcapReader reads UDP datagrams from a libpcap capture file.
+type PcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint32
+}
+
+// NewPcapReader reads the file header of a capture.
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, ErrPcapFormat
+	}
+	p := &PcapReader{r: r}
+	switch {
+	case binary.LittleEndian.Uint32(hdr[0:]) == 0xa1b2c3d4:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[0:]) == 0xa1b2c3d4:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[0:]) == 0xa1b23c4d:
+		p.order, p.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[0:]) == 0xa1b23c4d:
+		p.order, p.nanos = binary.BigEndian, true
+	default:
+		return nil, ErrPcapFormat
+	}
+	p.linkType = p.order.Uint32(hdr[20:]) & 0xffff
+	switch p.linkType {
+	case pcapLinkEthernet, pcapLinkRaw, pcapLinkLinuxSLL, pcapLinkIPv4, pcapLinkIPv6:
+	default:
+		return nil, fmt.Errorf("fountain: unsupported pcap link type %d", p.linkType)
+	}
+	return p, nil
+}
+
+// Next returns the next UDP datagram in the capture, skipping other packets.
+// Returns io.EOF at the end of the capture.
+func (p *PcapReader) Next() (UDPPacket, error) {
+	for {
+		var hdr [16]byte
+		if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return UDPPacket{}, io.EOF
+			}
+			return UDPPacket{}, ErrPcapFormat
+		}
+		frac := time.Duration(p.order.Uint32(hdr[4:]))
+		if !p.nanos {
+			frac *= time.Microsecond
+		}
+		ts := time.Unix(int64(p.order.Uint32(hdr[0:])), int64(frac))
+		n := p.order.Uint32(hdr[8:])
+		if n > 1<<20 {
+			return UDPPacket{}, ErrPcapFormat
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(p.r, frame); err != nil {
+			return UDPPacket{}, ErrPcapFormat
+		}
+		if packet, ok := p.parseFrame(frame); ok {
+			packet.Timestamp = ts
+			return packet, nil
+		}
+	}
+}
+
+// parseFrame extracts the UDP datagram from a captured frame, if it holds one.
+func (p *PcapReader) parseFrame(frame []byte) (UDPPacket, bool) {
+	switch p.linkType {
+	case pcapLinkEthernet:
+		if len(frame) < 14 {
+			return UDPPacket{}, false
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:])
+		frame = frame[14:]
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(frame) >= 4 {
+			etherType = binary.BigEndian.Uint16(frame[2:])
+			frame = frame[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return UDPPacket{}, false
+		}
+	case pcapLinkLinuxSLL:
+		if len(frame) < 16 {
+			return UDPPacket{}, false
+		}
+		frame = frame[16:]
+	}
+	return parseIP(frame)
+}
+
+// parseIP extracts the UDP datagram from an IPv4 or IPv6 packet.
+func parseIP(packet []byte) (UDPPacket, bool) {
+	if len(packet) < 1 {
+		return UDPPacket{}, false
+	}
+	var udp []byte
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return UDPPacket{}, false
+		}
+		ihl := int(packet[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(packet[2:]))
+		fragment := binary.BigEndian.Uint16(packet[6:])
+		if packet[9] != 17 || fragment&0x3fff != 0 || ihl < 20 || total < ihl || total > len(packet) {
+			return UDPPacket{}, false
+		}
+		udp = packet[ihl:total]
+	case 6:
+		// Extension headers aren't followed; UDP must be the next header.
+		if len(packet) < 40 || packet[6] != 17 {
+			return UDPPacket{}, false
+		}
+		end := 40 + int(binary.BigEndian.Uint16(packet[4:]))
+		if end > len(packet) {
+			return UDPPacket{}, false
+		}
+		udp = packet[40:end]
+	default:
+		return UDPPacket{}, false
+	}
+	if len(udp) < 8 {
+		return UDPPacket{}, false
+	}
+	length := int(binary.BigEndian.Uint16(udp[4:]))
+	if length < 8 || length > len(udp) {
+		return UDPPacket{}, false
+	}
+	return UDPPacket{
+		SrcPort: binary.BigEndian.Uint16(udp[0:]),
+		DstPort: binary.BigEndian.Uint16(udp[2:]),
+		Payload: udp[8:length],
+	}, true
+}
+
+// PcapReplay summarizes the replay of a capture into a decoder.
+type PcapReplay struct {
+	// Packets is the number of UDP datagrams to the port, and ParseErrors the
+	// number of those which didn't parse as code blocks.
+	Packets, ParseErrors int
+
+	// Determined is true if the decoder became determined, and DeterminedAt is
+	// then the index among Packets of the datagram which completed it.
+	Determined   bool
+	DeterminedAt int
+}
+
+// ReplayPcap feeds the UDP datagrams sent to the given port in a capture to a
+// decoder, parsing each payload into a code block with parse. It continues to
+// the end of the capture even once the decoder is determined.
+func ReplayPcap(r io.Reader, port uint16, parse func(payload []byte) (LTBlock, error), d Decoder) (PcapReplay, error) {
+	var replay PcapReplay
+	p, err := NewPcapReader(r)
+	if err != nil {
+		return replay, err
+	}
+	for {
+		packet, err := p.Next()
+		if err == io.EOF {
+			return replay, nil
+		}
+		if err != nil {
+			return replay, err
+		}
+		if packet.DstPort != port {
+			continue
+		}
+		replay.Packets++
+		b, err := parse(packet.Payload)
+		if err != nil {
+			replay.ParseErrors++
+			continue
+		}
+		if d.AddBlocks([]LTBlock{b}) && !replay.Determined {
+			replay.Determined = true
+			replay.DeterminedAt = replay.Packets - 1
+		}
+	}
+}
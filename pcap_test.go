@@ -0,0 +1,111 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// pcapFile builds a little-endian Ethernet capture of the given frames.
+func pcapFile(frames [][]byte) []byte {
+	le := binary.LittleEndian
+	f := le.AppendUint32(nil, 0xa1b2c3d4)
+	f = le.AppendUint16(f, 2)
+	f = le.AppendUint16(f, 4)
+	f = append(f, make([]byte, 8)...)
+	f = le.AppendUint32(f, 65535)
+	f = le.AppendUint32(f, pcapLinkEthernet)
+	for i, frame := range frames {
+		f = le.AppendUint32(f, uint32(1000+i))
+		f = le.AppendUint32(f, 0)
+		f = le.AppendUint32(f, uint32(len(frame)))
+		f = le.AppendUint32(f, uint32(len(frame)))
+		f = append(f, frame...)
+	}
+	return f
+}
+
+// udpFrame builds an Ethernet/IPv4/UDP frame carrying payload to port.
+func udpFrame(port uint16, payload []byte) []byte {
+	be := binary.BigEndian
+	frame := make([]byte, 12)
+	frame = be.AppendUint16(frame, 0x0800)
+	ip := []byte{0x45, 0}
+	ip = be.AppendUint16(ip, uint16(20+8+len(payload)))
+	ip = append(ip, 0, 0, 0x40, 0, 64, 17, 0, 0, 10, 0, 0, 1, 10, 0, 0, 2)
+	udp := be.AppendUint16(nil, 5000)
+	udp = be.AppendUint16(udp, port)
+	udp = be.AppendUint16(udp, uint16(8+len(payload)))
+	udp = append(udp, 0, 0)
+	return append(append(append(frame, ip...), udp...), payload...)
+}
+
+// testPacket is the payload format of the test: a 2-byte ID and the data.
+func testPacket(b LTBlock) []byte {
+	return append(ByteOrder.AppendUint16(nil, uint16(b.BlockCode)), b.Data...)
+}
+
+func parseTestPacket(p []byte) (LTBlock, error) {
+	if len(p) < 2 {
+		return LTBlock{}, errors.New("short packet")
+	}
+	return LTBlock{BlockCode: int64(ByteOrder.Uint16(p)), Data: p[2:]}, nil
+}
+
+func TestReplayPcap(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	var ids []int64
+	for i := int64(0); i < 30; i++ {
+		if i%5 != 2 {
+			ids = append(ids, i)
+		}
+	}
+	var frames [][]byte
+	for i, b := range EncodeLTBlocks(append([]byte(nil), message...), ids, codec) {
+		frames = append(frames, udpFrame(9000, testPacket(b)))
+		if i == 3 {
+			frames = append(frames, udpFrame(53, []byte("other traffic")), udpFrame(9000, []byte{1}))
+		}
+	}
+
+	d := codec.NewDecoder(len(message))
+	replay, err := ReplayPcap(bytes.NewReader(pcapFile(frames)), 9000, parseTestPacket, d)
+	if err != nil {
+		t.Fatalf("ReplayPcap failed: %v", err)
+	}
+	if replay.Packets != 25 || replay.ParseErrors != 1 || !replay.Determined {
+		t.Errorf("Replay = %+v, want 25 packets, 1 parse error, determined", replay)
+	}
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("Decoded %q, want %q", decoded, message)
+	}
+
+	r, err := NewPcapReader(bytes.NewReader(pcapFile(frames[:1])))
+	if err != nil {
+		t.Fatalf("NewPcapReader failed: %v", err)
+	}
+	packet, err := r.Next()
+	if err != nil || packet.SrcPort != 5000 || packet.DstPort != 9000 || packet.Timestamp.Unix() != 1000 {
+		t.Errorf("First packet is %+v, %v", packet, err)
+	}
+
+	if _, err := NewPcapReader(bytes.NewReader([]byte("not a capture file at all"))); err != ErrPcapFormat {
+		t.Errorf("NewPcapReader of garbage gave %v, want ErrPcapFormat", err)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A deployment typically stacks several of the package's layers: an
+// integrity trailer on the object, the codec, per-symbol encryption, packet
+// framing and send pacing. Each receive step must undo the matching send step
+// in reverse order, and the receiver must account for what the sender added
+// (the trailer's length, for one). A Pipeline assembles both directions from
+// one declarative description, so they always agree.
+//
+// Send:    object → trailer → encode → seal → packetize → pace
+// Receive: packet → depacketize → open → decode → verify trailer
+
+// Packetizer frames code blocks as packets and parses them back.
+type Packetizer interface {
+	// Packetize returns the packet carrying the code block.
+	Packetize(b LTBlock) ([]byte, error)
+
+	// Depacketize returns the code block a packet carries.
+	Depacketize(packet []byte) (LTBlock, error)
+}
+
+// idPacketizer frames a code block as its ID, 8 bytes in network byte order,
+// followed by its data. It is the pipelines' default.
+type idPacketizer struct{}
+
+func (idPacketizer) Packetize(b LTBlock) ([]byte, error) {
+	return append(ByteOrder.AppendUint64(nil, uint64(b.BlockCode)), b.Data...), nil
+}
+
+func (idPacketizer) Depacketize(packet []byte) (LTBlock, error) {
+	if len(packet) < 8 {
+		return LTBlock{}, ErrShortWireData
+	}
+	return LTBlock{BlockCode: int64(ByteOrder.Uint64(packet)), Data: packet[8:]}, nil
+}
+
+// RFC5053Packetizer frames each code block as an RFC 5053 packet of one
+// symbol, with an FEC Payload ID for the given source block number.
+type RFC5053Packetizer struct {
+	SBN uint16
+}
+
+// Packetize returns the packet carrying the code block.
+func (p RFC5053Packetizer) Packetize(b LTBlock) ([]byte, error) {
+	return AppendPacket(nil, p.SBN, []LTBlock{b})
+}
+
+// Depacketize returns the code block a packet carries. Returns an error if
+// the packet is for another source block.
+func (p RFC5053Packetizer) Depacketize(packet []byte) (LTBlock, error) {
+	sbn, blocks, err := ParsePacket(packet, len(packet)-PayloadIDLen)
+	if err != nil {
+		return LTBlock{}, err
+	}
+	if sbn != p.SBN {
+		return LTBlock{}, fmt.Errorf("fountain: packet for source block %d, want %d", sbn, p.SBN)
+	}
+	return blocks[0], nil
+}
+
+// pipelineConfig is the description a Pipeline is built from.
+type pipelineConfig struct {
+	codec      Codec
+	checksum   bool
+	secret     []byte
+	packetizer Packetizer
+	rate       float64
+
+	// now and sleep are the clock used for pacing.
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// PipelineOption configures a layer of a Pipeline.
+type PipelineOption func(*pipelineConfig)
+
+// WithCodec sets the codec. It is required.
+func WithCodec(c Codec) PipelineOption {
+	return func(p *pipelineConfig) { p.codec = c }
+}
+
+// WithChecksum appends an integrity trailer (see AppendIntegrityTrailer) to
+// the object before encoding, and verifies it after decoding.
+func WithChecksum() PipelineOption {
+	return func(p *pipelineConfig) { p.checksum = true }
+}
+
+// WithEncryption seals every code block after encoding, and opens it before
+// decoding, with AES-256-GCM. Blocks which fail authentication are rejected.
+// Each object gets a key of its own, derived from the long-term secret and
+// its object ID with DeriveTransferKey, so that no two objects share the
+// nonces of a key, and a block of one object doesn't open in another's
+// receiver.
+func WithEncryption(secret []byte) PipelineOption {
+	return func(p *pipelineConfig) { p.secret = secret }
+}
+
+// WithPacketizer sets the packet framing. By default, a packet is the code
+// block's ID, 8 bytes in network byte order, followed by its data.
+func WithPacketizer(pk Packetizer) PipelineOption {
+	return func(p *pipelineConfig) { p.packetizer = pk }
+}
+
+// WithPacing limits the sender to bytesPerSecond bytes of packets per second,
+// by sleeping before handing out packets which would exceed the rate.
+func WithPacing(bytesPerSecond float64) PipelineOption {
+	return func(p *pipelineConfig) { p.rate = bytesPerSecond }
+}
+
+// Pipeline builds matching senders and receivers.
+type Pipeline struct {
+	config pipelineConfig
+}
+
+// NewPipeline assembles a pipeline from the given layers. Returns an error if
+// no codec was given.
+func NewPipeline(opts ...PipelineOption) (*Pipeline, error) {
+	p := &Pipeline{config: pipelineConfig{
+		packetizer: idPacketizer{},
+		now:        time.Now,
+		sleep:      time.Sleep,
+	}}
+	for _, opt := range opts {
+		opt(&p.config)
+	}
+	if p.config.codec == nil {
+		return nil, errors.New("fountain: pipeline has no codec")
+	}
+	if p.config.rate < 0 {
+		return nil, fmt.Errorf("fountain: pipeline pacing rate %v is negative", p.config.rate)
+	}
+	return p, nil
+}
+
+// sealer returns the sealer of the object with the given ID, or nil if the
+// pipeline doesn't encrypt.
+func (c *pipelineConfig) sealer(objectID uint32) (*SymbolSealer, error) {
+	if c.secret == nil {
+		return nil, nil
+	}
+	key, err := DeriveTransferKey(c.secret, objectID, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return NewSymbolSealer(aead, objectID)
+}
+
+// PipelineSender produces the packets of one object.
+type PipelineSender struct {
+	config  *pipelineConfig
+	sealer  *SymbolSealer
+	encoder Encoder
+	length  int
+
+	// next is the earliest time the next packet may be handed out.
+	next time.Time
+}
+
+// NewSender creates a sender for an object, which is left untouched. The
+// object ID must be unique among the objects the pipeline sends, and the
+// receiver created with the same ID.
+func (p *Pipeline) NewSender(objectID uint32, object []byte) (*PipelineSender, error) {
+	sealer, err := p.config.sealer(objectID)
+	if err != nil {
+		return nil, err
+	}
+	// Both copy the object, which the codec may then encode in place.
+	var message []byte
+	if p.config.checksum {
+		message = AppendIntegrityTrailer(object)
+	} else {
+		message = bytes.Clone(object)
+	}
+	return &PipelineSender{
+		config:  &p.config,
+		sealer:  sealer,
+		encoder: intermediateEncoder(p.config.codec, message),
+		length:  len(message),
+	}, nil
+}
+
+// Packet returns the packet carrying the code block with the given ID. With
+// pacing, it first waits until sending the packet keeps within the rate.
+func (s *PipelineSender) Packet(id int64) ([]byte, error) {
+	b := s.encoder.Generate(id)
+	if s.sealer != nil {
+		b = s.sealer.Seal(b)
+	}
+	packet, err := s.config.packetizer.Packetize(b)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.rate > 0 {
+		now := s.config.now()
+		if s.next.After(now) {
+			s.config.sleep(s.next.Sub(now))
+		} else {
+			s.next = now
+		}
+		s.next = s.next.Add(time.Duration(float64(len(packet)) / s.config.rate * float64(time.Second)))
+	}
+	return packet, nil
+}
+
+// PipelineReceiver decodes one object from packets.
+type PipelineReceiver struct {
+	config  *pipelineConfig
+	sealer  *SymbolSealer
+	decoder Decoder
+
+	// Rejected counts the packets which couldn't be depacketized or failed
+	// authentication.
+	Rejected int
+}
+
+// NewReceiver creates a receiver for the object with the given ID, of
+// objectLength bytes.
+func (p *Pipeline) NewReceiver(objectID uint32, objectLength int) (*PipelineReceiver, error) {
+	sealer, err := p.config.sealer(objectID)
+	if err != nil {
+		return nil, err
+	}
+	if p.config.checksum {
+		objectLength += IntegrityTrailerLen
+	}
+	return &PipelineReceiver{config: &p.config, sealer: sealer, decoder: p.config.codec.NewDecoder(objectLength)}, nil
+}
+
+// Receive processes a packet, and returns whether the object can be decoded.
+// Returns an error if the packet was rejected.
+func (r *PipelineReceiver) Receive(packet []byte) (bool, error) {
+	b, err := r.config.packetizer.Depacketize(packet)
+	if err == nil && r.sealer != nil {
+		b, err = r.sealer.Open(b)
+	}
+	if err != nil {
+		r.Rejected++
+		return false, err
+	}
+	return r.decoder.AddBlocks([]LTBlock{b}), nil
+}
+
+// Object decodes the object, verifying its integrity trailer if the pipeline
+// has one.
+func (r *PipelineReceiver) Object() ([]byte, error) {
+	if r.config.checksum {
+		return DecodeVerified(r.decoder)
+	}
+	return TryDecode(r.decoder)
+}
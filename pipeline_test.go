@@ -0,0 +1,164 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// testPipelineSecret is the long-term secret of the encrypting pipelines.
+var testPipelineSecret = []byte("long-term secret")
+
+// newTestSender creates a sender, failing the test on error.
+func newTestSender(t *testing.T, p *Pipeline, objectID uint32, object []byte) *PipelineSender {
+	t.Helper()
+	s, err := p.NewSender(objectID, object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestReceiver creates a receiver, failing the test on error.
+func newTestReceiver(t *testing.T, p *Pipeline, objectID uint32, objectLength int) *PipelineReceiver {
+	t.Helper()
+	r, err := p.NewReceiver(objectID, objectLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestPipeline(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	codecs := testCodecs()
+	layers := []struct {
+		name string
+		opts []PipelineOption
+	}{
+		{"plain", nil},
+		{"checksum", []PipelineOption{WithChecksum()}},
+		{"sealed", []PipelineOption{WithEncryption(testPipelineSecret)}},
+		{"all", []PipelineOption{WithChecksum(), WithEncryption(testPipelineSecret), WithPacketizer(RFC5053Packetizer{SBN: 2})}},
+	}
+
+	for _, c := range codecs {
+		for _, l := range layers {
+			p, err := NewPipeline(append([]PipelineOption{WithCodec(c)}, l.opts...)...)
+			if err != nil {
+				t.Fatalf("%T %s: NewPipeline failed: %v", c, l.name, err)
+			}
+			original := append([]byte(nil), message...)
+			sender := newTestSender(t, p, 3, original)
+			receiver := newTestReceiver(t, p, 3, len(message))
+			done := false
+			for id := int64(0); id < 500 && !done; id++ {
+				packet, err := sender.Packet(id)
+				if err != nil {
+					t.Fatalf("%T %s: Packet(%d) failed: %v", c, l.name, id, err)
+				}
+				if done, err = receiver.Receive(packet); err != nil {
+					t.Fatalf("%T %s: Receive failed: %v", c, l.name, err)
+				}
+			}
+			if !done {
+				t.Fatalf("%T %s: no decode after 500 packets", c, l.name)
+			}
+			out, err := receiver.Object()
+			if err != nil {
+				t.Fatalf("%T %s: Object failed: %v", c, l.name, err)
+			}
+			if !bytes.Equal(out, message) {
+				t.Errorf("%T %s: decoded %q, want %q", c, l.name, out, message)
+			}
+			if !bytes.Equal(original, message) {
+				t.Errorf("%T %s: sender modified the object", c, l.name)
+			}
+		}
+	}
+}
+
+func TestPipelineRejects(t *testing.T) {
+	p, err := NewPipeline(WithCodec(NewRU10Codec(10, 1)), WithEncryption(testPipelineSecret),
+		WithPacketizer(RFC5053Packetizer{SBN: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := []byte("abcdefghijklmnopqrst")
+	sender := newTestSender(t, p, 1, object)
+	receiver := newTestReceiver(t, p, 1, 20)
+
+	packet, _ := sender.Packet(0)
+	packet[len(packet)-1] ^= 1
+	if _, err := receiver.Receive(packet); err == nil {
+		t.Errorf("Receive accepted a tampered packet")
+	}
+	other, _ := RFC5053Packetizer{SBN: 2}.Packetize(LTBlock{BlockCode: 0, Data: []byte("x")})
+	if _, err := receiver.Receive(other); err == nil {
+		t.Errorf("Receive accepted a packet for another source block")
+	}
+	// The same object sent again as another object is sealed under another
+	// key, so its blocks differ and don't open in this object's receiver.
+	packet, _ = sender.Packet(1)
+	replayed, _ := newTestSender(t, p, 2, object).Packet(1)
+	if bytes.Equal(replayed, packet) {
+		t.Errorf("two objects sealed block 1 alike")
+	}
+	if _, err := receiver.Receive(replayed); err == nil {
+		t.Errorf("Receive accepted a block of another object")
+	}
+	if receiver.Rejected != 3 {
+		t.Errorf("Rejected = %d, want 3", receiver.Rejected)
+	}
+	if _, err := receiver.Object(); err == nil {
+		t.Errorf("Object succeeded with no blocks")
+	}
+}
+
+func TestPipelinePacing(t *testing.T) {
+	p, err := NewPipeline(WithCodec(NewBinaryCodec(4)), WithPacing(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Unix(0, 0)
+	var slept time.Duration
+	p.config.now = func() time.Time { return clock }
+	p.config.sleep = func(d time.Duration) {
+		slept += d
+		clock = clock.Add(d)
+	}
+
+	// Each packet is 8 bytes of ID plus 2 bytes of data: 100ms at 100 bytes/s.
+	sender := newTestSender(t, p, 0, []byte("abcdefgh"))
+	for id := int64(0); id < 5; id++ {
+		if _, err := sender.Packet(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := 400 * time.Millisecond; slept != want {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestPipelineOptions(t *testing.T) {
+	if _, err := NewPipeline(WithChecksum()); err == nil {
+		t.Errorf("NewPipeline succeeded without a codec")
+	}
+	if _, err := NewPipeline(WithCodec(NewBinaryCodec(4)), WithPacing(-1)); err == nil {
+		t.Errorf("NewPipeline succeeded with a negative rate")
+	}
+}
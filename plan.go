@@ -0,0 +1,222 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"math"
+)
+
+// Choosing a codec and its parameters means trading reception overhead
+// against the probability of failing to decode, given how lossy the channel
+// is. PlanParameters makes the choice from the formulas the codecs come with:
+// a Reed-Solomon code needs exactly K shares but only fits small K, an R10
+// raptor code fails to decode from K+A symbols with probability about
+// 0.85*0.567^A (RFC 5053 §1), and the outer code of an online code fails with
+// probability (epsilon/2)^(quality+1) (Maymounkov and Mazieres). Losses are
+// taken to be independent, so the number of code blocks arriving out of n
+// sent is binomial.
+
+// CodecKind identifies one of the package's codecs.
+type CodecKind int
+
+// The codecs PlanParameters chooses from.
+const (
+	ReedSolomonCodecKind CodecKind = iota + 1
+	RaptorCodecKind
+	OnlineCodecKind
+)
+
+// String returns the name of the codec.
+func (k CodecKind) String() string {
+	switch k {
+	case ReedSolomonCodecKind:
+		return "reed-solomon"
+	case RaptorCodecKind:
+		return "raptor"
+	case OnlineCodecKind:
+		return "online"
+	}
+	return fmt.Sprintf("CodecKind(%d)", int(k))
+}
+
+// planReedSolomonMaxK is the number of source blocks below which
+// PlanParameters picks the Reed-Solomon codec, whose lack of overhead matters
+// most for small K.
+const planReedSolomonMaxK = 30
+
+// planOnlineEpsilon is the epsilon of the online codecs PlanParameters plans.
+const planOnlineEpsilon = 0.01
+
+// CodecParams are the parameters of a codec chosen by PlanParameters, along
+// with what to expect of it.
+type CodecParams struct {
+	// Kind is the codec.
+	Kind CodecKind
+
+	// SourceBlocks is the number of source blocks (K), and SymbolSize their
+	// size in bytes (T).
+	SourceBlocks, SymbolSize int
+
+	// Alignment is the symbol alignment (Al) of a raptor codec.
+	Alignment int
+
+	// Epsilon and Quality are the parameters of an online codec.
+	Epsilon float64
+	Quality int
+
+	// Shares is the number of shares of a Reed-Solomon codec.
+	Shares int
+
+	// Overhead is the number of code blocks beyond K the receiver should
+	// count on needing.
+	Overhead int
+
+	// SendBlocks is the number of code blocks to send, and FailureProb the
+	// estimated probability that the receiver can't decode the message from
+	// those which arrive.
+	SendBlocks  int
+	FailureProb float64
+}
+
+// NewCodec creates the codec the parameters describe. The seed is used by the
+// online codec.
+func (p CodecParams) NewCodec(seed int64) Codec {
+	switch p.Kind {
+	case ReedSolomonCodecKind:
+		return NewReedSolomonCodec(p.SourceBlocks, p.Shares)
+	case RaptorCodecKind:
+		return NewRaptorCodec(p.SourceBlocks, p.Alignment)
+	case OnlineCodecKind:
+		return NewOnlineCodec(p.SourceBlocks, p.Epsilon, p.Quality, seed)
+	}
+	return nil
+}
+
+// PlanParameters chooses a codec, and its parameters, for sending a message of
+// messageLength bytes in packets of at most maxPacketSize bytes, one code
+// block per packet with an RFC 5053 FEC Payload ID, over a channel losing a
+// fraction lossRate of packets independently, so that the receiver fails to
+// decode with probability at most targetFailureProb. Small messages get a
+// Reed-Solomon codec, messages of up to MaxRaptorSourceSymbols aligned
+// symbols a raptor codec, and larger ones an online codec. The raptor codec
+// isn't planned past the K of RFC 5053, as its systematic indices beyond are
+// slow to search for and not interoperable. If the target can't be met,
+// returns the best parameters found along with an error.
+func PlanParameters(messageLength, maxPacketSize int, lossRate, targetFailureProb float64) (CodecParams, error) {
+	if messageLength < 0 || maxPacketSize <= PayloadIDLen || !(lossRate >= 0 && lossRate < 1) ||
+		!(targetFailureProb > 0 && targetFailureProb < 1) {
+		return CodecParams{}, fmt.Errorf("%w: can't plan for a %d-byte message in %d-byte packets, loss rate %v, failure probability %v",
+			ErrInvalidCodec, messageLength, maxPacketSize, lossRate, targetFailureProb)
+	}
+	t := maxPacketSize - PayloadIDLen
+	k := max(1, (messageLength+t-1)/t)
+	arrive := 1 - lossRate
+	// Raptor symbols are aligned, so there may be more of them.
+	al := 1
+	if t >= 4 {
+		al = 4
+	}
+	rt := t / al * al
+	rk := max(MinRaptorSourceSymbols, (messageLength+rt-1)/rt)
+
+	var p CodecParams
+	switch {
+	case k < planReedSolomonMaxK:
+		p = CodecParams{Kind: ReedSolomonCodecKind, SourceBlocks: k, SymbolSize: t}
+		p.SendBlocks = sendBlocks(k, arrive, targetFailureProb)
+		p.Shares = min(p.SendBlocks, MaxReedSolomonShares)
+		p.SendBlocks = p.Shares
+		p.FailureProb = binomialBelow(p.SendBlocks, arrive, k)
+
+	case rk <= MaxRaptorSourceSymbols:
+		k = rk
+		p = CodecParams{Kind: RaptorCodecKind, SourceBlocks: k, SymbolSize: rt, Alignment: al}
+		// Spend the failure probability on the overhead A and on the losses in
+		// whichever way needs the fewest code blocks sent. Past the point where
+		// the overhead accounts for a thousandth of the target, more of it
+		// can't buy a meaningful allowance for losses.
+		for a := 0; raptorFailureProb(a) > targetFailureProb/1000; a++ {
+			if raptorFailureProb(a) >= targetFailureProb {
+				continue
+			}
+			n := sendBlocks(k+a, arrive, targetFailureProb-raptorFailureProb(a))
+			if p.SendBlocks == 0 || n < p.SendBlocks {
+				p.Overhead, p.SendBlocks = a, n
+			}
+		}
+		p.FailureProb = raptorFailureProb(p.Overhead) + binomialBelow(p.SendBlocks, arrive, k+p.Overhead)
+
+	default:
+		eps := planOnlineEpsilon
+		q := max(1, int(math.Ceil(math.Log(targetFailureProb/2)/math.Log(eps/2)))-1)
+		p = CodecParams{Kind: OnlineCodecKind, SourceBlocks: k, SymbolSize: t, Epsilon: eps, Quality: q}
+		needed := onlineCodec{numSourceBlocks: k, epsilon: eps, quality: q}.estimateDecodeBlocksNeeded()
+		p.Overhead = needed - k
+		p.SendBlocks = sendBlocks(needed, arrive, targetFailureProb/2)
+		p.FailureProb = math.Pow(eps/2, float64(q+1)) + binomialBelow(p.SendBlocks, arrive, needed)
+	}
+	if p.FailureProb > targetFailureProb {
+		return p, fmt.Errorf("fountain: %s codec fails with probability %.3g, more than %.3g",
+			p.Kind, p.FailureProb, targetFailureProb)
+	}
+	return p, nil
+}
+
+// raptorFailureProb returns the probability that the R10 raptor code fails to
+// decode from K+overhead symbols.
+func raptorFailureProb(overhead int) float64 {
+	return 0.85 * math.Pow(0.567, float64(overhead))
+}
+
+// sendBlocks returns the least number of code blocks to send so that, if each
+// arrives independently with probability arrive, at least need arrive except
+// with probability at most fail.
+func sendBlocks(need int, arrive, fail float64) int {
+	hi := need
+	for binomialBelow(hi, arrive, need) > fail {
+		hi *= 2
+	}
+	lo := need
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if binomialBelow(mid, arrive, need) > fail {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// binomialBelow returns the probability that fewer than k of n trials succeed,
+// each with probability p.
+func binomialBelow(n int, p float64, k int) float64 {
+	if k <= 0 {
+		return 0
+	}
+	if k > n {
+		return 1
+	}
+	if p >= 1 {
+		return 0
+	}
+	sum := 0.0
+	lp, lq := math.Log(p), math.Log1p(-p)
+	for i := 0; i < k; i++ {
+		sum += math.Exp(logChoose(n, i) + float64(i)*lp + float64(n-i)*lq)
+	}
+	return math.Min(sum, 1)
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestPlanParameters(t *testing.T) {
+	var tests = []struct {
+		messageLength, maxPacketSize int
+		want                         CodecKind
+	}{
+		{1000, 104, ReedSolomonCodecKind},
+		{100000, 1004, RaptorCodecKind},
+		{10000000, 104, OnlineCodecKind},
+		// 8192 symbols of 1023 bytes would fit the raptor codec, but not once
+		// they are aligned to 1020 bytes.
+		{8192 * 1024, 1024 + PayloadIDLen, RaptorCodecKind},
+		{8192 * 1023, 1023 + PayloadIDLen, OnlineCodecKind},
+		{16384 * 1027, 1027 + PayloadIDLen, OnlineCodecKind},
+	}
+	for _, test := range tests {
+		p, err := PlanParameters(test.messageLength, test.maxPacketSize, 0.1, 1e-6)
+		if err != nil {
+			t.Errorf("PlanParameters(%d, %d) failed: %v", test.messageLength, test.maxPacketSize, err)
+			continue
+		}
+		if p.Kind != test.want {
+			t.Errorf("PlanParameters(%d, %d) chose %s, want %s", test.messageLength, test.maxPacketSize, p.Kind, test.want)
+		}
+		if p.Kind == RaptorCodecKind && p.SourceBlocks > MaxRaptorSourceSymbols {
+			t.Errorf("PlanParameters(%d, %d) = %+v, past the K of RFC 5053", test.messageLength, test.maxPacketSize, p)
+		}
+		if p.SymbolSize+PayloadIDLen > test.maxPacketSize || p.SourceBlocks*p.SymbolSize < test.messageLength {
+			t.Errorf("PlanParameters(%d, %d) = %+v, which doesn't fit the message in the packets",
+				test.messageLength, test.maxPacketSize, p)
+		}
+		if p.FailureProb > 1e-6 || p.SendBlocks < p.SourceBlocks+p.Overhead {
+			t.Errorf("PlanParameters(%d, %d) = %+v, want failure probability at most 1e-6",
+				test.messageLength, test.maxPacketSize, p)
+		}
+	}
+}
+
+func TestPlanParametersRoundTrip(t *testing.T) {
+	message := make([]byte, 20000)
+	rand.New(rand.NewSource(3)).Read(message)
+	p, err := PlanParameters(len(message), 504, 0.2, 1e-4)
+	if err != nil {
+		t.Fatalf("PlanParameters failed: %v", err)
+	}
+	if p.Kind != RaptorCodecKind {
+		t.Fatalf("PlanParameters chose %s, want raptor", p.Kind)
+	}
+
+	c := p.NewCodec(1)
+	ids := make([]int64, p.SendBlocks)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	blocks := EncodeLTBlocksCopy(message, ids, c)
+	r := rand.New(rand.NewSource(4))
+	var received []LTBlock
+	for _, b := range blocks {
+		if r.Float64() >= 0.2 {
+			received = append(received, b)
+		}
+	}
+	d := c.NewDecoder(len(message))
+	if !d.AddBlocks(received) {
+		t.Fatalf("Failed to decode from %d of %d blocks", len(received), len(blocks))
+	}
+	if !bytes.Equal(d.Decode(), message) {
+		t.Errorf("Decoded message doesn't match the original")
+	}
+}
+
+func TestPlanParametersErrors(t *testing.T) {
+	if _, err := PlanParameters(1000, PayloadIDLen, 0.1, 1e-6); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("PlanParameters with no room for symbols returned %v, want ErrInvalidCodec", err)
+	}
+	if _, err := PlanParameters(1000, 104, 1, 1e-6); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("PlanParameters with a loss rate of 1 returned %v, want ErrInvalidCodec", err)
+	}
+	// A Reed-Solomon code can't send enough shares to survive 99% losses.
+	p, err := PlanParameters(1000, 104, 0.99, 1e-6)
+	if err == nil || errors.Is(err, ErrInvalidCodec) || p.Kind != ReedSolomonCodecKind {
+		t.Errorf("PlanParameters at 99%% loss = %+v, %v; want Reed-Solomon parameters and an error", p, err)
+	}
+}
+
+func TestSendBlocks(t *testing.T) {
+	if n := sendBlocks(10, 1, 1e-9); n != 10 {
+		t.Errorf("sendBlocks on a lossless channel = %d, want 10", n)
+	}
+	n := sendBlocks(100, 0.5, 1e-3)
+	if binomialBelow(n, 0.5, 100) > 1e-3 || binomialBelow(n-1, 0.5, 100) <= 1e-3 {
+		t.Errorf("sendBlocks(100, 0.5, 1e-3) = %d, not the least sufficient", n)
+	}
+}
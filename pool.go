@@ -0,0 +1,247 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"sync"
+)
+
+// A repair service shared by several tenants generates code blocks for many
+// objects at once. If jobs were served in arrival order, one large object's
+// repair generation would hold up every small, latency-sensitive object
+// queued behind it. The EncoderPool queues jobs per tenant and shares its
+// workers among the tenants with weighted deficit round robin: in each round a
+// tenant may have as many blocks generated as its weight. A worker generates
+// the blocks of a tenant's turn from one job together, as GenerateBatch does
+// in SourceOrder.
+
+// encodeJob is a request to generate code blocks for one message.
+type encodeJob struct {
+	codec  Codec
+	source []block
+	ids    []int64
+	blocks []LTBlock
+
+	// next is the index of the next ID to hand to a worker, and remaining the
+	// number of blocks not yet generated.
+	next, remaining int
+	done            chan []LTBlock
+}
+
+// tenantQueue holds a tenant's jobs in submission order.
+type tenantQueue struct {
+	name   string
+	weight int
+	credit int
+	jobs   []*encodeJob
+}
+
+// EncoderPool generates code blocks on a fixed number of worker goroutines,
+// scheduling work fairly among tenants. It is safe for concurrent use.
+type EncoderPool struct {
+	mu      sync.Mutex
+	ready   *sync.Cond
+	tenants map[string]*tenantQueue
+
+	// active lists the tenants with queued work in round robin order, and
+	// next is the index of the tenant being served.
+	active []*tenantQueue
+	next   int
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewEncoderPool starts a pool with the given number of workers, at least
+// one.
+func NewEncoderPool(workers int) *EncoderPool {
+	p := newEncoderPool()
+	for i := 0; i < max(workers, 1); i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// newEncoderPool creates a pool without workers.
+func newEncoderPool() *EncoderPool {
+	p := &EncoderPool{tenants: make(map[string]*tenantQueue)}
+	p.ready = sync.NewCond(&p.mu)
+	return p
+}
+
+// tenant returns the queue of the named tenant, creating it with weight 1 if
+// needed. The pool forgets tenants of weight 1 once they have no work queued,
+// so that it doesn't keep an entry for every tenant it has seen. p.mu must be
+// held.
+func (p *EncoderPool) tenant(name string) *tenantQueue {
+	t, ok := p.tenants[name]
+	if !ok {
+		t = &tenantQueue{name: name, weight: 1}
+		p.tenants[name] = t
+	}
+	return t
+}
+
+// SetWeight sets the share of the workers a tenant gets when several tenants
+// have work queued: up to weight code blocks per round. Weights below 1 are
+// treated as 1. A weight other than 1 is kept until it is set back to 1.
+func (p *EncoderPool) SetWeight(tenant string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.tenant(tenant)
+	t.weight = weight
+	p.forget(t)
+}
+
+// forget drops the entry of a tenant of weight 1 without work queued. p.mu
+// must be held.
+func (p *EncoderPool) forget(t *tenantQueue) {
+	if t.weight == 1 && len(t.jobs) == 0 {
+		delete(p.tenants, t.name)
+	}
+}
+
+// Submit queues the generation of the code blocks with the given IDs for a
+// message, on behalf of a tenant. The message's intermediate blocks are
+// computed before Submit returns; the message itself is not modified. The
+// returned channel receives the code blocks, in the order of ids, once they
+// have all been generated. Returns an error wrapping ErrNonCompliant, without
+// queueing anything, if the codec can't compose a block with one of the IDs.
+// Submit panics if the pool has been closed.
+func (p *EncoderPool) Submit(tenant string, c Codec, message []byte, ids []int64) (<-chan []LTBlock, error) {
+	if err := checkBlockCodes(c, ids); err != nil {
+		return nil, err
+	}
+	messageCopy := make([]byte, len(message))
+	copy(messageCopy, message)
+	job := &encodeJob{
+		codec:     c,
+		source:    c.GenerateIntermediateBlocks(messageCopy, c.SourceBlocks()),
+		ids:       ids,
+		blocks:    make([]LTBlock, len(ids)),
+		remaining: len(ids),
+		done:      make(chan []LTBlock, 1),
+	}
+	compactZeroBlocks(job.source)
+	if len(ids) == 0 {
+		job.done <- job.blocks
+		return job.done, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		panic("fountain: Submit on closed EncoderPool")
+	}
+	t := p.tenant(tenant)
+	if len(t.jobs) == 0 {
+		p.active = append(p.active, t)
+	}
+	t.jobs = append(t.jobs, job)
+	p.ready.Signal()
+	return job.done, nil
+}
+
+// take picks the next code blocks to generate: n consecutive ones of a job's
+// IDs, from the ith, as many as the tenant's credit allows. p.mu must be held,
+// and there must be active tenants.
+func (p *EncoderPool) take() (job *encodeJob, i, n int) {
+	if p.next >= len(p.active) {
+		p.next = 0
+	}
+	t := p.active[p.next]
+	if t.credit == 0 {
+		t.credit = t.weight
+	}
+	job = t.jobs[0]
+	i = job.next
+	n = min(t.credit, len(job.ids)-i)
+	job.next += n
+	t.credit -= n
+	if job.next == len(job.ids) {
+		t.jobs = t.jobs[1:]
+	}
+
+	switch {
+	case len(t.jobs) == 0:
+		// The tenant leaves the round; the next tenant moves into its slot.
+		t.credit = 0
+		p.active = append(p.active[:p.next], p.active[p.next+1:]...)
+		p.forget(t)
+	case t.credit == 0:
+		p.next++
+	}
+	return job, i, n
+}
+
+// generate generates the n code blocks of the job from the ith, together if
+// they are XORs of the intermediate blocks.
+func (j *encodeJob) generate(i, n int) {
+	ids := j.ids[i : i+n]
+	symbols := make([]block, n)
+	if g, ok := symbolGeneratorOf(j.codec); ok {
+		for k, id := range ids {
+			symbols[k] = g.generateSymbol(j.source, id)
+		}
+	} else {
+		indices := make([][]int, n)
+		for k, id := range ids {
+			indices[k] = j.codec.PickIndices(id)
+		}
+		symbols = generateLubyTransformBatch(j.source, indices)
+	}
+	for k, b := range symbols {
+		j.blocks[i+k] = LTBlock{BlockCode: ids[k], Data: make([]byte, b.length())}
+		copy(j.blocks[i+k].Data, b.data)
+	}
+}
+
+// work is the body of a worker goroutine.
+func (p *EncoderPool) work() {
+	defer p.wg.Done()
+	p.mu.Lock()
+	for {
+		for len(p.active) == 0 && !p.closed {
+			p.ready.Wait()
+		}
+		if len(p.active) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		job, i, n := p.take()
+		p.mu.Unlock()
+
+		job.generate(i, n)
+
+		p.mu.Lock()
+		job.remaining -= n
+		if job.remaining == 0 {
+			job.done <- job.blocks
+		}
+	}
+}
+
+// Close stops the workers once all submitted work is done, and waits for
+// them to exit.
+func (p *EncoderPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.ready.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncoderPoolScheduling(t *testing.T) {
+	// Schedule without workers to check the round robin order directly.
+	p := newEncoderPool()
+	p.SetWeight("b", 2)
+	c := NewBinaryCodec(4)
+	p.Submit("a", c, []byte("abcd"), []int64{1, 2, 3, 4, 5})
+	p.Submit("b", c, []byte("abcd"), []int64{11, 12, 13, 14, 15})
+	p.Submit("a", c, []byte("abcd"), []int64{6})
+
+	var order []int64
+	for len(p.active) > 0 {
+		job, i, n := p.take()
+		order = append(order, job.ids[i:i+n]...)
+	}
+	want := []int64{1, 11, 12, 2, 13, 14, 3, 15, 4, 5, 6}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Scheduled %v, want %v", order, want)
+	}
+	// Once their queues drain, tenants of weight 1 are forgotten, and others
+	// keep their weight until it is set back to 1.
+	if len(p.tenants) != 1 || p.tenants["b"] == nil || p.tenants["b"].weight != 2 {
+		t.Errorf("idle tenants %v, want only b, of weight 2", p.tenants)
+	}
+	p.SetWeight("b", 1)
+	if len(p.tenants) != 0 {
+		t.Errorf("idle tenants %v, want none", p.tenants)
+	}
+	p.Close()
+}
+
+func TestEncoderPoolNoWorkers(t *testing.T) {
+	// A pool asked for no workers still gets one, so that work completes.
+	p := NewEncoderPool(0)
+	defer p.Close()
+	c := NewBinaryCodec(4)
+	done, err := p.Submit("a", c, []byte("abcd"), []int64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks := <-done; len(blocks) != 2 {
+		t.Errorf("got %d blocks, want 2", len(blocks))
+	}
+}
+
+func TestEncoderPool(t *testing.T) {
+	p := NewEncoderPool(2)
+	defer p.Close()
+
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	large := make([]int64, 2000)
+	for i := range large {
+		large[i] = int64(i)
+	}
+	big, err := p.Submit("bulk", codec, message, large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := p.Submit("interactive", codec, message, []int64{100, 101, 102})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Submit("bad", codec, message, []int64{1, MaxRaptorESI + 1}); !errors.Is(err, ErrNonCompliant) {
+		t.Errorf("Submit of an ID past the last ESI = %v, want ErrNonCompliant", err)
+	}
+
+	blocks := <-small
+	want := EncodeLTBlocks(append([]byte(nil), message...), []int64{100, 101, 102}, codec)
+	for i := range want {
+		if blocks[i].BlockCode != want[i].BlockCode || !bytes.Equal(blocks[i].Data, want[i].Data) {
+			t.Errorf("Block %d is %v, want %v", i, blocks[i], want[i])
+		}
+	}
+
+	d := codec.NewDecoder(len(message))
+	d.AddBlocks(<-big)
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("Decoded %q, want %q", decoded, message)
+	}
+}
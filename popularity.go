@@ -0,0 +1,136 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import "sync"
+
+// A code block is useful to the decoder only if it covers an intermediate
+// block which isn't yet solved, so a degree distribution which picks some
+// intermediate blocks rarely leaves them to be covered by the last few code
+// blocks received, and the reception overhead grows. (The R10 precode's
+// half-symbol blocks each cover half of the other intermediate blocks so that
+// none is left out entirely.) Codecs with custom distributions or precodes
+// can be checked for this by counting how often each intermediate block is
+// picked.
+
+// Popularity counts how often each intermediate block of a codec has been
+// picked for a code block. It is safe for concurrent use.
+type Popularity struct {
+	mu     sync.Mutex
+	blocks int64
+	counts []int64
+}
+
+// add counts the intermediate blocks picked for one code block.
+func (p *Popularity) add(indices []int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blocks++
+	for _, i := range indices {
+		for i >= len(p.counts) {
+			p.counts = append(p.counts, 0)
+		}
+		p.counts[i]++
+	}
+}
+
+// Blocks returns the number of code blocks counted.
+func (p *Popularity) Blocks() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.blocks
+}
+
+// Counts returns the histogram of how many code blocks each of the first n
+// intermediate blocks was picked for. For the raptor codecs, n is usually
+// the number of intermediate blocks, not of source blocks.
+func (p *Popularity) Counts(n int) []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make([]int64, n)
+	copy(counts, p.counts)
+	return counts
+}
+
+// Underused returns, in ascending order, the indices of those of the first n
+// intermediate blocks which were picked fewer than fraction times the mean
+// number of times. With a fraction of zero, it returns those never picked.
+func (p *Popularity) Underused(n int, fraction float64) []int {
+	if n <= 0 {
+		return nil
+	}
+	counts := p.Counts(n)
+	var total int64
+	for _, c := range counts {
+		total += c
+	}
+	threshold := fraction * float64(total) / float64(n)
+	var under []int
+	for i, c := range counts {
+		if float64(c) < threshold || c == 0 {
+			under = append(under, i)
+		}
+	}
+	return under
+}
+
+// MeasurePopularity counts the intermediate blocks the codec picks for the
+// code blocks with the given IDs, without encoding anything.
+func MeasurePopularity(c Codec, ids []int64) *Popularity {
+	p := &Popularity{}
+	for _, id := range ids {
+		p.add(c.PickIndices(id))
+	}
+	return p
+}
+
+// popularityCodec is a Codec which counts the intermediate blocks it picks.
+type popularityCodec struct {
+	Codec
+	popularity *Popularity
+}
+
+// PopularityCodec returns a codec which behaves exactly like c, but counts
+// the intermediate blocks picked for every code block it composes, and the
+// Popularity it counts them in. Decoders created by the codec aren't counted,
+// nor are the code blocks of codecs which don't compose them by XOR, such as
+// the Reed-Solomon codec.
+func PopularityCodec(c Codec) (Codec, *Popularity) {
+	p := &Popularity{}
+	return &popularityCodec{Codec: c, popularity: p}, p
+}
+
+// PickIndices picks the indices using the wrapped codec, and counts them.
+func (c *popularityCodec) PickIndices(codeBlockIndex int64) []int {
+	indices := c.Codec.PickIndices(codeBlockIndex)
+	c.popularity.add(indices)
+	return indices
+}
+
+// NewEncoder creates an encoder whose picks are counted.
+func (c *popularityCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
+// unwrap returns the wrapped codec.
+func (c *popularityCodec) unwrap() Codec {
+	return c.Codec
+}
+
+// encodesInPlace reports whether the wrapped codec encodes in place.
+func (c *popularityCodec) encodesInPlace() bool {
+	e, ok := c.Codec.(inPlaceEncoder)
+	return ok && e.encodesInPlace()
+}
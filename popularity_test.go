@@ -0,0 +1,76 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPopularityCodec(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	ids := []int64{3, 7, 11, 15, 19, 23, 27}
+	for _, c := range testCodecs() {
+		pc, p := PopularityCodec(c)
+		got := EncodeLTBlocksCopy(message, ids, pc)
+		want := EncodeLTBlocksCopy(message, ids, c)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: popularity codec encodes %v, want %v", c, got, want)
+		}
+		if p.Blocks() != int64(len(ids)) {
+			t.Errorf("%T: counted %d blocks, want %d", c, p.Blocks(), len(ids))
+		}
+		m := MeasurePopularity(c, ids)
+		if !reflect.DeepEqual(p.Counts(32), m.Counts(32)) {
+			t.Errorf("%T: encoder counted %v, MeasurePopularity %v", c, p.Counts(32), m.Counts(32))
+		}
+	}
+}
+
+func TestPopularityUnderused(t *testing.T) {
+	// Every code block has degree one, so five of them leave at least five of
+	// the ten source blocks out.
+	c := NewSeededLubyCodec(10, 99, []float64{0, 1})
+	p := MeasurePopularity(c, []int64{0, 1, 2, 3, 4})
+	counts := p.Counts(10)
+	var total int64
+	var never []int
+	for i, n := range counts {
+		total += n
+		if n == 0 {
+			never = append(never, i)
+		}
+	}
+	if total != 5 {
+		t.Errorf("Counts(10) = %v, want 5 picks in all", counts)
+	}
+	if len(never) < 5 {
+		t.Errorf("Counts(10) = %v, want at least 5 blocks never picked", counts)
+	}
+	if got := p.Underused(10, 0); !reflect.DeepEqual(got, never) {
+		t.Errorf("Underused(10, 0) = %v, want %v", got, never)
+	}
+	// The mean is half a pick, so blocks picked at all aren't underused even at
+	// a fraction of 2, but every block is at a fraction of 20.
+	if got := p.Underused(10, 2); !reflect.DeepEqual(got, never) {
+		t.Errorf("Underused(10, 2) = %v, want %v", got, never)
+	}
+	if got := p.Underused(10, 20); len(got) != 10 {
+		t.Errorf("Underused(10, 20) = %v, want all blocks", got)
+	}
+	if got := p.Underused(0, 1); got != nil {
+		t.Errorf("Underused(0, 1) = %v, want none", got)
+	}
+}
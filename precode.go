@@ -0,0 +1,94 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math"
+)
+
+// Precode describes the parity blocks a raptor-like codec appends to its K
+// source blocks to form the intermediate blocks from which code blocks are
+// composed. The R10 precode (S LDPC blocks followed by H half-symbol blocks)
+// is R10Precode; the RU10 codec can be given an alternative, for example a
+// heavier LDPC structure for very lossy channels.
+type Precode interface {
+	// Compositions returns, for k source blocks, the composition of each parity
+	// block in order. Parity block i is intermediate block k+i, and is the XOR
+	// of the intermediate blocks listed in its composition. The composition
+	// must be sorted, and may only list blocks with indices less than k+i.
+	// The result must depend only on k.
+	Compositions(k int) [][]int
+}
+
+// r10Precode is the precode from RFC 5053 section 5.4.2.3.
+type r10Precode struct{}
+
+// R10Precode is the LDPC and half-symbol precode of the R10 raptor code.
+var R10Precode Precode = r10Precode{}
+
+// Compositions returns the S LDPC compositions followed by the H half-symbol
+// compositions, where S and H are given by intermediateSymbols(k).
+// The S blocks are a low density parity check group of blocks which have
+// contributions from three of the first K intermediate blocks arranged in
+// successive clusters. The H blocks are composed of many (half) of the first
+// K+S intermediate blocks following a gray code.
+func (r10Precode) Compositions(k int) [][]int {
+	p := raptorParamsFor(k)
+	s, h := p.s, p.h
+	compositions := make([][]int, s, s+h)
+
+	for i := 0; i < k; i++ {
+		a := 1 + (int(math.Floor(float64(i)/float64(s))) % (s - 1))
+		b := i % s
+		compositions[b] = append(compositions[b], i)
+		b = (b + a) % s
+		compositions[b] = append(compositions[b], i)
+		b = (b + a) % s
+		compositions[b] = append(compositions[b], i)
+	}
+
+	hprime := int(math.Ceil(float64(h) / 2))
+	m := buildGraySequence(k+s, hprime)
+	for i := 0; i < h; i++ {
+		var hcomposition []int
+		for j := 0; j < k+s; j++ {
+			if bitSet(uint(m[j]), uint(i)) {
+				hcomposition = append(hcomposition, j)
+			}
+		}
+		compositions = append(compositions, hcomposition)
+	}
+	return compositions
+}
+
+// addPrecodeConstraints adds an equation for each parity block of the
+// precode to the decode matrix. Each parity block XORed with its composition
+// is zero, so the equations have a zero value.
+func addPrecodeConstraints(m *sparseMatrix, k int, compositions [][]int) {
+	for i, composition := range compositions {
+		equation := make([]int, len(composition), len(composition)+1)
+		copy(equation, composition)
+		m.addEquation(append(equation, k+i), block{})
+	}
+}
+
+// appendParityBlocks computes the parity blocks of the precode from the k
+// source blocks and appends them, returning the intermediate blocks.
+func appendParityBlocks(source []block, compositions [][]int) []block {
+	for _, composition := range compositions {
+		source = append(source, generateLubyTransformBlock(source, composition))
+	}
+	return source
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestR10PrecodeCompositions(t *testing.T) {
+	compositions := R10Precode.Compositions(10)
+	_, s, h := intermediateSymbols(10)
+	if len(compositions) != s+h {
+		t.Fatalf("Got %d compositions, want S+H=%d", len(compositions), s+h)
+	}
+	// The first LDPC block, from the Luby/Shokrollahi test vectors also used
+	// in TestRaptorDecoderConstruction.
+	if !reflect.DeepEqual(compositions[0], []int{0, 5, 6, 7}) {
+		t.Errorf("First LDPC composition was %v, should be {0, 5, 6, 7}", compositions[0])
+	}
+	for i, c := range compositions {
+		for j := range c {
+			if c[j] >= 10+i || (j > 0 && c[j] <= c[j-1]) {
+				t.Errorf("Composition %d (%v) isn't sorted or refers to a later block", i, c)
+				break
+			}
+		}
+	}
+}
+
+// stripePrecode is a simple custom precode: n parity blocks, each the XOR of
+// every n'th source block.
+type stripePrecode struct {
+	n int
+}
+
+func (p stripePrecode) Compositions(k int) [][]int {
+	compositions := make([][]int, p.n)
+	for i := 0; i < k; i++ {
+		compositions[i%p.n] = append(compositions[i%p.n], i)
+	}
+	return compositions
+}
+
+func TestRU10CustomPrecode(t *testing.T) {
+	c := NewRU10CodecWithPrecode(13, 4, stripePrecode{n: 5})
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789")
+
+	messageCopy := make([]byte, len(message))
+	copy(messageCopy, message)
+	intermediate := c.GenerateIntermediateBlocks(messageCopy, c.SourceBlocks())
+	if len(intermediate) != 18 {
+		t.Fatalf("Got %d intermediate blocks, want 13+5", len(intermediate))
+	}
+	for _, i := range c.PickIndices(12345) {
+		if i >= 18 {
+			t.Errorf("PickIndices picked block %d of 18", i)
+		}
+	}
+
+	ids := make([]int64, 60)
+	random := rand.New(rand.NewSource(8923489))
+	for i := range ids {
+		ids[i] = random.Int63()
+	}
+	copy(messageCopy, message)
+	blocks := EncodeLTBlocks(messageCopy, ids, c)
+
+	d := c.NewDecoder(len(message))
+	for i := range blocks {
+		if d.AddBlocks(blocks[i : i+1]) {
+			break
+		}
+	}
+	if decoded := d.Decode(); !reflect.DeepEqual(decoded, message) {
+		t.Errorf("Decoded %v, want %v", decoded, message)
+	}
+}
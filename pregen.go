@@ -0,0 +1,188 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Edge servers distributing static content can serve loss-protected downloads
+// without encoding anything on the fly if the repair symbols are generated
+// ahead of time. PregenerateRepair walks a content library and writes a
+// container file (see ContainerWriter) for each file in it, in parallel.
+
+// ContainerExt is the extension of container files.
+const ContainerExt = ".fount"
+
+// PregenOptions configure PregenerateRepair. The encoding parameters of each
+// file are derived by DeriveObjectParams.
+type PregenOptions struct {
+	// OutDir is the directory the containers are written to, mirroring the
+	// layout of the library. If empty, each container is written next to its
+	// file.
+	OutDir string
+
+	// Alignment, PayloadSize, MaxSubBlock, MinSymbols and MaxSymbolsPerPacket
+	// are the inputs of DeriveObjectParams.
+	Alignment, PayloadSize, MaxSubBlock, MinSymbols, MaxSymbolsPerPacket int
+
+	// RepairPercent is the number of repair symbols generated for each source
+	// block, as a percentage of its number of source symbols.
+	RepairPercent float64
+
+	// Systematic is whether the containers also hold the source symbols.
+	Systematic bool
+
+	// Workers is the number of files encoded concurrently. If zero,
+	// runtime.GOMAXPROCS(0) is used.
+	Workers int
+}
+
+// DefaultPregenOptions returns options suited to packets of about 1KB, with
+// 20% repair and the source symbols included.
+func DefaultPregenOptions() PregenOptions {
+	return PregenOptions{
+		Alignment:           4,
+		PayloadSize:         1024,
+		MaxSubBlock:         1 << 20,
+		MinSymbols:          1024,
+		MaxSymbolsPerPacket: 10,
+		RepairPercent:       20,
+		Systematic:          true,
+	}
+}
+
+// PregenResult reports the outcome for one file of the library.
+type PregenResult struct {
+	// Path is the file's path relative to the library's root, and Container
+	// the path of the container written for it.
+	Path, Container string
+
+	// Params are the file's encoding parameters.
+	Params ObjectParams
+
+	// Repair is the number of repair symbols written for each source block.
+	Repair int
+
+	// Err is the error which prevented writing the container, if any.
+	Err error
+}
+
+// PregenerateRepair writes a container for each regular file under root,
+// except existing containers, and returns the results sorted by path. Files
+// too small for the codec's minimum number of source symbols fail with an
+// error wrapping ErrNonCompliant. Returns an error, and no results, only if
+// the library can't be walked.
+func PregenerateRepair(root string, opts PregenOptions) ([]PregenResult, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && !strings.HasSuffix(path, ContainerExt) {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	results := make([]PregenResult, len(paths))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = pregenerate(root, paths[i], opts)
+			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results, nil
+}
+
+// pregenerate writes the container of the file at path, relative to root.
+// The container is written to a temporary file first, so that a server never
+// sees a partial container.
+func pregenerate(root, path string, opts PregenOptions) PregenResult {
+	r := PregenResult{Path: path, Container: filepath.Join(root, path) + ContainerExt}
+	if opts.OutDir != "" {
+		r.Container = filepath.Join(opts.OutDir, path) + ContainerExt
+	}
+	object, err := os.ReadFile(filepath.Join(root, path))
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if len(object) == 0 {
+		r.Err = fmt.Errorf("fountain: %s is empty", path)
+		return r
+	}
+	r.Params, r.Err = DeriveObjectParams(int64(len(object)), opts.Alignment, opts.PayloadSize,
+		opts.MaxSubBlock, opts.MinSymbols, opts.MaxSymbolsPerPacket)
+	if r.Err != nil {
+		return r
+	}
+	r.Repair = int(math.Ceil(float64(r.Params.sourceSymbols(0)) * opts.RepairPercent / 100))
+	if r.Err = os.MkdirAll(filepath.Dir(r.Container), 0o755); r.Err != nil {
+		return r
+	}
+	f, err := os.CreateTemp(filepath.Dir(r.Container), ".pregen-*")
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	metadata := map[string]string{"name": filepath.ToSlash(path)}
+	w := bufio.NewWriter(f)
+	r.Err = WriteObjectContainer(w, object, r.Params, metadata, opts.Systematic, r.Repair)
+	if r.Err == nil {
+		r.Err = w.Flush()
+	}
+	if err := f.Close(); r.Err == nil {
+		r.Err = err
+	}
+	if r.Err == nil {
+		r.Err = os.Rename(f.Name(), r.Container)
+	}
+	if r.Err != nil {
+		os.Remove(f.Name())
+	}
+	return r
+}
@@ -0,0 +1,122 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPregenerateRepair(t *testing.T) {
+	root := t.TempDir()
+	random := rand.New(rand.NewSource(8))
+	files := map[string][]byte{
+		"a.bin":         make([]byte, 5000),
+		"dir/b.bin":     make([]byte, 20000),
+		"dir/sub/c.bin": make([]byte, 1234),
+		"tiny.txt":      []byte("hi"),
+	}
+	for name, data := range files {
+		random.Read(data)
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultPregenOptions()
+	opts.OutDir = t.TempDir()
+	opts.Workers = 2
+	results, err := PregenerateRepair(root, opts)
+	if err != nil {
+		t.Fatalf("PregenerateRepair failed: %v", err)
+	}
+	if len(results) != len(files) {
+		t.Fatalf("PregenerateRepair returned %d results, want %d", len(results), len(files))
+	}
+	for _, r := range results {
+		if r.Path == "tiny.txt" {
+			if !errors.Is(r.Err, ErrNonCompliant) {
+				t.Errorf("%s: error %v, want ErrNonCompliant", r.Path, r.Err)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Path, r.Err)
+			continue
+		}
+		if want := filepath.Join(opts.OutDir, r.Path) + ContainerExt; r.Container != want {
+			t.Errorf("%s: container %s, want %s", r.Path, r.Container, want)
+		}
+		if k := r.Params.SourceBlockSymbols(0); r.Repair*5 < k || r.Repair*5 > k+5 {
+			t.Errorf("%s: %d repair symbols for K=%d, want 20%%", r.Path, r.Repair, k)
+		}
+		f, err := os.Open(r.Container)
+		if err != nil {
+			t.Fatal(err)
+		}
+		object, h, err := ReadObjectContainer(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: ReadObjectContainer failed: %v", r.Path, err)
+		} else if !bytes.Equal(object, files[r.Path]) {
+			t.Errorf("%s: container decodes to the wrong object", r.Path)
+		}
+		if h.Metadata["name"] != r.Path {
+			t.Errorf("%s: container name %q", r.Path, h.Metadata["name"])
+		}
+	}
+}
+
+func TestPregenerateRepairOnly(t *testing.T) {
+	root := t.TempDir()
+	object := make([]byte, 3000)
+	rand.New(rand.NewSource(2)).Read(object)
+	if err := os.WriteFile(filepath.Join(root, "x"), object, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultPregenOptions()
+	opts.Systematic = false
+	opts.RepairPercent = 120
+	results, err := PregenerateRepair(root, opts)
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("PregenerateRepair = %+v, %v", results, err)
+	}
+
+	// Rerunning skips the containers written next to the files.
+	if again, err := PregenerateRepair(root, opts); err != nil || len(again) != 1 {
+		t.Errorf("second PregenerateRepair = %+v, %v, want a single result", again, err)
+	}
+
+	f, err := os.Open(filepath.Join(root, "x"+ContainerExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, _, err := ReadObjectContainer(f)
+	if err != nil {
+		t.Fatalf("ReadObjectContainer failed: %v", err)
+	}
+	if !bytes.Equal(got, object) {
+		t.Errorf("repair-only container decodes to the wrong object")
+	}
+}
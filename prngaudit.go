@@ -0,0 +1,135 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+)
+
+// Certification of broadcast equipment can require that a third party be able
+// to reproduce exactly how every code block of an object was composed. Each of
+// the package's codecs picks composition indices with a PRNG seeded from the
+// code block ID (and, for some codecs, a codec seed), so the mapping is fully
+// described by the PRNG used, its seed, and how many values were drawn from
+// it. AuditedCodec logs that for every code block an encoder generates.
+
+// PRNGTrace records the PRNG draws which chose the composition of a code block.
+type PRNGTrace struct {
+	// BlockCode is the ID of the code block.
+	BlockCode int64
+
+	// Generator names the PRNG: "mt19937" or "mt19937-64" for the Mersenne
+	// Twisters, or "rfc5053-rand" for the Rand function of RFC 5053.
+	Generator string
+
+	// Seed is the seed of the PRNG. The 64-bit Mersenne Twister is seeded
+	// with SeedSlice if there is more than one value.
+	Seed []uint64
+
+	// Draws is the number of values drawn from the PRNG.
+	Draws int
+}
+
+// String formats the trace as a single log line.
+func (t PRNGTrace) String() string {
+	return fmt.Sprintf("id=%d gen=%s seed=%v draws=%d", t.BlockCode, t.Generator, t.Seed, t.Draws)
+}
+
+// prngTracer is implemented by codecs whose index selection can be traced.
+type prngTracer interface {
+	tracePRNG(codeBlockIndex int64) (PRNGTrace, bool)
+}
+
+// countingSource counts the values drawn from a PRNG.
+type countingSource struct {
+	rand.Source
+	draws int
+}
+
+// Int63 returns the next value of the underlying PRNG.
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.Source.Int63()
+}
+
+// auditedCodec is a Codec which records the PRNG draws of PickIndices.
+type auditedCodec struct {
+	Codec
+	tracer prngTracer
+	record func(PRNGTrace)
+}
+
+// AuditedCodec returns a codec which behaves exactly like c, but calls record
+// with the PRNG trace of every code block whose indices it picks. Wrap the
+// codec separately for each object, so that each object's log holds only its
+// own draws. Decoders created by the codec aren't audited. Returns an error if
+// c's index selection can't be traced, as for a Luby codec using a
+// caller-supplied PRNG.
+func AuditedCodec(c Codec, record func(PRNGTrace)) (Codec, error) {
+	t, ok := c.(prngTracer)
+	if ok {
+		_, ok = t.tracePRNG(0)
+	}
+	if !ok {
+		return nil, fmt.Errorf("fountain: can't trace the PRNG of %T", c)
+	}
+	return &auditedCodec{Codec: c, tracer: t, record: record}, nil
+}
+
+// PickIndices picks the indices using the wrapped codec, and records the
+// draws it made.
+func (c *auditedCodec) PickIndices(codeBlockIndex int64) []int {
+	t, _ := c.tracer.tracePRNG(codeBlockIndex)
+	c.record(t)
+	return c.Codec.PickIndices(codeBlockIndex)
+}
+
+// NewEncoder creates an encoder whose index selection is audited.
+func (c *auditedCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
+// unwrap returns the wrapped codec.
+func (c *auditedCodec) unwrap() Codec {
+	return c.Codec
+}
+
+// encodesInPlace reports whether the wrapped codec encodes in place.
+func (c *auditedCodec) encodesInPlace() bool {
+	e, ok := c.Codec.(inPlaceEncoder)
+	return ok && e.encodesInPlace()
+}
+
+// CheckPRNGTrace re-derives the trace of a code block from the codec, and
+// returns an error if it differs from the logged one.
+func CheckPRNGTrace(c Codec, logged PRNGTrace) error {
+	if a, ok := c.(*auditedCodec); ok {
+		c = a.Codec
+	}
+	t, ok := c.(prngTracer)
+	if !ok {
+		return fmt.Errorf("fountain: can't trace the PRNG of %T", c)
+	}
+	want, ok := t.tracePRNG(logged.BlockCode)
+	if !ok {
+		return fmt.Errorf("fountain: can't trace the PRNG of %T", c)
+	}
+	if !reflect.DeepEqual(logged, want) {
+		return fmt.Errorf("fountain: logged PRNG trace %v, codec derives %v", logged, want)
+	}
+	return nil
+}
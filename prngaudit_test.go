@@ -0,0 +1,79 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestAuditedCodec(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	ids := []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+
+	codecs := testCodecs()
+	for _, c := range codecs {
+		var log []PRNGTrace
+		audited, err := AuditedCodec(c, func(t PRNGTrace) { log = append(log, t) })
+		if err != nil {
+			t.Fatalf("%T: AuditedCodec failed: %v", c, err)
+		}
+
+		got := EncodeLTBlocksCopy(message, ids, audited)
+		if want := EncodeLTBlocksCopy(message, ids, c); !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: audited codec encoded %v, want %v", c, got, want)
+		}
+		if len(log) != len(ids) {
+			t.Fatalf("%T: logged %d traces, want %d", c, len(log), len(ids))
+		}
+		for i, trace := range log {
+			if trace.BlockCode != ids[i] || trace.Draws == 0 {
+				t.Errorf("%T: trace %d is %v", c, i, trace)
+			}
+			if err := CheckPRNGTrace(c, trace); err != nil {
+				t.Errorf("%T: %v", c, err)
+			}
+		}
+
+		tampered := log[3]
+		tampered.Draws++
+		if err := CheckPRNGTrace(c, tampered); err == nil {
+			t.Errorf("%T: CheckPRNGTrace accepted %v", c, tampered)
+		}
+	}
+
+	if _, err := AuditedCodec(NewLubyCodec(10, rand.New(NewMersenneTwister(1)), SolitonDistribution(10)), func(PRNGTrace) {}); err == nil {
+		t.Errorf("AuditedCodec accepted a codec with a caller-supplied PRNG")
+	}
+}
+
+func TestPRNGTraceDraws(t *testing.T) {
+	// The triple generators of the raptor codecs draw d, a and b.
+	for _, c := range []Codec{NewRaptorCodec(13, 2), NewRU10Codec(13, 2)} {
+		for id := int64(0); id < 20; id++ {
+			if trace, ok := c.(prngTracer).tracePRNG(id); !ok || trace.Draws != 3 {
+				t.Errorf("%T: trace of block %d is %v, %v; want 3 draws", c, id, trace, ok)
+			}
+		}
+	}
+}
+
+func TestPRNGTraceString(t *testing.T) {
+	trace := PRNGTrace{BlockCode: 7, Generator: "mt19937-64", Seed: []uint64{99, 7}, Draws: 4}
+	if got, want := trace.String(), "id=7 gen=mt19937-64 seed=[99 7] draws=4"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+)
+
+// On marginal links a code block which fails a checksum or sanity check may
+// still be mostly right, or may have been mangled in a way a different parse
+// can undo. Rather than discard such blocks, a QuarantineDecoder holds them
+// aside. If decoding stalls, the application can try to salvage them, or
+// report them.
+
+// QuarantinedBlock is a code block which failed acceptance, with the reason.
+type QuarantinedBlock struct {
+	Block LTBlock
+	Err   error
+}
+
+// QuarantineDecoder wraps a Decoder, passing it only the code blocks which an
+// acceptance check allows and quarantining the rest.
+// Implements fountain.Decoder
+type QuarantineDecoder struct {
+	decoder Decoder
+	accept  func(LTBlock) error
+
+	// capacity bounds the number of quarantined blocks; the oldest are dropped
+	// to make room.
+	capacity    int
+	quarantined []QuarantinedBlock
+	dropped     int
+}
+
+// NewQuarantineDecoder wraps d. Code blocks for which accept returns an error
+// are quarantined, up to capacity of them.
+func NewQuarantineDecoder(d Decoder, accept func(LTBlock) error, capacity int) *QuarantineDecoder {
+	return &QuarantineDecoder{decoder: d, accept: accept, capacity: capacity}
+}
+
+// BlockLengthCheck returns an acceptance check which rejects code blocks whose
+// length isn't the symbol size.
+func BlockLengthCheck(symbolSize int) func(LTBlock) error {
+	return func(b LTBlock) error {
+		if len(b.Data) != symbolSize {
+			return fmt.Errorf("fountain: block %d has length %d, want %d",
+				b.BlockCode, len(b.Data), symbolSize)
+		}
+		return nil
+	}
+}
+
+// AddBlocks passes the acceptable blocks to the wrapped decoder and
+// quarantines the others. Returns true if the message can be fully decoded.
+func (d *QuarantineDecoder) AddBlocks(blocks []LTBlock) bool {
+	accepted := make([]LTBlock, 0, len(blocks))
+	for _, b := range blocks {
+		if err := d.accept(b); err != nil {
+			d.quarantine(b, err)
+			continue
+		}
+		accepted = append(accepted, b)
+	}
+	return d.decoder.AddBlocks(accepted)
+}
+
+func (d *QuarantineDecoder) quarantine(b LTBlock, err error) {
+	if d.capacity <= 0 {
+		d.dropped++
+		return
+	}
+	if len(d.quarantined) == d.capacity {
+		d.quarantined = d.quarantined[1:]
+		d.dropped++
+	}
+	d.quarantined = append(d.quarantined, QuarantinedBlock{Block: b, Err: err})
+}
+
+// Decode extracts the decoded message from the wrapped decoder.
+func (d *QuarantineDecoder) Decode() []byte {
+	return d.decoder.Decode()
+}
+
+// Quarantined returns the blocks currently in quarantine, oldest first.
+func (d *QuarantineDecoder) Quarantined() []QuarantinedBlock {
+	return append([]QuarantinedBlock(nil), d.quarantined...)
+}
+
+// Dropped returns the number of rejected blocks which didn't fit in the
+// quarantine.
+func (d *QuarantineDecoder) Dropped() int {
+	return d.dropped
+}
+
+// Retry gives each quarantined block to salvage, which may return a repaired
+// version of it. Repaired blocks which pass the acceptance check leave
+// quarantine and are given to the decoder; the others stay in quarantine.
+// Returns true if the message can be fully decoded.
+func (d *QuarantineDecoder) Retry(salvage func(QuarantinedBlock) (LTBlock, bool)) bool {
+	var keep []QuarantinedBlock
+	var recovered []LTBlock
+	for _, q := range d.quarantined {
+		b, ok := salvage(q)
+		if ok {
+			if err := d.accept(b); err == nil {
+				recovered = append(recovered, b)
+				continue
+			}
+		}
+		keep = append(keep, q)
+	}
+	d.quarantined = keep
+	return d.decoder.AddBlocks(recovered)
+}
+
+// Release gives all the quarantined blocks to the decoder unchecked, as a
+// last resort, and empties the quarantine. Returns true if the message can be
+// fully decoded.
+func (d *QuarantineDecoder) Release() bool {
+	blocks := make([]LTBlock, len(d.quarantined))
+	for i, q := range d.quarantined {
+		blocks[i] = q.Block
+	}
+	d.quarantined = nil
+	return d.decoder.AddBlocks(blocks)
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQuarantineDecoder(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	var ids []int64
+	for i := int64(0); i < 16; i++ {
+		ids = append(ids, i)
+	}
+	blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, codec)
+
+	// The link appends a stray byte to some blocks.
+	for i := 0; i < len(blocks); i += 3 {
+		blocks[i].Data = append(blocks[i].Data, 0xff)
+	}
+
+	d := NewQuarantineDecoder(codec.NewDecoder(len(message)), BlockLengthCheck(2), 4)
+	if d.AddBlocks(blocks) {
+		t.Fatalf("Decoder determined with damaged blocks quarantined")
+	}
+	if len(d.Quarantined()) != 4 || d.Dropped() != 2 {
+		t.Fatalf("%d blocks quarantined, %d dropped; want 4, 2", len(d.Quarantined()), d.Dropped())
+	}
+	if d.Quarantined()[0].Block.BlockCode != 6 {
+		t.Errorf("Oldest quarantined block is %d, want 6", d.Quarantined()[0].Block.BlockCode)
+	}
+
+	// Stripping the trailing byte salvages the blocks.
+	determined := d.Retry(func(q QuarantinedBlock) (LTBlock, bool) {
+		return LTBlock{BlockCode: q.Block.BlockCode, Data: q.Block.Data[:2]}, true
+	})
+	if !determined {
+		t.Fatalf("Decoder not determined after salvaging quarantined blocks")
+	}
+	if len(d.Quarantined()) != 0 {
+		t.Errorf("%d blocks left in quarantine", len(d.Quarantined()))
+	}
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("Decoded %q, want %q", decoded, message)
+	}
+}
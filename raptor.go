@@ -15,8 +15,11 @@
 package fountain
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"sort"
+	"sync"
 )
 
 // The Raptor fountain code (also called the R10 code) from RFC 5053.
@@ -28,9 +31,10 @@ import (
 // are part of the encoding. This enables the source blocks to be sent simply,
 // and then repair blocks constructed as needed using the code.
 //
-// A limitation is that the code supports a maximum of 8192 source blocks,
-// thus requiring very large source messages to be split up into sub-messages if
-// smaller packet sizes are a goal. Performance varies from the random fountain
+// RFC 5053 supports a maximum of 8192 source blocks, thus requiring very large
+// source messages to be split up into sub-messages if smaller packet sizes are
+// a goal. The codec goes beyond that, up to MaxRaptorCodecSourceSymbols, with
+// systematic indices of its own. Performance varies from the random fountain
 // the most for higher loss rates and smaller numbers of source blocks. A reasonable
 // expectation is that the encoding overhead due to using the code is a few percent.
 //
@@ -43,11 +47,25 @@ import (
 // A typical usage in a transmission system might be to just split the message and
 // send the first K symbols normally. Then create the codec and generate repair
 // symbols using random ESI values >= K until the message is reconstructed by
-// the receiver.
+// the receiver. A SystematicEncoder implements this pattern.
 //
 // The BlockCode in the resulting LTBlocks will be a uint16-compatible value.
 //
-// IMPORTANT NOTE: encoding is destructive to the input message.
+// Generating the intermediate blocks takes a full solve of the precode
+// matrix, which EncodeLTBlocks repeats on every call. A sender producing
+// repair symbols over time should instead create an Encoder for the message
+// with NewEncoder: it solves once and retains the intermediate blocks, so each
+// further repair symbol costs only its LT XORs.
+//
+// IMPORTANT NOTE: encoding is destructive to the input message. NewEncoder
+// works on a copy.
+//
+// Compatibility: earlier versions of this package computed the number of
+// half-symbols H wrongly when K+S exceeds 6435, which is for K from 6257 up,
+// using 15 where RFC 5053 calls for 16. For those K, they weren't RFC 5053
+// compliant, and for many of them the code wasn't even systematic. Raptor
+// symbols for K from 6257 up don't decode across that change in either
+// direction; K up to 6256 is unaffected.
 
 // raptorCodec describes the parameters needed to construct a raptor code. The codec
 // governs the production of an unbounded set of LTBlocks from a given source message.
@@ -67,7 +85,8 @@ type raptorCodec struct {
 	// with larger numbers of source blocks.
 	SymbolAlignmentSize int
 
-	// NumSourceSymbols = K. Must be in the range [4, 8192] (inclusive). This is
+	// NumSourceSymbols = K. Must be in the range [4, MaxRaptorCodecSourceSymbols]
+	// (inclusive), and in [4, 8192] to comply with RFC 5053. This is
 	// how many source symbols the input message will be divided into. If NumSourceSymbols
 	// doesn't evenly divide the length of the message in units of SymbolAlignmentSize,
 	// there will be null padding applied to the block.
@@ -75,8 +94,12 @@ type raptorCodec struct {
 }
 
 // NewRaptorCodec creates a new R10 raptor codec using the provided number of
-// source blocks and alignment size.
+// source blocks and alignment size. For more than 8192 source blocks, it
+// searches for the systematic index if no codec for that many has been
+// created before, which takes seconds. Such codecs are beyond RFC 5053, and
+// only interoperate with this package (see MaxRaptorCodecSourceSymbols).
 func NewRaptorCodec(sourceBlocks int, alignmentSize int) Codec {
+	raptorParamsFor(sourceBlocks).systematicIndex()
 	return &raptorCodec{
 		NumSourceSymbols:    sourceBlocks,
 		SymbolAlignmentSize: alignmentSize}
@@ -132,7 +155,9 @@ func intermediateSymbols(k int) (int, int, int) {
 	s := int(math.Ceil(0.01*float64(k))) + x
 	s = smallestPrimeGreaterOrEqual(s)
 
-	// H is the smallest integer such that choose(H, ceil(H/2)) >= K + S
+	// H is the smallest integer such that choose(H, ceil(H/2)) >= K + S.
+	// Versions of the package whose choose was wrong for H >= 15 took H = 15
+	// for K + S > 6435, that is for K above 6256 (see Compatibility above).
 	// choose(h, h/2) <= 4^(h/2), so begin with
 	// h/2 ln(4) = ln K+S
 	// h = ln(K+S)/ln(4)
@@ -144,33 +169,101 @@ func intermediateSymbols(k int) (int, int, int) {
 	return k + s + h, s, h
 }
 
+// raptorParams are the values intermediateSymbols derives from K, along with
+// L', the smallest prime at least L. Every code block needs them, so they are
+// computed once per K (see raptorParamsFor).
+type raptorParams struct {
+	k       int
+	l, s, h int
+	lprime  int
+
+	// j is the systematic index J(K), and jOK reports whether there is one.
+	// They are looked up on first use, as that may take a search (see
+	// lookupSystematicIndex); after that, reading them takes no lock.
+	jOnce sync.Once
+	j     uint16
+	jOK   bool
+}
+
+// systematicIndex returns the systematic index J(K), and reports whether
+// there is one.
+func (p *raptorParams) systematicIndex() (uint16, bool) {
+	p.jOnce.Do(func() { p.j, p.jOK = lookupSystematicIndex(p.k) })
+	return p.j, p.jOK
+}
+
+// jk returns the systematic index J(K). It panics if there is none.
+func (p *raptorParams) jk() uint16 {
+	j, ok := p.systematicIndex()
+	if !ok {
+		panic(fmt.Sprintf("fountain: no raptor systematic index for %d source symbols", p.k))
+	}
+	return j
+}
+
+// raptorParamsCache maps K to its *raptorParams.
+var raptorParamsCache sync.Map
+
+// raptorParamsFor returns the raptorParams for k source symbols.
+func raptorParamsFor(k int) *raptorParams {
+	if p, ok := raptorParamsCache.Load(k); ok {
+		return p.(*raptorParams)
+	}
+	l, s, h := intermediateSymbols(k)
+	p, _ := raptorParamsCache.LoadOrStore(k, &raptorParams{k: k, l: l, s: s, h: h, lprime: smallestPrimeGreaterOrEqual(l)})
+	return p.(*raptorParams)
+}
+
 // Triple generator from RFC section 5.4.4.4
 // k is the number of source symbols.
 // x is the (random) code symbol ID.
 // The generator creates values (d, a, b) to be used in constructing intermediate blocks.
 func tripleGenerator(k int, x uint16) (int, uint32, uint32) {
-	l, _, _ := intermediateSymbols(k)
-	lprime := smallestPrimeGreaterOrEqual(l)
-	q := uint32(65521) // largest prime < 2^16
-	jk := uint32(systematicIndextable[k])
+	p := raptorParamsFor(k)
+	return triple(p.lprime, p.jk(), x)
+}
 
-	a := uint32((53591 + (uint64(jk) * 997)) % uint64(q))
-	b := (10267 * (jk + 1)) % q
-	y := uint32((uint64(b) + (uint64(x) * uint64(a))) % uint64(q))
-	v := raptorRand(y, 0, 1048576) // 1048576 == 2^20
+// triple is the triple generator given L', the smallest prime at least the
+// number of intermediate symbols, using the systematic index jk in place of
+// J(K).
+func triple(lprime int, jk uint16, x uint16) (int, uint32, uint32) {
+	return tripleFrom(lprime, tripleSeed(jk, x), raptorRand)
+}
+
+// tripleFrom is the triple generator given L' and the seed y, calling rnd
+// for the Rand function.
+func tripleFrom(lprime int, y uint32, rnd func(x, i, m uint32) uint32) (int, uint32, uint32) {
+	v := rnd(y, 0, 1048576) // 1048576 == 2^20
 	d := deg(v)
-	a = 1 + raptorRand(y, 1, uint32(lprime-1))
-	b = raptorRand(y, 2, uint32(lprime))
+	a := 1 + rnd(y, 1, uint32(lprime-1))
+	b := rnd(y, 2, uint32(lprime))
 
 	return d, a, b
 }
 
+// tripleSeed computes the value y which seeds the Rand calls of the triple
+// generator, given the systematic index J(K).
+func tripleSeed(j uint16, x uint16) uint32 {
+	q := uint32(65521) // largest prime < 2^16
+	jk := uint32(j)
+
+	a := uint32((53591 + (uint64(jk) * 997)) % uint64(q))
+	b := (10267 * (jk + 1)) % q
+	return uint32((uint64(b) + (uint64(x) * uint64(a))) % uint64(q))
+}
+
 // findLTIndices discovers the composition of the ESI=x LT code block for a
 // raptor code. k is the number of source blocks.
 func findLTIndices(k int, x uint16) []int {
-	l, _, _ := intermediateSymbols(k)
-	lprime := uint32(smallestPrimeGreaterOrEqual(l))
-	d, a, b := tripleGenerator(k, x)
+	p := raptorParamsFor(k)
+	return ltIndices(p.l, p.lprime, p.jk(), x)
+}
+
+// ltIndices is findLTIndices for l intermediate symbols and L' = lp, using
+// the systematic index jk in place of J(K).
+func ltIndices(l, lp int, jk uint16, x uint16) []int {
+	lprime := uint32(lp)
+	d, a, b := triple(lp, jk, x)
 
 	if d > l {
 		d = l
@@ -238,19 +331,33 @@ func ltEncode(k int, x uint16, c []block) block {
 //
 // This method is destructive to the source blocks.
 func raptorIntermediateBlocks(source []block) []block {
+	intermediate, _ := raptorIntermediateBlocksContext(context.Background(), source)
+	return intermediate
+}
+
+// raptorIntermediateBlocksContext is like raptorIntermediateBlocks, but gives
+// up, returning ctx.Err(), if ctx is done first.
+func raptorIntermediateBlocksContext(ctx context.Context, source []block) ([]block, error) {
 	ltdecoder := newRaptorDecoder(&raptorCodec{SymbolAlignmentSize: 1,
 		NumSourceSymbols: len(source)}, 1)
 	for i := 0; i < len(source); i++ {
+		if i%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
 		indices := findLTIndices(len(source), uint16(i))
 		ltdecoder.matrix.addEquation(indices, source[i])
 	}
 
-	ltdecoder.matrix.reduce()
+	if err := ltdecoder.matrix.reduceContext(ctx); err != nil {
+		return nil, err
+	}
 
 	// panics if ~ltdecoder.determined. The J(K) selection should ensure that
 	// never happens.
 	intermediate := ltdecoder.matrix.v
-	return intermediate
+	return intermediate, nil
 }
 
 // GenerateIntermediateBlocks creates the pre-code representation given the
@@ -263,9 +370,41 @@ func (c *raptorCodec) GenerateIntermediateBlocks(message []byte, numBlocks int)
 	return raptorIntermediateBlocks(source)
 }
 
+// generateIntermediateBlocksContext is like GenerateIntermediateBlocks, but
+// gives up, returning ctx.Err(), if ctx is done first.
+func (c *raptorCodec) generateIntermediateBlocksContext(ctx context.Context, message []byte, numBlocks int) ([]block, error) {
+	sourceLong, sourceShort := partitionBytes(message, numBlocks)
+	source := equalizeBlockLengths(sourceLong, sourceShort)
+	return raptorIntermediateBlocksContext(ctx, source)
+}
+
+// tracePRNG records the Rand calls of PickIndices, which are those of the
+// triple generator.
+func (c *raptorCodec) tracePRNG(codeBlockIndex int64) (PRNGTrace, bool) {
+	p := raptorParamsFor(c.SourceBlocks())
+	y := tripleSeed(p.jk(), uint16(codeBlockIndex))
+	draws := 0
+	tripleFrom(p.lprime, y, func(x, i, m uint32) uint32 {
+		draws++
+		return raptorRand(x, i, m)
+	})
+	return PRNGTrace{BlockCode: codeBlockIndex, Generator: "rfc5053-rand", Seed: []uint64{uint64(y)}, Draws: draws}, true
+}
+
+// encodesInPlace reports that GenerateIntermediateBlocks solves for the
+// intermediate blocks in the message's own storage.
+func (c *raptorCodec) encodesInPlace() bool {
+	return true
+}
+
 // PickIndices chooses a set of indices for the provided CodeBlock index value
-// which are used to compose an LTBlock. It functions by
+// which are used to compose an LTBlock. It functions by running the triple
+// generator for the ESI. It panics if the ID isn't a valid ESI (see
+// validESI).
 func (c *raptorCodec) PickIndices(codeBlockIndex int64) []int {
+	if !validESI(codeBlockIndex) {
+		panic(fmt.Sprintf("fountain: raptor ESI %d is outside [0, %d]", codeBlockIndex, MaxRaptorESI))
+	}
 	return findLTIndices(int(c.SourceBlocks()), uint16(codeBlockIndex))
 }
 
@@ -274,6 +413,12 @@ func (c *raptorCodec) NewDecoder(messageLength int) Decoder {
 	return newRaptorDecoder(c, messageLength)
 }
 
+// NewEncoder creates a new raptor encoder. It computes the intermediate blocks
+// of the message once, so generating symbols doesn't repeat the precode solve.
+func (c *raptorCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
 // raptorDecoder is the state required for decoding a particular message prepared
 // with the Raptor code. It must be initialized with the same raptorCodec parameters
 // used for encoding, as well as the expected message length.
@@ -290,42 +435,12 @@ type raptorDecoder struct {
 func newRaptorDecoder(c *raptorCodec, length int) *raptorDecoder {
 	d := &raptorDecoder{codec: *c, messageLength: length}
 
-	l, s, h := intermediateSymbols(c.NumSourceSymbols)
+	l := raptorParamsFor(c.NumSourceSymbols).l
 
 	// Add the S + H intermediate symbol composition equations.
 	d.matrix.coeff = make([][]int, l)
 	d.matrix.v = make([]block, l)
-
-	k := c.NumSourceSymbols
-	compositions := make([][]int, s)
-
-	for i := 0; i < k; i++ {
-		a := 1 + (int(math.Floor(float64(i)/float64(s))) % (s - 1))
-		b := i % s
-		compositions[b] = append(compositions[b], i)
-		b = (b + a) % s
-		compositions[b] = append(compositions[b], i)
-		b = (b + a) % s
-		compositions[b] = append(compositions[b], i)
-	}
-	for i := 0; i < s; i++ {
-		compositions[i] = append(compositions[i], k+i)
-		d.matrix.addEquation(compositions[i], block{})
-	}
-
-	compositions = make([][]int, h)
-
-	hprime := int(math.Ceil(float64(h) / 2))
-	m := buildGraySequence(k+s, hprime)
-	for i := 0; i < h; i++ {
-		for j := 0; j < k+s; j++ {
-			if bitSet(uint(m[j]), uint(i)) {
-				compositions[i] = append(compositions[i], j)
-			}
-		}
-		compositions[i] = append(compositions[i], k+s+i)
-		d.matrix.addEquation(compositions[i], block{})
-	}
+	addPrecodeConstraints(&d.matrix, c.NumSourceSymbols, R10Precode.Compositions(c.NumSourceSymbols))
 
 	return d
 }
@@ -333,18 +448,53 @@ func newRaptorDecoder(c *raptorCodec, length int) *raptorDecoder {
 // AddBlocks adds a set of encoded blocks to the decoder. Returns true if the
 // message can be fully decoded. False if there is insufficient information.
 func (d *raptorDecoder) AddBlocks(blocks []LTBlock) bool {
+	blocks = d.dropInvalidESIs(blocks)
+	if d.matrix.lateBlocks(blocks) {
+		return true
+	}
 	for i := range blocks {
 		indices := findLTIndices(d.codec.NumSourceSymbols, uint16(blocks[i].BlockCode))
-		d.matrix.addEquation(indices, block{data: blocks[i].Data})
+		d.matrix.addCodeBlock(blocks[i].BlockCode, indices, block{data: blocks[i].Data})
+	}
+	return d.matrix.setComplete(d.matrix.determined())
+}
+
+// dropInvalidESIs returns the blocks whose IDs are ESIs, counting the others
+// as rejected. Truncating an ID to 16 bits would alias it to another ESI, so
+// IDs which aren't ESIs are dropped, before even late blocks are checked
+// against the decoded message.
+func (d *raptorDecoder) dropInvalidESIs(blocks []LTBlock) []LTBlock {
+	var valid []LTBlock
+	for i := range blocks {
+		if validESI(blocks[i].BlockCode) {
+			if valid != nil {
+				valid = append(valid, blocks[i])
+			}
+			continue
+		}
+		d.matrix.rejectCodeBlock()
+		if valid == nil {
+			valid = append(make([]LTBlock, 0, len(blocks)), blocks[:i]...)
+		}
+	}
+	if valid == nil {
+		return blocks
 	}
-	return d.matrix.determined()
+	return valid
 }
 
 // Decode extracts the decoded message from the decoder. If the decoder does
 // not have sufficient information to produce an output, returns a nil slice.
 func (d *raptorDecoder) Decode() []byte {
+	out, _ := d.decode(false)
+	return out
+}
+
+// decode reduces the decode matrix and reconstructs the message. See
+// reconstructBlocks for the meaning of strict.
+func (d *raptorDecoder) decode(strict bool) ([]byte, error) {
 	if !d.matrix.determined() {
-		return nil
+		return nil, errNotDetermined
 	}
 
 	d.matrix.reduce()
@@ -352,19 +502,40 @@ func (d *raptorDecoder) Decode() []byte {
 	// Now the intermediate blocks are held in d.matrix.v. Use the encoder function
 	// to recover the source blocks.
 	intermediate := d.matrix.v
+	xorBefore := d.matrix.cost.XORBytes
 	source := make([]block, d.codec.NumSourceSymbols)
 	for i := 0; i < d.codec.NumSourceSymbols; i++ {
-		source[i] = ltEncode(d.codec.NumSourceSymbols, uint16(i), intermediate)
+		indices := findLTIndices(d.codec.NumSourceSymbols, uint16(i))
+		source[i] = generateLubyTransformBlock(intermediate, indices)
+		for _, j := range indices {
+			d.matrix.cost.RowOps++
+			d.matrix.cost.XORBytes += int64(len(intermediate[j].data))
+		}
 	}
+	d.matrix.reportXOR(xorBefore)
 
 	lenLong, lenShort, numLong, numShort := partition(d.messageLength, d.codec.NumSourceSymbols)
-	out := make([]byte, d.messageLength)
-	out = out[0:0]
-	for i := 0; i < numLong; i++ {
-		out = append(out, source[i].data[0:lenLong]...)
-	}
-	for i := numLong; i < numLong+numShort; i++ {
-		out = append(out, source[i].data[0:lenShort]...)
+	return reconstructBlocks(source, d.messageLength, lenLong, lenShort, numLong, numShort, strict)
+}
+
+// recoverSource reduces the solvable rows of the decode matrix and returns
+// the source blocks, along with which of them have been recovered. A source
+// block is recovered once all the intermediate blocks it is composed of are.
+func (d *raptorDecoder) recoverSource() ([]block, []bool) {
+	ok := d.matrix.solvable()
+	d.matrix.reduce()
+	k := d.codec.NumSourceSymbols
+	source := make([]block, k)
+	recovered := make([]bool, k)
+	for i := 0; i < k; i++ {
+		indices := findLTIndices(k, uint16(i))
+		recovered[i] = true
+		for _, j := range indices {
+			recovered[i] = recovered[i] && ok[j]
+		}
+		if recovered[i] {
+			source[i] = generateLubyTransformBlock(d.matrix.v, indices)
+		}
 	}
-	return out
+	return source, recovered
 }
@@ -78,6 +78,9 @@ func TestIntermediateSymbols(t *testing.T) {
 		{14, 28, 7, 7},
 		{500, 553, 41, 12},
 		{5000, 5166, 151, 15},
+		// choose(15, 8) = 6435, so H grows to 16 with K+S past that.
+		{6256, 6450, 179, 15},
+		{6257, 6452, 179, 16},
 	}
 
 	for _, test := range intermediateTests {
@@ -89,6 +92,23 @@ func TestIntermediateSymbols(t *testing.T) {
 	}
 }
 
+func TestRaptorParamsFor(t *testing.T) {
+	for _, k := range []int{4, 10, 13, 1000, 8192, 8193} {
+		l, s, h := intermediateSymbols(k)
+		p := raptorParamsFor(k)
+		if p.k != k || p.l != l || p.s != s || p.h != h || p.lprime != smallestPrimeGreaterOrEqual(l) {
+			t.Errorf("raptorParamsFor(%d) = %d, %d, %d, %d, %d; want %d, %d, %d, %d, %d", k,
+				p.k, p.l, p.s, p.h, p.lprime, k, l, s, h, smallestPrimeGreaterOrEqual(l))
+		}
+		if j, ok := p.systematicIndex(); !ok || k <= MaxRaptorSourceSymbols && j != systematicIndextable[k] {
+			t.Errorf("raptorParamsFor(%d).systematicIndex() = %d, %v", k, j, ok)
+		}
+		if raptorParamsFor(k) != p {
+			t.Errorf("raptorParamsFor(%d) wasn't cached", k)
+		}
+	}
+}
+
 func TestTripleGenerator(t *testing.T) {
 	var tripleTests = []struct {
 		k int
@@ -321,3 +341,84 @@ func TestRaptorCodec(t *testing.T) {
 		}
 	}
 }
+
+// TestRaptorCodecUnevenMessage checks messages whose length isn't a multiple of
+// the number of source symbols, where the short source blocks get padded.
+func TestRaptorCodecUnevenMessage(t *testing.T) {
+	c := NewRaptorCodec(13, 2)
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789")
+	messageCopy := make([]byte, len(message))
+	copy(messageCopy, message)
+
+	ids := make([]int64, 30)
+	for i := range ids {
+		ids[i] = int64(100 + i)
+	}
+	codeBlocks := EncodeLTBlocks(messageCopy, ids, c)
+
+	decoder := c.NewDecoder(len(message))
+	for i := range codeBlocks {
+		if decoder.AddBlocks(codeBlocks[i : i+1]) {
+			break
+		}
+	}
+	out := decoder.Decode()
+	if !reflect.DeepEqual(message, out) {
+		t.Errorf("Decoding result must equal %s, got %s", string(message), string(out))
+	}
+}
+
+// BenchmarkRaptorRepair generates one repair symbol at a time for a 1MB
+// message of 1024 source symbols, re-encoding the message for each with
+// EncodeLTBlocksCopy, or with an Encoder retaining the intermediate blocks.
+func BenchmarkRaptorRepair(b *testing.B) {
+	message := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(message)
+	c := NewRaptorCodec(1024, 4)
+	b.Run("EncodeLTBlocksCopy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			EncodeLTBlocksCopy(message, []int64{int64(1024 + i%1000)}, c)
+		}
+	})
+	b.Run("Encoder", func(b *testing.B) {
+		e := c.NewEncoder(message)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			e.Generate(int64(1024 + i%1000))
+		}
+	})
+}
+
+// TestRaptorESIRange checks that IDs outside the ESI range aren't truncated to
+// alias valid ESIs.
+func TestRaptorESIRange(t *testing.T) {
+	c := NewRaptorCodec(13, 2)
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	e := c.NewEncoder(message)
+	d := c.NewDecoder(len(message))
+	valid := e.Generate(5)
+	d.AddBlocks([]LTBlock{
+		{BlockCode: MaxRaptorESI + 1 + 5, Data: append([]byte(nil), valid.Data...)},
+		{BlockCode: -1, Data: append([]byte(nil), valid.Data...)},
+		valid,
+	})
+	diag, _ := Diagnose(d)
+	if diag.Received != 3 || diag.Rejected != 2 || diag.Duplicates != 0 {
+		t.Errorf("Got %d received, %d rejected, %d duplicates, want 3, 2, 0",
+			diag.Received, diag.Rejected, diag.Duplicates)
+	}
+
+	for _, id := range []int64{-1, MaxRaptorESI + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("PickIndices(%d) didn't panic", id)
+				}
+			}()
+			c.PickIndices(id)
+		}()
+	}
+	if len(c.PickIndices(MaxRaptorESI)) == 0 {
+		t.Errorf("PickIndices(%d) picked nothing", MaxRaptorESI)
+	}
+}
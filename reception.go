@@ -0,0 +1,190 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"sort"
+)
+
+// When code blocks are emitted with sequential IDs (ESIs), a receiver can
+// tell which were lost from the gaps in what it received. That breaks down if
+// the sender skips ranges deliberately, for instance while a carousel is
+// paused: the skipped ESIs look like a burst of loss. The ReceptionTracker
+// records both the ESIs received and the ranges the sender declares it never
+// sent, so that loss is only counted against ESIs which were really sent.
+
+// ESIRange is an inclusive range of code block IDs.
+type ESIRange struct {
+	First, Last int64
+}
+
+// Len returns the number of IDs in the range.
+func (r ESIRange) Len() int64 {
+	if r.Last < r.First {
+		return 0
+	}
+	return r.Last - r.First + 1
+}
+
+// ReceptionTracker keeps account of the code block IDs a receiver has seen.
+// It is not safe for concurrent use.
+type ReceptionTracker struct {
+	received map[int64]bool
+
+	// low and high are the lowest and highest IDs received.
+	low, high int64
+
+	// notSent holds the ranges declared never sent, sorted and disjoint.
+	notSent []ESIRange
+}
+
+// NewReceptionTracker creates an empty ReceptionTracker.
+func NewReceptionTracker() *ReceptionTracker {
+	return &ReceptionTracker{received: make(map[int64]bool)}
+}
+
+// Receive records the arrival of a code block ID. Returns false if the ID was
+// already received.
+func (t *ReceptionTracker) Receive(id int64) bool {
+	if t.received[id] {
+		return false
+	}
+	if len(t.received) == 0 || id < t.low {
+		t.low = id
+	}
+	if len(t.received) == 0 || id > t.high {
+		t.high = id
+	}
+	t.received[id] = true
+	return true
+}
+
+// ReceiveBlocks records the IDs of a set of code blocks.
+func (t *ReceptionTracker) ReceiveBlocks(blocks []LTBlock) {
+	for i := range blocks {
+		t.Receive(blocks[i].BlockCode)
+	}
+}
+
+// NotSent records the sender's declaration that the IDs from first to last
+// (inclusive) were never transmitted.
+func (t *ReceptionTracker) NotSent(first, last int64) {
+	if last < first {
+		return
+	}
+	ranges := append(t.notSent, ESIRange{first, last})
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].First < ranges[j].First })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		end := &merged[len(merged)-1]
+		if r.First <= end.Last+1 {
+			if r.Last > end.Last {
+				end.Last = r.Last
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	t.notSent = merged
+}
+
+// Span returns the range from the lowest to the highest ID received. Only IDs
+// within the span are counted as sent or lost.
+func (t *ReceptionTracker) Span() ESIRange {
+	if len(t.received) == 0 {
+		return ESIRange{0, -1}
+	}
+	return ESIRange{t.low, t.high}
+}
+
+// Received returns the number of distinct IDs received.
+func (t *ReceptionTracker) Received() int64 {
+	return int64(len(t.received))
+}
+
+// Sent returns the number of IDs within the span which were sent: that is,
+// not declared as never sent.
+func (t *ReceptionTracker) Sent() int64 {
+	span := t.Span()
+	sent := span.Len()
+	for _, r := range t.notSent {
+		sent -= intersect(r, span).Len()
+	}
+	return sent
+}
+
+// Lost returns the number of IDs within the span which were sent but not
+// received. IDs received although they were declared never sent, as when a
+// declaration and blocks arrive out of order, don't offset the loss of
+// others.
+func (t *ReceptionTracker) Lost() int64 {
+	lost := t.Sent()
+	for id := range t.received {
+		if !t.declaredNotSent(id) {
+			lost--
+		}
+	}
+	return lost
+}
+
+// declaredNotSent reports whether id is in a range declared never sent.
+func (t *ReceptionTracker) declaredNotSent(id int64) bool {
+	i := sort.Search(len(t.notSent), func(i int) bool { return t.notSent[i].Last >= id })
+	return i < len(t.notSent) && t.notSent[i].First <= id
+}
+
+// LossRate returns the fraction of the IDs sent within the span which were
+// lost, or 0 if nothing has been received.
+func (t *ReceptionTracker) LossRate() float64 {
+	sent := t.Sent()
+	if sent == 0 {
+		return 0
+	}
+	return float64(t.Lost()) / float64(sent)
+}
+
+// Missing returns the ranges of IDs within r which were sent but not received.
+func (t *ReceptionTracker) Missing(r ESIRange) []ESIRange {
+	var missing []ESIRange
+	next := 0
+	var gap *ESIRange
+	for id := r.First; id <= r.Last; id++ {
+		for next < len(t.notSent) && t.notSent[next].Last < id {
+			next++
+		}
+		if t.received[id] || (next < len(t.notSent) && t.notSent[next].First <= id) {
+			gap = nil
+			continue
+		}
+		if gap == nil {
+			missing = append(missing, ESIRange{id, id})
+			gap = &missing[len(missing)-1]
+		} else {
+			gap.Last = id
+		}
+	}
+	return missing
+}
+
+// intersect returns the intersection of two ranges, which may be empty.
+func intersect(a, b ESIRange) ESIRange {
+	if b.First > a.First {
+		a.First = b.First
+	}
+	if b.Last < a.Last {
+		a.Last = b.Last
+	}
+	return a
+}
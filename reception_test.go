@@ -0,0 +1,68 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReceptionTracker(t *testing.T) {
+	tr := NewReceptionTracker()
+	if tr.LossRate() != 0 || tr.Sent() != 0 {
+		t.Errorf("Empty tracker has loss rate %f, %d sent", tr.LossRate(), tr.Sent())
+	}
+
+	// 0..19 received except 5, 6 (lost) and 10..14 (never sent).
+	for i := int64(0); i < 20; i++ {
+		if i == 5 || i == 6 || (i >= 10 && i <= 14) {
+			continue
+		}
+		tr.Receive(i)
+	}
+	if tr.Receive(3) {
+		t.Errorf("Receive of a duplicate ID returned true")
+	}
+	if tr.Lost() != 7 {
+		t.Errorf("Before declaring the pause, Lost() = %d, want 7", tr.Lost())
+	}
+
+	tr.NotSent(12, 14)
+	tr.NotSent(10, 11)
+	tr.NotSent(30, 40)
+	if !reflect.DeepEqual(tr.notSent, []ESIRange{{10, 14}, {30, 40}}) {
+		t.Errorf("Not-sent ranges are %v, want [{10 14} {30 40}]", tr.notSent)
+	}
+	if tr.Sent() != 15 || tr.Received() != 13 || tr.Lost() != 2 {
+		t.Errorf("Got %d sent, %d received, %d lost; want 15, 13, 2",
+			tr.Sent(), tr.Received(), tr.Lost())
+	}
+	if rate := tr.LossRate(); rate < 0.133 || rate > 0.134 {
+		t.Errorf("LossRate() = %f, want 2/15", rate)
+	}
+
+	missing := tr.Missing(ESIRange{0, 25})
+	want := []ESIRange{{5, 6}, {20, 25}}
+	if !reflect.DeepEqual(missing, want) {
+		t.Errorf("Missing(0, 25) = %v, want %v", missing, want)
+	}
+
+	// A declaration overtaken by blocks it covers doesn't hide the losses of
+	// 5 and 6.
+	tr.NotSent(15, 19)
+	if tr.Sent() != 10 || tr.Lost() != 2 {
+		t.Errorf("Got %d sent, %d lost; want 10, 2", tr.Sent(), tr.Lost())
+	}
+}
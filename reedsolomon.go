@@ -0,0 +1,290 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"slices"
+
+	"github.com/google/gofountain/internal/gf256"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Implementation of a systematic Reed-Solomon erasure code.
+// For a handful of source blocks, a fountain code needs proportionally many
+// code blocks beyond K to decode. A Reed-Solomon code is MDS: any K of its
+// shares decode the message. It isn't rateless, though: it has a fixed number
+// of shares, N of them, the first K of which are the source blocks, and the
+// rest parity shares. Here the parity shares are the rows of a Cauchy matrix
+// over GF(2^8) applied to the source blocks, so every K by K submatrix of the
+// generator matrix is invertible. See "An XOR-Based Erasure-Resilient Coding
+// Scheme" -- J. Blomer et al. (1995)
+
+// MaxReedSolomonShares is the largest number of shares of the Reed-Solomon
+// codec, the number of elements of GF(2^8).
+const MaxReedSolomonShares = 256
+
+// reedSolomonCodec contains the codec information for the Reed-Solomon
+// encoder and decoder.
+// Implements fountain.Codec.
+type reedSolomonCodec struct {
+	// sourceBlocks is the number of source blocks (K), and shares the number
+	// of shares (N) including them.
+	sourceBlocks, shares int
+}
+
+// NewReedSolomonCodec creates a codec for the systematic Reed-Solomon code
+// RS(shares, sourceBlocks), so that it can be used in place of a fountain
+// codec. Code block ID x is share x mod shares: shares 0 to sourceBlocks-1
+// are the source blocks themselves, and the rest are parity shares. Any
+// sourceBlocks distinct shares decode the message, but there are only shares
+// of them, so a transport mustn't count on sending more. shares must be in
+// [sourceBlocks, MaxReedSolomonShares]; see NewReedSolomonCodecChecked.
+func NewReedSolomonCodec(sourceBlocks, shares int) Codec {
+	return &reedSolomonCodec{sourceBlocks: sourceBlocks, shares: shares}
+}
+
+// SourceBlocks returns the number of source blocks used in the codec.
+func (c *reedSolomonCodec) SourceBlocks() int {
+	return c.sourceBlocks
+}
+
+// share returns the index of the share a code block ID stands for.
+func (c *reedSolomonCodec) share(codeBlockIndex int64) int {
+	share := int(codeBlockIndex % int64(c.shares))
+	if share < 0 {
+		share += c.shares
+	}
+	return share
+}
+
+// PickIndices returns the source blocks the share for the code block ID
+// depends on: itself for a source share, all of them for a parity share.
+// Parity shares aren't the XOR of those blocks but a GF(2^8) combination.
+func (c *reedSolomonCodec) PickIndices(codeBlockIndex int64) []int {
+	share := c.share(codeBlockIndex)
+	if share < c.sourceBlocks {
+		return []int{share}
+	}
+	indices := make([]int, c.sourceBlocks)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// coefficient returns the element of the generator matrix for a share and a
+// source block. The rows of the parity shares are the Cauchy matrix
+// 1/(x_share + y_i), with x_share = share and y_i = i, which are distinct.
+func (c *reedSolomonCodec) coefficient(share, i int) byte {
+	if share < c.sourceBlocks {
+		if share == i {
+			return 1
+		}
+		return 0
+	}
+	return gf256.Inverse(byte(share) ^ byte(i))
+}
+
+// GenerateIntermediateBlocks simply returns the partition of the input message
+// into source blocks.
+func (c *reedSolomonCodec) GenerateIntermediateBlocks(message []byte, numBlocks int) []block {
+	long, short := partitionBytes(message, c.sourceBlocks)
+	return equalizeBlockLengths(long, short)
+}
+
+// generateSymbol returns the share for the code block ID.
+func (c *reedSolomonCodec) generateSymbol(source []block, codeBlockIndex int64) block {
+	share := c.share(codeBlockIndex)
+	if share < c.sourceBlocks {
+		return source[share]
+	}
+	return c.combine(source, share)
+}
+
+// combine returns the combination of the source blocks with the coefficients
+// of the generator matrix row of a share. Source blocks which are all padding
+// (see compactZeroBlocks) add nothing but their length.
+func (c *reedSolomonCodec) combine(source []block, share int) block {
+	var b block
+	for i := range source {
+		b.mulAdd(source[i], c.coefficient(share, i))
+	}
+	return b
+}
+
+// NewEncoder creates a Reed-Solomon encoder.
+func (c *reedSolomonCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
+// NewDecoder creates a Reed-Solomon decoder.
+func (c *reedSolomonCodec) NewDecoder(messageLength int) Decoder {
+	return &reedSolomonDecoder{codec: c, messageLength: messageLength, shares: make(map[int]block)}
+}
+
+// reedSolomonDecoder is the state required to decode a Reed-Solomon message.
+type reedSolomonDecoder struct {
+	codec         *reedSolomonCodec
+	messageLength int
+
+	// shares holds the first K distinct shares received, by index, and source
+	// the source blocks, once they have been solved for.
+	shares map[int]block
+	source []block
+}
+
+// AddBlocks adds a set of encoded blocks to the decoder. Returns true if the
+// message can be fully decoded. False if there is insufficient information.
+func (d *reedSolomonDecoder) AddBlocks(blocks []LTBlock) bool {
+	for i := range blocks {
+		if d.determined() {
+			break
+		}
+		share := d.codec.share(blocks[i].BlockCode)
+		if _, ok := d.shares[share]; !ok {
+			d.shares[share] = block{data: blocks[i].Data}
+		}
+	}
+	return d.determined()
+}
+
+// determined reports whether K shares have been received.
+func (d *reedSolomonDecoder) determined() bool {
+	return len(d.shares) >= d.codec.sourceBlocks
+}
+
+// preloadSource adds source block i as its own share.
+func (d *reedSolomonDecoder) preloadSource(i int, data []byte) {
+	d.AddBlocks([]LTBlock{{BlockCode: int64(i), Data: data}})
+}
+
+// Decode extracts the decoded message from the decoder. If the decoder does
+// not have sufficient information to produce an output, returns a nil slice.
+func (d *reedSolomonDecoder) Decode() []byte {
+	out, _ := d.decode(false)
+	return out
+}
+
+// decode solves for the source blocks and reconstructs the message. See
+// reconstructBlocks for the meaning of strict.
+func (d *reedSolomonDecoder) decode(strict bool) ([]byte, error) {
+	if !d.determined() {
+		return nil, errNotDetermined
+	}
+	d.solve()
+	lenLong, lenShort, numLong, numShort := partition(d.messageLength, d.codec.sourceBlocks)
+	return reconstructBlocks(d.source, d.messageLength, lenLong, lenShort, numLong, numShort, strict)
+}
+
+// recoverSource returns the source blocks, along with which of them have been
+// recovered: all of them once the decoder is determined, and otherwise the
+// source shares received.
+func (d *reedSolomonDecoder) recoverSource() ([]block, []bool) {
+	k := d.codec.sourceBlocks
+	recovered := make([]bool, k)
+	if d.determined() {
+		d.solve()
+		for i := range recovered {
+			recovered[i] = true
+		}
+		return d.source, recovered
+	}
+	source := make([]block, k)
+	for i := range source {
+		source[i], recovered[i] = d.shares[i]
+	}
+	return source, recovered
+}
+
+// solve computes the source blocks from the shares received. The source
+// shares are taken as they are; each missing source block is then a share
+// short, and as many parity shares make up for them. With the known source
+// blocks' terms subtracted, the parity shares are a square Cauchy submatrix
+// times the missing source blocks, and inverting it gives those.
+func (d *reedSolomonDecoder) solve() {
+	if d.source != nil {
+		return
+	}
+	c := d.codec
+	source := make([]block, c.sourceBlocks)
+	var missing, parity []int
+	for i := range source {
+		if b, ok := d.shares[i]; ok {
+			source[i] = b
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	for share := range d.shares {
+		if share >= c.sourceBlocks {
+			parity = append(parity, share)
+		}
+	}
+	slices.Sort(parity)
+	parity = parity[:len(missing)]
+
+	// rhs[j] is parity share j minus the terms of the known source blocks.
+	rhs := make([]block, len(parity))
+	for j, share := range parity {
+		rhs[j].mulAdd(d.shares[share], 1)
+		for i := range source {
+			if _, ok := d.shares[i]; ok {
+				rhs[j].mulAdd(source[i], c.coefficient(share, i))
+			}
+		}
+	}
+
+	// Gauss-Jordan elimination on the submatrix, applied to rhs alongside.
+	m := make([][]byte, len(parity))
+	for j, share := range parity {
+		m[j] = make([]byte, len(missing))
+		for col, i := range missing {
+			m[j][col] = c.coefficient(share, i)
+		}
+	}
+	for col := range missing {
+		pivot := col
+		for m[pivot][col] == 0 {
+			pivot++
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		rhs[col], rhs[pivot] = rhs[pivot], rhs[col]
+		if inv := gf256.Inverse(m[col][col]); inv != 1 {
+			gf256.Scale(m[col], inv)
+			gf256.Scale(rhs[col].data, inv)
+		}
+		for j := range m {
+			if f := m[j][col]; j != col && f != 0 {
+				gf256.MulAdd(m[j], m[col], f)
+				rhs[j].mulAdd(rhs[col], f)
+			}
+		}
+	}
+	for col, i := range missing {
+		source[i] = rhs[col]
+	}
+	d.source = source
+}
+
+// mulAdd adds f times the block a to the block. The result is as long as
+// the longer of the two.
+func (b *block) mulAdd(a block, f byte) {
+	length := max(b.length(), a.length())
+	if len(a.data) > len(b.data) {
+		b.data = append(b.data, make([]byte, len(a.data)-len(b.data))...)
+	}
+	gf256.MulAdd(b.data, a.data, f)
+	b.padding = length - len(b.data)
+}
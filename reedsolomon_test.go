@@ -0,0 +1,174 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReedSolomonAnyKShares(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXY")
+	const k, n = 4, 8
+	c := NewReedSolomonCodec(k, n)
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	shares := EncodeLTBlocksCopy(message, ids, c)
+
+	// Every choice of k of the n shares decodes the message.
+	for set := 0; set < 1<<n; set++ {
+		var chosen []LTBlock
+		for i := 0; i < n; i++ {
+			if set&(1<<i) != 0 {
+				chosen = append(chosen, shares[i])
+			}
+		}
+		if len(chosen) != k {
+			continue
+		}
+		d := c.NewDecoder(len(message))
+		if d.AddBlocks(chosen[:k-1]) {
+			t.Fatalf("shares %b: determined after %d shares", set, k-1)
+		}
+		if !d.AddBlocks(chosen[k-1:]) {
+			t.Fatalf("shares %b: not determined after %d shares", set, k)
+		}
+		if decoded, err := DecodeStrict(d); err != nil || !bytes.Equal(decoded, message) {
+			t.Errorf("shares %b: DecodeStrict = %q, %v; want %q", set, decoded, err, message)
+		}
+	}
+}
+
+func TestReedSolomonCodeBlockIDs(t *testing.T) {
+	message := make([]byte, 1000)
+	for i := range message {
+		message[i] = byte(i * 13)
+	}
+	c := NewReedSolomonCodec(10, 14)
+	e := c.NewEncoder(message)
+	// IDs are taken modulo the number of shares, and the source shares are
+	// the source blocks.
+	for id := int64(0); id < 14; id++ {
+		if a, b := e.Generate(id), e.Generate(id+14); !bytes.Equal(a.Data, b.Data) {
+			t.Errorf("share %d differs from share %d", id, id+14)
+		}
+	}
+	if got := e.Generate(3).Data; !bytes.Equal(got, message[300:400]) {
+		t.Errorf("share 3 isn't source block 3")
+	}
+
+	// Duplicate shares don't count.
+	d := c.NewDecoder(len(message))
+	var blocks []LTBlock
+	for id := int64(0); id < 9; id++ {
+		blocks = append(blocks, e.Generate(id), e.Generate(id+14))
+	}
+	if d.AddBlocks(blocks) {
+		t.Fatal("determined by 9 distinct shares")
+	}
+	if !d.AddBlocks([]LTBlock{e.Generate(13)}) {
+		t.Fatal("not determined by 10 distinct shares")
+	}
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("decoded message differs")
+	}
+
+	// GenerateBatch and the encoder pool agree with Generate.
+	ids := []int64{12, 0, 11, 10}
+	batch, err := GenerateBatch(e, ids, SourceOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range batch {
+		if want := e.Generate(ids[i]); !bytes.Equal(b.Data, want.Data) {
+			t.Errorf("GenerateBatch share %d differs from Generate", ids[i])
+		}
+	}
+}
+
+func TestReedSolomonPartialAndWarmStart(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewReedSolomonCodec(10, 13)
+	e := c.NewEncoder(message)
+
+	// Source shares are recovered as they arrive.
+	d := c.NewDecoder(len(message))
+	d.AddBlocks([]LTBlock{e.Generate(2), e.Generate(11)})
+	data, recovered := DecodePartial(d)
+	for i, ok := range recovered {
+		if ok != (i == 2) {
+			t.Errorf("source block %d recovered = %v", i, ok)
+		}
+	}
+	if got := data[14:20]; !bytes.Equal(got, message[14:20]) {
+		t.Errorf("DecodePartial block 2 = %q, want %q", got, message[14:20])
+	}
+
+	// A stale local copy loads 7 blocks, so 3 parity shares finish the job.
+	local := append([]byte(nil), message[:60]...)
+	local[15] = '!'
+	local[50] = '!'
+	d = c.NewDecoder(len(message))
+	loaded, err := WarmStart(d, local, len(message), SymbolDigests(message, 10))
+	if err != nil || loaded != 7 {
+		t.Fatalf("WarmStart = %d, %v; want 7 blocks", loaded, err)
+	}
+	if !d.AddBlocks([]LTBlock{e.Generate(10), e.Generate(11), e.Generate(12)}) {
+		t.Fatal("not determined by 7 source blocks and 3 parity shares")
+	}
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("decoded %q, want %q", decoded, message)
+	}
+}
+
+func TestReedSolomonWrappers(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXY")
+	const k, n = 4, 8
+	key, _ := DeriveTransferKey([]byte("secret"), 1, 16)
+	mac, _ := NewSymbolMAC(key, 1, 16)
+	popular, _ := PopularityCodec(NewReedSolomonCodec(k, n))
+	for name, c := range map[string]Codec{
+		"popularity":            popular,
+		"authenticated":         NewAuthenticatedCodec(NewReedSolomonCodec(k, n), mac),
+		"authenticated popular": NewAuthenticatedCodec(popular, mac),
+	} {
+		// Only parity shares, which the XOR of the source blocks would get
+		// wrong.
+		ids := []int64{4, 5, 6, 7}
+		batch, err := GenerateBatch(c.NewEncoder(message), ids, LocalityOrder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, blocks := range [][]LTBlock{EncodeLTBlocksCopy(message, ids, c), batch} {
+			d := c.NewDecoder(len(message))
+			if !d.AddBlocks(blocks) {
+				t.Fatalf("%s: decoder not determined by %d parity shares", name, k)
+			}
+			if got := d.Decode(); !bytes.Equal(got, message) {
+				t.Errorf("%s: decoded %q, want %q", name, got, message)
+			}
+		}
+	}
+
+	// The wrappers which depend on XOR code blocks refuse the codec.
+	if _, err := NewROICodec(NewReedSolomonCodec(k, n), len(message), []ByteRange{{0, 4}}); err == nil {
+		t.Error("NewROICodec accepted a Reed-Solomon codec")
+	}
+	if _, err := AuditedCodec(NewReedSolomonCodec(k, n), func(PRNGTrace) {}); err == nil {
+		t.Error("AuditedCodec accepted a Reed-Solomon codec")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"math"
+)
+
+// When a receiver of a systematic code reports that it is still short of
+// some source symbols, the sender can either resend exactly the missing ones
+// or send fresh repair symbols. A resent symbol is only useful if it is one
+// the receiver lacks, so every one of them has to get through, which may take
+// further feedback round trips on a lossy channel. Repair symbols are all
+// interchangeable, so they can simply be sent until the receiver is done, but
+// the receiver usually needs a few more of them than its rank deficit.
+// ChooseResend compares the expected remaining bytes of both.
+
+// ResendParams describes a receiver's state, as reported in its feedback, and
+// the channel.
+type ResendParams struct {
+	// SymbolSize is the size in bytes of each symbol sent.
+	SymbolSize int
+
+	// SourceSymbols is K, and Rank is the rank of the receiver's decode
+	// matrix over the source symbols. Their difference is the deficit.
+	SourceSymbols, Rank int
+
+	// Missing is the number of source symbols the receiver reported missing.
+	// It can exceed the deficit when repair symbols have been received.
+	Missing int
+
+	// LossRate is the estimated probability that a symbol is lost.
+	LossRate float64
+
+	// RepairOverhead is the expected number of repair symbols beyond the
+	// deficit the decoder needs, as a fraction of the deficit.
+	RepairOverhead float64
+
+	// FeedbackBytes is the cost, in bytes, charged for each additional round of
+	// feedback and retransmission, to account for its latency.
+	FeedbackBytes int
+}
+
+// ResendDecision is the result of ChooseResend.
+type ResendDecision struct {
+	// Resend is true if the missing source symbols should be resent, and false
+	// if repair symbols should be sent instead.
+	Resend bool
+
+	// ResendBytes and RepairBytes are the expected remaining bytes of each
+	// approach.
+	ResendBytes, RepairBytes float64
+
+	// Reason explains the decision, for logging.
+	Reason string
+}
+
+// expectedRounds returns the expected number of rounds of selective
+// retransmission needed for all of n symbols to get through when each is lost
+// with probability p.
+func expectedRounds(n int, p float64) float64 {
+	if n == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return 1
+	}
+	// E[R] = sum over k >= 0 of P(R > k) = sum of 1 - (1-p^k)^n.
+	rounds := 0.0
+	for k := 0; k < 1000; k++ {
+		term := 1 - math.Pow(1-math.Pow(p, float64(k)), float64(n))
+		rounds += term
+		if term < 1e-9 {
+			break
+		}
+	}
+	return rounds
+}
+
+// ChooseResend decides whether resending the receiver's missing source
+// symbols or sending repair symbols is expected to finish the transfer with
+// fewer bytes.
+// Resending assumes the receiver needs every missing symbol; repair symbols
+// are sent continuously until the receiver reports that it is done.
+func ChooseResend(p ResendParams) ResendDecision {
+	deficit := p.SourceSymbols - p.Rank
+	if deficit <= 0 {
+		return ResendDecision{Reason: "receiver can already decode"}
+	}
+	if p.LossRate >= 1 {
+		return ResendDecision{
+			ResendBytes: math.Inf(1),
+			RepairBytes: math.Inf(1),
+			Reason:      "channel loses every symbol",
+		}
+	}
+	delivered := 1 - p.LossRate
+	size := float64(p.SymbolSize)
+
+	resendRounds := expectedRounds(p.Missing, p.LossRate)
+	resend := float64(p.Missing)*size/delivered + (resendRounds-1)*float64(p.FeedbackBytes)
+	repair := float64(deficit) * (1 + p.RepairOverhead) * size / delivered
+
+	d := ResendDecision{Resend: resend < repair, ResendBytes: resend, RepairBytes: repair}
+	if d.Resend {
+		d.Reason = fmt.Sprintf("resend %d missing symbols (%.0f bytes, %.2f rounds) beats %d+%.0f%% repair symbols (%.0f bytes)",
+			p.Missing, resend, resendRounds, deficit, 100*p.RepairOverhead, repair)
+	} else {
+		d.Reason = fmt.Sprintf("%d+%.0f%% repair symbols (%.0f bytes) beat resending %d missing symbols (%.0f bytes, %.2f rounds)",
+			deficit, 100*p.RepairOverhead, repair, p.Missing, resend, resendRounds)
+	}
+	return d
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"testing"
+)
+
+func TestExpectedRounds(t *testing.T) {
+	if r := expectedRounds(10, 0); r != 1 {
+		t.Errorf("Lossless rounds = %f, want 1", r)
+	}
+	// A single symbol takes 1/(1-p) rounds.
+	if r := expectedRounds(1, 0.5); r < 1.999 || r > 2.001 {
+		t.Errorf("Rounds for one symbol at p=0.5 = %f, want 2", r)
+	}
+	if expectedRounds(100, 0.1) <= expectedRounds(10, 0.1) {
+		t.Errorf("More symbols should need more rounds")
+	}
+}
+
+func TestChooseResend(t *testing.T) {
+	var resendTests = []struct {
+		name   string
+		p      ResendParams
+		resend bool
+	}{
+		{"lossless, missing equals deficit",
+			ResendParams{SymbolSize: 1000, SourceSymbols: 100, Rank: 90, Missing: 10,
+				RepairOverhead: 0.05, FeedbackBytes: 5000}, true},
+		{"many missing symbols are covered by repair already received",
+			ResendParams{SymbolSize: 1000, SourceSymbols: 100, Rank: 95, Missing: 30,
+				LossRate: 0.01, RepairOverhead: 0.05, FeedbackBytes: 500}, false},
+		{"lossy channel makes extra rounds expensive",
+			ResendParams{SymbolSize: 1000, SourceSymbols: 1000, Rank: 800, Missing: 200,
+				LossRate: 0.2, RepairOverhead: 0.02, FeedbackBytes: 50000}, false},
+		{"lossy channel with cheap feedback",
+			ResendParams{SymbolSize: 1000, SourceSymbols: 1000, Rank: 800, Missing: 200,
+				LossRate: 0.2, RepairOverhead: 0.02, FeedbackBytes: 100}, true},
+	}
+
+	for _, test := range resendTests {
+		d := ChooseResend(test.p)
+		if d.Resend != test.resend {
+			t.Errorf("%s: Resend = %t, want %t (%s)", test.name, d.Resend, test.resend, d.Reason)
+		}
+		if d.Reason == "" {
+			t.Errorf("%s: no reason given", test.name)
+		}
+	}
+
+	if d := ChooseResend(ResendParams{SourceSymbols: 10, Rank: 10}); d.Resend || d.RepairBytes != 0 {
+		t.Errorf("Finished receiver: %+v", d)
+	}
+}
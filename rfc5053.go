@@ -0,0 +1,111 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The raptor codec is permissive: it accepts more source symbols than the
+// RFC's systematic index table covers, partitions messages of any length, and
+// truncates code block IDs to 16 bits. Broadcast equipment certified against
+// RFC 5053 must not rely on that. The RaptorStrictEncoder instead enforces the
+// constraints of the RFC and fails with an error wrapping ErrNonCompliant on
+// any violation.
+
+// The range of source symbols per source block allowed by RFC 5053.
+const (
+	MinRaptorSourceSymbols = 4
+	MaxRaptorSourceSymbols = 8192
+)
+
+// ErrNonCompliant is wrapped by errors reporting violations of RFC 5053.
+var ErrNonCompliant = errors.New("fountain: violates RFC 5053")
+
+// RaptorStrictEncoder generates RFC 5053 compliant packets of raptor code
+// symbols.
+type RaptorStrictEncoder struct {
+	encoder    Encoder
+	symbolSize int
+}
+
+// NewRaptorStrictEncoder creates a strict encoder for one source block. The
+// codec must be an R10 raptor codec (from NewRaptorCodec) with a number of
+// source symbols K in [4, 8192], and symbolSize (T) must be a positive
+// multiple of its alignment (Al). The message may be at most K*T bytes, and
+// is padded with zeros to exactly K*T, so that all symbols have size T. The
+// message is left untouched.
+func NewRaptorStrictEncoder(c Codec, message []byte, symbolSize int) (*RaptorStrictEncoder, error) {
+	r, ok := c.(*raptorCodec)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not an R10 raptor codec", ErrNonCompliant, c)
+	}
+	k := r.NumSourceSymbols
+	if k < MinRaptorSourceSymbols || k > MaxRaptorSourceSymbols {
+		return nil, fmt.Errorf("%w: K=%d is outside [%d, %d]", ErrNonCompliant, k,
+			MinRaptorSourceSymbols, MaxRaptorSourceSymbols)
+	}
+	if r.SymbolAlignmentSize <= 0 || symbolSize <= 0 || symbolSize%r.SymbolAlignmentSize != 0 {
+		return nil, fmt.Errorf("%w: symbol size T=%d is not a positive multiple of Al=%d", ErrNonCompliant,
+			symbolSize, r.SymbolAlignmentSize)
+	}
+	if len(message) > k*symbolSize {
+		return nil, fmt.Errorf("%w: %d byte source block exceeds K*T=%d", ErrNonCompliant,
+			len(message), k*symbolSize)
+	}
+
+	padded := make([]byte, k*symbolSize)
+	copy(padded, message)
+	return &RaptorStrictEncoder{encoder: intermediateEncoder(r, padded), symbolSize: symbolSize}, nil
+}
+
+// Packet returns the g encoding symbols with consecutive ESIs starting at esi,
+// which make up one packet. All ESIs must be in [0, MaxRaptorESI].
+func (e *RaptorStrictEncoder) Packet(esi int64, g int) ([]LTBlock, error) {
+	if g < 1 {
+		return nil, fmt.Errorf("%w: packet of %d symbols", ErrNonCompliant, g)
+	}
+	if esi < 0 || esi+int64(g)-1 > MaxRaptorESI {
+		return nil, fmt.Errorf("%w: ESIs %d..%d are outside [0, %d]", ErrNonCompliant,
+			esi, esi+int64(g)-1, MaxRaptorESI)
+	}
+	packet := make([]LTBlock, g)
+	for i := range packet {
+		packet[i] = e.encoder.Generate(esi + int64(i))
+	}
+	return packet, nil
+}
+
+// CheckRaptorPacket checks that a received packet is composed the way RFC 5053
+// requires: at least one symbol, every symbol of size symbolSize, and
+// consecutive ESIs within [0, MaxRaptorESI].
+func CheckRaptorPacket(packet []LTBlock, symbolSize int) error {
+	if len(packet) == 0 {
+		return fmt.Errorf("%w: empty packet", ErrNonCompliant)
+	}
+	for i, b := range packet {
+		if b.BlockCode < 0 || b.BlockCode > MaxRaptorESI {
+			return fmt.Errorf("%w: ESI %d is outside [0, %d]", ErrNonCompliant, b.BlockCode, MaxRaptorESI)
+		}
+		if i > 0 && b.BlockCode != packet[i-1].BlockCode+1 {
+			return fmt.Errorf("%w: ESI %d follows %d in a packet", ErrNonCompliant, b.BlockCode, packet[i-1].BlockCode)
+		}
+		if len(b.Data) != symbolSize {
+			return fmt.Errorf("%w: symbol %d has %d bytes, want %d", ErrNonCompliant, b.BlockCode, len(b.Data), symbolSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var raptorStrictEncoderTests = []struct {
+	codec      Codec
+	length     int
+	symbolSize int
+	ok         bool
+}{
+	{NewRaptorCodec(10, 4), 80, 8, true},
+	{NewRaptorCodec(10, 4), 75, 8, true},
+	{NewRaptorCodec(10, 4), 81, 8, false},
+	{NewRaptorCodec(10, 4), 60, 6, false},
+	{NewRaptorCodec(10, 4), 0, 0, false},
+	{NewRaptorCodec(3, 1), 12, 4, false},
+	{NewRaptorCodec(8193, 1), 8193, 1, false},
+	{NewRU10Codec(10, 4), 80, 8, false},
+}
+
+func TestNewRaptorStrictEncoder(t *testing.T) {
+	for _, test := range raptorStrictEncoderTests {
+		_, err := NewRaptorStrictEncoder(test.codec, make([]byte, test.length), test.symbolSize)
+		if (err == nil) != test.ok {
+			t.Errorf("NewRaptorStrictEncoder(%T K=%d, %d bytes, T=%d) error = %v, want ok=%v",
+				test.codec, test.codec.SourceBlocks(), test.length, test.symbolSize, err, test.ok)
+		}
+		if err != nil && !errors.Is(err, ErrNonCompliant) {
+			t.Errorf("error %v doesn't wrap ErrNonCompliant", err)
+		}
+	}
+}
+
+func TestRaptorStrictEncoderPackets(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewRaptorCodec(10, 4)
+	e, err := NewRaptorStrictEncoder(c, message, 8)
+	if err != nil {
+		t.Fatalf("NewRaptorStrictEncoder failed: %v", err)
+	}
+
+	d := c.NewDecoder(80)
+	for esi := int64(3); ; esi += 4 {
+		packet, err := e.Packet(esi, 4)
+		if err != nil {
+			t.Fatalf("Packet(%d, 4) failed: %v", esi, err)
+		}
+		if err := CheckRaptorPacket(packet, 8); err != nil {
+			t.Fatalf("CheckRaptorPacket: %v", err)
+		}
+		if d.AddBlocks(packet) {
+			break
+		}
+	}
+	want := append(append([]byte(nil), message...), make([]byte, 80-len(message))...)
+	if decoded := d.Decode(); !bytes.Equal(decoded, want) {
+		t.Errorf("decoded %q, want %q", decoded, want)
+	}
+
+	if _, err := e.Packet(MaxRaptorESI-1, 3); err == nil {
+		t.Errorf("Packet accepted ESIs beyond %d", MaxRaptorESI)
+	}
+	if _, err := e.Packet(0, 0); err == nil {
+		t.Errorf("Packet accepted an empty packet")
+	}
+}
+
+var checkRaptorPacketTests = []struct {
+	packet []LTBlock
+	ok     bool
+}{
+	{[]LTBlock{{BlockCode: 5, Data: make([]byte, 4)}, {BlockCode: 6, Data: make([]byte, 4)}}, true},
+	{[]LTBlock{{BlockCode: 5, Data: make([]byte, 4)}, {BlockCode: 7, Data: make([]byte, 4)}}, false},
+	{[]LTBlock{{BlockCode: 5, Data: make([]byte, 4)}, {BlockCode: 6, Data: make([]byte, 3)}}, false},
+	{[]LTBlock{{BlockCode: MaxRaptorESI + 1, Data: make([]byte, 4)}}, false},
+	{nil, false},
+}
+
+func TestCheckRaptorPacket(t *testing.T) {
+	for i, test := range checkRaptorPacketTests {
+		if err := CheckRaptorPacket(test.packet, 4); (err == nil) != test.ok {
+			t.Errorf("%d: CheckRaptorPacket error = %v, want ok=%v", i, err, test.ok)
+		}
+	}
+}
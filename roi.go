@@ -0,0 +1,196 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Some bytes of a message can matter more than the rest: the header of a
+// file, or the index of an archive. A region-of-interest codec protects
+// chosen byte ranges more strongly than the rest of the message by adding
+// region repair blocks, composed only of the intermediate blocks the source
+// blocks of those ranges are made of, to the code blocks of the underlying
+// codec. Sending some of them along with the ordinary code blocks lets a
+// receiver recover the regions (see DecodeRegions) from fewer code blocks
+// than the whole message needs, and they still help decode the rest. This is
+// unequal protection within one object, where a LayeredEncoder needs an
+// object for each layer.
+//
+// Region repair blocks have negative IDs; the ordinary code blocks keep their
+// IDs. Each intermediate block of the regions is included in a region repair
+// block with probability one half, as in the binary fountain code, so that
+// every region repair block is as useful as possible to the regions.
+
+// ByteRange locates a range of bytes in a message.
+type ByteRange struct {
+	Offset, Length int
+}
+
+// roiCodec is a Codec which adds region repair blocks to another codec.
+type roiCodec struct {
+	Codec
+	messageLength int
+	regions       []ByteRange
+
+	// cover lists, in ascending order, the intermediate blocks the source
+	// blocks of the regions are composed of.
+	cover []int
+}
+
+// NewROICodec returns a codec which behaves like c, but also composes region
+// repair blocks for the given regions of a message of messageLength bytes:
+// the code block with ID -1-n is region repair block n. The codec encodes
+// and decodes only messages of messageLength bytes. Returns an error if a
+// region is empty or out of bounds, or c isn't one of the package's codecs.
+func NewROICodec(c Codec, messageLength int, regions []ByteRange) (Codec, error) {
+	if decoderMatrix(c.NewDecoder(messageLength)) == nil {
+		return nil, fmt.Errorf("fountain: region repair doesn't support %T", c)
+	}
+	k := c.SourceBlocks()
+	p := sourcePartition(messageLength, k)
+	covered := make(map[int]bool)
+	for _, r := range regions {
+		if r.Length <= 0 || r.Offset < 0 || r.Offset+r.Length > messageLength {
+			return nil, fmt.Errorf("fountain: region %+v isn't within the %d byte message", r, messageLength)
+		}
+		for i := 0; i < p.Pieces(); i++ {
+			if p.Offset(i+1) > r.Offset && p.Offset(i) < r.Offset+r.Length {
+				for _, j := range sourceComposition(c, i) {
+					covered[j] = true
+				}
+			}
+		}
+	}
+	if len(covered) == 0 {
+		return nil, fmt.Errorf("fountain: no regions of interest")
+	}
+	rc := &roiCodec{Codec: c, messageLength: messageLength, regions: regions}
+	for j := range covered {
+		rc.cover = append(rc.cover, j)
+	}
+	sort.Ints(rc.cover)
+	return rc, nil
+}
+
+// sourceComposition returns the intermediate blocks whose XOR is source block
+// i of the codec.
+func sourceComposition(c Codec, i int) []int {
+	if r, ok := c.(*raptorCodec); ok {
+		// The R10 code is systematic: source block i is the code block with
+		// ESI i.
+		return findLTIndices(r.NumSourceSymbols, uint16(i))
+	}
+	return []int{i}
+}
+
+// PickIndices picks the indices of the region repair block for a negative
+// ID, and those of the underlying codec otherwise.
+func (c *roiCodec) PickIndices(codeBlockIndex int64) []int {
+	if codeBlockIndex >= 0 {
+		return c.Codec.PickIndices(codeBlockIndex)
+	}
+	t := &MersenneTwister64{}
+	t.Seed(codeBlockIndex)
+	random := rand.New(t)
+	var indices []int
+	for _, j := range c.cover {
+		if random.Intn(2) == 1 {
+			indices = append(indices, j)
+		}
+	}
+	if len(indices) == 0 {
+		indices = append(indices, c.cover[random.Intn(len(c.cover))])
+	}
+	return indices
+}
+
+// NewEncoder creates an encoder which also generates region repair blocks.
+// The message must be of the codec's message length.
+func (c *roiCodec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
+}
+
+// unwrap returns the wrapped codec.
+func (c *roiCodec) unwrap() Codec {
+	return c.Codec
+}
+
+// encodesInPlace reports whether the wrapped codec encodes in place.
+func (c *roiCodec) encodesInPlace() bool {
+	e, ok := c.Codec.(inPlaceEncoder)
+	return ok && e.encodesInPlace()
+}
+
+// NewDecoder creates a decoder which also accepts region repair blocks. The
+// message length must be the codec's.
+func (c *roiCodec) NewDecoder(messageLength int) Decoder {
+	return &roiDecoder{Decoder: c.Codec.NewDecoder(messageLength), codec: c}
+}
+
+// roiDecoder adds the equations of region repair blocks to the decode matrix
+// of the underlying codec's decoder.
+type roiDecoder struct {
+	Decoder
+	codec *roiCodec
+}
+
+// AddBlocks adds code blocks and region repair blocks to the decoder.
+// Returns true if the message can be fully decoded. Region repair blocks are
+// checked against the decoder's symbol size and counted as received like
+// code blocks, but their IDs aren't recorded, as they aren't part of the
+// sequence of code block IDs. They are ignored once the decoder is
+// determined.
+func (d *roiDecoder) AddBlocks(blocks []LTBlock) bool {
+	m := decoderMatrix(d.Decoder)
+	var coded []LTBlock
+	for i := range blocks {
+		if blocks[i].BlockCode >= 0 {
+			coded = append(coded, blocks[i])
+		} else if !m.complete {
+			m.addRegionBlock(d.codec.PickIndices(blocks[i].BlockCode), block{data: blocks[i].Data})
+		}
+	}
+	return d.Decoder.AddBlocks(coded)
+}
+
+func (d *roiDecoder) recoverSource() ([]block, []bool) {
+	return d.Decoder.(sourceRecoverer).recoverSource()
+}
+
+func (d *roiDecoder) preloadSource(i int, data []byte) {
+	d.Decoder.(sourcePreloader).preloadSource(i, data)
+}
+
+// DecodeRegions returns the bytes of each region of interest of a decoder
+// created by a region-of-interest codec, once all of the regions can be
+// recovered, even if the rest of the message can't yet be. Returns false if
+// they can't, or d wasn't created by a region-of-interest codec.
+func DecodeRegions(d Decoder) ([][]byte, bool) {
+	rd, ok := d.(*roiDecoder)
+	if !ok {
+		return nil, false
+	}
+	source, recovered := rd.recoverSource()
+	out := make([][]byte, len(rd.codec.regions))
+	for i, r := range rd.codec.regions {
+		if out[i], ok = sourceRange(source, recovered, rd.codec.messageLength, r.Offset, r.Offset+r.Length); !ok {
+			return nil, false
+		}
+	}
+	return out, true
+}
@@ -0,0 +1,126 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestROICodec(t *testing.T) {
+	message := make([]byte, 1000)
+	rand.New(rand.NewSource(8)).Read(message)
+	regions := []ByteRange{{Offset: 0, Length: 40}, {Offset: 500, Length: 10}}
+	for _, c := range []Codec{
+		NewSeededLubyCodec(50, 99, SolitonDistribution(50)),
+		NewBinaryCodec(50),
+		NewOnlineCodec(50, 0.3, 10, 200),
+		NewRaptorCodec(50, 1),
+		NewRU10Codec(50, 1),
+	} {
+		rc, err := NewROICodec(c, len(message), regions)
+		if err != nil {
+			t.Fatalf("NewROICodec(%T) error %v", c, err)
+		}
+		e := rc.NewEncoder(message)
+		d := rc.NewDecoder(len(message))
+		if _, ok := DecodeRegions(d); ok {
+			t.Errorf("%T: regions decoded before any blocks", c)
+		}
+
+		// Region repair blocks alone recover the regions, which span three
+		// of the 50 source blocks, but not the message.
+		var got [][]byte
+		for n := int64(-1); n > -40; n-- {
+			if d.AddBlocks([]LTBlock{e.Generate(n)}) {
+				t.Fatalf("%T: message decoded from region repair blocks", c)
+			}
+			var ok bool
+			if got, ok = DecodeRegions(d); ok {
+				break
+			}
+		}
+		if got == nil {
+			t.Errorf("%T: regions not decoded", c)
+			continue
+		}
+		for i, r := range regions {
+			if want := message[r.Offset : r.Offset+r.Length]; !bytes.Equal(got[i], want) {
+				t.Errorf("%T: region %d = %v, want %v", c, i, got[i], want)
+			}
+		}
+
+		// The ordinary code blocks are unchanged, and complete the decode.
+		plain := c.NewEncoder(message)
+		for id := int64(0); id < 1000; id++ {
+			b := e.Generate(id)
+			if want := plain.Generate(id); !bytes.Equal(b.Data, want.Data) {
+				t.Fatalf("%T: code block %d = %v, want %v", c, id, b.Data, want.Data)
+			}
+			if d.AddBlocks([]LTBlock{b}) {
+				break
+			}
+		}
+		if out := d.Decode(); !bytes.Equal(out, message) {
+			t.Errorf("%T: decoded %v, want the message", c, out)
+		}
+	}
+}
+
+func TestROICodecErrors(t *testing.T) {
+	c := NewBinaryCodec(10)
+	for _, regions := range [][]ByteRange{
+		nil,
+		{{Offset: 0, Length: 0}},
+		{{Offset: -1, Length: 5}},
+		{{Offset: 95, Length: 6}},
+	} {
+		if _, err := NewROICodec(c, 100, regions); err == nil {
+			t.Errorf("NewROICodec(%v) succeeded, want an error", regions)
+		}
+	}
+}
+
+func TestROIDecoderRegionBlockStats(t *testing.T) {
+	message := make([]byte, 1000)
+	rand.New(rand.NewSource(8)).Read(message)
+	rc, err := NewROICodec(NewRaptorCodec(50, 1), len(message), []ByteRange{{Offset: 0, Length: 40}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := rc.NewEncoder(message)
+	d := rc.NewDecoder(len(message))
+	if !SetSymbolSize(d, 20) {
+		t.Fatal("SetSymbolSize failed")
+	}
+
+	// An oversized region repair block is rejected like a code block; a good
+	// one is counted as received, without its ID entering the reception span.
+	big := e.Generate(-1)
+	big.Data = append(big.Data, 0)
+	d.AddBlocks([]LTBlock{big, e.Generate(-2), e.Generate(0)})
+	diag, _ := Diagnose(d)
+	added := 0
+	for _, n := range diag.Degrees {
+		added += n
+	}
+	if diag.Received != 3 || diag.Rejected != 1 || added != 2 {
+		t.Errorf("Diagnose = %v, want 3 received, of which 1 rejected", diag)
+	}
+	if r, _ := DecoderReception(d); r.Span() != (ESIRange{0, 0}) {
+		t.Errorf("reception span %v, want [0, 0]", r.Span())
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import "math/bits"
+
+// The coefficients of a matrix row are a sorted slice of the blocks in its
+// equation, and combining two rows takes the symmetric difference of their
+// slices. That suits the sparse rows of LT codes, but the rows of the binary
+// codec, and the half-symbol rows of R10, name half of the blocks; merging
+// those is slow and allocates a new slice at every step of elimination.
+// So a row above a density threshold also keeps its coefficients as a
+// word-packed bitset, and an incoming dense equation is reduced in a bitset
+// too, by XORing words. The slices stay the canonical form: reading a row
+// never needs to know which kind it is.
+
+// denseRowDivisor sets the density threshold: a row is dense if it has at
+// least one coefficient in denseRowDivisor. Below that, merging the slices
+// costs fewer operations than XORing the words.
+const denseRowDivisor = 32
+
+// denseRowMinRows is the smallest matrix whose rows are ever dense. A few
+// words of slice merging cost less than allocating a bitset.
+const denseRowMinRows = 128
+
+// bitRow is a row's coefficients as a bitset: bit c%64 of word c/64 is set if
+// block c is in the row's equation.
+type bitRow []uint64
+
+// newBitRow returns the bitset of the coefficients of a row in a matrix of n
+// rows.
+func newBitRow(n int, coeffs []int) bitRow {
+	r := make(bitRow, (n+63)/64)
+	for _, c := range coeffs {
+		r[c/64] |= 1 << (c % 64)
+	}
+	return r
+}
+
+// has reports whether block c is in the row's equation.
+func (r bitRow) has(c int) bool {
+	return r[c/64]&(1<<(c%64)) != 0
+}
+
+// toggle adds block c to the row's equation, or removes it if it's there.
+// Returns the change in the row's weight.
+func (r bitRow) toggle(c int) int {
+	r[c/64] ^= 1 << (c % 64)
+	if r.has(c) {
+		return 1
+	}
+	return -1
+}
+
+// xor sets r to the symmetric difference of r and s. Returns the change in the
+// row's weight.
+func (r bitRow) xor(s bitRow) int {
+	delta := 0
+	for i, w := range s {
+		if w == 0 {
+			continue
+		}
+		delta -= bits.OnesCount64(r[i])
+		r[i] ^= w
+		delta += bits.OnesCount64(r[i])
+	}
+	return delta
+}
+
+// next returns the first block from c onwards in the row's equation, or -1 if
+// there is none.
+func (r bitRow) next(c int) int {
+	for i := c / 64; i < len(r); i++ {
+		w := r[i]
+		if i == c/64 {
+			w &= ^uint64(0) << (c % 64)
+		}
+		if w != 0 {
+			return i*64 + bits.TrailingZeros64(w)
+		}
+	}
+	return -1
+}
+
+// coeffs returns the blocks in the row's equation, of which there are weight,
+// as a sorted slice.
+func (r bitRow) coeffs(weight int) []int {
+	out := make([]int, 0, weight)
+	for i, w := range r {
+		for w != 0 {
+			out = append(out, i*64+bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+	return out
+}
+
+// dense reports whether a row with weight coefficients is dense.
+func (m *sparseMatrix) dense(weight int) bool {
+	return len(m.coeff) >= denseRowMinRows && weight*denseRowDivisor >= len(m.coeff)
+}
+
+// setRow stores the equation (coeffs, b) in row i, keeping the row's bitset
+// if it is dense.
+func (m *sparseMatrix) setRow(i int, coeffs []int, b block) {
+	m.coeff[i], m.v[i] = coeffs, b
+	if len(m.bits) != len(m.coeff) {
+		m.bits = make([]bitRow, len(m.coeff))
+	}
+	m.bits[i] = nil
+	if m.dense(len(coeffs)) {
+		m.bits[i] = newBitRow(len(m.coeff), coeffs)
+	}
+}
+
+// dropBits discards the bitset of row i, once the row has been solved.
+func (m *sparseMatrix) dropBits(i int) {
+	if i < len(m.bits) {
+		m.bits[i] = nil
+	}
+}
+
+// rowBits returns the bitset of row i, or nil if the row doesn't keep one.
+func (m *sparseMatrix) rowBits(i int) bitRow {
+	if i >= len(m.bits) || len(m.coeff[i]) == 0 {
+		return nil
+	}
+	return m.bits[i]
+}
+
+// addDenseEquation finishes adding a dense equation to the matrix, making the
+// same reductions and swaps as addEquation does, but combining the equation
+// with the rows in a bitset. Returns what is left of the equation once its
+// leading block's row is empty: nothing if the equation was redundant.
+func (m *sparseMatrix) addDenseEquation(components []int, b block) ([]int, block) {
+	n := len(m.coeff)
+	acc := newBitRow(n, components)
+	weight := len(components)
+	for lead := components[0]; ; {
+		if lead = acc.next(lead); lead < 0 {
+			return nil, b
+		}
+		if len(m.coeff[lead]) == 0 {
+			return acc.coeffs(weight), b
+		}
+		row := m.coeff[lead]
+		if weight < len(row) {
+			// Swap the existing row for the new one, and carry on reducing the
+			// existing one.
+			rowv := m.v[lead]
+			m.setRow(lead, acc.coeffs(weight), b)
+			clear(acc)
+			for _, c := range row {
+				acc[c/64] |= 1 << (c % 64)
+			}
+			weight, b = len(row), rowv
+			continue
+		}
+		b.xor(m.v[lead])
+		m.cost.RowOps++
+		m.cost.XORBytes += int64(len(m.v[lead].data))
+		if rb := m.rowBits(lead); rb != nil {
+			weight += acc.xor(rb)
+		} else {
+			for _, c := range row {
+				weight += acc.toggle(c)
+			}
+		}
+	}
+}
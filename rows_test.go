@@ -0,0 +1,134 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBitRow(t *testing.T) {
+	r := newBitRow(200, []int{3, 64, 130})
+	if got := r.coeffs(3); !reflect.DeepEqual(got, []int{3, 64, 130}) {
+		t.Errorf("coeffs() = %v, want [3 64 130]", got)
+	}
+	for _, c := range []struct{ from, want int }{{0, 3}, {3, 3}, {4, 64}, {65, 130}, {131, -1}} {
+		if got := r.next(c.from); got != c.want {
+			t.Errorf("next(%d) = %d, want %d", c.from, got, c.want)
+		}
+	}
+	if d := r.toggle(64); d != -1 || r.has(64) {
+		t.Errorf("toggle(64) = %d, has(64) = %v, want -1, false", d, r.has(64))
+	}
+	if d := r.toggle(199); d != 1 || !r.has(199) {
+		t.Errorf("toggle(199) = %d, has(199) = %v, want 1, true", d, r.has(199))
+	}
+	if d := r.xor(newBitRow(200, []int{3, 5, 199})); d != -1 {
+		t.Errorf("xor() = %d, want -1", d)
+	}
+	if got := r.coeffs(2); !reflect.DeepEqual(got, []int{5, 130}) {
+		t.Errorf("coeffs() after xor = %v, want [5 130]", got)
+	}
+}
+
+// addSparseEquation is addEquation without the bitset rows.
+func addSparseEquation(m *sparseMatrix, components []int, b block) {
+	for len(components) > 0 && len(m.coeff[components[0]]) > 0 {
+		s := components[0]
+		if len(components) >= len(m.coeff[s]) {
+			components, b = m.xorRow(s, components, b)
+		} else {
+			components, m.coeff[s] = m.coeff[s], components
+			b, m.v[s] = m.v[s], b
+		}
+	}
+	if len(components) > 0 {
+		m.coeff[components[0]] = components
+		m.v[components[0]] = b
+	}
+}
+
+func TestDenseRows(t *testing.T) {
+	const n = 300
+	random := rand.New(rand.NewSource(7))
+	dense := sparseMatrix{coeff: make([][]int, n), v: make([]block, n)}
+	sparse := sparseMatrix{coeff: make([][]int, n), v: make([]block, n)}
+	for e := 0; e < 2*n; e++ {
+		// Mix sparse equations with ones naming up to half of the blocks.
+		degree := 1 + random.Intn(4)
+		if e%3 == 0 {
+			degree = 1 + random.Intn(n/2)
+		}
+		components := sampleUniform(random, degree, n)
+		data := make([]byte, 8)
+		random.Read(data)
+		dense.addEquation(append([]int(nil), components...), block{data: bytes.Clone(data)})
+		addSparseEquation(&sparse, components, block{data: data})
+	}
+
+	if !reflect.DeepEqual(dense.coeff, sparse.coeff) {
+		t.Fatalf("Eliminating with bitset rows gave different coefficients")
+	}
+	for i := range dense.v {
+		if !bytes.Equal(dense.v[i].data, sparse.v[i].data) {
+			t.Errorf("Row %d value = %v, want %v", i, dense.v[i].data, sparse.v[i].data)
+		}
+	}
+	numDense := 0
+	for i, r := range dense.bits {
+		if r == nil {
+			continue
+		}
+		numDense++
+		if got := r.coeffs(len(dense.coeff[i])); !reflect.DeepEqual(got, dense.coeff[i]) {
+			t.Errorf("Row %d bitset holds %v, want %v", i, got, dense.coeff[i])
+		}
+	}
+	if numDense == 0 {
+		t.Errorf("No rows were stored as bitsets")
+	}
+
+	dense.reduce()
+	sparse.reduce()
+	for i := range dense.v {
+		if !bytes.Equal(dense.v[i].data, sparse.v[i].data) {
+			t.Errorf("Reduced row %d value = %v, want %v", i, dense.v[i].data, sparse.v[i].data)
+		}
+	}
+}
+
+func BenchmarkBinaryDecode(b *testing.B) {
+	message := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(message)
+	c := NewBinaryCodec(256)
+	ids := make([]int64, 300)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	blocks := EncodeLTBlocksCopy(message, ids, c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := c.NewDecoder(len(message))
+		for j := range blocks {
+			// Decoders XOR into the blocks they are given.
+			if d.AddBlocks([]LTBlock{{BlockCode: blocks[j].BlockCode, Data: bytes.Clone(blocks[j].Data)}}) {
+				break
+			}
+		}
+		d.Decode()
+	}
+}
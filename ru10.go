@@ -15,9 +15,8 @@
 package fountain
 
 import (
-	"math"
-  "math/rand"
-  "sort"
+	"math/rand"
+	"sort"
 )
 
 // The RU10 fountain is an unsystematic(*) fountain code which uses a degree
@@ -40,19 +39,19 @@ import (
 // (*) Well, not by design at least.
 
 // This triple generator uses the Mersenne Twister to generate random seeds.
-// k is the number of source symbols.
+// lprime is the smallest prime at least the number of intermediate symbols.
 // x is the (random) code symbol ID.
 // The generator creates values (d, a, b) to be used in constructing intermediate blocks.
-func ru10TripleGenerator(k int, x int64) (int, uint32, uint32) {
-	l, _, _ := intermediateSymbols(k)
-	lprime := smallestPrimeGreaterOrEqual(l)
-
+func ru10TripleGenerator(lprime int, x int64) (int, uint32, uint32) {
 	// TODO(gbillock): nudge x as a function of k to get better overhead-failure curve?
-	rand := rand.New(NewMersenneTwister64(x))
+	return ru10Triple(rand.New(NewMersenneTwister64(x)), lprime)
+}
 
-	v := uint32(rand.Int63() % 1048576)
-	a := uint32(1 + (rand.Int63() % int64(lprime - 1)))
-	b := uint32(rand.Int63() % int64(lprime))
+// ru10Triple draws the triple of ru10TripleGenerator from random.
+func ru10Triple(random *rand.Rand, lprime int) (int, uint32, uint32) {
+	v := uint32(random.Int63() % 1048576)
+	a := uint32(1 + (random.Int63() % int64(lprime-1)))
+	b := uint32(random.Int63() % int64(lprime))
 	d := deg(v)
 
 	return d, a, b
@@ -63,15 +62,36 @@ func ru10TripleGenerator(k int, x int64) (int, uint32, uint32) {
 type ru10Codec struct {
 	numSourceSymbols int
 
-  symbolAlignmentSize int
+	symbolAlignmentSize int
+
+	// precode supplies the parity blocks appended to the source blocks.
+	precode Precode
+
+	// compositions caches precode.Compositions(numSourceSymbols).
+	compositions [][]int
+
+	// lprime caches the smallest prime at least intermediateBlocks().
+	lprime int
 }
 
 // NewRU10Codec creates an unsystematic raptor-like fountain codec which uses an
 // intermediate block generation algorithm similar to the Raptor R10 codec.
 func NewRU10Codec(numSourceSymbols int, symbolAlignmentSize int) Codec {
-  return &ru10Codec{
-    numSourceSymbols: numSourceSymbols,
-    symbolAlignmentSize: symbolAlignmentSize}
+	return NewRU10CodecWithPrecode(numSourceSymbols, symbolAlignmentSize, R10Precode)
+}
+
+// NewRU10CodecWithPrecode creates an RU10 codec which uses the given precode
+// in place of the R10 LDPC and half-symbol blocks. Code blocks are still
+// composed using the R10 degree distribution, but over the K source blocks plus
+// however many parity blocks the precode adds.
+func NewRU10CodecWithPrecode(numSourceSymbols int, symbolAlignmentSize int, p Precode) Codec {
+	c := &ru10Codec{
+		numSourceSymbols:    numSourceSymbols,
+		symbolAlignmentSize: symbolAlignmentSize,
+		precode:             p,
+		compositions:        p.Compositions(numSourceSymbols)}
+	c.lprime = smallestPrimeGreaterOrEqual(c.intermediateBlocks())
+	return c
 }
 
 // SourceBlocks returns the number of source blocks the codec uses in the
@@ -80,12 +100,18 @@ func (c *ru10Codec) SourceBlocks() int {
 	return c.numSourceSymbols
 }
 
+// intermediateBlocks returns the number of intermediate blocks: the source
+// blocks plus the parity blocks of the precode.
+func (c *ru10Codec) intermediateBlocks() int {
+	return c.numSourceSymbols + len(c.compositions)
+}
+
 // PickIndices uses the R10 distribution function to pick indices. It gets
 // numbers from the triple generator.
 func (c *ru10Codec) PickIndices(codeBlockIndex int64) []int {
-	d, a, b := ru10TripleGenerator(c.numSourceSymbols, codeBlockIndex)
-	l, _, _ := intermediateSymbols(c.numSourceSymbols)
-	lprime := uint32(smallestPrimeGreaterOrEqual(l))
+	l := c.intermediateBlocks()
+	d, a, b := ru10TripleGenerator(c.lprime, codeBlockIndex)
+	lprime := uint32(c.lprime)
 
 	if d > l {
 		d = l
@@ -109,85 +135,89 @@ func (c *ru10Codec) PickIndices(codeBlockIndex int64) []int {
 	return indices
 }
 
+// tracePRNG records the PRNG draws of PickIndices, which are those of
+// ru10TripleGenerator.
+func (c *ru10Codec) tracePRNG(codeBlockIndex int64) (PRNGTrace, bool) {
+	src := &countingSource{Source: NewMersenneTwister64(codeBlockIndex)}
+	ru10Triple(rand.New(src), c.lprime)
+	return PRNGTrace{BlockCode: codeBlockIndex, Generator: "mt19937-64", Seed: []uint64{uint64(codeBlockIndex)}, Draws: src.draws}, true
+}
+
 // RU10 intermediate encoding consists of the source symbols plus additional
 // intermediate symbols consisting of exactly the S and H blocks the R10 code
-// uses. The difference is that the code is unsystematic -- the source blocks
-// aren't necessarily going to be represented at the output -- so when we do
-// the decode we don't need to translate from the intermediate symbols back to
-// the source symbols: the source symbols are just the first K intermediate symbols.
+// uses (or the parity blocks of the codec's precode). The difference is that
+// the code is unsystematic -- the source blocks aren't necessarily going to be
+// represented at the output -- so when we do the decode we don't need to
+// translate from the intermediate symbols back to the source symbols: the
+// source symbols are just the first K intermediate symbols.
 func (c *ru10Codec) GenerateIntermediateBlocks(message []byte, numBlocks int) []block {
 	sourceLong, sourceShort := partitionBytes(message, c.numSourceSymbols)
 	source := equalizeBlockLengths(sourceLong, sourceShort)
-
-	_, s, h := intermediateSymbols(c.numSourceSymbols)
-
-	k := c.numSourceSymbols
-	compositions := make([][]int, s)
-
-	for i := 0; i < k; i++ {
-		a := 1 + (int(math.Floor(float64(i)/float64(s))) % (s - 1))
-		b := i % s
-		compositions[b] = append(compositions[b], i)
-		b = (b + a) % s
-		compositions[b] = append(compositions[b], i)
-		b = (b + a) % s
-		compositions[b] = append(compositions[b], i)
-	}
-	for i := 0; i < s; i++ {
-		b := generateLubyTransformBlock(source, compositions[i])
-		source = append(source, b)
-	}
-
-	hprime := int(math.Ceil(float64(h) / 2))
-	m := buildGraySequence(k+s, hprime)
-	for i := 0; i < h; i++ {
-		hcomposition := make([]int, 0)
-		for j := 0; j < k+s; j++ {
-			if bitSet(uint(m[j]), uint(i)) {
-				hcomposition = append(hcomposition, j)
-			}
-		}
-		b := generateLubyTransformBlock(source, hcomposition)
-		source = append(source, b)
-	}
-	return source
+	return appendParityBlocks(source, c.compositions)
 }
 
 // NewDecoder creates a new RU10 decoder
 func (c *ru10Codec) NewDecoder(messageLength int) Decoder {
-  return newRU10Decoder(c, messageLength)
+	return newRU10Decoder(c, messageLength)
+}
+
+// NewEncoder creates a new RU10 encoder
+func (c *ru10Codec) NewEncoder(message []byte) Encoder {
+	return newLTEncoder(c, message)
 }
 
 // ru10Decoder is the corresponding decoder for fountain codes using the RU10 encoder.
 type ru10Decoder struct {
+	codec   *ru10Codec
 	decoder *raptorDecoder
 }
 
 // newRU10Decoder creates a new raptor decoder for a given message. The
 // codec supplied must be the same one as the message was encoded with.
 func newRU10Decoder(c *ru10Codec, length int) *ru10Decoder {
-	return &ru10Decoder{
-		decoder: newRaptorDecoder(&raptorCodec{
-      SymbolAlignmentSize: c.symbolAlignmentSize,
-			NumSourceSymbols: c.numSourceSymbols},
-			length),
+	d := &raptorDecoder{
+		codec: raptorCodec{
+			SymbolAlignmentSize: c.symbolAlignmentSize,
+			NumSourceSymbols:    c.numSourceSymbols},
+		messageLength: length,
 	}
+	d.matrix.coeff = make([][]int, c.intermediateBlocks())
+	d.matrix.v = make([]block, c.intermediateBlocks())
+	addPrecodeConstraints(&d.matrix, c.numSourceSymbols, c.compositions)
+	return &ru10Decoder{codec: c, decoder: d}
 }
 
+// AddBlocks adds a set of encoded blocks to the decoder. Returns true if the
+// message can be fully decoded. False if there is insufficient information.
 func (d *ru10Decoder) AddBlocks(blocks []LTBlock) bool {
-	c := ru10Codec{
-    symbolAlignmentSize: d.decoder.codec.SymbolAlignmentSize,
-		numSourceSymbols: d.decoder.codec.NumSourceSymbols}
+	if d.decoder.matrix.lateBlocks(blocks) {
+		return true
+	}
 	for i := range blocks {
-		indices := c.PickIndices(blocks[i].BlockCode)
-		d.decoder.matrix.addEquation(indices, block{data: blocks[i].Data})
+		indices := d.codec.PickIndices(blocks[i].BlockCode)
+		d.decoder.matrix.addCodeBlock(blocks[i].BlockCode, indices, block{data: blocks[i].Data})
 	}
-	return d.decoder.matrix.determined()
+	return d.decoder.matrix.setComplete(d.determined())
+}
+
+// determined reports whether the source blocks (the first K intermediate
+// blocks) can all be recovered. The precode's parity blocks needn't be.
+func (d *ru10Decoder) determined() bool {
+	return d.decoder.matrix.solvedThrough(d.codec.numSourceSymbols)
 }
 
+// Decode extracts the decoded message from the decoder. If the decoder does
+// not have sufficient information to produce an output, returns a nil slice.
 func (d *ru10Decoder) Decode() []byte {
-	if !d.decoder.matrix.determined() {
-		return nil
+	out, _ := d.decode(false)
+	return out
+}
+
+// decode reduces the decode matrix and reconstructs the message. See
+// reconstructBlocks for the meaning of strict.
+func (d *ru10Decoder) decode(strict bool) ([]byte, error) {
+	if !d.determined() {
+		return nil, errNotDetermined
 	}
 
 	d.decoder.matrix.reduce()
@@ -198,13 +228,16 @@ func (d *ru10Decoder) Decode() []byte {
 
 	lenLong, lenShort, numLong, numShort :=
 		partition(d.decoder.messageLength, d.decoder.codec.NumSourceSymbols)
-	out := make([]byte, d.decoder.messageLength)
-	out = out[0:0]
-	for i := 0; i < numLong; i++ {
-		out = append(out, intermediate[i].data[0:lenLong]...)
-	}
-	for i := numLong; i < numLong+numShort; i++ {
-		out = append(out, intermediate[i].data[0:lenShort]...)
-	}
-	return out
+	return reconstructBlocks(intermediate, d.decoder.messageLength,
+		lenLong, lenShort, numLong, numShort, strict)
+}
+
+// recoverSource reduces the solvable rows of the decode matrix and returns
+// the source blocks, which are the first K intermediate blocks, along with
+// which of them have been recovered.
+func (d *ru10Decoder) recoverSource() ([]block, []bool) {
+	k := d.codec.numSourceSymbols
+	ok := d.decoder.matrix.solvable()
+	d.decoder.matrix.reduce()
+	return d.decoder.matrix.v[:k], ok[:k]
 }
@@ -0,0 +1,45 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRU10Codec(t *testing.T) {
+	c := NewRU10Codec(13, 2)
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	ids := make([]int64, 45)
+	random := rand.New(rand.NewSource(8923489))
+	for i := range ids {
+		ids[i] = random.Int63()
+	}
+
+	messageCopy := make([]byte, len(message))
+	copy(messageCopy, message)
+	blocks := EncodeLTBlocks(messageCopy, ids, c)
+
+	d := c.NewDecoder(len(message))
+	for i := range blocks {
+		if d.AddBlocks(blocks[i : i+1]) {
+			break
+		}
+	}
+	if decoded := d.Decode(); !reflect.DeepEqual(decoded, message) {
+		t.Errorf("Decoded %v, want %v", decoded, message)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// The Luby and Online codecs pick the source indices of a code block by
+// sampling them uniformly from a PRNG seeded with its ID, so encoders and
+// decoders must sample the same way. The original sampler draws until it has
+// found enough distinct indices, which gets slow as the degree approaches the
+// number of source blocks. Later samplers are selected explicitly, so that
+// code blocks composed by earlier versions of the package still decode.
+
+// IndexSampling identifies how a codec samples the source indices of a code
+// block.
+type IndexSampling int
+
+const (
+	// SamplingRejection draws numbers until it has found enough distinct
+	// ones. It is the default, and composes the code blocks earlier versions
+	// of the package did.
+	SamplingRejection IndexSampling = iota
+
+	// SamplingFloyd samples with Floyd's algorithm, which draws exactly one
+	// number per index however close the degree is to the number of source
+	// blocks, in O(degree) expected time and space.
+	SamplingFloyd
+)
+
+// WithIndexSampling returns a copy of c, a Luby or Online codec, which
+// samples the source indices of code blocks with s. Encoders and decoders of
+// the code blocks must use the same sampling.
+func WithIndexSampling(c Codec, s IndexSampling) (Codec, error) {
+	if s != SamplingRejection && s != SamplingFloyd {
+		return nil, fmt.Errorf("fountain: unknown index sampling %d", s)
+	}
+	switch c := c.(type) {
+	case *lubyCodec:
+		copied := *c
+		copied.sampling = s
+		return &copied, nil
+	case *onlineCodec:
+		copied := *c
+		copied.sampling = s
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("fountain: %T doesn't sample indices", c)
+}
+
+// sample picks num numbers from [0,max) uniformly with s, like sampleUniform.
+func (s IndexSampling) sample(random *rand.Rand, num, max int) []int {
+	if s == SamplingFloyd {
+		return sampleFloyd(random, num, max)
+	}
+	return sampleUniform(random, num, max)
+}
+
+// sampleFloyd picks num numbers from [0,max) uniformly with Floyd's
+// algorithm: for each j in [max-num, max), it picks a number up to j, or j
+// itself if that number was already picked. Like sampleUniform, it returns
+// the numbers sorted, and all of [0,max) without touching the PRNG if
+// num >= max. The picks are kept in a bitset if they are dense, and otherwise
+// in a hash set, then bucket sorted.
+func sampleFloyd(random *rand.Rand, num, max int) []int {
+	if num >= max {
+		return sampleUniform(random, num, max)
+	}
+	if num*denseSampleDivisor >= max {
+		set := make(bitRow, (max+63)/64)
+		for j := max - num; j < max; j++ {
+			p := random.Intn(j + 1)
+			if set.has(p) {
+				p = j
+			}
+			set[p/64] |= 1 << (p % 64)
+		}
+		return set.coeffs(num)
+	}
+	set := newIntSet(num)
+	picks := make([]int, 0, num)
+	for j := max - num; j < max; j++ {
+		p := random.Intn(j + 1)
+		if !set.add(p) {
+			// j is larger than all the picks so far.
+			p = j
+			set.add(p)
+		}
+		picks = append(picks, p)
+	}
+	return bucketSort(picks, max)
+}
+
+// denseSampleDivisor sets when sampleFloyd keeps its picks in a bitset of max
+// bits rather than a hash set: when it picks at least one number in
+// denseSampleDivisor. The bitset is then no bigger than the hash set.
+const denseSampleDivisor = 64
+
+// intSet is an open addressing hash set of non-negative ints, with room for a
+// fixed number of them. Slots hold a number plus one, and 0 when empty.
+type intSet struct {
+	slots []int
+	shift uint
+}
+
+// newIntSet creates a set with room for n numbers, at most half full.
+func newIntSet(n int) intSet {
+	shift := uint(64)
+	for size := 1; size < 2*n; size *= 2 {
+		shift--
+	}
+	return intSet{slots: make([]int, 1<<(64-shift)), shift: shift}
+}
+
+// add adds v to the set. Returns false if it was already there.
+func (s intSet) add(v int) bool {
+	mask := len(s.slots) - 1
+	// Fibonacci hashing spreads consecutive numbers across the slots.
+	for i := int(uint64(v) * 0x9e3779b97f4a7c15 >> s.shift); ; i = (i + 1) & mask {
+		switch s.slots[i] {
+		case 0:
+			s.slots[i] = v + 1
+			return true
+		case v + 1:
+			return false
+		}
+	}
+}
+
+// bucketSort sorts distinct numbers from [0,max), spread uniformly, in O(n)
+// expected time: it scatters them into n buckets covering equal ranges of
+// numbers, then insertion sorts the result, where each number is already in
+// its bucket.
+func bucketSort(picks []int, max int) []int {
+	n := len(picks)
+	bucket := func(p int) int { return int(int64(p) * int64(n) / int64(max)) }
+	start := make([]int, n+1)
+	for _, p := range picks {
+		start[bucket(p)+1]++
+	}
+	for i := 1; i <= n; i++ {
+		start[i] += start[i-1]
+	}
+	sorted := make([]int, n)
+	for _, p := range picks {
+		b := bucket(p)
+		sorted[start[b]] = p
+		start[b]++
+	}
+	for i := 1; i < n; i++ {
+		for j := i; j > 0 && sorted[j] < sorted[j-1]; j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	return sorted
+}
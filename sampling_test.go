@@ -0,0 +1,96 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSampleFloyd(t *testing.T) {
+	random := rand.New(rand.NewSource(256))
+	for _, test := range []struct {
+		num, length int
+		want        []int
+	}{
+		{2, 5, []int{3, 4}},
+		{2, 2, []int{0, 1}},
+		{12, 2, []int{0, 1}},
+	} {
+		if got := sampleFloyd(random, test.num, test.length); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("sampleFloyd(%d, %d) = %v, want %v", test.num, test.length, got, test.want)
+		}
+	}
+
+	// Sparse samples are kept in a hash set, and dense ones in a bitset.
+	for _, test := range []struct{ num, length int }{{5, 1000}, {300, 100000}, {40, 100}, {199, 200}} {
+		for n := 0; n < 100; n++ {
+			got := sampleFloyd(random, test.num, test.length)
+			if len(got) != test.num {
+				t.Fatalf("sampleFloyd(%d, %d) picked %d numbers", test.num, test.length, len(got))
+			}
+			for k, p := range got {
+				if p < 0 || p >= test.length || (k > 0 && p <= got[k-1]) {
+					t.Fatalf("sampleFloyd(%d, %d) = %v, want distinct sorted numbers in [0, %d)",
+						test.num, test.length, got, test.length)
+				}
+			}
+		}
+	}
+}
+
+func TestWithIndexSampling(t *testing.T) {
+	message := make([]byte, 40*16)
+	rand.New(rand.NewSource(9)).Read(message)
+	ids := make([]int64, 200)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	for _, c := range []Codec{
+		NewSeededLubyCodec(40, 7, SolitonDistribution(40)),
+		NewOnlineCodec(40, 0.2, 7, 5),
+	} {
+		floyd, err := WithIndexSampling(c, SamplingFloyd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The default sampling is left alone, and Floyd's picks differently.
+		if rejection, _ := WithIndexSampling(c, SamplingRejection); !reflect.DeepEqual(rejection, c) {
+			t.Errorf("%T: WithIndexSampling(SamplingRejection) = %+v, want %+v", c, rejection, c)
+		}
+		differs := false
+		for _, id := range ids {
+			differs = differs || !reflect.DeepEqual(floyd.PickIndices(id), c.PickIndices(id))
+		}
+		if !differs {
+			t.Errorf("%T: Floyd's sampling picked the same indices", c)
+		}
+		d := floyd.NewDecoder(len(message))
+		if !d.AddBlocks(EncodeLTBlocksCopy(message, ids, floyd)) {
+			t.Fatalf("%T: decoder not determined", c)
+		}
+		if got := d.Decode(); !bytes.Equal(got, message) {
+			t.Errorf("%T: decoded message differs", c)
+		}
+	}
+	if _, err := WithIndexSampling(NewRaptorCodec(10, 4), SamplingFloyd); err == nil {
+		t.Error("WithIndexSampling accepted a raptor codec")
+	}
+	if _, err := WithIndexSampling(NewBinaryCodec(10), 7); err == nil {
+		t.Error("WithIndexSampling accepted an unknown sampling")
+	}
+}
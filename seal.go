@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+)
+
+// A code block can be encrypted and authenticated on its own with an AEAD,
+// so that a receiver verifies each one before its equation enters the decode
+// matrix; a forged or corrupted block is then rejected rather than silently
+// corrupting the decode. The nonce and the additional data bind the block to
+// its ID and to the object it belongs to, so a block cannot be replayed under
+// another ID or into another object's decoder.
+
+// ErrSymbolAuth is returned when a sealed code block fails authentication.
+var ErrSymbolAuth = errors.New("fountain: code block failed authentication")
+
+// SymbolSealer encrypts and authenticates individual code blocks of one
+// object.
+type SymbolSealer struct {
+	aead     cipher.AEAD
+	objectID uint32
+}
+
+// NewSymbolSealer creates a sealer for the code blocks of the object with the
+// given ID. The nonce of each block is formed from the object ID and the
+// block ID, so (object ID, block ID) pairs must never repeat under one key:
+// in particular, object IDs must not be reused with the same key. The AEAD's
+// nonce must be at least 12 bytes long, as it is for AES-GCM and
+// ChaCha20-Poly1305.
+func NewSymbolSealer(aead cipher.AEAD, objectID uint32) (*SymbolSealer, error) {
+	if aead.NonceSize() < 12 {
+		return nil, fmt.Errorf("fountain: AEAD nonce size %d is less than 12", aead.NonceSize())
+	}
+	return &SymbolSealer{aead: aead, objectID: objectID}, nil
+}
+
+// nonce returns the nonce for a code block: zeros, then the object ID and the
+// block ID in the canonical wire byte order.
+func (s *SymbolSealer) nonce(id int64) []byte {
+	nonce := make([]byte, s.aead.NonceSize()-12, s.aead.NonceSize())
+	nonce = ByteOrder.AppendUint32(nonce, s.objectID)
+	return ByteOrder.AppendUint64(nonce, uint64(id))
+}
+
+// additionalData returns the authenticated, unencrypted data for a code
+// block. It repeats what the nonce holds, so the binding holds even for AEADs
+// which are weak against related nonces.
+func (s *SymbolSealer) additionalData(id int64) []byte {
+	ad := ByteOrder.AppendUint32(nil, s.objectID)
+	return ByteOrder.AppendUint64(ad, uint64(id))
+}
+
+// Seal returns the block with its data encrypted and authenticated. The
+// sealed data is longer than the original by the AEAD's overhead.
+func (s *SymbolSealer) Seal(b LTBlock) LTBlock {
+	return LTBlock{
+		BlockCode: b.BlockCode,
+		Data:      s.aead.Seal(nil, s.nonce(b.BlockCode), b.Data, s.additionalData(b.BlockCode)),
+	}
+}
+
+// Open authenticates and decrypts a sealed block. Returns ErrSymbolAuth if the
+// block was modified, or sealed for another ID or object.
+func (s *SymbolSealer) Open(b LTBlock) (LTBlock, error) {
+	data, err := s.aead.Open(nil, s.nonce(b.BlockCode), b.Data, s.additionalData(b.BlockCode))
+	if err != nil {
+		return LTBlock{}, ErrSymbolAuth
+	}
+	return LTBlock{BlockCode: b.BlockCode, Data: data}, nil
+}
+
+// OpenBlocks opens each of the sealed blocks, and returns those which
+// authenticate along with the number rejected.
+func (s *SymbolSealer) OpenBlocks(blocks []LTBlock) ([]LTBlock, int) {
+	opened := make([]LTBlock, 0, len(blocks))
+	rejected := 0
+	for _, b := range blocks {
+		o, err := s.Open(b)
+		if err != nil {
+			rejected++
+			continue
+		}
+		opened = append(opened, o)
+	}
+	return opened, rejected
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+func TestSymbolSealer(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSymbolSealer(aead, 7)
+	if err != nil {
+		t.Fatalf("NewSymbolSealer failed: %v", err)
+	}
+	other, _ := NewSymbolSealer(aead, 8)
+
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	codec := NewRaptorCodec(13, 2)
+	var ids []int64
+	for i := int64(0); i < 20; i++ {
+		ids = append(ids, i)
+	}
+	var sealed []LTBlock
+	for _, b := range EncodeLTBlocks(append([]byte(nil), message...), ids, codec) {
+		sealed = append(sealed, s.Seal(b))
+	}
+
+	// Tampered data, a changed ID and a block from another object are all
+	// rejected.
+	sealed[0].Data[0] ^= 1
+	sealed[1].BlockCode = 100
+	sealed[2] = other.Seal(LTBlock{BlockCode: 2, Data: []byte("xx")})
+
+	opened, rejected := s.OpenBlocks(sealed)
+	if rejected != 3 || len(opened) != 17 {
+		t.Fatalf("Opened %d blocks and rejected %d, want 17 and 3", len(opened), rejected)
+	}
+	d := codec.NewDecoder(len(message))
+	if !d.AddBlocks(opened) {
+		t.Fatalf("Decoder not determined with 17 authenticated blocks")
+	}
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("Decoded %q, want %q", decoded, message)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// Protocols built on the package need several independent pseudo-random
+// seeds per object: for the codec (NewSeededLubyCodec, NewGrowthCodec), for
+// the order of systematic symbols (ShuffledSystematicOrder), and for Online
+// code auxiliary blocks (NewOnlineCodec, whose check blocks are picked from
+// their IDs alone). Deriving them all from one session secret with HKDF,
+// under distinct labels, keeps them independent and reproducible at both
+// ends.
+//
+// Each seed is the first 8 bytes, big-endian, of HKDF-SHA256 with no salt,
+// keyed by the secret, with info "label" || 0x00 || object ID (8 bytes,
+// big-endian). Labels may not contain a NUL byte, so distinct (label, object)
+// pairs always give distinct info strings.
+
+// SeedLabel names the purpose of a derived seed.
+type SeedLabel string
+
+// The labels for the seeds the package's codecs and helpers use.
+const (
+	// CodecSeedLabel derives the seed passed to the constructor of a codec
+	// which picks the indices of its code blocks with it, such as
+	// NewSeededLubyCodec.
+	CodecSeedLabel SeedLabel = "gofountain/v1/codec"
+
+	// ESIPermutationLabel derives the seed of ShuffledSystematicOrder.
+	ESIPermutationLabel SeedLabel = "gofountain/v1/esi-permutation"
+
+	// AuxBlockSeedLabel derives the seed of Online code auxiliary blocks, the
+	// seed NewOnlineCodec takes (see NewDerivedOnlineCodec).
+	AuxBlockSeedLabel SeedLabel = "gofountain/v1/aux-blocks"
+)
+
+// DeriveSeed derives the seed for the given purpose and object from a session
+// secret. Applications may use their own labels for other purposes.
+func DeriveSeed(secret []byte, label SeedLabel, object uint64) (int64, error) {
+	if strings.IndexByte(string(label), 0) >= 0 {
+		return 0, fmt.Errorf("fountain: seed label %q contains a NUL byte", label)
+	}
+	info := append([]byte(label), 0)
+	info = ByteOrder.AppendUint64(info, object)
+	key, err := hkdf.Key(sha256.New, secret, nil, string(info), 8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(ByteOrder.Uint64(key)), nil
+}
+
+// NewDerivedOnlineCodec creates an Online codec (see NewOnlineCodec) for the
+// given object, whose auxiliary blocks are picked with the seed derived from a
+// session secret under AuxBlockSeedLabel.
+func NewDerivedOnlineCodec(secret []byte, object uint64, sourceBlocks int, epsilon float64, quality int) (Codec, error) {
+	seed, err := DeriveSeed(secret, AuxBlockSeedLabel, object)
+	if err != nil {
+		return nil, err
+	}
+	return NewOnlineCodec(sourceBlocks, epsilon, quality, seed), nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDeriveSeed(t *testing.T) {
+	secret := []byte("session secret")
+	seeds := make(map[int64]bool)
+	for _, label := range []SeedLabel{CodecSeedLabel, ESIPermutationLabel, AuxBlockSeedLabel} {
+		for object := uint64(0); object < 100; object++ {
+			seed, err := DeriveSeed(secret, label, object)
+			if err != nil {
+				t.Fatalf("DeriveSeed(%q, %d) failed: %v", label, object, err)
+			}
+			if seeds[seed] {
+				t.Errorf("DeriveSeed(%q, %d) repeats an earlier seed", label, object)
+			}
+			seeds[seed] = true
+			if again, _ := DeriveSeed(secret, label, object); again != seed {
+				t.Errorf("DeriveSeed(%q, %d) isn't deterministic", label, object)
+			}
+		}
+	}
+
+	other, _ := DeriveSeed([]byte("another secret"), CodecSeedLabel, 0)
+	if seeds[other] {
+		t.Errorf("Different secrets derive the same seed")
+	}
+	if _, err := DeriveSeed(secret, "a\x00b", 0); err == nil {
+		t.Errorf("DeriveSeed accepted a label with a NUL byte")
+	}
+
+	// Pin the derivation, since both ends of a protocol depend on it. The
+	// value was computed independently from the HKDF definition in RFC 5869.
+	if seed, _ := DeriveSeed(secret, CodecSeedLabel, 1); seed != 2764223917150620634 {
+		t.Errorf("DeriveSeed(%q, 1) = %d, want 2764223917150620634", CodecSeedLabel, seed)
+	}
+}
+
+func TestNewDerivedOnlineCodec(t *testing.T) {
+	secret := []byte("session secret")
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c, err := NewDerivedOnlineCodec(secret, 3, 10, 0.3, 10)
+	if err != nil {
+		t.Fatalf("NewDerivedOnlineCodec failed: %v", err)
+	}
+	seed, _ := DeriveSeed(secret, AuxBlockSeedLabel, 3)
+	if want := NewOnlineCodec(10, 0.3, 10, seed); !reflect.DeepEqual(c, want) {
+		t.Errorf("NewDerivedOnlineCodec = %+v, want %+v", c, want)
+	}
+
+	// Another object's codec picks other auxiliary blocks, so its code blocks
+	// differ, though they have the same indices.
+	other, _ := NewDerivedOnlineCodec(secret, 4, 10, 0.3, 10)
+	differ := false
+	for id := int64(0); id < 20; id++ {
+		a := EncodeLTBlocksCopy(message, []int64{id}, c)[0]
+		b := EncodeLTBlocksCopy(message, []int64{id}, other)[0]
+		differ = differ || !bytes.Equal(a.Data, b.Data)
+	}
+	if !differ {
+		t.Error("codecs of two objects generate the same code blocks")
+	}
+}
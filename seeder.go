@@ -0,0 +1,75 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"math"
+)
+
+// In peer-assisted distribution, a receiver which has decoded an object goes
+// on to serve it to other peers. RU10 code blocks are interchangeable and
+// their IDs unbounded, so any number of seeders can generate code blocks for
+// the same object independently, as long as each draws its IDs from its own
+// namespace. A seeder reuses the intermediate symbols its decoder recovered,
+// so it is ready to send immediately after decoding.
+
+// Seeder generates code blocks for an object a receiver has decoded.
+type Seeder struct {
+	encoder   *ltEncoder
+	namespace int64
+	next      int64
+}
+
+// BecomeSeeder creates a Seeder for the object decoded by a determined RU10
+// decoder. Its code blocks have IDs namespace<<32 + n for n = 0, 1, ...; give
+// every seeder of an object (including the original sender, which by
+// convention uses namespace 0) a different namespace, between 0 and
+// math.MaxInt32, so their code blocks are distinct.
+func BecomeSeeder(d Decoder, namespace uint32) (*Seeder, error) {
+	r, ok := d.(*ru10Decoder)
+	if !ok {
+		return nil, fmt.Errorf("fountain: BecomeSeeder doesn't support %T", d)
+	}
+	if namespace > math.MaxInt32 {
+		return nil, fmt.Errorf("fountain: seeder namespace %d exceeds %d", namespace, math.MaxInt32)
+	}
+	symbols, err := IntermediateSymbols(d)
+	if err != nil {
+		return nil, err
+	}
+	source := make([]block, len(symbols))
+	for i := range symbols {
+		source[i] = block{data: symbols[i]}
+	}
+	compactZeroBlocks(source)
+	return &Seeder{
+		encoder:   &ltEncoder{codec: r.codec, source: source},
+		namespace: int64(namespace) << 32,
+	}, nil
+}
+
+// Generate returns the code block with the given ID, which needn't be in the
+// seeder's namespace. Seeder implements Encoder.
+func (s *Seeder) Generate(id int64) LTBlock {
+	return s.encoder.Generate(id)
+}
+
+// Next returns the next code block of the seeder's namespace.
+func (s *Seeder) Next() LTBlock {
+	id := s.namespace + s.next
+	s.next++
+	return s.Generate(id)
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBecomeSeeder(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewRU10Codec(13, 2)
+
+	d := c.NewDecoder(len(message))
+	if _, err := BecomeSeeder(d, 1); err == nil {
+		t.Errorf("BecomeSeeder of an undetermined decoder succeeded")
+	}
+	for id := int64(0); !d.AddBlocks(EncodeLTBlocksCopy(message, []int64{id}, c)); id++ {
+	}
+
+	s, err := BecomeSeeder(d, 7)
+	if err != nil {
+		t.Fatalf("BecomeSeeder failed: %v", err)
+	}
+	peer := c.NewDecoder(len(message))
+	for i := 0; i < 100; i++ {
+		b := s.Next()
+		if want := int64(7)<<32 + int64(i); b.BlockCode != want {
+			t.Fatalf("code block %d has ID %d, want %d", i, b.BlockCode, want)
+		}
+		if want := EncodeLTBlocksCopy(message, []int64{b.BlockCode}, c)[0]; !reflect.DeepEqual(b, want) {
+			t.Errorf("seeded block %v, want %v", b, want)
+		}
+		if peer.AddBlocks([]LTBlock{b}) {
+			break
+		}
+	}
+	if decoded := peer.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("peer decoded %q, want %q", decoded, message)
+	}
+
+	if _, err := BecomeSeeder(NewRaptorCodec(13, 2).NewDecoder(10), 1); err == nil {
+		t.Errorf("BecomeSeeder accepted a raptor decoder")
+	}
+}
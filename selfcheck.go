@@ -0,0 +1,127 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Services can call SelfCheck at startup, for instance from a readiness
+// probe, to make sure the package works on the host it was deployed to: that
+// the XOR kernel selected for the CPU computes the right thing, that the PRNG
+// produces the sequence peers on other hosts expect, and that every codec
+// round-trips messages. The checks use small parameters and take milliseconds.
+
+// SelfCheckResult is the outcome of one check run by SelfCheck.
+type SelfCheckResult struct {
+	// Name identifies the check: "xor", "mersenne", or a codec's name.
+	Name string
+
+	// Detail describes what was checked, such as the XOR kernel in use.
+	Detail string
+
+	// Err is the failure found, or nil if the check passed.
+	Err error
+
+	// Duration is how long the check took.
+	Duration time.Duration
+}
+
+// selfCheckCodecs returns the codecs SelfCheck round-trips, by name.
+func selfCheckCodecs() []struct {
+	name  string
+	codec Codec
+} {
+	return []struct {
+		name  string
+		codec Codec
+	}{
+		{"luby", NewSeededLubyCodec(10, 99, SolitonDistribution(10))},
+		{"binary", NewBinaryCodec(10)},
+		{"online", NewOnlineCodec(10, 0.3, 10, 200)},
+		{"raptor", NewRaptorCodec(10, 4)},
+		{"ru10", NewRU10Codec(10, 4)},
+	}
+}
+
+// SelfCheck runs the package's self-test and returns the result of each
+// check, along with an error joining the failures, if any.
+func SelfCheck() ([]SelfCheckResult, error) {
+	var results []SelfCheckResult
+	run := func(name, detail string, check func() error) {
+		start := time.Now()
+		err := check()
+		results = append(results, SelfCheckResult{Name: name, Detail: detail, Err: err, Duration: time.Since(start)})
+	}
+
+	run("xor", xorKernel(), checkXorKernel)
+	run("mersenne", "MT19937 reference output", checkMersenne)
+	for _, c := range selfCheckCodecs() {
+		run(c.name, fmt.Sprintf("%d source blocks", c.codec.SourceBlocks()), func() error {
+			for _, message := range ConformanceMessages(c.codec.SourceBlocks()) {
+				if err := RoundTrip(c.codec, message, nil, 500); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("fountain: self-check %s: %w", r.Name, r.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// checkXorKernel compares xorBytes with the generic version, for lengths and
+// alignments covering the kernels' bulk loops and tails.
+func checkXorKernel() error {
+	src := make([]byte, 300)
+	for i := range src {
+		src[i] = byte(i*31 + 7)
+	}
+	for offset := 0; offset < 4; offset++ {
+		for n := 0; n+offset <= 200; n++ {
+			got := make([]byte, 300)
+			want := make([]byte, 300)
+			for i := range got {
+				got[i] = byte(i * 13)
+				want[i] = got[i]
+			}
+			xorBytes(got[offset:], src[:n])
+			xorBytesGeneric(want[offset:], src[:n])
+			if !bytes.Equal(got, want) {
+				return fmt.Errorf("%s kernel XORed %d bytes at offset %d incorrectly", xorKernel(), n, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// checkMersenne checks the first output of MT19937 with the reference seed
+// against the value published by its authors.
+func checkMersenne() error {
+	const want uint32 = 3499211612
+	if got := NewMersenneTwister(5489).(*MersenneTwister).Uint32(); got != want {
+		return fmt.Errorf("first output for seed 5489 is %d, want %d", got, want)
+	}
+	return nil
+}
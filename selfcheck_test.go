@@ -0,0 +1,36 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"testing"
+)
+
+func TestSelfCheck(t *testing.T) {
+	results, err := SelfCheck()
+	if err != nil {
+		t.Fatalf("SelfCheck failed: %v", err)
+	}
+	names := map[string]bool{}
+	for _, r := range results {
+		t.Logf("%s (%s): %v in %v", r.Name, r.Detail, r.Err, r.Duration)
+		names[r.Name] = true
+	}
+	for _, name := range []string{"xor", "mersenne", "luby", "binary", "online", "raptor", "ru10"} {
+		if !names[name] {
+			t.Errorf("SelfCheck didn't run the %s check", name)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Decoders modify the data of the code blocks they are given in place, so a
+// code block feeding several decoders (for instance a base layer symbol which
+// is part of two objects under layered delivery) would normally have to be
+// copied for each of them. A SharedSymbol is a reference counted code block
+// which decoders borrow instead: each keeps a reference while it uses the
+// data, and copies it only if it needs to modify it. Once every decoder has
+// finished with it, the symbol's buffer can be reused.
+
+// SharedSymbol is a reference counted code block whose data may be shared by
+// several decoders. It is safe for concurrent use.
+type SharedSymbol struct {
+	block LTBlock
+	refs  int32
+	free  func(data []byte)
+}
+
+// NewSharedSymbol wraps a code block in a SharedSymbol holding one reference,
+// which belongs to the caller. When the last reference is released, free (if
+// not nil) is called with the block's data, for instance to return it to a
+// buffer pool. The data must not be modified while the symbol is referenced.
+func NewSharedSymbol(b LTBlock, free func(data []byte)) *SharedSymbol {
+	return &SharedSymbol{block: b, refs: 1, free: free}
+}
+
+// Block returns the code block. Its data must not be modified.
+func (s *SharedSymbol) Block() LTBlock {
+	return s.block
+}
+
+// Retain adds a reference to the symbol and returns it.
+func (s *SharedSymbol) Retain() *SharedSymbol {
+	atomic.AddInt32(&s.refs, 1)
+	return s
+}
+
+// Release drops a reference to the symbol.
+func (s *SharedSymbol) Release() {
+	n := atomic.AddInt32(&s.refs, -1)
+	if n < 0 {
+		panic("fountain: SharedSymbol released more often than retained")
+	}
+	if n == 0 && s.free != nil {
+		s.free(s.block.Data)
+	}
+}
+
+// Refs returns the number of references to the symbol.
+func (s *SharedSymbol) Refs() int {
+	return int(atomic.LoadInt32(&s.refs))
+}
+
+// AddSharedBlocks adds shared code blocks to a decoder created by one of the
+// package's codecs, like AddBlocks, but without copying their data. The
+// decoder retains each symbol until it is compacted: when it becomes
+// determined, or when Compact is called, which should be done before an
+// undetermined decoder is abandoned. Symbols given to an already determined
+// decoder are copied instead. Returns whether the decoder is determined, and
+// an error if the decoder is of an unknown type.
+func AddSharedBlocks(d Decoder, symbols []*SharedSymbol) (bool, error) {
+	m := decoderMatrix(d)
+	if m == nil {
+		return false, fmt.Errorf("fountain: AddSharedBlocks doesn't support %T", d)
+	}
+	blocks := make([]LTBlock, len(symbols))
+	for i, s := range symbols {
+		blocks[i] = s.block
+		if m.complete {
+			blocks[i].Data = append([]byte(nil), blocks[i].Data...)
+		}
+	}
+	if m.complete {
+		return d.AddBlocks(blocks), nil
+	}
+
+	for _, s := range symbols {
+		m.shared = append(m.shared, s.Retain())
+	}
+	m.borrowing = true
+	defer func() { m.borrowing = false }()
+	return d.AddBlocks(blocks), nil
+}
+
+// releaseShared drops the matrix's references to SharedSymbols.
+func (m *sparseMatrix) releaseShared() {
+	for _, s := range m.shared {
+		s.Release()
+	}
+	m.shared = nil
+}
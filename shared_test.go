@@ -0,0 +1,85 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSharedSymbols(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	c := NewRU10Codec(10, 1)
+
+	freed := 0
+	var symbols []*SharedSymbol
+	var originals []LTBlock
+	for _, b := range EncodeLTBlocksCopy(message, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, c) {
+		originals = append(originals, LTBlock{BlockCode: b.BlockCode, Data: append([]byte(nil), b.Data...)})
+		symbols = append(symbols, NewSharedSymbol(b, func([]byte) { freed++ }))
+	}
+
+	decoders := []Decoder{c.NewDecoder(len(message)), c.NewDecoder(len(message))}
+	for _, d := range decoders {
+		determined := false
+		for i := 0; i < len(symbols) && !determined; i++ {
+			var err error
+			if determined, err = AddSharedBlocks(d, symbols[i:i+1]); err != nil {
+				t.Fatalf("AddSharedBlocks failed: %v", err)
+			}
+		}
+		if !determined {
+			t.Fatalf("decoder not determined")
+		}
+		if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+			t.Errorf("decoded %q, want %q", decoded, message)
+		}
+	}
+
+	for i, s := range symbols {
+		if !bytes.Equal(s.Block().Data, originals[i].Data) {
+			t.Errorf("symbol %d was modified by the decoders", i)
+		}
+		if s.Refs() != 1 {
+			t.Errorf("symbol %d has %d references after decoding, want 1", i, s.Refs())
+		}
+		s.Release()
+	}
+	if freed != len(symbols) {
+		t.Errorf("%d symbols freed, want %d", freed, len(symbols))
+	}
+}
+
+func TestSharedSymbolsCompact(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz")
+	c := NewBinaryCodec(10)
+	s := NewSharedSymbol(EncodeLTBlocksCopy(message, []int64{7}, c)[0], nil)
+
+	d := c.NewDecoder(len(message))
+	if _, err := AddSharedBlocks(d, []*SharedSymbol{s}); err != nil {
+		t.Fatalf("AddSharedBlocks failed: %v", err)
+	}
+	if s.Refs() != 2 {
+		t.Errorf("symbol has %d references, want 2", s.Refs())
+	}
+	Compact(d)
+	if s.Refs() != 1 {
+		t.Errorf("symbol has %d references after Compact, want 1", s.Refs())
+	}
+
+	if _, err := AddSharedBlocks(NewQuarantineDecoder(d, nil, 1), []*SharedSymbol{s}); err == nil {
+		t.Errorf("AddSharedBlocks succeeded on an unknown decoder")
+	}
+}
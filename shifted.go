@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import "math"
+
+// A receiver resuming an interrupted download (see WarmStart) already holds
+// some of the source blocks. Plain LT code blocks are wasted on it: the
+// degree distribution is tuned for a receiver which knows nothing, so most low
+// degree blocks only cover blocks it already has. The shifted LT code of
+// Hagedorn, Agarwal, Starobinski and Trachtenberg ("Rateless Coding with
+// Feedback", 2009) raises each degree so that, once the known blocks are
+// substituted, a code block's remaining degree follows the distribution tuned
+// for the number of blocks still missing.
+
+// ShiftedDistribution returns the CDF of the degree distribution cdf shifted
+// for a receiver already holding known of n source blocks: each degree d is
+// raised to ceil(d*n/(n-known)), capped at n, so that on average d of its
+// blocks are unknown to the receiver. cdf should be the distribution for the
+// n-known blocks missing, for instance RobustSolitonDistribution(n-known, ...).
+// The CDF is one-based, like cdf, and covers degrees 1 to n.
+func ShiftedDistribution(cdf []float64, n, known int) []float64 {
+	unknown := max(1, n-known)
+	shifted := make([]float64, n+1)
+	for d := 1; d < len(cdf); d++ {
+		i := min(n, int(math.Ceil(float64(d)*float64(n)/float64(unknown))))
+		shifted[i] += cdf[d] - cdf[d-1]
+	}
+	for i := 1; i < len(shifted); i++ {
+		shifted[i] += shifted[i-1]
+	}
+	return shifted
+}
+
+// shiftedDelta and shiftedSpread are the parameters of the robust soliton
+// distribution used by NewShiftedLubyCodec: the bound on the probability of
+// failing to decode, and Luby's constant c, which places the spike at degree
+// sqrt(n)/(c*ln(n/delta)).
+const (
+	shiftedDelta  = 0.5
+	shiftedSpread = 0.1
+)
+
+// NewShiftedLubyCodec creates a seeded Luby Transform codec (see
+// NewSeededLubyCodec) for a receiver which already holds known of the
+// sourceBlocks source blocks, loaded with WarmStart. Its degree distribution
+// is the robust soliton distribution for the sourceBlocks-known blocks
+// missing, shifted by ShiftedDistribution, so the receiver needs about as many
+// code blocks as a fresh download of just the missing blocks would, rather
+// than a large fraction of the whole message.
+//
+// The decoder must be created from a codec with the same parameters, so the
+// sender has to learn known from the receiver, say in its resume request. The
+// positions of the known blocks don't matter.
+func NewShiftedLubyCodec(sourceBlocks, known int, seed int64) Codec {
+	unknown := max(1, sourceBlocks-known)
+	m := math.Sqrt(float64(unknown)) / (shiftedSpread * math.Log(float64(unknown)/shiftedDelta))
+	spike := min(unknown, max(1, int(math.Round(m))))
+	cdf := RobustSolitonDistribution(unknown, spike, shiftedDelta)
+	return NewSeededLubyCodec(sourceBlocks, seed, ShiftedDistribution(cdf, sourceBlocks, known))
+}
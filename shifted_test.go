@@ -0,0 +1,96 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestShiftedDistribution(t *testing.T) {
+	cdf := SolitonDistribution(5)
+	shifted := ShiftedDistribution(cdf, 20, 15)
+	if err := checkDegreeCDF(shifted); err != nil {
+		t.Fatalf("ShiftedDistribution: %v", err)
+	}
+	if len(shifted) != 21 {
+		t.Fatalf("len = %d, want 21", len(shifted))
+	}
+	// A quarter of the blocks are unknown, so degree d moves to 4d.
+	for d := 1; d <= 5; d++ {
+		want := cdf[d] - cdf[d-1]
+		if got := shifted[4*d] - shifted[4*d-1]; math.Abs(got-want) > 1e-12 {
+			t.Errorf("pdf(%d) = %v, want pdf(%d) of the soliton distribution, %v", 4*d, got, d, want)
+		}
+	}
+
+	// Nothing known leaves the distribution as it is.
+	same := ShiftedDistribution(cdf, 5, 0)
+	for i := range cdf {
+		if math.Abs(same[i]-cdf[i]) > 1e-12 {
+			t.Errorf("unshifted CDF[%d] = %v, want %v", i, same[i], cdf[i])
+		}
+	}
+}
+
+// resumeBlocks returns how many code blocks a decoder which has preloaded the
+// source blocks for which known returns true needs to decode the message.
+func resumeBlocks(t *testing.T, c Codec, message []byte, known func(int) bool) int {
+	t.Helper()
+	d := c.NewDecoder(len(message))
+	p := sourcePartition(len(message), c.SourceBlocks())
+	for i := 0; i < c.SourceBlocks(); i++ {
+		if known(i) {
+			d.(sourcePreloader).preloadSource(i, append([]byte(nil), message[p.Offset(i):p.Offset(i+1)]...))
+		}
+	}
+	e := c.NewEncoder(message)
+	for id := int64(0); id < 10000; id++ {
+		if d.AddBlocks([]LTBlock{e.Generate(id)}) {
+			if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+				t.Fatalf("%T: decoded message differs", c)
+			}
+			return int(id + 1)
+		}
+	}
+	t.Fatalf("%T: not decoded after 10000 code blocks", c)
+	return 0
+}
+
+func TestShiftedLubyCodecResume(t *testing.T) {
+	const k, known = 200, 160
+	message := make([]byte, 20*k)
+	for i := range message {
+		message[i] = byte(i * 31)
+	}
+	// Every fifth source block is missing.
+	have := func(i int) bool { return i%5 != 0 }
+
+	plain, shifted := 0, 0
+	const trials = 5
+	for seed := int64(0); seed < trials; seed++ {
+		plain += resumeBlocks(t, NewShiftedLubyCodec(k, 0, seed), message, have)
+		shifted += resumeBlocks(t, NewShiftedLubyCodec(k, known, seed), message, have)
+	}
+	t.Logf("resuming with %d of %d blocks missing: %d code blocks unshifted, %d shifted",
+		k-known, k, plain/trials, shifted/trials)
+	if shifted >= plain/2 {
+		t.Errorf("shifted codec needed %d code blocks on average, unshifted %d; want under half", shifted/trials, plain/trials)
+	}
+	if shifted/trials > 2*(k-known) {
+		t.Errorf("shifted codec needed %d code blocks on average for %d missing blocks", shifted/trials, k-known)
+	}
+}
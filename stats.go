@@ -0,0 +1,170 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Diagnose and MeasureDecodeCost describe one decoder after the fact. A
+// production system exporting metrics instead wants to hear about the work of
+// all its encoders and decoders as it is done, which is what a Stats collector
+// attached to them with SetEncoderStats and SetDecoderStats does.
+
+// Stats collects metrics of encoders and decoders. Its methods are called
+// synchronously from the encoder or decoder, so they should be cheap, and if
+// the collector is shared between goroutines, safe for concurrent use.
+type Stats interface {
+	// SymbolsGenerated is called when an encoder has generated n code blocks.
+	SymbolsGenerated(n int)
+
+	// XORBytes is called with the number of bytes an encoder or decoder has
+	// XORed.
+	XORBytes(n int64)
+
+	// EquationAdded is called when a decoder adds an equation, including a
+	// precode constraint, to its decode matrix.
+	EquationAdded()
+
+	// RedundantSymbol is called when an equation a decoder adds turns out to
+	// hold no new information and is discarded.
+	RedundantSymbol()
+
+	// Reduced is called when a decoder has solved its decode matrix, with the
+	// time that took.
+	Reduced(d time.Duration)
+}
+
+// SetEncoderStats attaches a Stats collector to an encoder created by one of
+// the package's codecs, which reports the code blocks it generates and the
+// bytes it XORs to do so. A nil collector detaches it. Returns false if the
+// encoder is of an unknown type.
+func SetEncoderStats(e Encoder, s Stats) bool {
+	le, ok := e.(*ltEncoder)
+	if !ok {
+		return false
+	}
+	le.stats = s
+	return true
+}
+
+// SetDecoderStats attaches a Stats collector to a decoder created by one of
+// the package's codecs. Equations the decoder has already added, such as
+// those of its precode, aren't reported. A nil collector detaches it. Returns
+// false if the decoder is of an unknown type.
+func SetDecoderStats(d Decoder, s Stats) bool {
+	m := decoderMatrix(d)
+	if m == nil {
+		return false
+	}
+	m.collector = s
+	return true
+}
+
+// StatsCounter is a Stats collector which totals what it is told. It is safe
+// for concurrent use, so one counter can collect the metrics of many encoders
+// and decoders.
+type StatsCounter struct {
+	symbols, xorBytes, equations, redundant, reduceTime atomic.Int64
+}
+
+// SymbolsGenerated adds n to the count of code blocks generated.
+func (c *StatsCounter) SymbolsGenerated(n int) {
+	c.symbols.Add(int64(n))
+}
+
+// XORBytes adds n to the count of bytes XORed.
+func (c *StatsCounter) XORBytes(n int64) {
+	c.xorBytes.Add(n)
+}
+
+// EquationAdded counts an equation added.
+func (c *StatsCounter) EquationAdded() {
+	c.equations.Add(1)
+}
+
+// RedundantSymbol counts a redundant equation.
+func (c *StatsCounter) RedundantSymbol() {
+	c.redundant.Add(1)
+}
+
+// Reduced adds d to the total time spent solving decode matrices.
+func (c *StatsCounter) Reduced(d time.Duration) {
+	c.reduceTime.Add(int64(d))
+}
+
+// StatsSnapshot is the totals of a StatsCounter at some moment.
+type StatsSnapshot struct {
+	// SymbolsGenerated is the number of code blocks generated, and XORBytes
+	// the number of bytes XORed by encoders and decoders.
+	SymbolsGenerated int
+	XORBytes         int64
+
+	// Equations is the number of equations added to decode matrices, and
+	// Redundant the number of those which were discarded.
+	Equations, Redundant int
+
+	// ReduceTime is the total time spent solving decode matrices.
+	ReduceTime time.Duration
+}
+
+// Snapshot returns the counter's totals.
+func (c *StatsCounter) Snapshot() StatsSnapshot {
+	return StatsSnapshot{
+		SymbolsGenerated: int(c.symbols.Load()),
+		XORBytes:         c.xorBytes.Load(),
+		Equations:        int(c.equations.Load()),
+		Redundant:        int(c.redundant.Load()),
+		ReduceTime:       time.Duration(c.reduceTime.Load()),
+	}
+}
+
+// generateCounted generates the code block with the given ID, reporting it
+// and the bytes XORed to the encoder's collector. The Reed-Solomon codec
+// combines blocks with GF(2^8) arithmetic rather than XOR, so only the block
+// is reported for it.
+func (e *ltEncoder) generateCounted(id int64) LTBlock {
+	if g, ok := symbolGeneratorOf(e.codec); ok {
+		e.stats.SymbolsGenerated(1)
+		return e.block(id, g.generateSymbol(e.source, id))
+	}
+	return e.generate(id, e.codec.PickIndices(id))
+}
+
+// countGenerated reports a code block with the given intermediate block
+// indices, and the bytes XORed to generate it, to the encoder's collector, if
+// it has one.
+func (e *ltEncoder) countGenerated(indices []int) {
+	if e.stats == nil {
+		return
+	}
+	var n int64
+	for _, i := range indices {
+		if i < len(e.source) {
+			n += int64(len(e.source[i].data))
+		}
+	}
+	e.stats.XORBytes(n)
+	e.stats.SymbolsGenerated(1)
+}
+
+// reportXOR reports the bytes the matrix has XORed since its XORBytes cost was
+// before to its collector, if it has one.
+func (m *sparseMatrix) reportXOR(before int64) {
+	if m.collector != nil && m.cost.XORBytes > before {
+		m.collector.XORBytes(m.cost.XORBytes - before)
+	}
+}
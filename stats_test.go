@@ -0,0 +1,66 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	message := make([]byte, 4000)
+	for i := range message {
+		message[i] = byte(i * 13)
+	}
+	for _, c := range []Codec{NewRaptorCodec(40, 4), NewBinaryCodec(30), NewOnlineCodec(100, 0.3, 10, 5)} {
+		var counter StatsCounter
+		e := c.NewEncoder(message)
+		if !SetEncoderStats(e, &counter) {
+			t.Fatalf("%T: SetEncoderStats failed", c)
+		}
+		d := c.NewDecoder(len(message))
+		if !SetDecoderStats(d, &counter) {
+			t.Fatalf("%T: SetDecoderStats failed", c)
+		}
+		var blocks []LTBlock
+		for id := int64(0); id < 200; id++ {
+			blocks = append(blocks, e.Generate(id))
+			if d.AddBlocks(blocks[id : id+1]) {
+				break
+			}
+		}
+		// Add the last block again, which is redundant.
+		d.AddBlocks(blocks[len(blocks)-1:])
+		if !bytes.Equal(d.Decode(), message) {
+			t.Fatalf("%T: decoded message doesn't match", c)
+		}
+
+		got := counter.Snapshot()
+		if got.SymbolsGenerated != len(blocks) {
+			t.Errorf("%T: SymbolsGenerated = %d, want %d", c, got.SymbolsGenerated, len(blocks))
+		}
+		if got.Equations != len(blocks)+1 || got.Redundant < 1 {
+			t.Errorf("%T: %d equations, %d redundant; want %d and at least 1",
+				c, got.Equations, got.Redundant, len(blocks)+1)
+		}
+		if got.XORBytes <= 0 || got.ReduceTime <= 0 {
+			t.Errorf("%T: %+v, want XORs and reduce time", c, got)
+		}
+	}
+
+	if SetEncoderStats(nil, &StatsCounter{}) || SetDecoderStats(nil, &StatsCounter{}) {
+		t.Errorf("Attached stats to nil encoder or decoder")
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// The decoders keep their equation matrix triangular as code blocks arrive
+// (see sparseMatrix.addEquation), and solve it by back-substitution when the
+// message is decoded. A Strategy is an algorithm for that back-substitution.
+// Every strategy produces the same decoded message; they differ in the order
+// of their work, and so in speed and in how much is solved if the decode is
+// abandoned partway (see DecodeContext). All the strategies here are
+// deterministic: for a given set of code blocks they perform the same
+// operations in the same order every time.
+//
+// Strategies can also be implemented outside the package, using the
+// operations of Reduction, which keep the matrix consistent at every step.
+
+// Strategy is a back-substitution algorithm for a decoder's equation matrix.
+// See SetStrategy.
+type Strategy interface {
+	// Reduce solves the solvable rows of the matrix, leaving each with only
+	// its leading coefficient. It should return early if r.Cancelled().
+	Reduce(r *Reduction)
+}
+
+// Reduction gives a Strategy access to the decode matrix it reduces. Row i of
+// the matrix is an equation: the XOR of the blocks its coefficients name,
+// the first of which is always block i, equals the row's value. The other
+// coefficients are all greater than i. A row is solved once block i is its
+// only coefficient, and solvable if the rows of all its other coefficients
+// are solvable in turn.
+type Reduction struct {
+	m     *sparseMatrix
+	ok    []bool
+	steps int
+}
+
+// Rows returns the number of rows of the matrix.
+func (r *Reduction) Rows() int {
+	return len(r.m.coeff)
+}
+
+// Solvable reports whether row i can be solved.
+func (r *Reduction) Solvable(i int) bool {
+	return r.ok[i]
+}
+
+// Solved reports whether row i has been solved.
+func (r *Reduction) Solved(i int) bool {
+	return len(r.m.coeff[i]) == 1
+}
+
+// Coefficients returns the coefficients of row i, in increasing order. It is
+// empty if the row has no equation yet. The slice must not be modified, and
+// is only valid until the row is next changed.
+func (r *Reduction) Coefficients(i int) []int {
+	r.m.cost.Scans += int64(len(r.m.coeff[i]))
+	return r.m.coeff[i]
+}
+
+// Substitute XORs solved row j into row i, which must refer to it, removing
+// j from row i's coefficients.
+func (r *Reduction) Substitute(i, j int) error {
+	m := r.m
+	if i < 0 || j < 0 || i >= len(m.coeff) || j >= len(m.coeff) {
+		return fmt.Errorf("fountain: substitution of row %d into row %d of %d", j, i, len(m.coeff))
+	}
+	if len(m.coeff[j]) != 1 {
+		return fmt.Errorf("fountain: row %d isn't solved", j)
+	}
+	row := m.coeff[i]
+	k := sort.SearchInts(row, j)
+	if k == 0 || k == len(row) || row[k] != j {
+		return fmt.Errorf("fountain: row %d doesn't refer to row %d", i, j)
+	}
+	m.v[i].xor(m.v[j])
+	m.cost.RowOps++
+	m.cost.XORBytes += int64(len(m.v[j].data))
+	m.coeff[i] = append(row[:k:k], row[k+1:]...)
+	if b := m.rowBits(i); b != nil {
+		b.toggle(j)
+	}
+	if len(m.coeff[i]) == 1 {
+		m.dropBits(i)
+	}
+	return nil
+}
+
+// Cancelled reports whether the reduction is to be abandoned, because the
+// context of the decode is done (see DecodeContext). It is cheap enough to
+// call once per row.
+func (r *Reduction) Cancelled() bool {
+	r.steps++
+	return r.m.cancelled(r.steps)
+}
+
+// pushStrategy substitutes each solved row, from the bottom up, into every
+// row above which refers to it.
+type pushStrategy struct{}
+
+// pullStrategy solves each row, from the bottom up, by XORing in the solved
+// rows it refers to.
+type pullStrategy struct{}
+
+// weightStrategy solves the rows whose references are all solved, lightest
+// first.
+type weightStrategy struct{}
+
+// inactivationStrategy solves the rows which many others refer to as a dense
+// system, then the others by pulling.
+type inactivationStrategy struct{}
+
+// PushStrategy is the decoders' default strategy, which they use for whatever
+// they don't reduce as a dense system. Once a row is solved, it is XORed into
+// all the rows above it which refer to it. It scans the whole
+// matrix for each row, so its cost grows quadratically with the number of rows.
+var PushStrategy Strategy = pushStrategy{}
+
+// PullStrategy solves one row at a time by XORing the rows it refers to into
+// it. It performs the same XORs as PushStrategy, but only visits the
+// coefficients each row actually has, and writes to one destination row at a
+// time.
+var PullStrategy Strategy = pullStrategy{}
+
+// WeightStrategy solves the rows in order of weight: of the rows whose
+// references are all solved, it solves the one with the fewest coefficients
+// next. It performs the same XORs as PullStrategy, but if the decode is
+// abandoned partway, it has solved as many rows as the XORs made so far allow.
+var WeightStrategy Strategy = weightStrategy{}
+
+// InactivationStrategy is modeled on inactivation decoding. It inactivates
+// the rows which many others refer to, along with the rows they refer to in
+// turn, and solves those first, as one dense GF(2) system (see reduceCore);
+// it then solves the remaining rows, which are sparse, like PullStrategy.
+var InactivationStrategy Strategy = inactivationStrategy{}
+
+func (pushStrategy) Reduce(r *Reduction) {
+	m, ok := r.m, r.ok
+	for i := len(m.coeff) - 1; i >= 0; i-- {
+		if m.cancelled(i) {
+			pushAbandoned(m, i, ok)
+			return
+		}
+		if !ok[i] {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if !ok[j] {
+				continue
+			}
+			ci, cj := m.coeff[i], m.coeff[j]
+			if bj := m.rowBits(j); bj != nil {
+				m.cost.Scans++
+				if bj.has(ci[0]) {
+					m.v[j].xor(m.v[i])
+					m.cost.RowOps++
+					m.cost.XORBytes += int64(len(m.v[i].data))
+				}
+				continue
+			}
+			m.cost.Scans += int64(len(cj) - 1)
+			for k := 1; k < len(cj); k++ {
+				if cj[k] == ci[0] {
+					m.v[j].xor(m.v[i])
+					m.cost.RowOps++
+					m.cost.XORBytes += int64(len(m.v[i].data))
+					continue
+				}
+			}
+		}
+		// All but the leading coefficient in the rows have been reduced out.
+		m.coeff[i] = m.coeff[i][0:1]
+		m.dropBits(i)
+	}
+}
+
+// pushAbandoned restores the coefficients of the rows up to and including
+// row i, when a push reduction is abandoned before row i. The values of the
+// solved rows below have been XORed into the rows which referred to them, but
+// the coefficients of those rows are only trimmed once they are solved in turn.
+func pushAbandoned(m *sparseMatrix, i int, ok []bool) {
+	for j := 0; j <= i; j++ {
+		if !ok[j] || len(m.coeff[j]) < 2 {
+			continue
+		}
+		coeffs := []int{m.coeff[j][0]}
+		for _, c := range m.coeff[j][1:] {
+			if c <= i || !ok[c] {
+				coeffs = append(coeffs, c)
+			}
+		}
+		m.setRow(j, coeffs, m.v[j])
+	}
+}
+
+func (pullStrategy) Reduce(r *Reduction) {
+	m, ok := r.m, r.ok
+	for i := len(m.coeff) - 1; i >= 0; i-- {
+		if m.cancelled(i) {
+			return
+		}
+		if !ok[i] {
+			continue
+		}
+		// The rows referred to are below this one, so already solved.
+		m.cost.Scans += int64(len(m.coeff[i]) - 1)
+		for _, c := range m.coeff[i][1:] {
+			m.v[i].xor(m.v[c])
+			m.cost.RowOps++
+			m.cost.XORBytes += int64(len(m.v[c].data))
+		}
+		m.coeff[i] = m.coeff[i][0:1]
+		m.dropBits(i)
+	}
+}
+
+func (weightStrategy) Reduce(r *Reduction) {
+	m, ok := r.m, r.ok
+	// waiting counts the unsolved rows each row refers to, and referrers
+	// lists the rows which refer to each unsolved row.
+	waiting := make([]int, len(m.coeff))
+	referrers := make([][]int, len(m.coeff))
+	var ready rowHeap
+	for i, row := range m.coeff {
+		if !ok[i] || len(row) < 2 {
+			continue
+		}
+		for _, c := range row[1:] {
+			if len(m.coeff[c]) > 1 {
+				waiting[i]++
+				referrers[c] = append(referrers[c], i)
+			}
+		}
+		if waiting[i] == 0 {
+			ready.rows = append(ready.rows, i)
+		}
+	}
+	ready.m = m
+	heap.Init(&ready)
+	for ready.Len() > 0 {
+		if r.Cancelled() {
+			return
+		}
+		i := heap.Pop(&ready).(int)
+		m.cost.Scans += int64(len(m.coeff[i]) - 1)
+		for _, c := range m.coeff[i][1:] {
+			m.v[i].xor(m.v[c])
+			m.cost.RowOps++
+			m.cost.XORBytes += int64(len(m.v[c].data))
+		}
+		m.coeff[i] = m.coeff[i][0:1]
+		m.dropBits(i)
+		for _, j := range referrers[i] {
+			if waiting[j]--; waiting[j] == 0 {
+				heap.Push(&ready, j)
+			}
+		}
+	}
+}
+
+// rowHeap is a heap of rows ordered by weight, then by index.
+type rowHeap struct {
+	m    *sparseMatrix
+	rows []int
+}
+
+func (h *rowHeap) Len() int      { return len(h.rows) }
+func (h *rowHeap) Swap(i, j int) { h.rows[i], h.rows[j] = h.rows[j], h.rows[i] }
+func (h *rowHeap) Push(x any)    { h.rows = append(h.rows, x.(int)) }
+
+func (h *rowHeap) Less(i, j int) bool {
+	a, b := h.rows[i], h.rows[j]
+	if wa, wb := len(h.m.coeff[a]), len(h.m.coeff[b]); wa != wb {
+		return wa < wb
+	}
+	return a < b
+}
+
+func (h *rowHeap) Pop() any {
+	x := h.rows[len(h.rows)-1]
+	h.rows = h.rows[:len(h.rows)-1]
+	return x
+}
+
+// inactiveDivisor sets which rows InactivationStrategy inactivates: those
+// which at least one in inactiveDivisor of the matrix's rows refer to.
+const inactiveDivisor = 64
+
+func (inactivationStrategy) Reduce(r *Reduction) {
+	m, ok := r.m, r.ok
+	n := len(m.coeff)
+	refs := make([]int, n)
+	for i, row := range m.coeff {
+		if ok[i] && len(row) > 1 {
+			for _, c := range row[1:] {
+				refs[c]++
+			}
+		}
+	}
+	// The rows refer only to rows below them, so a single pass down the
+	// matrix adds the rows the inactive ones refer to.
+	inactive := make([]bool, n)
+	var rows []int
+	for i, row := range m.coeff {
+		if !ok[i] || len(row) < 2 {
+			continue
+		}
+		if refs[i]*inactiveDivisor >= n {
+			inactive[i] = true
+		}
+		if !inactive[i] {
+			continue
+		}
+		rows = append(rows, i)
+		for _, c := range row[1:] {
+			inactive[c] = true
+		}
+	}
+	if len(rows) > 0 {
+		m.reduceDense(rows)
+		if m.ctxErr != nil {
+			return
+		}
+	}
+	PullStrategy.Reduce(r)
+}
+
+// SetStrategy selects the back-substitution strategy of a decoder created by
+// one of the package's codecs, which then does all of the decoder's
+// reduction; nil restores the default. Any solvable rows the strategy leaves
+// unsolved, unless it was cancelled, are solved by PullStrategy afterwards.
+// Returns false if the decoder is of an unknown type.
+func SetStrategy(d Decoder, s Strategy) bool {
+	m := decoderMatrix(d)
+	if m == nil {
+		return false
+	}
+	m.strategy = s
+	return true
+}
@@ -0,0 +1,129 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStrategies(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789")
+	codecs := []Codec{
+		NewBinaryCodec(9),
+		NewOnlineCodec(9, 0.3, 10, 200),
+		NewRaptorCodec(9, 2),
+		NewRU10Codec(9, 2),
+	}
+	for _, c := range codecs {
+		var costs []DecodeCost
+		for _, s := range []Strategy{PushStrategy, PullStrategy, WeightStrategy, InactivationStrategy, substituteStrategy{}} {
+			d := c.NewDecoder(len(message))
+			if !SetStrategy(d, s) {
+				t.Fatalf("SetStrategy doesn't support %T", d)
+			}
+			for id := int64(0); id < 200; id++ {
+				if d.AddBlocks(EncodeLTBlocks(append([]byte(nil), message...), []int64{id}, c)) {
+					break
+				}
+			}
+			if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+				t.Errorf("%T with %T decoded %q, want %q", c, s, decoded, message)
+			}
+			cost := decoderMatrix(d).cost
+			// Only the comparisons made depend on the strategy.
+			cost.Scans = 0
+			costs = append(costs, cost)
+		}
+		for i := 1; i < len(costs); i++ {
+			if costs[i] != costs[0] {
+				t.Errorf("%T: strategy %d cost %+v, push strategy cost %+v", c, i, costs[i], costs[0])
+			}
+		}
+	}
+}
+
+// substituteStrategy is a Strategy which uses only the operations Reduction
+// exports: it substitutes each row, from the bottom up, into the rows above
+// which refer to it.
+type substituteStrategy struct{}
+
+func (substituteStrategy) Reduce(r *Reduction) {
+	for i := r.Rows() - 1; i >= 0; i-- {
+		if r.Cancelled() {
+			return
+		}
+		if !r.Solvable(i) {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if !r.Solvable(j) {
+				continue
+			}
+			for _, c := range r.Coefficients(j)[1:] {
+				if c == i {
+					if err := r.Substitute(j, i); err != nil {
+						panic(err)
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestReductionSubstitute(t *testing.T) {
+	m := sparseMatrix{
+		coeff: [][]int{{0, 1, 2}, {1, 2}, {2}},
+		v:     []block{{data: []byte{7}}, {data: []byte{6}}, {data: []byte{4}}},
+	}
+	r := &Reduction{m: &m, ok: m.solvable()}
+	for _, bad := range [][2]int{{0, 1}, {2, 0}, {1, 0}, {0, 3}, {-1, 2}} {
+		if err := r.Substitute(bad[0], bad[1]); err == nil {
+			t.Errorf("Substitute(%d, %d) succeeded", bad[0], bad[1])
+		}
+	}
+	if err := r.Substitute(1, 2); err != nil || !r.Solved(1) || m.v[1].data[0] != 2 {
+		t.Errorf("Substitute(1, 2) = %v, row 1 = (%v = %v); want it solved as 2", err, m.coeff[1], m.v[1].data)
+	}
+	if err := r.Substitute(1, 2); err == nil {
+		t.Errorf("Substitute(1, 2) succeeded twice")
+	}
+	r.Substitute(0, 2)
+	r.Substitute(0, 1)
+	if !r.Solved(0) || m.v[0].data[0] != 1 {
+		t.Errorf("Row 0 = (%v = %v), want it solved as 1", m.coeff[0], m.v[0].data)
+	}
+}
+
+// unsolvingStrategy is a Strategy which does nothing.
+type unsolvingStrategy struct{}
+
+func (unsolvingStrategy) Reduce(*Reduction) {}
+
+func TestStrategyLeavesRows(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789")
+	c := NewRaptorCodec(9, 2)
+	d := c.NewDecoder(len(message))
+	SetStrategy(d, unsolvingStrategy{})
+	for id := int64(0); id < 200; id++ {
+		if d.AddBlocks(EncodeLTBlocks(append([]byte(nil), message...), []int64{id}, c)) {
+			break
+		}
+	}
+	if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+		t.Errorf("Decoded %q, want %q", decoded, message)
+	}
+}
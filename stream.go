@@ -0,0 +1,157 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFinalized is returned when data is written to a StreamEncoder after
+// Finalize has been called.
+var ErrFinalized = errors.New("fountain: stream encoder is finalized")
+
+// ErrNotFinalized is returned when repair blocks are requested from a
+// StreamEncoder before Finalize has been called.
+var ErrNotFinalized = errors.New("fountain: stream encoder is not finalized")
+
+// StreamEncoder encodes an object which is still being produced. With a
+// systematic code such as Raptor, the code blocks with IDs below K are the
+// source symbols themselves, so each one can be transmitted as soon as its
+// data has been written, before K is known. When the object is complete, the
+// application calls Finalize, which fixes K and the code, after which repair
+// blocks can be generated, and calls the encoder's finalization callback.
+//
+// The object is padded with zeros to a whole number of symbols. The receiver
+// must create its decoder with a message length of K*symbolSize and trim the
+// decoded message to the object's length.
+type StreamEncoder struct {
+	symbolSize int
+	newCodec   func(sourceBlocks int) (Codec, error)
+	onFinalize func(c Codec, length int)
+
+	data    []byte
+	emitted int
+
+	// Set by Finalize.
+	length int
+	codec  Codec
+	source []block
+}
+
+// NewStreamEncoder creates an encoder for an object of unknown length split
+// into symbols of symbolSize bytes. newCodec is called by Finalize with the
+// number of source symbols, and must return a systematic codec (such as
+// NewRaptorCodecChecked does) whose code blocks 0..K-1 are the source
+// symbols, or an error if there can't be one for that many. onFinalize, if
+// not nil, is called once Finalize has fixed the code, with the codec and the
+// length of the object, for instance to announce them to receivers and start
+// sending repair blocks.
+func NewStreamEncoder(symbolSize int, newCodec func(sourceBlocks int) (Codec, error), onFinalize func(c Codec, length int)) *StreamEncoder {
+	return &StreamEncoder{symbolSize: symbolSize, newCodec: newCodec, onFinalize: onFinalize}
+}
+
+// Write appends p to the object. It returns ErrFinalized if the encoder has
+// been finalized.
+func (e *StreamEncoder) Write(p []byte) (int, error) {
+	if e.codec != nil {
+		return 0, ErrFinalized
+	}
+	e.data = append(e.data, p...)
+	return len(p), nil
+}
+
+// SourceBlocks returns the source symbols completed since the last call, as
+// code blocks whose IDs are their indices in the object. Before Finalize, only
+// complete symbols are returned; after it, the zero-padded last symbol is too.
+func (e *StreamEncoder) SourceBlocks() []LTBlock {
+	complete := len(e.data) / e.symbolSize
+	var blocks []LTBlock
+	for ; e.emitted < complete; e.emitted++ {
+		start := e.emitted * e.symbolSize
+		data := make([]byte, e.symbolSize)
+		copy(data, e.data[start:start+e.symbolSize])
+		blocks = append(blocks, LTBlock{BlockCode: int64(e.emitted), Data: data})
+	}
+	return blocks
+}
+
+// Finalize marks the end of the object, pads it to a whole number of symbols,
+// fixes the code, and calls the finalization callback. It returns the codec,
+// which the receiver must also use. Calling Finalize again returns the same
+// codec. If the symbol size isn't positive, or newCodec returns an error or a
+// codec without K source symbols, Finalize returns the error and the encoder
+// isn't finalized.
+func (e *StreamEncoder) Finalize() (Codec, error) {
+	if e.codec != nil {
+		return e.codec, nil
+	}
+	if e.symbolSize <= 0 {
+		return nil, fmt.Errorf("fountain: stream of %d-byte symbols", e.symbolSize)
+	}
+	k := max((len(e.data)+e.symbolSize-1)/e.symbolSize, 1)
+	codec, err := e.newCodec(k)
+	if err != nil {
+		return nil, err
+	}
+	if codec == nil {
+		return nil, fmt.Errorf("fountain: no stream codec for %d source symbols", k)
+	}
+	if n := codec.SourceBlocks(); n != k {
+		return nil, fmt.Errorf("fountain: stream codec for %d source symbols has %d", k, n)
+	}
+
+	e.length = len(e.data)
+	for len(e.data) < k*e.symbolSize {
+		e.data = append(e.data, 0)
+	}
+	e.codec = codec
+	// GenerateIntermediateBlocks may overwrite its input, and e.data still
+	// backs source blocks not yet returned.
+	message := make([]byte, len(e.data))
+	copy(message, e.data)
+	e.source = e.codec.GenerateIntermediateBlocks(message, k)
+	compactZeroBlocks(e.source)
+	if e.onFinalize != nil {
+		e.onFinalize(e.codec, e.length)
+	}
+	return e.codec, nil
+}
+
+// Length returns the length of the object before padding. It is only valid
+// once the encoder is finalized.
+func (e *StreamEncoder) Length() int {
+	return e.length
+}
+
+// Repair generates the code blocks with the given IDs, which are usually K or
+// above. It returns ErrNotFinalized before Finalize has been called, and an
+// error wrapping ErrNonCompliant if the codec can't compose a block with one
+// of the IDs.
+func (e *StreamEncoder) Repair(ids []int64) ([]LTBlock, error) {
+	if e.codec == nil {
+		return nil, ErrNotFinalized
+	}
+	if err := checkBlockCodes(e.codec, ids); err != nil {
+		return nil, err
+	}
+	blocks := make([]LTBlock, len(ids))
+	for i, id := range ids {
+		b := encodeCodeBlock(e.codec, e.source, id)
+		blocks[i] = LTBlock{BlockCode: id, Data: make([]byte, b.length())}
+		copy(blocks[i].Data, b.data)
+	}
+	return blocks, nil
+}
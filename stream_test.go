@@ -0,0 +1,119 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestStreamEncoder(t *testing.T) {
+	object := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJ")
+	var finalized Codec
+	finalizedLength := 0
+	e := NewStreamEncoder(4, func(k int) (Codec, error) { return NewRaptorCodecChecked(k, 2) },
+		func(c Codec, length int) { finalized, finalizedLength = c, length })
+
+	var sent []LTBlock
+	for i := 0; i < len(object); i += 5 {
+		end := i + 5
+		if end > len(object) {
+			end = len(object)
+		}
+		e.Write(object[i:end])
+		sent = append(sent, e.SourceBlocks()...)
+		if len(sent) != end/4 {
+			t.Fatalf("After writing %d bytes, %d source blocks were sent", end, len(sent))
+		}
+	}
+	if _, err := e.Repair([]int64{20}); err != ErrNotFinalized {
+		t.Errorf("Repair before Finalize gave %v, want ErrNotFinalized", err)
+	}
+
+	codec, err := e.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %v", err)
+	}
+	sent = append(sent, e.SourceBlocks()...)
+	k := codec.SourceBlocks()
+	if k != 12 || len(sent) != k || e.Length() != len(object) {
+		t.Fatalf("K = %d, %d source blocks sent, length %d", k, len(sent), e.Length())
+	}
+	if finalized != codec || finalizedLength != len(object) {
+		t.Errorf("finalization callback got %v and length %d, want the codec and %d", finalized, finalizedLength, len(object))
+	}
+	if again, err := e.Finalize(); again != codec || err != nil {
+		t.Errorf("second Finalize = %v, %v; want the same codec", again, err)
+	}
+	if _, err := e.Write([]byte("x")); err != ErrFinalized {
+		t.Errorf("Write after Finalize gave %v, want ErrFinalized", err)
+	}
+
+	// The streamed source blocks match what the codec would have produced.
+	padded := make([]byte, k*4)
+	copy(padded, object)
+	for _, b := range sent {
+		want := EncodeLTBlocks(append([]byte(nil), padded...), []int64{b.BlockCode}, codec)[0]
+		if !bytes.Equal(b.Data, want.Data) {
+			t.Errorf("Source block %d is %v, want %v", b.BlockCode, b.Data, want.Data)
+		}
+	}
+
+	if _, err := e.Repair([]int64{MaxRaptorESI + 1}); err == nil {
+		t.Error("Repair accepted an ID past the last raptor ESI")
+	}
+
+	// Lose two source blocks and repair from blocks generated afterwards.
+	repair, err := e.Repair([]int64{12, 13, 14, 15, 16, 17})
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	decoder := codec.NewDecoder(k * 4)
+	decoder.AddBlocks(sent[2:])
+	if !decoder.AddBlocks(repair) {
+		t.Fatalf("Decoder not determined with %d blocks", len(sent)-2+len(repair))
+	}
+	if decoded := decoder.Decode()[:e.Length()]; !bytes.Equal(decoded, object) {
+		t.Errorf("Decoded %q, want %q", decoded, object)
+	}
+}
+
+func TestStreamEncoderFinalizeError(t *testing.T) {
+	// Two symbols are too few for the raptor codec; the encoder stays open.
+	called := false
+	e := NewStreamEncoder(4, func(k int) (Codec, error) { return NewRaptorCodecChecked(k, 2) },
+		func(Codec, int) { called = true })
+	e.Write([]byte("abcdefg"))
+	if _, err := e.Finalize(); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("Finalize of 2 symbols = %v, want ErrInvalidCodec", err)
+	}
+	if called {
+		t.Error("finalization callback called after an error")
+	}
+	e.Write(make([]byte, 10))
+	if codec, err := e.Finalize(); err != nil || codec.SourceBlocks() != 5 {
+		t.Errorf("Finalize of 5 symbols = %v, %v", codec, err)
+	}
+	if !called {
+		t.Error("finalization callback not called")
+	}
+
+	e = NewStreamEncoder(4, func(k int) (Codec, error) { return NewRaptorCodec(k+1, 2), nil }, nil)
+	e.Write(make([]byte, 40))
+	if _, err := e.Finalize(); err == nil {
+		t.Error("Finalize accepted a codec of the wrong number of source symbols")
+	}
+}
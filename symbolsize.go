@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+)
+
+// A decoder's rows grow to the length of the longest code block XORed into
+// them, so a single malformed or malicious code block with a huge Data slice
+// makes the decoder allocate equally huge rows. A decoder told the size of
+// the symbols it expects drops larger code blocks instead, and counts them in
+// its Diagnostics. Shorter code blocks are accepted: the LT, binary and online
+// encoders leave out the trailing padding of code blocks which only combine
+// the shorter source blocks, and rows never grow past the symbol size.
+
+// SetSymbolSize makes a decoder created by one of the package's codecs drop
+// code blocks whose data is longer than size bytes. A size of zero accepts
+// blocks of any size, as decoders do by default. Returns false if the decoder
+// is of an unknown type.
+func SetSymbolSize(d Decoder, size int) bool {
+	m := decoderMatrix(d)
+	if m == nil || size < 0 {
+		return false
+	}
+	m.symbolSize = size
+	return true
+}
+
+// NewSizedDecoder creates a decoder of the codec for a message of
+// messageLength bytes, which drops code blocks whose data is longer than
+// symbolSize bytes.
+func NewSizedDecoder(c Codec, messageLength, symbolSize int) (Decoder, error) {
+	if symbolSize <= 0 {
+		return nil, fmt.Errorf("fountain: symbol size %d is not positive", symbolSize)
+	}
+	d := c.NewDecoder(messageLength)
+	if !SetSymbolSize(d, symbolSize) {
+		return nil, fmt.Errorf("fountain: NewSizedDecoder doesn't support %T", d)
+	}
+	return d, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewSizedDecoder(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	codecs := testCodecs()
+	for _, c := range codecs {
+		e := c.NewEncoder(message)
+		size := 0
+		for id := int64(0); id < 20; id++ {
+			size = max(size, len(e.Generate(id).Data))
+		}
+		d, err := NewSizedDecoder(c, len(message), size)
+		if err != nil {
+			t.Fatalf("%T: NewSizedDecoder failed: %v", c, err)
+		}
+
+		// Oversized blocks are dropped before they can touch the matrix.
+		huge := e.Generate(1)
+		huge.Data = append(huge.Data, make([]byte, 1<<20)...)
+		long := e.Generate(2)
+		long.Data = make([]byte, size+1)
+		d.AddBlocks([]LTBlock{huge, long})
+		diag, _ := Diagnose(d)
+		if diag.Received != 2 || diag.Rejected != 2 || diag.Rank != diagRankOf(c, len(message)) {
+			t.Errorf("%T: after bad blocks, diagnostics %v", c, diag)
+		}
+
+		for id := int64(0); !d.AddBlocks([]LTBlock{e.Generate(id)}); id++ {
+			if id > 500 {
+				t.Fatalf("%T: no decode after 500 blocks", c)
+			}
+		}
+		if out := d.Decode(); !bytes.Equal(out, message) {
+			t.Errorf("%T: decoded %q, want %q", c, out, message)
+		}
+	}
+
+	if _, err := NewSizedDecoder(NewBinaryCodec(4), 10, 0); err == nil {
+		t.Errorf("NewSizedDecoder accepted a zero symbol size")
+	}
+}
+
+// diagRankOf returns the rank of a fresh decoder, which holds the precode
+// constraints of the codecs which have one.
+func diagRankOf(c Codec, messageLength int) int {
+	diag, _ := Diagnose(c.NewDecoder(messageLength))
+	return diag.Rank
+}
+
+func TestSetSymbolSize(t *testing.T) {
+	d := NewBinaryCodec(4).NewDecoder(8)
+	if SetSymbolSize(d, -1) {
+		t.Errorf("SetSymbolSize accepted a negative size")
+	}
+	if !SetSymbolSize(d, 2) || !SetSymbolSize(d, 0) {
+		t.Errorf("SetSymbolSize failed on a binary decoder")
+	}
+	d.AddBlocks([]LTBlock{{BlockCode: 1, Data: make([]byte, 100)}})
+	if diag, _ := Diagnose(d); diag.Rejected != 0 {
+		t.Errorf("decoder without a symbol size rejected %d blocks", diag.Rejected)
+	}
+	if SetSymbolSize(NewQuarantineDecoder(d, nil, 1), 2) {
+		t.Errorf("SetSymbolSize succeeded on a foreign decoder")
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+// The raptor code is systematic because the systematic index J(K) seeding the
+// triple generator is chosen so that the LT equations of ESIs 0 through K-1,
+// together with the precode constraints, determine the intermediate symbols.
+// RFC 5053 tabulates J(K) for K up to 8192. For larger K, the codec searches
+// for the smallest index which works, once per K, and caches it.
+//
+// The search doesn't reproduce the RFC's table, whose entries aren't the
+// smallest working indices, so codecs for K beyond the table are only
+// compatible with this package. Working indices get rarer as K grows: most
+// fail because some of the LT equations of degree one and two are dependent,
+// which is ever more likely with more of them. Around K = 16384 one index in
+// a few thousand works, and the search takes seconds; by K = 24000 none of
+// the 65521 distinct ones do.
+
+// MaxRaptorCodecSourceSymbols is the largest number of source symbols of a
+// raptor codec. RFC 5053 allows up to MaxRaptorSourceSymbols; codecs for more
+// than that use systematic indices of this package's own, so their symbols
+// are only understood by this package, and only by versions which have the
+// same limit or a larger one.
+const MaxRaptorCodecSourceSymbols = 16384
+
+// systematicIndex returns the systematic index J(K) for k source symbols. It
+// panics if there is none (see lookupSystematicIndex).
+func systematicIndex(k int) uint16 {
+	return raptorParamsFor(k).jk()
+}
+
+// lookupSystematicIndex returns the systematic index J(K) for k source
+// symbols: the RFC 5053 value if k is in the table, otherwise the smallest
+// index making the code systematic, which it searches for. It reports false
+// if k is out of range or, improbably, no index works. Codecs find the index
+// through raptorParamsFor, which searches once per k.
+func lookupSystematicIndex(k int) (uint16, bool) {
+	if k >= 0 && k < len(systematicIndextable) {
+		return systematicIndextable[k], true
+	}
+	if k < 0 || k > MaxRaptorCodecSourceSymbols {
+		return 0, false
+	}
+	for j := 0; j < 65521; j++ {
+		if systematicIndexValid(k, uint16(j)) {
+			return uint16(j), true
+		}
+	}
+	return 0, false
+}
+
+// systematicIndexValid reports whether jk makes the raptor code systematic for
+// k source symbols.
+func systematicIndexValid(k int, jk uint16) bool {
+	p := raptorParamsFor(k)
+	l, lprime := p.l, p.lprime
+	// Most indices fail for large k because some of the LT equations of degree
+	// one and two are dependent, which is quick to find.
+	f := newCycleFinder(l)
+	lt := make([][]int, k)
+	for i := range lt {
+		lt[i] = ltIndices(l, lprime, jk, uint16(i))
+		if f.closes(lt[i]) {
+			return false
+		}
+	}
+	rows := R10Precode.Compositions(k)
+	for i := range rows {
+		rows[i] = append(rows[i], k+i)
+	}
+	return fullRank(append(rows, lt...), l)
+}
+
+// cycleFinder finds dependent rows of one and two columns. Rows of two
+// columns are edges of a graph on the columns, and rows of one, edges to an
+// extra vertex: a subset of them XORs to zero if and only if the edges make a
+// cycle. parent is the union-find forest of the graph's components.
+type cycleFinder struct {
+	parent []int
+}
+
+func newCycleFinder(n int) *cycleFinder {
+	f := &cycleFinder{parent: make([]int, n+1)}
+	for i := range f.parent {
+		f.parent[i] = i
+	}
+	return f
+}
+
+func (f *cycleFinder) find(x int) int {
+	for f.parent[x] != x {
+		f.parent[x] = f.parent[f.parent[x]]
+		x = f.parent[x]
+	}
+	return x
+}
+
+// closes adds the row, reporting whether it is dependent on those of one and
+// two columns already added. Longer rows are ignored.
+func (f *cycleFinder) closes(r []int) bool {
+	var a, b int
+	switch len(r) {
+	case 1:
+		a, b = r[0], len(f.parent)-1
+	case 2:
+		a, b = r[0], r[1]
+	default:
+		return false
+	}
+	ra, rb := f.find(a), f.find(b)
+	if ra == rb {
+		return true
+	}
+	f.parent[ra] = rb
+	return false
+}
+
+// fullRank reports whether the GF(2) matrix of n columns whose rows have ones
+// in the given columns has rank n. The rows must be sorted, and are modified.
+//
+// Eliminating the sparse matrix outright is slow, since the dense rows fill it
+// in. Instead, rows with one unresolved column are peeled off, each resolving
+// its column in terms of earlier ones, and when there are none, columns of the
+// sparsest row are inactivated, deferring them to the end. That gives every
+// column, and so every row left over, as a combination of the few inactive
+// columns, and the matrix has full rank if the leftover rows do over those.
+func fullRank(rows [][]int, n int) bool {
+	if len(rows) < n {
+		return false
+	}
+	for i, r := range rows {
+		rows[i] = cancelPairs(r)
+	}
+
+	cols := make([][]int, n)
+	for i, r := range rows {
+		for _, c := range r {
+			cols[c] = append(cols[c], i)
+		}
+	}
+
+	const (
+		unresolved = iota
+		peeled
+		inactive
+	)
+	state := make([]int, n)
+	pivot := make([]int, 0, n) // peeled rows, in order
+	used := make([]bool, len(rows))
+	count := make([]int, len(rows)) // unresolved columns of each row
+	var queue []int
+	for i, r := range rows {
+		count[i] = len(r)
+		if count[i] == 1 {
+			queue = append(queue, i)
+		}
+	}
+	var inactivated []int
+
+	resolve := func(c, s int) {
+		state[c] = s
+		for _, i := range cols[c] {
+			if count[i]--; count[i] == 1 && !used[i] {
+				queue = append(queue, i)
+			}
+		}
+	}
+	for resolved := 0; resolved < n; {
+		if len(queue) > 0 {
+			i := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if used[i] || count[i] != 1 {
+				continue
+			}
+			used[i] = true
+			for _, c := range rows[i] {
+				if state[c] == unresolved {
+					pivot = append(pivot, i)
+					resolve(c, peeled)
+					break
+				}
+			}
+			resolved++
+			continue
+		}
+		// Inactivate all but one unresolved column of the sparsest row.
+		best := -1
+		for i := range rows {
+			if !used[i] && count[i] > 1 && (best < 0 || count[i] < count[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			// The remaining columns are in no unused row.
+			return false
+		}
+		for _, c := range rows[best] {
+			if count[best] == 1 {
+				break
+			}
+			if state[c] == unresolved {
+				inactivated = append(inactivated, c)
+				resolve(c, inactive)
+				resolved++
+			}
+		}
+	}
+
+	// Express each column over the inactive columns.
+	words := (len(inactivated) + 63) / 64
+	expr := make([][]uint64, n)
+	for b, c := range inactivated {
+		expr[c] = make([]uint64, words)
+		expr[c][b/64] |= 1 << (b % 64)
+	}
+	for _, i := range pivot {
+		v := make([]uint64, words)
+		p := -1
+		for _, c := range rows[i] {
+			if expr[c] == nil {
+				p = c
+				continue
+			}
+			xorWords(v, expr[c])
+		}
+		expr[p] = v
+	}
+
+	var left [][]uint64
+	for i, r := range rows {
+		if used[i] {
+			continue
+		}
+		v := make([]uint64, words)
+		for _, c := range r {
+			xorWords(v, expr[c])
+		}
+		left = append(left, v)
+	}
+	return denseRank(left, len(inactivated)) == len(inactivated)
+}
+
+// cancelPairs removes columns which occur an even number of times from a
+// sorted row, in place.
+func cancelPairs(r []int) []int {
+	out := r[:0]
+	for _, c := range r {
+		if n := len(out); n > 0 && out[n-1] == c {
+			out = out[:n-1]
+		} else {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func xorWords(dst, src []uint64) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// denseRank returns the GF(2) rank of the bit vectors of n bits, eliminating
+// them in place.
+func denseRank(vs [][]uint64, n int) int {
+	rank := 0
+	for b := 0; b < n && rank < len(vs); b++ {
+		w, bit := b/64, uint64(1)<<(b%64)
+		p := -1
+		for i := rank; i < len(vs); i++ {
+			if vs[i][w]&bit != 0 {
+				p = i
+				break
+			}
+		}
+		if p < 0 {
+			continue
+		}
+		vs[rank], vs[p] = vs[p], vs[rank]
+		for i := rank + 1; i < len(vs); i++ {
+			if vs[i][w]&bit != 0 {
+				xorWords(vs[i], vs[rank])
+			}
+		}
+		rank++
+	}
+	return rank
+}
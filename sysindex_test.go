@@ -0,0 +1,104 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import "testing"
+
+func TestSystematicIndexTable(t *testing.T) {
+	for k := MinRaptorSourceSymbols; k <= MaxRaptorSourceSymbols; k += 251 {
+		if !systematicIndexValid(k, systematicIndextable[k]) {
+			t.Errorf("J(%d) = %d isn't systematic", k, systematicIndextable[k])
+		}
+	}
+	if !systematicIndexValid(8192, systematicIndextable[8192]) {
+		t.Errorf("J(8192) = %d isn't systematic", systematicIndextable[8192])
+	}
+}
+
+func TestSystematicIndexSearch(t *testing.T) {
+	j, ok := lookupSystematicIndex(8193)
+	if !ok || j != 22 {
+		t.Fatalf("lookupSystematicIndex(8193) = (%d, %v), want (22, true)", j, ok)
+	}
+	for i := uint16(0); i < j; i++ {
+		if systematicIndexValid(8193, i) {
+			t.Errorf("J = %d is systematic for K = 8193, but the search skipped it", i)
+		}
+	}
+	if !systematicIndexValid(8193, j) {
+		t.Errorf("J = %d isn't systematic for K = 8193", j)
+	}
+	if j, ok := lookupSystematicIndex(MaxRaptorCodecSourceSymbols + 1); ok {
+		t.Errorf("lookupSystematicIndex(%d) = %d, want none", MaxRaptorCodecSourceSymbols+1, j)
+	}
+}
+
+// TestFullRank compares fullRank to eliminating the decode matrix.
+func TestFullRank(t *testing.T) {
+	valid := 0
+	for k := MinRaptorSourceSymbols; k < 40; k++ {
+		l, _, _ := intermediateSymbols(k)
+		lprime := smallestPrimeGreaterOrEqual(l)
+		for j := uint16(0); j < 10; j++ {
+			m := sparseMatrix{coeff: make([][]int, l), v: make([]block, l)}
+			addPrecodeConstraints(&m, k, R10Precode.Compositions(k))
+			for i := 0; i < k; i++ {
+				m.addEquation(ltIndices(l, lprime, j, uint16(i)), block{})
+			}
+			want := m.determined()
+			if got := systematicIndexValid(k, j); got != want {
+				t.Errorf("systematicIndexValid(%d, %d) = %v, want %v", k, j, got, want)
+			}
+			if want {
+				valid++
+			}
+		}
+	}
+	if valid == 0 {
+		t.Error("No index was systematic")
+	}
+
+	rows := [][]int{{0, 2}, {1}, {0, 1, 2}}
+	if fullRank(rows, 3) {
+		t.Errorf("fullRank(%v) = true, want false", rows)
+	}
+	rows = [][]int{{0, 2}, {1}, {0, 1}}
+	if !fullRank(rows, 3) {
+		t.Errorf("fullRank(%v) = false, want true", rows)
+	}
+	rows = [][]int{{0, 0, 1}, {0, 2}, {2}}
+	if !fullRank(rows, 3) {
+		t.Errorf("fullRank(%v) = false, want true", rows)
+	}
+}
+
+func TestCycleFinder(t *testing.T) {
+	f := newCycleFinder(3)
+	for _, r := range [][]int{{0, 1}, {1, 2}, {0, 1, 2}} {
+		if f.closes(r) {
+			t.Errorf("closes(%v) = true, want false", r)
+		}
+	}
+	if r := []int{0, 2}; !f.closes(r) {
+		t.Errorf("closes(%v) = false, want true", r)
+	}
+
+	f = newCycleFinder(3)
+	f.closes([]int{0})
+	f.closes([]int{0, 2})
+	if r := []int{2}; !f.closes(r) {
+		t.Errorf("closes(%v) = false, want true", r)
+	}
+}
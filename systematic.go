@@ -0,0 +1,76 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+)
+
+// The raptor code is systematic: the code blocks with ESIs below K are the
+// source symbols themselves. A sender can transmit those straight from the
+// message, and only needs the intermediate blocks, which take a solve of the
+// precode matrix to compute, once the receivers ask for repair symbols. The
+// SystematicEncoder packages that usage pattern.
+
+// SystematicEncoder serves the source symbols of a message encoded with the
+// raptor codec without running the precode, and repair symbols on demand.
+type SystematicEncoder struct {
+	codec   *raptorCodec
+	message []byte
+
+	// repair generates the repair symbols. It is created by the first call
+	// to Repair.
+	repair *ltEncoder
+}
+
+// NewSystematicEncoder creates a systematic encoder for a message. The codec
+// must be an R10 raptor codec (from NewRaptorCodec). The encoder refers to the
+// message, which must not be modified while the encoder is in use.
+func NewSystematicEncoder(c Codec, message []byte) (*SystematicEncoder, error) {
+	r, ok := c.(*raptorCodec)
+	if !ok {
+		return nil, fmt.Errorf("fountain: %T is not a systematic codec", c)
+	}
+	return &SystematicEncoder{codec: r, message: message}, nil
+}
+
+// SourceSymbols returns the code blocks with ESIs 0 to K-1, which are the
+// message's source blocks. Their data refers to the message, so they must be
+// copied before being given to a decoder in the same process, which would
+// modify them. Where K doesn't divide the message length, the shorter source
+// blocks are returned without the padding the encoder would add; decoders
+// treat the missing trailing bytes as zeros.
+func (e *SystematicEncoder) SourceSymbols() []LTBlock {
+	long, short := partitionBytes(e.message, e.codec.NumSourceSymbols)
+	symbols := make([]LTBlock, 0, len(long)+len(short))
+	for _, b := range append(long, short...) {
+		symbols = append(symbols, LTBlock{BlockCode: int64(len(symbols)), Data: b.data})
+	}
+	return symbols
+}
+
+// Repair returns the repair symbol with the given ESI, which must be in
+// [K, MaxRaptorESI]. The first call computes the intermediate blocks of the
+// message.
+func (e *SystematicEncoder) Repair(esi int64) (LTBlock, error) {
+	k := int64(e.codec.NumSourceSymbols)
+	if esi < k || esi > MaxRaptorESI {
+		return LTBlock{}, fmt.Errorf("fountain: repair ESI %d is outside [%d, %d]", esi, k, MaxRaptorESI)
+	}
+	if e.repair == nil {
+		e.repair = newLTEncoder(e.codec, e.message)
+	}
+	return e.repair.Generate(esi), nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSystematicEncoder(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	original := append([]byte(nil), message...)
+	c := NewRaptorCodec(10, 1)
+	e, err := NewSystematicEncoder(c, message)
+	if err != nil {
+		t.Fatalf("NewSystematicEncoder failed: %v", err)
+	}
+
+	source := e.SourceSymbols()
+	if e.repair != nil {
+		t.Errorf("SourceSymbols ran the precode")
+	}
+	full := c.NewEncoder(message)
+	for i, s := range source {
+		want := full.Generate(int64(i))
+		if s.BlockCode != int64(i) || !bytes.Equal(s.Data, want.Data[:len(s.Data)]) {
+			t.Errorf("source symbol %d = %v, want a prefix of %v", i, s, want)
+		}
+	}
+	if len(source) != 10 {
+		t.Errorf("SourceSymbols returned %d symbols, want 10", len(source))
+	}
+
+	// Lose three source symbols, and make up for them with repair symbols.
+	// The rest are copied, as they would be by transmission.
+	d := c.NewDecoder(len(message))
+	for _, s := range append(source[:2:2], source[5:]...) {
+		d.AddBlocks([]LTBlock{{BlockCode: s.BlockCode, Data: append([]byte(nil), s.Data...)}})
+	}
+	for esi := int64(10); !d.AddBlocks([]LTBlock{mustRepair(t, e, esi)}); esi++ {
+		if esi > 100 {
+			t.Fatalf("no decode after 90 repair symbols")
+		}
+	}
+	if out := d.Decode(); !bytes.Equal(out, original) {
+		t.Errorf("decoded %q, want %q", out, original)
+	}
+	if !bytes.Equal(message, original) {
+		t.Errorf("the encoder modified the message")
+	}
+
+	for _, esi := range []int64{-1, 9, MaxRaptorESI + 1} {
+		if _, err := e.Repair(esi); err == nil {
+			t.Errorf("Repair(%d) succeeded", esi)
+		}
+	}
+	if _, err := NewSystematicEncoder(NewRU10Codec(10, 1), message); err == nil {
+		t.Errorf("NewSystematicEncoder accepted the RU10 codec")
+	}
+}
+
+func mustRepair(t *testing.T, e *SystematicEncoder, esi int64) LTBlock {
+	b, err := e.Repair(esi)
+	if err != nil {
+		t.Fatalf("Repair(%d) failed: %v", esi, err)
+	}
+	return b
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// An integrity trailer is appended to an object before it is encoded, so it
+// is protected by the code like the rest of the object's data and survives
+// whatever loss the object survives. After decoding, the receiver checks the
+// object against the trailer without needing a digest from elsewhere.
+//
+// The trailer is the SHA-256 digest of the object followed by the object's
+// length as a 64-bit integer in the canonical wire byte order.
+
+// IntegrityTrailerLen is the length in bytes of an integrity trailer.
+const IntegrityTrailerLen = sha256.Size + 8
+
+// ErrIntegrity is returned when a decoded object doesn't match its integrity
+// trailer.
+var ErrIntegrity = errors.New("fountain: object doesn't match its integrity trailer")
+
+// AppendIntegrityTrailer returns the object with its integrity trailer
+// appended. The result is what should be passed to the encoder, and its length
+// is what the decoder must be created with.
+func AppendIntegrityTrailer(object []byte) []byte {
+	out := make([]byte, len(object), len(object)+IntegrityTrailerLen)
+	copy(out, object)
+	digest := sha256.Sum256(object)
+	out = append(out, digest[:]...)
+	return ByteOrder.AppendUint64(out, uint64(len(object)))
+}
+
+// VerifyIntegrityTrailer checks a decoded object against its integrity
+// trailer, and returns the object without the trailer. Returns ErrIntegrity if
+// the length or the digest doesn't match.
+func VerifyIntegrityTrailer(decoded []byte) ([]byte, error) {
+	if len(decoded) < IntegrityTrailerLen {
+		return nil, ErrShortWireData
+	}
+	n := len(decoded) - IntegrityTrailerLen
+	if ByteOrder.Uint64(decoded[len(decoded)-8:]) != uint64(n) {
+		return nil, ErrIntegrity
+	}
+	digest := sha256.Sum256(decoded[:n])
+	if !bytes.Equal(digest[:], decoded[n:n+sha256.Size]) {
+		return nil, ErrIntegrity
+	}
+	return decoded[:n], nil
+}
+
+// DecodeVerified decodes an object which was encoded with an integrity
+// trailer, and verifies it. It is DecodeStrict followed by
+// VerifyIntegrityTrailer.
+func DecodeVerified(d Decoder) ([]byte, error) {
+	decoded, err := DecodeStrict(d)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyIntegrityTrailer(decoded)
+}
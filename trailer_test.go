@@ -0,0 +1,53 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIntegrityTrailer(t *testing.T) {
+	object := []byte("abcdefghijklmnopqrstuvwxyz")
+	message := AppendIntegrityTrailer(object)
+	if len(message) != len(object)+IntegrityTrailerLen {
+		t.Fatalf("Trailered message has length %d", len(message))
+	}
+
+	codec := NewRaptorCodec(13, 2)
+	ids := []int64{0, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18}
+	blocks := EncodeLTBlocks(append([]byte(nil), message...), ids, codec)
+	decoder := codec.NewDecoder(len(message))
+	decoder.AddBlocks(blocks)
+	decoded, err := DecodeVerified(decoder)
+	if err != nil {
+		t.Fatalf("DecodeVerified failed: %v", err)
+	}
+	if !bytes.Equal(decoded, object) {
+		t.Errorf("Decoded %q, want %q", decoded, object)
+	}
+
+	corrupt := append([]byte(nil), message...)
+	corrupt[3] ^= 1
+	if _, err := VerifyIntegrityTrailer(corrupt); err != ErrIntegrity {
+		t.Errorf("Corrupted object gave %v, want ErrIntegrity", err)
+	}
+	if _, err := VerifyIntegrityTrailer(message[1:]); err != ErrIntegrity {
+		t.Errorf("Truncated object gave %v, want ErrIntegrity", err)
+	}
+	if _, err := VerifyIntegrityTrailer(message[:10]); err == nil {
+		t.Errorf("Object shorter than a trailer verified")
+	}
+}
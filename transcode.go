@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+	"io"
+)
+
+// A gateway bridging two deployments which partition or encode objects
+// differently (say, R10 with small symbols broadcast in, another codec with
+// large symbols unicast out) receives an object under one codec and set of
+// parameters and sends it under another. Transcode does the handoff in one
+// step, from the object a receiver decoded and the FEC Object
+// Transmission Information it was received with. Rather than building the
+// encoders of every source block up front like an ObjectEncoder, a Transcoder
+// encodes one source block at a time as its symbols are streamed, so it holds
+// the intermediate symbols of only one source block besides the object.
+
+// Transcoder streams the encoding symbols of an object re-encoded by
+// Transcode.
+type Transcoder struct {
+	object     []byte
+	params     ObjectParams
+	newCodec   func(sourceSymbols, alignment int) Codec
+	systematic bool
+	repair     int
+
+	// sbn is the source block being sent, and esi the next ESI of it to send.
+	sbn int
+	esi int64
+
+	// encoders holds the encoder of each sub-block of source block sbn, or is
+	// nil if the source block isn't started yet.
+	encoders []Encoder
+}
+
+// Transcode re-encodes an object, as ObjectDecoder.Object returns it, which
+// was received with the FEC Object Transmission Information oti, under the
+// parameters to and the codec newCodec creates for each sub-block from its
+// number of source symbols and the alignment, such as NewRU10Codec. If
+// newCodec is nil, the object is re-encoded with the R10 raptor codec, as an
+// ObjectEncoder does. The object is checked against oti: its length, and if
+// oti has a Merkle root for an object which wasn't compressed, the root.
+//
+// The Transcoder returned streams the symbols of each source block in turn:
+// its first K if systematic is set (the source symbols, for a systematic
+// codec), followed by the given number of repair symbols, like
+// WriteObjectContainer. Receivers decode each sub-block of a source block
+// with the same codec. The options configure the encoding as in
+// NewObjectEncoder. The object is left untouched.
+func Transcode(object []byte, oti, to ObjectParams, newCodec func(sourceSymbols, alignment int) Codec, systematic bool, repair int, opts ...ObjectEncoderOption) (*Transcoder, error) {
+	if err := oti.Validate(); err != nil {
+		return nil, err
+	}
+	length := oti.TransferLength
+	if oti.Compression != CompressionNone {
+		length = oti.ContentLength
+	}
+	if int64(len(object)) != length {
+		return nil, fmt.Errorf("fountain: object of %d bytes, but it was received as %d", len(object), length)
+	}
+	if oti.Compression == CompressionNone {
+		if err := oti.verifyMerkleRoot(object); err != nil {
+			return nil, err
+		}
+	}
+	object, p, err := prepareObject(object, to, opts)
+	if err != nil {
+		return nil, err
+	}
+	if newCodec == nil {
+		newCodec = NewRaptorCodec
+	}
+	k := max(p.sourceSymbols(0), p.sourceSymbols(p.SourceBlocks-1))
+	if repair < 0 || !validBlockCode(newCodec(k, p.Alignment), int64(k)+int64(repair)-1) {
+		return nil, fmt.Errorf("%w: %d repair symbols of a source block of %d symbols", ErrNonCompliant, repair, k)
+	}
+	return &Transcoder{object: object, params: p, newCodec: newCodec, systematic: systematic, repair: repair}, nil
+}
+
+// Params returns the parameters of the encoding, which receivers must decode
+// with. They differ from those given to Transcode if the object is
+// compressed.
+func (t *Transcoder) Params() ObjectParams {
+	return t.params
+}
+
+// Next returns the next symbol of the stream. Returns io.EOF after the last.
+func (t *Transcoder) Next() (ObjectSymbol, error) {
+	for t.sbn < t.params.SourceBlocks {
+		k := int64(t.params.sourceSymbols(t.sbn))
+		if t.encoders == nil {
+			t.encoders = t.params.sourceBlockEncoders(t.object, t.sbn, t.newCodec)
+			if t.esi = k; t.systematic {
+				t.esi = 0
+			}
+		}
+		if t.esi < k+int64(t.repair) {
+			s := sourceBlockSymbol(t.encoders, t.sbn, t.esi, t.params.SymbolSize)
+			t.esi++
+			return s, nil
+		}
+		// Drop the finished source block's encoders before encoding the next.
+		t.sbn, t.encoders = t.sbn+1, nil
+	}
+	return ObjectSymbol{}, io.EOF
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// decodeTranscoded decodes an object from all the symbols of a transcoder,
+// and returns it along with the number of symbols.
+func decodeTranscoded(t *testing.T, tc *Transcoder) ([]byte, int, error) {
+	t.Helper()
+	d, err := NewObjectDecoder(tc.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		s, err := tc.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Data) != tc.Params().SymbolSize {
+			t.Fatalf("symbol %d/%d of %d bytes, want %d", s.SBN, s.BlockCode, len(s.Data), tc.Params().SymbolSize)
+		}
+		d.AddSymbols([]ObjectSymbol{s})
+		n++
+	}
+	object, err := d.Object()
+	return object, n, err
+}
+
+func TestTranscode(t *testing.T) {
+	random := rand.New(rand.NewSource(9))
+	object := make([]byte, 1000)
+	random.Read(object)
+	in := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 3, SubBlocks: 4, SymbolsPerPacket: 1}
+	out := ObjectParams{TransferLength: 1000, SymbolSize: 64, Alignment: 8, SourceBlocks: 2, SubBlocks: 2, SymbolsPerPacket: 1}
+
+	// Receive the object under one set of parameters.
+	e, err := NewObjectEncoder(object, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := decodeObject(t, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send it on under another, as repair symbols only: 16 symbols, as two
+	// source blocks of 8.
+	tc, err := Transcode(received, in, out, nil, false, 12)
+	if err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+	if tc.Params() != out {
+		t.Errorf("Params = %+v, want %+v", tc.Params(), out)
+	}
+	first, err := tc.Next()
+	if err != nil || first.SBN != 0 || first.BlockCode != 8 {
+		t.Errorf("first symbol %d/%d, %v; want the repair symbol 0/8", first.SBN, first.BlockCode, err)
+	}
+	tc, _ = Transcode(received, in, out, nil, false, 12)
+	if got, n, err := decodeTranscoded(t, tc); err != nil || n != 24 || !bytes.Equal(got, object) {
+		t.Errorf("decoded %d bytes from %d symbols, %v; want the object from 24", len(got), n, err)
+	}
+	if tc.encoders != nil {
+		t.Error("Transcoder kept the encoders of its last source block")
+	}
+
+	// Compress a compressible object on the way, with the source symbols.
+	for i := range object {
+		object[i] = 'a' + byte(random.Intn(4))
+	}
+	tc, err = Transcode(object, in, out, nil, true, 0, WithCompression(CompressionDeflate))
+	if err != nil {
+		t.Fatalf("Transcode with compression failed: %v", err)
+	}
+	if tc.Params().Compression != CompressionDeflate {
+		t.Errorf("Params = %+v, want a deflated object", tc.Params())
+	}
+	if got, _, err := decodeTranscoded(t, tc); err != nil || !bytes.Equal(got, object) {
+		t.Errorf("decoded %d bytes, %v; want the object", len(got), err)
+	}
+
+	// An object received compressed is given decompressed, of its content
+	// length.
+	compressed := in
+	compressed.TransferLength, compressed.Compression, compressed.ContentLength = 500, CompressionDeflate, 1000
+	compressed.SourceBlocks = 1
+	if _, err := Transcode(object, compressed, out, nil, true, 0); err != nil {
+		t.Errorf("Transcode of an object received compressed failed: %v", err)
+	}
+
+	if _, err := Transcode(object[:999], in, out, nil, true, 0); err == nil {
+		t.Error("Transcode accepted an object shorter than its transfer length")
+	}
+	withRoot := in
+	withRoot.MerkleRoot[0] = 1
+	if _, err := Transcode(object, withRoot, out, nil, true, 0); !errors.Is(err, ErrMerkleProof) {
+		t.Errorf("Transcode of an object not matching its Merkle root = %v, want ErrMerkleProof", err)
+	}
+	if _, err := Transcode(object, in, out, nil, true, -1); err == nil {
+		t.Error("Transcode accepted a negative repair count")
+	}
+	if _, err := Transcode(object, in, out, nil, true, MaxRaptorESI); !errors.Is(err, ErrNonCompliant) {
+		t.Errorf("Transcode of ESIs past %d = %v, want ErrNonCompliant", MaxRaptorESI, err)
+	}
+}
+
+func TestTranscodeCodec(t *testing.T) {
+	random := rand.New(rand.NewSource(10))
+	object := make([]byte, 1000)
+	random.Read(object)
+	in := ObjectParams{TransferLength: 1000, SymbolSize: 24, Alignment: 4, SourceBlocks: 3, SubBlocks: 4, SymbolsPerPacket: 1}
+	out := ObjectParams{TransferLength: 1000, SymbolSize: 64, Alignment: 8, SourceBlocks: 2, SubBlocks: 1, SymbolsPerPacket: 1}
+
+	// Re-encode the R10 object with RU10: 16 symbols, as two source blocks of
+	// 8, each decoded by an RU10 decoder.
+	tc, err := Transcode(object, in, out, NewRU10Codec, true, 12)
+	if err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+	decoders := make([]Decoder, out.SourceBlocks)
+	for sbn := range decoders {
+		decoders[sbn] = NewRU10Codec(8, 8).NewDecoder(8 * 64)
+	}
+	for {
+		s, err := tc.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoders[s.SBN].AddBlocks([]LTBlock{s.LTBlock})
+	}
+	var got []byte
+	for sbn, d := range decoders {
+		block := d.Decode()
+		if block == nil {
+			t.Fatalf("source block %d not decoded", sbn)
+		}
+		got = append(got, block...)
+	}
+	if !bytes.Equal(got[:len(object)], object) {
+		t.Error("decoded object differs")
+	}
+
+	// The codec's IDs aren't bounded like raptor ESIs.
+	if _, err := Transcode(object, in, out, NewRU10Codec, true, MaxRaptorESI); err != nil {
+		t.Errorf("Transcode to RU10 of ESIs past %d failed: %v", MaxRaptorESI, err)
+	}
+}
@@ -27,11 +27,14 @@ import (
 // random number r (0 <= r < 1) and then find the smallest i such that
 // CDF[i] >= r.
 
-// solitonDistribution returns a CDF mapping for the soliton distribution.
-// N (the number of elements in the CDF) cannot be less than 1
-// The CDF is one-based: the probability of picking 1 from the distribution
-// is CDF[1].
-func solitonDistribution(n int) []float64 {
+// SolitonDistribution returns a CDF mapping for the ideal soliton
+// distribution over degrees 1 to n, where n is usually the number of source
+// blocks: pdf(1) = 1/n and pdf(i) = 1/(i(i-1)) for i = 2..n. n cannot be less
+// than 1. The CDF is one-based: the probability of picking 1 from the
+// distribution is CDF[1]. It can be passed to NewLubyCodec, though in practice
+// the ideal soliton distribution too often runs out of degree one blocks
+// before decoding finishes; RobustSolitonDistribution fixes that.
+func SolitonDistribution(n int) []float64 {
 	cdf := make([]float64, n+1)
 	cdf[1] = 1 / float64(n)
 	for i := 2; i < len(cdf); i++ {
@@ -40,10 +43,13 @@ func solitonDistribution(n int) []float64 {
 	return cdf
 }
 
-// robustSolitonDistribution returns a CDF mapping for the robust solition
+// RobustSolitonDistribution returns a CDF mapping for Luby's robust soliton
 // distribution.
-// This is an addition to the soliton distribution with three parameters,
-// N, M, and delta.
+// This is an addition to the soliton distribution with three parameters:
+// N, the number of source blocks; M, the degree at which the correction
+// spikes, in [1, N] and usually about N/ln(N/delta) or less; and delta, in
+// (0, 1), a bound on the probability that decoding fails after receiving
+// about N + sqrt(N)*ln^2(N/delta) code blocks.
 // Before normalization, the correction pdf(i) = 1/i*M, for i=1..M-1,
 // pdf(M) = ln(N/(M*delta))/M
 // pdf(i) = 0 for i = M+1..N
@@ -51,7 +57,7 @@ func solitonDistribution(n int) []float64 {
 // result normalized.
 // The CDF is one-based: the probability of picking 1 from the distribution
 // is CDF[1].
-func robustSolitonDistribution(n int, m int, delta float64) []float64 {
+func RobustSolitonDistribution(n int, m int, delta float64) []float64 {
 	pdf := make([]float64, n+1)
 
 	pdf[1] = 1/float64(n) + 1/float64(m)
@@ -75,8 +81,8 @@ func robustSolitonDistribution(n int, m int, delta float64) []float64 {
 	return cdf
 }
 
-// onlineSolitionDistribution returns a soliton-like distribution for
-// Online Codes
+// OnlineSolitonDistribution returns a soliton-like distribution for
+// Online Codes, as used by NewOnlineCodec
 // See http://pdos.csail.mit.edu/~petar/papers/maymounkov-bigdown-lncs.ps
 // 'Rateless Codes and Big Downloads' by Maymounkov and Mazieres
 // The distribution is described by a parameter epsilon, which is the
@@ -85,7 +91,11 @@ func robustSolitonDistribution(n int, m int, delta float64) []float64 {
 // F = ciel(ln(eps^2/4 / ln(1 - eps/2))
 // and the pdf is pdf[1] = 1 - (1 + 1/F)/(1 + eps)
 // pdf[i] = ((1 - pdf[1])F) / ((F-1)i(i-1)) for 2 <= i <= F
-func onlineSolitonDistribution(eps float64) []float64 {
+// eps must be in (0, 1); smaller values need fewer extra code blocks but give
+// a distribution with a higher maximum degree F.
+// The CDF is one-based: the probability of picking 1 from the distribution
+// is CDF[1].
+func OnlineSolitonDistribution(eps float64) []float64 {
 	f := math.Ceil(math.Log(eps*eps/4) / math.Log(1-(eps/2)))
 
 	cdf := make([]float64, int(f+1))
@@ -183,41 +193,18 @@ func centerBinomial(x int) int {
 	return choose(x, x/2)
 }
 
-// choose calculates (n k) or n choose k. Tolerant of quite large n/k.
+// choose calculates (n k) or n choose k. Each step of the product is itself a
+// binomial coefficient, so the divisions are exact, and the intermediate
+// values overflow only if the result nearly does.
 func choose(n int, k int) int {
 	if k > n/2 {
 		k = n - k
 	}
-	numerator := make([]int, n-k)
-	denominator := make([]int, n-k)
-	for i, j := k+1, 1; i <= n; i, j = i+1, j+1 {
-		numerator[j-1] = int(i)
-		denominator[j-1] = j
-	}
-
-	if len(denominator) > 0 {
-		// find the first member of numerator not in denominator
-		z := sort.SearchInts(numerator, denominator[len(denominator)-1])
-		if z > 0 {
-			numerator = numerator[z+1:]
-			denominator = denominator[0 : len(denominator)-z-1]
-		}
-	}
-
-	for j := len(denominator) - 1; j > 0; j-- {
-		for i := len(numerator) - 1; i >= 0; i-- {
-			if numerator[i]%denominator[j] == 0 {
-				numerator[i] /= denominator[j]
-				denominator[j] = 1
-				break
-			}
-		}
+	c := 1
+	for i := 1; i <= k; i++ {
+		c = c * (n - k + i) / i
 	}
-	f := 1
-	for _, i := range numerator {
-		f *= i
-	}
-	return f
+	return c
 }
 
 // bitSet returns true if x has the b'th bit set
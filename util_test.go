@@ -35,7 +35,7 @@ func TestSolitonDistribution(t *testing.T) {
 	tests = append(tests, 1, 10)
 
 	for _, n := range tests {
-		cdf := solitonDistribution(n)
+		cdf := SolitonDistribution(n)
 		if len(cdf) != n+1 {
 			t.Errorf("n=%d: Wrong length CDF: %d", n, len(cdf))
 			t.Log("CDF=", cdf)
@@ -59,7 +59,7 @@ func TestSolitonDistribution(t *testing.T) {
 }
 
 func TestRobustSolitonDistribution(t *testing.T) {
-	cdf := robustSolitonDistribution(10, 8, 0.1)
+	cdf := RobustSolitonDistribution(10, 8, 0.1)
 	if len(cdf) != 11 {
 		t.Errorf("Wrong length CDF: %d, should be 11", len(cdf))
 		t.Log("CDF=", cdf)
@@ -89,7 +89,7 @@ func TestRobustSolitonDistribution(t *testing.T) {
 }
 
 func TestOnlineSolitonDistribution(t *testing.T) {
-	cdf := onlineSolitonDistribution(0.1)
+	cdf := OnlineSolitonDistribution(0.1)
 	if len(cdf) != 118 {
 		t.Errorf("Wrong length CDF: %d. Should be 118", len(cdf))
 		t.Log("CDF=", cdf)
@@ -110,14 +110,27 @@ func TestOnlineSolitonDistribution(t *testing.T) {
 		t.Log("CDF=", cdf)
 	}
 
-	cdf = onlineSolitonDistribution(0.01)
+	cdf = OnlineSolitonDistribution(0.01)
 	if len(cdf) != 2116 {
 		t.Errorf("Wrong length CDF for 0.0: %d, should be 2116", len(cdf))
 	}
 }
 
+func TestDistributionsAreValidCDFs(t *testing.T) {
+	cdfs := map[string][]float64{
+		"soliton":        SolitonDistribution(20),
+		"robust soliton": RobustSolitonDistribution(20, 10, 0.05),
+		"online soliton": OnlineSolitonDistribution(0.2),
+	}
+	for name, cdf := range cdfs {
+		if err := checkDegreeCDF(cdf); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
 func TestPickDegree(t *testing.T) {
-	cdf := onlineSolitonDistribution(0.25)
+	cdf := OnlineSolitonDistribution(0.25)
 	random := rand.New(rand.NewSource(25))
 	var numLessThanFive int
 	for i := 0; i < 100; i++ {
@@ -218,6 +231,9 @@ func TestBinomial(t *testing.T) {
 		{7, 35},
 		{11, 462},
 		{12, 924},
+		{15, 6435},
+		{16, 12870},
+		{20, 184756},
 	}
 
 	for _, test := range binomialTests {
@@ -245,6 +261,8 @@ func TestChoose(t *testing.T) {
 		{52, 1, 52},
 		{52, 52, 1},
 		{52, 0, 1},
+		{15, 7, 6435},
+		{30, 15, 155117520},
 	}
 	for _, test := range chooseTests {
 		if choose(test.n, test.k) != test.comb {
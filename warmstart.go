@@ -0,0 +1,121 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// A receiver resuming a download, or updating a stale copy of an object,
+// already holds much of its data. If the sender publishes a digest of each
+// source block with the object's metadata, the receiver can check its local
+// copy block by block and load the blocks which match straight into its
+// decoder, so that code blocks from the network are only needed for what is
+// missing or changed.
+
+// SymbolDigest is the SHA-256 digest of one source block.
+type SymbolDigest [sha256.Size]byte
+
+// SymbolDigests splits a message into source blocks the way the package's
+// codecs do, and returns the digest of each block's bytes (without padding).
+func SymbolDigests(message []byte, sourceBlocks int) []SymbolDigest {
+	p := sourcePartition(len(message), sourceBlocks)
+	digests := make([]SymbolDigest, sourceBlocks)
+	for i := range digests {
+		digests[i] = sha256.Sum256(message[p.Offset(i):p.Offset(i+1)])
+	}
+	return digests
+}
+
+// sourcePartition returns the partition of a message into source blocks the
+// package's codecs use.
+func sourcePartition(messageLength, sourceBlocks int) Partition {
+	lenLong, lenShort, numLong, numShort := partition(messageLength, sourceBlocks)
+	return Partition{LongSize: lenLong, ShortSize: lenShort, NumLong: numLong, NumShort: numShort}
+}
+
+// sourcePreloader is implemented by the package's decoders.
+type sourcePreloader interface {
+	// preloadSource adds the equation stating that source block i is data.
+	preloadSource(i int, data []byte)
+}
+
+// WarmStart compares a local, possibly stale or partial, copy of an object
+// against the published digests of its source blocks, and loads each block
+// which matches into the decoder. The decoder must have been created by one of
+// the package's codecs for a message of messageLength bytes, and digests must
+// have one entry per source block; otherwise WarmStart returns an error and
+// loads nothing. Returns the number of blocks loaded.
+func WarmStart(d Decoder, local []byte, messageLength int, digests []SymbolDigest) (int, error) {
+	s, ok := d.(sourcePreloader)
+	k, known := decoderSourceBlocks(d)
+	length, _ := decoderLength(d)
+	if !ok || !known {
+		return 0, fmt.Errorf("fountain: WarmStart doesn't support %T", d)
+	}
+	if len(digests) != k || messageLength != length {
+		return 0, fmt.Errorf("fountain: %d digests of a %d-byte message, but the decoder has %d source blocks of a %d-byte message",
+			len(digests), messageLength, k, length)
+	}
+	p := sourcePartition(messageLength, len(digests))
+	loaded := 0
+	for i := range digests {
+		start, end := p.Offset(i), p.Offset(i+1)
+		if end > len(local) {
+			break
+		}
+		if sha256.Sum256(local[start:end]) != digests[i] {
+			continue
+		}
+		s.preloadSource(i, append([]byte(nil), local[start:end]...))
+		loaded++
+	}
+	return loaded, nil
+}
+
+// decoderSourceBlocks returns the number of source blocks of the message d
+// decodes, if d is one of the package's decoders which can preload them.
+func decoderSourceBlocks(d Decoder) (int, bool) {
+	if d, ok := d.(*reedSolomonDecoder); ok {
+		return d.codec.SourceBlocks(), true
+	}
+	if c := decoderCodec(d); c != nil {
+		return c.SourceBlocks(), true
+	}
+	return 0, false
+}
+
+func (d *lubyDecoder) preloadSource(i int, data []byte) {
+	d.matrix.addEquation([]int{i}, block{data: data})
+}
+
+func (d *binaryDecoder) preloadSource(i int, data []byte) {
+	d.matrix.addEquation([]int{i}, block{data: data})
+}
+
+func (d *onlineDecoder) preloadSource(i int, data []byte) {
+	d.matrix.addEquation([]int{i}, block{data: data})
+}
+
+// The R10 code is systematic: source block i is the code block with ESI i.
+func (d *raptorDecoder) preloadSource(i int, data []byte) {
+	d.matrix.addEquation(findLTIndices(d.codec.NumSourceSymbols, uint16(i)), block{data: data})
+}
+
+// The RU10 source blocks are the first K intermediate blocks.
+func (d *ru10Decoder) preloadSource(i int, data []byte) {
+	d.decoder.matrix.addEquation([]int{i}, block{data: data})
+}
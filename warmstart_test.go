@@ -0,0 +1,64 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWarmStart(t *testing.T) {
+	message := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	digests := SymbolDigests(message, 10)
+
+	// The local copy is stale in blocks 2 and 7, and truncated in block 9.
+	local := append([]byte(nil), message[:60]...)
+	local[15] = '!'
+	local[50] = '!'
+
+	codecs := testCodecs()
+	for _, c := range codecs {
+		d := c.NewDecoder(len(message))
+		loaded, err := WarmStart(d, local, len(message), digests)
+		if err != nil {
+			t.Fatalf("%T: WarmStart failed: %v", c, err)
+		}
+		if loaded != 7 {
+			t.Errorf("%T: loaded %d blocks, want 7", c, loaded)
+		}
+
+		received := 0
+		for id := int64(0); id < 500; id++ {
+			received++
+			if d.AddBlocks(EncodeLTBlocks(append([]byte(nil), message...), []int64{id}, c)) {
+				break
+			}
+		}
+		if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+			t.Errorf("%T: decoded %q, want %q", c, decoded, message)
+		}
+		t.Logf("%T: decoded after %d network blocks", c, received)
+	}
+
+	// Digests or a length not matching the decoder's load nothing.
+	c := codecs[0]
+	d := c.NewDecoder(len(message))
+	if loaded, err := WarmStart(d, local, len(message), SymbolDigests(message, 9)); err == nil || loaded != 0 {
+		t.Errorf("WarmStart with 9 digests for 10 source blocks = %d, %v; want an error", loaded, err)
+	}
+	if loaded, err := WarmStart(d, local, len(message)-1, digests); err == nil || loaded != 0 {
+		t.Errorf("WarmStart with the wrong message length = %d, %v; want an error", loaded, err)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import "github.com/google/gofountain/wire"
+
+// The primitives of the package's wire formats live in package wire. These
+// are aliases of them, kept so that existing code needn't change.
+
+// ByteOrder is the canonical byte order of fixed-width wire fields.
+var ByteOrder = wire.ByteOrder
+
+// WireFlagLittleEndian marks a wire structure whose fixed-width fields were
+// written in little-endian order.
+const WireFlagLittleEndian = wire.FlagLittleEndian
+
+// WireFlagChecksum marks a wire structure which is followed by a CRC-32C
+// (Castagnoli) checksum of its encoding, header included.
+const WireFlagChecksum = wire.FlagChecksum
+
+// WireHeaderLen is the length in bytes of an encoded WireHeader.
+const WireHeaderLen = wire.HeaderLen
+
+// WireHeader is the header which starts every wire structure.
+type WireHeader = wire.Header
+
+// ErrShortWireData is returned when wire data ends before the structure does.
+// It is wire.ErrShortData.
+var ErrShortWireData = wire.ErrShortData
+
+// ParseWireHeader decodes the header at the start of b, and returns it along
+// with the remainder of b (see wire.ParseHeader).
+func ParseWireHeader(b []byte, maxVersion uint8) (WireHeader, []byte, error) {
+	return wire.ParseHeader(b, maxVersion)
+}
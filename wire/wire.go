@@ -0,0 +1,87 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wire holds the primitives of gofountain's wire formats, which
+// package fountain and the transports built on it share.
+//
+// Every wire structure begins with a two-byte Header: a format version
+// followed by a flags byte. Multi-byte fixed-width fields are written in
+// network byte order (big-endian). Some deployed peers wrote little-endian
+// fields instead; they are recognized by a flag bit in the header, so readers
+// interpret them correctly rather than misparsing IDs. Writers always produce
+// the canonical form.
+//
+// Package fountain keeps its names for these (fountain.WireHeader and so on)
+// as aliases, so existing code needn't change.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ByteOrder is the canonical byte order of fixed-width wire fields.
+var ByteOrder = binary.BigEndian
+
+// FlagLittleEndian marks a wire structure whose fixed-width fields were
+// written in little-endian order.
+const FlagLittleEndian = 0x01
+
+// FlagChecksum marks a wire structure which is followed by a CRC-32C
+// (Castagnoli) checksum of its encoding, header included.
+const FlagChecksum = 0x02
+
+// HeaderLen is the length in bytes of an encoded Header.
+const HeaderLen = 2
+
+// Header is the header which starts every wire structure.
+type Header struct {
+	// Version is the version of the structure's format.
+	Version uint8
+
+	// Flags is a bit field of Flag values.
+	Flags uint8
+}
+
+// ErrShortData is returned when wire data ends before the structure does.
+var ErrShortData = errors.New("wire: data too short")
+
+// ByteOrder returns the byte order of the fixed-width fields following the
+// header.
+func (h Header) ByteOrder() binary.ByteOrder {
+	if h.Flags&FlagLittleEndian != 0 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// AppendTo appends the encoded header to b and returns the extended slice.
+func (h Header) AppendTo(b []byte) []byte {
+	return append(b, h.Version, h.Flags)
+}
+
+// ParseHeader decodes the header at the start of b, and returns it along with
+// the remainder of b. Returns an error if b is too short or the version is
+// greater than maxVersion.
+func ParseHeader(b []byte, maxVersion uint8) (Header, []byte, error) {
+	if len(b) < HeaderLen {
+		return Header{}, nil, ErrShortData
+	}
+	h := Header{Version: b[0], Flags: b[1]}
+	if h.Version > maxVersion {
+		return h, nil, fmt.Errorf("wire: unsupported format version %d", h.Version)
+	}
+	return h, b[HeaderLen:], nil
+}
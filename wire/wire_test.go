@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	b := Header{Version: 1}.AppendTo(nil)
+	b = ByteOrder.AppendUint32(b, 0x01020304)
+	if b[2] != 1 || b[5] != 4 {
+		t.Errorf("Canonical encoding is %v, want big-endian fields", b)
+	}
+
+	h, rest, err := ParseHeader(b, 1)
+	if err != nil || h.Version != 1 || h.ByteOrder() != binary.BigEndian {
+		t.Fatalf("ParseHeader = (%+v, %v), want version 1, big-endian", h, err)
+	}
+	if v := h.ByteOrder().Uint32(rest); v != 0x01020304 {
+		t.Errorf("Read %x, want 1020304", v)
+	}
+
+	// A little-endian peer's structure, flagged as such, reads the same.
+	le := Header{Version: 1, Flags: FlagLittleEndian}.AppendTo(nil)
+	le = binary.LittleEndian.AppendUint32(le, 0x01020304)
+	h, rest, err = ParseHeader(le, 1)
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	if v := h.ByteOrder().Uint32(rest); v != 0x01020304 {
+		t.Errorf("Read %x from little-endian data, want 1020304", v)
+	}
+
+	if _, _, err := ParseHeader(b, 0); err == nil {
+		t.Errorf("ParseHeader accepted version 1 with max version 0")
+	}
+	if _, _, err := ParseHeader(b[:1], 1); err != ErrShortData {
+		t.Errorf("ParseHeader of one byte gave %v, want ErrShortData", err)
+	}
+}
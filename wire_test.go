@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestWireHeader(t *testing.T) {
+	b := WireHeader{Version: 1}.AppendTo(nil)
+	b = ByteOrder.AppendUint32(b, 0x01020304)
+	if b[2] != 1 || b[5] != 4 {
+		t.Errorf("Canonical encoding is %v, want big-endian fields", b)
+	}
+
+	h, rest, err := ParseWireHeader(b, 1)
+	if err != nil || h.Version != 1 || h.ByteOrder() != binary.BigEndian {
+		t.Fatalf("ParseWireHeader = (%+v, %v), want version 1, big-endian", h, err)
+	}
+	if v := h.ByteOrder().Uint32(rest); v != 0x01020304 {
+		t.Errorf("Read %x, want 1020304", v)
+	}
+
+	// A little-endian peer's structure, flagged as such, reads the same.
+	le := WireHeader{Version: 1, Flags: WireFlagLittleEndian}.AppendTo(nil)
+	le = binary.LittleEndian.AppendUint32(le, 0x01020304)
+	h, rest, err = ParseWireHeader(le, 1)
+	if err != nil {
+		t.Fatalf("ParseWireHeader failed: %v", err)
+	}
+	if v := h.ByteOrder().Uint32(rest); v != 0x01020304 {
+		t.Errorf("Read %x from little-endian data, want 1020304", v)
+	}
+
+	if _, _, err := ParseWireHeader(b, 0); err == nil {
+		t.Errorf("ParseWireHeader accepted version 1 with max version 0")
+	}
+	if _, _, err := ParseWireHeader(b[:1], 1); err != ErrShortWireData {
+		t.Errorf("ParseWireHeader of one byte gave %v, want ErrShortWireData", err)
+	}
+}
@@ -0,0 +1,463 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bats implements BATS codes (batched sparse codes), for carrying a
+// message across a multi-hop network, such as a mesh, with losses on every
+// hop.
+//
+// With a fountain code, a relay either forwards the code blocks it receives,
+// so the losses of all the hops add up, or decodes the whole message and
+// encodes it afresh, which delays it and costs the relay the memory and work
+// of a decoder. A BATS code is a fountain code whose code blocks come in
+// batches: each batch is a set of BatchSize packets, each a random linear
+// combination over GF(2^8) of the same few source packets. A relay combines
+// the packets it has received of a batch into new random combinations,
+// recoding without decoding, so each hop only has to deliver enough of each
+// batch, and every packet carries its coefficients over the batch so that
+// the receiver can follow the combinations.
+//
+// The outer code picks the source packets of each batch, and its generator
+// matrix, from the batch ID and a seed, like fountain.NewSeededLubyCodec. The
+// decoder here solves the packets it receives by Gaussian elimination as they
+// arrive, rather than by the belief propagation of Yang and Yeung's paper
+// ("Batched Sparse Codes", 2014), which makes it simpler and independent of
+// the degree distribution, at a cost quadratic in SourcePackets.
+//
+// This package is experimental (see package x).
+package bats
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+
+	fountain "github.com/google/gofountain"
+	"github.com/google/gofountain/internal/gf256"
+)
+
+// ErrInvalidParams is returned for parameters out of range, and for packets
+// which don't match the parameters.
+var ErrInvalidParams = errors.New("bats: invalid parameters")
+
+// Params are the parameters of a BATS code, which the encoder and the
+// decoder must agree on. Relays only need BatchSize.
+type Params struct {
+	// SourcePackets is the number of source packets (K) the message is split
+	// into, and PacketSize their size in bytes.
+	SourcePackets, PacketSize int
+
+	// BatchSize is the number of packets in a batch (M).
+	BatchSize int
+
+	// Seed is mixed with the batch ID to pick the source packets and the
+	// generator matrix of each batch.
+	Seed int64
+
+	// DegreeCDF is the distribution of the number of source packets combined
+	// in a batch, one-based like fountain.SolitonDistribution, over degrees up
+	// to SourcePackets. If nil, the degree is BatchSize times a degree drawn
+	// from the ideal soliton distribution over the ceil(K/M) groups of source
+	// packets, so that each batch has at least as many source packets as it
+	// has packets.
+	DegreeCDF []float64
+}
+
+// check returns an error wrapping ErrInvalidParams if the parameters are out
+// of range, and otherwise the degree CDF.
+func (p Params) check() ([]float64, error) {
+	if p.SourcePackets < 1 || p.PacketSize < 1 || p.BatchSize < 1 {
+		return nil, fmt.Errorf("%w: %d source packets of %d bytes, batch size %d",
+			ErrInvalidParams, p.SourcePackets, p.PacketSize, p.BatchSize)
+	}
+	if p.DegreeCDF == nil {
+		return p.defaultDegrees(), nil
+	}
+	cdf := p.DegreeCDF
+	if len(cdf) < 2 || len(cdf) > p.SourcePackets+1 || !sort.Float64sAreSorted(cdf) ||
+		math.Abs(cdf[len(cdf)-1]-1) > 1e-9 {
+		return nil, fmt.Errorf("%w: degree CDF must be nondecreasing, end at 1 and cover degrees up to %d",
+			ErrInvalidParams, p.SourcePackets)
+	}
+	return cdf, nil
+}
+
+// defaultDegrees returns the default degree CDF, described at DegreeCDF.
+func (p Params) defaultDegrees() []float64 {
+	groups := (p.SourcePackets + p.BatchSize - 1) / p.BatchSize
+	soliton := fountain.SolitonDistribution(groups)
+	cdf := make([]float64, p.SourcePackets+1)
+	for d := 1; d < len(soliton); d++ {
+		cdf[min(p.SourcePackets, d*p.BatchSize)] += soliton[d] - soliton[d-1]
+	}
+	for i := 1; i < len(cdf); i++ {
+		cdf[i] += cdf[i-1]
+	}
+	return cdf
+}
+
+// composition returns the source packets of a batch and its generator
+// matrix: packet m of the batch is the sum over k of g[k][m] times source
+// packet indices[k].
+func (p Params) composition(cdf []float64, batch int64) (indices []int, g [][]byte) {
+	t := &fountain.MersenneTwister64{}
+	t.SeedSlice([]uint64{uint64(p.Seed), uint64(batch)})
+	random := rand.New(t)
+
+	degree := sort.SearchFloat64s(cdf, random.Float64())
+	degree = max(1, min(degree, len(cdf)-1, p.SourcePackets))
+	// Floyd's algorithm samples degree distinct source packets.
+	chosen := make(map[int]bool, degree)
+	for j := p.SourcePackets - degree; j < p.SourcePackets; j++ {
+		i := random.Intn(j + 1)
+		if chosen[i] {
+			i = j
+		}
+		chosen[i] = true
+		indices = append(indices, i)
+	}
+
+	g = make([][]byte, degree)
+	for k := range g {
+		g[k] = make([]byte, p.BatchSize)
+		random.Read(g[k])
+	}
+	return indices, g
+}
+
+// Packet is a packet of a batch.
+type Packet struct {
+	// Batch is the ID of the batch.
+	Batch int64
+
+	// Coefficients is the packet's coding vector over the BatchSize packets
+	// the encoder generated for the batch: the packet is the sum of
+	// Coefficients[m] times encoded packet m.
+	Coefficients []byte
+
+	// Data is the packet's data.
+	Data []byte
+}
+
+// packetVersion is the wire format version of a packet.
+const packetVersion = 1
+
+// AppendBinary appends the wire encoding of the packet to b: a
+// fountain.WireHeader, then the batch ID, the number of coefficients and the
+// coefficients, and the length of the data and the data, the numbers as
+// uvarints. Implements encoding.BinaryAppender.
+func (p Packet) AppendBinary(b []byte) ([]byte, error) {
+	b = fountain.WireHeader{Version: packetVersion}.AppendTo(b)
+	b = binary.AppendUvarint(b, uint64(p.Batch))
+	b = binary.AppendUvarint(b, uint64(len(p.Coefficients)))
+	b = append(b, p.Coefficients...)
+	b = binary.AppendUvarint(b, uint64(len(p.Data)))
+	return append(b, p.Data...), nil
+}
+
+// ParsePacket decodes the packet at the start of b, written by AppendBinary,
+// and returns it along with the remainder of b. The packet's coefficients and
+// data alias b.
+func ParsePacket(b []byte) (Packet, []byte, error) {
+	_, b, err := fountain.ParseWireHeader(b, packetVersion)
+	if err != nil {
+		return Packet{}, nil, err
+	}
+	batch, n := binary.Uvarint(b)
+	if n <= 0 {
+		return Packet{}, nil, fountain.ErrShortWireData
+	}
+	b = b[n:]
+	var fields [2][]byte
+	for i := range fields {
+		length, n := binary.Uvarint(b)
+		if n <= 0 || length > uint64(len(b)-n) {
+			return Packet{}, nil, fountain.ErrShortWireData
+		}
+		b = b[n:]
+		fields[i], b = b[:length:length], b[length:]
+	}
+	return Packet{Batch: int64(batch), Coefficients: fields[0], Data: fields[1]}, b, nil
+}
+
+// Encoder generates the batches of a message.
+type Encoder struct {
+	params Params
+	cdf    []float64
+	source [][]byte
+}
+
+// NewEncoder creates an encoder for a message of at most SourcePackets times
+// PacketSize bytes, which is zero-padded to that length. The message is
+// copied.
+func NewEncoder(p Params, message []byte) (*Encoder, error) {
+	cdf, err := p.check()
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > p.SourcePackets*p.PacketSize {
+		return nil, fmt.Errorf("%w: %d-byte message is longer than %d packets of %d bytes",
+			ErrInvalidParams, len(message), p.SourcePackets, p.PacketSize)
+	}
+	e := &Encoder{params: p, cdf: cdf, source: make([][]byte, p.SourcePackets)}
+	for i := range e.source {
+		e.source[i] = make([]byte, p.PacketSize)
+		if start := i * p.PacketSize; start < len(message) {
+			copy(e.source[i], message[start:])
+		}
+	}
+	return e, nil
+}
+
+// Batch returns the BatchSize packets of the batch with the given ID. Packet
+// m has the m-th unit vector as its coefficients.
+func (e *Encoder) Batch(id int64) []Packet {
+	indices, g := e.params.composition(e.cdf, id)
+	packets := make([]Packet, e.params.BatchSize)
+	for m := range packets {
+		packets[m] = Packet{
+			Batch:        id,
+			Coefficients: make([]byte, e.params.BatchSize),
+			Data:         make([]byte, e.params.PacketSize),
+		}
+		packets[m].Coefficients[m] = 1
+		for k, i := range indices {
+			gf256.MulAdd(packets[m].Data, e.source[i], g[k][m])
+		}
+	}
+	return packets
+}
+
+// Recoder recodes batches at a relay. It keeps the linearly independent
+// packets it receives of each batch, at most BatchSize of them, until the
+// batch is forgotten.
+type Recoder struct {
+	batchSize int
+	batches   map[int64]*basis
+}
+
+// basis holds linearly independent packets of a batch, in echelon form: the
+// packet at rows[i] has its first nonzero coefficient, 1, at i.
+type basis struct {
+	rows []*Packet
+	rank int
+}
+
+// NewRecoder creates a recoder for batches of batchSize packets.
+func NewRecoder(batchSize int) (*Recoder, error) {
+	if batchSize < 1 {
+		return nil, fmt.Errorf("%w: batch size %d", ErrInvalidParams, batchSize)
+	}
+	return &Recoder{batchSize: batchSize, batches: make(map[int64]*basis)}, nil
+}
+
+// Add adds a received packet, which is copied, and reports whether it was
+// linearly independent of those already held of its batch. Returns an error
+// wrapping ErrInvalidParams if it has the wrong number of coefficients or a
+// different length from those held.
+func (r *Recoder) Add(p Packet) (bool, error) {
+	if len(p.Coefficients) != r.batchSize {
+		return false, fmt.Errorf("%w: packet has %d coefficients, batch size is %d",
+			ErrInvalidParams, len(p.Coefficients), r.batchSize)
+	}
+	b := r.batches[p.Batch]
+	if b == nil {
+		b = &basis{rows: make([]*Packet, r.batchSize)}
+		r.batches[p.Batch] = b
+	}
+	for _, row := range b.rows {
+		if row != nil && len(row.Data) != len(p.Data) {
+			return false, fmt.Errorf("%w: %d-byte packet in a batch of %d-byte packets",
+				ErrInvalidParams, len(p.Data), len(row.Data))
+		}
+	}
+	q := &Packet{Batch: p.Batch, Coefficients: slices.Clone(p.Coefficients), Data: slices.Clone(p.Data)}
+	for i, c := range q.Coefficients {
+		if c == 0 {
+			continue
+		}
+		if row := b.rows[i]; row != nil {
+			gf256.MulAdd(q.Coefficients, row.Coefficients, c)
+			gf256.MulAdd(q.Data, row.Data, c)
+			continue
+		}
+		inv := gf256.Inverse(c)
+		gf256.Scale(q.Coefficients, inv)
+		gf256.Scale(q.Data, inv)
+		b.rows[i] = q
+		b.rank++
+		return true, nil
+	}
+	return false, nil
+}
+
+// Rank returns the number of linearly independent packets held of a batch.
+// A relay needs no more than that many packets to pass on all it has of it.
+func (r *Recoder) Rank(batch int64) int {
+	if b := r.batches[batch]; b != nil {
+		return b.rank
+	}
+	return 0
+}
+
+// Recode returns n new packets of a batch, each a random linear combination,
+// with coefficients from random, of the packets held. Returns nil if none are
+// held.
+func (r *Recoder) Recode(batch int64, n int, random *rand.Rand) []Packet {
+	b := r.batches[batch]
+	if b == nil || b.rank == 0 {
+		return nil
+	}
+	var size int
+	for _, row := range b.rows {
+		if row != nil {
+			size = len(row.Data)
+		}
+	}
+	packets := make([]Packet, n)
+	for j := range packets {
+		p := Packet{Batch: batch, Coefficients: make([]byte, r.batchSize), Data: make([]byte, size)}
+		for _, row := range b.rows {
+			if row == nil {
+				continue
+			}
+			f := byte(random.Intn(256))
+			gf256.MulAdd(p.Coefficients, row.Coefficients, f)
+			gf256.MulAdd(p.Data, row.Data, f)
+		}
+		packets[j] = p
+	}
+	return packets
+}
+
+// Forget discards the packets held of a batch, once the relay has passed it
+// on.
+func (r *Recoder) Forget(batch int64) {
+	delete(r.batches, batch)
+}
+
+// Decoder decodes a message from packets of its batches.
+type Decoder struct {
+	params        Params
+	cdf           []float64
+	messageLength int
+
+	// rows and values are the equations received, in echelon form: rows[i],
+	// if not nil, has its first nonzero coefficient, 1, at source packet i,
+	// and values[i] is its value. Once the decoder is determined, solved
+	// records that the rows have been reduced to the identity.
+	rows, values [][]byte
+	rank         int
+	solved       bool
+}
+
+// NewDecoder creates a decoder for a message of messageLength bytes.
+func NewDecoder(p Params, messageLength int) (*Decoder, error) {
+	cdf, err := p.check()
+	if err != nil {
+		return nil, err
+	}
+	if messageLength < 0 || messageLength > p.SourcePackets*p.PacketSize {
+		return nil, fmt.Errorf("%w: %d-byte message doesn't fit %d packets of %d bytes",
+			ErrInvalidParams, messageLength, p.SourcePackets, p.PacketSize)
+	}
+	return &Decoder{
+		params:        p,
+		cdf:           cdf,
+		messageLength: messageLength,
+		rows:          make([][]byte, p.SourcePackets),
+		values:        make([][]byte, p.SourcePackets),
+	}, nil
+}
+
+// Add adds a received packet, and reports whether the message can be
+// decoded. Returns an error wrapping ErrInvalidParams if the packet doesn't
+// match the parameters.
+func (d *Decoder) Add(p Packet) (bool, error) {
+	if len(p.Coefficients) != d.params.BatchSize || len(p.Data) != d.params.PacketSize {
+		return false, fmt.Errorf("%w: packet with %d coefficients and %d bytes of data",
+			ErrInvalidParams, len(p.Coefficients), len(p.Data))
+	}
+	if d.Determined() {
+		return true, nil
+	}
+
+	// The packet is the sum over m of Coefficients[m] times encoded packet m,
+	// which is the sum over k of g[k][m] times source packet indices[k].
+	indices, g := d.params.composition(d.cdf, p.Batch)
+	row := make([]byte, d.params.SourcePackets)
+	for k, i := range indices {
+		for m, c := range p.Coefficients {
+			row[i] ^= gf256.Mul(g[k][m], c)
+		}
+	}
+	value := slices.Clone(p.Data)
+	for i, c := range row {
+		if c == 0 {
+			continue
+		}
+		if d.rows[i] != nil {
+			gf256.MulAdd(row, d.rows[i], c)
+			gf256.MulAdd(value, d.values[i], c)
+			continue
+		}
+		inv := gf256.Inverse(c)
+		gf256.Scale(row, inv)
+		gf256.Scale(value, inv)
+		d.rows[i], d.values[i] = row, value
+		d.rank++
+		break
+	}
+	return d.Determined(), nil
+}
+
+// Rank returns the number of linearly independent equations the packets
+// received give for the source packets. The message can be decoded once it
+// reaches SourcePackets.
+func (d *Decoder) Rank() int {
+	return d.rank
+}
+
+// Determined reports whether the message can be decoded.
+func (d *Decoder) Determined() bool {
+	return d.rank == d.params.SourcePackets
+}
+
+// Decode returns the message, or nil if it can't be decoded yet.
+func (d *Decoder) Decode() []byte {
+	if !d.Determined() {
+		return nil
+	}
+	if !d.solved {
+		// Substitute back from the last source packet, which its row alone
+		// determines.
+		for i := len(d.rows) - 1; i >= 0; i-- {
+			for j := i + 1; j < len(d.rows); j++ {
+				if c := d.rows[i][j]; c != 0 {
+					gf256.MulAdd(d.values[i], d.values[j], c)
+					d.rows[i][j] = 0
+				}
+			}
+		}
+		d.solved = true
+	}
+	out := make([]byte, 0, d.params.SourcePackets*d.params.PacketSize)
+	for _, v := range d.values {
+		out = append(out, v...)
+	}
+	return out[:d.messageLength]
+}
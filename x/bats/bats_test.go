@@ -0,0 +1,190 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bats
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func testMessage(n int) []byte {
+	message := make([]byte, n)
+	rand.New(rand.NewSource(3)).Read(message)
+	return message
+}
+
+func TestDirect(t *testing.T) {
+	p := Params{SourcePackets: 40, PacketSize: 32, BatchSize: 8, Seed: 5}
+	message := testMessage(40*32 - 7)
+	e, err := NewEncoder(p, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(p, len(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := 0
+	for batch := int64(0); !d.Determined(); batch++ {
+		if batch > 50 {
+			t.Fatalf("not decoded after %d batches, rank %d", batch, d.Rank())
+		}
+		for _, packet := range e.Batch(batch) {
+			received++
+			if ok, err := d.Add(packet); err != nil {
+				t.Fatal(err)
+			} else if ok {
+				break
+			}
+		}
+	}
+	t.Logf("decoded %d source packets from %d packets", p.SourcePackets, received)
+	if got := d.Decode(); !bytes.Equal(got, message) {
+		t.Error("decoded message differs")
+	}
+}
+
+func TestRelay(t *testing.T) {
+	// Each of two hops loses a quarter of its packets. The relay passes on
+	// BatchSize recoded packets of each batch, combining whatever it got,
+	// without ever decoding.
+	p := Params{SourcePackets: 64, PacketSize: 16, BatchSize: 16, Seed: 9}
+	message := testMessage(64 * 16)
+	e, err := NewEncoder(p, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay, err := NewRecoder(p.BatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(p, len(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(1))
+	batches := int64(0)
+	for ; !d.Determined(); batches++ {
+		if batches > 40 {
+			t.Fatalf("not decoded after %d batches, rank %d", batches, d.Rank())
+		}
+		for _, packet := range e.Batch(batches) {
+			if random.Float64() >= 0.25 {
+				if _, err := relay.Add(packet); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		for _, packet := range relay.Recode(batches, p.BatchSize, random) {
+			if random.Float64() >= 0.25 {
+				if _, err := d.Add(packet); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		relay.Forget(batches)
+	}
+	t.Logf("decoded %d source packets from %d batches of %d", p.SourcePackets, batches, p.BatchSize)
+	if got := d.Decode(); !bytes.Equal(got, message) {
+		t.Error("decoded message differs")
+	}
+}
+
+func TestRecoderRank(t *testing.T) {
+	p := Params{SourcePackets: 10, PacketSize: 4, BatchSize: 3}
+	e, _ := NewEncoder(p, testMessage(40))
+	r, _ := NewRecoder(3)
+	packets := e.Batch(7)
+	for i, packet := range packets {
+		if ok, err := r.Add(packet); !ok || err != nil {
+			t.Fatalf("Add of packet %d = %v, %v; want independent", i, ok, err)
+		}
+	}
+	random := rand.New(rand.NewSource(2))
+	for _, packet := range r.Recode(7, 5, random) {
+		if ok, _ := r.Add(packet); ok {
+			t.Error("recoded packet is independent of the batch")
+		}
+	}
+	if r.Rank(7) != 3 {
+		t.Errorf("Rank = %d, want 3", r.Rank(7))
+	}
+	if r.Recode(8, 1, random) != nil {
+		t.Error("Recode of an unknown batch returned packets")
+	}
+	if _, err := r.Add(Packet{Batch: 7, Coefficients: []byte{1, 2}}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Add of a packet with 2 coefficients: err = %v, want ErrInvalidParams", err)
+	}
+	r.Forget(7)
+	if r.Rank(7) != 0 {
+		t.Errorf("Rank after Forget = %d, want 0", r.Rank(7))
+	}
+}
+
+func TestPacketWireRoundTrip(t *testing.T) {
+	packets := []Packet{
+		{Batch: 3, Coefficients: []byte{0, 1, 0}, Data: []byte("abcd")},
+		{Batch: 1 << 40, Coefficients: []byte{9, 8, 7}, Data: []byte("wxyz")},
+	}
+	var b []byte
+	for _, p := range packets {
+		b, _ = p.AppendBinary(b)
+	}
+	for i, want := range packets {
+		var got Packet
+		var err error
+		got, b, err = ParsePacket(b)
+		if err != nil {
+			t.Fatalf("ParsePacket %d: %v", i, err)
+		}
+		if got.Batch != want.Batch || !bytes.Equal(got.Coefficients, want.Coefficients) || !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("ParsePacket %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if len(b) != 0 {
+		t.Errorf("%d bytes left over", len(b))
+	}
+	full, _ := packets[1].AppendBinary(nil)
+	for n := range full {
+		if _, _, err := ParsePacket(full[:n]); err == nil {
+			t.Errorf("ParsePacket of %d of %d bytes succeeded", n, len(full))
+		}
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	invalid := []Params{
+		{SourcePackets: 0, PacketSize: 4, BatchSize: 2},
+		{SourcePackets: 4, PacketSize: 0, BatchSize: 2},
+		{SourcePackets: 4, PacketSize: 4, BatchSize: 0},
+		{SourcePackets: 4, PacketSize: 4, BatchSize: 2, DegreeCDF: []float64{0, 0.5}},
+		{SourcePackets: 2, PacketSize: 4, BatchSize: 2, DegreeCDF: []float64{0, 0.2, 0.5, 1}},
+	}
+	for _, p := range invalid {
+		if _, err := NewDecoder(p, 0); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("NewDecoder(%+v): err = %v, want ErrInvalidParams", p, err)
+		}
+	}
+	p := Params{SourcePackets: 4, PacketSize: 4, BatchSize: 2}
+	if _, err := NewEncoder(p, make([]byte, 17)); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("NewEncoder of 17 bytes: err = %v, want ErrInvalidParams", err)
+	}
+	d, _ := NewDecoder(p, 16)
+	if _, err := d.Add(Packet{Coefficients: []byte{1, 0}, Data: []byte{1}}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Add of a 1-byte packet: err = %v, want ErrInvalidParams", err)
+	}
+}
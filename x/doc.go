@@ -0,0 +1,41 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package x is the root of gofountain's experimental packages.
+//
+// The API of package fountain is stable: its Codec, Encoder and Decoder
+// interfaces, and its other exported names, don't change incompatibly, and
+// the same holds for fountainnet and wire. Large new subsystems, such as
+// transports, GF(256) arithmetic or the RaptorQ code, need room to change
+// while their design settles, so they start out as packages under x, which
+// carry no such promise: they may change incompatibly, or be removed, in any
+// release. Experimental packages may depend on package fountain, but not the
+// other way around, so the stable API never changes to suit them.
+//
+// Two kinds of addition are stable from the start instead. Layers over the
+// codecs which need package fountain's unexported encoders and decoders land
+// in package fountain itself, as the object layer, the container format,
+// pipelines and pcap capture did. And fountainnet, the RFC 5053 datagram
+// transport with its Sender, Receiver and Carousel, is stable: it carries
+// only the packet format the RFC fixes, so it has no design left to settle.
+// Transports with formats of their own, such as fountainhttp and quicdgram,
+// start under x like any other subsystem.
+//
+// An experimental package is promoted once its API has gone unchanged for a
+// release, is documented and tested to the standard of package fountain, and
+// doesn't need anything of package fountain that it doesn't export. It then
+// moves out of x, either into package fountain or to a package of its own,
+// and its x path is kept for one more release as a deprecated package of
+// aliases to the promoted names.
+package x
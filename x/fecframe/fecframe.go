@@ -0,0 +1,471 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fecframe implements the Raptor FEC scheme for arbitrary packet flows
+// of RFC 6681, so that the R10 raptor code can protect the packets of a
+// streaming stack built on the FEC Framework (FECFRAME, RFC 6363).
+//
+// The sender collects the application data units (ADUs, typically the
+// payloads of RTP or UDP packets) of one or more flows into source blocks of
+// symbols: each ADU is written as an ADU Information (ADUI): its flow ID F (1
+// byte), its length L (2 bytes), its data and zero padding to a whole number
+// of symbols. Source packets are sent as they come, carrying the ADU followed
+// by an explicit Source FEC Payload ID, the source block number and the ESI
+// of the ADUI's first symbol. Once a source block is closed, repair packets
+// carry a Repair FEC Payload ID, which adds the number of source symbols in
+// the block (the source block length, SBL), followed by a repair symbol. A
+// receiver passes the source packets it gets to the application at once, and
+// recovers the lost ones from the repair packets.
+//
+// R10 needs at least 4 source symbols, so shorter source blocks are padded
+// with zero symbols, which read as empty ADUs of flow 0. The decoder never
+// returns those, so empty ADUs of flow 0 can't be recovered.
+//
+// This package is experimental (see package x).
+package fecframe
+
+import (
+	"errors"
+	"fmt"
+
+	fountain "github.com/google/gofountain"
+)
+
+// RaptorEncodingID is the FECFRAME FEC Encoding ID of the Raptor FEC scheme
+// for arbitrary packet flows.
+const RaptorEncodingID = 1
+
+// Lengths in bytes of the encoded OTI and payload IDs.
+const (
+	OTILen             = 4
+	SourcePayloadIDLen = 4
+	RepairPayloadIDLen = 6
+)
+
+// aduiHeaderLen is the length of the flow ID and length at the start of an
+// ADUI.
+const aduiHeaderLen = 3
+
+// The range of source block lengths R10 supports.
+const (
+	minSourceBlockLen = fountain.MinRaptorSourceSymbols
+	maxSourceBlockLen = fountain.MaxRaptorSourceSymbols
+)
+
+// alignment is the symbol alignment of the raptor codec. Any symbol size T
+// will do.
+const alignment = 1
+
+// ErrNoSourceBlock is returned by Encoder.Repair before any source block has
+// been closed.
+var ErrNoSourceBlock = errors.New("fecframe: no source block to repair")
+
+// OTI is the FEC Scheme-Specific Information of the scheme, which the sender
+// signals to receivers in the FEC Framework Configuration Information, for
+// instance in SDP.
+type OTI struct {
+	// SymbolSize is the encoding symbol size T in bytes.
+	SymbolSize uint16
+
+	// MaxSourceBlockLength is the largest number of source symbols in a
+	// source block, in [4, 8192].
+	MaxSourceBlockLength uint16
+}
+
+// check returns an error if the OTI is out of range.
+func (o OTI) check() error {
+	if o.SymbolSize == 0 || o.MaxSourceBlockLength < minSourceBlockLen || o.MaxSourceBlockLength > maxSourceBlockLen {
+		return fmt.Errorf("fecframe: invalid OTI: T=%d, maximum source block length %d not in [%d, %d]",
+			o.SymbolSize, o.MaxSourceBlockLength, minSourceBlockLen, maxSourceBlockLen)
+	}
+	return nil
+}
+
+// AppendTo appends the encoded OTI to b: T, then the maximum source block
+// length, in network byte order.
+func (o OTI) AppendTo(b []byte) []byte {
+	b = fountain.ByteOrder.AppendUint16(b, o.SymbolSize)
+	return fountain.ByteOrder.AppendUint16(b, o.MaxSourceBlockLength)
+}
+
+// ParseOTI decodes the OTI at the start of b, and returns it along with the
+// remainder of b.
+func ParseOTI(b []byte) (OTI, []byte, error) {
+	if len(b) < OTILen {
+		return OTI{}, nil, fountain.ErrShortWireData
+	}
+	o := OTI{SymbolSize: fountain.ByteOrder.Uint16(b), MaxSourceBlockLength: fountain.ByteOrder.Uint16(b[2:])}
+	return o, b[OTILen:], o.check()
+}
+
+// SourcePayloadID is the Source FEC Payload ID of a source packet.
+type SourcePayloadID struct {
+	// SBN is the source block number.
+	SBN uint16
+
+	// ESI is the encoding symbol ID of the first symbol of the ADUI.
+	ESI uint16
+}
+
+// AppendTo appends the encoded Source FEC Payload ID to b.
+func (p SourcePayloadID) AppendTo(b []byte) []byte {
+	b = fountain.ByteOrder.AppendUint16(b, p.SBN)
+	return fountain.ByteOrder.AppendUint16(b, p.ESI)
+}
+
+// RepairPayloadID is the Repair FEC Payload ID of a repair packet.
+type RepairPayloadID struct {
+	// SBN is the source block number.
+	SBN uint16
+
+	// ESI is the encoding symbol ID of the repair symbol.
+	ESI uint16
+
+	// SourceBlockLength is the number of source symbols in the source block.
+	SourceBlockLength uint16
+}
+
+// AppendTo appends the encoded Repair FEC Payload ID to b.
+func (p RepairPayloadID) AppendTo(b []byte) []byte {
+	b = fountain.ByteOrder.AppendUint16(b, p.SBN)
+	b = fountain.ByteOrder.AppendUint16(b, p.ESI)
+	return fountain.ByteOrder.AppendUint16(b, p.SourceBlockLength)
+}
+
+// AppendSourcePacket appends a source packet to b: the ADU followed by its
+// Source FEC Payload ID.
+func AppendSourcePacket(b, adu []byte, id SourcePayloadID) []byte {
+	return id.AppendTo(append(b, adu...))
+}
+
+// ParseSourcePacket splits a source packet into its ADU and its Source FEC
+// Payload ID. The ADU aliases p.
+func ParseSourcePacket(p []byte) ([]byte, SourcePayloadID, error) {
+	if len(p) < SourcePayloadIDLen {
+		return nil, SourcePayloadID{}, fountain.ErrShortWireData
+	}
+	n := len(p) - SourcePayloadIDLen
+	id := SourcePayloadID{SBN: fountain.ByteOrder.Uint16(p[n:]), ESI: fountain.ByteOrder.Uint16(p[n+2:])}
+	return p[:n:n], id, nil
+}
+
+// RepairPacket is a repair packet: a repair symbol and its Repair FEC Payload
+// ID.
+type RepairPacket struct {
+	ID     RepairPayloadID
+	Symbol []byte
+}
+
+// AppendTo appends the encoded repair packet to b: the Repair FEC Payload ID
+// followed by the symbol.
+func (p RepairPacket) AppendTo(b []byte) []byte {
+	return append(p.ID.AppendTo(b), p.Symbol...)
+}
+
+// ParseRepairPacket decodes a repair packet. The symbol aliases p.
+func ParseRepairPacket(p []byte) (RepairPacket, error) {
+	if len(p) < RepairPayloadIDLen {
+		return RepairPacket{}, fountain.ErrShortWireData
+	}
+	id := RepairPayloadID{
+		SBN:               fountain.ByteOrder.Uint16(p),
+		ESI:               fountain.ByteOrder.Uint16(p[2:]),
+		SourceBlockLength: fountain.ByteOrder.Uint16(p[4:]),
+	}
+	return RepairPacket{ID: id, Symbol: p[RepairPayloadIDLen:]}, nil
+}
+
+// aduiSymbols returns the number of symbols of the ADUI of an ADU of n bytes.
+func (o OTI) aduiSymbols(n int) int {
+	t := int(o.SymbolSize)
+	return (aduiHeaderLen + n + t - 1) / t
+}
+
+// appendADUI appends the ADUI of an ADU to b.
+func (o OTI) appendADUI(b []byte, flow uint8, adu []byte) []byte {
+	b = append(b, flow)
+	b = fountain.ByteOrder.AppendUint16(b, uint16(len(adu)))
+	b = append(b, adu...)
+	pad := o.aduiSymbols(len(adu))*int(o.SymbolSize) - aduiHeaderLen - len(adu)
+	return append(b, make([]byte, pad)...)
+}
+
+// checkADU returns an error if an ADU is too long for a source block.
+func (o OTI) checkADU(adu []byte) error {
+	if len(adu) > 0xffff || o.aduiSymbols(len(adu)) > int(o.MaxSourceBlockLength) {
+		return fmt.Errorf("fecframe: %d-byte ADU doesn't fit a source block of %d %d-byte symbols",
+			len(adu), o.MaxSourceBlockLength, o.SymbolSize)
+	}
+	return nil
+}
+
+// Encoder protects the ADUs of a sender's flows.
+type Encoder struct {
+	oti OTI
+
+	// sbn is the number of the open source block, and block its ADUIs.
+	sbn   uint16
+	block []byte
+
+	// closed is the last source block closed, which Repair encodes: its
+	// number, length, encoder and the ESI of its next repair symbol.
+	closed struct {
+		sbn     uint16
+		k       int
+		encoder *fountain.RaptorStrictEncoder
+		next    int64
+	}
+}
+
+// NewEncoder creates an encoder with the given OTI.
+func NewEncoder(oti OTI) (*Encoder, error) {
+	if err := oti.check(); err != nil {
+		return nil, err
+	}
+	return &Encoder{oti: oti}, nil
+}
+
+// Protect adds an ADU of the given flow to the open source block, and returns
+// the Source FEC Payload ID to send it with (see AppendSourcePacket). If the
+// ADU doesn't fit in the open source block, that block is closed first (see
+// Close), and the ADU starts the next one.
+func (e *Encoder) Protect(flow uint8, adu []byte) (SourcePayloadID, error) {
+	if err := e.oti.checkADU(adu); err != nil {
+		return SourcePayloadID{}, err
+	}
+	symbols := len(e.block) / int(e.oti.SymbolSize)
+	if symbols+e.oti.aduiSymbols(len(adu)) > int(e.oti.MaxSourceBlockLength) {
+		if _, err := e.Close(); err != nil {
+			return SourcePayloadID{}, err
+		}
+		symbols = 0
+	}
+	id := SourcePayloadID{SBN: e.sbn, ESI: uint16(symbols)}
+	e.block = e.oti.appendADUI(e.block, flow, adu)
+	return id, nil
+}
+
+// Close closes the open source block, if it has any ADUs, so that Repair
+// generates repair packets for it, and reports whether it did. The ADUs
+// protected from then on go into the next source block.
+func (e *Encoder) Close() (bool, error) {
+	if len(e.block) == 0 {
+		return false, nil
+	}
+	t := int(e.oti.SymbolSize)
+	k := max(minSourceBlockLen, len(e.block)/t)
+	c, err := fountain.NewRaptorCodecChecked(k, alignment)
+	if err != nil {
+		return false, err
+	}
+	encoder, err := fountain.NewRaptorStrictEncoder(c, e.block, t)
+	if err != nil {
+		return false, err
+	}
+	e.closed.sbn, e.closed.k, e.closed.encoder, e.closed.next = e.sbn, k, encoder, int64(k)
+	e.sbn++
+	e.block = nil
+	return true, nil
+}
+
+// Repair returns n more repair packets of the last source block closed.
+// Returns ErrNoSourceBlock if none has been, and an error once the block runs
+// out of ESIs.
+func (e *Encoder) Repair(n int) ([]RepairPacket, error) {
+	if e.closed.encoder == nil {
+		return nil, ErrNoSourceBlock
+	}
+	packets := make([]RepairPacket, 0, n)
+	for i := 0; i < n; i++ {
+		symbols, err := e.closed.encoder.Packet(e.closed.next, 1)
+		if err != nil {
+			return packets, err
+		}
+		id := RepairPayloadID{SBN: e.closed.sbn, ESI: uint16(e.closed.next), SourceBlockLength: uint16(e.closed.k)}
+		packets = append(packets, RepairPacket{ID: id, Symbol: symbols[0].Data})
+		e.closed.next++
+	}
+	return packets, nil
+}
+
+// ADU is an application data unit recovered by a Decoder.
+type ADU struct {
+	// Flow is the flow ID.
+	Flow uint8
+
+	// Data is the ADU.
+	Data []byte
+}
+
+// Decoder recovers the lost ADUs of a receiver's flows.
+type Decoder struct {
+	oti    OTI
+	blocks map[uint16]*sourceBlock
+}
+
+// sourceBlock is the state of a source block being received.
+type sourceBlock struct {
+	// sources records the ESIs of the source symbols received, and starts
+	// those at which a received ADUI starts.
+	sources, starts map[int]bool
+
+	// k is the source block length, and decoder the block's decoder, once a
+	// repair packet has given it; until then, pending holds the source
+	// symbols received. determined is set once the decoder can decode, and
+	// done once the block needs nothing more.
+	k          int
+	decoder    fountain.Decoder
+	pending    []fountain.LTBlock
+	determined bool
+	done       bool
+}
+
+// add adds symbols of the source block, which the decoder may modify.
+func (b *sourceBlock) add(symbols []fountain.LTBlock) {
+	if b.decoder == nil {
+		b.pending = append(b.pending, symbols...)
+		return
+	}
+	b.determined = b.decoder.AddBlocks(symbols)
+}
+
+// NewDecoder creates a decoder with the given OTI.
+func NewDecoder(oti OTI) (*Decoder, error) {
+	if err := oti.check(); err != nil {
+		return nil, err
+	}
+	return &Decoder{oti: oti, blocks: make(map[uint16]*sourceBlock)}, nil
+}
+
+// block returns the state of a source block.
+func (d *Decoder) block(sbn uint16) *sourceBlock {
+	b := d.blocks[sbn]
+	if b == nil {
+		b = &sourceBlock{sources: make(map[int]bool), starts: make(map[int]bool)}
+		d.blocks[sbn] = b
+	}
+	return b
+}
+
+// AddSource adds a received source packet's ADU, which the caller has
+// already passed on, and returns the ADUs of its source block which have been
+// recovered since, if any.
+func (d *Decoder) AddSource(flow uint8, adu []byte, id SourcePayloadID) ([]ADU, error) {
+	if err := d.oti.checkADU(adu); err != nil {
+		return nil, err
+	}
+	t := int(d.oti.SymbolSize)
+	n := d.oti.aduiSymbols(len(adu))
+	if int(id.ESI)+n > int(d.oti.MaxSourceBlockLength) {
+		return nil, fmt.Errorf("fecframe: ADUI at ESI %d runs past the maximum source block length %d",
+			id.ESI, d.oti.MaxSourceBlockLength)
+	}
+	b := d.block(id.SBN)
+	if b.done || b.starts[int(id.ESI)] {
+		return nil, nil
+	}
+	adui := d.oti.appendADUI(nil, flow, adu)
+	symbols := make([]fountain.LTBlock, n)
+	for i := range symbols {
+		esi := int(id.ESI) + i
+		symbols[i] = fountain.LTBlock{BlockCode: int64(esi), Data: adui[i*t : (i+1)*t]}
+		b.sources[esi] = true
+	}
+	b.starts[int(id.ESI)] = true
+	b.add(symbols)
+	return d.recover(b)
+}
+
+// AddRepair adds a received repair packet, and returns the ADUs of its
+// source block which have been recovered since, if any.
+func (d *Decoder) AddRepair(p RepairPacket) ([]ADU, error) {
+	if len(p.Symbol) != int(d.oti.SymbolSize) {
+		return nil, fmt.Errorf("fecframe: %d-byte repair symbol, T is %d", len(p.Symbol), d.oti.SymbolSize)
+	}
+	k := int(p.ID.SourceBlockLength)
+	if k < minSourceBlockLen || k > int(d.oti.MaxSourceBlockLength) || int(p.ID.ESI) < k {
+		return nil, fmt.Errorf("fecframe: repair symbol ESI %d of a source block of %d symbols", p.ID.ESI, k)
+	}
+	b := d.block(p.ID.SBN)
+	if b.done {
+		return nil, nil
+	}
+	if b.decoder == nil {
+		b.k = k
+		b.decoder = fountain.NewRaptorCodec(k, alignment).NewDecoder(k * int(d.oti.SymbolSize))
+		pending := b.pending
+		b.pending = nil
+		b.add(pending)
+	} else if k != b.k {
+		return nil, fmt.Errorf("fecframe: source block %d has length %d, not %d", p.ID.SBN, b.k, k)
+	}
+	b.add([]fountain.LTBlock{{BlockCode: int64(p.ID.ESI), Data: append([]byte(nil), p.Symbol...)}})
+	return d.recover(b)
+}
+
+// recover decodes a source block once it is determined, and returns the
+// ADUs which weren't received.
+func (d *Decoder) recover(b *sourceBlock) ([]ADU, error) {
+	if b.decoder == nil {
+		return nil, nil
+	}
+	lost := false
+	for esi := 0; esi < b.k; esi++ {
+		if !b.sources[esi] {
+			lost = true
+			break
+		}
+	}
+	if !lost {
+		b.finish()
+		return nil, nil
+	}
+	if !b.determined {
+		return nil, nil
+	}
+	t := int(d.oti.SymbolSize)
+	block := b.decoder.Decode()
+
+	var adus []ADU
+	for esi := 0; esi < b.k; {
+		header := block[esi*t:]
+		if len(header) < aduiHeaderLen {
+			break
+		}
+		flow, length := header[0], int(fountain.ByteOrder.Uint16(header[1:]))
+		if aduiHeaderLen+length > len(header) {
+			b.finish()
+			return adus, fmt.Errorf("fecframe: recovered ADUI at ESI %d runs past the source block", esi)
+		}
+		if !b.starts[esi] && (flow != 0 || length != 0) {
+			adus = append(adus, ADU{Flow: flow, Data: header[aduiHeaderLen : aduiHeaderLen+length]})
+		}
+		esi += d.oti.aduiSymbols(length)
+	}
+	b.finish()
+	return adus, nil
+}
+
+// finish marks a source block as done, and frees its decoder.
+func (b *sourceBlock) finish() {
+	b.done = true
+	b.sources, b.starts, b.decoder, b.pending = nil, nil, nil, nil
+}
+
+// Forget discards the state of a source block, once no more of its packets
+// are expected. Source block numbers wrap around, so a receiver must forget
+// old blocks before their numbers come around again.
+func (d *Decoder) Forget(sbn uint16) {
+	delete(d.blocks, sbn)
+}
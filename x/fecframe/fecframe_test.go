@@ -0,0 +1,179 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fecframe
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	fountain "github.com/google/gofountain"
+)
+
+// sent is a packet as sent by the encoder, for the tests.
+type sent struct {
+	flow   uint8
+	adu    []byte
+	source []byte
+}
+
+func TestRecoverLostADUs(t *testing.T) {
+	oti := OTI{SymbolSize: 32, MaxSourceBlockLength: 40}
+	e, err := NewEncoder(oti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []sent
+	for i := 0; i < 12; i++ {
+		adu := bytes.Repeat([]byte{byte(i)}, 10+i*9)
+		id, err := e.Protect(uint8(i%3), adu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.SBN != 0 {
+			t.Fatalf("ADU %d went into source block %d", i, id.SBN)
+		}
+		packets = append(packets, sent{flow: uint8(i % 3), adu: adu, source: AppendSourcePacket(nil, adu, id)})
+	}
+	if ok, err := e.Close(); !ok || err != nil {
+		t.Fatalf("Close = %v, %v", ok, err)
+	}
+	repairs, err := e.Repair(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDecoder(oti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ADUs 2, 5 and 11 are lost, 11 being the last of the block.
+	lost := map[int]bool{2: true, 5: true, 11: true}
+	for i, p := range packets {
+		if lost[i] {
+			continue
+		}
+		adu, id, err := ParseSourcePacket(p.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recovered, err := d.AddSource(p.flow, adu, id); err != nil || recovered != nil {
+			t.Fatalf("AddSource %d = %v, %v; want nothing recovered", i, recovered, err)
+		}
+	}
+	var recovered []ADU
+	for _, r := range repairs {
+		p, err := ParseRepairPacket(r.AppendTo(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		adus, err := d.AddRepair(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recovered = append(recovered, adus...)
+	}
+	var want []ADU
+	for i := range packets {
+		if lost[i] {
+			want = append(want, ADU{Flow: packets[i].flow, Data: packets[i].adu})
+		}
+	}
+	if fmt.Sprint(recovered) != fmt.Sprint(want) {
+		t.Errorf("recovered %v, want %v", recovered, want)
+	}
+}
+
+func TestSourceBlocks(t *testing.T) {
+	oti := OTI{SymbolSize: 16, MaxSourceBlockLength: 8}
+	e, _ := NewEncoder(oti)
+	if _, err := e.Repair(1); err != ErrNoSourceBlock {
+		t.Errorf("Repair before Close: err = %v, want ErrNoSourceBlock", err)
+	}
+	// Each ADUI takes 3 symbols, so the third ADU starts a new block.
+	var ids []SourcePayloadID
+	for i := 0; i < 3; i++ {
+		id, err := e.Protect(1, make([]byte, 40))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if want := []SourcePayloadID{{0, 0}, {0, 3}, {1, 0}}; fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("payload IDs %v, want %v", ids, want)
+	}
+	repairs, err := e.Repair(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RepairPayloadID{SBN: 0, ESI: 6, SourceBlockLength: 6}); repairs[0].ID != want {
+		t.Errorf("repair payload ID %+v, want %+v", repairs[0].ID, want)
+	}
+	if _, err := e.Protect(1, make([]byte, 200)); err == nil {
+		t.Error("Protect of an ADU longer than a source block succeeded")
+	}
+
+	// A short source block is padded to 4 symbols; its single ADU can be
+	// recovered from repair packets alone.
+	e.Close()
+	repairs, err = e.Repair(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repairs[0].ID.SourceBlockLength != 4 {
+		t.Errorf("source block length %d, want 4", repairs[0].ID.SourceBlockLength)
+	}
+	d, _ := NewDecoder(oti)
+	var recovered []ADU
+	for _, r := range repairs {
+		adus, err := d.AddRepair(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recovered = append(recovered, adus...)
+	}
+	if len(recovered) != 1 || recovered[0].Flow != 1 || !bytes.Equal(recovered[0].Data, make([]byte, 40)) {
+		t.Errorf("recovered %v, want the single ADU", recovered)
+	}
+}
+
+func TestWireFormats(t *testing.T) {
+	oti := OTI{SymbolSize: 1024, MaxSourceBlockLength: 256}
+	b := oti.AppendTo(nil)
+	if want := []byte{4, 0, 1, 0}; !bytes.Equal(b, want) {
+		t.Errorf("OTI encoding %x, want %x", b, want)
+	}
+	if got, rest, err := ParseOTI(b); err != nil || got != oti || len(rest) != 0 {
+		t.Errorf("ParseOTI = %+v, %x, %v", got, rest, err)
+	}
+	if _, _, err := ParseOTI([]byte{4, 0, 0, 2}); err == nil {
+		t.Error("ParseOTI of a maximum source block length of 2 succeeded")
+	}
+	if _, _, err := ParseOTI(b[:3]); err != fountain.ErrShortWireData {
+		t.Errorf("ParseOTI of 3 bytes: err = %v, want ErrShortWireData", err)
+	}
+
+	p := AppendSourcePacket(nil, []byte("adu"), SourcePayloadID{SBN: 0x102, ESI: 0x304})
+	if want := []byte("adu\x01\x02\x03\x04"); !bytes.Equal(p, want) {
+		t.Errorf("source packet %x, want %x", p, want)
+	}
+	r := RepairPacket{ID: RepairPayloadID{SBN: 1, ESI: 2, SourceBlockLength: 3}, Symbol: []byte("sym")}
+	if want := []byte("\x00\x01\x00\x02\x00\x03sym"); !bytes.Equal(r.AppendTo(nil), want) {
+		t.Errorf("repair packet %x, want %x", r.AppendTo(nil), want)
+	}
+	if _, err := ParseRepairPacket([]byte{0, 1, 0}); err != fountain.ErrShortWireData {
+		t.Errorf("ParseRepairPacket of 3 bytes: err = %v, want ErrShortWireData", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flute
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+// ntpEpochOffset is the number of seconds from the NTP epoch (1900) to the
+// Unix epoch (1970).
+const ntpEpochOffset int64 = 2208988800
+
+// FDT is an FDT Instance (RFC 6726 §3.4.2): the description of the files of a
+// session.
+type FDT struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:fdt FDT-Instance"`
+
+	// Expires is the time the FDT Instance expires, as the 32 bits of the
+	// integer part of an NTP timestamp.
+	Expires uint32 `xml:"Expires,attr"`
+
+	// Files describes the files.
+	Files []File `xml:"File"`
+}
+
+// File describes a file of a session. The FEC Object Transmission Information
+// is that of the Raptor FEC scheme; the scheme-specific part is base64 encoded,
+// as RFC 6726 §3.4.2 requires. The Merkle root of a file's parameters, if any,
+// is base64 encoded in a Merkle-Root attribute, which receivers predating it
+// ignore (the FDT schema allows any attribute), decoding the file unchecked.
+type File struct {
+	TOI             uint32 `xml:"TOI,attr"`
+	ContentLocation string `xml:"Content-Location,attr"`
+	ContentLength   int64  `xml:"Content-Length,attr,omitempty"`
+	TransferLength  int64  `xml:"Transfer-Length,attr"`
+	ContentType     string `xml:"Content-Type,attr,omitempty"`
+
+	FECEncodingID      uint8  `xml:"FEC-OTI-FEC-Encoding-ID,attr"`
+	SymbolLength       int    `xml:"FEC-OTI-Encoding-Symbol-Length,attr"`
+	SchemeSpecificInfo string `xml:"FEC-OTI-Scheme-Specific-Info,attr"`
+
+	MerkleRoot string `xml:"Merkle-Root,attr,omitempty"`
+}
+
+// Params returns the parameters the file was encoded with. The number of
+// symbols per packet isn't signalled, and is returned as 1.
+func (f File) Params() (fountain.ObjectParams, error) {
+	if f.FECEncodingID != RaptorEncodingID {
+		return fountain.ObjectParams{}, fmt.Errorf("flute: file %d has FEC Encoding ID %d, want %d",
+			f.TOI, f.FECEncodingID, RaptorEncodingID)
+	}
+	p := fountain.ObjectParams{TransferLength: f.TransferLength, SymbolSize: f.SymbolLength, SymbolsPerPacket: 1}
+	info, err := base64.StdEncoding.DecodeString(f.SchemeSpecificInfo)
+	if err != nil {
+		return fountain.ObjectParams{}, fmt.Errorf("flute: file %d: %v", f.TOI, err)
+	}
+	if err := parseSchemeInfo(info, &p); err != nil {
+		return fountain.ObjectParams{}, err
+	}
+	if f.MerkleRoot != "" {
+		root, err := base64.StdEncoding.DecodeString(f.MerkleRoot)
+		if err != nil || len(root) != sha256.Size {
+			return fountain.ObjectParams{}, fmt.Errorf("flute: file %d has a bad Merkle root %q", f.TOI, f.MerkleRoot)
+		}
+		copy(p.MerkleRoot[:], root)
+	}
+	return p, p.Validate()
+}
+
+// newFile describes a file encoded with parameters p.
+func newFile(toi uint32, location, contentType string, p fountain.ObjectParams) File {
+	var root string
+	if p.MerkleRoot != [sha256.Size]byte{} {
+		root = base64.StdEncoding.EncodeToString(p.MerkleRoot[:])
+	}
+	return File{
+		TOI:                toi,
+		ContentLocation:    location,
+		ContentLength:      p.TransferLength,
+		TransferLength:     p.TransferLength,
+		ContentType:        contentType,
+		FECEncodingID:      RaptorEncodingID,
+		SymbolLength:       p.SymbolSize,
+		SchemeSpecificInfo: base64.StdEncoding.EncodeToString(appendSchemeInfo(nil, p)),
+		MerkleRoot:         root,
+	}
+}
+
+// NTPSeconds returns the integer part of the NTP timestamp of t, as carried
+// in FDT.Expires.
+func NTPSeconds(t time.Time) uint32 {
+	return uint32(t.Unix() + ntpEpochOffset)
+}
+
+// Marshal returns the XML encoding of the FDT Instance.
+func (f FDT) Marshal() ([]byte, error) {
+	b, err := xml.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("flute: marshalling FDT: %v", err)
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// ParseFDT decodes an XML FDT Instance.
+func ParseFDT(b []byte) (FDT, error) {
+	var f FDT
+	if err := xml.Unmarshal(b, &f); err != nil {
+		return FDT{}, fmt.Errorf("flute: parsing FDT: %v", err)
+	}
+	return f, nil
+}
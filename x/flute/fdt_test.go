@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flute
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+func TestFDTRoundTrip(t *testing.T) {
+	p := fountain.ObjectParams{TransferLength: 5000, SymbolSize: 64, Alignment: 4,
+		SourceBlocks: 2, SubBlocks: 2, SymbolsPerPacket: 1}
+	fdt := FDT{
+		Expires: NTPSeconds(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Files:   []File{newFile(3, "file:///a.txt", "text/plain", p)},
+	}
+	b, err := fdt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`FDT-Instance xmlns="urn:ietf:params:xml:ns:fdt"`, `TOI="3"`,
+		`Content-Location="file:///a.txt"`, `FEC-OTI-FEC-Encoding-ID="1"`} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Errorf("FDT %s doesn't contain %s", b, s)
+		}
+	}
+	got, err := ParseFDT(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.XMLName = fdt.XMLName
+	if !reflect.DeepEqual(got, fdt) {
+		t.Errorf("ParseFDT = %+v, want %+v", got, fdt)
+	}
+	gotParams, err := got.Files[0].Params()
+	if err != nil || gotParams != p {
+		t.Errorf("Params = %+v, %v; want %+v", gotParams, err, p)
+	}
+	if bytes.Contains(b, []byte("Merkle-Root")) {
+		t.Errorf("FDT %s of a file without a Merkle root has one", b)
+	}
+
+	// The Merkle root is carried along with the FEC OTI.
+	p.MerkleRoot[0], p.MerkleRoot[31] = 1, 2
+	fdt.Files[0] = newFile(3, "file:///a.txt", "text/plain", p)
+	if b, err = fdt.Marshal(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = ParseFDT(b); err != nil {
+		t.Fatal(err)
+	}
+	if gotParams, err := got.Files[0].Params(); err != nil || gotParams != p {
+		t.Errorf("Params = %+v, %v; want %+v", gotParams, err, p)
+	}
+}
+
+func TestFileParamsErrors(t *testing.T) {
+	p := fountain.ObjectParams{TransferLength: 100, SymbolSize: 10, Alignment: 1,
+		SourceBlocks: 1, SubBlocks: 1, SymbolsPerPacket: 1}
+	f := newFile(1, "x", "", p)
+	f.FECEncodingID = 0
+	if _, err := f.Params(); err == nil {
+		t.Error("Params accepted the Compact No-Code FEC scheme")
+	}
+	f = newFile(1, "x", "", p)
+	f.SchemeSpecificInfo = "AAE="
+	if _, err := f.Params(); err == nil {
+		t.Error("Params accepted 2 bytes of scheme-specific information")
+	}
+	f = newFile(1, "x", "", p)
+	f.SymbolLength = 50
+	if _, err := f.Params(); err == nil {
+		t.Error("Params accepted a source block of 2 symbols")
+	}
+	f = newFile(1, "x", "", p)
+	f.MerkleRoot = "AAE="
+	if _, err := f.Params(); err == nil {
+		t.Error("Params accepted a Merkle root of 2 bytes")
+	}
+}
+
+func TestNTPSeconds(t *testing.T) {
+	if got := NTPSeconds(time.Unix(0, 0)); int64(got) != ntpEpochOffset {
+		t.Errorf("NTPSeconds(Unix epoch) = %d, want %d", got, ntpEpochOffset)
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flute implements a minimal FLUTE (RFC 6726) sender and receiver, for
+// delivering files over ALC (RFC 5775) sessions with the Raptor FEC scheme of
+// RFC 5053.
+//
+// A session is identified by its transport session identifier (TSI), and each
+// object sent in it by a transport object identifier (TOI). Files are encoded
+// with fountain.ObjectEncoder, and each packet carries an LCT header (RFC
+// 5651), the FEC Payload ID (fountain.PayloadID) and a run of consecutive
+// encoding symbols of one source block. The files of a session are described
+// by the File Delivery Table (FDT), an XML document which is itself sent as
+// object FDTTOI, with the EXT_FDT header extension naming its instance and
+// EXT_FTI carrying its FEC Object Transmission Information. The FDT gives the
+// receiver each file's TOI, name and FEC Object Transmission Information.
+//
+// Only the parts of the protocols needed to move files are implemented: there
+// is no congestion control, no content encoding and no FDT expiry handling,
+// and only the Raptor FEC scheme is supported. Sending the packets is left to
+// the caller.
+//
+// This package is experimental (see package x).
+package flute
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+// FDTTOI is the TOI FDT Instances are sent with.
+const FDTTOI = 0
+
+// RaptorEncodingID is the FEC Encoding ID of the Raptor FEC scheme (RFC 5053
+// §3.1), which ALC carries as the LCT codepoint.
+const RaptorEncodingID = 1
+
+// fdtAlignment is the symbol alignment FDT Instances are encoded with.
+const fdtAlignment = 1
+
+// sentFile is a file of a Sender.
+type sentFile struct {
+	file    File
+	encoder *fountain.ObjectEncoder
+}
+
+// Sender builds the packets of a FLUTE session.
+type Sender struct {
+	tsi        uint32
+	symbolSize int
+	expires    uint32
+
+	// files holds the session's files by TOI, and nextTOI is the TOI the next
+	// file added gets.
+	files   map[uint32]*sentFile
+	order   []uint32
+	nextTOI uint32
+
+	// instance is the ID of the current FDT Instance, and fdt its encoder,
+	// which is nil until it is first needed after a file is added.
+	instance uint32
+	fdt      *fountain.ObjectEncoder
+}
+
+// NewSender creates a sender for session tsi. FDT Instances are encoded with
+// symbols of at most symbolSize bytes, and expire at expires.
+func NewSender(tsi uint32, symbolSize int, expires time.Time) (*Sender, error) {
+	if symbolSize <= 0 || symbolSize > 1<<16-1 {
+		return nil, fmt.Errorf("flute: symbol size %d is outside [1, %d]", symbolSize, 1<<16-1)
+	}
+	return &Sender{tsi: tsi, symbolSize: symbolSize, expires: NTPSeconds(expires),
+		files: make(map[uint32]*sentFile), nextTOI: FDTTOI + 1}, nil
+}
+
+// AddFile adds a file to the session, encoded with parameters p, and returns
+// its TOI. The FDT Instance is replaced by one which also describes the file,
+// with a new instance ID if any of its packets were built. The content is
+// left untouched.
+func (s *Sender) AddFile(location, contentType string, content []byte, p fountain.ObjectParams) (uint32, error) {
+	if p.SymbolSize > 1<<16-1 {
+		return 0, fmt.Errorf("flute: symbol size %d doesn't fit in EXT_FTI", p.SymbolSize)
+	}
+	if p.SourceBlocks > 1<<16 {
+		return 0, fmt.Errorf("flute: %d source blocks don't fit in the FEC Payload ID", p.SourceBlocks)
+	}
+	if p.Compression != fountain.CompressionNone {
+		return 0, errors.New("flute: compressed files aren't supported")
+	}
+	e, err := fountain.NewObjectEncoder(content, p)
+	if err != nil {
+		return 0, err
+	}
+	if s.nextTOI == FDTTOI {
+		return 0, errors.New("flute: out of TOIs")
+	}
+	toi := s.nextTOI
+	s.nextTOI++
+	s.files[toi] = &sentFile{file: newFile(toi, location, contentType, p), encoder: e}
+	s.order = append(s.order, toi)
+	if s.fdt != nil {
+		s.instance = (s.instance + 1) & maxFDTInstanceID
+		s.fdt = nil
+	}
+	return toi, nil
+}
+
+// FDT returns the current FDT Instance, which describes the files in the
+// order they were added.
+func (s *Sender) FDT() FDT {
+	f := FDT{Expires: s.expires}
+	for _, toi := range s.order {
+		f.Files = append(f.Files, s.files[toi].file)
+	}
+	return f
+}
+
+// FDTInstanceID returns the ID of the current FDT Instance.
+func (s *Sender) FDTInstanceID() uint32 {
+	return s.instance
+}
+
+// fdtEncoder returns the encoder of the current FDT Instance.
+func (s *Sender) fdtEncoder() (*fountain.ObjectEncoder, error) {
+	if s.fdt != nil {
+		return s.fdt, nil
+	}
+	b, err := s.FDT().Marshal()
+	if err != nil {
+		return nil, err
+	}
+	e, err := fountain.NewObjectEncoder(b, fdtParams(int64(len(b)), s.symbolSize))
+	if err != nil {
+		return nil, err
+	}
+	s.fdt = e
+	return e, nil
+}
+
+// fdtParams returns the parameters an FDT Instance of f bytes is encoded
+// with: one symbol per packet, and symbols of at most symbolSize bytes, but
+// small enough that every source block has the 4 symbols the raptor codec
+// needs.
+func fdtParams(f int64, symbolSize int) fountain.ObjectParams {
+	t := int(min(int64(symbolSize), max(f/fountain.MinRaptorSourceSymbols, 1)))
+	kt := (f + int64(t) - 1) / int64(t)
+	z := (kt + fountain.MaxRaptorSourceSymbols - 1) / fountain.MaxRaptorSourceSymbols
+	return fountain.ObjectParams{TransferLength: f, SymbolSize: t, Alignment: fdtAlignment,
+		SourceBlocks: int(z), SubBlocks: 1, SymbolsPerPacket: 1}
+}
+
+// Packet returns the packet of object toi, a file's or FDTTOI, which carries
+// the encoding symbols of source block sbn starting at ESI esi, as many as the
+// object's parameters put in a packet.
+func (s *Sender) Packet(toi uint32, sbn int, esi int64) ([]byte, error) {
+	h := Header{TSI: s.tsi, TOI: toi, Codepoint: RaptorEncodingID}
+	var e *fountain.ObjectEncoder
+	if toi == FDTTOI {
+		var err error
+		if e, err = s.fdtEncoder(); err != nil {
+			return nil, err
+		}
+		p := e.Params()
+		h.HasFDT, h.FDTInstanceID, h.OTI = true, s.instance, &p
+	} else {
+		f, ok := s.files[toi]
+		if !ok {
+			return nil, fmt.Errorf("flute: no object with TOI %d", toi)
+		}
+		e = f.encoder
+	}
+
+	blocks := make([]fountain.LTBlock, e.Params().SymbolsPerPacket)
+	for i := range blocks {
+		symbol, err := e.Symbol(sbn, esi+int64(i))
+		if err != nil {
+			return nil, err
+		}
+		blocks[i] = symbol.LTBlock
+	}
+	return fountain.AppendPacket(h.AppendTo(nil), uint16(sbn), blocks)
+}
+
+// SourcePackets returns packets carrying all the source symbols of object
+// toi. A receiver which gets all of them decodes the object without any
+// repair symbols. The last packet of a source block may be filled out with
+// repair symbols.
+func (s *Sender) SourcePackets(toi uint32) ([][]byte, error) {
+	var p fountain.ObjectParams
+	if toi == FDTTOI {
+		e, err := s.fdtEncoder()
+		if err != nil {
+			return nil, err
+		}
+		p = e.Params()
+	} else {
+		f, ok := s.files[toi]
+		if !ok {
+			return nil, fmt.Errorf("flute: no object with TOI %d", toi)
+		}
+		p = f.encoder.Params()
+	}
+	var packets [][]byte
+	for sbn := 0; sbn < p.SourceBlocks; sbn++ {
+		for esi := 0; esi < p.SourceBlockSymbols(sbn); esi += p.SymbolsPerPacket {
+			packet, err := s.Packet(toi, sbn, int64(esi))
+			if err != nil {
+				return nil, err
+			}
+			packets = append(packets, packet)
+		}
+	}
+	return packets, nil
+}
+
+// receivedFile is a file being received.
+type receivedFile struct {
+	file    File
+	params  fountain.ObjectParams
+	decoder *fountain.ObjectDecoder
+
+	// content is set once the file has been decoded.
+	content []byte
+}
+
+// Receiver receives the files of a FLUTE session.
+type Receiver struct {
+	tsi uint32
+
+	// fdts holds the decoders of the FDT Instances being received, by
+	// instance ID.
+	fdts map[uint32]*fountain.ObjectDecoder
+
+	// fdt is the latest FDT Instance received, and hasFDT is set once one has
+	// been.
+	fdt    FDT
+	hasFDT bool
+
+	files    map[uint32]*receivedFile
+	rejected int
+}
+
+// NewReceiver creates a receiver for session tsi.
+func NewReceiver(tsi uint32) *Receiver {
+	return &Receiver{tsi: tsi, fdts: make(map[uint32]*fountain.ObjectDecoder),
+		files: make(map[uint32]*receivedFile)}
+}
+
+// Receive processes a packet, and returns the TOIs of the files it completes.
+// Packets of other sessions, and of files not yet described by an FDT
+// Instance, are rejected without an error: the symbols of a file sent before
+// its FDT Instance is received are lost. The packet is copied, so its buffer
+// may be reused.
+func (r *Receiver) Receive(packet []byte) ([]uint32, error) {
+	h, payload, err := ParseHeader(packet)
+	if err != nil {
+		return nil, err
+	}
+	if h.TSI != r.tsi {
+		r.rejected++
+		return nil, nil
+	}
+	if h.Codepoint != RaptorEncodingID {
+		return nil, fmt.Errorf("flute: codepoint %d, want %d", h.Codepoint, RaptorEncodingID)
+	}
+	if h.TOI == FDTTOI {
+		return r.receiveFDT(h, payload)
+	}
+	f, ok := r.files[h.TOI]
+	if !ok {
+		r.rejected++
+		return nil, nil
+	}
+	if f.content != nil {
+		return nil, nil
+	}
+	if err := addPayload(f.decoder, payload, f.params.SymbolSize); err != nil {
+		return nil, err
+	}
+	if !f.decoder.Complete() {
+		return nil, nil
+	}
+	if f.content, err = f.decoder.Object(); err != nil {
+		return nil, err
+	}
+	return []uint32{h.TOI}, nil
+}
+
+// receiveFDT processes the payload of a packet of an FDT Instance.
+func (r *Receiver) receiveFDT(h Header, payload []byte) ([]uint32, error) {
+	if !h.HasFDT || h.OTI == nil {
+		return nil, errors.New("flute: FDT packet without EXT_FDT and EXT_FTI")
+	}
+	d, ok := r.fdts[h.FDTInstanceID]
+	if !ok {
+		var err error
+		if d, err = fountain.NewObjectDecoder(*h.OTI); err != nil {
+			return nil, err
+		}
+		r.fdts[h.FDTInstanceID] = d
+	}
+	if d.Complete() {
+		return nil, nil
+	}
+	if err := addPayload(d, payload, h.OTI.SymbolSize); err != nil {
+		return nil, err
+	}
+	if !d.Complete() {
+		return nil, nil
+	}
+	b, err := d.Object()
+	if err != nil {
+		return nil, err
+	}
+	fdt, err := ParseFDT(b)
+	if err != nil {
+		return nil, err
+	}
+	r.fdt, r.hasFDT = fdt, true
+	for _, file := range fdt.Files {
+		if _, ok := r.files[file.TOI]; ok || file.TOI == FDTTOI {
+			continue
+		}
+		p, err := file.Params()
+		if err != nil {
+			return nil, err
+		}
+		fd, err := fountain.NewObjectDecoder(p)
+		if err != nil {
+			return nil, err
+		}
+		r.files[file.TOI] = &receivedFile{file: file, params: p, decoder: fd}
+	}
+	return nil, nil
+}
+
+// addPayload adds the symbols of a packet's payload to d. The decoder keeps
+// the symbols' data, so the payload is copied.
+func addPayload(d *fountain.ObjectDecoder, payload []byte, symbolSize int) error {
+	sbn, blocks, err := fountain.ParsePacket(append([]byte(nil), payload...), symbolSize)
+	if err != nil {
+		return err
+	}
+	symbols := make([]fountain.ObjectSymbol, len(blocks))
+	for i, b := range blocks {
+		symbols[i] = fountain.ObjectSymbol{SBN: int(sbn), LTBlock: b}
+	}
+	d.AddSymbols(symbols)
+	return nil
+}
+
+// FDT returns the latest FDT Instance received, and whether one has been.
+func (r *Receiver) FDT() (FDT, bool) {
+	return r.fdt, r.hasFDT
+}
+
+// Content returns the content of file toi, and whether it has been received.
+func (r *Receiver) Content(toi uint32) ([]byte, bool) {
+	f, ok := r.files[toi]
+	if !ok || f.content == nil {
+		return nil, false
+	}
+	return f.content, true
+}
+
+// Rejected returns the number of packets rejected because they belonged to
+// another session or to an unknown file.
+func (r *Receiver) Rejected() int {
+	return r.rejected
+}
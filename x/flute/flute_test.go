@@ -0,0 +1,166 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flute
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+// testFile is the content of a file for the tests.
+func testFile(n int, seed int64) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func TestSendReceive(t *testing.T) {
+	s, err := NewSender(42, 256, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[uint32][]byte{}
+	for i, n := range []int{3000, 20000} {
+		content := testFile(n, int64(i))
+		p, err := fountain.DeriveObjectParams(int64(n), 4, 512, 4096, 8, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toi, err := s.AddFile("file:///f"+string(rune('a'+i)), "application/octet-stream", content, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[toi] = content
+	}
+
+	r := NewReceiver(42)
+	// A packet of a file sent before the FDT is rejected, as is one of
+	// another session.
+	early, err := s.Packet(1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done, err := r.Receive(early); done != nil || err != nil {
+		t.Fatalf("Receive(early) = %v, %v", done, err)
+	}
+	other, _ := NewSender(43, 256, time.Now())
+	other.AddFile("x", "", testFile(100, 9), fountain.ObjectParams{TransferLength: 100, SymbolSize: 20,
+		Alignment: 4, SourceBlocks: 1, SubBlocks: 1, SymbolsPerPacket: 1})
+	foreign, _ := other.Packet(1, 0, 0)
+	r.Receive(foreign)
+	if got := r.Rejected(); got != 2 {
+		t.Errorf("Rejected = %d, want 2", got)
+	}
+
+	fdtPackets, err := s.SourcePackets(FDTTOI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range fdtPackets {
+		if _, err := r.Receive(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fdt, ok := r.FDT()
+	if !ok || len(fdt.Files) != 2 {
+		t.Fatalf("FDT = %+v, %v; want 2 files", fdt, ok)
+	}
+
+	// Drop every third source packet, and make up for it with repair packets.
+	random := rand.New(rand.NewSource(1))
+	var completed []uint32
+	for toi := range contents {
+		packets, err := s.SourcePackets(toi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := s.files[toi].file.Params()
+		g := s.files[toi].encoder.Params().SymbolsPerPacket
+		for sbn := 0; sbn < s.files[toi].encoder.Params().SourceBlocks; sbn++ {
+			for esi := p.SourceBlockSymbols(sbn); esi < 2*p.SourceBlockSymbols(sbn); esi += g {
+				packet, err := s.Packet(toi, sbn, int64(esi))
+				if err != nil {
+					t.Fatal(err)
+				}
+				packets = append(packets, packet)
+			}
+		}
+		random.Shuffle(len(packets), func(i, j int) { packets[i], packets[j] = packets[j], packets[i] })
+		for i, packet := range packets {
+			if i%3 == 0 {
+				continue
+			}
+			done, err := r.Receive(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			completed = append(completed, done...)
+		}
+	}
+	if len(completed) != len(contents) {
+		t.Fatalf("completed %v, want %d files", completed, len(contents))
+	}
+	for toi, content := range contents {
+		got, ok := r.Content(toi)
+		if !ok || !bytes.Equal(got, content) {
+			t.Errorf("Content(%d) = %d bytes, %v; want the %d bytes sent", toi, len(got), ok, len(content))
+		}
+	}
+}
+
+func TestFDTInstanceID(t *testing.T) {
+	s, err := NewSender(1, 64, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := fountain.ObjectParams{TransferLength: 100, SymbolSize: 20, Alignment: 4,
+		SourceBlocks: 1, SubBlocks: 1, SymbolsPerPacket: 1}
+	s.AddFile("a", "", testFile(100, 1), p)
+	s.AddFile("b", "", testFile(100, 2), p)
+	if got := s.FDTInstanceID(); got != 0 {
+		t.Errorf("FDTInstanceID before any FDT packet = %d, want 0", got)
+	}
+	packet, err := s.Packet(FDTTOI, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _, err := ParseHeader(packet)
+	if err != nil || !h.HasFDT || h.FDTInstanceID != 0 || h.OTI == nil {
+		t.Fatalf("FDT packet header = %+v, %v", h, err)
+	}
+	s.AddFile("c", "", testFile(100, 3), p)
+	if got := s.FDTInstanceID(); got != 1 {
+		t.Errorf("FDTInstanceID after a file was added = %d, want 1", got)
+	}
+	if _, err := s.Packet(7, 0, 0); err == nil {
+		t.Error("Packet of an unknown TOI succeeded")
+	}
+}
+
+func TestFDTParams(t *testing.T) {
+	for _, f := range []int64{16, 100, 1 << 20, 1 << 24} {
+		p := fdtParams(f, 1024)
+		if err := p.Validate(); err != nil {
+			t.Errorf("fdtParams(%d) = %+v: %v", f, p, err)
+		}
+		if p.SymbolSize > 1024 {
+			t.Errorf("fdtParams(%d) symbol size %d, want at most 1024", f, p.SymbolSize)
+		}
+	}
+}
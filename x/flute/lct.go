@@ -0,0 +1,212 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flute
+
+import (
+	"fmt"
+
+	fountain "github.com/google/gofountain"
+)
+
+// lctVersion is the LCT version number (RFC 5651 §5.1).
+const lctVersion = 1
+
+// fluteVersion is the FLUTE version number carried in EXT_FDT (RFC 6726
+// §3.4.1).
+const fluteVersion = 2
+
+// Header extension types (RFC 5651 §5.2, RFC 5775 §4.2 and RFC 6726 §3.4.1).
+const (
+	extFTI = 64
+	extFDT = 192
+)
+
+// ftiLen is the length in bytes of the EXT_FTI extension of the Raptor FEC
+// scheme.
+const ftiLen = 16
+
+// maxFDTInstanceID is the largest FDT Instance ID, which has 20 bits.
+const maxFDTInstanceID = 1<<20 - 1
+
+// Header is an LCT header (RFC 5651 §5.1) with the header extensions FLUTE
+// uses. The sender writes 32-bit TSIs and TOIs, no congestion control
+// information, and codepoint RaptorEncodingID; the receiver also accepts
+// 16-bit and 48-bit fields as long as the values fit in 32 bits.
+type Header struct {
+	// TSI is the transport session identifier, and TOI the transport object
+	// identifier: FDTTOI for FDT Instances, and otherwise a file's.
+	TSI, TOI uint32
+
+	// CloseSession and CloseObject are the A and B flags, which say that the
+	// session or the object will end soon.
+	CloseSession, CloseObject bool
+
+	// Codepoint is the LCT codepoint, which ALC sets to the FEC Encoding ID.
+	Codepoint uint8
+
+	// FDTInstanceID is the FDT Instance ID of the EXT_FDT extension, which
+	// is present if HasFDT is set.
+	HasFDT        bool
+	FDTInstanceID uint32
+
+	// OTI is the FEC Object Transmission Information of the EXT_FTI
+	// extension, which is present if it isn't nil.
+	OTI *fountain.ObjectParams
+}
+
+// AppendTo appends the encoded header to b.
+func (h Header) AppendTo(b []byte) []byte {
+	words := 4
+	if h.HasFDT {
+		words++
+	}
+	if h.OTI != nil {
+		words += ftiLen / 4
+	}
+	var flags byte = 1<<7 | 1<<5 // S=1 and O=1: 32-bit TSI and TOI
+	if h.CloseSession {
+		flags |= 1 << 1
+	}
+	if h.CloseObject {
+		flags |= 1
+	}
+	b = append(b, lctVersion<<4, flags, byte(words), h.Codepoint)
+	b = fountain.ByteOrder.AppendUint32(b, 0) // CCI
+	b = fountain.ByteOrder.AppendUint32(b, h.TSI)
+	b = fountain.ByteOrder.AppendUint32(b, h.TOI)
+	if h.HasFDT {
+		b = fountain.ByteOrder.AppendUint32(b, extFDT<<24|fluteVersion<<20|h.FDTInstanceID&maxFDTInstanceID)
+	}
+	if h.OTI != nil {
+		b = append(b, extFTI, ftiLen/4)
+		b = appendUint48(b, uint64(h.OTI.TransferLength))
+		b = fountain.ByteOrder.AppendUint16(b, uint16(h.OTI.SymbolSize))
+		b = appendSchemeInfo(b, *h.OTI)
+		b = append(b, 0, 0)
+	}
+	return b
+}
+
+// appendUint48 appends the low 48 bits of v to b in network byte order.
+func appendUint48(b []byte, v uint64) []byte {
+	return append(b, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// appendSchemeInfo appends the Raptor FEC Scheme-Specific Object
+// Transmission Information (RFC 5053 §3.2.3) to b: Z as 16 bits, then N and
+// Al as 8 bits each.
+func appendSchemeInfo(b []byte, p fountain.ObjectParams) []byte {
+	b = fountain.ByteOrder.AppendUint16(b, uint16(p.SourceBlocks))
+	return append(b, byte(p.SubBlocks), byte(p.Alignment))
+}
+
+// parseSchemeInfo decodes Scheme-Specific Object Transmission Information
+// into p.
+func parseSchemeInfo(b []byte, p *fountain.ObjectParams) error {
+	if len(b) != 4 {
+		return fmt.Errorf("flute: %d bytes of scheme-specific information, want 4", len(b))
+	}
+	p.SourceBlocks = int(fountain.ByteOrder.Uint16(b))
+	p.SubBlocks, p.Alignment = int(b[2]), int(b[3])
+	return nil
+}
+
+// ParseHeader decodes the LCT header at the start of b, and returns it along
+// with the remainder of b. Unknown header extensions are skipped.
+func ParseHeader(b []byte) (Header, []byte, error) {
+	if len(b) < 4 {
+		return Header{}, nil, fountain.ErrShortWireData
+	}
+	if v := b[0] >> 4; v != lctVersion {
+		return Header{}, nil, fmt.Errorf("flute: LCT version %d, want %d", v, lctVersion)
+	}
+	c := int(b[0] >> 2 & 3)
+	s, o, half := int(b[1]>>7), int(b[1]>>5&3), int(b[1]>>4&1)
+	hdrLen := int(b[2]) * 4
+	h := Header{CloseSession: b[1]&2 != 0, CloseObject: b[1]&1 != 0, Codepoint: b[3]}
+	if len(b) < hdrLen {
+		return Header{}, nil, fountain.ErrShortWireData
+	}
+	rest, ext := b[hdrLen:], b[4:hdrLen]
+
+	var err error
+	fields := []struct {
+		n int
+		v *uint32
+	}{
+		{4 * (c + 1), nil},
+		{4*s + 2*half, &h.TSI},
+		{4*o + 2*half, &h.TOI},
+	}
+	for _, f := range fields {
+		if ext, err = parseField(ext, f.n, f.v); err != nil {
+			return Header{}, nil, err
+		}
+	}
+
+	for len(ext) > 0 {
+		het := ext[0]
+		n := 4
+		if het < 128 {
+			if len(ext) < 2 || ext[1] == 0 {
+				return Header{}, nil, fmt.Errorf("flute: malformed header extension %d", het)
+			}
+			n = int(ext[1]) * 4
+		}
+		if len(ext) < n {
+			return Header{}, nil, fountain.ErrShortWireData
+		}
+		switch het {
+		case extFDT:
+			v := fountain.ByteOrder.Uint32(ext)
+			if version := v >> 20 & 15; version != fluteVersion {
+				return Header{}, nil, fmt.Errorf("flute: FLUTE version %d, want %d", version, fluteVersion)
+			}
+			h.HasFDT, h.FDTInstanceID = true, v&maxFDTInstanceID
+		case extFTI:
+			if n != ftiLen {
+				return Header{}, nil, fmt.Errorf("flute: %d-byte EXT_FTI, want %d", n, ftiLen)
+			}
+			p := fountain.ObjectParams{SymbolsPerPacket: 1}
+			for _, x := range ext[2:8] {
+				p.TransferLength = p.TransferLength<<8 | int64(x)
+			}
+			p.SymbolSize = int(fountain.ByteOrder.Uint16(ext[8:]))
+			parseSchemeInfo(ext[10:14], &p)
+			h.OTI = &p
+		}
+		ext = ext[n:]
+	}
+	return h, rest, nil
+}
+
+// parseField decodes an n-byte field at the start of b into v, if v isn't
+// nil, and returns the remainder of b.
+func parseField(b []byte, n int, v *uint32) ([]byte, error) {
+	if len(b) < n {
+		return nil, fountain.ErrShortWireData
+	}
+	var x uint64
+	for _, c := range b[:n] {
+		x = x<<8 | uint64(c)
+	}
+	if v != nil {
+		if x > 1<<32-1 {
+			return nil, fmt.Errorf("flute: identifier %d doesn't fit in 32 bits", x)
+		}
+		*v = uint32(x)
+	}
+	return b[n:], nil
+}
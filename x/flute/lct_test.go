@@ -0,0 +1,80 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flute
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	fountain "github.com/google/gofountain"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	oti := fountain.ObjectParams{TransferLength: 1<<40 + 3, SymbolSize: 1024, Alignment: 4,
+		SourceBlocks: 300, SubBlocks: 2, SymbolsPerPacket: 1}
+	for _, h := range []Header{
+		{TSI: 7, TOI: 1 << 31, Codepoint: RaptorEncodingID},
+		{TSI: 1, TOI: FDTTOI, CloseObject: true, HasFDT: true, FDTInstanceID: maxFDTInstanceID, OTI: &oti},
+		{TSI: 1<<32 - 1, TOI: 9, CloseSession: true, OTI: &oti},
+	} {
+		b := h.AppendTo(nil)
+		if len(b)%4 != 0 || int(b[2])*4 != len(b) {
+			t.Errorf("%+v: HDR_LEN %d for %d bytes", h, b[2], len(b))
+		}
+		got, rest, err := ParseHeader(append(b, 0xaa))
+		if err != nil {
+			t.Fatalf("ParseHeader(%+v) = %v", h, err)
+		}
+		if !reflect.DeepEqual(got, h) || !bytes.Equal(rest, []byte{0xaa}) {
+			t.Errorf("ParseHeader = %+v, %x; want %+v, aa", got, rest, h)
+		}
+	}
+}
+
+func TestParseHeaderVariants(t *testing.T) {
+	// A header with a 16-byte CCI, 16-bit TSI and TOI (S=0, O=0, H=1), and an
+	// unknown fixed-length extension (HET 200).
+	b := []byte{lctVersion<<4 | 3<<2, 1 << 4, 7, 5}
+	b = append(b, make([]byte, 16)...)
+	b = append(b, 0x12, 0x34, 0x00, 0x42)
+	b = append(b, 200, 1, 2, 3)
+	h, rest, err := ParseHeader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Header{TSI: 0x1234, TOI: 0x42, Codepoint: 5}
+	if !reflect.DeepEqual(h, want) || len(rest) != 0 {
+		t.Errorf("ParseHeader = %+v, %x; want %+v", h, rest, want)
+	}
+}
+
+func TestParseHeaderErrors(t *testing.T) {
+	good := Header{TSI: 1, TOI: 2}.AppendTo(nil)
+	if _, _, err := ParseHeader(good[:10]); !errors.Is(err, fountain.ErrShortWireData) {
+		t.Errorf("ParseHeader(truncated) = %v, want ErrShortWireData", err)
+	}
+	v2 := append([]byte{2 << 4}, good[1:]...)
+	if _, _, err := ParseHeader(v2); err == nil {
+		t.Error("ParseHeader accepted LCT version 2")
+	}
+	// A 48-bit TOI which doesn't fit in 32 bits.
+	wide := []byte{lctVersion << 4, 1<<7 | 1<<6 | 1<<4, 5, 0}
+	wide = append(wide, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 0)
+	if _, _, err := ParseHeader(wide); err == nil {
+		t.Error("ParseHeader accepted a TOI of more than 32 bits")
+	}
+}
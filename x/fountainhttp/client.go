@@ -0,0 +1,180 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountainhttp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	fountain "github.com/google/gofountain"
+)
+
+// mirrorSymbols is a batch of symbols received from a mirror, or the error
+// which ended its stream.
+type mirrorSymbols struct {
+	mirror  int
+	params  fountain.ObjectParams
+	symbols []fountain.ObjectSymbol
+	err     error
+}
+
+// batchSize is the number of symbols a mirror's reader hands over at once.
+const batchSize = 16
+
+// Download fetches an object from the given mirrors at once, each serving it
+// with a Handler, and returns it as soon as the symbols received from all of
+// them decode it. The first mirror is asked for the source symbols, which
+// decode the object by themselves if nothing else arrives, and the others for
+// repair symbols from ESIs spread over the ESI space, so that no two mirrors
+// send the same symbols. A nil client means http.DefaultClient. Returns an
+// error if every mirror fails or ends its stream before the object can be
+// decoded, or if the mirrors disagree about the object. Objects longer than
+// fountain.DefaultMaxObjectLength aren't downloaded (see DownloadLimit).
+func Download(ctx context.Context, client *http.Client, mirrors []string) ([]byte, error) {
+	return DownloadLimit(ctx, client, mirrors, fountain.DefaultMaxObjectLength)
+}
+
+// DownloadLimit is like Download, but returns fountain.ErrObjectTooLarge
+// (wrapped) if decoding the object takes more than maxLength bytes (see
+// fountain.ObjectParams.CheckLength).
+func DownloadLimit(ctx context.Context, client *http.Client, mirrors []string, maxLength int64) ([]byte, error) {
+	if len(mirrors) == 0 {
+		return nil, errors.New("fountainhttp: no mirrors")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The readers stop sending once ctx is cancelled, so the channel needn't
+	// be drained.
+	batches := make(chan mirrorSymbols)
+	spacing := (fountain.MaxRaptorESI + 1) / int64(len(mirrors))
+	for i, mirror := range mirrors {
+		go readMirror(ctx, client, i, mirror, int64(i)*spacing, batches)
+	}
+
+	var (
+		params  fountain.ObjectParams
+		decoder *fountain.ObjectDecoder
+		errs    []error
+	)
+	for running := len(mirrors); running > 0; {
+		var b mirrorSymbols
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case b = <-batches:
+		}
+		if b.err != nil {
+			running--
+			if b.err != io.EOF {
+				errs = append(errs, fmt.Errorf("mirror %s: %w", mirrors[b.mirror], b.err))
+			}
+			continue
+		}
+		if decoder == nil {
+			if err := b.params.CheckLength(maxLength); err != nil {
+				return nil, fmt.Errorf("fountainhttp: mirror %s: %w", mirrors[b.mirror], err)
+			}
+			var err error
+			if decoder, err = fountain.NewObjectDecoder(b.params); err != nil {
+				return nil, err
+			}
+			params = b.params
+		} else if b.params != params {
+			return nil, fmt.Errorf("fountainhttp: mirror %s serves %+v, but another serves %+v",
+				mirrors[b.mirror], b.params, params)
+		}
+		decoder.AddSymbols(b.symbols)
+		if decoder.Complete() {
+			return decoder.Object()
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("fountainhttp: object not decoded: %w", errors.Join(errs...))
+	}
+	return nil, errors.New("fountainhttp: every mirror ended its stream before the object could be decoded")
+}
+
+// readMirror requests the symbols from ESI start on from a mirror, and sends
+// them to batches, ending with the error which stopped it, io.EOF if the
+// stream ended. It gives up as soon as ctx is done.
+func readMirror(ctx context.Context, client *http.Client, i int, mirror string, start int64, batches chan<- mirrorSymbols) {
+	send := func(b mirrorSymbols) bool {
+		b.mirror = i
+		select {
+		case batches <- b:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	u, err := url.Parse(mirror)
+	if err != nil {
+		send(mirrorSymbols{err: err})
+		return
+	}
+	q := u.Query()
+	q.Set("start", strconv.FormatInt(start, 10))
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		send(mirrorSymbols{err: err})
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		send(mirrorSymbols{err: err})
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		send(mirrorSymbols{err: fmt.Errorf("status %s", resp.Status)})
+		return
+	}
+
+	r := bufio.NewReader(resp.Body)
+	p, err := ReadOTI(r)
+	if err != nil {
+		send(mirrorSymbols{err: fmt.Errorf("reading stream header: %w", err)})
+		return
+	}
+	for {
+		b := mirrorSymbols{params: p}
+		for len(b.symbols) < batchSize {
+			s, err := ReadFrame(r, p.SymbolSize)
+			if err != nil {
+				b.err = err
+				break
+			}
+			b.symbols = append(b.symbols, s)
+		}
+		if len(b.symbols) > 0 && !send(mirrorSymbols{params: p, symbols: b.symbols}) {
+			return
+		}
+		if b.err != nil {
+			send(mirrorSymbols{err: b.err})
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountainhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+// startRecorder records the start ESIs of the requests it passes on.
+type startRecorder struct {
+	h      http.Handler
+	mu     sync.Mutex
+	starts []string
+}
+
+func (s *startRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.starts = append(s.starts, r.URL.Query().Get("start"))
+	s.mu.Unlock()
+	s.h.ServeHTTP(w, r)
+}
+
+func TestDownload(t *testing.T) {
+	object, e := testObject(t, 30000, 2)
+	rec := &startRecorder{h: NewHandler(e)}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	k := e.Params().SourceBlockSymbols(0)
+
+	// Each mirror only sends a third of what is needed, and one of them
+	// fails, so the object can only be decoded from all three together.
+	third := url.Values{"count": {strconv.Itoa(k/3 + 2)}}.Encode()
+	mirrors := []string{
+		srv.URL + "/a?" + third,
+		srv.URL + "/b?" + third,
+		"http://127.0.0.1:0/unreachable",
+		srv.URL + "/c?" + third,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	got, err := Download(ctx, nil, mirrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, object) {
+		t.Errorf("Download returned %d bytes, want the %d of the object", len(got), len(object))
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	seen := map[string]bool{}
+	for _, s := range rec.starts {
+		if seen[s] {
+			t.Errorf("two mirrors were asked for ESIs from %s", s)
+		}
+		seen[s] = true
+	}
+	if !seen["0"] {
+		t.Errorf("no mirror was asked for the source symbols; starts %v", rec.starts)
+	}
+}
+
+func TestDownloadStopsEarly(t *testing.T) {
+	object, e := testObject(t, 10000, 3)
+	srv := httptest.NewServer(NewHandler(e))
+	defer srv.Close()
+	// The streams are endless, so Download must hang up on them once the
+	// object is decoded.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	got, err := Download(ctx, srv.Client(), []string{srv.URL, srv.URL})
+	if err != nil || !bytes.Equal(got, object) {
+		t.Errorf("Download = %d bytes, %v; want the object", len(got), err)
+	}
+}
+
+func TestDownloadFails(t *testing.T) {
+	_, e := testObject(t, 10000, 4)
+	srv := httptest.NewServer(NewHandler(e))
+	defer srv.Close()
+	if _, err := Download(context.Background(), nil, []string{srv.URL + "?count=2"}); err == nil {
+		t.Error("Download succeeded with too few symbols")
+	}
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if _, err := Download(context.Background(), nil, []string{missing.URL}); err == nil {
+		t.Error("Download succeeded from a mirror answering 404")
+	}
+	if _, err := Download(context.Background(), nil, nil); err == nil {
+		t.Error("Download succeeded without mirrors")
+	}
+	if _, err := DownloadLimit(context.Background(), nil, []string{srv.URL}, 9999); !errors.Is(err, fountain.ErrObjectTooLarge) {
+		t.Errorf("DownloadLimit(9999) of a 10000-byte object = %v, want ErrObjectTooLarge", err)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fountainhttp serves objects over HTTP as streams of encoding
+// symbols, so that a client can download an object from several mirrors at
+// once without coordinating which parts it gets from each.
+//
+// A Handler serves an object encoded with fountain.ObjectEncoder. The
+// response body is self-describing: it starts with the object's FEC Object
+// Transmission Information, and is followed by frames each holding a source
+// block number and an encoding symbol encoded with
+// fountain.LTBlock.AppendBinary. The query parameter "start" gives the first
+// ESI to send, and "count" the number of ESIs; each ESI is sent for every
+// source block in turn. Without a count, symbols are streamed until the client
+// hangs up.
+//
+// Download requests the object from each mirror with a different starting
+// ESI, so that the mirrors send distinct symbols, and stops all the requests
+// as soon as the symbols received decode the object.
+//
+// This package is experimental (see package x).
+package fountainhttp
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	fountain "github.com/google/gofountain"
+)
+
+// ContentType is the media type of a stream of symbols.
+const ContentType = "application/x-gofountain-symbols"
+
+// Wire format versions of a stream of symbols. A stream has the oldest
+// version whose header can describe the object: streams of compressed objects
+// have a version whose header also holds the object's content length, and
+// streams of objects with a Merkle root one whose header also holds the root.
+const (
+	streamVersion           = 1
+	streamCompressedVersion = 2
+	streamMerkleVersion     = 3
+)
+
+// ltBlockVersion is the wire format version of the code blocks written by
+// fountain.LTBlock.AppendBinary which ReadFrame reads.
+const ltBlockVersion = 1
+
+// otiLen is the length in bytes of the encoded FEC Object Transmission
+// Information.
+const otiLen = 12
+
+// contentLengthLen is the length in bytes of the encoded content length.
+const contentLengthLen = 5
+
+// flushInterval is the number of frames written between flushes of the
+// response.
+const flushInterval = 64
+
+// AppendOTI appends the stream header of an object encoded with p to b: a
+// fountain.WireHeader, then the Common and Raptor Scheme-Specific FEC Object
+// Transmission Information of RFC 5053 §3.2: F as 40 bits, the compression in
+// place of the 8 reserved bits, T as 16 bits, Z as 16 bits, N and Al as 8
+// bits. The header of a compressed object is version 2, and is followed by
+// the object's content length as 40 bits. The header of an object with a
+// Merkle root is version 3, and is followed by the content length, zero if
+// the object isn't compressed, and the root.
+func AppendOTI(b []byte, p fountain.ObjectParams) []byte {
+	version := otiVersion(p)
+	b = fountain.WireHeader{Version: version}.AppendTo(b)
+	b = append40(b, p.TransferLength)
+	b = append(b, byte(p.Compression))
+	b = fountain.ByteOrder.AppendUint16(b, uint16(p.SymbolSize))
+	b = fountain.ByteOrder.AppendUint16(b, uint16(p.SourceBlocks))
+	b = append(b, byte(p.SubBlocks), byte(p.Alignment))
+	if version >= streamCompressedVersion {
+		b = append40(b, p.ContentLength)
+	}
+	if version >= streamMerkleVersion {
+		b = append(b, p.MerkleRoot[:]...)
+	}
+	return b
+}
+
+// otiVersion returns the version of the stream header of an object encoded
+// with p.
+func otiVersion(p fountain.ObjectParams) uint8 {
+	switch {
+	case p.MerkleRoot != [sha256.Size]byte{}:
+		return streamMerkleVersion
+	case p.Compression != fountain.CompressionNone:
+		return streamCompressedVersion
+	}
+	return streamVersion
+}
+
+// otiVersionLen returns the length in bytes of a stream header of the given
+// version, after its fountain.WireHeader.
+func otiVersionLen(version uint8) int {
+	switch version {
+	case streamCompressedVersion:
+		return otiLen + contentLengthLen
+	case streamMerkleVersion:
+		return otiLen + contentLengthLen + sha256.Size
+	}
+	return otiLen
+}
+
+// append40 appends the low 40 bits of v to b, most significant byte first.
+func append40(b []byte, v int64) []byte {
+	return append(b, byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// parse40 decodes 40 bits written by append40.
+func parse40(b []byte) int64 {
+	var v int64
+	for _, x := range b[:5] {
+		v = v<<8 | int64(x)
+	}
+	return v
+}
+
+// ParseOTI decodes the stream header at the start of b, and returns the
+// parameters, with one symbol per packet, along with the remainder of b.
+func ParseOTI(b []byte) (fountain.ObjectParams, []byte, error) {
+	h, b, err := fountain.ParseWireHeader(b, streamMerkleVersion)
+	if err != nil {
+		return fountain.ObjectParams{}, nil, err
+	}
+	n := otiVersionLen(h.Version)
+	if len(b) < n {
+		return fountain.ObjectParams{}, nil, fountain.ErrShortWireData
+	}
+	p := fountain.ObjectParams{
+		TransferLength:   parse40(b),
+		SymbolSize:       int(fountain.ByteOrder.Uint16(b[6:])),
+		SourceBlocks:     int(fountain.ByteOrder.Uint16(b[8:])),
+		SubBlocks:        int(b[10]),
+		Alignment:        int(b[11]),
+		SymbolsPerPacket: 1,
+		Compression:      fountain.Compression(b[5]),
+	}
+	if h.Version >= streamCompressedVersion {
+		p.ContentLength = parse40(b[otiLen:])
+	}
+	if h.Version >= streamMerkleVersion {
+		copy(p.MerkleRoot[:], b[otiLen+contentLengthLen:])
+	}
+	// Validate rejects the compression of a version 1 header, which lacks
+	// the content length.
+	if err := p.Validate(); err != nil {
+		return fountain.ObjectParams{}, nil, err
+	}
+	if otiVersion(p) != h.Version {
+		return fountain.ObjectParams{}, nil, fmt.Errorf("fountainhttp: stream header version %d for parameters %+v", h.Version, p)
+	}
+	return p, b[n:], nil
+}
+
+// ReadOTI reads a stream header written by AppendOTI from r, and returns the
+// parameters like ParseOTI.
+func ReadOTI(r *bufio.Reader) (fountain.ObjectParams, error) {
+	head, err := r.Peek(fountain.WireHeaderLen)
+	if err == nil {
+		head, err = r.Peek(fountain.WireHeaderLen + otiVersionLen(head[0]))
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = fountain.ErrShortWireData
+		}
+		return fountain.ObjectParams{}, err
+	}
+	p, _, err := ParseOTI(head)
+	if err != nil {
+		return fountain.ObjectParams{}, err
+	}
+	_, err = r.Discard(len(head))
+	return p, err
+}
+
+// AppendFrame appends a frame holding a symbol to b: its source block number
+// as 16 bits, then the symbol encoded with fountain.LTBlock.AppendBinary.
+func AppendFrame(b []byte, s fountain.ObjectSymbol) []byte {
+	b = fountain.ByteOrder.AppendUint16(b, uint16(s.SBN))
+	b, _ = s.LTBlock.AppendBinary(b)
+	return b
+}
+
+// ReadFrame reads a frame from r, whose symbols must be of symbolSize bytes.
+// Returns io.EOF if r ends before the frame starts.
+func ReadFrame(r *bufio.Reader, symbolSize int) (fountain.ObjectSymbol, error) {
+	var head [2 + fountain.WireHeaderLen]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fountain.ErrShortWireData
+		}
+		return fountain.ObjectSymbol{}, err
+	}
+	h, _, err := fountain.ParseWireHeader(head[2:], ltBlockVersion)
+	if err != nil {
+		return fountain.ObjectSymbol{}, err
+	}
+	// The block's fields are all varints, so only its byte order flag is
+	// harmless.
+	if h.Flags&^fountain.WireFlagLittleEndian != 0 {
+		return fountain.ObjectSymbol{}, fmt.Errorf("fountainhttp: unsupported code block flags %#x", h.Flags)
+	}
+	code, err := binary.ReadVarint(r)
+	if err != nil {
+		return fountain.ObjectSymbol{}, fountain.ErrShortWireData
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fountain.ObjectSymbol{}, fountain.ErrShortWireData
+	}
+	if size != uint64(symbolSize) || code < 0 || code > fountain.MaxRaptorESI {
+		return fountain.ObjectSymbol{}, fmt.Errorf("fountainhttp: symbol %d of %d bytes, want ESI up to %d and %d bytes",
+			code, size, fountain.MaxRaptorESI, symbolSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fountain.ObjectSymbol{}, fountain.ErrShortWireData
+	}
+	return fountain.ObjectSymbol{SBN: int(fountain.ByteOrder.Uint16(head[:])),
+		LTBlock: fountain.LTBlock{BlockCode: int64(code), Data: data}}, nil
+}
+
+// Handler serves an object as a stream of symbols. It may serve any number
+// of requests at once.
+type Handler struct {
+	// mu serializes the calls generating symbols, as encoders needn't be safe
+	// for concurrent use.
+	mu      sync.Mutex
+	encoder *fountain.ObjectEncoder
+}
+
+// NewHandler creates a handler serving the object encoded by e.
+func NewHandler(e *fountain.ObjectEncoder) *Handler {
+	return &Handler{encoder: e}
+}
+
+// ServeHTTP streams the symbols the request's query asks for.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	start, count, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	p := h.encoder.Params()
+	if _, err := w.Write(AppendOTI(nil, p)); err != nil {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	var buf []byte
+	frames := 0
+	for esi := start; esi <= fountain.MaxRaptorESI && (count < 0 || esi < start+count); esi++ {
+		if r.Context().Err() != nil {
+			return
+		}
+		for sbn := 0; sbn < p.SourceBlocks; sbn++ {
+			h.mu.Lock()
+			s, err := h.encoder.Symbol(sbn, esi)
+			h.mu.Unlock()
+			if err != nil {
+				return
+			}
+			buf = AppendFrame(buf[:0], s)
+			if _, err := w.Write(buf); err != nil {
+				return
+			}
+			if frames++; frames%flushInterval == 0 && flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// parseRange returns the first ESI and the number of ESIs a request asks
+// for, which is negative if it asks for them all.
+func parseRange(r *http.Request) (start, count int64, err error) {
+	q := r.URL.Query()
+	count = -1
+	if s := q.Get("start"); s != "" {
+		if start, err = strconv.ParseInt(s, 10, 64); err != nil || start < 0 || start > fountain.MaxRaptorESI {
+			return 0, 0, fmt.Errorf("fountainhttp: invalid start ESI %q", s)
+		}
+	}
+	if s := q.Get("count"); s != "" {
+		if count, err = strconv.ParseInt(s, 10, 64); err != nil || count < 0 {
+			return 0, 0, fmt.Errorf("fountainhttp: invalid count %q", s)
+		}
+	}
+	return start, count, nil
+}
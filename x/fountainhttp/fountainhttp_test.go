@@ -0,0 +1,181 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountainhttp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fountain "github.com/google/gofountain"
+)
+
+// testObject returns an object of n bytes and an encoder of it.
+func testObject(t *testing.T, n int, seed int64) ([]byte, *fountain.ObjectEncoder) {
+	t.Helper()
+	object := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(object)
+	p, err := fountain.DeriveObjectParams(int64(n), 4, 256, 2048, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := fountain.NewObjectEncoder(object, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return object, e
+}
+
+func TestOTIRoundTrip(t *testing.T) {
+	p := fountain.ObjectParams{TransferLength: 1<<40 - 1, SymbolSize: 65532, Alignment: 4,
+		SourceBlocks: 65535, SubBlocks: 3, SymbolsPerPacket: 1}
+	b := AppendOTI(nil, p)
+	if len(b) != fountain.WireHeaderLen+otiLen || b[0] != streamVersion {
+		t.Errorf("AppendOTI wrote %x, want a version 1 header of %d bytes", b, fountain.WireHeaderLen+otiLen)
+	}
+	got, rest, err := ParseOTI(append(b, 7))
+	if err != nil || got != p || !bytes.Equal(rest, []byte{7}) {
+		t.Errorf("ParseOTI = %+v, %x, %v; want %+v", got, rest, err, p)
+	}
+	if _, _, err := ParseOTI(b[:9]); !errors.Is(err, fountain.ErrShortWireData) {
+		t.Errorf("ParseOTI(truncated) = %v, want ErrShortWireData", err)
+	}
+
+	// The header of a compressed object is followed by its content length.
+	p.Compression, p.ContentLength = fountain.CompressionDeflate, 1<<40-1
+	b = AppendOTI(nil, p)
+	if len(b) != fountain.WireHeaderLen+otiLen+contentLengthLen || b[0] != streamCompressedVersion {
+		t.Errorf("AppendOTI of a compressed object wrote %x, want a version 2 header", b)
+	}
+	if got, err := ReadOTI(bufio.NewReader(bytes.NewReader(append(b, 7)))); err != nil || got != p {
+		t.Errorf("ReadOTI = %+v, %v; want %+v", got, err, p)
+	}
+	if _, err := ReadOTI(bufio.NewReader(bytes.NewReader(b[:len(b)-1]))); !errors.Is(err, fountain.ErrShortWireData) {
+		t.Errorf("ReadOTI(truncated) = %v, want ErrShortWireData", err)
+	}
+	if _, _, err := ParseOTI(append([]byte{streamVersion}, b[1:]...)); err == nil {
+		t.Error("ParseOTI accepted a compression in a version 1 header")
+	}
+
+	// The header of an object with a Merkle root is followed by the root.
+	p.Compression, p.ContentLength = fountain.CompressionNone, 0
+	p.MerkleRoot[0], p.MerkleRoot[31] = 1, 2
+	b = AppendOTI(nil, p)
+	if len(b) != fountain.WireHeaderLen+otiLen+contentLengthLen+sha256.Size || b[0] != streamMerkleVersion {
+		t.Errorf("AppendOTI of an object with a Merkle root wrote %x, want a version 3 header", b)
+	}
+	if got, err := ReadOTI(bufio.NewReader(bytes.NewReader(b))); err != nil || got != p {
+		t.Errorf("ReadOTI = %+v, %v; want %+v", got, err, p)
+	}
+	if _, _, err := ParseOTI(append([]byte{streamCompressedVersion}, b[1:]...)); err == nil {
+		t.Error("ParseOTI accepted a version 2 header of an uncompressed object")
+	}
+	p.MerkleRoot = [sha256.Size]byte{}
+	p.Compression, p.ContentLength = fountain.CompressionDeflate, 1<<40-1
+	p.Compression = 99
+	if _, _, err := ParseOTI(AppendOTI(nil, p)); err == nil {
+		t.Error("ParseOTI accepted an unknown compression")
+	}
+	p.SourceBlocks, p.Compression, p.ContentLength = 0, fountain.CompressionNone, 0
+	if _, _, err := ParseOTI(AppendOTI(nil, p)); !errors.Is(err, fountain.ErrNonCompliant) {
+		t.Errorf("ParseOTI(Z=0) = %v, want ErrNonCompliant", err)
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	symbols := []fountain.ObjectSymbol{
+		{SBN: 3, LTBlock: fountain.LTBlock{BlockCode: 0, Data: []byte{1, 2, 3, 4}}},
+		{SBN: 0, LTBlock: fountain.LTBlock{BlockCode: fountain.MaxRaptorESI, Data: []byte{5, 6, 7, 8}}},
+	}
+	var b []byte
+	for _, s := range symbols {
+		b = AppendFrame(b, s)
+	}
+	r := bufio.NewReader(bytes.NewReader(b))
+	for _, want := range symbols {
+		got, err := ReadFrame(r, 4)
+		if err != nil || got.SBN != want.SBN || got.BlockCode != want.BlockCode || !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("ReadFrame = %+v, %v; want %+v", got, err, want)
+		}
+	}
+	if _, err := ReadFrame(r, 4); err != io.EOF {
+		t.Errorf("ReadFrame at the end = %v, want EOF", err)
+	}
+	if _, err := ReadFrame(bufio.NewReader(bytes.NewReader(b)), 8); err == nil {
+		t.Error("ReadFrame accepted a symbol of the wrong size")
+	}
+	checksummed := fountain.ByteOrder.AppendUint16(nil, 0)
+	checksummed = symbols[0].LTBlock.AppendBinaryChecksum(checksummed)
+	if _, err := ReadFrame(bufio.NewReader(bytes.NewReader(checksummed)), 4); err == nil {
+		t.Error("ReadFrame accepted a checksummed code block")
+	}
+	r = bufio.NewReader(bytes.NewReader(b[:len(b)-1]))
+	ReadFrame(r, 4)
+	if _, err := ReadFrame(r, 4); !errors.Is(err, fountain.ErrShortWireData) {
+		t.Errorf("ReadFrame of a truncated frame = %v, want ErrShortWireData", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	_, e := testObject(t, 5000, 1)
+	p := e.Params()
+	srv := httptest.NewServer(NewHandler(e))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?start=10&count=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ContentType)
+	}
+	r := bufio.NewReader(resp.Body)
+	if got, err := ReadOTI(r); err != nil || got != p {
+		t.Fatalf("ReadOTI = %+v, %v; want %+v", got, err, p)
+	}
+	for esi := int64(10); esi < 13; esi++ {
+		for sbn := 0; sbn < p.SourceBlocks; sbn++ {
+			got, err := ReadFrame(r, p.SymbolSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := e.Symbol(sbn, esi)
+			if got.SBN != sbn || got.BlockCode != esi || !bytes.Equal(got.Data, want.Data) {
+				t.Errorf("frame = %d/%d, want %d/%d", got.SBN, got.BlockCode, sbn, esi)
+			}
+		}
+	}
+	if _, err := ReadFrame(r, p.SymbolSize); err != io.EOF {
+		t.Errorf("ReadFrame past the count = %v, want EOF", err)
+	}
+
+	for _, query := range []string{"?start=-1", "?start=65536", "?count=x"} {
+		resp, err := http.Get(srv.URL + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quicdgram carries fountain code blocks in QUIC unreliable datagrams
+// (RFC 9221).
+//
+// QUIC datagrams are neither retransmitted nor reordered into sequence, which
+// suits code blocks: a lost datagram needs no repair of its own, as any other
+// code block makes up for it. A Sender writes the code blocks of an encoder,
+// each encoded with fountain.LTBlock.AppendBinary and one or more to a
+// datagram, and a Receiver feeds the code blocks of the datagrams it receives
+// to a decoder.
+//
+// The package doesn't depend on a QUIC implementation. It uses connections
+// through the DatagramConn interface, which the connections of quic-go
+// implement, so they can be passed in as they are. A datagram must fit in a
+// single QUIC packet, so SymbolSize computes the largest symbol size whose
+// code blocks fit in the datagrams a connection accepts.
+//
+// This package is experimental (see package x).
+package quicdgram
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	fountain "github.com/google/gofountain"
+)
+
+// DefaultMaxDatagramSize is the largest datagram payload which fits in a QUIC
+// packet on any path: the 1200-byte minimum UDP payload QUIC requires,
+// less the largest short header (a flags byte, a 20-byte connection ID and a
+// 4-byte packet number), the 16-byte AEAD tag and the DATAGRAM frame's type
+// and 2-byte length.
+const DefaultMaxDatagramSize = 1200 - (1 + 20 + 4) - 16 - (1 + 2)
+
+// ErrDatagramTooLarge is returned (wrapped) by Sender.Send when the code
+// blocks of a datagram don't fit in the largest datagram.
+var ErrDatagramTooLarge = errors.New("quicdgram: datagram too large")
+
+// ErrBlockCodesExhausted is returned by Sender.Send once it has sent the code
+// block with the last block code.
+var ErrBlockCodesExhausted = errors.New("quicdgram: block codes exhausted")
+
+// DatagramConn is the part of a QUIC connection with the DATAGRAM extension
+// the package uses. The connections of quic-go implement it.
+type DatagramConn interface {
+	// SendDatagram sends payload as an unreliable datagram.
+	SendDatagram(payload []byte) error
+
+	// ReceiveDatagram waits for the next datagram, and returns its payload,
+	// which the caller owns. Returns ctx's error if it is done first.
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// encodedLen returns the length of a code block with the given block code and
+// size of data encoded by AppendBinary.
+func encodedLen(code int64, size int) int {
+	var buf [binary.MaxVarintLen64]byte
+	return fountain.WireHeaderLen + binary.PutVarint(buf[:], code) +
+		binary.PutUvarint(buf[:], uint64(size)) + size
+}
+
+// SymbolSize returns the largest symbol size, a multiple of alignment, for
+// which blocksPerDatagram code blocks with block codes up to maxBlockCode fit
+// in a datagram of maxDatagramSize bytes. Returns 0 if no symbol size fits.
+func SymbolSize(maxDatagramSize, blocksPerDatagram, alignment int, maxBlockCode int64) int {
+	if maxDatagramSize <= 0 || blocksPerDatagram <= 0 || alignment <= 0 || maxBlockCode < 0 {
+		return 0
+	}
+	// Start from the size which ignores the framing, and back off until the
+	// framing fits too; the framing grows by at most a few bytes.
+	t := maxDatagramSize / blocksPerDatagram / alignment * alignment
+	for ; t > 0; t -= alignment {
+		if blocksPerDatagram*encodedLen(maxBlockCode, t) <= maxDatagramSize {
+			return t
+		}
+	}
+	return 0
+}
+
+// SenderConfig configures a Sender.
+type SenderConfig struct {
+	// MaxDatagramSize is the largest datagram the connection accepts. If
+	// zero, it is DefaultMaxDatagramSize.
+	MaxDatagramSize int
+
+	// BlocksPerDatagram is the number of code blocks packed in each datagram.
+	// If zero, each datagram holds one code block.
+	BlocksPerDatagram int
+
+	// FirstBlockCode is the block code of the first code block sent.
+	FirstBlockCode int64
+
+	// LastBlockCode is the largest block code sent, which must be set:
+	// usually fountain.LastBlockCode of the encoder's codec, which is
+	// fountain.MaxDistinctRaptorESI for the raptor codec, and math.MaxInt64
+	// for codecs whose block codes are unbounded.
+	LastBlockCode int64
+}
+
+// Sender sends the code blocks of an encoder over a QUIC connection.
+type Sender struct {
+	conn    DatagramConn
+	encoder fountain.Encoder
+	config  SenderConfig
+
+	// code is the block code of the next code block to send.
+	code int64
+
+	// Datagrams counts the datagrams sent.
+	Datagrams int
+}
+
+// NewSender creates a sender of the code blocks of e over conn.
+func NewSender(conn DatagramConn, e fountain.Encoder, config SenderConfig) (*Sender, error) {
+	if config.MaxDatagramSize == 0 {
+		config.MaxDatagramSize = DefaultMaxDatagramSize
+	}
+	if config.BlocksPerDatagram == 0 {
+		config.BlocksPerDatagram = 1
+	}
+	if config.MaxDatagramSize < 0 || config.BlocksPerDatagram < 0 || config.FirstBlockCode < 0 ||
+		config.LastBlockCode <= 0 || config.LastBlockCode < config.FirstBlockCode {
+		return nil, fmt.Errorf("quicdgram: invalid sender config %+v", config)
+	}
+	return &Sender{conn: conn, encoder: e, config: config, code: config.FirstBlockCode}, nil
+}
+
+// Send sends the next n datagrams, or until ctx is done. If n is negative, it
+// sends datagrams until then. Returns ErrDatagramTooLarge (wrapped) without
+// sending anything more if a datagram's code blocks don't fit; the symbol
+// size should be chosen with SymbolSize. Returns ErrBlockCodesExhausted once
+// the code block with the last block code has been sent; the last datagram
+// may hold fewer code blocks than the others.
+func (s *Sender) Send(ctx context.Context, n int) error {
+	for i := 0; n < 0 || i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if s.code > s.config.LastBlockCode {
+			return ErrBlockCodesExhausted
+		}
+		blocks := min(int64(s.config.BlocksPerDatagram), s.config.LastBlockCode-s.code+1)
+		var datagram []byte
+		for j := int64(0); j < blocks; j++ {
+			datagram, _ = s.encoder.Generate(s.code + j).AppendBinary(datagram)
+		}
+		if len(datagram) > s.config.MaxDatagramSize {
+			return fmt.Errorf("%w: %d bytes for block codes from %d, but the largest datagram is %d bytes",
+				ErrDatagramTooLarge, len(datagram), s.code, s.config.MaxDatagramSize)
+		}
+		if err := s.conn.SendDatagram(datagram); err != nil {
+			return err
+		}
+		s.code += blocks
+		s.Datagrams++
+	}
+	return nil
+}
+
+// Receiver feeds the code blocks of the datagrams arriving on a QUIC
+// connection to a decoder.
+type Receiver struct {
+	conn    DatagramConn
+	decoder fountain.Decoder
+	done    chan struct{}
+
+	// Datagrams counts the datagrams received, and Rejected those of them
+	// which didn't hold well-formed code blocks.
+	Datagrams, Rejected int
+}
+
+// NewReceiver creates a receiver feeding code blocks received over conn to
+// d.
+func NewReceiver(conn DatagramConn, d fountain.Decoder) *Receiver {
+	return &Receiver{conn: conn, decoder: d, done: make(chan struct{})}
+}
+
+// Done returns a channel which is closed once the message can be decoded.
+func (r *Receiver) Done() <-chan struct{} {
+	return r.done
+}
+
+// Decoder returns the receiver's decoder.
+func (r *Receiver) Decoder() fountain.Decoder {
+	return r.decoder
+}
+
+// Receive receives datagrams until the message can be decoded, and then
+// returns nil. Returns ctx's error if it is done first, or the connection's
+// error if receiving fails. Receive may only be called from one goroutine at
+// a time.
+func (r *Receiver) Receive(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return nil
+	default:
+	}
+	for {
+		datagram, err := r.conn.ReceiveDatagram(ctx)
+		if err != nil {
+			return err
+		}
+		r.Datagrams++
+		blocks, err := parseDatagram(datagram)
+		if err != nil {
+			r.Rejected++
+			continue
+		}
+		// The caller owns the datagram, so the decoder can take ownership of
+		// the blocks' data, which refers to it.
+		if r.decoder.AddBlocks(blocks) {
+			close(r.done)
+			return nil
+		}
+	}
+}
+
+// parseDatagram splits a datagram into its code blocks, whose data refers to
+// the datagram.
+func parseDatagram(datagram []byte) ([]fountain.LTBlock, error) {
+	var blocks []fountain.LTBlock
+	for len(datagram) > 0 {
+		b, rest, err := fountain.ParseLTBlock(datagram)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+		datagram = rest
+	}
+	if len(blocks) == 0 {
+		return nil, fountain.ErrShortWireData
+	}
+	return blocks, nil
+}
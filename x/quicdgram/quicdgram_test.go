@@ -0,0 +1,171 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quicdgram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	fountain "github.com/google/gofountain"
+)
+
+// lossyConn is a DatagramConn which delivers the datagrams it sends to itself,
+// dropping every dropEvery'th, and refuses those larger than maxSize.
+type lossyConn struct {
+	datagrams chan []byte
+	maxSize   int
+	dropEvery int
+	sent      int
+}
+
+func newLossyConn(maxSize, dropEvery int) *lossyConn {
+	return &lossyConn{datagrams: make(chan []byte, 4096), maxSize: maxSize, dropEvery: dropEvery}
+}
+
+func (c *lossyConn) SendDatagram(payload []byte) error {
+	if len(payload) > c.maxSize {
+		return errors.New("datagram too large")
+	}
+	c.sent++
+	if c.dropEvery > 0 && c.sent%c.dropEvery == 0 {
+		return nil
+	}
+	c.datagrams <- append([]byte(nil), payload...)
+	return nil
+}
+
+func (c *lossyConn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case d := <-c.datagrams:
+		return d, nil
+	}
+}
+
+func TestSendReceive(t *testing.T) {
+	const k = 40
+	for _, g := range []int{1, 3} {
+		symbolSize := SymbolSize(DefaultMaxDatagramSize, g, 4, fountain.MaxRaptorESI)
+		message := make([]byte, k*symbolSize)
+		rand.New(rand.NewSource(int64(g))).Read(message)
+		codec := fountain.NewRaptorCodec(k, 4)
+
+		conn := newLossyConn(DefaultMaxDatagramSize, 4)
+		s, err := NewSender(conn, codec.NewEncoder(message), SenderConfig{BlocksPerDatagram: g, LastBlockCode: fountain.LastBlockCode(codec)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Send(context.Background(), 2*k/g); err != nil {
+			t.Fatalf("G=%d: Send = %v", g, err)
+		}
+		r := NewReceiver(conn, codec.NewDecoder(len(message)))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := r.Receive(ctx); err != nil {
+			t.Fatalf("G=%d: Receive = %v after %d datagrams", g, err, r.Datagrams)
+		}
+		got, err := fountain.TryDecode(r.Decoder())
+		if err != nil || !bytes.Equal(got, message) {
+			t.Errorf("G=%d: decoded %d bytes, %v; want the message", g, len(got), err)
+		}
+	}
+}
+
+func TestSendTooLarge(t *testing.T) {
+	codec := fountain.NewRaptorCodec(10, 4)
+	message := make([]byte, 10*400)
+	conn := newLossyConn(1000, 0)
+	s, err := NewSender(conn, codec.NewEncoder(message), SenderConfig{MaxDatagramSize: 1000, BlocksPerDatagram: 3, LastBlockCode: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), 1); !errors.Is(err, ErrDatagramTooLarge) {
+		t.Errorf("Send = %v, want ErrDatagramTooLarge", err)
+	}
+	if conn.sent != 0 {
+		t.Errorf("sent %d datagrams, want none", conn.sent)
+	}
+}
+
+func TestSendExhausted(t *testing.T) {
+	codec := fountain.NewRaptorCodec(10, 4)
+	conn := newLossyConn(1000, 0)
+	s, err := NewSender(conn, codec.NewEncoder(make([]byte, 40)),
+		SenderConfig{BlocksPerDatagram: 2, FirstBlockCode: fountain.MaxDistinctRaptorESI - 2, LastBlockCode: fountain.LastBlockCode(codec)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), -1); err != ErrBlockCodesExhausted {
+		t.Errorf("Send = %v, want ErrBlockCodesExhausted", err)
+	}
+	if s.Datagrams != 2 {
+		t.Errorf("sent %d datagrams, want 2", s.Datagrams)
+	}
+	if _, err := NewSender(conn, codec.NewEncoder(make([]byte, 40)), SenderConfig{}); err == nil {
+		t.Error("NewSender accepted a config without a last block code")
+	}
+}
+
+func TestSymbolSize(t *testing.T) {
+	for _, test := range []struct {
+		max, g, al int
+		code       int64
+	}{
+		{DefaultMaxDatagramSize, 1, 1, fountain.MaxRaptorESI},
+		{DefaultMaxDatagramSize, 4, 4, 1 << 40},
+		{1400, 2, 16, 100},
+		{300, 7, 8, 1 << 20},
+	} {
+		t0 := SymbolSize(test.max, test.g, test.al, test.code)
+		if t0 <= 0 || t0%test.al != 0 {
+			t.Errorf("SymbolSize(%+v) = %d", test, t0)
+			continue
+		}
+		if n := test.g * encodedLen(test.code, t0); n > test.max {
+			t.Errorf("SymbolSize(%+v) = %d: datagram of %d bytes", test, t0, n)
+		}
+		if n := test.g * encodedLen(test.code, t0+test.al); n <= test.max {
+			t.Errorf("SymbolSize(%+v) = %d, but %d fits", test, t0, t0+test.al)
+		}
+		// The size matches what AppendBinary writes.
+		b, _ := fountain.LTBlock{BlockCode: test.code, Data: make([]byte, t0)}.AppendBinary(nil)
+		if len(b) != encodedLen(test.code, t0) {
+			t.Errorf("encodedLen = %d, AppendBinary wrote %d bytes", encodedLen(test.code, t0), len(b))
+		}
+	}
+	if got := SymbolSize(4, 1, 1, 0); got != 0 {
+		t.Errorf("SymbolSize of a 4-byte datagram = %d, want 0", got)
+	}
+}
+
+func TestReceiverRejects(t *testing.T) {
+	conn := newLossyConn(DefaultMaxDatagramSize, 0)
+	conn.SendDatagram([]byte{0xff, 0, 1})
+	conn.SendDatagram(nil)
+	r := NewReceiver(conn, fountain.NewRaptorCodec(10, 4).NewDecoder(400))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Receive(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Receive = %v, want DeadlineExceeded", err)
+	}
+	if r.Datagrams != 2 || r.Rejected != 2 {
+		t.Errorf("Datagrams, Rejected = %d, %d; want 2, 2", r.Datagrams, r.Rejected)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fountain_grpc
+
+package repairrpc
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+)
+
+// RepairServer is the server API of the Repair service.
+type RepairServer interface {
+	StreamRepair(*Request, SymbolSender) error
+}
+
+// streamRepairMethod is the full name of the StreamRepair method.
+const streamRepairMethod = "/gofountain.repair.v1.Repair/StreamRepair"
+
+// ServiceDesc is the gRPC description of the Repair service of repair.proto.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gofountain.repair.v1.Repair",
+	HandlerType: (*RepairServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "StreamRepair",
+		Handler:       streamRepairHandler,
+		ServerStreams: true,
+	}},
+	Metadata: "repair.proto",
+}
+
+// RegisterServer registers s with a gRPC server, which must be created with
+// the ServerCodec option.
+func RegisterServer(r grpc.ServiceRegistrar, s RepairServer) {
+	r.RegisterService(&ServiceDesc, s)
+}
+
+// streamRepairHandler receives the request of a StreamRepair call and passes
+// it to the service's handler.
+func streamRepairHandler(srv any, stream grpc.ServerStream) error {
+	req := new(Request)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(RepairServer).StreamRepair(req, symbolSender{stream})
+}
+
+// symbolSender is the server side of a StreamRepair stream of a gRPC server.
+type symbolSender struct {
+	grpc.ServerStream
+}
+
+func (s symbolSender) Send(sym *Symbol) error {
+	return s.SendMsg(sym)
+}
+
+// NewCall returns a function which calls StreamRepair through cc, for Fetch.
+func NewCall(cc grpc.ClientConnInterface, opts ...grpc.CallOption) func(context.Context, *Request) (SymbolReceiver, error) {
+	opts = append([]grpc.CallOption{grpc.ForceCodec(codec{})}, opts...)
+	return func(ctx context.Context, req *Request) (SymbolReceiver, error) {
+		stream, err := cc.NewStream(ctx, &ServiceDesc.Streams[0], streamRepairMethod, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.SendMsg(req); err != nil {
+			return nil, err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return nil, err
+		}
+		return symbolReceiver{stream}, nil
+	}
+}
+
+// symbolReceiver is the client side of a StreamRepair stream of a gRPC client.
+type symbolReceiver struct {
+	grpc.ClientStream
+}
+
+func (r symbolReceiver) Recv() (*Symbol, error) {
+	s := new(Symbol)
+	if err := r.RecvMsg(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ServerCodec returns the option creating a gRPC server with the codec of
+// the service's messages. Since they are encoded in the protocol buffer wire
+// format, the codec is named "proto", and it leaves the messages of the
+// server's other services to the protocol buffer codec.
+func ServerCodec() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+// codec encodes the service's messages with their MarshalBinary and
+// UnmarshalBinary methods, and other messages with the registered protocol
+// buffer codec.
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	switch m := v.(type) {
+	case *Request:
+		return m.MarshalBinary()
+	case *Symbol:
+		return m.MarshalBinary()
+	}
+	c, err := protoCodec(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	switch m := v.(type) {
+	case *Request:
+		return m.UnmarshalBinary(data)
+	case *Symbol:
+		return m.UnmarshalBinary(data)
+	}
+	c, err := protoCodec(v)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(data, v)
+}
+
+func (codec) Name() string {
+	return proto.Name
+}
+
+// protoCodec returns the registered protocol buffer codec, to encode v.
+func protoCodec(v any) (encoding.Codec, error) {
+	c := encoding.GetCodec(proto.Name)
+	if c == nil {
+		return nil, fmt.Errorf("repairrpc: no codec for %T", v)
+	}
+	return c, nil
+}
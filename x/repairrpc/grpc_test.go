@@ -0,0 +1,71 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fountain_grpc
+
+package repairrpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	fountain "github.com/google/gofountain"
+)
+
+// fakeServerStream is the server stream of a call, carrying messages through
+// the service's codec.
+type fakeServerStream struct {
+	grpc.ServerStream
+	req  []byte
+	sent []Symbol
+}
+
+func (f *fakeServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func (f *fakeServerStream) RecvMsg(m any) error {
+	return codec{}.Unmarshal(f.req, m)
+}
+
+func (f *fakeServerStream) SendMsg(m any) error {
+	b, err := codec{}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var s Symbol
+	if err := (codec{}).Unmarshal(b, &s); err != nil {
+		return err
+	}
+	f.sent = append(f.sent, s)
+	return nil
+}
+
+func TestServiceDesc(t *testing.T) {
+	s := NewServer()
+	s.Register("a", fountain.NewRaptorCodec(10, 4).NewEncoder(make([]byte, 400)))
+	req, _ := (&Request{ObjectID: "a", StartESI: 7, Count: 3}).MarshalBinary()
+	stream := &fakeServerStream{req: req}
+	if err := ServiceDesc.Streams[0].Handler(s, stream); err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.sent) != 3 || stream.sent[0].ESI != 7 || stream.sent[2].ESI != 9 {
+		t.Errorf("handler sent %+v, want ESIs 7 through 9", stream.sent)
+	}
+	if _, ok := any(s).(RepairServer); !ok || (codec{}).Name() != "proto" {
+		t.Error("Server doesn't implement RepairServer, or the codec isn't named proto")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repairrpc
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	fountain "github.com/google/gofountain"
+)
+
+// The messages are encoded in the protocol buffer wire format, as described
+// by repair.proto, so that they can be carried by gRPC with a codec calling
+// their MarshalBinary and UnmarshalBinary methods, without generated code.
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Request is a RepairRequest: a request for the code blocks of an object.
+type Request struct {
+	// ObjectID is the ID the object was registered with on the server.
+	ObjectID string
+
+	// StartESI is the ESI of the first code block to send.
+	StartESI int64
+
+	// Count is the number of code blocks to send, or 0 to send them until
+	// the call is cancelled.
+	Count uint32
+}
+
+// MarshalBinary encodes the request in the protocol buffer wire format.
+// Implements encoding.BinaryMarshaler.
+func (r *Request) MarshalBinary() ([]byte, error) {
+	var b []byte
+	if r.ObjectID != "" {
+		b = appendBytesField(b, 1, []byte(r.ObjectID))
+	}
+	if r.StartESI != 0 {
+		b = appendVarintField(b, 2, uint64(r.StartESI))
+	}
+	if r.Count != 0 {
+		b = appendVarintField(b, 3, uint64(r.Count))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a request encoded in the protocol buffer wire
+// format into r. Unknown fields are skipped. Implements
+// encoding.BinaryUnmarshaler.
+func (r *Request) UnmarshalBinary(data []byte) error {
+	*r = Request{}
+	return parseFields(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			r.ObjectID = string(b)
+		case 2:
+			if v > 1<<63-1 {
+				return fmt.Errorf("repairrpc: start ESI %d is out of range", v)
+			}
+			r.StartESI = int64(v)
+		case 3:
+			r.Count = uint32(v)
+		}
+		return nil
+	})
+}
+
+// Symbol is a RepairSymbol: a code block of an object.
+type Symbol struct {
+	// ESI is the code block's ESI.
+	ESI int64
+
+	// Data is the code block's data.
+	Data []byte
+}
+
+// MarshalBinary encodes the symbol in the protocol buffer wire format.
+// Implements encoding.BinaryMarshaler.
+func (s *Symbol) MarshalBinary() ([]byte, error) {
+	var b []byte
+	if s.ESI != 0 {
+		b = appendVarintField(b, 1, uint64(s.ESI))
+	}
+	if len(s.Data) != 0 {
+		b = appendBytesField(b, 2, s.Data)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a symbol encoded in the protocol buffer wire format
+// into s. The data is copied. Unknown fields are skipped. Implements
+// encoding.BinaryUnmarshaler.
+func (s *Symbol) UnmarshalBinary(data []byte) error {
+	*s = Symbol{}
+	return parseFields(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			if v > 1<<63-1 {
+				return fmt.Errorf("repairrpc: ESI %d is out of range", v)
+			}
+			s.ESI = int64(v)
+		case 2:
+			s.Data = append([]byte(nil), b...)
+		}
+		return nil
+	})
+}
+
+// LTBlock returns the symbol as a code block, whose data is the symbol's.
+func (s *Symbol) LTBlock() fountain.LTBlock {
+	return fountain.LTBlock{BlockCode: s.ESI, Data: s.Data}
+}
+
+// appendVarintField appends a varint field to b.
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytesField appends a length-delimited field to b.
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// parseFields calls f with the number and value of each field of a message
+// encoded in the protocol buffer wire format: v for varint fields, and b for
+// length-delimited ones. Fixed-size fields are skipped.
+func parseFields(data []byte, f func(field int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fountain.ErrShortWireData
+		}
+		data = data[n:]
+		field := int(key >> 3)
+		if field == 0 {
+			return fmt.Errorf("repairrpc: field number 0")
+		}
+		var v uint64
+		var b []byte
+		switch key & 7 {
+		case wireVarint:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return fountain.ErrShortWireData
+			}
+			data = data[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return fountain.ErrShortWireData
+			}
+			b, data = data[n:n+int(length)], data[n+int(length):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if key&7 == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return fountain.ErrShortWireData
+			}
+			data = data[size:]
+			continue
+		default:
+			return fmt.Errorf("repairrpc: unsupported wire type %d", key&7)
+		}
+		if err := f(field, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repairrpc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	for _, r := range []Request{
+		{},
+		{ObjectID: "bucket/object", StartESI: 70000, Count: 300},
+		{ObjectID: "x", StartESI: 1<<63 - 1},
+	} {
+		b, _ := r.MarshalBinary()
+		var got Request
+		if err := got.UnmarshalBinary(b); err != nil || got != r {
+			t.Errorf("UnmarshalBinary(%x) = %+v, %v; want %+v", b, got, err, r)
+		}
+	}
+}
+
+func TestRequestWireFormat(t *testing.T) {
+	// The encoding protoc-generated code writes for
+	// {object_id: "ab", start_esi: 300, count: 1}.
+	want := []byte{0x0a, 2, 'a', 'b', 0x10, 0xac, 0x02, 0x18, 1}
+	b, _ := (&Request{ObjectID: "ab", StartESI: 300, Count: 1}).MarshalBinary()
+	if !bytes.Equal(b, want) {
+		t.Errorf("MarshalBinary = %x, want %x", b, want)
+	}
+}
+
+func TestSymbolRoundTrip(t *testing.T) {
+	s := Symbol{ESI: 12345, Data: []byte{1, 2, 3, 4}}
+	b, _ := s.MarshalBinary()
+	// Unknown fields of every wire type are skipped.
+	b = append(b, 0x18, 5) // varint field 3
+	b = append(b, 0x21, 1, 2, 3, 4, 5, 6, 7, 8)
+	b = append(b, 0x2a, 1, 9)
+	b = append(b, 0x35, 1, 2, 3, 4)
+	var got Symbol
+	if err := got.UnmarshalBinary(b); err != nil || !reflect.DeepEqual(got, s) {
+		t.Errorf("UnmarshalBinary = %+v, %v; want %+v", got, err, s)
+	}
+	if lt := got.LTBlock(); lt.BlockCode != s.ESI || !bytes.Equal(lt.Data, s.Data) {
+		t.Errorf("LTBlock = %+v", lt)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, b := range [][]byte{
+		{0x0a, 5, 'a'}, // truncated bytes field
+		{0x10},         // truncated varint
+		{0x10, 0x80},   // unterminated varint
+		{0x03, 0},      // group wire type
+		{0x00, 1},      // field number 0
+		{0x21, 1, 2},   // truncated fixed64
+		{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, // ESI beyond int64
+	} {
+		var s Symbol
+		if err := s.UnmarshalBinary(b); err == nil {
+			t.Errorf("UnmarshalBinary(%x) = %+v, want an error", b, s)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repairrpc implements a streaming RPC service which serves the code
+// blocks of objects, for bulk replication between machines which can reach
+// each other reliably but want to fetch an object from several replicas at
+// once, or to resume a transfer without keeping track of what they got.
+//
+// The service, defined in repair.proto, has one server-streaming method:
+// StreamRepair takes an object ID and a starting ESI, and streams code blocks
+// with consecutive ESIs from there. A Server answers it from the encoders of
+// the objects registered with it, and Fetch calls it and feeds the code
+// blocks to a decoder until the object can be decoded. Since any K code
+// blocks or so decode the object, a client fetching from several replicas
+// asks each for a different range of ESIs.
+//
+// The package doesn't depend on gRPC unless built with the fountain_grpc tag.
+// Its messages encode themselves in the protocol buffer wire format with
+// MarshalBinary and UnmarshalBinary, so gRPC carries them with a codec calling
+// those methods, and the streams of the service's handler and client are the
+// small SymbolSender and SymbolReceiver interfaces, whose methods are those of
+// the stream types gRPC generates. With the fountain_grpc tag, the package
+// also has the service's ServiceDesc, along with helpers registering a Server
+// with a gRPC server and calling the service through a gRPC client connection.
+//
+// This package is experimental (see package x).
+package repairrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	fountain "github.com/google/gofountain"
+)
+
+// ErrUnknownObject is returned (wrapped) by Server.StreamRepair when the
+// requested object isn't registered. A gRPC server would map it to the
+// NotFound status.
+var ErrUnknownObject = errors.New("repairrpc: unknown object")
+
+// ErrESIRange is returned (wrapped) by Server.StreamRepair when the requested
+// ESIs go past the last ESI of the object. A gRPC server would map it to the
+// OutOfRange status.
+var ErrESIRange = errors.New("repairrpc: ESIs out of range")
+
+// SymbolSender is the server side of a StreamRepair stream.
+type SymbolSender interface {
+	// Context returns the call's context, which is done when the client
+	// cancels the call.
+	Context() context.Context
+
+	// Send sends a code block to the client.
+	Send(*Symbol) error
+}
+
+// SymbolReceiver is the client side of a StreamRepair stream.
+type SymbolReceiver interface {
+	// Recv returns the next code block, or io.EOF once the server has sent
+	// them all.
+	Recv() (*Symbol, error)
+}
+
+// servedObject is an object registered with a Server.
+type servedObject struct {
+	// mu serializes the calls generating code blocks, as encoders needn't be
+	// safe for concurrent use.
+	mu      sync.Mutex
+	encoder fountain.Encoder
+
+	// lastESI is the largest ESI served.
+	lastESI int64
+}
+
+// generate returns the code block with the given ESI.
+func (o *servedObject) generate(esi int64) fountain.LTBlock {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.encoder.Generate(esi)
+}
+
+// Server serves the code blocks of registered objects. Its methods may be
+// called from any goroutine.
+type Server struct {
+	mu      sync.Mutex
+	objects map[string]*servedObject
+}
+
+// NewServer creates a server with no objects.
+func NewServer() *Server {
+	return &Server{objects: make(map[string]*servedObject)}
+}
+
+// Register makes the code blocks of e with ESIs up to fountain.MaxRaptorESI,
+// the last ESI of the raptor codec, available as object id, replacing any
+// object already registered with that ID.
+func (s *Server) Register(id string, e fountain.Encoder) {
+	s.RegisterRange(id, e, fountain.MaxRaptorESI)
+}
+
+// RegisterRange is like Register, but serves the code blocks with ESIs up to
+// lastESI, for codecs whose ESIs have other bounds. Codecs whose ESIs are
+// unbounded may pass math.MaxInt64.
+func (s *Server) RegisterRange(id string, e fountain.Encoder, lastESI int64) {
+	s.mu.Lock()
+	s.objects[id] = &servedObject{encoder: e, lastESI: lastESI}
+	s.mu.Unlock()
+}
+
+// Unregister removes object id. Calls already streaming it go on. Returns
+// false if there is no such object.
+func (s *Server) Unregister(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.objects[id]
+	delete(s.objects, id)
+	return ok
+}
+
+// StreamRepair is the handler of the StreamRepair method. It sends the code
+// blocks req asks for, and returns nil once it has sent them all, or the
+// call's context's error if the client cancels it first. A request with a
+// count of 0 is sent the code blocks up to the object's last ESI. Returns
+// ErrESIRange (wrapped), without sending anything, if the start ESI or the
+// ESI of the last code block asked for is past it.
+func (s *Server) StreamRepair(req *Request, stream SymbolSender) error {
+	if req.StartESI < 0 {
+		return fmt.Errorf("repairrpc: negative start ESI %d", req.StartESI)
+	}
+	s.mu.Lock()
+	o, ok := s.objects[req.ObjectID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownObject, req.ObjectID)
+	}
+	last := o.lastESI
+	if req.Count != 0 {
+		last = req.StartESI + int64(req.Count) - 1
+	}
+	if req.StartESI > o.lastESI || last > o.lastESI || last < req.StartESI {
+		return fmt.Errorf("%w: object %q has ESIs up to %d, asked for %d from %d",
+			ErrESIRange, req.ObjectID, o.lastESI, req.Count, req.StartESI)
+	}
+	ctx := stream.Context()
+	for esi := req.StartESI; ; esi++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b := o.generate(esi)
+		if err := stream.Send(&Symbol{ESI: b.BlockCode, Data: b.Data}); err != nil {
+			return err
+		}
+		if esi == last {
+			return nil
+		}
+	}
+}
+
+// Fetch calls StreamRepair with req through call, and adds the code blocks it
+// receives to d until d is determined. call opens the stream, and must return
+// one which ends when its context is cancelled: Fetch cancels it once it has
+// what it needs. Returns the number of code blocks received, and an error if
+// the stream ends or fails first.
+func Fetch(ctx context.Context, call func(context.Context, *Request) (SymbolReceiver, error), req *Request, d fountain.Decoder) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := call(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	for n := 0; ; {
+		s, err := stream.Recv()
+		if err == io.EOF {
+			return n, fmt.Errorf("repairrpc: object %q ended after %d code blocks, before it could be decoded", req.ObjectID, n)
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+		if d.AddBlocks([]fountain.LTBlock{s.LTBlock()}) {
+			return n, nil
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repairrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	fountain "github.com/google/gofountain"
+)
+
+// pipeStream carries the symbols of a call from the server's handler to the
+// client, encoding them on the way as gRPC would.
+type pipeStream struct {
+	ctx     context.Context
+	symbols chan []byte
+	done    chan error
+}
+
+func (p *pipeStream) Context() context.Context {
+	return p.ctx
+}
+
+func (p *pipeStream) Send(s *Symbol) error {
+	b, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	select {
+	case p.symbols <- b:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+func (p *pipeStream) Recv() (*Symbol, error) {
+	b, ok := <-p.symbols
+	if !ok {
+		if err := <-p.done; err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var s Symbol
+	return &s, s.UnmarshalBinary(b)
+}
+
+// pipeCall returns a function which calls s's handler in a goroutine, the way
+// a client would call StreamRepair.
+func pipeCall(s *Server) func(context.Context, *Request) (SymbolReceiver, error) {
+	return func(ctx context.Context, req *Request) (SymbolReceiver, error) {
+		b, err := req.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		var r Request
+		if err := r.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		p := &pipeStream{ctx: ctx, symbols: make(chan []byte), done: make(chan error, 1)}
+		go func() {
+			err := s.StreamRepair(&r, p)
+			close(p.symbols)
+			p.done <- err
+		}()
+		return p, nil
+	}
+}
+
+func TestFetch(t *testing.T) {
+	const k, symbolSize = 50, 64
+	message := make([]byte, k*symbolSize)
+	rand.New(rand.NewSource(1)).Read(message)
+	codec := fountain.NewRaptorCodec(k, 4)
+	s := NewServer()
+	s.Register("obj", codec.NewEncoder(append([]byte(nil), message...)))
+
+	// Fetch half the code blocks from one range of ESIs, as if from one
+	// replica, and the rest from another.
+	d := codec.NewDecoder(len(message))
+	n, err := Fetch(context.Background(), pipeCall(s), &Request{ObjectID: "obj", StartESI: 1000, Count: k / 2}, d)
+	if err == nil || n != k/2 {
+		t.Fatalf("Fetch of %d code blocks = %d, %v; want them all and an error", k/2, n, err)
+	}
+	n, err = Fetch(context.Background(), pipeCall(s), &Request{ObjectID: "obj", StartESI: 5000}, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < k/2 || n > k {
+		t.Errorf("second Fetch needed %d code blocks, want about %d", n, k/2)
+	}
+	got, err := fountain.TryDecode(d)
+	if err != nil || !bytes.Equal(got, message) {
+		t.Errorf("decoded %d bytes, %v; want the message", len(got), err)
+	}
+}
+
+func TestStreamRepairUnknownObject(t *testing.T) {
+	s := NewServer()
+	s.Register("a", fountain.NewRaptorCodec(10, 4).NewEncoder(make([]byte, 400)))
+	if !s.Unregister("a") || s.Unregister("a") {
+		t.Error("Unregister didn't report the object once")
+	}
+	_, err := Fetch(context.Background(), pipeCall(s), &Request{ObjectID: "a"},
+		fountain.NewRaptorCodec(10, 4).NewDecoder(400))
+	if !errors.Is(err, ErrUnknownObject) {
+		t.Errorf("Fetch = %v, want ErrUnknownObject", err)
+	}
+}
+
+func TestStreamRepairCancelled(t *testing.T) {
+	s := NewServer()
+	s.Register("a", fountain.NewRaptorCodec(10, 4).NewEncoder(make([]byte, 400)))
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, _ := pipeCall(s)(ctx, &Request{ObjectID: "a"})
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if err != context.Canceled {
+				t.Errorf("Recv after cancelling = %v, want Canceled", err)
+			}
+			break
+		}
+	}
+}
+
+func TestStreamRepairESIRange(t *testing.T) {
+	s := NewServer()
+	s.Register("a", fountain.NewRaptorCodec(10, 4).NewEncoder(make([]byte, 400)))
+	for _, req := range []*Request{
+		{ObjectID: "a", StartESI: 70000},
+		{ObjectID: "a", StartESI: fountain.MaxRaptorESI + 1, Count: 1},
+		{ObjectID: "a", StartESI: fountain.MaxRaptorESI - 1, Count: 3},
+	} {
+		stream, _ := pipeCall(s)(context.Background(), req)
+		if _, err := stream.Recv(); !errors.Is(err, ErrESIRange) {
+			t.Errorf("Recv of %+v = %v, want ErrESIRange", req, err)
+		}
+	}
+
+	// A request without a count ends at the last ESI.
+	stream, _ := pipeCall(s)(context.Background(), &Request{ObjectID: "a", StartESI: fountain.MaxRaptorESI - 2})
+	var esis []int64
+	for {
+		sym, err := stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				t.Errorf("Recv = %v, want EOF", err)
+			}
+			break
+		}
+		esis = append(esis, sym.ESI)
+	}
+	if len(esis) != 3 || esis[2] != fountain.MaxRaptorESI {
+		t.Errorf("streamed ESIs %v, want the last 3", esis)
+	}
+
+	s.RegisterRange("b", fountain.NewBinaryCodec(10).NewEncoder(make([]byte, 400)), math.MaxInt64)
+	stream, _ = pipeCall(s)(context.Background(), &Request{ObjectID: "b", StartESI: math.MaxInt64, Count: 1})
+	if sym, err := stream.Recv(); err != nil || sym.ESI != math.MaxInt64 {
+		t.Errorf("Recv = %+v, %v; want the code block with the last ESI", sym, err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Recv past the last ESI = %v, want EOF", err)
+	}
+}
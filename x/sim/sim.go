@@ -0,0 +1,276 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sim simulates sending fountain-coded messages over lossy channels,
+// to compare how many code blocks codecs need under a given pattern of losses.
+//
+// A Simulation encodes random messages, drops code blocks as a
+// fountain.LossModel decides, and feeds the rest to a decoder until it can
+// decode, checking the decoded message. Its Result is the distribution of the
+// reception overhead: how many code blocks beyond K each message needed.
+// Besides fountain.NewBernoulliLoss, which drops blocks independently, the
+// package has a Gilbert-Elliott model of burst losses, and a model which
+// replays a recorded trace of losses.
+//
+// This package is experimental (see package x).
+package sim
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	fountain "github.com/google/gofountain"
+)
+
+// gilbertElliottLoss is a two-state Markov model of a channel which is either
+// good or bad, losing blocks with a different probability in each state.
+type gilbertElliottLoss struct {
+	// goodToBad and badToGood are the probabilities of changing state after
+	// each block, and lossGood and lossBad the loss probabilities in each.
+	goodToBad, badToGood float64
+	lossGood, lossBad    float64
+
+	bad    bool
+	random *rand.Rand
+}
+
+// NewGilbertElliottLoss returns a fountain.LossModel of a channel which, after
+// each block, goes from its good state to its bad state with probability
+// goodToBad, and back with probability badToGood. It drops each block with
+// probability lossGood in the good state and lossBad in the bad one. The
+// channel starts in the good state. The mean length of a burst is
+// 1/badToGood blocks, and the long-run loss rate is
+// (badToGood*lossGood + goodToBad*lossBad) / (goodToBad + badToGood).
+func NewGilbertElliottLoss(goodToBad, badToGood, lossGood, lossBad float64, random *rand.Rand) fountain.LossModel {
+	return &gilbertElliottLoss{goodToBad: goodToBad, badToGood: badToGood,
+		lossGood: lossGood, lossBad: lossBad, random: random}
+}
+
+// Lost reports whether the next block is dropped.
+func (l *gilbertElliottLoss) Lost() bool {
+	p := l.lossGood
+	if l.bad {
+		p = l.lossBad
+	}
+	lost := l.random.Float64() < p
+	if l.bad {
+		l.bad = l.random.Float64() >= l.badToGood
+	} else {
+		l.bad = l.random.Float64() < l.goodToBad
+	}
+	return lost
+}
+
+// traceLoss replays a recorded trace of losses.
+type traceLoss struct {
+	trace []bool
+	next  int
+}
+
+// NewTraceLoss returns a fountain.LossModel which replays trace, dropping a
+// block wherever the trace has true and wrapping around at its end. If random
+// isn't nil, the replay starts at a random place in the trace, so that
+// different runs see different parts of it.
+func NewTraceLoss(trace []bool, random *rand.Rand) fountain.LossModel {
+	l := &traceLoss{trace: trace}
+	if random != nil && len(trace) > 0 {
+		l.next = random.Intn(len(trace))
+	}
+	return l
+}
+
+// Lost reports whether the next block is dropped.
+func (l *traceLoss) Lost() bool {
+	if len(l.trace) == 0 {
+		return false
+	}
+	lost := l.trace[l.next]
+	l.next = (l.next + 1) % len(l.trace)
+	return lost
+}
+
+// ReadTrace reads a loss trace for NewTraceLoss: a sequence of '0' for a
+// block received and '1' for a block lost. Whitespace is ignored, as are lines
+// starting with '#'.
+func ReadTrace(r io.Reader) ([]bool, error) {
+	var trace []bool
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		for _, c := range text {
+			switch c {
+			case '0':
+				trace = append(trace, false)
+			case '1':
+				trace = append(trace, true)
+			case ' ', '\t':
+			default:
+				return nil, fmt.Errorf("sim: line %d of trace: unexpected %q", line, c)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("sim: reading trace: %w", err)
+	}
+	return trace, nil
+}
+
+// Simulation sends random messages over a simulated channel.
+type Simulation struct {
+	// NewLoss creates the channel model for a run, drawing from random. If
+	// nil, no blocks are lost.
+	NewLoss func(random *rand.Rand) fountain.LossModel
+
+	// Rounds is the number of messages to send.
+	Rounds int
+
+	// MessageLength is the length in bytes of each random message.
+	MessageLength int
+
+	// MaxBlocks is the number of code blocks sent for a message before giving
+	// up on decoding it. If zero, it is 4*K+64.
+	MaxBlocks int
+}
+
+// Result is the outcome of a simulation run.
+type Result struct {
+	// Rounds is the number of messages sent, and Failures the number which
+	// couldn't be decoded from MaxBlocks code blocks.
+	Rounds, Failures int
+
+	// Sent is the total number of code blocks sent, including lost ones, and
+	// Lost the number of them which were lost.
+	Sent, Lost int
+
+	// Overheads holds, in increasing order, the number of code blocks beyond
+	// K each decoded message needed.
+	Overheads []int
+}
+
+// MeanOverhead returns the mean number of code blocks beyond K the decoded
+// messages needed.
+func (r Result) MeanOverhead() float64 {
+	if len(r.Overheads) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, o := range r.Overheads {
+		sum += o
+	}
+	return float64(sum) / float64(len(r.Overheads))
+}
+
+// Percentile returns the smallest overhead at least a fraction p of the
+// decoded messages needed no more than.
+func (r Result) Percentile(p float64) int {
+	if len(r.Overheads) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(r.Overheads)))) - 1
+	return r.Overheads[min(max(i, 0), len(r.Overheads)-1)]
+}
+
+// String summarizes the result.
+func (r Result) String() string {
+	return fmt.Sprintf("%d rounds, %d failed, loss %.3f, overhead mean %.2f, median %d, 99th percentile %d, max %d",
+		r.Rounds, r.Failures, float64(r.Lost)/float64(max(r.Sent, 1)),
+		r.MeanOverhead(), r.Percentile(0.5), r.Percentile(0.99), r.Percentile(1))
+}
+
+// Run sends the simulation's messages with codec c. The message contents and
+// code block IDs are drawn from a random source seeded with seed, and the
+// channel from another, so runs with the same seed are reproducible and
+// different codecs see the same losses. Returns an error if a message decodes
+// incorrectly.
+func (s Simulation) Run(c fountain.Codec, seed int64) (Result, error) {
+	random := rand.New(rand.NewSource(seed))
+	var loss fountain.LossModel
+	if s.NewLoss != nil {
+		loss = s.NewLoss(rand.New(rand.NewSource(seed + 1)))
+	}
+	k := c.SourceBlocks()
+	maxBlocks := s.MaxBlocks
+	if maxBlocks == 0 {
+		maxBlocks = 4*k + 64
+	}
+
+	var result Result
+	for round := 0; round < s.Rounds; round++ {
+		result.Rounds++
+		message := make([]byte, s.MessageLength)
+		random.Read(message)
+
+		// Consecutive IDs from a random start stay distinct, modulo the raptor
+		// codec's ESIs of distinct code blocks.
+		const esis = fountain.MaxDistinctRaptorESI + 1
+		start := int64(random.Intn(esis))
+		d := c.NewDecoder(len(message))
+		received, sent := 0, 0
+		determined := false
+		for !determined && sent < maxBlocks {
+			ids := make([]int64, min(max(k, 1), maxBlocks-sent))
+			for i := range ids {
+				ids[i] = (start + int64(sent+i)) % esis
+			}
+			for _, b := range fountain.EncodeLTBlocksCopy(message, ids, c) {
+				sent++
+				if loss != nil && loss.Lost() {
+					result.Lost++
+					continue
+				}
+				received++
+				if d.AddBlocks([]fountain.LTBlock{b}) {
+					determined = true
+					break
+				}
+			}
+		}
+		result.Sent += sent
+
+		if !determined {
+			result.Failures++
+			continue
+		}
+		if !bytes.Equal(d.Decode(), message) {
+			return result, fmt.Errorf("sim: round %d: decoded message differs from the original", round)
+		}
+		result.Overheads = append(result.Overheads, received-k)
+	}
+	sort.Ints(result.Overheads)
+	return result, nil
+}
+
+// Compare runs the simulation with each of the named codecs, with the same
+// seed so that they all see the same losses, and returns their results by
+// name.
+func (s Simulation) Compare(codecs map[string]fountain.Codec, seed int64) (map[string]Result, error) {
+	results := make(map[string]Result, len(codecs))
+	for name, c := range codecs {
+		r, err := s.Run(c, seed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		results[name] = r
+	}
+	return results, nil
+}
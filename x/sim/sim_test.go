@@ -0,0 +1,106 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	fountain "github.com/google/gofountain"
+)
+
+func TestGilbertElliottLoss(t *testing.T) {
+	l := NewGilbertElliottLoss(0.01, 0.1, 0, 1, rand.New(rand.NewSource(1)))
+	lost, bursts, prev := 0, 0, false
+	const n = 200000
+	for i := 0; i < n; i++ {
+		b := l.Lost()
+		if b {
+			lost++
+			if !prev {
+				bursts++
+			}
+		}
+		prev = b
+	}
+	// The long-run loss rate is 0.01/0.11, and bursts average 10 blocks.
+	if rate := float64(lost) / n; math.Abs(rate-0.01/0.11) > 0.01 {
+		t.Errorf("Loss rate = %.4f, want about %.4f", rate, 0.01/0.11)
+	}
+	if mean := float64(lost) / float64(bursts); math.Abs(mean-10) > 1 {
+		t.Errorf("Mean burst length = %.2f, want about 10", mean)
+	}
+}
+
+func TestTraceLoss(t *testing.T) {
+	trace, err := ReadTrace(strings.NewReader("# a trace\n0 1 1\n0\n"))
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	if want := []bool{false, true, true, false}; !reflect.DeepEqual(trace, want) {
+		t.Fatalf("ReadTrace = %v, want %v", trace, want)
+	}
+	l := NewTraceLoss(trace, nil)
+	var got []bool
+	for i := 0; i < 6; i++ {
+		got = append(got, l.Lost())
+	}
+	if want := []bool{false, true, true, false, false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Replayed %v, want %v", got, want)
+	}
+
+	if _, err := ReadTrace(strings.NewReader("01x")); err == nil {
+		t.Errorf("ReadTrace accepted a bad trace")
+	}
+}
+
+func TestSimulation(t *testing.T) {
+	s := Simulation{
+		NewLoss: func(random *rand.Rand) fountain.LossModel {
+			return NewGilbertElliottLoss(0.05, 0.3, 0.01, 0.5, random)
+		},
+		Rounds:        20,
+		MessageLength: 4000,
+	}
+	results, err := s.Compare(map[string]fountain.Codec{
+		"raptor": fountain.NewRaptorCodec(100, 4),
+		"online": fountain.NewOnlineCodec(100, 0.2, 7, 1),
+	}, 5)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	for name, r := range results {
+		if r.Rounds != 20 || r.Failures != 0 || len(r.Overheads) != 20 {
+			t.Errorf("%s: %v, want 20 rounds decoded", name, r)
+		}
+		if r.Lost == 0 || r.Sent <= 20*100 {
+			t.Errorf("%s: %v, want some losses", name, r)
+		}
+		if r.Percentile(0) < 0 || r.Percentile(0.5) > r.Percentile(1) {
+			t.Errorf("%s: percentiles out of order: %v", name, r)
+		}
+	}
+	if results["raptor"].MeanOverhead() > 5 {
+		t.Errorf("raptor: %v, want mean overhead at most 5", results["raptor"])
+	}
+
+	again, err := s.Run(fountain.NewRaptorCodec(100, 4), 5)
+	if err != nil || !reflect.DeepEqual(again, results["raptor"]) {
+		t.Errorf("Rerun = %v, %v; want %v", again, err, results["raptor"])
+	}
+}
@@ -0,0 +1,481 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package slidingwindow is a sliding-window random linear code over GF(2), for
+// streams which never end, such as live video or telemetry.
+//
+// The fountain codecs encode a whole message, so a receiver can't decode any
+// of it until enough code blocks for all of it have arrived. Here, instead,
+// each repair symbol is the XOR of a random subset of the most recent source
+// symbols: the encoder's window. A receiver recovers a lost source symbol from
+// the repair symbols sent while it was in the window, and gives it up as lost
+// once it has left the window of every symbol received since, so the latency
+// of decoding is bounded by the window, whatever the losses.
+//
+// Source symbols are sent as they are (the code is systematic), and repair
+// symbols are interleaved with them at the encoder's code rate. A window of w
+// symbols with code rate r recovers bursts of up to about w*(1-r) losses.
+// Random GF(2) combinations are a little less efficient than those over larger
+// fields: recovering n lost symbols takes on average about 1.6 repair symbols
+// more than n.
+//
+// This package is experimental (see package x).
+package slidingwindow
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	fountain "github.com/google/gofountain"
+)
+
+// ErrSymbolSize is returned for source data larger than the symbol size, and
+// for received symbols of the wrong size.
+var ErrSymbolSize = errors.New("slidingwindow: wrong symbol size")
+
+// Symbol is a source or repair symbol of a stream.
+type Symbol struct {
+	// Seq is the sequence number of a source symbol, or that of the first
+	// source symbol in the window of a repair symbol.
+	Seq uint64
+
+	// Count is the number of source symbols in the window of a repair symbol,
+	// and zero for a source symbol.
+	Count int
+
+	// Key seeds the choice of the source symbols a repair symbol combines.
+	Key uint32
+
+	// Data is the symbol's data.
+	Data []byte
+}
+
+// Repair reports whether s is a repair symbol.
+func (s Symbol) Repair() bool {
+	return s.Count > 0
+}
+
+// symbolVersion is the wire format version of a symbol.
+const symbolVersion = 1
+
+// AppendBinary appends the wire encoding of the symbol to b: a
+// fountain.WireHeader, then the sequence number and count as uvarints, then
+// for a repair symbol the key as 4 bytes, then the length of the data as a
+// uvarint, and the data. Implements encoding.BinaryAppender.
+func (s Symbol) AppendBinary(b []byte) ([]byte, error) {
+	b = fountain.WireHeader{Version: symbolVersion}.AppendTo(b)
+	b = binary.AppendUvarint(b, s.Seq)
+	b = binary.AppendUvarint(b, uint64(s.Count))
+	if s.Repair() {
+		b = fountain.ByteOrder.AppendUint32(b, s.Key)
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.Data)))
+	return append(b, s.Data...), nil
+}
+
+// ParseSymbol decodes the symbol at the start of b, written by AppendBinary,
+// and returns it along with the remainder of b. The symbol's data aliases b.
+func ParseSymbol(b []byte) (Symbol, []byte, error) {
+	h, b, err := fountain.ParseWireHeader(b, symbolVersion)
+	if err != nil {
+		return Symbol{}, nil, err
+	}
+	var s Symbol
+	seq, n := binary.Uvarint(b)
+	if n <= 0 {
+		return Symbol{}, nil, fountain.ErrShortWireData
+	}
+	b = b[n:]
+	count, n := binary.Uvarint(b)
+	if n <= 0 || count > uint64(maxWindow) {
+		return Symbol{}, nil, fountain.ErrShortWireData
+	}
+	b = b[n:]
+	s.Seq, s.Count = seq, int(count)
+	if s.Repair() {
+		if len(b) < 4 {
+			return Symbol{}, nil, fountain.ErrShortWireData
+		}
+		s.Key = h.ByteOrder().Uint32(b)
+		b = b[4:]
+	}
+	length, n := binary.Uvarint(b)
+	if n <= 0 || length > uint64(len(b)-n) {
+		return Symbol{}, nil, fountain.ErrShortWireData
+	}
+	b = b[n:]
+	s.Data = b[:length:length]
+	return s, b[length:], nil
+}
+
+// maxWindow is the largest window size.
+const maxWindow = 1 << 16
+
+// combination returns the offsets, in the window of count source symbols
+// starting at first, of the source symbols the repair symbol with the given
+// key combines. Each is picked with probability one half, and if none is, the
+// last is.
+func combination(first uint64, count int, key uint32) []int {
+	t := &fountain.MersenneTwister64{}
+	t.SeedSlice([]uint64{first, uint64(key)})
+	var offsets []int
+	for i := 0; i < count; i += 64 {
+		w := t.Uint64()
+		for j := 0; j < 64 && i+j < count; j++ {
+			if w&(1<<j) != 0 {
+				offsets = append(offsets, i+j)
+			}
+		}
+	}
+	if len(offsets) == 0 {
+		offsets = append(offsets, count-1)
+	}
+	return offsets
+}
+
+// Encoder encodes a stream of source symbols.
+type Encoder struct {
+	window, symbolSize int
+
+	// repairs is the number of repair symbols per source symbol, (1-r)/r for
+	// code rate r, and owed the repair symbols due but not yet sent.
+	repairs, owed float64
+
+	// recent holds the source symbols in the window, source symbol n at
+	// recent[n%window].
+	recent [][]byte
+
+	// next is the sequence number of the next source symbol, and key that of
+	// the next repair symbol.
+	next uint64
+	key  uint32
+}
+
+// NewEncoder creates an encoder of source symbols of symbolSize bytes, whose
+// repair symbols combine the last window source symbols. The code rate is the
+// fraction of the symbols sent which are source symbols: at rate 0.8, Add
+// sends a repair symbol after every four source symbols. At rate 1, Add sends
+// none; they can still be made by Repair.
+func NewEncoder(window, symbolSize int, rate float64) (*Encoder, error) {
+	if window < 1 || window > maxWindow || symbolSize < 1 || !(rate > 0 && rate <= 1) {
+		return nil, fmt.Errorf("slidingwindow: invalid encoder window %d, symbol size %d or rate %v",
+			window, symbolSize, rate)
+	}
+	return &Encoder{
+		window:     window,
+		symbolSize: symbolSize,
+		repairs:    (1 - rate) / rate,
+		recent:     make([][]byte, window),
+	}, nil
+}
+
+// Add appends a source symbol to the stream, zero-padded to the symbol size,
+// and returns the symbols to send: the source symbol itself, then the repair
+// symbols due at the code rate. Returns ErrSymbolSize if data is larger than
+// the symbol size.
+func (e *Encoder) Add(data []byte) ([]Symbol, error) {
+	if len(data) > e.symbolSize {
+		return nil, fmt.Errorf("%w: %d bytes of source data, symbols are %d", ErrSymbolSize, len(data), e.symbolSize)
+	}
+	source := make([]byte, e.symbolSize)
+	copy(source, data)
+	e.recent[e.next%uint64(e.window)] = source
+	symbols := []Symbol{{Seq: e.next, Data: source}}
+	e.next++
+
+	for e.owed += e.repairs; e.owed >= 1; e.owed-- {
+		symbols = append(symbols, e.Repair())
+	}
+	return symbols, nil
+}
+
+// Repair returns a new repair symbol combining source symbols of the current
+// window, for instance to send in response to reported losses. It panics if
+// no source symbol has been added.
+func (e *Encoder) Repair() Symbol {
+	if e.next == 0 {
+		panic("slidingwindow: Repair of an empty stream")
+	}
+	count := int(min(e.next, uint64(e.window)))
+	first := e.next - uint64(count)
+	s := Symbol{Seq: first, Count: count, Key: e.key, Data: make([]byte, e.symbolSize)}
+	e.key++
+	for _, i := range combination(first, count, s.Key) {
+		subtle.XORBytes(s.Data, s.Data, e.recent[(first+uint64(i))%uint64(e.window)])
+	}
+	return s
+}
+
+// Source is a source symbol delivered by a Decoder, or a run of consecutive
+// ones which were lost.
+type Source struct {
+	// Seq is the sequence number of the source symbol, or of the first of the
+	// run of lost ones.
+	Seq uint64
+
+	// Data is the source symbol's data, or nil if it was lost.
+	Data []byte
+
+	// Lost is the number of source symbols lost, if Data is nil.
+	Lost uint64
+}
+
+// DecoderStats counts the source symbols a Decoder has delivered.
+type DecoderStats struct {
+	// Received counts the source symbols received, Recovered those recovered
+	// from repair symbols and Lost those given up as lost.
+	Received, Recovered, Lost uint64
+}
+
+// equation states that the XOR of the unknown source symbols, with sorted
+// sequence numbers, is data.
+type equation struct {
+	unknowns []uint64
+	data     []byte
+}
+
+// Decoder decodes a stream of symbols from an Encoder with the same window
+// and symbol size, and delivers the source symbols in order.
+type Decoder struct {
+	window, symbolSize int
+
+	// next is the sequence number of the next source symbol to deliver, and
+	// newest the largest sequence number of a source symbol known to have been
+	// sent (if any symbol has been received).
+	next, newest uint64
+	started      bool
+
+	// known holds the data of the source symbols received or recovered, from
+	// a window before next onwards, and rows the equations of the repair
+	// symbols still in use, by their first unknown.
+	known map[uint64][]byte
+	rows  map[uint64]*equation
+
+	stats DecoderStats
+}
+
+// NewDecoder creates a decoder of a stream of symbols of symbolSize bytes
+// from an Encoder whose repair symbols combine the last window source
+// symbols.
+func NewDecoder(window, symbolSize int) (*Decoder, error) {
+	if window < 1 || window > maxWindow || symbolSize < 1 {
+		return nil, fmt.Errorf("slidingwindow: invalid decoder window %d or symbol size %d", window, symbolSize)
+	}
+	return &Decoder{
+		window:     window,
+		symbolSize: symbolSize,
+		known:      make(map[uint64][]byte),
+		rows:       make(map[uint64]*equation),
+	}, nil
+}
+
+// Stats returns the decoder's counts of source symbols.
+func (d *Decoder) Stats() DecoderStats {
+	return d.stats
+}
+
+// Add adds a received symbol, and returns the source symbols which can be
+// delivered since: those received or recovered which follow the ones already
+// delivered, in order, along with runs of lost ones which can no longer be
+// recovered because they have left the window of the newest symbol. Symbols
+// too old to help are ignored. The data of the symbol is copied. Returns
+// ErrSymbolSize if the symbol's data isn't the symbol size, and an error if a
+// repair symbol's window is larger than the decoder's.
+func (d *Decoder) Add(s Symbol) ([]Source, error) {
+	if len(s.Data) != d.symbolSize {
+		return nil, fmt.Errorf("%w: %d-byte symbol, want %d", ErrSymbolSize, len(s.Data), d.symbolSize)
+	}
+	if s.Count < 0 || s.Count > d.window {
+		return nil, fmt.Errorf("slidingwindow: repair symbol window %d is larger than %d", s.Count, d.window)
+	}
+	last := s.Seq
+	if s.Repair() {
+		last += uint64(s.Count) - 1
+	}
+	if !d.started || last > d.newest {
+		d.newest, d.started = last, true
+	}
+
+	if !s.Repair() {
+		if _, ok := d.known[s.Seq]; !ok && s.Seq >= d.next {
+			d.stats.Received++
+			d.learn(s.Seq, slices.Clone(s.Data))
+		}
+	} else if e, ok := d.equation(s); ok {
+		d.insert(e)
+	}
+	d.solve()
+	return d.deliver(), nil
+}
+
+// equation returns the equation of a repair symbol over the source symbols
+// still unknown. Returns false if it names a source symbol which is neither
+// known nor can still be recovered.
+func (d *Decoder) equation(s Symbol) (*equation, bool) {
+	e := &equation{data: slices.Clone(s.Data)}
+	for _, i := range combination(s.Seq, s.Count, s.Key) {
+		seq := s.Seq + uint64(i)
+		if data, ok := d.known[seq]; ok {
+			subtle.XORBytes(e.data, e.data, data)
+		} else if seq < d.next {
+			return nil, false
+		} else {
+			e.unknowns = append(e.unknowns, seq)
+		}
+	}
+	return e, len(e.unknowns) > 0
+}
+
+// insert adds an equation to the rows, reducing it by the row of its first
+// unknown until that row is empty, like the fountain decoders do. This keeps
+// the first unknowns of the rows distinct.
+func (d *Decoder) insert(e *equation) {
+	for len(e.unknowns) > 0 {
+		r, ok := d.rows[e.unknowns[0]]
+		if !ok {
+			d.rows[e.unknowns[0]] = e
+			return
+		}
+		if len(e.unknowns) < len(r.unknowns) {
+			d.rows[e.unknowns[0]], e, r = e, r, e
+		}
+		e.unknowns = symmetricDifference(e.unknowns, r.unknowns)
+		subtle.XORBytes(e.data, e.data, r.data)
+	}
+}
+
+// symmetricDifference returns the sequence numbers in exactly one of the
+// sorted slices a and b.
+func symmetricDifference(a, b []uint64) []uint64 {
+	var out []uint64
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		default:
+			out = append(out, b[j])
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// learn records the data of source symbol seq, and eliminates it from the
+// rows.
+func (d *Decoder) learn(seq uint64, data []byte) {
+	d.known[seq] = data
+	lead, isLead := d.rows[seq]
+	delete(d.rows, seq)
+	for _, r := range d.rows {
+		if i, ok := slices.BinarySearch(r.unknowns, seq); ok {
+			r.unknowns = slices.Delete(r.unknowns, i, i+1)
+			subtle.XORBytes(r.data, r.data, data)
+		}
+	}
+	if isLead {
+		lead.unknowns = lead.unknowns[1:]
+		subtle.XORBytes(lead.data, lead.data, data)
+		d.insert(lead)
+	}
+}
+
+// solve recovers the source symbols the rows determine. Since each row's
+// first unknown is the first of no other row, a row determines its first
+// unknown if the rows after it determine all its others; so working back from
+// the last row settles them all.
+func (d *Decoder) solve() {
+	leads := make([]uint64, 0, len(d.rows))
+	for seq := range d.rows {
+		leads = append(leads, seq)
+	}
+	slices.Sort(leads)
+	solved := make(map[uint64][]byte)
+	for i := len(leads) - 1; i >= 0; i-- {
+		r := d.rows[leads[i]]
+		ok := true
+		for _, seq := range r.unknowns[1:] {
+			if _, ok = solved[seq]; !ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		for _, seq := range r.unknowns[1:] {
+			subtle.XORBytes(r.data, r.data, solved[seq])
+		}
+		solved[leads[i]] = r.data
+	}
+	if len(solved) == 0 {
+		return
+	}
+	for seq := range solved {
+		delete(d.rows, seq)
+	}
+	for seq, data := range solved {
+		d.stats.Recovered++
+		d.learn(seq, data)
+	}
+}
+
+// deliver returns the source symbols from next onwards which are known or
+// lost, in order, and forgets those too old to help decode.
+func (d *Decoder) deliver() []Source {
+	var out []Source
+	for d.started {
+		if data, ok := d.known[d.next]; ok {
+			out = append(out, Source{Seq: d.next, Data: data})
+			d.next++
+			continue
+		}
+		// Every symbol sent after the newest one received has a window starting
+		// after expired, so the source symbols before it which are unknown now
+		// will stay so.
+		expired := d.newest + 1 - min(d.newest+1, uint64(d.window))
+		if d.next >= expired {
+			break
+		}
+		end := expired
+		for seq := range d.known {
+			if seq > d.next && seq < end {
+				end = seq
+			}
+		}
+		for seq := range d.rows {
+			if seq > d.next && seq < end {
+				end = seq
+			}
+		}
+		delete(d.rows, d.next)
+		out = append(out, Source{Seq: d.next, Lost: end - d.next})
+		d.stats.Lost += end - d.next
+		d.next = end
+	}
+
+	for seq := range d.known {
+		if seq+uint64(d.window) < d.next {
+			delete(d.known, seq)
+		}
+	}
+	return out
+}
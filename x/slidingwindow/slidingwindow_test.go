@@ -0,0 +1,227 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slidingwindow
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// stream sends n source symbols through an encoder and a decoder, dropping the
+// symbols for which drop returns true, and checks that the decoder delivers
+// every source symbol in order, each with the right data unless lost. Returns
+// the decoder's stats.
+func stream(t *testing.T, window int, rate float64, n int, drop func(s Symbol, i int) bool) DecoderStats {
+	t.Helper()
+	const size = 24
+	e, err := NewEncoder(window, size, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(window, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(7))
+	var sent [][]byte
+	var next uint64
+	count := 0
+	for i := 0; i < n; i++ {
+		data := make([]byte, size)
+		random.Read(data)
+		sent = append(sent, data)
+		symbols, err := e.Add(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range symbols {
+			count++
+			if drop(s, count) {
+				continue
+			}
+			delivered, err := d.Add(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, src := range delivered {
+				if src.Seq != next {
+					t.Fatalf("delivered source %d, want %d", src.Seq, next)
+				}
+				if src.Data == nil {
+					if src.Lost == 0 {
+						t.Fatalf("empty run of lost sources at %d", src.Seq)
+					}
+					next += src.Lost
+					continue
+				}
+				if !bytes.Equal(src.Data, sent[src.Seq]) {
+					t.Fatalf("source %d = %x, want %x", src.Seq, src.Data, sent[src.Seq])
+				}
+				next++
+			}
+		}
+	}
+	// Everything that has left the window must have been delivered.
+	if want := uint64(n - window); next < want {
+		t.Errorf("delivered %d sources of %d, want at least %d", next, n, want)
+	}
+	stats := d.Stats()
+	if got := stats.Received + stats.Recovered + stats.Lost; got < next {
+		t.Errorf("stats %+v count %d sources, want at least %d", stats, got, next)
+	}
+	return stats
+}
+
+func TestLossless(t *testing.T) {
+	stats := stream(t, 8, 0.75, 200, func(Symbol, int) bool { return false })
+	if stats.Received != 200 || stats.Recovered != 0 || stats.Lost != 0 {
+		t.Errorf("Stats() = %+v, want all 200 received", stats)
+	}
+}
+
+func TestRandomLoss(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	stats := stream(t, 16, 0.7, 2000, func(Symbol, int) bool { return random.Float64() < 0.1 })
+	if stats.Recovered == 0 {
+		t.Errorf("Stats() = %+v, want some recovered", stats)
+	}
+	// At rate 0.7 there are three repair symbols for every seven source
+	// symbols, far more than the 10% lost, so nearly every loss is recovered.
+	if stats.Lost > stats.Recovered/20 {
+		t.Errorf("Stats() = %+v, want few lost", stats)
+	}
+}
+
+func TestBurstLoss(t *testing.T) {
+	// A burst of 40 source symbols, with their repair symbols, is far more
+	// than a window of 8 can recover, but the symbols after it are delivered.
+	stats := stream(t, 8, 0.8, 300, func(s Symbol, _ int) bool {
+		last := s.Seq + uint64(max(s.Count, 1)) - 1
+		return last >= 100 && last < 140
+	})
+	if stats.Lost < 30 || stats.Lost > 40 {
+		t.Errorf("Stats() = %+v, want 30 to 40 lost", stats)
+	}
+	if stats.Received < 250 {
+		t.Errorf("Stats() = %+v, want the symbols after the burst received", stats)
+	}
+}
+
+func TestShortBurstRecovered(t *testing.T) {
+	// A window of 32 at rate 0.75 sends about 10 repair symbols after a burst
+	// of 4 lost source symbols, before they leave the window.
+	stats := stream(t, 32, 0.75, 300, func(s Symbol, _ int) bool {
+		return !s.Repair() && s.Seq >= 100 && s.Seq < 104
+	})
+	if stats.Recovered != 4 || stats.Lost != 0 {
+		t.Errorf("Stats() = %+v, want the 4 lost recovered", stats)
+	}
+}
+
+func TestRepairOnDemand(t *testing.T) {
+	e, err := NewEncoder(4, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lost []byte
+	for i := 0; i < 4; i++ {
+		symbols, err := e.Add([]byte{byte(i + 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(symbols) != 1 {
+			t.Fatalf("Add at rate 1 returned %d symbols, want 1", len(symbols))
+		}
+		if i == 2 {
+			lost = symbols[0].Data
+			continue
+		}
+		d.Add(symbols[0])
+	}
+	// Each repair symbol combines the lost one with probability one half.
+	for i := 0; i < 64; i++ {
+		delivered, err := d.Add(e.Repair())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(delivered) > 0 {
+			if delivered[0].Seq != 2 || !bytes.Equal(delivered[0].Data, lost) {
+				t.Fatalf("Add delivered %+v, want source 2 = %x", delivered[0], lost)
+			}
+			return
+		}
+	}
+	t.Error("source 2 not recovered from 64 repair symbols")
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := NewEncoder(0, 8, 0.5); err == nil {
+		t.Error("NewEncoder with window 0 succeeded")
+	}
+	if _, err := NewEncoder(8, 8, 0); err == nil {
+		t.Error("NewEncoder with rate 0 succeeded")
+	}
+	if _, err := NewDecoder(8, 0); err == nil {
+		t.Error("NewDecoder with symbol size 0 succeeded")
+	}
+	e, _ := NewEncoder(8, 4, 0.5)
+	if _, err := e.Add(make([]byte, 5)); !errors.Is(err, ErrSymbolSize) {
+		t.Errorf("Add of 5 bytes: err = %v, want ErrSymbolSize", err)
+	}
+	d, _ := NewDecoder(4, 4)
+	if _, err := d.Add(Symbol{Data: make([]byte, 3)}); !errors.Is(err, ErrSymbolSize) {
+		t.Errorf("Add of a 3-byte symbol: err = %v, want ErrSymbolSize", err)
+	}
+	if _, err := d.Add(Symbol{Count: 8, Data: make([]byte, 4)}); err == nil {
+		t.Error("Add of a repair symbol with a larger window succeeded")
+	}
+}
+
+func TestSymbolWireRoundTrip(t *testing.T) {
+	symbols := []Symbol{
+		{Seq: 5, Data: []byte("hello")},
+		{Seq: 1 << 40, Count: 300, Key: 0xdeadbeef, Data: []byte("repair")},
+	}
+	var b []byte
+	for _, s := range symbols {
+		b, _ = s.AppendBinary(b)
+	}
+	for i, want := range symbols {
+		var got Symbol
+		var err error
+		got, b, err = ParseSymbol(b)
+		if err != nil {
+			t.Fatalf("ParseSymbol %d: %v", i, err)
+		}
+		if got.Seq != want.Seq || got.Count != want.Count || got.Key != want.Key || !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("ParseSymbol %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if len(b) != 0 {
+		t.Errorf("%d bytes left over", len(b))
+	}
+	full, _ := symbols[1].AppendBinary(nil)
+	for n := range full {
+		if _, _, err := ParseSymbol(full[:n]); err == nil {
+			t.Errorf("ParseSymbol of %d of %d bytes succeeded", n, len(full))
+		}
+	}
+}
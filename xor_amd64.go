@@ -0,0 +1,96 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !purego && !race
+
+package fountain
+
+// useAVX2 is set at init if the CPU and operating system support AVX2.
+// Otherwise the SSE2 kernel, which every amd64 CPU has, is used.
+var useAVX2 = hasAVX2()
+
+// xorSSE2 XORs n bytes of src into dst 16 bytes at a time. n must be a
+// multiple of 16.
+//
+//go:noescape
+func xorSSE2(dst, src *byte, n int)
+
+// xorAVX2 XORs n bytes of src into dst 32 bytes at a time. n must be a
+// multiple of 32.
+//
+//go:noescape
+func xorAVX2(dst, src *byte, n int)
+
+// cpuid executes the CPUID instruction for the given leaf and subleaf.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+// xgetbv reads extended control register 0.
+func xgetbv() (eax, edx uint32)
+
+// hasAVX2 reports whether the CPU supports AVX2, and the operating system
+// saves the YMM registers on context switches.
+func hasAVX2() bool {
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const osxsave = 1 << 27
+	const avx = 1 << 28
+	if ecx1&osxsave == 0 || ecx1&avx == 0 {
+		return false
+	}
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	const avx2 = 1 << 5
+	return ebx7&avx2 != 0
+}
+
+// xorKernel names the XOR kernel xorBytes uses.
+func xorKernel() string {
+	if useAVX2 {
+		return "avx2"
+	}
+	return "sse2"
+}
+
+// xorBytes XORs src into dst, which must be at least as long. The bulk of the
+// slices is XORed with SSE2 or AVX2 instructions, and any tail with the
+// generic version.
+func xorBytes(dst, src []byte) {
+	n := len(src)
+	if n == 0 {
+		return
+	}
+	// Panic like the generic version if dst is too short, rather than let the
+	// kernel write past it.
+	_ = dst[n-1]
+	done := 0
+	if useAVX2 {
+		done = n &^ 31
+		if done > 0 {
+			xorAVX2(&dst[0], &src[0], done)
+		}
+	} else {
+		done = n &^ 15
+		if done > 0 {
+			xorSSE2(&dst[0], &src[0], done)
+		}
+	}
+	if done < n {
+		xorBytesGeneric(dst[done:n], src[done:])
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !purego && !race
+
+package fountain
+
+import (
+	"testing"
+)
+
+// TestXorBytesSSE2 checks the SSE2 kernel, which is only used on CPUs without
+// AVX2.
+func TestXorBytesSSE2(t *testing.T) {
+	t.Logf("AVX2 supported: %v", useAVX2)
+	defer func(avx2 bool) { useAVX2 = avx2 }(useAVX2)
+	useAVX2 = false
+	testXorBytes(t)
+}
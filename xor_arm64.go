@@ -0,0 +1,48 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !purego && !race
+
+package fountain
+
+// xorNEON XORs n bytes of src into dst 64 bytes at a time. n must be a
+// multiple of 64. NEON is part of the arm64 baseline, so needs no detection.
+//
+//go:noescape
+func xorNEON(dst, src *byte, n int)
+
+// xorKernel names the XOR kernel xorBytes uses.
+func xorKernel() string {
+	return "neon"
+}
+
+// xorBytes XORs src into dst, which must be at least as long. The bulk of the
+// slices is XORed with NEON instructions, and any tail with the generic
+// version.
+func xorBytes(dst, src []byte) {
+	n := len(src)
+	if n == 0 {
+		return
+	}
+	// Panic like the generic version if dst is too short, rather than let the
+	// kernel write past it.
+	_ = dst[n-1]
+	done := n &^ 63
+	if done > 0 {
+		xorNEON(&dst[0], &src[0], done)
+	}
+	if done < n {
+		xorBytesGeneric(dst[done:n], src[done:])
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (!amd64 && !arm64) || purego || race
+
+package fountain
+
+// xorBytes XORs src into dst, which must be at least as long. Architectures
+// without assembly kernels, and purego and race builds, use the generic
+// version.
+func xorBytes(dst, src []byte) {
+	xorBytesGeneric(dst, src)
+}
+
+// xorKernel names the XOR kernel xorBytes uses.
+func xorKernel() string {
+	return "generic"
+}
@@ -0,0 +1,28 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !fountain_unsafe || !purego || !amd64 || race
+
+package fountain
+
+// xorBytesGeneric XORs src into dst, which must be at least as long. This is
+// the portable byte-wise version; building with the fountain_unsafe and
+// purego tags on amd64 substitutes one which works a 64-bit word at a time.
+// On amd64 and arm64 it only handles the tails the assembly kernels leave,
+// unless they are disabled by the purego tag.
+func xorBytesGeneric(dst, src []byte) {
+	for i := 0; i < len(src); i++ {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestXorBytes checks xorBytes (in whichever version the build selected)
+// against a byte-wise XOR for unaligned slices of many lengths.
+func TestXorBytes(t *testing.T) {
+	testXorBytes(t)
+}
+
+func testXorBytes(t *testing.T) {
+	random := rand.New(rand.NewSource(77))
+	for n := 0; n < 200; n++ {
+		for offset := 0; offset < 8; offset++ {
+			src := make([]byte, n+offset)
+			dst := make([]byte, n+offset+3)
+			random.Read(src)
+			random.Read(dst)
+
+			want := make([]byte, len(dst))
+			copy(want, dst)
+			for i := 0; i < n; i++ {
+				want[offset+i] ^= src[offset+i]
+			}
+
+			xorBytes(dst[offset:], src[offset:])
+			if !reflect.DeepEqual(dst, want) {
+				t.Fatalf("xorBytes of %d bytes at offset %d got %v, want %v", n, offset, dst, want)
+			}
+		}
+	}
+}
+
+func TestXorBytesShortDst(t *testing.T) {
+	for _, n := range []int{7, 64, 100} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("xorBytes of %d bytes into %d didn't panic", n, n-1)
+				}
+			}()
+			xorBytes(make([]byte, n-1, n+64), make([]byte, n))
+		}()
+	}
+}
+
+func BenchmarkXorBytes(b *testing.B) {
+	src := make([]byte, 64*1024+1)
+	dst := make([]byte, len(src))
+	b.SetBytes(int64(len(src) - 1))
+	for i := 0; i < b.N; i++ {
+		xorBytes(dst[1:], src[1:])
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fountain_unsafe && purego && amd64 && !race
+
+package fountain
+
+import (
+	"unsafe"
+)
+
+// xorBytesGeneric XORs src into dst, which must be at least as long. It reads and
+// writes 64-bit words through unsafe pointer casts, regardless of the
+// alignment of the slices, which amd64 permits at little or no cost. Any tail
+// shorter than a word is XORed byte-wise.
+// The casts trip the race detector's pointer alignment checks, so race builds
+// use the portable version instead. Without the purego tag, the assembly
+// kernels leave xorBytesGeneric only tails of a few words, so the
+// fountain_unsafe tag takes effect only together with purego.
+func xorBytesGeneric(dst, src []byte) {
+	n := len(src)
+	if n == 0 {
+		return
+	}
+	// Panic like the portable version if dst is too short, rather than write
+	// past it.
+	_ = dst[n-1]
+	words := n / 8
+	if words > 0 {
+		d := unsafe.Pointer(&dst[0])
+		s := unsafe.Pointer(&src[0])
+		for i := 0; i < words; i++ {
+			*(*uint64)(unsafe.Add(d, i*8)) ^= *(*uint64)(unsafe.Add(s, i*8))
+		}
+	}
+	for i := words * 8; i < n; i++ {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"fmt"
+)
+
+// Sparse objects, such as disk images, often have many source blocks which
+// are entirely zero. An encoder can skip XORing such blocks altogether, and a
+// sender can list them in the object's metadata so that receivers fill them
+// in directly and need correspondingly fewer code blocks.
+
+// compactZeroBlocks replaces each all-zero block with an all-padding block of
+// the same length, which XORs into other blocks at no cost. It returns the
+// number of blocks replaced.
+func compactZeroBlocks(blocks []block) int {
+	n := 0
+	for i := range blocks {
+		if len(blocks[i].data) > 0 && blocks[i].zero() {
+			blocks[i] = block{padding: blocks[i].length()}
+			n++
+		}
+	}
+	return n
+}
+
+// ZeroSymbolBitmap splits a message into source blocks the way the package's
+// codecs do, and returns a bitmap of the blocks which are entirely zero: bit
+// i%8 of byte i/8 is set if block i is. It is meant to be sent with the
+// object's metadata, for receivers to pass to PrefillZeroSymbols.
+func ZeroSymbolBitmap(message []byte, sourceBlocks int) []byte {
+	p := sourcePartition(len(message), sourceBlocks)
+	bitmap := make([]byte, (sourceBlocks+7)/8)
+	for i := 0; i < sourceBlocks; i++ {
+		b := block{data: message[p.Offset(i):p.Offset(i+1)]}
+		if b.zero() {
+			bitmap[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return bitmap
+}
+
+// PrefillZeroSymbols loads the source blocks marked in a ZeroSymbolBitmap
+// into a decoder created by one of the package's codecs for a message of
+// messageLength bytes. Returns the number of blocks loaded.
+func PrefillZeroSymbols(d Decoder, messageLength int, bitmap []byte) (int, error) {
+	s, ok := d.(sourcePreloader)
+	c := decoderCodec(d)
+	if !ok || c == nil {
+		return 0, fmt.Errorf("fountain: PrefillZeroSymbols doesn't support %T", d)
+	}
+	k := c.SourceBlocks()
+	if len(bitmap) != (k+7)/8 {
+		return 0, fmt.Errorf("fountain: zero symbol bitmap of %d bytes for %d source blocks", len(bitmap), k)
+	}
+	p := sourcePartition(messageLength, k)
+	loaded := 0
+	for i := 0; i < k; i++ {
+		if bitmap[i/8]&(1<<uint(i%8)) != 0 {
+			s.preloadSource(i, make([]byte, p.Size(i)))
+			loaded++
+		}
+	}
+	return loaded, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fountain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestZeroSymbolBitmap(t *testing.T) {
+	message := make([]byte, 100)
+	copy(message[10:], "x")
+	copy(message[95:], "y")
+
+	got := ZeroSymbolBitmap(message, 10)
+	if want := []byte{0xfd, 0x01}; !bytes.Equal(got, want) {
+		t.Errorf("ZeroSymbolBitmap = %x, want %x", got, want)
+	}
+}
+
+func TestEncodeZeroSymbols(t *testing.T) {
+	message := make([]byte, 100)
+	copy(message[25:], "some data")
+	c := NewRU10Codec(10, 1)
+
+	// Code blocks composed only of zero source blocks are still full length.
+	for _, b := range EncodeLTBlocks(append([]byte(nil), message...), []int64{0, 1, 2, 3, 4, 5}, c) {
+		if len(b.Data) != 10 {
+			t.Errorf("block %d has length %d, want 10", b.BlockCode, len(b.Data))
+		}
+	}
+}
+
+func TestPrefillZeroSymbols(t *testing.T) {
+	message := make([]byte, 100)
+	copy(message[21:], "some data")
+	copy(message[71:], "more data")
+	bitmap := ZeroSymbolBitmap(message, 10)
+
+	codecs := testCodecs()
+	for _, c := range codecs {
+		d := c.NewDecoder(len(message))
+		loaded, err := PrefillZeroSymbols(d, len(message), bitmap)
+		if err != nil {
+			t.Fatalf("%T: PrefillZeroSymbols failed: %v", c, err)
+		}
+		if loaded != 8 {
+			t.Errorf("%T: loaded %d blocks, want 8", c, loaded)
+		}
+
+		received := 0
+		for id := int64(0); id < 500; id++ {
+			received++
+			if d.AddBlocks(EncodeLTBlocks(append([]byte(nil), message...), []int64{id}, c)) {
+				break
+			}
+		}
+		if decoded := d.Decode(); !bytes.Equal(decoded, message) {
+			t.Errorf("%T: decoded %q, want %q", c, decoded, message)
+		}
+		t.Logf("%T: decoded after %d network blocks", c, received)
+	}
+
+	if _, err := PrefillZeroSymbols(NewBinaryCodec(20).NewDecoder(100), 100, bitmap); err == nil {
+		t.Errorf("PrefillZeroSymbols accepted a bitmap for the wrong number of blocks")
+	}
+}